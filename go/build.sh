@@ -47,6 +47,7 @@ export COMMANDS=(
 	socialapi/workers/cmd/collaboration
 	socialapi/workers/cmd/email/emailsender
 	socialapi/workers/cmd/team
+	socialapi/workers/cmd/slackbridge
 	vendor/github.com/koding/kite/kitectl
 	vendor/github.com/canthefason/go-watcher
 	vendor/github.com/mattes/migrate
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// States of a collaboration document.
+const (
+	// CollaborationDocActive is the state of documents that are being edited
+	CollaborationDocActive = "active"
+
+	// CollaborationDocSuspended is the state of documents whose session
+	// stopped pinging, they can be resumed by any participant
+	CollaborationDocSuspended = "suspended"
+
+	// CollaborationDocEnded is the state of documents whose session was
+	// ended by the host
+	CollaborationDocEnded = "ended"
+)
+
+// CollaborationDoc holds the latest snapshot of a collaborative editing
+// session document.
+type CollaborationDoc struct {
+	// FileId is the id that clients use for the collaboration session
+	FileId string `bson:"_id" json:"fileId"`
+
+	// ChannelId is the id of the collaboration channel
+	ChannelId int64 `bson:"channelId" json:"channelId,string"`
+
+	// HostId is the account id of the participant who hosts the session
+	HostId int64 `bson:"hostId" json:"hostId,string"`
+
+	State    string `bson:"state" json:"state"`
+	Content  string `bson:"content" json:"content"`
+	Revision int    `bson:"revision" json:"revision"`
+
+	CreatedAt   time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time `bson:"updatedAt" json:"updatedAt"`
+	SuspendedAt time.Time `bson:"suspendedAt,omitempty" json:"suspendedAt,omitempty"`
+
+	// Ops holds the most recent operations ordered by revision, they are
+	// stored with the content so both are updated at once
+	Ops []*CollaborationOp `bson:"ops,omitempty" json:"-"`
+}
+
+// CollaborationOp is an operation applied to a collaboration document, it
+// is stored within the document.
+type CollaborationOp struct {
+	FileId string `bson:"fileId" json:"fileId"`
+
+	// Revision is the revision of the document the operation was applied
+	// to, the document is at Revision+1 after the operation
+	Revision int `bson:"revision" json:"revision"`
+
+	AccountId int64 `bson:"accountId" json:"accountId,string"`
+
+	// Operation is the JSON encoded operation
+	Operation string `bson:"operation" json:"operation"`
+
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
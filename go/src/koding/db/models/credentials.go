@@ -23,8 +23,20 @@ type CredentialMeta struct {
 }
 
 type CredentialData struct {
-	Id         bson.ObjectId `bson:"_id" json:"-"`
-	Identifier string        `bson:"identifier"`
-	Meta       bson.M        `bson:"meta"`
-	OriginId   bson.ObjectId `bson:"originId"`
+	Id         bson.ObjectId             `bson:"_id" json:"-"`
+	Identifier string                    `bson:"identifier"`
+	Meta       bson.M                    `bson:"meta"`
+	OriginId   bson.ObjectId             `bson:"originId"`
+	Encryption *CredentialDataEncryption `bson:"encryption,omitempty"`
+}
+
+// CredentialDataEncryption describes encrypted credential data.
+//
+// When the credential data is encrypted, the Meta field
+// is empty and the data is kept in Ciphertext instead.
+type CredentialDataEncryption struct {
+	KeyID      string `bson:"keyId"`      // ID of the master key that encrypted DataKey
+	DataKey    []byte `bson:"dataKey"`    // data key, encrypted with the master key
+	Nonce      []byte `bson:"nonce"`      // nonce used to encrypt the data
+	Ciphertext []byte `bson:"ciphertext"` // BSON-encoded meta, encrypted with the data key
 }
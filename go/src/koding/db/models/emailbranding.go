@@ -0,0 +1,22 @@
+package models
+
+// EmailBranding holds the team specific look of the emails sent on behalf
+// of a group. It is stored within the group data under "emailBranding".
+type EmailBranding struct {
+	Name      string `json:"name,omitempty" bson:"name,omitempty"`
+	LogoURL   string `json:"logoURL,omitempty" bson:"logoURL,omitempty"`
+	Color     string `json:"color,omitempty" bson:"color,omitempty"`
+	FromName  string `json:"fromName,omitempty" bson:"fromName,omitempty"`
+	FromEmail string `json:"fromEmail,omitempty" bson:"fromEmail,omitempty"`
+	Footer    string `json:"footer,omitempty" bson:"footer,omitempty"`
+
+	// Templates overrides built-in email templates, keyed by template name.
+	Templates map[string]*EmailTemplate `json:"templates,omitempty" bson:"templates,omitempty"`
+}
+
+// EmailTemplate is a custom template for a single email type.
+type EmailTemplate struct {
+	Subject string `json:"subject,omitempty" bson:"subject,omitempty"`
+	HTML    string `json:"html,omitempty" bson:"html,omitempty"`
+	Text    string `json:"text,omitempty" bson:"text,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Reasons for suppressing emails to an address.
+const (
+	EmailSuppressionBounce    = "bounce"
+	EmailSuppressionComplaint = "complaint"
+)
+
+// EmailSuppression marks an email address no further mails should be sent
+// to, because it bounced or its owner complained.
+type EmailSuppression struct {
+	ObjectId  bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	Email     string        `bson:"email" json:"email"`
+	Reason    string        `bson:"reason" json:"reason"`
+	Details   string        `bson:"details,omitempty" json:"details,omitempty"`
+	Count     int           `bson:"count" json:"count"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// EmailDeadLetter stores an email that could not be delivered after all
+// retries were exhausted.
+type EmailDeadLetter struct {
+	ObjectId  bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	To        string        `bson:"to" json:"to"`
+	Subject   string        `bson:"subject" json:"subject"`
+	Mail      []byte        `bson:"mail" json:"mail"`
+	Error     string        `bson:"error" json:"error"`
+	Attempts  int           `bson:"attempts" json:"attempts"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SlackBridge configures mirroring of group channels to a Slack team. It is
+// stored within the group data under "slackBridge".
+type SlackBridge struct {
+	Enabled   bool                  `json:"enabled" bson:"enabled"`
+	TeamID    string                `json:"teamId" bson:"teamId"`
+	Token     string                `json:"-" bson:"token"`
+	BotUserID string                `json:"botUserId" bson:"botUserId"`
+	Channels  []*SlackBridgeChannel `json:"channels" bson:"channels"`
+}
+
+// SlackBridgeChannel pairs a socialapi channel with a Slack channel.
+type SlackBridgeChannel struct {
+	ChannelID      int64  `json:"channelId,string" bson:"channelId"`
+	SlackChannelID string `json:"slackChannelId" bson:"slackChannelId"`
+}
+
+// ByChannelID returns the Slack pair of the given socialapi channel.
+func (sb *SlackBridge) ByChannelID(id int64) *SlackBridgeChannel {
+	for _, ch := range sb.Channels {
+		if ch.ChannelID == id {
+			return ch
+		}
+	}
+
+	return nil
+}
+
+// BySlackChannelID returns the socialapi pair of the given Slack channel.
+func (sb *SlackBridge) BySlackChannelID(id string) *SlackBridgeChannel {
+	for _, ch := range sb.Channels {
+		if ch.SlackChannelID == id {
+			return ch
+		}
+	}
+
+	return nil
+}
+
+// SlackMessageLink links a socialapi message with its Slack copy, it is
+// used for threading replies on both sides.
+type SlackMessageLink struct {
+	ObjectId       bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	GroupName      string        `bson:"groupName" json:"groupName"`
+	MessageID      int64         `bson:"messageId" json:"messageId,string"`
+	SlackChannelID string        `bson:"slackChannelId" json:"slackChannelId"`
+	SlackTs        string        `bson:"slackTs" json:"slackTs"`
+	CreatedAt      time.Time     `bson:"createdAt" json:"createdAt"`
+}
@@ -14,6 +14,8 @@ type Snapshot struct {
 	StorageSize string        `bson:"storageSize"`
 	Region      string        `bson:"region"`
 	Label       string        `bson:"label"`
+	Provider    string        `bson:"provider,omitempty"`
+	Group       string        `bson:"group,omitempty"`
 	CreatedAt   time.Time     `bson:"createdAt"`
 	Username    string        `bson:"-"`
 }
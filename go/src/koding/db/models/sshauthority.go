@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SSHAuthority describes team SSH certificate authority.
+//
+// Authorities are kept apart from group data, which is readable by team
+// admins, and are accessed by kloud only. The private key of the authority
+// is stored in a credential data of the given identifier, which is
+// encrypted by kloud.
+type SSHAuthority struct {
+	ObjectId   bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	Team       string        `bson:"team" json:"team"`
+	Identifier string        `bson:"identifier" json:"-"`
+	CreatedAt  time.Time     `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,179 @@
+package modelhelper
+
+import (
+	"koding/db/models"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// CollaborationDocsColl holds the collection name for CollaborationDoc
+	CollaborationDocsColl = "jCollaborationDocs"
+
+	// CollaborationOpsHistory is the number of operations that are kept per
+	// document, clients that are behind more than that need to reload it
+	CollaborationOpsHistory = 1000
+)
+
+// EnsureCollaborationIndexes creates the indexes of the collaboration
+// collections.
+func EnsureCollaborationIndexes() error {
+	docs := mgo.Index{
+		Key:        []string{"state", "suspendedAt"},
+		Background: true,
+	}
+
+	return Mongo.EnsureIndex(CollaborationDocsColl, docs)
+}
+
+// CreateCollaborationDoc inserts a new document.
+func CreateCollaborationDoc(d *models.CollaborationDoc) error {
+	now := time.Now().UTC()
+	d.CreatedAt, d.UpdatedAt = now, now
+
+	if d.State == "" {
+		d.State = models.CollaborationDocActive
+	}
+
+	query := func(c *mgo.Collection) error {
+		return c.Insert(d)
+	}
+
+	return Mongo.Run(CollaborationDocsColl, query)
+}
+
+// GetCollaborationDoc fetches the document of the collaboration session
+// without its operations.
+func GetCollaborationDoc(fileId string) (*models.CollaborationDoc, error) {
+	var d models.CollaborationDoc
+
+	query := func(c *mgo.Collection) error {
+		return c.FindId(fileId).Select(bson.M{"ops": 0}).One(&d)
+	}
+
+	if err := Mongo.Run(CollaborationDocsColl, query); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// ApplyCollaborationOp sets the content of the document and stores the
+// operation that produced it if the document is still at the given
+// revision, its revision is incremented. Only the last
+// CollaborationOpsHistory operations are kept. mgo.ErrNotFound is returned
+// when the document was changed concurrently.
+//
+// The content and the operation are updated at once, so operations are
+// available to concurrent submitters as soon as the revision changes.
+func ApplyCollaborationOp(revision int, content string, op *models.CollaborationOp) error {
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = time.Now().UTC()
+	}
+
+	op.Revision = revision
+
+	selector := bson.M{"_id": op.FileId, "revision": revision}
+	update := bson.M{
+		"$set": bson.M{"content": content, "updatedAt": time.Now().UTC()},
+		"$inc": bson.M{"revision": 1},
+		"$push": bson.M{"ops": bson.M{
+			"$each":  []*models.CollaborationOp{op},
+			"$slice": -CollaborationOpsHistory,
+		}},
+	}
+
+	query := func(c *mgo.Collection) error {
+		return c.Update(selector, update)
+	}
+
+	return Mongo.Run(CollaborationDocsColl, query)
+}
+
+// SuspendCollaborationDoc marks the active document as suspended.
+func SuspendCollaborationDoc(fileId string) error {
+	selector := bson.M{"_id": fileId, "state": models.CollaborationDocActive}
+	update := bson.M{"$set": bson.M{
+		"state":       models.CollaborationDocSuspended,
+		"suspendedAt": time.Now().UTC(),
+	}}
+
+	return updateCollaborationDoc(selector, update)
+}
+
+// ResumeCollaborationDoc marks the document as active and sets its host.
+func ResumeCollaborationDoc(fileId string, hostId int64) error {
+	selector := bson.M{"_id": fileId, "state": bson.M{"$ne": models.CollaborationDocEnded}}
+	update := bson.M{
+		"$set":   bson.M{"state": models.CollaborationDocActive, "hostId": hostId},
+		"$unset": bson.M{"suspendedAt": ""},
+	}
+
+	return updateCollaborationDoc(selector, update)
+}
+
+// EndCollaborationDoc marks the document as ended.
+func EndCollaborationDoc(fileId string) error {
+	selector := bson.M{"_id": fileId}
+	update := bson.M{"$set": bson.M{"state": models.CollaborationDocEnded}}
+
+	return updateCollaborationDoc(selector, update)
+}
+
+func updateCollaborationDoc(selector, update bson.M) error {
+	query := func(c *mgo.Collection) error {
+		return c.Update(selector, update)
+	}
+
+	return Mongo.Run(CollaborationDocsColl, query)
+}
+
+// GetSuspendedCollaborationDocs fetches the documents that are suspended
+// before the given time.
+func GetSuspendedCollaborationDocs(before time.Time) ([]*models.CollaborationDoc, error) {
+	var docs []*models.CollaborationDoc
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(bson.M{
+			"state":       models.CollaborationDocSuspended,
+			"suspendedAt": bson.M{"$lt": before},
+		}).Select(bson.M{"content": 0, "ops": 0}).All(&docs)
+	}
+
+	return docs, Mongo.Run(CollaborationDocsColl, query)
+}
+
+// DeleteCollaborationDoc removes the document along with its operations.
+func DeleteCollaborationDoc(fileId string) error {
+	query := func(c *mgo.Collection) error {
+		return c.RemoveId(fileId)
+	}
+
+	return Mongo.Run(CollaborationDocsColl, query)
+}
+
+// GetCollaborationOps fetches the operations of the document that were
+// applied at or after the given revision, ordered by revision.
+func GetCollaborationOps(fileId string, since int) ([]*models.CollaborationOp, error) {
+	var d models.CollaborationDoc
+
+	query := func(c *mgo.Collection) error {
+		return c.FindId(fileId).Select(bson.M{"ops": 1}).One(&d)
+	}
+
+	if err := Mongo.Run(CollaborationDocsColl, query); err != nil {
+		return nil, err
+	}
+
+	var ops []*models.CollaborationOp
+
+	for _, op := range d.Ops {
+		if op.Revision >= since {
+			ops = append(ops, op)
+		}
+	}
+
+	return ops, nil
+}
@@ -65,6 +65,23 @@ func GetCredentialDatasFromIdentifiers(identifier ...string) ([]*models.Credenti
 	return credentialData, nil
 }
 
+// ForEachCredentialData calls fn for each credential data matching
+// the given selector. The iteration stops when fn returns non-nil error.
+func ForEachCredentialData(s Selector, fn func(*models.CredentialData) error) error {
+	return Mongo.Run(CredentialDatasColl, func(c *mgo.Collection) error {
+		iter := c.Find(s).Iter()
+
+		for data := new(models.CredentialData); iter.Next(data); data = new(models.CredentialData) {
+			if err := fn(data); err != nil {
+				iter.Close()
+				return err
+			}
+		}
+
+		return iter.Close()
+	})
+}
+
 func InsertCredential(cred *models.Credential, data *models.CredentialData) error {
 	err := Mongo.Run(CredentialsColl, func(c *mgo.Collection) error {
 		return c.Insert(cred)
@@ -77,6 +94,19 @@ func InsertCredential(cred *models.Credential, data *models.CredentialData) erro
 	})
 }
 
+// CreateCredentialData creates an empty credential data of the given
+// identifier, which is not owned by any account. Its value is set
+// with UpdateCredentialData.
+func CreateCredentialData(identifier string) error {
+	return Mongo.Run(CredentialDatasColl, func(c *mgo.Collection) error {
+		return c.Insert(bson.M{
+			"_id":        bson.NewObjectId(),
+			"identifier": identifier,
+			"meta":       bson.M{},
+		})
+	})
+}
+
 func UpdateCredentialData(identifier string, data bson.M) error {
 	return Mongo.Run(CredentialDatasColl, func(c *mgo.Collection) error {
 		return c.Update(bson.M{"identifier": identifier}, data)
@@ -0,0 +1,80 @@
+package modelhelper
+
+import (
+	"koding/db/models"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	EmailSuppressionsColl = "jEmailSuppressions"
+	EmailDeadLettersColl  = "jEmailDeadLetters"
+)
+
+// SuppressEmail marks the given address as not deliverable. Suppressing
+// already suppressed address increments its counter.
+func SuppressEmail(email, reason, details string) error {
+	now := time.Now().UTC()
+
+	query := func(c *mgo.Collection) error {
+		_, err := c.Upsert(
+			bson.M{"email": strings.ToLower(email)},
+			bson.M{
+				"$set": bson.M{
+					"reason":    reason,
+					"details":   details,
+					"updatedAt": now,
+				},
+				"$setOnInsert": bson.M{
+					"createdAt": now,
+				},
+				"$inc": bson.M{
+					"count": 1,
+				},
+			},
+		)
+		return err
+	}
+
+	return Mongo.Run(EmailSuppressionsColl, query)
+}
+
+// GetEmailSuppression fetches suppression of the given address.
+func GetEmailSuppression(email string) (*models.EmailSuppression, error) {
+	var s models.EmailSuppression
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(bson.M{"email": strings.ToLower(email)}).One(&s)
+	}
+
+	if err := Mongo.Run(EmailSuppressionsColl, query); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// RemoveEmailSuppression allows sending emails to the given address again.
+func RemoveEmailSuppression(email string) error {
+	query := func(c *mgo.Collection) error {
+		return c.Remove(bson.M{"email": strings.ToLower(email)})
+	}
+
+	return Mongo.Run(EmailSuppressionsColl, query)
+}
+
+// CreateEmailDeadLetter stores an undelivered email.
+func CreateEmailDeadLetter(dl *models.EmailDeadLetter) error {
+	if dl.ObjectId == "" {
+		dl.ObjectId = bson.NewObjectId()
+	}
+
+	query := func(c *mgo.Collection) error {
+		return c.Insert(dl)
+	}
+
+	return Mongo.Run(EmailDeadLettersColl, query)
+}
@@ -24,6 +24,15 @@ func GetGroupById(id string) (*models.Group, error) {
 	})
 }
 
+// GetMachineGroup returns the group the given machine belongs to.
+func GetMachineGroup(m *models.Machine) (*models.Group, error) {
+	if len(m.Groups) == 0 || !m.Groups[0].Id.Valid() {
+		return nil, mgo.ErrNotFound
+	}
+
+	return GetGroupById(m.Groups[0].Id.Hex())
+}
+
 // GetGroupsByIds returns groups by their given IDs
 func GetGroupsByIds(ids ...bson.ObjectId) ([]*models.Group, error) {
 	var groups []*models.Group
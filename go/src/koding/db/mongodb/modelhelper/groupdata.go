@@ -70,3 +70,17 @@ func FetchCountlyInfo(slug string) (*models.Countly, error) {
 	}
 	return res.Payload.Countly, nil
 }
+
+// FetchEmailBranding gets the email branding data for a given group
+func FetchEmailBranding(slug string) (*models.EmailBranding, error) {
+	type branding struct {
+		Payload struct {
+			EmailBranding *models.EmailBranding `bson:"emailBranding"`
+		}
+	}
+	res := &branding{}
+	if err := GetGroupDataPath(slug, "emailBranding", res); err != nil {
+		return nil, err
+	}
+	return res.Payload.EmailBranding, nil
+}
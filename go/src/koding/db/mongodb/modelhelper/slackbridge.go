@@ -0,0 +1,137 @@
+package modelhelper
+
+import (
+	"koding/db/models"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SlackMessageLinksColl holds the collection name for SlackMessageLink model.
+const SlackMessageLinksColl = "jSlackMessageLinks"
+
+type slackBridgeData struct {
+	Slug    string `bson:"slug"`
+	Payload struct {
+		SlackBridge *models.SlackBridge `bson:"slackBridge"`
+	} `bson:"payload"`
+}
+
+// FetchSlackBridge gets the Slack bridge configuration of a group.
+func FetchSlackBridge(slug string) (*models.SlackBridge, error) {
+	res := &slackBridgeData{}
+	if err := GetGroupDataPath(slug, "slackBridge", res); err != nil {
+		return nil, err
+	}
+
+	if res.Payload.SlackBridge == nil {
+		return nil, mgo.ErrNotFound
+	}
+
+	return res.Payload.SlackBridge, nil
+}
+
+// FetchSlackBridgeByTeamID gets the Slack bridge configuration of a group
+// that is bridged with the given Slack team.
+func FetchSlackBridgeByTeamID(teamID string) (string, *models.SlackBridge, error) {
+	res := &slackBridgeData{}
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(bson.M{"payload.slackBridge.teamId": teamID}).
+			Select(bson.M{"slug": 1, "payload.slackBridge": 1}).
+			One(res)
+	}
+
+	if err := Mongo.Run(GroupDataColl, query); err != nil {
+		return "", nil, err
+	}
+
+	return res.Slug, res.Payload.SlackBridge, nil
+}
+
+// UpsertSlackBridge creates or updates Slack bridge configuration of a group.
+func UpsertSlackBridge(slug string, sb *models.SlackBridge) error {
+	return UpsertGroupData(slug, "slackBridge", sb)
+}
+
+// EnsureSlackMessageLinkIndexes creates the indexes of the message links
+// collection. Slack messages are unique per channel and timestamp, which
+// guards mirroring of redelivered events.
+func EnsureSlackMessageLinkIndexes() error {
+	slack := mgo.Index{
+		Key:        []string{"slackChannelId", "slackTs"},
+		Unique:     true,
+		Background: true,
+	}
+
+	if err := Mongo.EnsureIndex(SlackMessageLinksColl, slack); err != nil {
+		return err
+	}
+
+	messages := mgo.Index{
+		Key:        []string{"messageId"},
+		Background: true,
+	}
+
+	return Mongo.EnsureIndex(SlackMessageLinksColl, messages)
+}
+
+// CreateSlackMessageLink stores the link between socialapi and Slack messages.
+func CreateSlackMessageLink(l *models.SlackMessageLink) error {
+	if l.ObjectId == "" {
+		l.ObjectId = bson.NewObjectId()
+	}
+
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = time.Now().UTC()
+	}
+
+	query := func(c *mgo.Collection) error {
+		return c.Insert(l)
+	}
+
+	return Mongo.Run(SlackMessageLinksColl, query)
+}
+
+// UpdateSlackMessageLinkMessageID sets the socialapi message of the link.
+func UpdateSlackMessageLinkMessageID(id bson.ObjectId, messageID int64) error {
+	query := func(c *mgo.Collection) error {
+		return c.UpdateId(id, bson.M{"$set": bson.M{"messageId": messageID}})
+	}
+
+	return Mongo.Run(SlackMessageLinksColl, query)
+}
+
+// DeleteSlackMessageLink removes the link with the given id.
+func DeleteSlackMessageLink(id bson.ObjectId) error {
+	query := func(c *mgo.Collection) error {
+		return c.RemoveId(id)
+	}
+
+	return Mongo.Run(SlackMessageLinksColl, query)
+}
+
+// GetSlackMessageLinkByMessageID fetches the link of the socialapi message.
+func GetSlackMessageLinkByMessageID(messageID int64) (*models.SlackMessageLink, error) {
+	return getSlackMessageLink(bson.M{"messageId": messageID})
+}
+
+// GetSlackMessageLinkBySlackTs fetches the link of the Slack message.
+func GetSlackMessageLinkBySlackTs(slackChannelID, ts string) (*models.SlackMessageLink, error) {
+	return getSlackMessageLink(bson.M{"slackChannelId": slackChannelID, "slackTs": ts})
+}
+
+func getSlackMessageLink(selector bson.M) (*models.SlackMessageLink, error) {
+	var l models.SlackMessageLink
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(selector).One(&l)
+	}
+
+	if err := Mongo.Run(SlackMessageLinksColl, query); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
@@ -29,3 +29,60 @@ func DeleteSnapshot(snapshotId string) error {
 
 	return Mongo.Run(SnapshotCol, query)
 }
+
+// CreateSnapshot inserts the given snapshot.
+func CreateSnapshot(s *models.Snapshot) error {
+	query := insertQuery(s)
+	return Mongo.Run(SnapshotCol, query)
+}
+
+// GetSnapshotsByMachineId returns snapshots of the given machine, the oldest
+// one is returned first.
+func GetSnapshotsByMachineId(machineId bson.ObjectId) ([]*models.Snapshot, error) {
+	var snapshots []*models.Snapshot
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(bson.M{"machineId": machineId}).Sort("createdAt").All(&snapshots)
+	}
+
+	if err := Mongo.Run(SnapshotCol, query); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// CountSnapshotsByGroup returns the number of snapshots that belong to the
+// machines of the given group.
+func CountSnapshotsByGroup(slug string) (count int, err error) {
+	query := func(c *mgo.Collection) error {
+		count, err = c.Find(bson.M{"group": slug}).Count()
+		return err
+	}
+
+	return count, Mongo.Run(SnapshotCol, query)
+}
+
+// FetchSnapshotLimit gets the maximum number of snapshots that the given group
+// is allowed to have, zero is returned if the group does not set a limit.
+func FetchSnapshotLimit(slug string) (int, error) {
+	type limit struct {
+		Payload struct {
+			Snapshots struct {
+				Limit int `bson:"limit"`
+			} `bson:"snapshots"`
+		}
+	}
+
+	res := &limit{}
+	err := GetGroupDataPath(slug, "snapshots.limit", res)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.Payload.Snapshots.Limit, nil
+}
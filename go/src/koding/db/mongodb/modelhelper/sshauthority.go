@@ -0,0 +1,68 @@
+package modelhelper
+
+import (
+	"time"
+
+	"koding/db/models"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SSHAuthoritiesColl holds the collection name for SSHAuthority model.
+const SSHAuthoritiesColl = "jSSHAuthorities"
+
+// EnsureSSHAuthorityIndexes creates the indexes of the SSH authorities
+// collection. The unique team index guarantees concurrent upserts never
+// create more than one authority per team.
+func EnsureSSHAuthorityIndexes() error {
+	index := mgo.Index{
+		Key:        []string{"team"},
+		Unique:     true,
+		Background: true,
+	}
+
+	return Mongo.EnsureIndex(SSHAuthoritiesColl, index)
+}
+
+// GetSSHAuthority fetches SSH certificate authority of the given team.
+func GetSSHAuthority(team string) (*models.SSHAuthority, error) {
+	var sa models.SSHAuthority
+
+	query := func(c *mgo.Collection) error {
+		return c.Find(bson.M{"team": team}).One(&sa)
+	}
+
+	if err := Mongo.Run(SSHAuthoritiesColl, query); err != nil {
+		return nil, err
+	}
+
+	return &sa, nil
+}
+
+// CreateSSHAuthority stores SSH certificate authority of the given team,
+// which private key is kept in the identifier credential data, unless
+// the team already has one. The stored authority is returned, which may
+// be the one created by a concurrent caller.
+func CreateSSHAuthority(team, identifier string) (*models.SSHAuthority, error) {
+	query := func(c *mgo.Collection) error {
+		_, err := c.Upsert(
+			bson.M{"team": team},
+			bson.M{
+				"$setOnInsert": bson.M{
+					"identifier": identifier,
+					"createdAt":  time.Now().UTC(),
+				},
+			},
+		)
+		return err
+	}
+
+	// Losing the race for the unique index is not an error, the authority
+	// of the winner is used instead.
+	if err := Mongo.Run(SSHAuthoritiesColl, query); err != nil && !mgo.IsDup(err) {
+		return nil, err
+	}
+
+	return GetSSHAuthority(team)
+}
@@ -0,0 +1,48 @@
+package modelhelper_test
+
+import (
+	"koding/db/mongodb/modelhelper"
+	"koding/db/mongodb/modelhelper/modeltesthelper"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestCreateSSHAuthority(t *testing.T) {
+	db := modeltesthelper.NewMongoDB(t)
+	defer db.Close()
+
+	if err := modelhelper.EnsureSSHAuthorityIndexes(); err != nil {
+		t.Fatalf("EnsureSSHAuthorityIndexes() = %v, want %v", err, nil)
+	}
+
+	team := bson.NewObjectId().Hex()
+
+	sa, err := modelhelper.CreateSSHAuthority(team, "ident1")
+	if err != nil {
+		t.Fatalf("CreateSSHAuthority() = %v, want %v", err, nil)
+	}
+
+	if sa.Identifier != "ident1" {
+		t.Fatalf("sa.Identifier = %v, want %v", sa.Identifier, "ident1")
+	}
+
+	// Existing authority must never be overwritten.
+	sa, err = modelhelper.CreateSSHAuthority(team, "ident2")
+	if err != nil {
+		t.Fatalf("CreateSSHAuthority() = %v, want %v", err, nil)
+	}
+
+	if sa.Identifier != "ident1" {
+		t.Fatalf("sa.Identifier = %v, want %v", sa.Identifier, "ident1")
+	}
+
+	sa, err = modelhelper.GetSSHAuthority(team)
+	if err != nil {
+		t.Fatalf("GetSSHAuthority() = %v, want %v", err, nil)
+	}
+
+	if sa.Identifier != "ident1" {
+		t.Fatalf("sa.Identifier = %v, want %v", sa.Identifier, "ident1")
+	}
+}
@@ -42,5 +42,11 @@ func main() {
 		die(err)
 	}
 
+	if opts.TLSPort != 0 {
+		go func() {
+			die(server.ListenAndServeTLS())
+		}()
+	}
+
 	k.Run()
 }
@@ -37,12 +37,13 @@ type Client struct {
 	Home      string               // uses config.KodingHome by default
 	Mounts    string               // uses config.KodingMounts by default
 	Owner     *config.User         // uses config.CurrentUser by default
+	Dir       string               // if non-empty, config.ProfileFile is looked up from Dir upwards
 
 	once sync.Once // for c.init()
 }
 
 type usedKonfig struct {
-	ID string `json:"id"`
+	ID string `json:"id"` // either ID or name of a profile
 }
 
 func (c *Client) List() (k config.Konfigs) {
@@ -61,10 +62,10 @@ func (c *Client) Read(e *config.Environments) *config.Konfig {
 	k := config.NewKonfig(e)
 
 	_ = c.commit(func(cache *config.Cache) error {
-		var used usedKonfig
 		var konfigs = make(config.Konfigs)
 
-		if err := cache.GetValue("konfigs.used", &used); err != nil {
+		key, err := c.usedKey(cache)
+		if err != nil {
 			return err
 		}
 
@@ -72,7 +73,12 @@ func (c *Client) Read(e *config.Environments) *config.Konfig {
 			return err
 		}
 
-		if mixin, ok := konfigs[used.ID]; ok {
+		if _, ok := konfigs[key]; ok {
+			mixin, err := konfigs.Resolve(key)
+			if err != nil {
+				return err
+			}
+
 			if err := mergeIn(k, mixin); err != nil {
 				return err
 			}
@@ -87,8 +93,12 @@ func (c *Client) Read(e *config.Environments) *config.Konfig {
 func (c *Client) Use(k *config.Konfig) error {
 	c.init()
 
-	if err := k.Valid(); err != nil {
-		return err
+	// Profiles which inherit from other configurations
+	// are validated after resolving.
+	if k.Inherits == "" {
+		if err := k.Valid(); err != nil {
+			return err
+		}
 	}
 
 	return c.commit(makeUseFunc(k))
@@ -99,7 +109,7 @@ func (c *Client) Used() (*config.Konfig, error) {
 
 	var konfig config.Konfig
 
-	if err := c.commit(makeUsedFunc(&konfig)); err != nil {
+	if err := c.commit(c.makeUsedFunc(&konfig)); err != nil {
 		return nil, err
 	}
 
@@ -142,10 +152,10 @@ func (c *Client) CacheOptions(app string) *config.CacheOptions {
 
 func (c *Client) Set(key, value string) error {
 	return c.commit(func(cache *config.Cache) error {
-		var used usedKonfig
 		var konfigs = make(config.Konfigs)
 
-		if err := cache.GetValue("konfigs.used", &used); err != nil {
+		key, err := c.usedKey(cache)
+		if err != nil {
 			return err
 		}
 
@@ -153,7 +163,7 @@ func (c *Client) Set(key, value string) error {
 			return err
 		}
 
-		k, ok := konfigs[used.ID]
+		k, ok := konfigs[key]
 		if !ok {
 			return storage.ErrKeyNotFound
 		}
@@ -183,6 +193,39 @@ func (c *Client) initClient() {
 	})
 }
 
+// Profile gives the configuration profile selected by config.ProfileFile
+// found in c.Dir or any of its parent directories, together
+// with the path of the file.
+//
+// If there is no profile file, it returns os.ErrNotExist.
+func (c *Client) Profile() (string, *config.Profile, error) {
+	if c.Dir == "" {
+		return "", nil, os.ErrNotExist
+	}
+
+	return config.FindProfile(c.Dir)
+}
+
+// usedKey gives a key of the configuration currently in use, which
+// is either a profile selected by config.ProfileFile or the one
+// set with the last Use call.
+func (c *Client) usedKey(cache *config.Cache) (string, error) {
+	switch _, p, err := c.Profile(); {
+	case err == nil:
+		return p.Profile, nil
+	case !os.IsNotExist(err):
+		return "", err
+	}
+
+	var used usedKonfig
+
+	if err := cache.GetValue("konfigs.used", &used); err != nil {
+		return "", err
+	}
+
+	return used.ID, nil
+}
+
 func (c *Client) boltFile(app string) string {
 	if used, err := c.Used(); err == nil && app != "konfig" {
 		return filepath.Join(config.KodingHome(), app+"."+used.ID()+".bolt")
@@ -237,11 +280,10 @@ func (c *Client) owner() *config.User {
 	return config.CurrentUser
 }
 
-func makeUsedFunc(konfig *config.Konfig) func(cache *config.Cache) error {
+func (c *Client) makeUsedFunc(konfig *config.Konfig) func(cache *config.Cache) error {
 	return func(cache *config.Cache) error {
-		var used usedKonfig
-
-		if err := cache.GetValue("konfigs.used", &used); err != nil {
+		key, err := c.usedKey(cache)
+		if err != nil {
 			return err
 		}
 
@@ -251,12 +293,17 @@ func makeUsedFunc(konfig *config.Konfig) func(cache *config.Cache) error {
 			return err
 		}
 
-		if k, ok := konfigs[used.ID]; ok {
-			*konfig = *k
-			return nil
+		if _, ok := konfigs[key]; !ok {
+			return errors.New("config not found - use one that exists")
+		}
+
+		k, err := konfigs.Resolve(key)
+		if err != nil {
+			return err
 		}
 
-		return errors.New("config not found - use one that exists")
+		*konfig = *k
+		return nil
 	}
 }
 
@@ -268,13 +315,22 @@ func makeUseFunc(konfig *config.Konfig) func(cache *config.Cache) error {
 			return err
 		}
 
-		id := konfig.ID()
+		key := konfig.Key()
+
+		konfigs[key] = konfigs.Trim(konfig)
 
-		konfigs[id] = konfig
+		k, err := konfigs.Resolve(key)
+		if err != nil {
+			return err
+		}
+
+		if err := k.Valid(); err != nil {
+			return err
+		}
 
 		return nonil(
 			cache.SetValue("konfigs", konfigs),
-			cache.SetValue("konfigs.used", &usedKonfig{ID: id}),
+			cache.SetValue("konfigs.used", &usedKonfig{ID: key}),
 		)
 	}
 }
@@ -92,6 +92,9 @@ type Mount struct {
 	// Sync configures behavior of synchronization goroutines.
 	Sync *MountSync `json:"sync,omitempty"`
 
+	// Prefetch configures initial download of mounted files.
+	Prefetch *MountPrefetch `json:"prefetch,omitempty"`
+
 	// Debug is a debug level used for logging within
 	// mounts.
 	//
@@ -117,6 +120,14 @@ type MountSync struct {
 	Workers int `json:"workers,omitempty,string"`
 }
 
+// MountPrefetch describes configuration of mount prefetching.
+type MountPrefetch struct {
+	// Budget limits the size of files prefetched by selective
+	// strategies. When zero or when remote directory is smaller
+	// than the budget, all files are prefetched.
+	Budget int64 `json:"budget,omitempty,string"`
+}
+
 // Export gives a path for the named mount.
 //
 // If the named mount does not exist, it returns false.
@@ -143,6 +154,16 @@ type Template struct {
 // Konfig represents a single configuration stored
 // in a konfig.bolt database.
 type Konfig struct {
+	// Name is a name of the configuration profile.
+	//
+	// If empty, the configuration is not a named
+	// profile and it is identified by its ID.
+	Name string `json:"name,omitempty"`
+
+	// Inherits is a key of the configuration, which
+	// Endpoints, Mount and Template are inherited from.
+	Inherits string `json:"inherits,omitempty"`
+
 	Endpoints *Endpoints `json:"endpoints,omitempty"`
 
 	KontrolURL string `json:"kontrolURL,omitempty"` // deprecated / read-only
@@ -225,6 +246,19 @@ func (k *Konfig) ID() string {
 	return ID(k.KodingPublic().String())
 }
 
+// Deployment gives a human readable name of the Koding deployment
+// the configuration points to, which is a host name of the Koding
+// base URL.
+//
+// Profiles that point to the same Koding base URL share
+// the deployment name.
+func (k *Konfig) Deployment() string {
+	if u := k.KodingPublic(); u != nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return k.ID()
+}
+
 // ID creates an identifier for the given Koding base URL.
 func ID(kodingURL string) string {
 	if kodingURL == "" {
@@ -319,6 +353,43 @@ func (kfg Konfigs) Slice() []*Konfig {
 	return slice
 }
 
+// Deployments gives resolved configurations, one for each
+// Koding deployment.
+//
+// Named profiles which point to the same Koding base URL as
+// other configurations are skipped.
+func (kfg Konfigs) Deployments() []*Konfig {
+	keys := make([]string, 0, len(kfg))
+	for k := range kfg {
+		keys = append(keys, k)
+	}
+
+	// Prefer unnamed configurations over profiles.
+	sort.Slice(keys, func(i, j int) bool {
+		if ni, nj := kfg[keys[i]].Name != "", kfg[keys[j]].Name != ""; ni != nj {
+			return nj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var (
+		seen  = make(map[string]bool)
+		slice []*Konfig
+	)
+
+	for _, key := range keys {
+		k, err := kfg.Resolve(key)
+		if err != nil || k.KodingPublic() == nil || seen[k.ID()] {
+			continue
+		}
+
+		seen[k.ID()] = true
+		slice = append(slice, k)
+	}
+
+	return slice
+}
+
 // Environment is a hacky workaround for kd <-> klient environments.
 // The managed klient expects to have kd from production channel,
 // and devmanaged klient - from development. Depending from which
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ProfileFile is a name of the file, which selects a configuration
+// profile for a directory tree.
+//
+// KD looks for the file starting from current working directory
+// up to the root.
+const ProfileFile = ".kd.yml"
+
+// Profile represents content of the ProfileFile.
+type Profile struct {
+	// Profile is a name of the configuration profile
+	// used within the directory.
+	Profile string `yaml:"profile"`
+}
+
+// FindProfile looks for the ProfileFile starting from the given
+// directory and walking up to the root.
+//
+// It returns the path of the first file found and its content.
+// If there is no ProfileFile in any of the parent directories,
+// it returns os.ErrNotExist.
+func FindProfile(dir string) (string, *Profile, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for {
+		file := filepath.Join(dir, ProfileFile)
+
+		if p, err := ioutil.ReadFile(file); err == nil {
+			var profile Profile
+
+			if err := yaml.Unmarshal(p, &profile); err != nil {
+				return "", nil, fmt.Errorf("unable to read %s: %s", file, err)
+			}
+
+			if profile.Profile == "" {
+				return "", nil, fmt.Errorf("no profile set in %s", file)
+			}
+
+			return file, &profile, nil
+		} else if !os.IsNotExist(err) {
+			return "", nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil, os.ErrNotExist
+		}
+
+		dir = parent
+	}
+}
+
+// Key gives a key under which the configuration is stored
+// in a konfig.bolt database.
+//
+// Named profiles are keyed by their names, other
+// configurations by their IDs.
+func (k *Konfig) Key() string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return k.ID()
+}
+
+// Resolve gives the configuration stored under the given key
+// with Endpoints, Mount and Template fields inherited from
+// its base profiles.
+//
+// Fields set explicitly by a profile take precedence over
+// fields of its base.
+func (kfg Konfigs) Resolve(key string) (*Konfig, error) {
+	k, ok := kfg[key]
+	if !ok {
+		return nil, fmt.Errorf("config %q not found", key)
+	}
+
+	resolved := *k
+	seen := map[string]bool{key: true}
+	chain := []string{key}
+
+	for base := k.Inherits; base != ""; {
+		if seen[base] {
+			return nil, fmt.Errorf("config %q has an inheritance cycle: %s", key,
+				strings.Join(append(chain, base), " -> "))
+		}
+
+		b, ok := kfg[base]
+		if !ok {
+			return nil, fmt.Errorf("config %q inherits from %q, which does not exist", key, base)
+		}
+
+		resolved.inherit(b)
+
+		seen[base] = true
+		chain = append(chain, base)
+		base = b.Inherits
+	}
+
+	return &resolved, nil
+}
+
+// Trim gives a copy of the given profile with Endpoints, Mount
+// and Template fields cleared, if they are equal to the ones
+// inherited from its base, so the profile keeps following
+// later changes of the base.
+//
+// It is used to store back a configuration obtained from Resolve.
+func (kfg Konfigs) Trim(k *Konfig) *Konfig {
+	if k.Inherits == "" {
+		return k
+	}
+
+	base, err := kfg.Resolve(k.Inherits)
+	if err != nil {
+		return k
+	}
+
+	trimmed := *k
+	k = &trimmed
+
+	if e, b := k.Endpoints, base.Endpoints; e != nil && b != nil {
+		endpoints := *e
+		e = &endpoints
+
+		e.Koding = trimEndpoint(e.Koding, b.Koding)
+		e.Tunnel = trimEndpoint(e.Tunnel, b.Tunnel)
+		e.IP = trimEndpoint(e.IP, b.IP)
+		e.IPCheck = trimEndpoint(e.IPCheck, b.IPCheck)
+		e.KlientLatest = trimEndpoint(e.KlientLatest, b.KlientLatest)
+		e.KDLatest = trimEndpoint(e.KDLatest, b.KDLatest)
+		e.Klient = trimEndpoint(e.Klient, b.Klient)
+
+		if reflect.DeepEqual(e, &Endpoints{}) {
+			e = nil
+		}
+
+		k.Endpoints = e
+	}
+
+	if reflect.DeepEqual(k.Mount, base.Mount) {
+		k.Mount = nil
+	}
+
+	if reflect.DeepEqual(k.Template, base.Template) {
+		k.Template = nil
+	}
+
+	return k
+}
+
+func trimEndpoint(e, base *Endpoint) *Endpoint {
+	if e != nil && base != nil && e.Equal(base) {
+		return nil
+	}
+	return e
+}
+
+// inherit sets each unset Endpoints, Mount and Template field
+// of k to the value from the base configuration.
+func (k *Konfig) inherit(base *Konfig) {
+	k.Endpoints = k.Endpoints.inherit(base.Endpoints)
+	k.Mount = k.Mount.inherit(base.Mount)
+	k.Template = k.Template.inherit(base.Template)
+}
+
+func (e *Endpoints) inherit(base *Endpoints) *Endpoints {
+	if base == nil {
+		return e
+	}
+
+	if e == nil {
+		e = &Endpoints{}
+	}
+
+	return &Endpoints{
+		Koding:       inheritEndpoint(e.Koding, base.Koding),
+		Tunnel:       inheritEndpoint(e.Tunnel, base.Tunnel),
+		IP:           inheritEndpoint(e.IP, base.IP),
+		IPCheck:      inheritEndpoint(e.IPCheck, base.IPCheck),
+		KlientLatest: inheritEndpoint(e.KlientLatest, base.KlientLatest),
+		KDLatest:     inheritEndpoint(e.KDLatest, base.KDLatest),
+		Klient:       inheritEndpoint(e.Klient, base.Klient),
+	}
+}
+
+func inheritEndpoint(e, base *Endpoint) *Endpoint {
+	if e != nil {
+		return e
+	}
+	return base.Copy()
+}
+
+func (m *Mount) inherit(base *Mount) *Mount {
+	if base == nil {
+		return m
+	}
+
+	if m == nil {
+		m = &Mount{}
+	}
+
+	mount := *m
+
+	if mount.Home == "" {
+		mount.Home = base.Home
+	}
+
+	if len(base.Exports) != 0 {
+		mount.Exports = make(map[string]string, len(m.Exports)+len(base.Exports))
+
+		for name, dir := range base.Exports {
+			mount.Exports[name] = dir
+		}
+
+		for name, dir := range m.Exports {
+			mount.Exports[name] = dir
+		}
+	}
+
+	if mount.Inspect == nil && base.Inspect != nil {
+		inspect := *base.Inspect
+		mount.Inspect = &inspect
+	}
+
+	if mount.Sync == nil && base.Sync != nil {
+		sync := *base.Sync
+		mount.Sync = &sync
+	}
+
+	if mount.Debug == 0 {
+		mount.Debug = base.Debug
+	}
+
+	return &mount
+}
+
+func (t *Template) inherit(base *Template) *Template {
+	if base == nil || (t != nil && t.File != "") {
+		return t
+	}
+
+	template := *base
+	return &template
+}
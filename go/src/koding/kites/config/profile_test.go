@@ -0,0 +1,154 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKonfigsResolve(t *testing.T) {
+	base := &Konfig{
+		Endpoints: &Endpoints{
+			Koding: NewEndpoint("https://koding.com"),
+			Tunnel: NewEndpoint("https://tunnel.koding.com"),
+		},
+		Mount: &Mount{
+			Home:    "~/koding/mnt",
+			Exports: map[string]string{"default": "~", "src": "~/src"},
+		},
+		Template: &Template{File: "kd.yaml"},
+	}
+
+	konfigs := Konfigs{
+		base.ID(): base,
+		"staging": {
+			Name:     "staging",
+			Inherits: base.ID(),
+			Endpoints: &Endpoints{
+				Koding: NewEndpoint("https://staging.koding.com"),
+			},
+			Mount: &Mount{
+				Exports: map[string]string{"src": "~/work"},
+			},
+		},
+		"dev": {
+			Name:     "dev",
+			Inherits: "staging",
+			Template: &Template{File: "dev.yaml"},
+		},
+		"a": {Name: "a", Inherits: "b"},
+		"b": {Name: "b", Inherits: "a"},
+	}
+
+	k, err := konfigs.Resolve("dev")
+	if err != nil {
+		t.Fatalf("Resolve()=%s", err)
+	}
+
+	if got, want := k.KodingPublic().String(), "https://staging.koding.com"; got != want {
+		t.Errorf("got %q, want %q koding URL", got, want)
+	}
+
+	if got, want := k.Endpoints.Tunnel.Public.String(), "https://tunnel.koding.com"; got != want {
+		t.Errorf("got %q, want %q tunnel URL", got, want)
+	}
+
+	wantMount := &Mount{
+		Home:    "~/koding/mnt",
+		Exports: map[string]string{"default": "~", "src": "~/work"},
+	}
+
+	if !reflect.DeepEqual(k.Mount, wantMount) {
+		t.Errorf("got %+v, want %+v mount", k.Mount, wantMount)
+	}
+
+	if k.Template.File != "dev.yaml" {
+		t.Errorf("got %q, want %q template", k.Template.File, "dev.yaml")
+	}
+
+	if _, err := konfigs.Resolve("a"); err == nil {
+		t.Error("expected Resolve to fail on inheritance cycle")
+	}
+
+	trimmed := konfigs.Trim(k)
+
+	if trimmed.Endpoints != nil || trimmed.Mount != nil {
+		t.Errorf("got %+v, %+v, want inherited fields to be trimmed", trimmed.Endpoints, trimmed.Mount)
+	}
+
+	if trimmed.Template == nil || trimmed.Template.File != "dev.yaml" {
+		t.Errorf("got %+v, want overridden template to be kept", trimmed.Template)
+	}
+
+	if k.Endpoints == nil || k.Mount == nil {
+		t.Error("expected Trim to not modify the given configuration")
+	}
+}
+
+func TestFindProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "project", "src")
+
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("MkdirAll()=%s", err)
+	}
+
+	file := filepath.Join(dir, "project", ProfileFile)
+
+	if err := ioutil.WriteFile(file, []byte("profile: staging\n"), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	got, p, err := FindProfile(sub)
+	if err != nil {
+		t.Fatalf("FindProfile()=%s", err)
+	}
+
+	if got != file {
+		t.Errorf("got %q, want %q", got, file)
+	}
+
+	if p.Profile != "staging" {
+		t.Errorf("got %q, want %q", p.Profile, "staging")
+	}
+
+	if _, _, err := FindProfile(dir); !os.IsNotExist(err) {
+		t.Errorf("got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestKonfigsDeployments(t *testing.T) {
+	konfigs := Konfigs{
+		"a": {
+			Endpoints: &Endpoints{Koding: NewEndpoint("https://koding.com")},
+		},
+		"b": {
+			Endpoints: &Endpoints{Koding: NewEndpoint("https://team.example.com:8090")},
+		},
+		"staging": {
+			Name:     "staging",
+			Inherits: "a",
+		},
+		"broken": {
+			Name:     "broken",
+			Inherits: "nonexisting",
+		},
+	}
+
+	var got []string
+	for _, k := range konfigs.Deployments() {
+		got = append(got, k.Deployment())
+	}
+
+	want := []string{"koding.com", "team.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
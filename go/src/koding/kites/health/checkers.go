@@ -0,0 +1,105 @@
+package health
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"koding/db/mongodb"
+
+	"github.com/koding/kite"
+	"github.com/koding/kite/protocol"
+)
+
+// Mongo gives a checker, which pings the given MongoDB.
+func Mongo(db *mongodb.MongoDB) Checker {
+	return CheckFunc(func() error {
+		s := db.Copy()
+		defer s.Close()
+
+		return s.Ping()
+	})
+}
+
+// SQL gives a checker, which pings the given SQL database.
+func SQL(db *sql.DB) Checker {
+	return CheckFunc(db.Ping)
+}
+
+// Kontrol gives a checker, which ensures the kite is currently
+// registered to kontrol, by looking itself up there.
+//
+// Waiting for the first registration only is not enough, as the kite
+// may get disconnected from kontrol and fail to register again.
+func Kontrol(k *kite.Kite) Checker {
+	return CheckFunc(func() error {
+		select {
+		case <-k.KontrolReadyNotify():
+		default:
+			return errors.New("not registered to kontrol")
+		}
+
+		args := &protocol.GetKitesArgs{
+			Query: k.Kite().Query(),
+		}
+
+		part, err := k.TellKontrolWithTimeout("getKites", DefaultTimeout, args)
+		if err != nil {
+			return err
+		}
+
+		var res protocol.GetKitesResult
+
+		if err := part.Unmarshal(&res); err != nil {
+			return err
+		}
+
+		if len(res.Kites) == 0 {
+			return errors.New("not registered to kontrol")
+		}
+
+		return nil
+	})
+}
+
+// HTTP gives a checker, which requests the given path from the kite's
+// own HTTP server. It fails when the kite is not listening or its
+// server stopped responding.
+//
+// It is meant to be used as a liveness check.
+func HTTP(k *kite.Kite, path string) Checker {
+	client := &http.Client{
+		Timeout: DefaultTimeout,
+		Transport: &http.Transport{
+			// The certificate is issued for the public hostname
+			// of the kite, not for the loopback address.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	return CheckFunc(func() error {
+		port := k.Port()
+		if port == 0 {
+			return errors.New("kite is not listening")
+		}
+
+		scheme := "http"
+		if k.TLSConfig != nil {
+			scheme = "https"
+		}
+
+		resp, err := client.Get(fmt.Sprintf("%s://127.0.0.1:%d%s", scheme, port, path))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: unexpected status: %s", path, resp.Status)
+		}
+
+		return nil
+	})
+}
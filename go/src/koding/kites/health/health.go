@@ -0,0 +1,258 @@
+// Package health provides liveness and readiness checks for kites.
+//
+// Each kite registers checks of its dependencies and serves their
+// results with:
+//
+//	h := health.New("kloud")
+//	h.Ready("mongo", health.Mongo(db))
+//	h.Ready("kontrol", health.Kontrol(k))
+//	h.Register(k)
+//
+// which handles /healthz and /readyz HTTP endpoints and "health"
+// kite method.
+//
+// A failing liveness check means the process is wedged and needs to
+// be restarted, a failing readiness check means the process should not
+// receive requests until its dependencies are back.
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"koding/artifact"
+
+	"github.com/koding/kite"
+)
+
+// DefaultTimeout is a maximum time a single check can take
+// before it is considered failed.
+var DefaultTimeout = 5 * time.Second
+
+// Checker is implemented by dependencies, which can report their health.
+type Checker interface {
+	// Check returns non-nil error if the dependency is not available.
+	Check() error
+}
+
+// CheckFunc is an adapter, which allows ordinary functions
+// to be used as Checkers.
+type CheckFunc func() error
+
+// Check implements the Checker interface.
+func (fn CheckFunc) Check() error {
+	return fn()
+}
+
+// Result describes an outcome of a single check.
+type Result struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Status describes health of a kite.
+type Status struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	OK      bool      `json:"ok"`
+	Checks  []*Result `json:"checks,omitempty"`
+}
+
+// Health keeps a set of checks for a single kite.
+type Health struct {
+	Name    string        // name of the kite
+	Timeout time.Duration // DefaultTimeout if zero
+
+	mu     sync.Mutex
+	checks map[string]*check
+}
+
+type check struct {
+	Checker
+	live bool
+}
+
+// New gives new Health value for a kite with the given name.
+func New(name string) *Health {
+	return &Health{
+		Name:   name,
+		checks: make(map[string]*check),
+	}
+}
+
+// Live adds a liveness check.
+//
+// Liveness checks are part of readiness checks as well.
+func (h *Health) Live(name string, c Checker) {
+	h.add(name, c, true)
+}
+
+// Ready adds a readiness check.
+func (h *Health) Ready(name string, c Checker) {
+	h.add(name, c, false)
+}
+
+func (h *Health) add(name string, c Checker, live bool) {
+	h.mu.Lock()
+	h.checks[name] = &check{
+		Checker: c,
+		live:    live,
+	}
+	h.mu.Unlock()
+}
+
+// LiveStatus gives a result of liveness checks.
+func (h *Health) LiveStatus() *Status {
+	return h.status(true)
+}
+
+// ReadyStatus gives a result of readiness checks.
+func (h *Health) ReadyStatus() *Status {
+	return h.status(false)
+}
+
+func (h *Health) status(live bool) *Status {
+	h.mu.Lock()
+	checks := make(map[string]Checker, len(h.checks))
+	for name, c := range h.checks {
+		if !live || c.live {
+			checks[name] = c.Checker
+		}
+	}
+	h.mu.Unlock()
+
+	s := &Status{
+		Name:    h.Name,
+		Version: artifact.VERSION,
+		OK:      true,
+		Checks:  make([]*Result, 0, len(checks)),
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex // protects s
+	)
+
+	for name, c := range checks {
+		wg.Add(1)
+
+		go func(name string, c Checker) {
+			defer wg.Done()
+
+			res := h.run(name, c)
+
+			mu.Lock()
+			s.Checks = append(s.Checks, res)
+			s.OK = s.OK && res.OK
+			mu.Unlock()
+		}(name, c)
+	}
+
+	wg.Wait()
+
+	sort.Sort(byName(s.Checks))
+
+	return s
+}
+
+// run executes a single check; checks which do not return within
+// the timeout are failed, as a hanging dependency usually means
+// wedged process.
+func (h *Health) run(name string, c Checker) *Result {
+	res := &Result{
+		Name: name,
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.Check()
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-time.After(h.timeout()):
+		err = errors.New("check timed out")
+	}
+
+	res.Duration = time.Now().Sub(start)
+
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.OK = true
+	}
+
+	return res
+}
+
+// LiveHandler gives HTTP handler, which responds with result of liveness
+// checks. It responds with 503 status code if any of the checks failed.
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeStatus(w, h.LiveStatus())
+	})
+}
+
+// ReadyHandler gives HTTP handler, which responds with result of readiness
+// checks. It responds with 503 status code if any of the checks failed.
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeStatus(w, h.ReadyStatus())
+	})
+}
+
+// HandleHealth is a kite handler for the "health" method, which
+// responds with result of readiness checks.
+func (h *Health) HandleHealth(*kite.Request) (interface{}, error) {
+	return h.ReadyStatus(), nil
+}
+
+// Register registers health endpoints and "health" method
+// on the given kite.
+//
+// The endpoints are served under /healthz and /readyz paths.
+func (h *Health) Register(k *kite.Kite) {
+	h.RegisterPrefix(k, "")
+}
+
+// RegisterPrefix registers health endpoints and "health" method
+// on the given kite. The HTTP endpoints are served under the
+// given path prefix.
+func (h *Health) RegisterPrefix(k *kite.Kite, prefix string) {
+	k.HandleHTTP(prefix+"/healthz", h.LiveHandler())
+	k.HandleHTTP(prefix+"/readyz", h.ReadyHandler())
+	k.HandleFunc("health", h.HandleHealth)
+}
+
+func (h *Health) timeout() time.Duration {
+	if h.Timeout != 0 {
+		return h.Timeout
+	}
+	return DefaultTimeout
+}
+
+func writeStatus(w http.ResponseWriter, s *Status) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(s)
+}
+
+type byName []*Result
+
+func (r byName) Len() int           { return len(r) }
+func (r byName) Less(i, j int) bool { return r[i].Name < r[j].Name }
+func (r byName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
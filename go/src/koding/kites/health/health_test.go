@@ -0,0 +1,119 @@
+package health_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"koding/kites/health"
+
+	"github.com/koding/kite"
+	"github.com/koding/kite/config"
+)
+
+func TestHealth(t *testing.T) {
+	h := health.New("terraformer")
+	h.Timeout = 50 * time.Millisecond
+
+	hang := make(chan struct{})
+	defer close(hang)
+
+	h.Live("state", health.CheckFunc(func() error { return nil }))
+	h.Ready("storage", health.CheckFunc(func() error { return errors.New("bucket not found") }))
+	h.Ready("plugins", health.CheckFunc(func() error { <-hang; return nil }))
+
+	cases := map[string]struct {
+		handler http.Handler
+		code    int
+		checks  map[string]string
+	}{
+		"liveness": {
+			h.LiveHandler(),
+			http.StatusOK,
+			map[string]string{
+				"state": "",
+			},
+		},
+		"readiness": {
+			h.ReadyHandler(),
+			http.StatusServiceUnavailable,
+			map[string]string{
+				"plugins": "check timed out",
+				"state":   "",
+				"storage": "bucket not found",
+			},
+		},
+	}
+
+	for name, cas := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			cas.handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+			if rec.Code != cas.code {
+				t.Fatalf("got %d, want %d", rec.Code, cas.code)
+			}
+
+			var s health.Status
+
+			if err := json.NewDecoder(rec.Body).Decode(&s); err != nil {
+				t.Fatalf("Decode()=%s", err)
+			}
+
+			if s.Name != "terraformer" {
+				t.Fatalf("got %q, want %q", s.Name, "terraformer")
+			}
+
+			if s.OK != (cas.code == http.StatusOK) {
+				t.Fatalf("got %t, want %t", s.OK, cas.code == http.StatusOK)
+			}
+
+			if len(s.Checks) != len(cas.checks) {
+				t.Fatalf("got %d checks, want %d", len(s.Checks), len(cas.checks))
+			}
+
+			for i, res := range s.Checks {
+				want, ok := cas.checks[res.Name]
+				if !ok {
+					t.Fatalf("%d: unexpected %q check", i, res.Name)
+				}
+
+				if i != 0 && s.Checks[i-1].Name > res.Name {
+					t.Fatalf("%d: checks are not sorted: %q > %q", i, s.Checks[i-1].Name, res.Name)
+				}
+
+				if res.Error != want || res.OK != (want == "") {
+					t.Fatalf("%d: got %+v, want error %q", i, res, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	k := kite.New("health", "0.0.1")
+	k.Config = config.New()
+	k.Config.Port = 0
+	k.Config.DisableAuthentication = true
+
+	k.HandleHTTPFunc("/healthCheck", func(http.ResponseWriter, *http.Request) {})
+
+	if err := health.HTTP(k, "/healthCheck").Check(); err == nil {
+		t.Fatal("expected check to fail before kite is listening")
+	}
+
+	go k.Run()
+	<-k.ServerReadyNotify()
+	defer k.Close()
+
+	if err := health.HTTP(k, "/healthCheck").Check(); err != nil {
+		t.Fatalf("Check()=%s", err)
+	}
+
+	if err := health.HTTP(k, "/notfound").Check(); err == nil {
+		t.Fatal("expected check to fail for missing path")
+	}
+}
@@ -0,0 +1,136 @@
+package keygen
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SignedURLs maps object names to URLs, which allow for uploading
+// the objects with a PUT request until the token expires.
+//
+// It is a value of AuthResponse for "gcs" and "minio" auth types.
+type SignedURLs map[string]string
+
+// URLBucket provides a client for writing to a bucket with
+// signed URLs obtained from keygen server.
+//
+// It is an alternative to UserBucket for auth types other
+// than "s3", e.g. "gcs" or "minio".
+type URLBucket struct {
+	cfg    *Config
+	client *http.Client
+
+	mu   sync.Mutex
+	base string // resource of the last auth response
+}
+
+// NewURLBucket creates new bucket value for the given configuration.
+func NewURLBucket(cfg *Config) *URLBucket {
+	return &URLBucket{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.timeout(),
+		},
+	}
+}
+
+// Put streams the content of rs reader to the bucket under the given key.
+func (ub *URLBucket) Put(key string, rs io.ReadSeeker) (*url.URL, error) {
+	u, err := ub.sign(key)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", u.String(), rs)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+
+	ub.cfg.log().Debug("PUT %s (%d bytes)", u.Path, size)
+
+	resp, err := ub.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("uploading %q failed: %s", key, resp.Status)
+	}
+
+	u.RawQuery = ""
+
+	return u, nil
+}
+
+// URL gives the remote URL of the key.
+func (ub *URLBucket) URL(key string) *url.URL {
+	ub.mu.Lock()
+	base := ub.base
+	ub.mu.Unlock()
+
+	// The base is known only after the first Put, the URL is not
+	// signed here as it would issue new credentials for each call.
+	if base == "" {
+		return &url.URL{
+			Path: "/" + ub.cfg.username() + "/" + key,
+		}
+	}
+
+	u, err := url.Parse(strings.TrimRight(base, "/") + "/" + key)
+	if err != nil {
+		return &url.URL{
+			Path: "/" + ub.cfg.username() + "/" + key,
+		}
+	}
+
+	return u
+}
+
+func (ub *URLBucket) sign(key string) (*url.URL, error) {
+	req := &AuthRequest{
+		Type: ub.typ(),
+		Keys: []string{key},
+	}
+
+	var urls SignedURLs
+
+	resp, err := ub.cfg.auth(req, &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	ub.mu.Lock()
+	ub.base = resp.Resource
+	ub.mu.Unlock()
+
+	s, ok := urls[key]
+	if !ok {
+		return nil, fmt.Errorf("no signed URL for %q", key)
+	}
+
+	return url.Parse(s)
+}
+
+func (ub *URLBucket) typ() string {
+	if ub.cfg.ProviderType != "" {
+		return ub.cfg.ProviderType
+	}
+
+	return "minio"
+}
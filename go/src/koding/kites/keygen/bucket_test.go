@@ -0,0 +1,248 @@
+package keygen_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/kites/keygen"
+	"koding/kites/keygen/keygentest"
+
+	dogstatsd "github.com/DataDog/datadog-go/statsd"
+	"github.com/koding/kite"
+)
+
+// objects is a S3-compatible server stand-in, which
+// stores objects uploaded with presigned URLs.
+type objects struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+func (o *objects) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" || r.URL.Query().Get("X-Amz-Signature") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	p, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	o.mu.Lock()
+	o.m[r.URL.Path] = string(p)
+	o.mu.Unlock()
+}
+
+func newConfig(t *testing.T) *keygen.Config {
+	stats, err := dogstatsd.New("127.0.0.1:8125")
+	if err != nil {
+		t.Fatalf("New()=%s", err)
+	}
+
+	return &keygen.Config{
+		AuthExpire: 15 * time.Minute,
+		Metrics:    stats,
+	}
+}
+
+func TestURLBucket(t *testing.T) {
+	obj := &objects{m: make(map[string]string)}
+	srv := httptest.NewServer(obj)
+	defer srv.Close()
+
+	issuer, err := keygen.NewMinioIssuer(&keygen.MinioConfig{
+		Endpoint:  srv.URL,
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		Bucket:    "logs",
+	})
+	if err != nil {
+		t.Fatalf("NewMinioIssuer()=%s", err)
+	}
+
+	drv := &keygentest.Driver{}
+	cfg := newConfig(t)
+	cfg.Issuers = map[string]keygen.Issuer{
+		"minio": issuer,
+	}
+
+	defer drv.Server(cfg)()
+
+	ub := keygen.NewURLBucket(drv.Kite(cfg, "user1"))
+
+	u, err := ub.Put("klient.log", strings.NewReader("content"))
+	if err != nil {
+		t.Fatalf("Put()=%s", err)
+	}
+
+	if want := srv.URL + "/logs/user1/klient.log"; u.String() != want {
+		t.Fatalf("got %q, want %q", u, want)
+	}
+
+	if got := obj.m["/logs/user1/klient.log"]; got != "content" {
+		t.Fatalf("got %q, want %q", got, "content")
+	}
+
+	if got := ub.URL("other.log").String(); got != srv.URL+"/logs/user1/other.log" {
+		t.Fatalf("got %q, want %q", got, srv.URL+"/logs/user1/other.log")
+	}
+
+	if _, err := ub.Put("../user2/klient.log", strings.NewReader("content")); err == nil {
+		t.Fatal("expected Put outside of user prefix to fail")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	issuer, err := keygen.NewMinioIssuer(&keygen.MinioConfig{
+		Endpoint:  "http://127.0.0.1:9000",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		Bucket:    "logs",
+	})
+	if err != nil {
+		t.Fatalf("NewMinioIssuer()=%s", err)
+	}
+
+	drv := &keygentest.Driver{}
+	cfg := newConfig(t)
+	cfg.Issuers = map[string]keygen.Issuer{
+		"minio": issuer,
+	}
+
+	defer drv.Server(cfg)()
+
+	user1 := dial(t, drv.Kite(cfg, "user1"))
+	defer user1.Close()
+
+	user2 := dial(t, drv.Kite(cfg, "user2"))
+	defer user2.Close()
+
+	var ids []string
+
+	for i := 0; i < 2; i++ {
+		var resp keygen.AuthResponse
+
+		call(t, user1, "keygen.auth", &keygen.AuthRequest{Type: "minio", Keys: []string{"a"}}, &resp)
+
+		if resp.ID == "" || resp.Expires.IsZero() {
+			t.Fatalf("got %+v, want non-empty ID and expiration", resp)
+		}
+
+		ids = append(ids, resp.ID)
+	}
+
+	var resp keygen.RevokeResponse
+
+	// user2 can't revoke tokens of user1
+	call(t, user2, "keygen.revoke", &keygen.RevokeRequest{User: "user1", ID: ids[0]}, &resp)
+
+	if len(resp.Revoked) != 0 {
+		t.Fatalf("got %v, want no tokens revoked", resp.Revoked)
+	}
+
+	call(t, user1, "keygen.revoke", &keygen.RevokeRequest{ID: ids[0]}, &resp)
+
+	if len(resp.Revoked) != 1 || resp.Revoked[0] != ids[0] {
+		t.Fatalf("got %v, want %v", resp.Revoked, ids[:1])
+	}
+
+	call(t, user1, "keygen.revoke", &keygen.RevokeRequest{}, &resp)
+
+	if len(resp.Revoked) != 1 || resp.Revoked[0] != ids[1] {
+		t.Fatalf("got %v, want %v", resp.Revoked, ids[1:])
+	}
+}
+
+func TestGCSIssuer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey()=%s", err)
+	}
+
+	issuer, err := keygen.NewGCSIssuer(&keygen.GCSConfig{
+		Bucket:         "logs",
+		GoogleAccessID: "keygen@koding.iam.gserviceaccount.com",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewGCSIssuer()=%s", err)
+	}
+
+	tok := &keygen.Token{
+		Prefix:  "user1",
+		Keys:    []string{"klient.log"},
+		Expires: time.Now().Add(time.Minute),
+	}
+
+	res, v, err := issuer.Issue(tok)
+	if err != nil {
+		t.Fatalf("Issue()=%s", err)
+	}
+
+	if want := "https://storage.googleapis.com/logs/user1"; res != want {
+		t.Fatalf("got %q, want %q", res, want)
+	}
+
+	u, err := url.Parse(v.(keygen.SignedURLs)["klient.log"])
+	if err != nil {
+		t.Fatalf("Parse()=%s", err)
+	}
+
+	q := u.Query()
+
+	sig, err := base64.StdEncoding.DecodeString(q.Get("Signature"))
+	if err != nil {
+		t.Fatalf("DecodeString()=%s", err)
+	}
+
+	s := fmt.Sprintf("PUT\n\n\n%s\n%s", q.Get("Expires"), u.Path)
+	sum := sha256.Sum256([]byte(s))
+
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("VerifyPKCS1v15()=%s", err)
+	}
+}
+
+func dial(t *testing.T, cfg *keygen.Config) *kite.Client {
+	c := cfg.Kite.NewClient(cfg.ServerURL)
+	c.Auth = &kite.Auth{
+		Type: "kiteKey",
+		Key:  cfg.Kite.KiteKey(),
+	}
+
+	if err := c.DialTimeout(15 * time.Second); err != nil {
+		t.Fatalf("DialTimeout()=%s", err)
+	}
+
+	return c
+}
+
+func call(t *testing.T, c *kite.Client, method string, req, resp interface{}) {
+	part, err := c.TellWithTimeout(method, 15*time.Second, req)
+	if err != nil {
+		t.Fatalf("%s: Tell()=%s", method, err)
+	}
+
+	if err := part.Unmarshal(resp); err != nil {
+		t.Fatalf("%s: Unmarshal()=%s", method, err)
+	}
+}
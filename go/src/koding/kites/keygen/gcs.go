@@ -0,0 +1,133 @@
+package keygen
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// GCSConfig is used to configure GCSIssuer.
+type GCSConfig struct {
+	Bucket         string // GCS bucket name; required
+	GoogleAccessID string // service account email; required
+	PrivateKey     []byte // PEM-encoded service account private key; required
+}
+
+// GCSIssuer issues GCS signed URLs, which allow for uploading
+// objects under token prefix within the bucket.
+type GCSIssuer struct {
+	cfg *GCSConfig
+	key *rsa.PrivateKey
+}
+
+var _ Issuer = (*GCSIssuer)(nil)
+
+// NewGCSIssuer gives new GCSIssuer for the given configuration.
+func NewGCSIssuer(cfg *GCSConfig) (*GCSIssuer, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket is empty")
+	}
+
+	if cfg.GoogleAccessID == "" {
+		return nil, errors.New("google access ID is empty")
+	}
+
+	key, err := parseRSAKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSIssuer{
+		cfg: cfg,
+		key: key,
+	}, nil
+}
+
+// Issue implements the Issuer interface.
+//
+// The signed URLs are signed for PUT requests without
+// Content-Type and Content-MD5 headers.
+func (g *GCSIssuer) Issue(tok *Token) (string, interface{}, error) {
+	if len(tok.Keys) == 0 {
+		return "", nil, errors.New("no keys requested")
+	}
+
+	urls := make(SignedURLs, len(tok.Keys))
+
+	for _, key := range tok.Keys {
+		u, err := g.sign(path.Join(tok.Prefix, key), tok.Expires.Unix())
+		if err != nil {
+			return "", nil, err
+		}
+
+		urls[key] = u.String()
+	}
+
+	return g.url(tok.Prefix).String(), urls, nil
+}
+
+// sign creates V2 signed URL for the given object.
+func (g *GCSIssuer) sign(object string, expires int64) (*url.URL, error) {
+	u := g.url(object)
+
+	// The string to sign is:
+	//
+	//   Method \n Content-MD5 \n Content-Type \n Expires \n Resource
+	//
+	s := fmt.Sprintf("PUT\n\n\n%d\n%s", expires, u.EscapedPath())
+
+	sum := sha256.Sum256([]byte(s))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	u.RawQuery = url.Values{
+		"GoogleAccessId": {g.cfg.GoogleAccessID},
+		"Expires":        {strconv.FormatInt(expires, 10)},
+		"Signature":      {base64.StdEncoding.EncodeToString(sig)},
+	}.Encode()
+
+	return u, nil
+}
+
+func (g *GCSIssuer) url(object string) *url.URL {
+	return &url.URL{
+		Scheme: "https",
+		Host:   "storage.googleapis.com",
+		Path:   "/" + g.cfg.Bucket + "/" + object,
+	}
+}
+
+func parseRSAKey(p []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(p)
+	if block == nil {
+		return nil, errors.New("private key is not PEM-encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not RSA key: %T", key)
+	}
+
+	return rsaKey, nil
+}
@@ -3,24 +3,22 @@ package keygen
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"koding/kites/kloud/api/amazon"
 	"koding/kites/metrics"
 
 	dogstatsd "github.com/DataDog/datadog-go/statsd"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/go-multierror"
 	"github.com/koding/kite"
 	"github.com/koding/logging"
+	"github.com/satori/go.uuid"
 )
 
-// TODO(rjeczalik): refactor Server/Provider to support multiple auth types (AuthRequest.Type)
-
 var defaultLog = logging.NewCustom("keygen", false)
 
 // DefaultBefore is a default behavior for Config.BeforeFunc field.
@@ -35,7 +33,7 @@ type Config struct {
 	RootUser string // kite user allowed to impersonate other users; "koding" by default
 
 	// S3 auth configuration
-	AccessKey string // AWS access key; required
+	AccessKey string // AWS access key of an IAM user allowed to write to the Bucket; required
 	SecretKey string // AWS secret key; required
 	Bucket    string // S3 bucket resource for "s3" auth; required
 	Region    string // S3 bucket region; "us-east-1" by default
@@ -52,7 +50,11 @@ type Config struct {
 	// If 0, the default of 3h is used.
 	AuthExpire time.Duration
 
-	ProviderType string // value for Provider.Type; defaults to "s3"
+	// Issuers are additional auth types supported by the Server,
+	// like "gcs" or "minio", keyed by the type name.
+	Issuers map[string]Issuer
+
+	ProviderType string // value for Provider.Type and URLBucket auth type; defaults to "s3" and "minio" respectively
 	Kite         *kite.Kite
 	ServerURL    string
 	Timeout      time.Duration        // max time of client<->server communication; 15s by default
@@ -81,6 +83,49 @@ func (cfg *Config) region() string {
 	return "us-east-1"
 }
 
+func (cfg *Config) timeout() time.Duration {
+	if cfg.Timeout != 0 {
+		return cfg.Timeout
+	}
+
+	return 15 * time.Second
+}
+
+// auth requests credentials from the keygen server, decoding
+// the response value into v.
+func (cfg *Config) auth(req *AuthRequest, v interface{}) (*AuthResponse, error) {
+	client := cfg.Kite.NewClient(cfg.ServerURL)
+	client.Auth = &kite.Auth{
+		Type: "kiteKey",
+		Key:  cfg.Kite.KiteKey(),
+	}
+
+	if err := client.DialTimeout(cfg.timeout()); err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	part, err := client.TellWithTimeout("keygen.auth", cfg.timeout(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &AuthResponse{
+		Value: v,
+	}
+
+	if err := part.Unmarshal(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Type != req.Type {
+		return nil, fmt.Errorf("authorization type not expected: %q", resp.Type)
+	}
+
+	return resp, nil
+}
+
 func (cfg *Config) log() logging.Logger {
 	if cfg.Log != nil {
 		return cfg.Log
@@ -93,43 +138,63 @@ func (cfg *Config) log() logging.Logger {
 type AuthRequest struct {
 	User string `json:"user"`
 	Type string `json:"type"`
+
+	// Keys are object names, relative to the user prefix, the
+	// credentials are requested for. Required by auth types which
+	// sign URLs per object, like "gcs" or "minio".
+	Keys []string `json:"keys,omitempty"`
+
+	// TTL is a requested lifetime of the credentials. It can't exceed
+	// the Config.AuthExpire, which is also used when TTL is 0.
+	TTL time.Duration `json:"ttl,omitempty"`
 }
 
 // AuthResponse represents response message for the "keygen.auth" method.
 type AuthResponse struct {
+	ID       string      `json:"id,omitempty"`
 	Type     string      `json:"type"`
 	Resource string      `json:"resource"`
+	Expires  time.Time   `json:"expires,omitempty"`
 	Value    interface{} `json:"value"`
 }
 
+// RevokeRequest represents request message for the "keygen.revoke" method.
+type RevokeRequest struct {
+	User string `json:"user,omitempty"`
+	ID   string `json:"id,omitempty"` // if empty, all user tokens are revoked
+}
+
+// RevokeResponse represents response message for the "keygen.revoke" method.
+type RevokeResponse struct {
+	Revoked []string `json:"revoked"`
+}
+
 // Server is a keygen server.
 type Server struct {
-	cfg *Config
-	sts *sts.STS
-	s3  *s3.S3
+	cfg     *Config
+	issuers map[string]Issuer
+	tokens  tokens
 }
 
 // NewServer gives new server value created from the given configuration.
+//
+// The "s3" auth type is always supported, other ones
+// are read from cfg.Issuers.
 func NewServer(cfg *Config) *Server {
-	awsCfg := &aws.Config{
-		Credentials: credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""),
-		Region:      aws.String(cfg.region()),
-	}
-
-	if cfg.Log != nil {
-		awsCfg.Logger = amazon.NewLogger(cfg.Log.Debug)
-	}
-
-	sess := session.New(awsCfg)
-
 	s := &Server{
 		cfg: cfg,
-		sts: sts.New(sess),
-		s3:  s3.New(sess),
+		issuers: map[string]Issuer{
+			"s3": newS3Issuer(cfg),
+		},
+	}
+
+	for typ, issuer := range cfg.Issuers {
+		s.issuers[typ] = issuer
 	}
 
 	if s.cfg.Kite != nil {
 		s.cfg.Kite.HandleFunc("keygen.auth", metrics.WrapKiteHandler(cfg.Metrics, "keygen.auth", s.Auth))
+		s.cfg.Kite.HandleFunc("keygen.revoke", metrics.WrapKiteHandler(cfg.Metrics, "keygen.revoke", s.Revoke))
 	}
 
 	return s
@@ -155,46 +220,118 @@ func (s *Server) Auth(r *kite.Request) (interface{}, error) {
 		return nil, err
 	}
 
-	if req.Type != "s3" {
+	issuer, ok := s.issuers[req.Type]
+	if !ok {
 		return nil, fmt.Errorf("authorization type not supported: %q", req.Type)
 	}
 
-	policy := fmt.Sprintf(stsPolicyTmpl, s.cfg.Bucket, req.User)
-
-	token := &sts.GetFederationTokenInput{
-		Name:            &req.User,
-		DurationSeconds: aws.Int64(int64(s.expire() / time.Second)),
-		Policy:          &policy,
+	tok, err := s.newToken(&req)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := s.sts.GetFederationToken(token)
+	res, value, err := issuer.Issue(tok)
 	if err != nil {
 		return nil, err
 	}
 
-	s.cfg.log().Debug("GetFedetationToken()=%+v", resp)
+	s.tokens.add(tok)
+
+	s.cfg.log().Debug("issued %q token for %q: %+v", tok.Type, tok.User, tok)
 
-	policy = fmt.Sprintf(s3PolicyTmpl, s.cfg.Bucket, req.User, aws.StringValue(resp.FederatedUser.Arn))
+	return &AuthResponse{
+		ID:       tok.ID,
+		Type:     tok.Type,
+		Resource: res,
+		Expires:  tok.Expires,
+		Value:    value,
+	}, nil
+}
 
-	bucket := &s3.PutBucketPolicyInput{
-		Bucket: &s.cfg.Bucket,
-		Policy: aws.String(policy),
+// Revoke is a kite handler for the "keygen.revoke" method.
+//
+// The root user can revoke tokens of any user, other users
+// can revoke only their own tokens.
+func (s *Server) Revoke(r *kite.Request) (interface{}, error) {
+	if r.Args == nil {
+		return nil, errors.New("missing argument")
+	}
+
+	var req RevokeRequest
+
+	if err := r.Args.One().Unmarshal(&req); err != nil {
+		return nil, err
+	}
+
+	if r.Username != s.rootUser() || req.User == "" {
+		req.User = r.Username
 	}
 
-	s.cfg.log().Debug("PutBucketPolicy()=%+v", bucket)
+	var (
+		resp RevokeResponse
+		err  error
+	)
+
+	for _, tok := range s.tokens.remove(req.User, req.ID) {
+		if revoker, ok := s.issuers[tok.Type].(Revoker); ok {
+			if e := revoker.Revoke(tok); e != nil {
+				err = multierror.Append(err, fmt.Errorf("%s: %s", tok.ID, e))
+				continue
+			}
+		}
+
+		resp.Revoked = append(resp.Revoked, tok.ID)
+	}
+
+	s.cfg.log().Debug("revoked tokens for %q: %v", req.User, resp.Revoked)
 
-	_, err = s.s3.PutBucketPolicy(bucket)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO(rjeczalik): refactor "s3" to separate AuthProvider
-	res := fmt.Sprintf("arn:aws:s3:::%s/%s", s.cfg.Bucket, req.User)
+	return &resp, nil
+}
 
-	return &AuthResponse{
-		Type:     "s3",
-		Resource: res,
-		Value:    resp.Credentials,
+// Check implements the health.Checker interface.
+//
+// It ensures buckets of all issuers, which are able
+// to check them, are available.
+func (s *Server) Check() error {
+	var err error
+
+	for typ, issuer := range s.issuers {
+		if c, ok := issuer.(interface {
+			Check() error
+		}); ok {
+			if e := c.Check(); e != nil {
+				err = multierror.Append(err, fmt.Errorf("%s: %s", typ, e))
+			}
+		}
+	}
+
+	return err
+}
+
+func (s *Server) newToken(req *AuthRequest) (*Token, error) {
+	ttl := s.expire()
+
+	if req.TTL > 0 && req.TTL < ttl {
+		ttl = req.TTL
+	}
+
+	for _, key := range req.Keys {
+		if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+			return nil, fmt.Errorf("invalid key: %q", key)
+		}
+	}
+
+	return &Token{
+		ID:      uuid.NewV4().String(),
+		Type:    req.Type,
+		User:    req.User,
+		Prefix:  req.User,
+		Keys:    req.Keys,
+		Expires: time.Now().Add(ttl).UTC(),
 	}, nil
 }
 
@@ -242,41 +379,17 @@ func NewProvider(cfg *Config) *Provider {
 
 // Retrieve implements the credentials.Provider interface.
 func (p *Provider) Retrieve() (v credentials.Value, err error) {
-	client := p.cfg.Kite.NewClient(p.cfg.ServerURL)
-	client.Auth = &kite.Auth{
-		Type: "kiteKey",
-		Key:  p.cfg.Kite.KiteKey(),
-	}
-
-	if err := client.DialTimeout(p.timeout()); err != nil {
-		return v, err
-	}
-
-	defer client.Close()
-
 	req := &AuthRequest{
 		Type: p.typ(),
 	}
 
-	part, err := client.TellWithTimeout("keygen.auth", p.timeout(), req)
-	if err != nil {
-		return v, err
-	}
-
 	var cred sts.Credentials
 
-	resp := &AuthResponse{
-		Value: &cred,
-	}
-
-	if err := part.Unmarshal(resp); err != nil {
+	resp, err := p.cfg.auth(req, &cred)
+	if err != nil {
 		return v, err
 	}
 
-	if resp.Type != req.Type {
-		return v, fmt.Errorf("authorization type not expected: %q", resp.Type)
-	}
-
 	p.expire = aws.TimeValue(cred.Expiration)
 
 	p.cfg.log().Debug("Retrieve()=%+v", resp)
@@ -294,14 +407,6 @@ func (p *Provider) IsExpired() bool {
 	return p.before(p.expire)
 }
 
-func (p *Provider) timeout() time.Duration {
-	if p.cfg.Timeout != 0 {
-		return p.cfg.Timeout
-	}
-
-	return 15 * time.Second
-}
-
 func (p *Provider) typ() string {
 	if p.cfg.ProviderType != "" {
 		return p.cfg.ProviderType
@@ -0,0 +1,108 @@
+package keygen
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"koding/kites/kloud/api/amazon"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/koding/logging"
+)
+
+// MinioConfig is used to configure MinioIssuer.
+type MinioConfig struct {
+	Endpoint  string // URL of the S3-compatible server, e.g. "http://127.0.0.1:9000"; required
+	AccessKey string // required
+	SecretKey string // required
+	Bucket    string // required
+	Region    string // "us-east-1" by default
+	Log       logging.Logger
+}
+
+// MinioIssuer issues presigned URLs for S3-compatible servers,
+// like MinIO, which allow for uploading objects under token
+// prefix within the bucket.
+type MinioIssuer struct {
+	cfg *MinioConfig
+	s3  *s3.S3
+}
+
+var _ Issuer = (*MinioIssuer)(nil)
+
+// NewMinioIssuer gives new MinioIssuer for the given configuration.
+func NewMinioIssuer(cfg *MinioConfig) (*MinioIssuer, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is empty")
+	}
+
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket is empty")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	awsCfg := &aws.Config{
+		Credentials:      credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""),
+		Endpoint:         aws.String(cfg.Endpoint),
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+
+	if cfg.Log != nil {
+		awsCfg.Logger = amazon.NewLogger(cfg.Log.Debug)
+	}
+
+	return &MinioIssuer{
+		cfg: cfg,
+		s3:  s3.New(session.New(awsCfg)),
+	}, nil
+}
+
+// Issue implements the Issuer interface.
+func (m *MinioIssuer) Issue(tok *Token) (string, interface{}, error) {
+	if len(tok.Keys) == 0 {
+		return "", nil, errors.New("no keys requested")
+	}
+
+	ttl := tok.Expires.Sub(time.Now())
+	urls := make(SignedURLs, len(tok.Keys))
+
+	for _, key := range tok.Keys {
+		req, _ := m.s3.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: &m.cfg.Bucket,
+			Key:    aws.String(path.Join(tok.Prefix, key)),
+		})
+
+		u, err := req.Presign(ttl)
+		if err != nil {
+			return "", nil, err
+		}
+
+		urls[key] = u
+	}
+
+	u, err := url.Parse(strings.TrimRight(m.cfg.Endpoint, "/") + "/" + m.cfg.Bucket + "/" + tok.Prefix)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return u.String(), urls, nil
+}
+
+// Check implements the health.Checker interface.
+func (m *MinioIssuer) Check() error {
+	_, err := m.s3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: &m.cfg.Bucket,
+	})
+	return err
+}
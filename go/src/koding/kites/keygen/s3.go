@@ -2,13 +2,19 @@ package keygen
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/url"
+	"strings"
+	"time"
+
+	"koding/kites/kloud/api/amazon"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
 )
 
 // Policy represents S3/STS policy document.
@@ -25,40 +31,108 @@ type Permission struct {
 	Principal interface{} `json:"Principal,omitempty"`
 }
 
-var (
-	stsPolicyTmpl = mustJSON(&Policy{
-		Version: "2012-10-17",
-		Statement: []Permission{{
-			Effect: "Allow",
-			Action: []string{
-				"s3:PutObject",
-				"s3:PutObjectAcl",
-			},
-			Resource: []string{
-				"arn:aws:s3:::%[1]s/%[2]s",
-				"arn:aws:s3:::%[1]s/%[2]s/*",
-			},
-		}},
-	})
+var stsPolicyTmpl = mustJSON(&Policy{
+	Version: "2012-10-17",
+	Statement: []Permission{{
+		Effect: "Allow",
+		Action: []string{
+			"s3:PutObject",
+			"s3:PutObjectAcl",
+		},
+		Resource: []string{
+			"arn:aws:s3:::%[1]s/%[2]s",
+			"arn:aws:s3:::%[1]s/%[2]s/*",
+		},
+	}},
+})
+
+// s3Issuer issues AWS STS federation tokens, which grant
+// write access to the token prefix within the S3 bucket.
+//
+// The scope of each token is defined by its session policy, which
+// AWS intersects with permissions of the IAM user keygen is configured
+// with, so the bucket policy is not modified when tokens are issued.
+// Federation tokens can't be invalidated selectively, so they are
+// valid until they expire - like signed URLs they should be issued
+// with short TTLs.
+type s3Issuer struct {
+	cfg *Config
+	sts *sts.STS
+	s3  *s3.S3
+}
+
+func newS3Issuer(cfg *Config) *s3Issuer {
+	awsCfg := &aws.Config{
+		Credentials: credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""),
+		Region:      aws.String(cfg.region()),
+	}
+
+	if cfg.Log != nil {
+		awsCfg.Logger = amazon.NewLogger(cfg.Log.Debug)
+	}
+
+	sess := session.New(awsCfg)
+
+	return &s3Issuer{
+		cfg: cfg,
+		sts: sts.New(sess),
+		s3:  s3.New(sess),
+	}
+}
+
+// Issue implements the Issuer interface.
+func (i *s3Issuer) Issue(tok *Token) (string, interface{}, error) {
+	policy := fmt.Sprintf(stsPolicyTmpl, i.cfg.Bucket, tok.Prefix)
+
+	// STS does not allow for federation tokens shorter than 15m.
+	dur := tok.Expires.Sub(time.Now())
+	if dur < 15*time.Minute {
+		dur = 15 * time.Minute
+	}
 
-	s3PolicyTmpl = mustJSON(&Policy{
-		Version: "2012-10-17",
-		Statement: []Permission{{
-			Effect: "Allow",
-			Action: []string{
-				"s3:PutObject",
-				"s3:PutObjectAcl",
-			},
-			Resource: []string{
-				"arn:aws:s3:::%[1]s/%[2]s",
-				"arn:aws:s3:::%[1]s/%[2]s/*",
-			},
-			Principal: map[string]string{
-				"AWS": "%[3]s",
-			},
-		}},
+	token := &sts.GetFederationTokenInput{
+		Name:            aws.String(federatedName(tok)),
+		DurationSeconds: aws.Int64(int64(dur / time.Second)),
+		Policy:          &policy,
+	}
+
+	resp, err := i.sts.GetFederationToken(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	i.cfg.log().Debug("GetFedetationToken()=%+v", resp)
+
+	tok.Expires = aws.TimeValue(resp.Credentials.Expiration)
+
+	return fmt.Sprintf("arn:aws:s3:::%s/%s", i.cfg.Bucket, tok.Prefix), resp.Credentials, nil
+}
+
+// Check implements the health.Checker interface.
+func (i *s3Issuer) Check() error {
+	if i.cfg.Bucket == "" {
+		return nil // "s3" auth type is not configured
+	}
+
+	_, err := i.s3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: &i.cfg.Bucket,
 	})
-)
+	return err
+}
+
+// federatedName gives a name of the federated user, unique
+// for the token, so requests made with different tokens
+// of the user can be told apart in S3 access logs.
+//
+// STS requires the name to be at most 32 characters long.
+func federatedName(tok *Token) string {
+	user := tok.User
+	if len(user) > 23 {
+		user = user[:23]
+	}
+
+	return user + "." + strings.Replace(tok.ID, "-", "", -1)[:8]
+}
 
 // UserBucket provides a client for writing to a publicly
 // available bucket.
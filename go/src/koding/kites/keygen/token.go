@@ -0,0 +1,88 @@
+package keygen
+
+import (
+	"sync"
+	"time"
+)
+
+// Token describes scoped credentials issued by the keygen server.
+type Token struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	User    string    `json:"user"`
+	Prefix  string    `json:"prefix"`         // path prefix the credentials grant write access to
+	Keys    []string  `json:"keys,omitempty"` // requested object names, relative to Prefix
+	Expires time.Time `json:"expires"`
+}
+
+// Issuer issues short-lived credentials, which grant write access
+// to objects under token's path prefix.
+type Issuer interface {
+	// Issue gives credentials for the given token.
+	//
+	// The resource identifies the location the credentials grant
+	// access to, the value is sent to the client as-is.
+	Issue(tok *Token) (resource string, value interface{}, err error)
+}
+
+// Revoker is implemented by issuers that are able to invalidate
+// credentials before they expire.
+//
+// Credentials issued by other issuers, like signed URLs, are valid
+// until they expire - revoking them only stops keygen from tracking
+// them, thus they should be issued with short TTLs.
+type Revoker interface {
+	Revoke(tok *Token) error
+}
+
+// tokens keeps track of issued tokens, until they expire.
+type tokens struct {
+	mu sync.Mutex
+	m  map[string]*Token // maps token ID to a token
+}
+
+func (t *tokens) add(tok *Token) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.m == nil {
+		t.m = make(map[string]*Token)
+	}
+
+	t.expire()
+
+	t.m[tok.ID] = tok
+}
+
+// remove removes tokens of the given user. If id is
+// non-empty, only the token with matching ID is removed.
+func (t *tokens) remove(user, id string) []*Token {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire()
+
+	var removed []*Token
+
+	for _, tok := range t.m {
+		if tok.User != user || (id != "" && tok.ID != id) {
+			continue
+		}
+
+		delete(t.m, tok.ID)
+
+		removed = append(removed, tok)
+	}
+
+	return removed
+}
+
+func (t *tokens) expire() {
+	now := time.Now()
+
+	for id, tok := range t.m {
+		if tok.Expires.Before(now) {
+			delete(t.m, id)
+		}
+	}
+}
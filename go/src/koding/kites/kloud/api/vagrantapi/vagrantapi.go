@@ -56,9 +56,10 @@ type Create struct {
 	Debug          bool             // enable klient/vagrant debug logging
 }
 
-// Command represents vagrant.{up,halt,destroy} requests.
+// Command represents vagrant.{up,halt,destroy,package,restore} requests.
 type Command struct {
 	FilePath  string // can be relative or absolute
+	Name      string // snapshot name, used by vagrant.{package,restore}
 	Success   dnode.Function
 	Failure   dnode.Function
 	Output    dnode.Function
@@ -148,12 +149,16 @@ func (k *Klient) send(queryString, method string, req, resp interface{}) (string
 }
 
 func (k *Klient) cmd(queryString, method, boxPath string) error {
+	return k.command(queryString, method, &Command{FilePath: boxPath})
+}
+
+func (k *Klient) command(queryString, method string, req *Command) error {
 	queryString, err := utils.QueryString(queryString)
 	if err != nil {
 		return err
 	}
 
-	k.Log.Debug("calling %q command on %q with %q", method, queryString, boxPath)
+	k.Log.Debug("calling %q command on %q with %q", method, queryString, req.FilePath)
 
 	kref, err := klient.ConnectTimeout(k.Kite, queryString, k.dialTimeout())
 	if err != nil {
@@ -214,12 +219,9 @@ func (k *Klient) cmd(queryString, method, boxPath string) error {
 		beat <- struct{}{}
 	})
 
-	req := &Command{
-		FilePath:  boxPath,
-		Success:   success,
-		Failure:   failure,
-		Heartbeat: heartbeat,
-	}
+	req.Success = success
+	req.Failure = failure
+	req.Heartbeat = heartbeat
 
 	if k.Debug {
 		log := k.Log.New(method)
@@ -313,6 +315,28 @@ func (k *Klient) Halt(queryString, boxPath string) error {
 	return k.cmd(queryString, "vagrant.halt", boxPath)
 }
 
+// Package calls vagrant.package method on a kite given by the queryString.
+func (k *Klient) Package(queryString, boxPath, name string) error {
+	return k.command(queryString, "vagrant.package", &Command{FilePath: boxPath, Name: name})
+}
+
+// Restore calls vagrant.restore method on a kite given by the queryString.
+func (k *Klient) Restore(queryString, boxPath, name string) error {
+	return k.command(queryString, "vagrant.restore", &Command{FilePath: boxPath, Name: name})
+}
+
+// RemoveSnapshot calls vagrant.removeSnapshot method on a kite given by the queryString.
+func (k *Klient) RemoveSnapshot(queryString, boxPath, name string) error {
+	req := &Command{
+		FilePath: boxPath,
+		Name:     name,
+	}
+
+	var ok bool
+	_, err := k.send(queryString, "vagrant.removeSnapshot", req, &ok)
+	return err
+}
+
 // Version calls vagrant.version method on a kite given by the queryString.
 func (k *Klient) Version(queryString string) (string, error) {
 	req := &struct {
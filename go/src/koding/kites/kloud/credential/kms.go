@@ -0,0 +1,261 @@
+package credential
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"koding/db/models"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// KMS provides an interface for managing master keys, which are
+// used for envelope encryption of credential data.
+//
+// Each credential data is encrypted with its own data key,
+// which is then encrypted with a master key and stored
+// alongside the ciphertext together with the master key ID.
+//
+// The KMS interface can be implemented by a remote key management
+// service. The FileKMS is a local implementation that keeps
+// master keys in files.
+type KMS interface {
+	// KeyID gives ID of the current master key.
+	KeyID() string
+
+	// GenerateDataKey generates new data key. It returns the ID of
+	// the master key, and the data key both in plaintext and
+	// encrypted with the master key.
+	GenerateDataKey() (keyID string, plaintext, ciphertext []byte, err error)
+
+	// Decrypt decrypts the data key that was encrypted with
+	// the master key of the given ID.
+	Decrypt(keyID string, ciphertext []byte) (plaintext []byte, err error)
+}
+
+// FileKMS is a KMS implementation that reads AES-256 master keys
+// from local files.
+//
+// The first key is the current master key, which is used
+// for encryption. The rest of the keys are used for decrypting
+// data keys which were encrypted before the key rotation.
+type FileKMS struct {
+	ids  []string
+	keys map[string][]byte // maps key ID to master key
+}
+
+var _ KMS = (*FileKMS)(nil)
+
+// NewFileKMS gives new FileKMS for the given master key files.
+//
+// Each file is expected to contain base64-encoded 32-byte key,
+// as created by GenerateKeyFile.
+func NewFileKMS(files ...string) (*FileKMS, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no master key files given")
+	}
+
+	kms := &FileKMS{
+		keys: make(map[string][]byte, len(files)),
+	}
+
+	for _, file := range files {
+		key, err := readKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		id := keyID(key)
+
+		if _, ok := kms.keys[id]; ok {
+			continue
+		}
+
+		kms.ids = append(kms.ids, id)
+		kms.keys[id] = key
+	}
+
+	return kms, nil
+}
+
+// GenerateKeyFile creates new file with a random master key.
+//
+// It fails if the file already exists.
+func GenerateKeyFile(file string) error {
+	key := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, base64.StdEncoding.EncodeToString(key)+"\n")
+
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// KeyID implements the KMS interface.
+func (kms *FileKMS) KeyID() string {
+	return kms.ids[0]
+}
+
+// GenerateDataKey implements the KMS interface.
+func (kms *FileKMS) GenerateDataKey() (string, []byte, []byte, error) {
+	id := kms.KeyID()
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", nil, nil, err
+	}
+
+	nonce, ciphertext, err := seal(kms.keys[id], key, []byte(id))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return id, key, append(nonce, ciphertext...), nil
+}
+
+// Decrypt implements the KMS interface.
+func (kms *FileKMS) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	key, ok := kms.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q not found", keyID)
+	}
+
+	if len(ciphertext) < 12 {
+		return nil, errors.New("data key is too short")
+	}
+
+	return open(key, ciphertext[:12], ciphertext[12:], []byte(keyID))
+}
+
+func readKeyFile(file string) ([]byte, error) {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(p)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid master key: %s", file, err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s: invalid master key length: %d", file, len(key))
+	}
+
+	return key, nil
+}
+
+// keyID identifies the key by a truncated hash of it.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Encrypt encrypts the given credential data value with a new data key
+// obtained from the kms.
+//
+// The value is encoded to BSON before encryption, the same way
+// it would be stored as jCredentialDatas.meta. The ident is
+// authenticated as additional data, so the ciphertext can't
+// be moved to a credential data of other identifier.
+func Encrypt(kms KMS, ident string, data interface{}) (*models.CredentialDataEncryption, error) {
+	p, err := bson.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	id, key, encKey, err := kms.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, ciphertext, err := seal(key, p, []byte(ident))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CredentialDataEncryption{
+		KeyID:      id,
+		DataKey:    encKey,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Decrypt decrypts the credential data value, which was
+// encrypted with Encrypt for the same ident.
+func Decrypt(kms KMS, ident string, enc *models.CredentialDataEncryption) (bson.M, error) {
+	key, err := kms.Decrypt(enc.KeyID, enc.DataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := open(key, enc.Nonce, enc.Ciphertext, []byte(ident))
+	if err != nil {
+		return nil, err
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(p, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func seal(key, plaintext, data []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, aead.Seal(nil, nonce, plaintext, data), nil
+}
+
+func open(key, nonce, ciphertext, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+
+	return aead.Open(nil, nonce, ciphertext, data)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package credential_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"koding/kites/kloud/credential"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestFileKMS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credential")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldKey := filepath.Join(dir, "old.key")
+	newKey := filepath.Join(dir, "new.key")
+
+	for _, file := range []string{oldKey, newKey} {
+		if err := credential.GenerateKeyFile(file); err != nil {
+			t.Fatalf("GenerateKeyFile()=%s", err)
+		}
+	}
+
+	if err := credential.GenerateKeyFile(oldKey); err == nil {
+		t.Fatal("expected GenerateKeyFile to not overwrite existing key")
+	}
+
+	oldKMS, err := credential.NewFileKMS(oldKey)
+	if err != nil {
+		t.Fatalf("NewFileKMS()=%s", err)
+	}
+
+	data := bson.M{
+		"access_key": "AKIA",
+		"secret_key": "secret",
+		"region":     "us-east-1",
+	}
+
+	enc, err := credential.Encrypt(oldKMS, "cred", data)
+	if err != nil {
+		t.Fatalf("Encrypt()=%s", err)
+	}
+
+	if enc.KeyID != oldKMS.KeyID() {
+		t.Fatalf("got %q, want %q", enc.KeyID, oldKMS.KeyID())
+	}
+
+	// Rotated KMS encrypts with the new key and is still
+	// able to decrypt data encrypted with the old one.
+	rotatedKMS, err := credential.NewFileKMS(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewFileKMS()=%s", err)
+	}
+
+	if rotatedKMS.KeyID() == oldKMS.KeyID() {
+		t.Fatalf("expected key IDs to differ: %q", rotatedKMS.KeyID())
+	}
+
+	got, err := credential.Decrypt(rotatedKMS, "cred", enc)
+	if err != nil {
+		t.Fatalf("Decrypt()=%s", err)
+	}
+
+	if !reflect.DeepEqual(got, data) {
+		t.Fatalf("got %+v, want %+v", got, data)
+	}
+
+	newKMS, err := credential.NewFileKMS(newKey)
+	if err != nil {
+		t.Fatalf("NewFileKMS()=%s", err)
+	}
+
+	if _, err := credential.Decrypt(newKMS, "cred", enc); err == nil {
+		t.Fatal("expected Decrypt to fail without old master key")
+	}
+
+	if _, err := credential.Decrypt(rotatedKMS, "other", enc); err == nil {
+		t.Fatal("expected Decrypt to fail for ciphertext of other credential")
+	}
+
+	enc.Ciphertext[0] ^= 0xff
+
+	if _, err := credential.Decrypt(rotatedKMS, "cred", enc); err == nil {
+		t.Fatal("expected Decrypt to fail for tampered ciphertext")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"koding/db/mongodb/modelhelper"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/koding/logging"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -55,15 +56,23 @@ func (db *mongoStore) Fetch(_ string, creds map[string]interface{}) error {
 			continue
 		}
 
+		meta, err := db.meta(data)
+		if err != nil {
+			db.Log.Warning("%s: reading credential data failed: %s", ident, err)
+
+			missing = append(missing, ident)
+			continue
+		}
+
 		if v != nil {
 			// The mapstructure package does not allow custom decoding
 			// and when v is *object.Inliner it will fail with:
 			//
 			//   &mapstructure.Error{Errors:[]string{"InlineFirst: unsupported type: interface", "InlineSecond: unsupported type: interface"}}
 			//
-			p, e := json.Marshal(data.Meta)
+			p, e := json.Marshal(meta)
 			if e != nil {
-				db.Log.Debug("%s: marshal failed with: %s (%#v)", ident, data.Meta, e)
+				db.Log.Debug("%s: marshal failed with: %s (%#v)", ident, meta, e)
 
 				missing = append(missing, ident)
 				continue
@@ -85,7 +94,7 @@ func (db *mongoStore) Fetch(_ string, creds map[string]interface{}) error {
 				}
 			}
 		} else {
-			creds[ident] = data.Meta
+			creds[ident] = meta
 		}
 
 		db.Log.Debug("fetched credential data for %q: %v", ident, creds[ident])
@@ -103,14 +112,17 @@ func (db *mongoStore) Fetch(_ string, creds map[string]interface{}) error {
 // Put updates the jCredentialDatas.meta field of an existing credential.
 // It does not create new credential if it's missing by design - kloud
 // does not own that resource.
+//
+// If the store was configured with KMS, the credential data is
+// encrypted and stored in jCredentialDatas.encryption field instead.
 func (db *mongoStore) Put(_ string, creds map[string]interface{}) error {
 	var err error
 
 	for ident, data := range creds {
-		op := bson.M{
-			"$set": bson.M{
-				"meta": data,
-			},
+		op, e := db.update(ident, data)
+		if e != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %s", ident, e))
+			continue
 		}
 
 		if e := modelhelper.UpdateCredentialData(ident, op); e != nil {
@@ -127,6 +139,106 @@ func (db *mongoStore) Put(_ string, creds map[string]interface{}) error {
 	return err
 }
 
+// replaces implements the replacer interface - encrypted records
+// are stored in place of plaintext ones.
+func (db *mongoStore) replaces(s Store) bool {
+	plain, ok := s.(*mongoStore)
+	return ok && plain.KMS == nil && db.KMS != nil
+}
+
+// meta gives credential data value, decrypting it when needed.
+func (db *mongoStore) meta(data *models.CredentialData) (bson.M, error) {
+	switch {
+	case data.Encryption == nil && db.KMS == nil:
+		return data.Meta, nil
+	case data.Encryption == nil:
+		return nil, errors.New("credential data is not encrypted")
+	case db.KMS == nil:
+		return nil, fmt.Errorf("credential data is encrypted with %q key, but no KMS is configured", data.Encryption.KeyID)
+	default:
+		return Decrypt(db.KMS, data.Identifier, data.Encryption)
+	}
+}
+
+// update gives update operation which stores the given
+// credential data value.
+func (db *mongoStore) update(ident string, data interface{}) (bson.M, error) {
+	if db.KMS == nil {
+		return bson.M{
+			"$set":   bson.M{"meta": data},
+			"$unset": bson.M{"encryption": ""},
+		}, nil
+	}
+
+	enc, err := Encrypt(db.KMS, ident, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{
+		"$set":   bson.M{"encryption": enc},
+		"$unset": bson.M{"meta": ""},
+	}, nil
+}
+
+// RotateKeys re-encrypts all credential datas stored in MongoDB,
+// that were not encrypted with the current master key of the kms.
+//
+// Plaintext credential datas are encrypted as well. Encrypted
+// credential datas that can't be decrypted are left intact.
+//
+// It returns the number of re-encrypted credential datas.
+func RotateKeys(kms KMS, log logging.Logger) (int, error) {
+	if log == nil {
+		log = defaultLog
+	}
+
+	var (
+		db  = &mongoStore{Options: &Options{Log: log, KMS: kms}}
+		id  = kms.KeyID()
+		n   int
+		err error
+	)
+
+	stale := modelhelper.Selector{
+		"encryption.keyId": bson.M{"$ne": id},
+	}
+
+	fn := func(data *models.CredentialData) error {
+		meta := data.Meta
+
+		if data.Encryption != nil {
+			var e error
+			if meta, e = db.meta(data); e != nil {
+				err = multierror.Append(err, fmt.Errorf("%s: %s", data.Identifier, e))
+				return nil
+			}
+		}
+
+		op, e := db.update(data.Identifier, meta)
+		if e == nil {
+			e = modelhelper.UpdateCredentialData(data.Identifier, op)
+		}
+
+		if e != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %s", data.Identifier, e))
+			return nil
+		}
+
+		log.Debug("%s: encrypted with %q key", data.Identifier, id)
+
+		n++
+
+		return nil
+	}
+
+	if e := modelhelper.ForEachCredentialData(stale, fn); e != nil {
+		err = multierror.Append(err, e)
+	}
+
+	return n, err
+}
+
 type MongoPerm struct {
 	AccModel   *models.Account
 	UserModel  *models.User
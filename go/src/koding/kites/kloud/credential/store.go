@@ -78,6 +78,10 @@ type Options struct {
 	CredURL       *url.URL
 	ObjectBuilder *object.Builder
 	Client        *http.Client
+
+	// KMS, when non-nil, is used to encrypt credential datas
+	// stored in MongoDB.
+	KMS KMS
 }
 
 func (opts *Options) objectBuilder() *object.Builder {
@@ -105,11 +109,26 @@ func (opts *Options) log() logging.Logger {
 // NewStore gives new credential store for the given options.
 //
 // The returned Store keeps all credentials encrypted in Sneaker.
+//
+// If CredURL is nil, the credentials are kept in MongoDB instead.
+// When additionally KMS is provided, the credentials are encrypted
+// and every plaintext one is encrypted on its first fetch.
 func NewStore(opts *Options) Store {
 	if opts.CredURL == nil {
-		return &mongoStore{
+		mongo := &mongoStore{
 			Options: opts.new("mongo"),
 		}
+
+		if opts.KMS == nil {
+			return mongo
+		}
+
+		plain := &mongoStore{
+			Options: opts.new("plain"),
+		}
+		plain.KMS = nil
+
+		return MigratingStore(plain, mongo)
 	}
 
 	return &socialStore{
@@ -123,8 +142,17 @@ func NewStore(opts *Options) Store {
 // is then put back to the dst one.
 //
 // On Put migrating store puts the credential both to src and dst
-// stores.
+// stores, unless dst stores credentials in place of src ones -
+// e.g. when moving plaintext credentials to encrypted ones
+// within the same MongoDB collection. In that case
+// the credential is put only to the dst store.
 func MigratingStore(src, dst Store) Store {
+	putter := NewMultiPutter(dst, src)
+
+	if r, ok := dst.(replacer); ok && r.replaces(src) {
+		putter = dst
+	}
+
 	return struct {
 		Fetcher
 		Putter
@@ -133,10 +161,16 @@ func MigratingStore(src, dst Store) Store {
 			dst,
 			&TeeFetcher{Fetcher: src, Putter: dst},
 		),
-		Putter: NewMultiPutter(dst, src),
+		Putter: putter,
 	}
 }
 
+// replacer is implemented by stores, which keep their credentials
+// in place of credentials of other store.
+type replacer interface {
+	replaces(Store) bool
+}
+
 func toIdents(creds map[string]interface{}) []string {
 	idents := make([]string, 0, len(creds))
 	for ident := range creds {
@@ -13,6 +13,7 @@ import (
 
 	"koding/klient/fs"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 	"koding/klient/sshkeys"
 
@@ -234,6 +235,22 @@ func (k *Klient) Kill(req *os.KillRequest) (*os.KillResponse, error) {
 	return &resp, nil
 }
 
+// Search calls the fs.search method of remote klient.
+func (k *Klient) Search(req *fs.SearchRequest) (*fs.SearchResponse, error) {
+	var resp fs.SearchResponse
+
+	if err := k.call("fs.search", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// CancelSearch calls the fs.cancelSearch method of remote klient.
+func (k *Klient) CancelSearch(req *fs.CancelSearchRequest) error {
+	return k.call("fs.cancelSearch", req, nil)
+}
+
 func (k *Klient) call(method string, req, resp interface{}) error {
 	type validator interface {
 		Valid() error
@@ -405,6 +422,19 @@ func (k *Klient) SSHAddKeys(username string, keys ...string) error {
 	return nil
 }
 
+// SSHTrustCA makes remote machine accept certificates signed by the given
+// certificate authority for the principal logging in as username.
+func (k *Klient) SSHTrustCA(username, caKey, principal string) error {
+	opts := sshkeys.TrustCAOptions{
+		Username:  username,
+		Key:       caKey,
+		Principal: principal,
+	}
+
+	_, err := k.Client.TellWithTimeout("sshkeys.trustCA", k.timeout(), opts)
+	return err
+}
+
 // MountHeadIndex returns the number and the overall size of files in a given
 // remote directory.
 func (k *Klient) MountHeadIndex(path string) (absPath string, count int, diskSize int64, err error) {
@@ -451,6 +481,52 @@ func (k *Klient) MountGetIndex(path string) (*index.Index, error) {
 	return resp.Index, nil
 }
 
+// ReadFile returns the content of a given remote file.
+func (k *Klient) ReadFile(path string) ([]byte, error) {
+	req := struct {
+		Path string `json:"path"`
+	}{
+		Path: path,
+	}
+
+	raw, err := k.Client.TellWithTimeout("fs.readFile", k.timeout(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Content []byte `json:"content"`
+	}
+
+	if err := raw.Unmarshal(&resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Content, nil
+}
+
+// MountPutIndex sends the index of local directory to remote machine and
+// returns the paths of files whose content is needed by remote.
+func (k *Klient) MountPutIndex(req *reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	raw, err := k.Client.TellWithTimeout("machine.reverse.index", k.timeout(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &reverse.IndexResponse{}
+	if err := raw.Unmarshal(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// MountApply materializes a local file change on remote machine.
+func (k *Klient) MountApply(req *reverse.ApplyRequest) error {
+	_, err := k.Client.TellWithTimeout("machine.reverse.apply", k.timeout(), req)
+	return err
+}
+
 // SetContext sets provided context to Klient.
 func (k *Klient) SetContext(ctx context.Context) {
 	k.mu.Lock()
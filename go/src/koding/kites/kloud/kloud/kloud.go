@@ -3,6 +3,7 @@ package kloud
 import (
 	"errors"
 	_ "expvar"
+	"fmt"
 	"io/ioutil"
 	"log"
 	_ "net/http/pprof"
@@ -20,6 +21,7 @@ import (
 	"koding/httputil"
 	"koding/kites/common"
 	"koding/kites/config"
+	"koding/kites/health"
 	"koding/kites/keygen"
 	"koding/kites/kloud/contexthelper/publickeys"
 	"koding/kites/kloud/contexthelper/session"
@@ -28,12 +30,15 @@ import (
 	"koding/kites/kloud/keycreator"
 	"koding/kites/kloud/machine"
 	"koding/kites/kloud/metrics"
+	"koding/kites/kloud/pkg/dnsclient"
 	"koding/kites/kloud/queue"
+	"koding/kites/kloud/sshcert"
 	"koding/kites/kloud/stack"
 	"koding/kites/kloud/stack/provider"
 	"koding/kites/kloud/team"
 	"koding/kites/kloud/userdata"
 	kitemetrics "koding/kites/metrics"
+	"koding/kites/tracing"
 	"koding/remoteapi"
 	"koding/tools/util"
 	"socialapi/workers/presence/client"
@@ -108,6 +113,15 @@ type Config struct {
 	// AWS Describe* API calls.
 	MaxResults int `default:"500"`
 
+	// SnapshotLimit limits the number of machine snapshots per team,
+	// unless overwritten with snapshots.limit group data. Zero
+	// means no limit.
+	SnapshotLimit int `default:"10"`
+
+	// SSHCertTTL is the validity period of SSH user certificates issued
+	// by team certificate authorities.
+	SSHCertTTL time.Duration `default:"30m"`
+
 	// --- KLIENT DEVELOPMENT ---
 	// KontrolURL to connect and to de deployed with klient
 	KontrolURL string `required:"true"`
@@ -141,6 +155,38 @@ type Config struct {
 	KeygenRegion    string        `default:"us-east-1"`
 	KeygenTokenTTL  time.Duration `default:"3h"`
 
+	// Keygen "gcs" auth type configuration, which issues
+	// signed URLs for the GCS bucket.
+	KeygenGCSBucket         string
+	KeygenGCSAccessID       string
+	KeygenGCSPrivateKeyFile string
+
+	// Keygen "minio" auth type configuration, which issues
+	// presigned URLs for the S3-compatible server.
+	KeygenMinioEndpoint  string
+	KeygenMinioAccessKey string
+	KeygenMinioSecretKey string
+	KeygenMinioBucket    string
+
+	// --- DNS CONFIGURATION ---
+	// DNSBackend selects a backend which manages machine domains
+	// within HostedZone, one of: "route53", "rfc2136", "file", "hosts"
+	// or "noop". If empty, the domains are not managed.
+	DNSBackend string
+
+	// DNSServer is an address of the primary DNS server, used by
+	// the "rfc2136" backend.
+	DNSServer string
+
+	// TSIG key used to sign update requests by the "rfc2136" backend.
+	DNSTSIGKey       string
+	DNSTSIGSecret    string
+	DNSTSIGAlgorithm string `default:"hmac-sha256."`
+
+	// DNSFile is a path of the zone file for the "file" backend
+	// or a path of the hosts file for the "hosts" backend.
+	DNSFile string
+
 	// --- KONTROL CONFIGURATION ---
 	Public      bool   // Try to register with a public ip
 	RegisterURL string // Explicitly register with this given url
@@ -155,6 +201,17 @@ type Config struct {
 
 	KodingURL *config.URL // Koding base URL
 	NoSneaker bool        // use Mongo for reading credentials, instead of /social/credential endpoint
+
+	// CredentialKeyFiles are paths of master key files, which are used
+	// to encrypt credentials kept in Mongo. The first key is used
+	// for encryption, the rest is used for decrypting credentials
+	// encrypted before the key rotation. If empty, credentials
+	// are stored in plaintext.
+	CredentialKeyFiles []string
+
+	// TraceExporter is a file path or a collector URL, which spans
+	// of stack requests are exported to; if empty, tracing is disabled.
+	TraceExporter string
 }
 
 // New gives new, registered kloud kite.
@@ -214,8 +271,25 @@ func New(conf *Config) (*Kloud, error) {
 		storeOpts.CredURL = e.Social().WithPath("/credential").Private.URL
 	}
 
+	if len(conf.CredentialKeyFiles) != 0 {
+		storeOpts.KMS, err = credential.NewFileKMS(conf.CredentialKeyFiles...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	sess.Log.Debug("storeOpts: %+v", storeOpts)
 
+	if conf.TraceExporter != "" {
+		tracing.DefaultTracer.Exporter, err = tracing.NewExporter(conf.TraceExporter)
+		if err != nil {
+			return nil, err
+		}
+
+		tracing.DefaultTracer.Service = stack.NAME
+		tracing.DefaultTracer.Log = sess.Log.New("tracing").Warning
+	}
+
 	userPrivateKey, userPublicKey := userMachinesKeys(conf.UserPublicKey, conf.UserPrivateKey)
 
 	stacker := &provider.Stacker{
@@ -306,6 +380,13 @@ func New(conf *Config) (*Kloud, error) {
 	kloud.Stack.Locker = stacker
 	kloud.Stack.Log = sess.Log
 	kloud.Stack.SecretKey = conf.KloudSecretKey
+	kloud.Stack.SnapshotLimit = conf.SnapshotLimit
+	kloud.Stack.SSHCA = newSSHCAStore(storeOpts, sess.Log)
+	kloud.Stack.SSHCertTTL = conf.SSHCertTTL
+
+	if err := modelhelper.EnsureSSHAuthorityIndexes(); err != nil {
+		return nil, err
+	}
 
 	for _, p := range provider.All() {
 		s := stacker.New(p)
@@ -321,7 +402,12 @@ func New(conf *Config) (*Kloud, error) {
 
 	go kloud.Queue.Run()
 
-	if conf.KeygenAccessKey != "" && conf.KeygenSecretKey != "" {
+	issuers, err := newKeygenIssuers(conf, sess.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	if (conf.KeygenAccessKey != "" && conf.KeygenSecretKey != "") || len(issuers) != 0 {
 		cfg := &keygen.Config{
 			AccessKey:  conf.KeygenAccessKey,
 			SecretKey:  conf.KeygenSecretKey,
@@ -329,6 +415,7 @@ func New(conf *Config) (*Kloud, error) {
 			Bucket:     conf.KeygenBucket,
 			AuthExpire: conf.KeygenTokenTTL,
 			AuthFunc:   kloud.Stack.ValidateUser,
+			Issuers:    issuers,
 			Kite:       k,
 			Metrics:    stats,
 		}
@@ -371,6 +458,14 @@ func New(conf *Config) (*Kloud, error) {
 
 	// Machine handling.
 	kloud.HandleFunc("machine.list", kloud.Stack.MachineList)
+	kloud.HandleFunc("machine.snapshot.create", kloud.Stack.SnapshotCreate)
+	kloud.HandleFunc("machine.snapshot.list", kloud.Stack.SnapshotList)
+	kloud.HandleFunc("machine.snapshot.delete", kloud.Stack.SnapshotDelete)
+	kloud.HandleFunc("machine.snapshot.restore", kloud.Stack.SnapshotRestore)
+
+	// SSH access handling.
+	kloud.HandleFunc("ssh.cert", kloud.Stack.SSHCert)
+	kloud.HandleFunc("ssh.config", kloud.Stack.SSHConfig)
 
 	// Single machine handling.
 	kloud.HandleFunc("stop", kloud.Stack.Stop)
@@ -385,6 +480,16 @@ func New(conf *Config) (*Kloud, error) {
 
 	k.HandleHTTPFunc("/healthCheck", artifact.HealthCheckHandler(Name))
 	k.HandleHTTPFunc("/version", artifact.VersionHandler())
+	k.HandleHTTP("/metrics", kitemetrics.PrometheusHandler())
+
+	h := health.New(Name)
+	h.Live("http", health.HTTP(k, "/healthCheck"))
+	h.Ready("mongo", health.Mongo(sess.DB))
+	h.Ready("kontrol", health.Kontrol(k))
+	if kloud.Keygen != nil {
+		h.Ready("s3", kloud.Keygen)
+	}
+	h.Register(k)
 
 	for worker, key := range authUsers {
 		worker, key := worker, key
@@ -435,6 +540,10 @@ func (k *Kloud) Close() error {
 		merr = multierror.Append(merr, err)
 	}
 
+	if err := tracing.DefaultTracer.Close(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+
 	k.closeOnce.Do(func() {
 		close(k.closeChan)
 	})
@@ -495,9 +604,97 @@ func newSession(conf *Config, k *kite.Kite) (*session.Session, error) {
 
 	sess.DNSStorage = dnsstorage.NewMongodbStorage(sess.DB)
 
+	dns, err := newDNSClient(conf, c, sess.Log.New("dns"))
+	if err != nil {
+		return nil, err
+	}
+
+	sess.DNSClient = dns
+
 	return sess, nil
 }
 
+func newKeygenIssuers(conf *Config, log logging.Logger) (map[string]keygen.Issuer, error) {
+	issuers := make(map[string]keygen.Issuer)
+
+	if conf.KeygenGCSBucket != "" {
+		key, err := ioutil.ReadFile(conf.KeygenGCSPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		gcs, err := keygen.NewGCSIssuer(&keygen.GCSConfig{
+			Bucket:         conf.KeygenGCSBucket,
+			GoogleAccessID: conf.KeygenGCSAccessID,
+			PrivateKey:     key,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("keygen: gcs: %s", err)
+		}
+
+		issuers["gcs"] = gcs
+	}
+
+	if conf.KeygenMinioEndpoint != "" {
+		minio, err := keygen.NewMinioIssuer(&keygen.MinioConfig{
+			Endpoint:  conf.KeygenMinioEndpoint,
+			AccessKey: conf.KeygenMinioAccessKey,
+			SecretKey: conf.KeygenMinioSecretKey,
+			Bucket:    conf.KeygenMinioBucket,
+			Log:       log.New("minio"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("keygen: minio: %s", err)
+		}
+
+		issuers["minio"] = minio
+	}
+
+	return issuers, nil
+}
+
+func newDNSClient(conf *Config, c *credentials.Credentials, log logging.Logger) (dnsclient.Client, error) {
+	switch conf.DNSBackend {
+	case "":
+		return nil, nil
+	case "route53":
+		return dnsclient.NewRoute53Client(&dnsclient.Options{
+			Creds:      c,
+			HostedZone: conf.HostedZone,
+			Log:        log,
+			Debug:      conf.DebugMode,
+		})
+	case "rfc2136":
+		return dnsclient.NewRFC2136(&dnsclient.RFC2136Options{
+			Server:        conf.DNSServer,
+			HostedZone:    conf.HostedZone,
+			TSIGKey:       conf.DNSTSIGKey,
+			TSIGSecret:    conf.DNSTSIGSecret,
+			TSIGAlgorithm: conf.DNSTSIGAlgorithm,
+			Log:           log,
+		})
+	case "file":
+		return dnsclient.NewFile(&dnsclient.FileOptions{
+			Path:       conf.DNSFile,
+			HostedZone: conf.HostedZone,
+			Log:        log,
+		})
+	case "hosts", "noop":
+		opts := &dnsclient.HostsOptions{
+			HostedZone: conf.HostedZone,
+			Log:        log,
+		}
+
+		if conf.DNSBackend == "hosts" {
+			opts.Path = conf.DNSFile
+		}
+
+		return dnsclient.NewHosts(opts), nil
+	default:
+		return nil, fmt.Errorf("unknown DNS backend: %q", conf.DNSBackend)
+	}
+}
+
 func newEndpoints(cfg *Config) *config.Endpoints {
 	e := config.NewKonfig(&config.Environments{Env: cfg.Environment}).Endpoints
 
@@ -515,6 +712,20 @@ func newEndpoints(cfg *Config) *config.Endpoints {
 	return e
 }
 
+// newSSHCAStore gives a store of team SSH certificate authorities. Their
+// private keys are kept in MongoDB credential datas, which are encrypted
+// with the credential master keys, and never sent to Sneaker, as they
+// are not owned by any user.
+func newSSHCAStore(storeOpts *credential.Options, log logging.Logger) *sshcert.MongoStore {
+	opts := *storeOpts
+	opts.CredURL = nil
+	opts.Log = log.New("sshca")
+
+	return &sshcert.MongoStore{
+		Creds: credential.NewStore(&opts),
+	}
+}
+
 func userMachinesKeys(publicPath, privatePath string) (string, string) {
 	pubKey, err := ioutil.ReadFile(publicPath)
 	if err != nil {
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"koding/db/mongodb/modelhelper"
+	"koding/kites/kloud/credential"
+
+	"github.com/koding/logging"
+	"github.com/mitchellh/cli"
+)
+
+// RotateCredentials provides an implementation for "rotate-credentials" command.
+type RotateCredentials struct {
+	Key      *string
+	OldKeys  *string
+	Generate *bool
+	MongoURL *string
+}
+
+// NewRotateCredentials gives new RotateCredentials value.
+func NewRotateCredentials() cli.CommandFactory {
+	return func() (cli.Command, error) {
+		f := NewFlag("rotate-credentials", "Re-encrypt all credentials with a new master key")
+		f.action = &RotateCredentials{
+			Key:      f.String("key", "", "Path of the new master key file."),
+			OldKeys:  f.String("old-keys", "", "Comma-separated paths of master key files the credentials are currently encrypted with."),
+			Generate: f.Bool("generate", false, "Generate new master key file under -key path."),
+			MongoURL: f.String("mongourl", "127.0.0.1:27017/koding", "Mongo URL of kloud database"),
+		}
+		return f, nil
+	}
+}
+
+// Valid implements the stack.Validator interface.
+func (r *RotateCredentials) Valid() error {
+	if *r.Key == "" {
+		return errors.New("-key is empty")
+	}
+
+	if *r.MongoURL == "" {
+		return errors.New("-mongourl is empty")
+	}
+
+	return nil
+}
+
+// Action is an entry point for "rotate-credentials" subcommand.
+func (r *RotateCredentials) Action([]string) error {
+	if err := r.Valid(); err != nil {
+		return err
+	}
+
+	if *r.Generate {
+		if err := credential.GenerateKeyFile(*r.Key); err != nil {
+			return err
+		}
+
+		DefaultUi.Info(fmt.Sprintf("Generated new master key: %s", *r.Key))
+	}
+
+	files := []string{*r.Key}
+
+	if *r.OldKeys != "" {
+		files = append(files, strings.Split(*r.OldKeys, ",")...)
+	}
+
+	kms, err := credential.NewFileKMS(files...)
+	if err != nil {
+		return err
+	}
+
+	modelhelper.Initialize(*r.MongoURL)
+	defer modelhelper.Close()
+
+	log := logging.NewCustom("rotate-credentials", flagDebug)
+
+	n, err := credential.RotateKeys(kms, log)
+
+	DefaultUi.Info(fmt.Sprintf("Re-encrypted %d credentials with %q master key.", n, kms.KeyID()))
+
+	return err
+}
@@ -5,14 +5,16 @@ import "github.com/mitchellh/cli"
 type Snapshot struct {
 	id         *string
 	snapshotId *string
+	provider   *string
 }
 
 func NewDeleteSnapshot() cli.CommandFactory {
 	return func() (cli.Command, error) {
-		f := NewFlag("deleteSnapshot", "Delete a snapshot")
+		f := NewFlag("delete-snapshot", "Delete a snapshot")
 		f.action = &Snapshot{
 			id:         f.String("ids", "", "Machine Id belonging to the Snapshot"),
 			snapshotId: f.String("snapshot", "", "Snapshot to be deleted"),
+			provider:   f.String("provider", "aws", "Kloud provider."),
 		}
 		return f, nil
 	}
@@ -23,9 +25,10 @@ func (s *Snapshot) Action(args []string) error {
 	if err != nil {
 		return err
 	}
-	_, err = k.Tell("deleteSnapshot", &KloudArgs{
+	_, err = k.Tell("machine.snapshot.delete", &KloudArgs{
 		MachineId:  *s.id,
 		SnapshotId: *s.snapshotId,
+		Provider:   *s.provider,
 	})
 
 	return err
@@ -17,23 +17,24 @@ func main() {
 	c := cli.NewCLI(Name, Version)
 	c.Args = os.Args[1:]
 	c.Commands = map[string]cli.CommandFactory{
-		"kontrol":         command.NewKontrol(),
-		"vagrant":         command.NewVagrant(),
-		"migrate":         command.NewMigrate(),
-		"team":            command.NewTeam(),
-		"group":           command.NewGroup(),
-		"ping":            command.NewPing(),
-		"event":           command.NewEvent(),
-		"info":            command.NewInfo(),
-		"build":           command.NewBuild(),
-		"start":           command.NewCmd("start"),
-		"stop":            command.NewCmd("stop"),
-		"destroy":         command.NewCmd("destroy"),
-		"restart":         command.NewCmd("restart"),
-		"resize":          command.NewCmd("resize"),
-		"reinit":          command.NewCmd("reinit"),
-		"create-snapshot": command.NewCmd("createSnapshot"),
-		"delete-snapshot": command.NewDeleteSnapshot(),
+		"kontrol":            command.NewKontrol(),
+		"vagrant":            command.NewVagrant(),
+		"migrate":            command.NewMigrate(),
+		"team":               command.NewTeam(),
+		"group":              command.NewGroup(),
+		"ping":               command.NewPing(),
+		"event":              command.NewEvent(),
+		"info":               command.NewInfo(),
+		"build":              command.NewBuild(),
+		"start":              command.NewCmd("start"),
+		"stop":               command.NewCmd("stop"),
+		"destroy":            command.NewCmd("destroy"),
+		"restart":            command.NewCmd("restart"),
+		"resize":             command.NewCmd("resize"),
+		"reinit":             command.NewCmd("reinit"),
+		"create-snapshot":    command.NewCmd("machine.snapshot.create"),
+		"delete-snapshot":    command.NewDeleteSnapshot(),
+		"rotate-credentials": command.NewRotateCredentials(),
 	}
 
 	_, err := c.Run()
@@ -136,8 +136,9 @@ func (s State) ValidMethods() []string {
 			"destroy",
 			"restart",
 			"reinit",
-			"createSnapshot",
-			"deleteSnapshot",
+			"machine.snapshot.create",
+			"machine.snapshot.delete",
+			"machine.snapshot.restore",
 		}
 	case Stopped:
 		return []string{
@@ -145,8 +146,9 @@ func (s State) ValidMethods() []string {
 			"resize",
 			"destroy",
 			"reinit",
-			"createSnapshot",
-			"deleteSnapshot",
+			"machine.snapshot.create",
+			"machine.snapshot.delete",
+			"machine.snapshot.restore",
 		}
 	case Terminated:
 		return []string{"build"}
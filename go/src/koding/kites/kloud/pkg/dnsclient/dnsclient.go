@@ -12,7 +12,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/cenkalti/backoff"
-	"github.com/dchest/validator"
 	"github.com/koding/logging"
 )
 
@@ -334,31 +333,8 @@ func (r *Route53) HostedZone() string {
 }
 
 func (r *Route53) Validate(domain, username string) error {
-	hostedZone := r.HostedZone()
-
-	if domain == "" {
-		return r.errorf("Domain name argument is empty")
-	}
-
-	if domain == hostedZone {
-		return r.errorf("Domain %q can't be the same as top-level domain %q", domain, hostedZone)
-	}
-
-	if !strings.Contains(domain, hostedZone) {
-		return r.errorf("Domain %q doesn't contain hostedzone %q", domain, hostedZone)
-	}
-
-	rest := strings.TrimSuffix(domain, "."+hostedZone)
-	if rest == domain {
-		return r.errorf("Domain %q is invalid (1)", domain)
-	}
-
-	if split := strings.Split(rest, "."); split[len(split)-1] != username {
-		return r.errorf("Domain %q doesn't contain %q username (hostedZone=%q)", domain, username, hostedZone)
-	}
-
-	if !validator.IsValidDomain(domain) {
-		return r.errorf("Domain %q is invalid (2)", domain)
+	if err := validate(domain, username, r.HostedZone()); err != nil {
+		return r.errorf("%s", err)
 	}
 
 	return nil
@@ -0,0 +1,178 @@
+package dnsclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/koding/logging"
+)
+
+const (
+	hostsBegin = "# BEGIN kloud records"
+	hostsEnd   = "# END kloud records"
+)
+
+// HostsOptions are used to configure the Hosts client.
+type HostsOptions struct {
+	// Path is a path of the hosts file. If empty, the records are
+	// kept in memory only.
+	Path string
+
+	// HostedZone is the top level domain of the records.
+	HostedZone string
+
+	Log logging.Logger
+}
+
+// Hosts is a Client implementation meant for development purposes.
+//
+// It keeps A records in a section of a hosts file, which allows
+// for resolving machine domains on the host kloud is running on.
+// The rest of the hosts file is preserved.
+//
+// When no path is given, the Hosts is a no-op client which keeps
+// the records in memory.
+type Hosts struct {
+	local
+}
+
+// NewHosts creates new Hosts client for the given options.
+func NewHosts(opts *HostsOptions) *Hosts {
+	h := &Hosts{
+		local: local{
+			zone: opts.HostedZone,
+			log:  defaultLog,
+		},
+	}
+
+	if opts.Path != "" {
+		h.c = &hostsFile{path: opts.Path}
+	} else {
+		h.c = &memory{}
+	}
+
+	if opts.Log != nil {
+		h.log = opts.Log
+	}
+
+	return h
+}
+
+type memory struct {
+	recs Records
+}
+
+var _ codec = (*memory)(nil)
+
+func (m *memory) load() (Records, error) {
+	recs := make(Records, len(m.recs))
+	for i, rec := range m.recs {
+		recCopy := *rec
+		recs[i] = &recCopy
+	}
+	return recs, nil
+}
+
+func (m *memory) save(recs Records) error {
+	m.recs = recs
+	return nil
+}
+
+type hostsFile struct {
+	path string
+}
+
+var _ codec = (*hostsFile)(nil)
+
+func (h *hostsFile) load() (Records, error) {
+	_, recs, err := h.read()
+	return recs, err
+}
+
+func (h *hostsFile) save(recs Records) error {
+	lines, _, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	for _, line := range lines {
+		fmt.Fprintln(&buf, line)
+	}
+
+	fmt.Fprintln(&buf, hostsBegin)
+	for _, rec := range recs {
+		if rec.Type != "A" && rec.Type != "AAAA" {
+			return fmt.Errorf("hosts file does not support %s records", rec.Type)
+		}
+
+		fmt.Fprintf(&buf, "%s\t%s\n", rec.IP, strings.TrimSuffix(rec.Name, "."))
+	}
+	fmt.Fprintln(&buf, hostsEnd)
+
+	return writeFile(h.path, buf.Bytes())
+}
+
+// read reads the hosts file, returning the lines outside
+// of the kloud section and the records from within it.
+func (h *hostsFile) read() (lines []string, recs Records, err error) {
+	p, err := ioutil.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inside := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch strings.TrimSpace(line) {
+		case hostsBegin:
+			inside = true
+			continue
+		case hostsEnd:
+			inside = false
+			continue
+		}
+
+		if !inside {
+			lines = append(lines, line)
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		typ := "A"
+		if ip := net.ParseIP(fields[0]); ip == nil {
+			continue
+		} else if ip.To4() == nil {
+			typ = "AAAA"
+		}
+
+		recs = append(recs, &Record{
+			Name: fqdn(fields[1]),
+			Type: typ,
+			IP:   fields[0],
+			TTL:  30,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return lines, recs, nil
+}
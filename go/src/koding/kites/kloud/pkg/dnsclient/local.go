@@ -0,0 +1,202 @@
+package dnsclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/koding/logging"
+)
+
+// codec reads and writes records from a local storage.
+type codec interface {
+	load() (Records, error)
+	save(Records) error
+}
+
+// local is a Client implementation for backends that keep the
+// records locally - it serializes the access to the records
+// and delegates storing them to a codec.
+type local struct {
+	zone string
+	log  logging.Logger
+	c    codec
+
+	mu sync.Mutex // protects c
+}
+
+var _ Client = (*local)(nil)
+
+// Upsert creates or updates the domain record with the given ip address.
+func (l *local) Upsert(domain, newIP string) error {
+	return l.UpsertRecord(&Record{
+		Name: domain,
+		Type: "A",
+		IP:   newIP,
+		TTL:  30,
+	})
+}
+
+// UpsertRecord creates or updates a DNS record.
+func (l *local) UpsertRecord(rec *Record) error {
+	l.log.Debug("upserting record: %# v", rec)
+
+	return l.update(func(recs Records) (Records, error) {
+		rec := normalize(rec)
+		return append(recs.without(rec.Name), rec), nil
+	})
+}
+
+// Delete deletes a domain record for the given domain.
+func (l *local) Delete(domain string) error {
+	l.log.Debug("deleting domain: %s", domain)
+
+	return l.update(func(recs Records) (Records, error) {
+		return recs.without(domain), nil
+	})
+}
+
+// Rename changes the domain from oldDomain to newDomain.
+func (l *local) Rename(oldDomain, newDomain string) error {
+	l.log.Debug("renaming domain %q to %q", oldDomain, newDomain)
+
+	return l.update(func(recs Records) (Records, error) {
+		old := recs.ByName(fqdn(oldDomain))
+		if len(old) == 0 {
+			return nil, ErrNoRecord
+		}
+
+		rec := *old[0]
+		rec.Name = fqdn(newDomain)
+
+		return append(recs.without(oldDomain).without(newDomain), &rec), nil
+	})
+}
+
+// Get retrieves the record for the given domain name.
+func (l *local) Get(domain string) (*Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recs, err := l.c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if recs = recs.ByName(fqdn(domain)); len(recs) != 0 {
+		return recs[0], nil
+	}
+
+	return nil, ErrNoRecord
+}
+
+// GetAll retrieves all the records, which names contain the given one.
+func (l *local) GetAll(name string) ([]*Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recs, err := l.c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Record
+	for _, rec := range recs {
+		if strings.Contains(rec.Name, strings.ToLower(name)) {
+			res = append(res, rec)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return res, nil
+}
+
+// HostedZone returns the zone the records are managed within.
+func (l *local) HostedZone() string {
+	return l.zone
+}
+
+// Validate validates if the given domain name is valid.
+func (l *local) Validate(domain, username string) error {
+	return validate(domain, username, l.zone)
+}
+
+func (l *local) update(fn func(Records) (Records, error)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recs, err := l.c.load()
+	if err != nil {
+		return err
+	}
+
+	if recs, err = fn(recs); err != nil {
+		return err
+	}
+
+	return l.c.save(recs)
+}
+
+// without returns the records without the ones for the given name.
+func (r Records) without(name string) (res Records) {
+	name = fqdn(name)
+	for _, rec := range r {
+		if rec.Name != name {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
+
+// fqdn gives lower-case fully qualified domain name.
+func fqdn(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+func normalize(rec *Record) *Record {
+	recCopy := *rec
+	recCopy.Name = fqdn(rec.Name)
+	recCopy.Type = strings.ToUpper(rec.Type)
+	if recCopy.Type == "CNAME" {
+		recCopy.IP = fqdn(rec.IP)
+	}
+	if recCopy.TTL == 0 {
+		recCopy.TTL = 30
+	}
+	return &recCopy
+}
+
+// writeFile replaces the file atomically with the given content.
+func writeFile(path string, p []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(p); err == nil {
+		err = f.Chmod(0644)
+	}
+
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
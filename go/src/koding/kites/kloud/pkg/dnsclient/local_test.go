@@ -0,0 +1,105 @@
+package dnsclient_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"koding/kites/kloud/pkg/dnsclient"
+)
+
+func testClient(t *testing.T, c dnsclient.Client) {
+	if err := c.Upsert("machine.user.example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Upsert()=%s", err)
+	}
+
+	if err := c.Upsert("machine.user.example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("Upsert()=%s", err)
+	}
+
+	rec, err := c.Get("machine.user.example.com")
+	if err != nil {
+		t.Fatalf("Get()=%s", err)
+	}
+
+	if rec.Type != "A" || rec.IP != "10.0.0.2" {
+		t.Fatalf("got %+v, want A 10.0.0.2 record", rec)
+	}
+
+	if err := c.Rename("machine.user.example.com", "vm.user.example.com"); err != nil {
+		t.Fatalf("Rename()=%s", err)
+	}
+
+	if _, err := c.Get("machine.user.example.com"); err != dnsclient.ErrNoRecord {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrNoRecord)
+	}
+
+	if err := c.Delete("vm.user.example.com"); err != nil {
+		t.Fatalf("Delete()=%s", err)
+	}
+
+	if _, err := c.Get("vm.user.example.com"); err != dnsclient.ErrNoRecord {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrNoRecord)
+	}
+
+	if err := c.Validate("vm.user.example.com", "user"); err != nil {
+		t.Fatalf("Validate()=%s", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnsclient")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	hosts := filepath.Join(dir, "hosts")
+
+	if err := ioutil.WriteFile(hosts, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	file, err := dnsclient.NewFile(&dnsclient.FileOptions{
+		Path:       filepath.Join(dir, "db.example.com"),
+		HostedZone: "example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewFile()=%s", err)
+	}
+
+	cases := map[string]dnsclient.Client{
+		"zone file":  file,
+		"hosts file": dnsclient.NewHosts(&dnsclient.HostsOptions{Path: hosts, HostedZone: "example.com"}),
+		"memory":     dnsclient.NewHosts(&dnsclient.HostsOptions{HostedZone: "example.com"}),
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			testClient(t, c)
+		})
+	}
+
+	if err := file.Upsert("vm.user.example.com", "10.0.0.3"); err != nil {
+		t.Fatalf("Upsert()=%s", err)
+	}
+
+	p, err := ioutil.ReadFile(filepath.Join(dir, "db.example.com"))
+	if err != nil {
+		t.Fatalf("ReadFile()=%s", err)
+	}
+
+	if s := string(p); !strings.Contains(s, "$ORIGIN example.com.") || !strings.Contains(s, "vm.user.example.com. 30 IN A 10.0.0.3") {
+		t.Fatalf("unexpected zone file:\n%s", s)
+	}
+
+	if p, err = ioutil.ReadFile(hosts); err != nil {
+		t.Fatalf("ReadFile()=%s", err)
+	}
+
+	if !strings.HasPrefix(string(p), "127.0.0.1\tlocalhost\n") {
+		t.Fatalf("hosts file entries were not preserved:\n%s", p)
+	}
+}
@@ -1,8 +1,12 @@
 package dnsclient
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"strings"
+
+	"github.com/dchest/validator"
 )
 
 // Records is a wrapper type for a slice of records that supports filtering.
@@ -94,3 +98,34 @@ func ParseRecord(domain, address string) *Record {
 		TTL:  30,
 	}
 }
+
+// validate checks whether the domain is a valid user domain
+// within the given hosted zone.
+func validate(domain, username, hostedZone string) error {
+	if domain == "" {
+		return errors.New("Domain name argument is empty")
+	}
+
+	if domain == hostedZone {
+		return fmt.Errorf("Domain %q can't be the same as top-level domain %q", domain, hostedZone)
+	}
+
+	if !strings.Contains(domain, hostedZone) {
+		return fmt.Errorf("Domain %q doesn't contain hostedzone %q", domain, hostedZone)
+	}
+
+	rest := strings.TrimSuffix(domain, "."+hostedZone)
+	if rest == domain {
+		return fmt.Errorf("Domain %q is invalid (1)", domain)
+	}
+
+	if split := strings.Split(rest, "."); split[len(split)-1] != username {
+		return fmt.Errorf("Domain %q doesn't contain %q username (hostedZone=%q)", domain, username, hostedZone)
+	}
+
+	if !validator.IsValidDomain(domain) {
+		return fmt.Errorf("Domain %q is invalid (2)", domain)
+	}
+
+	return nil
+}
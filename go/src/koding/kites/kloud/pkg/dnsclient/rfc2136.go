@@ -0,0 +1,802 @@
+package dnsclient
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/koding/logging"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS wire constants, which are not defined by the dnsmessage package.
+const (
+	opcodeUpdate = 5
+	typeTSIG     = 250
+	classNONE    = 254
+	classANY     = 255
+	tsigFudge    = 300
+)
+
+// TSIG algorithm names.
+const (
+	HmacMD5    = "hmac-md5.sig-alg.reg.int."
+	HmacSHA1   = "hmac-sha1."
+	HmacSHA256 = "hmac-sha256."
+	HmacSHA512 = "hmac-sha512."
+)
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	HmacMD5:    md5.New,
+	HmacSHA1:   sha1.New,
+	HmacSHA256: sha256.New,
+	HmacSHA512: sha512.New,
+}
+
+var tsigErrors = map[int]string{
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+}
+
+var (
+	// ErrMismatchedID is returned when the response does not answer
+	// the request sent.
+	ErrMismatchedID = errors.New("DNS response ID does not match the request")
+
+	// ErrBadSignature is returned when TSIG of the response does not
+	// verify with the configured key.
+	ErrBadSignature = errors.New("DNS response has invalid TSIG signature")
+
+	errUnsigned = errors.New("DNS response is not signed")
+)
+
+var rcodes = map[int]string{
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+// RFC2136Options are used to configure the RFC2136 client.
+type RFC2136Options struct {
+	// Server is an address of the primary DNS server for the zone,
+	// e.g. "ns1.example.com:53". The port defaults to 53.
+	Server string
+
+	// HostedZone is the zone, which records are managed.
+	HostedZone string
+
+	// TSIGKey is a name of the key used to sign update requests.
+	// If empty, the requests are not signed.
+	TSIGKey string
+
+	// TSIGSecret is a base64-encoded secret of the TSIGKey.
+	TSIGSecret string
+
+	// TSIGAlgorithm is a TSIG algorithm name, HmacSHA256 by default.
+	TSIGAlgorithm string
+
+	// Timeout is a timeout for a single request, 10s by default.
+	Timeout time.Duration
+
+	Log logging.Logger
+}
+
+func (opts *RFC2136Options) log() logging.Logger {
+	if opts.Log != nil {
+		return opts.Log
+	}
+	return defaultLog
+}
+
+// RFC2136 is a Client implementation, which manages the records
+// with DNS UPDATE messages (RFC 2136), optionally signed with
+// a TSIG key (RFC 2845).
+//
+// The messages are sent over TCP.
+type RFC2136 struct {
+	opts   *RFC2136Options
+	secret []byte
+	alg    func() hash.Hash
+}
+
+var _ Client = (*RFC2136)(nil)
+
+// NewRFC2136 creates new RFC2136 client for the given options.
+func NewRFC2136(opts *RFC2136Options) (*RFC2136, error) {
+	if opts.Server == "" {
+		return nil, errors.New("DNS server address is empty")
+	}
+
+	if opts.HostedZone == "" {
+		return nil, errors.New("hosted zone is empty")
+	}
+
+	optsCopy := *opts
+
+	if _, _, err := net.SplitHostPort(optsCopy.Server); err != nil {
+		optsCopy.Server = net.JoinHostPort(optsCopy.Server, "53")
+	}
+
+	if optsCopy.Timeout == 0 {
+		optsCopy.Timeout = 10 * time.Second
+	}
+
+	if optsCopy.TSIGAlgorithm == "" {
+		optsCopy.TSIGAlgorithm = HmacSHA256
+	}
+
+	// Key and algorithm names are signed in canonical form (RFC 4034, 6.2).
+	optsCopy.TSIGKey = fqdn(optsCopy.TSIGKey)
+	optsCopy.TSIGAlgorithm = fqdn(optsCopy.TSIGAlgorithm)
+
+	optsCopy.Log = opts.log()
+
+	r := &RFC2136{
+		opts: &optsCopy,
+	}
+
+	if opts.TSIGKey != "" {
+		secret, err := base64.StdEncoding.DecodeString(optsCopy.TSIGSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TSIG secret: %s", err)
+		}
+
+		alg, ok := tsigAlgorithms[optsCopy.TSIGAlgorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TSIG algorithm: %q", optsCopy.TSIGAlgorithm)
+		}
+
+		r.secret = secret
+		r.alg = alg
+	}
+
+	return r, nil
+}
+
+// Upsert creates or updates the domain record with the given ip address.
+func (r *RFC2136) Upsert(domain, newIP string) error {
+	return r.UpsertRecord(&Record{
+		Name: domain,
+		Type: "A",
+		IP:   newIP,
+		TTL:  30,
+	})
+}
+
+// UpsertRecord creates or updates a DNS record. Any other records
+// for the name are replaced.
+func (r *RFC2136) UpsertRecord(rec *Record) error {
+	r.opts.Log.Debug("upserting record: %# v", rec)
+
+	u := r.newUpdate()
+	u.deleteName(rec.Name)
+	if err := u.add(rec); err != nil {
+		return err
+	}
+
+	return r.send(u)
+}
+
+// Delete deletes all records for the given domain.
+func (r *RFC2136) Delete(domain string) error {
+	r.opts.Log.Debug("deleting domain: %s", domain)
+
+	u := r.newUpdate()
+	u.deleteName(domain)
+
+	return r.send(u)
+}
+
+// DeleteRecord deletes the given record.
+func (r *RFC2136) DeleteRecord(rec *Record) error {
+	r.opts.Log.Debug("deleting record: %v", rec)
+
+	u := r.newUpdate()
+	if err := u.delete(rec); err != nil {
+		return err
+	}
+
+	return r.send(u)
+}
+
+// Rename changes the domain from oldDomain to newDomain in a single update.
+func (r *RFC2136) Rename(oldDomain, newDomain string) error {
+	rec, err := r.Get(oldDomain)
+	if err != nil {
+		return err
+	}
+
+	r.opts.Log.Debug("updating domain name of IP %s from %q to %q", rec.IP, oldDomain, newDomain)
+
+	recCopy := *rec
+	recCopy.Name = newDomain
+
+	u := r.newUpdate()
+	u.deleteName(oldDomain)
+	u.deleteName(newDomain)
+	if err := u.add(&recCopy); err != nil {
+		return err
+	}
+
+	return r.send(u)
+}
+
+// Get queries the server for an A or CNAME record of the given domain.
+func (r *RFC2136) Get(domain string) (*Record, error) {
+	r.opts.Log.Debug("fetching domain record for domain: %s", domain)
+
+	name, err := dnsmessage.NewName(fqdn(domain))
+	if err != nil {
+		return nil, err
+	}
+
+	id := newID()
+
+	var b dnsmessage.Builder
+	b.Start(nil, dnsmessage.Header{ID: id})
+
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+
+	q := dnsmessage.Question{
+		Name:  name,
+		Type:  dnsmessage.TypeA,
+		Class: dnsmessage.ClassINET,
+	}
+
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.exchange(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.ID != id {
+		return nil, ErrMismatchedID
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.EqualFold(h.Name.String(), fqdn(domain)) {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rec := &Record{
+			Name: h.Name.String(),
+			TTL:  int(h.TTL),
+		}
+
+		switch h.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return nil, err
+			}
+
+			rec.Type = "A"
+			rec.IP = net.IP(a.A[:]).String()
+		case dnsmessage.TypeCNAME:
+			cname, err := p.CNAMEResource()
+			if err != nil {
+				return nil, err
+			}
+
+			rec.Type = "CNAME"
+			rec.IP = cname.CNAME.String()
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return rec, nil
+	}
+
+	return nil, ErrNoRecord
+}
+
+// HostedZone returns the zone the records are managed within.
+func (r *RFC2136) HostedZone() string {
+	return r.opts.HostedZone
+}
+
+// Validate validates if the given domain name is valid.
+func (r *RFC2136) Validate(domain, username string) error {
+	return validate(domain, username, r.opts.HostedZone)
+}
+
+func (r *RFC2136) newUpdate() *update {
+	return &update{
+		id:   newID(),
+		zone: r.opts.HostedZone,
+	}
+}
+
+func (r *RFC2136) send(u *update) error {
+	var (
+		msg    = u.bytes()
+		reqMAC []byte
+	)
+
+	if r.secret != nil {
+		msg, reqMAC = r.sign(msg, time.Now())
+	}
+
+	resp, err := r.exchange(msg)
+	if err != nil {
+		return err
+	}
+
+	if len(resp) < 12 {
+		return errors.New("malformed DNS response")
+	}
+
+	if binary.BigEndian.Uint16(resp) != u.id {
+		return ErrMismatchedID
+	}
+
+	rcode := int(resp[3] & 0x0f)
+
+	// Servers do not sign the error responses to requests, which
+	// they could not verify (RFC 2845, 4.3), thus the rcode of
+	// unsigned responses is reported first.
+	if r.secret != nil {
+		err := r.verify(resp, reqMAC, time.Now())
+		if err != nil && (err != errUnsigned || rcode == 0) {
+			return err
+		}
+	}
+
+	if rcode != 0 {
+		name, ok := rcodes[rcode]
+		if !ok {
+			name = fmt.Sprintf("RCODE%d", rcode)
+		}
+
+		return fmt.Errorf("DNS update for %q zone failed: %s", r.opts.HostedZone, name)
+	}
+
+	return nil
+}
+
+// sign appends TSIG record to the message as defined in RFC 2845,
+// it returns the signed message and its MAC.
+func (r *RFC2136) sign(msg []byte, now time.Time) ([]byte, []byte) {
+	key := packName(nil, r.opts.TSIGKey)
+	alg := packName(nil, r.opts.TSIGAlgorithm)
+	signed := uint64(now.Unix())
+
+	// Time signed is 48-bit unsigned integer.
+	var t [6]byte
+	for i := range t {
+		t[i] = byte(signed >> uint(8*(5-i)))
+	}
+
+	mac := hmac.New(r.alg, r.secret)
+	mac.Write(msg)
+	mac.Write(key)
+	mac.Write([]byte{0, classANY, 0, 0, 0, 0}) // class ANY, TTL 0
+	mac.Write(alg)
+	mac.Write(t[:])
+	mac.Write([]byte{tsigFudge >> 8, tsigFudge & 0xff, 0, 0, 0, 0}) // fudge, error, other len
+	sum := mac.Sum(nil)
+
+	rdata := append([]byte(nil), alg...)
+	rdata = append(rdata, t[:]...)
+	rdata = append(rdata, tsigFudge>>8, tsigFudge&0xff, byte(len(sum)>>8), byte(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = append(rdata, msg[0], msg[1], 0, 0, 0, 0) // original id, error, other len
+
+	msg = appendRR(msg, key, typeTSIG, classANY, 0, rdata)
+
+	arcount := binary.BigEndian.Uint16(msg[10:])
+	binary.BigEndian.PutUint16(msg[10:], arcount+1)
+
+	return msg, sum
+}
+
+// verify checks the TSIG record of the response to a request signed
+// with reqMAC, as defined in RFC 2845, section 4.3.
+func (r *RFC2136) verify(resp, reqMAC []byte, now time.Time) error {
+	unsigned, tsig, err := splitTSIG(resp)
+	if err != nil {
+		return err
+	}
+
+	if tsig == nil {
+		return errUnsigned
+	}
+
+	if tsig.name != r.opts.TSIGKey || tsig.alg != r.opts.TSIGAlgorithm {
+		return fmt.Errorf("response is signed with unexpected key %q (%s)", tsig.name, tsig.alg)
+	}
+
+	if tsig.error != 0 {
+		name, ok := tsigErrors[tsig.error]
+		if !ok {
+			name = fmt.Sprintf("error %d", tsig.error)
+		}
+
+		return fmt.Errorf("DNS server rejected TSIG: %s", name)
+	}
+
+	mac := hmac.New(r.alg, r.secret)
+	mac.Write([]byte{byte(len(reqMAC) >> 8), byte(len(reqMAC))})
+	mac.Write(reqMAC)
+	mac.Write(unsigned)
+	mac.Write(packName(nil, tsig.name))
+	mac.Write([]byte{0, classANY, 0, 0, 0, 0}) // class ANY, TTL 0
+	mac.Write(packName(nil, tsig.alg))
+	mac.Write(tsig.vars)
+
+	if !hmac.Equal(mac.Sum(nil), tsig.mac) {
+		return ErrBadSignature
+	}
+
+	if d := now.Unix() - int64(tsig.signed); d > int64(tsig.fudge) || -d > int64(tsig.fudge) {
+		return fmt.Errorf("response TSIG time is off by %ds", d)
+	}
+
+	return nil
+}
+
+func (r *RFC2136) exchange(msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", r.opts.Server, r.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(r.opts.Timeout))
+
+	p := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(p, uint16(len(msg)))
+	copy(p[2:], msg)
+
+	if _, err := conn.Write(p); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, p[:2]); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, binary.BigEndian.Uint16(p))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// update builds a DNS UPDATE message.
+type update struct {
+	id    uint16
+	zone  string
+	body  []byte
+	count uint16 // number of update RRs
+}
+
+func (u *update) rr(name string, typ, class uint16, ttl uint32, rdata []byte) {
+	u.body = appendRR(u.body, packName(nil, name), typ, class, ttl, rdata)
+	u.count++
+}
+
+// deleteName adds update, which deletes all RRsets of the name.
+func (u *update) deleteName(name string) {
+	u.rr(name, uint16(dnsmessage.TypeALL), classANY, 0, nil)
+}
+
+// add adds update, which adds the record.
+func (u *update) add(rec *Record) error {
+	rec = normalize(rec)
+
+	rdata, err := packRdata(rec)
+	if err != nil {
+		return err
+	}
+
+	u.rr(rec.Name, recordType(rec.Type), uint16(dnsmessage.ClassINET), uint32(rec.TTL), rdata)
+	return nil
+}
+
+// delete adds update, which deletes the record.
+func (u *update) delete(rec *Record) error {
+	rec = normalize(rec)
+
+	rdata, err := packRdata(rec)
+	if err != nil {
+		return err
+	}
+
+	u.rr(rec.Name, recordType(rec.Type), classNONE, 0, rdata)
+	return nil
+}
+
+func (u *update) bytes() []byte {
+	msg := make([]byte, 12, 12+len(u.body))
+
+	binary.BigEndian.PutUint16(msg[0:], u.id)
+	binary.BigEndian.PutUint16(msg[2:], opcodeUpdate<<11)
+	binary.BigEndian.PutUint16(msg[4:], 1)       // ZOCOUNT
+	binary.BigEndian.PutUint16(msg[6:], 0)       // PRCOUNT
+	binary.BigEndian.PutUint16(msg[8:], u.count) // UPCOUNT
+	binary.BigEndian.PutUint16(msg[10:], 0)      // ADCOUNT
+
+	// The zone section has the format of a question.
+	msg = packName(msg, u.zone)
+	msg = append(msg, 0, byte(dnsmessage.TypeSOA), 0, byte(dnsmessage.ClassINET))
+
+	return append(msg, u.body...)
+}
+
+func recordType(typ string) uint16 {
+	switch typ {
+	case "AAAA":
+		return uint16(dnsmessage.TypeAAAA)
+	case "CNAME":
+		return uint16(dnsmessage.TypeCNAME)
+	default:
+		return uint16(dnsmessage.TypeA)
+	}
+}
+
+func packRdata(rec *Record) ([]byte, error) {
+	switch rec.Type {
+	case "A":
+		if ip := net.ParseIP(rec.IP).To4(); ip != nil {
+			return ip, nil
+		}
+	case "AAAA":
+		if ip := net.ParseIP(rec.IP); ip != nil && ip.To4() == nil {
+			return ip.To16(), nil
+		}
+	case "CNAME":
+		return packName(nil, rec.IP), nil
+	default:
+		return nil, fmt.Errorf("unsupported record type: %s", rec.Type)
+	}
+
+	return nil, fmt.Errorf("invalid %s record value: %q", rec.Type, rec.IP)
+}
+
+// packName appends to b the uncompressed wire encoding of the name.
+func packName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(fqdn(name), "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func appendRR(b, name []byte, typ, class uint16, ttl uint32, rdata []byte) []byte {
+	var h [10]byte
+	binary.BigEndian.PutUint16(h[0:], typ)
+	binary.BigEndian.PutUint16(h[2:], class)
+	binary.BigEndian.PutUint32(h[4:], ttl)
+	binary.BigEndian.PutUint16(h[8:], uint16(len(rdata)))
+
+	b = append(b, name...)
+	b = append(b, h[:]...)
+	return append(b, rdata...)
+}
+
+// tsigRR is the TSIG record of a message.
+type tsigRR struct {
+	name   string
+	alg    string
+	signed uint64
+	fudge  uint16
+	mac    []byte
+	error  int
+
+	// vars are TSIG variables following the algorithm name, which
+	// are covered by the MAC: time signed, fudge, error and other data.
+	vars []byte
+}
+
+// splitTSIG returns the message without its TSIG record, with the
+// original ID restored and ARCOUNT decremented, along with the parsed
+// record. The returned record is nil when the message is not signed.
+func splitTSIG(msg []byte) ([]byte, *tsigRR, error) {
+	errMalformed := errors.New("malformed DNS response")
+
+	if len(msg) < 12 {
+		return nil, nil, errMalformed
+	}
+
+	var count int
+	for i := 4; i < 12; i += 2 {
+		count += int(binary.BigEndian.Uint16(msg[i:]))
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	arcount := binary.BigEndian.Uint16(msg[10:])
+
+	if arcount == 0 {
+		return msg, nil, nil
+	}
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		_, n, err := unpackName(msg, off)
+		if err != nil {
+			return nil, nil, err
+		}
+		off = n + 4
+	}
+
+	var start, rdoff, rdlen int
+	var typ uint16
+
+	for i := qdcount; i < count; i++ {
+		start = off
+
+		_, n, err := unpackName(msg, off)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if n+10 > len(msg) {
+			return nil, nil, errMalformed
+		}
+
+		typ = binary.BigEndian.Uint16(msg[n:])
+		rdoff = n + 10
+		rdlen = int(binary.BigEndian.Uint16(msg[n+8:]))
+		off = rdoff + rdlen
+
+		if off > len(msg) {
+			return nil, nil, errMalformed
+		}
+	}
+
+	// TSIG is required to be the last record of the message.
+	if typ != typeTSIG {
+		return msg, nil, nil
+	}
+
+	var (
+		tsig = &tsigRR{}
+		err  error
+		n    int
+	)
+
+	if tsig.name, _, err = unpackName(msg, start); err != nil {
+		return nil, nil, err
+	}
+
+	rdata := msg[:rdoff+rdlen]
+
+	if tsig.alg, n, err = unpackName(rdata, rdoff); err != nil {
+		return nil, nil, err
+	}
+
+	if n+10 > len(rdata) {
+		return nil, nil, errMalformed
+	}
+
+	for _, b := range rdata[n : n+6] {
+		tsig.signed = tsig.signed<<8 | uint64(b)
+	}
+
+	tsig.fudge = binary.BigEndian.Uint16(rdata[n+6:])
+	macSize := int(binary.BigEndian.Uint16(rdata[n+8:]))
+
+	if n+10+macSize+6 > len(rdata) {
+		return nil, nil, errMalformed
+	}
+
+	tsig.mac = rdata[n+10 : n+10+macSize]
+
+	tail := rdata[n+10+macSize:] // original id, error, other len, other data
+	tsig.error = int(binary.BigEndian.Uint16(tail[2:]))
+	tsig.vars = append(append([]byte(nil), rdata[n:n+8]...), tail[2:]...)
+
+	unsigned := append([]byte(nil), msg[:start]...)
+	copy(unsigned, tail[:2])
+	binary.BigEndian.PutUint16(unsigned[10:], arcount-1)
+
+	return unsigned, tsig, nil
+}
+
+// unpackName reads the possibly compressed name at the given offset,
+// it returns the lowercased name and the offset following it.
+func unpackName(msg []byte, off int) (string, int, error) {
+	var (
+		labels []string
+		end    = -1
+	)
+
+	for ptrs := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("malformed DNS name")
+		}
+
+		n := int(msg[off])
+
+		switch {
+		case n == 0:
+			if end == -1 {
+				end = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")) + ".", end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || ptrs > 10 {
+				return "", 0, errors.New("malformed DNS name")
+			}
+
+			if end == -1 {
+				end = off + 2
+			}
+
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			ptrs++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("malformed DNS name")
+			}
+
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+func newID() uint16 {
+	var p [2]byte
+	rand.Read(p[:])
+	return binary.BigEndian.Uint16(p[:])
+}
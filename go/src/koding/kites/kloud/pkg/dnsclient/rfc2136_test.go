@@ -0,0 +1,397 @@
+package dnsclient_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/kites/kloud/pkg/dnsclient"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+// server is a minimal authoritative DNS server stand-in, which
+// supports DNS UPDATE signed with hmac-sha256 TSIG key and
+// A/CNAME queries.
+type server struct {
+	l net.Listener
+
+	mu      sync.Mutex
+	records map[string]*dnsclient.Record // by name
+	updates int
+
+	// respSecret is used for signing responses, secret when nil.
+	respSecret []byte
+
+	// badID makes the server respond with an ID of another request.
+	badID bool
+}
+
+func newServer(t *testing.T) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen()=%s", err)
+	}
+
+	s := &server{
+		l:       l,
+		records: make(map[string]*dnsclient.Record),
+	}
+
+	go s.serve()
+
+	return s
+}
+
+func (s *server) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var n [2]byte
+	if _, err := io.ReadFull(conn, n[:]); err != nil {
+		return
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(n[:]))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return
+	}
+
+	var resp []byte
+	if opcode := (msg[2] >> 3) & 0x0f; opcode == 5 {
+		resp = s.update(msg)
+	} else {
+		resp = s.query(msg)
+	}
+
+	binary.BigEndian.PutUint16(n[:], uint16(len(resp)))
+	conn.Write(append(n[:], resp...))
+}
+
+func (s *server) update(msg []byte) []byte {
+	resp := []byte{msg[0], msg[1], 0x80 | 5<<3, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	if s.badID {
+		resp[0]++
+	}
+
+	mac, err := s.apply(msg)
+	if err != nil {
+		// Unverified requests are responded unsigned.
+		resp[3] = 9 // NOTAUTH
+		return resp
+	}
+
+	return s.sign(resp, mac)
+}
+
+// sign appends TSIG to the response as described in RFC 2845, section 4.2.
+func (s *server) sign(resp, reqMAC []byte) []byte {
+	respSecret := secret
+	if s.respSecret != nil {
+		respSecret = s.respSecret
+	}
+
+	key := []byte("\x03key\x07example\x03com\x00")
+	alg := []byte("\x0bhmac-sha256\x00")
+	vars := []byte{0, 0, 0, 0, 0, 0, 1, 44, 0, 0, 0, 0} // time, fudge, error, other len
+	binary.BigEndian.PutUint32(vars[2:], uint32(time.Now().Unix()))
+
+	h := hmac.New(sha256.New, respSecret)
+	h.Write([]byte{byte(len(reqMAC) >> 8), byte(len(reqMAC))})
+	h.Write(reqMAC)
+	h.Write(resp)
+	h.Write(key)
+	h.Write([]byte{0, 255, 0, 0, 0, 0})
+	h.Write(alg)
+	h.Write(vars)
+	mac := h.Sum(nil)
+
+	rdata := append([]byte(nil), alg...)
+	rdata = append(rdata, vars[:8]...)
+	rdata = append(rdata, 0, byte(len(mac)))
+	rdata = append(rdata, mac...)
+	rdata = append(rdata, resp[0], resp[1], 0, 0, 0, 0)
+
+	var rr [10]byte
+	binary.BigEndian.PutUint16(rr[0:], 250)
+	binary.BigEndian.PutUint16(rr[2:], 255)
+	binary.BigEndian.PutUint16(rr[8:], uint16(len(rdata)))
+
+	resp = append(resp, key...)
+	resp = append(resp, rr[:]...)
+	resp = append(resp, rdata...)
+	binary.BigEndian.PutUint16(resp[10:], 1)
+
+	return resp
+}
+
+func (s *server) apply(msg []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upcount := int(binary.BigEndian.Uint16(msg[8:]))
+	arcount := int(binary.BigEndian.Uint16(msg[10:]))
+
+	if arcount != 1 {
+		return nil, errors.New("missing TSIG")
+	}
+
+	off := 12
+	_, off = readName(msg, off)
+	off += 4 // zone type and class
+
+	type rr struct {
+		name         string
+		typ, class   uint16
+		ttl          uint32
+		rdata        []byte
+		start, rdoff int
+	}
+
+	readRR := func() rr {
+		var r rr
+		r.start = off
+		r.name, off = readName(msg, off)
+		r.typ = binary.BigEndian.Uint16(msg[off:])
+		r.class = binary.BigEndian.Uint16(msg[off+2:])
+		r.ttl = binary.BigEndian.Uint32(msg[off+4:])
+		n := int(binary.BigEndian.Uint16(msg[off+8:]))
+		r.rdoff = off + 10
+		r.rdata = msg[off+10 : off+10+n]
+		off += 10 + n
+		return r
+	}
+
+	var updates []rr
+	for i := 0; i < upcount; i++ {
+		updates = append(updates, readRR())
+	}
+
+	tsig := readRR()
+
+	// Verify TSIG as described in RFC 2845, section 3.4.
+	alg, algEnd := readName(msg, tsig.rdoff)
+	macSize := int(binary.BigEndian.Uint16(msg[algEnd+8:]))
+	mac := msg[algEnd+10 : algEnd+10+macSize]
+
+	unsigned := append([]byte(nil), msg[:tsig.start]...)
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+
+	h := hmac.New(sha256.New, secret)
+	h.Write(unsigned)
+	h.Write(msg[tsig.start : tsig.rdoff-10])                       // key name
+	h.Write([]byte{0, 255, 0, 0, 0, 0})                            // class, TTL
+	h.Write(msg[tsig.rdoff:algEnd])                                // algorithm name
+	h.Write(msg[algEnd : algEnd+8])                                // time signed, fudge
+	h.Write(msg[algEnd+10+macSize+2 : tsig.rdoff+len(tsig.rdata)]) // error, other
+
+	if alg != "hmac-sha256." || tsig.name != "key.example.com." || !hmac.Equal(h.Sum(nil), mac) {
+		return nil, errors.New("bad signature")
+	}
+
+	for _, u := range updates {
+		switch u.class {
+		case 255: // delete RRset
+			delete(s.records, u.name)
+		case 1: // add
+			rec := &dnsclient.Record{
+				Name: u.name,
+				TTL:  int(u.ttl),
+			}
+
+			switch u.typ {
+			case 1:
+				rec.Type = "A"
+				rec.IP = net.IP(u.rdata).String()
+			case 5:
+				rec.Type = "CNAME"
+				rec.IP, _ = readName(u.rdata, 0)
+			}
+
+			s.records[u.name] = rec
+		}
+	}
+
+	s.updates++
+
+	return mac, nil
+}
+
+func (s *server) query(msg []byte) []byte {
+	var p dnsmessage.Parser
+
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil
+	}
+
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	rec, ok := s.records[strings.ToLower(q.Name.String())]
+	s.mu.Unlock()
+
+	var b dnsmessage.Builder
+	b.Start(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true})
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+
+	if ok {
+		rh := dnsmessage.ResourceHeader{
+			Name:  q.Name,
+			Class: dnsmessage.ClassINET,
+			TTL:   uint32(rec.TTL),
+		}
+
+		switch rec.Type {
+		case "A":
+			var a dnsmessage.AResource
+			copy(a.A[:], net.ParseIP(rec.IP).To4())
+			b.AResource(rh, a)
+		case "CNAME":
+			name, _ := dnsmessage.NewName(rec.IP)
+			b.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: name})
+		}
+	}
+
+	resp, _ := b.Finish()
+	return resp
+}
+
+func readName(msg []byte, off int) (string, int) {
+	var labels []string
+	for msg[off] != 0 {
+		n := int(msg[off])
+		labels = append(labels, string(msg[off+1:off+1+n]))
+		off += 1 + n
+	}
+	return strings.Join(labels, ".") + ".", off + 1
+}
+
+func newClient(t *testing.T, s *server, secret []byte) *dnsclient.RFC2136 {
+	c, err := dnsclient.NewRFC2136(&dnsclient.RFC2136Options{
+		Server:     s.l.Addr().String(),
+		HostedZone: "example.com",
+		TSIGKey:    "Key.Example.COM",
+		TSIGSecret: base64.StdEncoding.EncodeToString(secret),
+	})
+	if err != nil {
+		t.Fatalf("NewRFC2136()=%s", err)
+	}
+	return c
+}
+
+func TestRFC2136(t *testing.T) {
+	s := newServer(t)
+	defer s.l.Close()
+
+	c := newClient(t, s, secret)
+
+	if err := c.Upsert("machine.user.example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Upsert()=%s", err)
+	}
+
+	rec, err := c.Get("machine.user.example.com")
+	if err != nil {
+		t.Fatalf("Get()=%s", err)
+	}
+
+	if rec.Type != "A" || rec.IP != "10.0.0.1" || rec.TTL != 30 {
+		t.Fatalf("got %+v, want A 10.0.0.1 record", rec)
+	}
+
+	if err := c.Rename("machine.user.example.com", "vm.user.example.com"); err != nil {
+		t.Fatalf("Rename()=%s", err)
+	}
+
+	if _, err := c.Get("machine.user.example.com"); err != dnsclient.ErrNoRecord {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrNoRecord)
+	}
+
+	cname := &dnsclient.Record{Name: "vm.user.example.com", Type: "CNAME", IP: "host.example.net"}
+
+	if err := c.UpsertRecord(cname); err != nil {
+		t.Fatalf("UpsertRecord()=%s", err)
+	}
+
+	if rec, err = c.Get("vm.user.example.com"); err != nil {
+		t.Fatalf("Get()=%s", err)
+	}
+
+	if rec.Type != "CNAME" || rec.IP != "host.example.net." {
+		t.Fatalf("got %+v, want CNAME host.example.net. record", rec)
+	}
+
+	if err := c.Delete("vm.user.example.com"); err != nil {
+		t.Fatalf("Delete()=%s", err)
+	}
+
+	if _, err := c.Get("vm.user.example.com"); err != dnsclient.ErrNoRecord {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrNoRecord)
+	}
+
+	if s.updates != 4 {
+		t.Fatalf("got %d updates, want 4", s.updates)
+	}
+}
+
+func TestRFC2136BadKey(t *testing.T) {
+	s := newServer(t)
+	defer s.l.Close()
+
+	c := newClient(t, s, []byte("invalid"))
+
+	err := c.Upsert("machine.user.example.com", "10.0.0.1")
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("got %v, want NOTAUTH error", err)
+	}
+
+	if s.updates != 0 {
+		t.Fatalf("got %d updates, want 0", s.updates)
+	}
+}
+
+func TestRFC2136BadResponse(t *testing.T) {
+	s := newServer(t)
+	defer s.l.Close()
+
+	c := newClient(t, s, secret)
+
+	s.badID = true
+
+	if err := c.Upsert("machine.user.example.com", "10.0.0.1"); err != dnsclient.ErrMismatchedID {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrMismatchedID)
+	}
+
+	s.badID = false
+	s.respSecret = []byte("forged")
+
+	if err := c.Upsert("machine.user.example.com", "10.0.0.1"); err != dnsclient.ErrBadSignature {
+		t.Fatalf("got %v, want %v", err, dnsclient.ErrBadSignature)
+	}
+}
@@ -0,0 +1,152 @@
+package dnsclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koding/logging"
+)
+
+// FileOptions are used to configure the File client.
+type FileOptions struct {
+	// Path is a path of the zone file.
+	Path string
+
+	// HostedZone is the origin of the zone file, e.g. "dev.koding.io".
+	HostedZone string
+
+	// Nameserver is a host name used in the SOA and NS records
+	// of the zone. If empty, "ns.<HostedZone>" is used.
+	Nameserver string
+
+	Log logging.Logger
+}
+
+// File is a Client implementation, which keeps the records in
+// a RFC 1035 zone file. The file is meant to be served
+// by a DNS server that reloads zone files on change,
+// like CoreDNS with the file plugin.
+//
+// The zone file is owned by the File client, any changes
+// that were made to the file by other means are
+// going to be lost.
+type File struct {
+	local
+}
+
+// NewFile creates new File client for the given options.
+func NewFile(opts *FileOptions) (*File, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("zone file path is empty")
+	}
+
+	if opts.HostedZone == "" {
+		return nil, fmt.Errorf("hosted zone is empty")
+	}
+
+	f := &File{
+		local: local{
+			zone: opts.HostedZone,
+			log:  defaultLog,
+			c: &zoneFile{
+				path:   opts.Path,
+				origin: fqdn(opts.HostedZone),
+				ns:     opts.Nameserver,
+			},
+		},
+	}
+
+	if opts.Log != nil {
+		f.log = opts.Log
+	}
+
+	return f, nil
+}
+
+type zoneFile struct {
+	path   string
+	origin string
+	ns     string
+}
+
+var _ codec = (*zoneFile)(nil)
+
+func (z *zoneFile) load() (Records, error) {
+	f, err := os.Open(z.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recs Records
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if i := strings.IndexRune(line, ';'); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+
+		// Only records in the format written by save are read,
+		// which is: <name> <ttl> IN <type> <value>.
+		if len(fields) != 5 || fields[2] != "IN" {
+			continue
+		}
+
+		typ := strings.ToUpper(fields[3])
+		if typ != "A" && typ != "AAAA" && typ != "CNAME" {
+			continue
+		}
+
+		ttl, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid TTL for %q: %s", z.path, fields[0], err)
+		}
+
+		recs = append(recs, &Record{
+			Name: fqdn(fields[0]),
+			Type: typ,
+			IP:   fields[4],
+			TTL:  ttl,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
+func (z *zoneFile) save(recs Records) error {
+	ns := z.ns
+	if ns == "" {
+		ns = "ns." + z.origin
+	}
+	ns = fqdn(ns)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "; Zone file managed by kloud, do not edit.\n")
+	fmt.Fprintf(&buf, "$ORIGIN %s\n", z.origin)
+	fmt.Fprintf(&buf, "$TTL 30\n")
+	fmt.Fprintf(&buf, "@ IN SOA %s hostmaster.%s %d 3600 600 86400 30\n", ns, z.origin, time.Now().Unix())
+	fmt.Fprintf(&buf, "@ IN NS %s\n", ns)
+
+	for _, rec := range recs {
+		fmt.Fprintf(&buf, "%s %d IN %s %s\n", rec.Name, rec.TTL, rec.Type, rec.IP)
+	}
+
+	return writeFile(z.path, buf.Bytes())
+}
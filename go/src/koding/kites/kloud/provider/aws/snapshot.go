@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"koding/db/models"
+	"koding/kites/kloud/api/amazon"
+	"koding/kites/kloud/machinestate"
+	"koding/kites/kloud/stack/provider"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
+)
+
+var _ provider.Snapshotter = (*Machine)(nil)
+
+// SnapshotPollInterval is the interval the state of a pending snapshot is
+// checked with.
+var SnapshotPollInterval = 15 * time.Second
+
+// CreateSnapshot creates EBS snapshot of the root volume of the instance.
+// It returns after the snapshot is completed, a snapshot that fails to
+// complete is deleted.
+func (m *Machine) CreateSnapshot(ctx context.Context, label string) (*models.Snapshot, error) {
+	_, vol, err := m.rootVolume()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := m.AWSClient.Client.CreateSnapshot(aws.StringValue(vol.VolumeId), label)
+	if err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(snapshot.SnapshotId)
+
+	if snapshot, err = m.waitSnapshot(ctx, id); err != nil {
+		if e := m.AWSClient.DeleteSnapshot(id); e != nil {
+			m.Log.Warning("failed to delete %q snapshot: %s", id, e)
+		}
+
+		return nil, err
+	}
+
+	return &models.Snapshot{
+		SnapshotId:  id,
+		StorageSize: strconv.FormatInt(aws.Int64Value(snapshot.VolumeSize), 10),
+		Region:      m.AWSClient.Region,
+	}, nil
+}
+
+// waitSnapshot polls the state of the snapshot until it is completed.
+func (m *Machine) waitSnapshot(ctx context.Context, id string) (*ec2.Snapshot, error) {
+	t := time.NewTicker(SnapshotPollInterval)
+	defer t.Stop()
+
+	for {
+		s, err := m.AWSClient.Client.SnapshotByID(id)
+		if err != nil && !amazon.IsNotFound(err) {
+			return nil, err
+		}
+
+		if s != nil {
+			switch aws.StringValue(s.State) {
+			case ec2.SnapshotStateCompleted:
+				return s, nil
+			case ec2.SnapshotStateError:
+				return nil, fmt.Errorf("snapshot %q failed: %s", id, aws.StringValue(s.StateMessage))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// DeleteSnapshot deletes the EBS snapshot.
+func (m *Machine) DeleteSnapshot(_ context.Context, s *models.Snapshot) error {
+	return m.AWSClient.DeleteSnapshot(s.SnapshotId)
+}
+
+// RestoreSnapshot replaces the root volume of the instance with a new volume
+// created from the EBS snapshot. The old volume is deleted only after the
+// instance has started from the new one, if it fails to start the old volume
+// is attached back.
+func (m *Machine) RestoreSnapshot(ctx context.Context, s *models.Snapshot) (interface{}, error) {
+	instance, vol, err := m.rootVolume()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		instanceID = aws.StringValue(instance.InstanceId)
+		device     = aws.StringValue(instance.RootDeviceName)
+		oldID      = aws.StringValue(vol.VolumeId)
+		size       = int(aws.Int64Value(vol.Size))
+		running    = m.State() == machinestate.Running
+	)
+
+	if running {
+		if err := m.AWSClient.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	newVol, err := m.AWSClient.CreateVolume(s.SnapshotId, aws.StringValue(vol.AvailabilityZone), aws.StringValue(vol.VolumeType), size)
+	if err != nil {
+		return nil, err
+	}
+
+	newID := aws.StringValue(newVol.VolumeId)
+
+	if err := m.AWSClient.DetachVolume(oldID); err != nil {
+		m.deleteVolume(newID)
+		return nil, err
+	}
+
+	if err := m.AWSClient.AttachVolume(newID, instanceID, device); err != nil {
+		m.reattachVolume(ctx, oldID, newID, instanceID, device, running)
+		return nil, err
+	}
+
+	if _, err := m.AWSClient.Start(ctx); err != nil {
+		if e := m.AWSClient.Stop(ctx); e != nil {
+			m.Log.Error("failed to stop %q: %s", instanceID, e)
+		}
+
+		if e := m.AWSClient.DetachVolume(newID); e != nil {
+			m.Log.Error("failed to detach %q volume from %q: %s", newID, instanceID, e)
+			return nil, err
+		}
+
+		m.reattachVolume(ctx, oldID, newID, instanceID, device, running)
+		return nil, err
+	}
+
+	m.deleteVolume(oldID)
+
+	if !running {
+		if err := m.AWSClient.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// reattachVolume tries to bring the machine back to the state it was in
+// before the restore, by attaching the old root volume back and deleting the
+// new one.
+func (m *Machine) reattachVolume(ctx context.Context, oldID, newID, instanceID, device string, running bool) {
+	if err := m.AWSClient.AttachVolume(oldID, instanceID, device); err != nil {
+		m.Log.Error("failed to reattach %q volume to %q: %s", oldID, instanceID, err)
+		return
+	}
+
+	m.deleteVolume(newID)
+
+	if running {
+		if _, err := m.AWSClient.Start(ctx); err != nil {
+			m.Log.Error("failed to start %q: %s", instanceID, err)
+		}
+	}
+}
+
+func (m *Machine) deleteVolume(id string) {
+	if err := m.AWSClient.Client.DeleteVolume(id); err != nil {
+		m.Log.Warning("failed to delete %q volume: %s", id, err)
+	}
+}
+
+// rootVolume returns the instance and its root EBS volume.
+func (m *Machine) rootVolume() (*ec2.Instance, *ec2.Volume, error) {
+	instance, err := m.AWSClient.Instance()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := aws.StringValue(instance.RootDeviceName)
+
+	for _, dev := range instance.BlockDeviceMappings {
+		if aws.StringValue(dev.DeviceName) != root || dev.Ebs == nil {
+			continue
+		}
+
+		vol, err := m.AWSClient.ExistingVolume(aws.StringValue(dev.Ebs.VolumeId))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return instance, vol, nil
+	}
+
+	return nil, nil, errors.New("instance has no EBS root volume")
+}
@@ -0,0 +1,143 @@
+package do
+
+import (
+	"fmt"
+	"strconv"
+
+	"koding/db/models"
+	"koding/kites/kloud/machinestate"
+	"koding/kites/kloud/stack/provider"
+
+	"github.com/digitalocean/godo"
+	"golang.org/x/net/context"
+)
+
+var _ provider.Snapshotter = (*Machine)(nil)
+
+// CreateSnapshot creates a snapshot image of the droplet. The droplet
+// is powered off for the time of the snapshot.
+func (m *Machine) CreateSnapshot(ctx context.Context, label string) (*models.Snapshot, error) {
+	dropletID, err := m.DropletID()
+	if err != nil {
+		return nil, err
+	}
+
+	if dropletID == 0 {
+		return nil, ErrInvalidDropletID
+	}
+
+	if m.State() == machinestate.Running {
+		if _, err := m.Stop(ctx); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			if _, err := m.Start(ctx); err != nil {
+				m.Log.Error("failed to start %d droplet: %s", dropletID, err)
+			}
+		}()
+	}
+
+	action, _, err := m.client.DropletActions.Snapshot(dropletID, label)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitForAction(ctx, m.client, action); err != nil {
+		return nil, err
+	}
+
+	image, err := m.snapshotImage(dropletID, label)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &models.Snapshot{
+		SnapshotId:  strconv.Itoa(image.ID),
+		StorageSize: strconv.Itoa(image.MinDiskSize),
+	}
+
+	if len(image.Regions) != 0 {
+		s.Region = image.Regions[0]
+	}
+
+	return s, nil
+}
+
+// DeleteSnapshot deletes the snapshot image.
+func (m *Machine) DeleteSnapshot(_ context.Context, s *models.Snapshot) error {
+	imageID, err := strconv.Atoi(s.SnapshotId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.client.Images.Delete(imageID)
+	return err
+}
+
+// RestoreSnapshot rebuilds the droplet from the snapshot image.
+func (m *Machine) RestoreSnapshot(ctx context.Context, s *models.Snapshot) (interface{}, error) {
+	dropletID, err := m.DropletID()
+	if err != nil {
+		return nil, err
+	}
+
+	if dropletID == 0 {
+		return nil, ErrInvalidDropletID
+	}
+
+	imageID, err := strconv.Atoi(s.SnapshotId)
+	if err != nil {
+		return nil, err
+	}
+
+	action, _, err := m.client.DropletActions.Restore(dropletID, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitForAction(ctx, m.client, action); err != nil {
+		return nil, err
+	}
+
+	// Restoring powers the droplet on, bring it back to the stopped state.
+	if m.State() != machinestate.Running {
+		if _, err := m.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// snapshotImage looks up snapshot image of the droplet by its name.
+func (m *Machine) snapshotImage(dropletID int, name string) (*godo.Image, error) {
+	opt := &godo.ListOptions{PerPage: 200}
+
+	for {
+		images, resp, err := m.client.Droplets.Snapshots(dropletID, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		// Look up the most recent one, as the names are not unique.
+		for i := len(images) - 1; i >= 0; i-- {
+			if images[i].Name == name {
+				return &images[i], nil
+			}
+		}
+
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			break
+		}
+
+		page, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+
+		opt.Page = page + 1
+	}
+
+	return nil, fmt.Errorf("snapshot %q of droplet %d not found", name, dropletID)
+}
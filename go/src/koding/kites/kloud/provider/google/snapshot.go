@@ -0,0 +1,261 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"koding/db/models"
+	"koding/kites/kloud/machinestate"
+	"koding/kites/kloud/stack/provider"
+
+	"golang.org/x/net/context"
+	compute "google.golang.org/api/compute/v1"
+)
+
+var _ provider.Snapshotter = (*Machine)(nil)
+
+// CreateSnapshot creates a snapshot of the boot disk of the instance.
+//
+// The snapshot name is generated from the instance name, as labels
+// are not valid Google resource names; the label is kept as the
+// snapshot's description instead.
+func (m *Machine) CreateSnapshot(ctx context.Context, label string) (*models.Snapshot, error) {
+	svc, err := m.Cred().ComputeService()
+	if err != nil {
+		return nil, err
+	}
+
+	project, zone, _ := m.Location()
+
+	disk, err := m.bootDisk(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &compute.Snapshot{
+		Name:        uniqueName(disk.Name),
+		Description: label,
+	}
+
+	op, err := svc.Disks.CreateSnapshot(project, zone, disk.Name, s).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitOperation(ctx, svc, project, zone, op); err != nil {
+		return nil, err
+	}
+
+	s, err = svc.Snapshots.Get(project, s.Name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Snapshot{
+		SnapshotId:  s.Name,
+		StorageSize: strconv.FormatInt(s.DiskSizeGb, 10),
+		Region:      zone,
+	}, nil
+}
+
+// DeleteSnapshot deletes the disk snapshot.
+func (m *Machine) DeleteSnapshot(ctx context.Context, s *models.Snapshot) error {
+	svc, err := m.Cred().ComputeService()
+	if err != nil {
+		return err
+	}
+
+	project, _, _ := m.Location()
+
+	op, err := svc.Snapshots.Delete(project, s.SnapshotId).Do()
+	if isNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return waitOperation(ctx, svc, project, "", op)
+}
+
+// RestoreSnapshot replaces the boot disk of the instance with a new disk
+// created from the snapshot. The old disk is deleted afterwards.
+func (m *Machine) RestoreSnapshot(ctx context.Context, s *models.Snapshot) (interface{}, error) {
+	svc, err := m.Cred().ComputeService()
+	if err != nil {
+		return nil, err
+	}
+
+	project, zone, name := m.Location()
+
+	instance, err := svc.Instances.Get(project, zone, name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	attached, err := bootAttachedDisk(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDisk, err := svc.Disks.Get(project, zone, path.Base(attached.Source)).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.State() == machinestate.Running {
+		if _, err := m.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	newDisk := &compute.Disk{
+		Name:           uniqueName(name),
+		SizeGb:         oldDisk.SizeGb,
+		Type:           oldDisk.Type,
+		SourceSnapshot: "global/snapshots/" + s.SnapshotId,
+	}
+
+	op, err := svc.Disks.Insert(project, zone, newDisk).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitOperation(ctx, svc, project, zone, op); err != nil {
+		return nil, err
+	}
+
+	deleteNew := func() {
+		if _, err := svc.Disks.Delete(project, zone, newDisk.Name).Do(); err != nil {
+			m.Log.Warning("failed to delete %q disk: %s", newDisk.Name, err)
+		}
+	}
+
+	op, err = svc.Instances.DetachDisk(project, zone, name, attached.DeviceName).Do()
+	if err == nil {
+		err = waitOperation(ctx, svc, project, zone, op)
+	}
+
+	if err != nil {
+		deleteNew()
+		return nil, err
+	}
+
+	if err := m.attachBootDisk(ctx, svc, attached.DeviceName, diskURL(project, zone, newDisk.Name)); err != nil {
+		// try to bring the machine back to the previous state
+		if e := m.attachBootDisk(ctx, svc, attached.DeviceName, attached.Source); e != nil {
+			m.Log.Error("failed to reattach %q disk to %q: %s", oldDisk.Name, name, e)
+		}
+
+		deleteNew()
+		return nil, err
+	}
+
+	if _, err := svc.Disks.Delete(project, zone, oldDisk.Name).Do(); err != nil {
+		m.Log.Warning("failed to delete %q disk: %s", oldDisk.Name, err)
+	}
+
+	if m.State() == machinestate.Running {
+		if _, err := m.Start(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *Machine) bootDisk(svc *compute.Service) (*compute.Disk, error) {
+	project, zone, name := m.Location()
+
+	instance, err := svc.Instances.Get(project, zone, name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	attached, err := bootAttachedDisk(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.Disks.Get(project, zone, path.Base(attached.Source)).Do()
+}
+
+func (m *Machine) attachBootDisk(ctx context.Context, svc *compute.Service, device, source string) error {
+	project, zone, name := m.Location()
+
+	disk := &compute.AttachedDisk{
+		Boot:       true,
+		AutoDelete: true,
+		DeviceName: device,
+		Source:     source,
+	}
+
+	op, err := svc.Instances.AttachDisk(project, zone, name, disk).Do()
+	if err != nil {
+		return err
+	}
+
+	return waitOperation(ctx, svc, project, zone, op)
+}
+
+func bootAttachedDisk(instance *compute.Instance) (*compute.AttachedDisk, error) {
+	for _, disk := range instance.Disks {
+		if disk.Boot {
+			return disk, nil
+		}
+	}
+
+	return nil, errors.New("instance has no boot disk")
+}
+
+func diskURL(project, zone, disk string) string {
+	return fmt.Sprintf("projects/%s/zones/%s/disks/%s", project, zone, disk)
+}
+
+// uniqueName gives a valid Google resource name, which is unique
+// for the given prefix.
+func uniqueName(prefix string) string {
+	suffix := "-" + strconv.FormatInt(time.Now().Unix(), 10)
+
+	// Google resource names are limited to 63 characters.
+	if len(prefix)+len(suffix) > 63 {
+		prefix = prefix[:63-len(suffix)]
+	}
+
+	return prefix + suffix
+}
+
+// waitOperation waits until the given operation is done. The operation
+// is zonal when zone is non-empty, global otherwise.
+func waitOperation(ctx context.Context, svc *compute.Service, project, zone string, op *compute.Operation) (err error) {
+	t := time.NewTicker(2 * time.Second)
+	defer t.Stop()
+
+	for op.Status != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		if zone != "" {
+			op, err = svc.ZoneOperations.Get(project, zone, op.Name).Do()
+		} else {
+			op, err = svc.GlobalOperations.Get(project, op.Name).Do()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if op.Error != nil && len(op.Error.Errors) != 0 {
+		return errors.New(op.Error.Errors[0].Message)
+	}
+
+	return nil
+}
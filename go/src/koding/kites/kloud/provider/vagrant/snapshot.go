@@ -0,0 +1,55 @@
+package vagrant
+
+import (
+	"koding/db/models"
+	"koding/kites/kloud/machinestate"
+	"koding/kites/kloud/stack/provider"
+
+	"golang.org/x/net/context"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var _ provider.Snapshotter = (*Machine)(nil)
+
+// CreateSnapshot exports the vagrant box with "vagrant package".
+// The box is stored on the host machine by klient.
+//
+// Packaging halts the box, it is started again if it was running.
+func (m *Machine) CreateSnapshot(ctx context.Context, _ string) (*models.Snapshot, error) {
+	name := bson.NewObjectId().Hex()
+
+	if err := m.api.Package(m.Cred().QueryString, m.Meta().FilePath, name); err != nil {
+		return nil, m.wrap(err)
+	}
+
+	if m.State() == machinestate.Running {
+		if _, err := m.Start(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.Snapshot{
+		SnapshotId: name,
+	}, nil
+}
+
+// DeleteSnapshot removes the exported box from the host machine.
+func (m *Machine) DeleteSnapshot(_ context.Context, s *models.Snapshot) error {
+	return m.wrap(m.api.RemoveSnapshot(m.Cred().QueryString, m.Meta().FilePath, s.SnapshotId))
+}
+
+// RestoreSnapshot recreates the vagrant box from the exported box.
+func (m *Machine) RestoreSnapshot(ctx context.Context, s *models.Snapshot) (interface{}, error) {
+	if err := m.api.Restore(m.Cred().QueryString, m.Meta().FilePath, s.SnapshotId); err != nil {
+		return nil, m.wrap(err)
+	}
+
+	// Restoring brings the box up, halt it if it was not running.
+	if m.State() != machinestate.Running {
+		if _, err := m.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
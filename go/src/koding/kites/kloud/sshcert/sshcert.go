@@ -0,0 +1,172 @@
+// Package sshcert implements team certificate authorities that issue
+// short-lived SSH user certificates.
+package sshcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultTTL is the default validity period of issued certificates.
+const DefaultTTL = 30 * time.Minute
+
+// clockSkew is subtracted from certificate's start time in order to tolerate
+// clock differences between kloud and remote machines.
+const clockSkew = 5 * time.Minute
+
+// ExtForwardAgent is a certificate extension that allows SSH agent
+// forwarding.
+const ExtForwardAgent = "permit-agent-forwarding"
+
+// defaultExtensions are the permissions granted to every issued certificate.
+var defaultExtensions = map[string]string{
+	"permit-pty":             "",
+	"permit-port-forwarding": "",
+	"permit-user-rc":         "",
+	"permit-X11-forwarding":  "",
+}
+
+// CA is an SSH certificate authority.
+type CA struct {
+	signer ssh.Signer
+	pem    []byte
+}
+
+// NewCA creates a new certificate authority from PEM encoded private key.
+func NewCA(privPEM []byte) (*CA, error) {
+	signer, err := ssh.ParsePrivateKey(privPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{
+		signer: signer,
+		pem:    privPEM,
+	}, nil
+}
+
+// GenerateCA creates a certificate authority with a new private key.
+func GenerateCA() (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCA(pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: der,
+	}))
+}
+
+// PEM returns PEM encoded private key of the certificate authority.
+func (ca *CA) PEM() []byte {
+	return ca.pem
+}
+
+// PublicKey returns certificate authority public key in authorized_keys
+// format.
+func (ca *CA) PublicKey() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.signer.PublicKey())))
+}
+
+// Principal gives the certificate principal of the user for the given
+// machine. Principals are scoped to machines, so a certificate issued for
+// one machine of a team is not accepted by other machines of the team,
+// and the access is revoked by removing the principal from the machine.
+func Principal(username, machineID string) string {
+	return username + "@" + machineID
+}
+
+// SignRequest describes the certificate to issue.
+type SignRequest struct {
+	// PublicKey is the user public key in authorized_keys format.
+	PublicKey string
+
+	// KeyID identifies the certificate in remote sshd logs.
+	KeyID string
+
+	// Principals are the names the certificate is valid for.
+	Principals []string
+
+	// TTL defines how long the certificate is valid. If zero, DefaultTTL
+	// is used.
+	TTL time.Duration
+
+	// ForwardAgent allows SSH agent forwarding with the certificate.
+	ForwardAgent bool
+}
+
+// Sign issues a new user certificate described by the request.
+func (ca *CA) Sign(req *SignRequest) (*ssh.Certificate, error) {
+	if len(req.Principals) == 0 {
+		return nil, errors.New("no certificate principals provided")
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %s", err)
+	}
+
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return nil, errors.New("public key must not be a certificate")
+	}
+
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           req.KeyID,
+		ValidPrincipals: req.Principals,
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: make(map[string]string, len(defaultExtensions)+1),
+		},
+	}
+
+	for ext, val := range defaultExtensions {
+		cert.Extensions[ext] = val
+	}
+
+	if req.ForwardAgent {
+		cert.Extensions[ExtForwardAgent] = ""
+	}
+
+	if err := cert.SignCert(rand.Reader, ca.signer); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// Marshal encodes the certificate in the format used by OpenSSH
+// *-cert.pub files.
+func Marshal(cert *ssh.Certificate) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert)))
+}
@@ -0,0 +1,128 @@
+package sshcert_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"koding/kites/kloud/sshcert"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSign(t *testing.T) {
+	ca, err := sshcert.GenerateCA()
+	if err != nil {
+		t.Fatalf("GenerateCA()=%s", err)
+	}
+
+	// Authority must be restorable from its private key.
+	restored, err := sshcert.NewCA(ca.PEM())
+	if err != nil {
+		t.Fatalf("NewCA()=%s", err)
+	}
+
+	if restored.PublicKey() != ca.PublicKey() {
+		t.Fatalf("got %q, want %q", restored.PublicKey(), ca.PublicKey())
+	}
+
+	caPub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca.PublicKey()))
+	if err != nil {
+		t.Fatalf("ParseAuthorizedKey()=%s", err)
+	}
+
+	userKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey()=%s", err)
+	}
+
+	userPub, err := ssh.NewPublicKey(&userKey.PublicKey)
+	if err != nil {
+		t.Fatalf("NewPublicKey()=%s", err)
+	}
+
+	cases := map[string]struct {
+		forwardAgent bool
+		principal    string
+		ok           bool
+	}{
+		"agent forwarding":    {true, "user", true},
+		"no agent forwarding": {false, "user", true},
+		"other principal":     {false, "root", false},
+	}
+
+	for name, cas := range cases {
+		t.Run(name, func(t *testing.T) {
+			cert, err := restored.Sign(&sshcert.SignRequest{
+				PublicKey:    string(ssh.MarshalAuthorizedKey(userPub)),
+				KeyID:        "user@koding",
+				Principals:   []string{"user"},
+				TTL:          time.Minute,
+				ForwardAgent: cas.forwardAgent,
+			})
+			if err != nil {
+				t.Fatalf("Sign()=%s", err)
+			}
+
+			if _, err := ssh.ParsePublicKey(cert.Marshal()); err != nil {
+				t.Fatalf("ParsePublicKey()=%s", err)
+			}
+
+			checker := &ssh.CertChecker{
+				IsUserAuthority: func(auth ssh.PublicKey) bool {
+					return string(auth.Marshal()) == string(caPub.Marshal())
+				},
+			}
+
+			_, err = checker.Authenticate(connMeta(cas.principal), cert)
+			if cas.ok && err != nil {
+				t.Fatalf("Authenticate()=%s", err)
+			}
+			if !cas.ok {
+				if err == nil {
+					t.Fatal("want Authenticate() to fail")
+				}
+				return
+			}
+
+			if _, ok := cert.Extensions[sshcert.ExtForwardAgent]; ok != cas.forwardAgent {
+				t.Fatalf("got %t, want %t", ok, cas.forwardAgent)
+			}
+
+			if max := uint64(time.Now().Add(time.Minute).Unix()); cert.ValidBefore > max {
+				t.Fatalf("got %d, want <= %d", cert.ValidBefore, max)
+			}
+		})
+	}
+}
+
+func TestSignInvalid(t *testing.T) {
+	ca, err := sshcert.GenerateCA()
+	if err != nil {
+		t.Fatalf("GenerateCA()=%s", err)
+	}
+
+	reqs := map[string]*sshcert.SignRequest{
+		"no principals": {PublicKey: ca.PublicKey()},
+		"invalid key":   {PublicKey: "ssh-rsa invalid", Principals: []string{"user"}},
+	}
+
+	for name, req := range reqs {
+		if _, err := ca.Sign(req); err == nil {
+			t.Errorf("%s: want Sign() to fail", name)
+		}
+	}
+}
+
+type connMeta string
+
+func (c connMeta) User() string { return string(c) }
+
+func (connMeta) SessionID() []byte     { return nil }
+func (connMeta) ClientVersion() []byte { return nil }
+func (connMeta) ServerVersion() []byte { return nil }
+func (connMeta) RemoteAddr() net.Addr  { return nil }
+func (connMeta) LocalAddr() net.Addr   { return nil }
@@ -0,0 +1,142 @@
+package sshcert
+
+import (
+	"errors"
+	"sync"
+
+	"koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"koding/kites/kloud/credential"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Store provides certificate authorities of teams.
+type Store interface {
+	// CA gets certificate authority of a given team. If the team has no
+	// authority yet, a new one is created.
+	CA(team string) (*CA, error)
+}
+
+// MongoStore keeps team certificate authorities in jSSHAuthorities
+// collection, which is accessed by kloud only. Private keys of the
+// authorities are kept in the credential store.
+type MongoStore struct {
+	// Creds stores private keys of the authorities. It is expected
+	// to encrypt them, like the MongoDB store configured with KMS does.
+	Creds credential.Store
+
+	mu  sync.Mutex
+	cas map[string]*CA
+}
+
+var _ Store = (*MongoStore)(nil)
+
+// caData is a credential data value, which holds private key of
+// a certificate authority.
+type caData struct {
+	PrivateKey string `json:"privateKey" bson:"privateKey"`
+}
+
+// Valid implements the validator interface of credential store.
+func (d *caData) Valid() error {
+	if d.PrivateKey == "" {
+		return errors.New("private key is empty")
+	}
+	return nil
+}
+
+// CA implements the Store interface.
+func (ms *MongoStore) CA(team string) (*CA, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ca, ok := ms.cas[team]; ok {
+		return ca, nil
+	}
+
+	sa, err := modelhelper.GetSSHAuthority(team)
+	if err == mgo.ErrNotFound {
+		sa, err = ms.create(team)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := &caData{}
+	if err := ms.Creds.Fetch(team, map[string]interface{}{sa.Identifier: data}); err != nil {
+		return nil, err
+	}
+
+	ca, err := NewCA([]byte(data.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if ms.cas == nil {
+		ms.cas = make(map[string]*CA)
+	}
+	ms.cas[team] = ca
+
+	return ca, nil
+}
+
+// create generates a new authority for the team. The private key is
+// stored before the authority, so other klouds never see an authority
+// without the key.
+func (ms *MongoStore) create(team string) (*models.SSHAuthority, error) {
+	ca, err := GenerateCA()
+	if err != nil {
+		return nil, err
+	}
+
+	ident := bson.NewObjectId().Hex()
+
+	if err := modelhelper.CreateCredentialData(ident); err != nil {
+		return nil, err
+	}
+
+	data := &caData{
+		PrivateKey: string(ca.PEM()),
+	}
+
+	if err := ms.Creds.Put(team, map[string]interface{}{ident: data}); err != nil {
+		return nil, err
+	}
+
+	// Other kloud may have created the authority in the meantime,
+	// in which case the stored one is returned and used.
+	return modelhelper.CreateSSHAuthority(team, ident)
+}
+
+// MemoryStore keeps team certificate authorities in memory. It is meant to
+// be used in tests.
+type MemoryStore struct {
+	mu  sync.Mutex
+	cas map[string]*CA
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// CA implements the Store interface.
+func (ms *MemoryStore) CA(team string) (*CA, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ca, ok := ms.cas[team]; ok {
+		return ca, nil
+	}
+
+	ca, err := GenerateCA()
+	if err != nil {
+		return nil, err
+	}
+
+	if ms.cas == nil {
+		ms.cas = make(map[string]*CA)
+	}
+	ms.cas[team] = ca
+
+	return ca, nil
+}
@@ -44,16 +44,19 @@ type statePair struct {
 	final machinestate.State
 }
 
+// states maps methods to their state pairs, methods with unknown final state
+// leave the machine in the state it was before the call.
 var states = map[string]*statePair{
-	"build":          {start: machinestate.Building, final: machinestate.Running},
-	"reinit":         {start: machinestate.Building, final: machinestate.Running},
-	"start":          {start: machinestate.Starting, final: machinestate.Running},
-	"stop":           {start: machinestate.Stopping, final: machinestate.Stopped},
-	"destroy":        {start: machinestate.Terminating, final: machinestate.Terminated},
-	"restart":        {start: machinestate.Rebooting, final: machinestate.Running},
-	"resize":         {start: machinestate.Pending, final: machinestate.Running},
-	"createSnapshot": {start: machinestate.Snapshotting, final: machinestate.Running},
-	"deleteSnapshot": {start: machinestate.Snapshotting, final: machinestate.Running},
+	"build":                    {start: machinestate.Building, final: machinestate.Running},
+	"reinit":                   {start: machinestate.Building, final: machinestate.Running},
+	"start":                    {start: machinestate.Starting, final: machinestate.Running},
+	"stop":                     {start: machinestate.Stopping, final: machinestate.Stopped},
+	"destroy":                  {start: machinestate.Terminating, final: machinestate.Terminated},
+	"restart":                  {start: machinestate.Rebooting, final: machinestate.Running},
+	"resize":                   {start: machinestate.Pending, final: machinestate.Running},
+	"machine.snapshot.create":  {start: machinestate.Snapshotting, final: machinestate.Unknown},
+	"machine.snapshot.delete":  {start: machinestate.Snapshotting, final: machinestate.Unknown},
+	"machine.snapshot.restore": {start: machinestate.Snapshotting, final: machinestate.Unknown},
 }
 
 // coreMethods is running and returning the response for the given machineFunc.
@@ -194,6 +197,10 @@ func (k *Kloud) coreMethods(r *kite.Request, fn machineFunc) (result interface{}
 			Percentage: 100,
 		}
 
+		if finalEvent.Status == machinestate.Unknown {
+			finalEvent.Status = m.State()
+		}
+
 		k.Log.Info("[%s] ======> %s started (requester: %s, provider: %s)<======",
 			args.MachineId, strings.ToUpper(r.Method), r.Username, args.Provider)
 		start := time.Now()
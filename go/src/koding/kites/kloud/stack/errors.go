@@ -49,6 +49,9 @@ const (
 	ErrMachineIsLocked           = 107
 	ErrSnapshotIdMissing         = 108
 	ErrTerraformContextIsMissing = 109
+	ErrSnapshotNotSupported      = 110
+	ErrSnapshotLimitReached      = 111
+	ErrSnapshotNotFound          = 112
 
 	ErrEventNotFound    = 200
 	ErrEventIdMissing   = 201
@@ -91,6 +94,9 @@ var kloudErrors = map[int]string{
 	ErrMachineIsLocked:           "Machine is locked by someone else",
 	ErrSnapshotIdMissing:         "Snapshot id is missing.",
 	ErrTerraformContextIsMissing: "Terraform context file is missing.",
+	ErrSnapshotNotSupported:      "Provider doesn't support snapshots.",
+	ErrSnapshotLimitReached:      "Snapshot limit of the team is reached.",
+	ErrSnapshotNotFound:          "Snapshot is not found.",
 
 	// Event errors
 	ErrEventIdMissing:   "Event id is missing.",
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"koding/api"
+	"koding/kites/tracing"
 	"koding/remoteapi"
 	"koding/remoteapi/client"
 	stacktemplate "koding/remoteapi/client/j_stack_template"
@@ -26,6 +27,9 @@ type ImportRequest struct {
 	Provider    string              `json:"provider"`
 	Team        string              `json:"team"`
 	Title       string              `json:"title,omitempty"`
+
+	// Trace, when non-nil, continues a trace started by the caller.
+	Trace *tracing.SpanContext `json:"trace,omitempty"`
 }
 
 // Valid implements the Validator interface.
@@ -74,7 +78,7 @@ var requiredData = map[string]interface{}{
 //
 // The method expects the received credentials to be already verified
 // and bootstrapped.
-func (k *Kloud) Import(r *kite.Request) (interface{}, error) {
+func (k *Kloud) Import(r *kite.Request) (_ interface{}, err error) {
 	var req ImportRequest
 
 	if err := r.Args.One().Unmarshal(&req); err != nil {
@@ -85,6 +89,14 @@ func (k *Kloud) Import(r *kite.Request) (interface{}, error) {
 		return nil, err
 	}
 
+	span, ctx := tracing.StartSpan(tracing.WithRemote(context.Background(), req.Trace), "kloud.import")
+	span.SetTag("user", r.Username)
+	span.SetTag("team", req.Team)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
 	// TODO(rjeczalik): Refactor stack/provider/apply to make it possible to build
 	// multiple stacks at once.
 	if req.Provider == "" {
@@ -114,6 +126,7 @@ func (k *Kloud) Import(r *kite.Request) (interface{}, error) {
 		Provider:   req.Provider,
 		GroupName:  req.Team,
 		Identifier: req.Credentials[req.Provider][0],
+		Trace:      tracing.FromContext(ctx),
 	}
 
 	sb := &stackBuilder{
@@ -19,8 +19,10 @@ import (
 	"koding/kites/kloud/machine"
 	"koding/kites/kloud/pkg/dnsclient"
 	"koding/kites/kloud/pkg/idlock"
+	"koding/kites/kloud/sshcert"
 	"koding/kites/kloud/team"
 	"koding/kites/kloud/userdata"
+	"koding/kites/tracing"
 	"koding/remoteapi"
 
 	dogstatsd "github.com/DataDog/datadog-go/statsd"
@@ -109,8 +111,24 @@ type Kloud struct {
 	// RemoteClient handles requests to "remote.api" endpoint.
 	RemoteClient *remoteapi.Client
 
+	// SSHCA provides team certificate authorities used by ssh.* methods.
+	//
+	// If nil, SSH certificates are not issued.
+	SSHCA sshcert.Store
+
+	// SSHCertTTL is the validity period of issued SSH certificates.
+	//
+	// If zero, sshcert.DefaultTTL is used.
+	SSHCertTTL time.Duration
+
 	Metrics *dogstatsd.Client
 
+	// SnapshotLimit is the default maximum number of snapshots a team can
+	// have, teams can overwrite it with snapshots.limit group data.
+	//
+	// If zero, the number of snapshots is not limited.
+	SnapshotLimit int
+
 	// Enable debug mode
 	Debug bool
 
@@ -149,6 +167,13 @@ func (k *Kloud) AddProvider(providerName string, provider Provider) error {
 
 func (k *Kloud) setTraceID(user, method string, ctx context.Context) context.Context {
 	traceID := uuid.NewV4().String()
+
+	// Reuse trace ID of a span, so logs can be correlated
+	// with exported spans.
+	if sc := tracing.FromContext(ctx); sc != nil {
+		traceID = sc.TraceID
+	}
+
 	k.Log.Info("Tracing request for user=%s, method=%s: %s", user, method, traceID)
 	return context.WithValue(ctx, TraceKey, traceID)
 }
@@ -21,6 +21,7 @@ import (
 	"koding/kites/kloud/stackstate"
 	"koding/kites/kloud/terraformer"
 	"koding/kites/kloud/utils/object"
+	"koding/kites/tracing"
 
 	"golang.org/x/net/context"
 )
@@ -36,7 +37,7 @@ import (
 // as soon as Apply method returns, allowed user list for each machine
 // is zeroed, which could make the destroy oepration to fail - we
 // first build machines and rest of the destroy is perfomed asynchronously.
-func (bs *BaseStack) HandleApply(ctx context.Context) (interface{}, error) {
+func (bs *BaseStack) HandleApply(ctx context.Context) (_ interface{}, err error) {
 	arg, ok := ctx.Value(stack.ApplyRequestKey).(*stack.ApplyRequest)
 	if !ok {
 		arg = &stack.ApplyRequest{}
@@ -50,7 +51,26 @@ func (bs *BaseStack) HandleApply(ctx context.Context) (interface{}, error) {
 		return nil, err
 	}
 
-	err := bs.Builder.BuildStack(arg.StackID, arg.Credentials)
+	name := "stack.apply"
+	if arg.Destroy {
+		name = "stack.destroy"
+	}
+
+	// The span is finished by the asynchronous part of apply
+	// or destroy, unless the request fails early.
+	span, ctx := tracing.StartSpan(ctx, name)
+	span.SetTag("stackId", arg.StackID)
+	span.SetTag("team", arg.GroupName)
+	defer func() {
+		if err != nil {
+			span.SetError(err)
+			span.Finish()
+		}
+	}()
+
+	bs.Trace = tracing.FromContext(ctx)
+
+	err = bs.Builder.BuildStack(arg.StackID, arg.Credentials)
 
 	if err != nil && !(arg.Destroy && models.IsNotFound(err, "jStackTemplate")) {
 		return nil, err
@@ -125,6 +145,10 @@ func (bs *BaseStack) apply(ctx context.Context, req *stack.ApplyRequest) {
 		}
 
 		bs.Eventer.Push(finalEvent)
+
+		span := tracing.SpanFromContext(ctx)
+		span.SetError(err)
+		span.Finish()
 	}()
 
 	err = bs.applyAsync(ctx, req)
@@ -165,7 +189,12 @@ func (bs *BaseStack) destroy(ctx context.Context, req *stack.ApplyRequest) error
 			Status:     machinestate.Terminated,
 		}
 
+		span := tracing.SpanFromContext(ctx)
+		defer span.Finish()
+
 		err := bs.destroyAsync(ctx, req)
+		span.SetError(err)
+
 		if err != nil {
 			// don't pass the error directly to the eventer, mask it to avoid
 			// error leaking to the client. We just log it here.
@@ -214,14 +243,20 @@ func (bs *BaseStack) destroyAsync(ctx context.Context, req *stack.ApplyRequest)
 		}
 		defer tfKite.Close()
 
+		span, ctx := tracing.StartSpan(ctx, "terraformer.destroy")
+		defer span.Finish()
+
 		tfReq := &terraformer.TerraformRequest{
 			ContentID: req.GroupName + "-" + req.StackID,
 			TraceID:   bs.TraceID,
+			Trace:     tracing.FromContext(ctx),
 		}
 
 		bs.Log.Debug("Calling terraform.destroy method with context: %+v", tfReq)
 
 		_, err = tfKite.Destroy(tfReq)
+		span.SetError(err)
+
 		if err != nil {
 			return err
 		}
@@ -329,10 +364,13 @@ func (bs *BaseStack) applyAsync(ctx context.Context, req *stack.ApplyRequest) er
 		}
 	}()
 
+	span, tfCtx := tracing.StartSpan(ctx, "terraformer.apply")
+
 	tfReq := &terraformer.TerraformRequest{
 		Content:   bs.Builder.Stack.Template,
 		ContentID: t.Key,
 		TraceID:   bs.TraceID,
+		Trace:     tracing.FromContext(tfCtx),
 	}
 
 	bs.Log.Debug("Final stack template. Calling terraform.apply method:")
@@ -340,6 +378,9 @@ func (bs *BaseStack) applyAsync(ctx context.Context, req *stack.ApplyRequest) er
 
 	state, err := tfKite.Apply(tfReq)
 
+	span.SetError(err)
+	span.Finish()
+
 	close(done)
 
 	if err != nil {
@@ -3,6 +3,7 @@ package provider
 import (
 	"koding/kites/kloud/stack"
 	"koding/kites/kloud/terraformer"
+	"koding/kites/tracing"
 	"koding/tools/util"
 
 	"golang.org/x/net/context"
@@ -13,7 +14,7 @@ var escapeVars = []string{
 	"payload_",
 }
 
-func (bs *BaseStack) HandlePlan(ctx context.Context) (interface{}, error) {
+func (bs *BaseStack) HandlePlan(ctx context.Context) (_ interface{}, err error) {
 	arg, ok := ctx.Value(stack.PlanRequestKey).(*stack.PlanRequest)
 	if !ok {
 		arg = &stack.PlanRequest{}
@@ -29,6 +30,16 @@ func (bs *BaseStack) HandlePlan(ctx context.Context) (interface{}, error) {
 
 	bs.Arg = arg
 
+	span, ctx := tracing.StartSpan(ctx, "stack.plan")
+	span.SetTag("stackTemplateId", arg.StackTemplateID)
+	span.SetTag("team", arg.GroupName)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
+	bs.Trace = tracing.FromContext(ctx)
+
 	bs.Log.Debug("Fetching template for id %s", arg.StackTemplateID)
 
 	if err := bs.Builder.BuildStackTemplate(arg.StackTemplateID); err != nil {
@@ -110,15 +121,21 @@ func (bs *BaseStack) Plan() (stack.Machines, error) {
 	}
 	defer tfKite.Close()
 
+	span, ctx := tracing.StartSpan(tracing.WithRemote(context.Background(), bs.Trace), "terraformer.plan")
+	defer span.Finish()
+
 	tfReq := &terraformer.TerraformRequest{
 		Content:   out,
 		ContentID: bs.Req.Username + "-" + bs.Arg.(*stack.PlanRequest).StackTemplateID,
 		TraceID:   bs.TraceID,
+		Trace:     tracing.FromContext(ctx),
 	}
 
 	bs.Log.Debug("Calling plan with content: %+v", tfReq)
 
 	plan, err := tfKite.Plan(tfReq)
+	span.SetError(err)
+
 	if err != nil {
 		return nil, err
 	}
@@ -10,6 +10,7 @@ import (
 	"koding/kites/kloud/eventer"
 	"koding/kites/kloud/machinestate"
 	"koding/kites/kloud/stack"
+	"koding/kites/tracing"
 
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/koding/kite"
@@ -235,6 +236,10 @@ type BaseStack struct {
 	Debug   bool
 	TraceID string
 
+	// Trace is used to continue the trace of the current
+	// request in terraformer calls.
+	Trace *tracing.SpanContext
+
 	// PlanFunc is used by HandlePlan method to
 	// build a list of machines created by
 	// a particular stack.
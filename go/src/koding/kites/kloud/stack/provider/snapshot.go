@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"time"
+
+	"koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"koding/kites/kloud/machinestate"
+	"koding/kites/kloud/stack"
+
+	"golang.org/x/net/context"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Snapshotter is implemented by provider machines that support
+// machine snapshots.
+//
+// The snapshot returned by CreateSnapshot is required to have
+// the SnapshotId field set, the rest of the fields are populated
+// by the BaseMachine.
+//
+// RestoreSnapshot is expected to leave the machine in the state
+// it was before the call, which is reported by State method.
+type Snapshotter interface {
+	CreateSnapshot(ctx context.Context, label string) (*models.Snapshot, error)
+	DeleteSnapshot(context.Context, *models.Snapshot) error
+	RestoreSnapshot(context.Context, *models.Snapshot) (metadata interface{}, err error)
+}
+
+var _ stack.Snapshotter = (*BaseMachine)(nil)
+
+func (bm *BaseMachine) HandleCreateSnapshot(ctx context.Context, req *stack.SnapshotRequest) error {
+	s, err := bm.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	origState := bm.State()
+
+	if err := modelhelper.ChangeMachineState(bm.ObjectId, "Machine is snapshotting", machinestate.Snapshotting); err != nil {
+		return err
+	}
+
+	defer modelhelper.ChangeMachineState(bm.ObjectId, "Machine is marked as "+origState.String(), origState)
+
+	label := req.Label
+	if label == "" {
+		label = bm.Label + "-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	bm.PushEvent("Creating snapshot", 20, machinestate.Snapshotting)
+
+	snapshot, err := s.CreateSnapshot(ctx, label)
+	if err != nil {
+		return stack.NewEventerError(err)
+	}
+
+	bm.PushEvent("Saving snapshot", 90, machinestate.Snapshotting)
+
+	account, err := modelhelper.GetAccount(bm.User.Name)
+	if err != nil {
+		return err
+	}
+
+	snapshot.Id = bson.NewObjectId()
+	snapshot.OriginId = account.Id
+	snapshot.MachineId = bm.ObjectId
+	snapshot.Label = label
+	snapshot.Provider = bm.Provider
+	snapshot.CreatedAt = time.Now().UTC()
+
+	switch group, err := modelhelper.GetMachineGroup(bm.Machine); err {
+	case nil:
+		snapshot.Group = group.Slug
+	case mgo.ErrNotFound:
+	default:
+		return err
+	}
+
+	return modelhelper.CreateSnapshot(snapshot)
+}
+
+func (bm *BaseMachine) HandleDeleteSnapshot(ctx context.Context, req *stack.SnapshotRequest) error {
+	s, err := bm.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := bm.snapshot(req.SnapshotID)
+	if err != nil {
+		return err
+	}
+
+	bm.PushEvent("Deleting snapshot", 20, machinestate.Snapshotting)
+
+	if err := s.DeleteSnapshot(ctx, snapshot); err != nil {
+		return stack.NewEventerError(err)
+	}
+
+	return modelhelper.DeleteSnapshot(snapshot.SnapshotId)
+}
+
+func (bm *BaseMachine) HandleRestoreSnapshot(ctx context.Context, req *stack.SnapshotRequest) (err error) {
+	s, err := bm.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := bm.snapshot(req.SnapshotID)
+	if err != nil {
+		return err
+	}
+
+	origState := bm.State()
+	currentState := origState
+
+	if err := modelhelper.ChangeMachineState(bm.ObjectId, "Machine is restoring a snapshot", machinestate.Snapshotting); err != nil {
+		return err
+	}
+
+	defer func() {
+		bm.Log.Debug("restore exit: origState=%s, currentState=%s, err=%v", origState, currentState, err)
+
+		if err != nil {
+			modelhelper.ChangeMachineState(bm.ObjectId, "Machine is marked as "+currentState.String(), currentState)
+		}
+	}()
+
+	bm.PushEvent("Restoring snapshot", 20, machinestate.Snapshotting)
+
+	meta, err := s.RestoreSnapshot(ctx, snapshot)
+	if err != nil {
+		return stack.NewEventerError(err)
+	}
+
+	var dialState *DialState
+
+	if origState == machinestate.Running {
+		bm.PushEvent("Checking remote machine", 80, machinestate.Snapshotting)
+
+		if dialState, err = bm.WaitKlientReady(0); err != nil {
+			currentState = machinestate.Stopped
+			return stack.NewEventerError(err)
+		}
+	}
+
+	return bm.updateMachine(dialState, meta, origState)
+}
+
+func (bm *BaseMachine) snapshotter() (Snapshotter, error) {
+	s, ok := bm.machine.(Snapshotter)
+	if !ok {
+		return nil, stack.NewEventerError(stack.NewError(stack.ErrSnapshotNotSupported))
+	}
+
+	return s, nil
+}
+
+// snapshot fetches the snapshot given by the id, ensuring
+// it was taken from the machine.
+func (bm *BaseMachine) snapshot(id string) (*models.Snapshot, error) {
+	snapshot, err := modelhelper.GetSnapshot(id)
+	if err == mgo.ErrNotFound || (err == nil && snapshot.MachineId != bm.ObjectId) {
+		return nil, stack.NewEventerError(stack.NewError(stack.ErrSnapshotNotFound))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
@@ -17,6 +17,7 @@ import (
 	"koding/kites/kloud/stack"
 	"koding/kites/kloud/userdata"
 	"koding/kites/kloud/utils/object"
+	"koding/kites/tracing"
 
 	"github.com/koding/kite"
 	"github.com/koding/logging"
@@ -238,6 +239,8 @@ func (s *Stacker) BaseStack(ctx context.Context) (*BaseStack, error) {
 		bs.TraceID = traceID
 	}
 
+	bs.Trace = tracing.FromContext(ctx)
+
 	if keys, ok := publickeys.FromContext(ctx); ok {
 		bs.Keys = keys
 	}
@@ -13,6 +13,7 @@ import (
 	"koding/kites/kloud/machinestate"
 	"koding/kites/kloud/stack"
 	"koding/kites/kloud/utils"
+	"koding/kites/tracing"
 
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/koding/kite"
@@ -296,8 +297,20 @@ func (p *Planner) DialKlients(ctx context.Context, kiteIDs stack.KiteMap) (map[s
 
 		go func(label, kiteID string) {
 			defer wg.Done()
+
+			// The span covers both connecting to klient
+			// and the ping handshake; state tag tells
+			// which of the steps failed.
+			span, _ := tracing.StartSpan(ctx, "klient.dial")
+			span.SetTag("label", label)
+			span.SetTag("kiteId", kiteID)
+
 			state := p.checkSingleKlient(sess.Kite, label, kiteID)
 
+			span.SetTag("state", state.State)
+			span.SetError(state.Err)
+			span.Finish()
+
 			mu.Lock()
 			if state.Err != nil {
 				de.States = append(de.States, state)
@@ -0,0 +1,232 @@
+package stack
+
+import (
+	"time"
+
+	"koding/db/mongodb/modelhelper"
+	"koding/kites/kloud/machine"
+
+	"github.com/koding/kite"
+	"golang.org/x/net/context"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SnapshotRequest represents a request value for "machine.snapshot.*"
+// kite methods.
+type SnapshotRequest struct {
+	MachineID  string `json:"machineId"`
+	Provider   string `json:"provider"`
+	SnapshotID string `json:"snapshotId,omitempty"`
+	Label      string `json:"label,omitempty"`
+	Debug      bool   `json:"debug,omitempty"`
+}
+
+// Snapshot represents a single snapshot of a machine.
+type Snapshot struct {
+	ID          string    `json:"id"`
+	MachineID   string    `json:"machineId"`
+	Label       string    `json:"label"`
+	Provider    string    `json:"provider"`
+	Region      string    `json:"region,omitempty"`
+	StorageSize string    `json:"storageSize,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// SnapshotListResponse represents a response value from "machine.snapshot.list"
+// kite method.
+type SnapshotListResponse struct {
+	Snapshots []*Snapshot `json:"snapshots"`
+}
+
+// Snapshotter is implemented by machines, which support snapshots.
+type Snapshotter interface {
+	HandleCreateSnapshot(context.Context, *SnapshotRequest) error
+	HandleDeleteSnapshot(context.Context, *SnapshotRequest) error
+	HandleRestoreSnapshot(context.Context, *SnapshotRequest) error
+}
+
+type snapshotFunc func(Snapshotter, context.Context, *SnapshotRequest) error
+
+// SnapshotCreate is a kite.Handler for "machine.snapshot.create" kite method.
+func (k *Kloud) SnapshotCreate(r *kite.Request) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit is checked before the snapshot is created by coreMethods,
+	// verify ownership first so non-owners can't learn the team's usage.
+	if err := k.checkSnapshotOwner(r.Username, req.MachineID); err != nil {
+		return nil, err
+	}
+
+	if err := k.checkSnapshotLimit(req.MachineID); err != nil {
+		return nil, err
+	}
+
+	return k.snapshotMethod(r, req, Snapshotter.HandleCreateSnapshot)
+}
+
+// SnapshotDelete is a kite.Handler for "machine.snapshot.delete" kite method.
+func (k *Kloud) SnapshotDelete(r *kite.Request) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SnapshotID == "" {
+		return nil, NewError(ErrSnapshotIdMissing)
+	}
+
+	return k.snapshotMethod(r, req, Snapshotter.HandleDeleteSnapshot)
+}
+
+// SnapshotRestore is a kite.Handler for "machine.snapshot.restore" kite method.
+func (k *Kloud) SnapshotRestore(r *kite.Request) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SnapshotID == "" {
+		return nil, NewError(ErrSnapshotIdMissing)
+	}
+
+	return k.snapshotMethod(r, req, Snapshotter.HandleRestoreSnapshot)
+}
+
+// SnapshotList is a kite.Handler for "machine.snapshot.list" kite method.
+func (k *Kloud) SnapshotList(r *kite.Request) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := k.checkSnapshotOwner(r.Username, req.MachineID); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := modelhelper.GetSnapshotsByMachineId(bson.ObjectIdHex(req.MachineID))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SnapshotListResponse{
+		Snapshots: make([]*Snapshot, len(snapshots)),
+	}
+
+	for i, s := range snapshots {
+		resp.Snapshots[i] = &Snapshot{
+			ID:          s.SnapshotId,
+			MachineID:   s.MachineId.Hex(),
+			Label:       s.Label,
+			Provider:    s.Provider,
+			Region:      s.Region,
+			StorageSize: s.StorageSize,
+			CreatedAt:   s.CreatedAt,
+		}
+	}
+
+	return resp, nil
+}
+
+func (k *Kloud) snapshotMethod(r *kite.Request, req *SnapshotRequest, fn snapshotFunc) (interface{}, error) {
+	return k.coreMethods(r, func(ctx context.Context, m Machiner) error {
+		s, ok := m.(Snapshotter)
+		if !ok {
+			return NewEventerError(NewError(ErrSnapshotNotSupported))
+		}
+
+		return fn(s, ctx, req)
+	})
+}
+
+// checkSnapshotOwner returns non-nil error when the given machine does
+// not exist or is not owned by the user.
+func (k *Kloud) checkSnapshotOwner(username, machineID string) error {
+	if !bson.IsObjectIdHex(machineID) {
+		return NewError(ErrMachineNotFound)
+	}
+
+	f := &machine.Filter{
+		ID:           machineID,
+		Username:     username,
+		Owners:       true,
+		OnlyApproved: true,
+	}
+
+	machines, err := k.MachineClient.Machines(f)
+	if err != nil {
+		return err
+	}
+
+	if len(machines) == 0 {
+		return NewError(ErrMachineNotFound)
+	}
+
+	return nil
+}
+
+// checkSnapshotLimit returns non-nil error when the team of the given
+// machine reached its snapshot limit.
+func (k *Kloud) checkSnapshotLimit(machineID string) error {
+	m, err := modelhelper.GetMachine(machineID)
+	if err == mgo.ErrNotFound {
+		return NewError(ErrMachineNotFound)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	group, err := modelhelper.GetMachineGroup(m)
+	if err == mgo.ErrNotFound {
+		return nil // machine does not belong to a team
+	}
+
+	if err != nil {
+		return err
+	}
+
+	limit, err := modelhelper.FetchSnapshotLimit(group.Slug)
+	if err != nil {
+		return err
+	}
+
+	if limit == 0 {
+		limit = k.SnapshotLimit
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+
+	n, err := modelhelper.CountSnapshotsByGroup(group.Slug)
+	if err != nil {
+		return err
+	}
+
+	if n >= limit {
+		return NewError(ErrSnapshotLimitReached)
+	}
+
+	return nil
+}
+
+func snapshotRequest(r *kite.Request) (*SnapshotRequest, error) {
+	if r.Args == nil {
+		return nil, NewError(ErrNoArguments)
+	}
+
+	var req SnapshotRequest
+	if err := r.Args.One().Unmarshal(&req); err != nil {
+		return nil, err
+	}
+
+	if req.MachineID == "" {
+		return nil, NewError(ErrMachineIdMissing)
+	}
+
+	return &req, nil
+}
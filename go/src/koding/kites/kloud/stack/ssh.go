@@ -0,0 +1,188 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"koding/kites/kloud/sshcert"
+
+	"github.com/koding/kite"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ForwardAgentMetaKey is a JMachine.meta field which enables SSH agent
+// forwarding for certificates issued for the machine.
+const ForwardAgentMetaKey = "forwardAgent"
+
+// SSHCertRequest represents a request value for "ssh.cert" kite method.
+type SSHCertRequest struct {
+	// MachineID is an ID of the machine the certificate is issued for.
+	MachineID string `json:"machineId"`
+
+	// PublicKey is the user public key in authorized_keys format.
+	PublicKey string `json:"publicKey"`
+}
+
+// SSHCertResponse represents a response value from "ssh.cert" kite method.
+type SSHCertResponse struct {
+	// Certificate is a signed user certificate in authorized_keys format.
+	Certificate string `json:"certificate"`
+
+	// CAPublicKey is the public key of the team certificate authority, which
+	// remote sshd must trust.
+	CAPublicKey string `json:"caPublicKey"`
+
+	// Principal is the certificate principal remote sshd must accept for
+	// the remote user. It is scoped to the machine, see sshcert.Principal.
+	Principal string `json:"principal"`
+
+	// ForwardAgent tells whether the certificate allows agent forwarding.
+	ForwardAgent bool `json:"forwardAgent"`
+
+	// ValidBefore is the certificate expiration time.
+	ValidBefore time.Time `json:"validBefore"`
+}
+
+// SSHConfigRequest represents a request value for "ssh.config" kite method.
+type SSHConfigRequest struct {
+	MachineID    string `json:"machineId"`
+	ForwardAgent bool   `json:"forwardAgent"`
+}
+
+// SSHCert is a kite.Handler for "ssh.cert" kite method. It issues
+// a short-lived user certificate signed by the certificate authority of
+// the team the machine belongs to.
+func (k *Kloud) SSHCert(r *kite.Request) (interface{}, error) {
+	if k.SSHCA == nil {
+		return nil, errors.New("ssh certificates are not supported")
+	}
+
+	if r.Args == nil {
+		return nil, NewError(ErrNoArguments)
+	}
+
+	var req SSHCertRequest
+	if err := r.Args.One().Unmarshal(&req); err != nil {
+		return nil, err
+	}
+
+	if req.PublicKey == "" {
+		return nil, errors.New("publicKey is not passed")
+	}
+
+	m, group, err := sshMachine(req.MachineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMachineUser(m, r.Username) {
+		return nil, fmt.Errorf("user %q has no access to %q machine", r.Username, req.MachineID)
+	}
+
+	ca, err := k.SSHCA.CA(group.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	forwardAgent, _ := m.Meta[ForwardAgentMetaKey].(bool)
+	principal := sshcert.Principal(r.Username, m.ObjectId.Hex())
+
+	cert, err := ca.Sign(&sshcert.SignRequest{
+		PublicKey:    req.PublicKey,
+		KeyID:        r.Username + "@" + group.Slug,
+		Principals:   []string{principal},
+		TTL:          k.SSHCertTTL,
+		ForwardAgent: forwardAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	k.Log.Debug("issued SSH certificate for user=%s, machine=%s, team=%s", r.Username, req.MachineID, group.Slug)
+
+	return &SSHCertResponse{
+		Certificate:  sshcert.Marshal(cert),
+		CAPublicKey:  ca.PublicKey(),
+		Principal:    principal,
+		ForwardAgent: forwardAgent,
+		ValidBefore:  time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	}, nil
+}
+
+// SSHConfig is a kite.Handler for "ssh.config" kite method. It allows
+// machine owner or team admin to change SSH settings of the machine.
+func (k *Kloud) SSHConfig(r *kite.Request) (interface{}, error) {
+	if r.Args == nil {
+		return nil, NewError(ErrNoArguments)
+	}
+
+	var req SSHConfigRequest
+	if err := r.Args.One().Unmarshal(&req); err != nil {
+		return nil, err
+	}
+
+	m, group, err := sshMachine(req.MachineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner := m.Owner(); owner == nil || owner.Username != r.Username {
+		isAdmin, err := modelhelper.IsAdmin(r.Username, group.Slug)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isAdmin {
+			return nil, fmt.Errorf("user %q is neither an owner of %q machine nor an admin of %q team", r.Username, req.MachineID, group.Slug)
+		}
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"meta." + ForwardAgentMetaKey: req.ForwardAgent,
+		},
+	}
+
+	if err := modelhelper.UpdateMachine(m.ObjectId, change); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+func sshMachine(id string) (*models.Machine, *models.Group, error) {
+	if id == "" {
+		return nil, nil, NewError(ErrMachineIdMissing)
+	}
+
+	m, err := modelhelper.GetMachine(id)
+	if err == mgo.ErrNotFound {
+		return nil, nil, NewError(ErrMachineNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group, err := modelhelper.GetMachineGroup(m)
+	if err != nil {
+		return nil, nil, models.ResError(err, "jGroup")
+	}
+
+	return m, group, nil
+}
+
+// isMachineUser checks whether the user is an owner of the machine or
+// the machine is permanently shared with them.
+func isMachineUser(m *models.Machine, username string) bool {
+	for _, u := range m.Users {
+		if u.Username == username && (u.Owner || (u.Permanent && u.Approved)) {
+			return true
+		}
+	}
+
+	return false
+}
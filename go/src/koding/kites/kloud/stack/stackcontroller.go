@@ -7,6 +7,7 @@ import (
 	"koding/kites/kloud/contexthelper/publickeys"
 	"koding/kites/kloud/contexthelper/request"
 	"koding/kites/kloud/eventer"
+	"koding/kites/tracing"
 
 	"github.com/koding/kite"
 	"golang.org/x/net/context"
@@ -21,6 +22,9 @@ type TeamRequest struct {
 	Debug       bool   `json:"debug,omitempty"`
 	Impersonate string `json:"impersonate,omitempty"` // only for kloudctl
 	Identifier  string `json:"identifier"`
+
+	// Trace, when non-nil, continues a trace started by the caller.
+	Trace *tracing.SpanContext `json:"trace,omitempty"`
 }
 
 func (req *TeamRequest) metricTags() []string {
@@ -90,6 +94,11 @@ func (k *Kloud) stackMethod(r *kite.Request, fn StackFunc) (interface{}, error)
 
 	ctx = k.traceRequest(ctx, args.metricTags())
 
+	span, ctx := tracing.StartSpan(ctx, "kloud."+r.Method)
+	span.SetTag("user", r.Username)
+	span.SetTag("team", args.GroupName)
+	span.SetTag("provider", args.Provider)
+
 	// Currently only apply method is asynchronous, rest
 	// of the is sync. That's why the fn execution is synchronous here,
 	// and the fn itself emits events if needed.
@@ -113,6 +122,9 @@ func (k *Kloud) stackMethod(r *kite.Request, fn StackFunc) (interface{}, error)
 		}
 	}
 
+	span.SetError(err)
+	span.Finish()
+
 	k.send(ctx)
 
 	return resp, err
@@ -150,7 +162,9 @@ func (k *Kloud) newStack(r *kite.Request, req *TeamRequest) (Stacker, context.Co
 		k.Log.Debug("Eventer created %q", evID)
 	}
 
-	if req.Debug {
+	ctx = tracing.WithRemote(ctx, req.Trace)
+
+	if req.Debug || req.Trace != nil {
 		ctx = k.setTraceID(r.Username, r.Method, ctx)
 	}
 
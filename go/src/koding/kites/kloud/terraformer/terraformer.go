@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"time"
 
+	"koding/kites/tracing"
+
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/koding/kite"
 )
@@ -17,6 +19,9 @@ type TerraformRequest struct {
 	Variables map[string]interface{}
 	ContentID string
 	TraceID   string
+
+	// Trace continues the trace of the caller in terraformer.
+	Trace *tracing.SpanContext `json:",omitempty"`
 }
 
 // Terraformer represents a remote terraformer instance.
@@ -11,6 +11,7 @@ import (
 	"koding/db/mongodb/modelhelper"
 	"koding/kites/common"
 	konfig "koding/kites/config"
+	"koding/kites/health"
 	"koding/kites/metrics"
 
 	"github.com/koding/kite"
@@ -93,6 +94,10 @@ func New(c *Config) *kontrol.Kontrol {
 	kon.AddAuthenticator("sessionID", authenticateFromSessionID)
 	kon.MachineAuthenticate = authenticateMachine
 
+	h := health.New(Name)
+	h.Live("http", health.HTTP(kon.Kite, "/healthCheck"))
+	h.Ready("mongo", health.Mongo(modelhelper.Mongo))
+
 	switch c.Storage {
 	case "etcd":
 		kon.SetStorage(kontrol.NewEtcd(c.Machines, kon.Kite.Log))
@@ -108,6 +113,8 @@ func New(c *Config) *kontrol.Kontrol {
 		p.DB.SetMaxOpenConns(20)
 		kon.SetStorage(p)
 
+		h.Ready("postgres", health.SQL(p.DB))
+
 		s := kontrol.NewCachedStorage(
 			p,
 			kontrol.NewMemKeyPairStorageTTL(time.Minute*5),
@@ -120,6 +127,8 @@ func New(c *Config) *kontrol.Kontrol {
 
 	kon.AddKeyPair("", string(publicKey), string(privateKey))
 
+	h.Register(kon.Kite)
+
 	if c.TLSKeyFile != "" && c.TLSCertFile != "" {
 		kon.Kite.UseTLSFile(c.TLSCertFile, c.TLSKeyFile)
 	}
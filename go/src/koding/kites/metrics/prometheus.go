@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are upper bounds (in seconds) of histogram buckets
+// used for request latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// DefaultRegistry collects request metrics of handlers wrapped
+// with WrapKiteHandler and WrapHTTPHandler.
+var DefaultRegistry = NewRegistry()
+
+// PrometheusHandler gives a handler that exposes metrics collected
+// by the DefaultRegistry in Prometheus text format.
+//
+// It is meant to be served under /metrics path of a kite:
+//
+//	k.HandleHTTP("/metrics", metrics.PrometheusHandler())
+func PrometheusHandler() http.Handler {
+	return DefaultRegistry
+}
+
+const (
+	kiteRequestMetric = "kite_request_duration_seconds"
+	httpRequestMetric = "http_request_duration_seconds"
+)
+
+// Registry keeps latency histograms of requests and exposes them in
+// Prometheus text format. The histogram counts can be used for
+// obtaining request counts and error rates as well.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	help   string
+	labels []string
+	series map[string]*histogram // keyed by joined label values
+}
+
+type histogram struct {
+	values  []string
+	buckets []uint64 // non-cumulative counts per DefaultBuckets
+	count   uint64
+	sum     float64
+}
+
+// NewRegistry gives new registry with kite and HTTP request
+// metrics registered.
+func NewRegistry() *Registry {
+	return &Registry{
+		families: map[string]*family{
+			kiteRequestMetric: {
+				help:   "Latency of kite method calls.",
+				labels: []string{"method", "success"},
+				series: make(map[string]*histogram),
+			},
+			httpRequestMetric: {
+				help:   "Latency of HTTP requests.",
+				labels: []string{"handler", "code"},
+				series: make(map[string]*histogram),
+			},
+		},
+	}
+}
+
+// ObserveKite records a kite method call.
+func (r *Registry) ObserveKite(method string, success bool, dur time.Duration) {
+	r.observe(kiteRequestMetric, dur, method, strconv.FormatBool(success))
+}
+
+// ObserveHTTP records an HTTP request.
+func (r *Registry) ObserveHTTP(handler string, code int, dur time.Duration) {
+	r.observe(httpRequestMetric, dur, handler, strconv.Itoa(code))
+}
+
+func (r *Registry) observe(name string, dur time.Duration, values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.families[name]
+	key := strings.Join(values, "\x00")
+
+	h, ok := f.series[key]
+	if !ok {
+		h = &histogram{
+			values:  values,
+			buckets: make([]uint64, len(DefaultBuckets)),
+		}
+		f.series[key] = h
+	}
+
+	sec := dur.Seconds()
+
+	if i := sort.SearchFloat64s(DefaultBuckets, sec); i < len(DefaultBuckets) {
+		h.buckets[i]++
+	}
+
+	h.count++
+	h.sum += sec
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	bw := bufio.NewWriter(w)
+	r.write(bw)
+	bw.Flush()
+}
+
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+
+		if len(f.series) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			h := f.series[key]
+			labels := formatLabels(f.labels, h.values)
+
+			var cumulative uint64
+			for i, le := range DefaultBuckets {
+				cumulative += h.buckets[i]
+				fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+			}
+
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+			fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+			fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	labels := make([]string, len(names))
+
+	for i, name := range names {
+		labels[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+
+	return strings.Join(labels, ",")
+}
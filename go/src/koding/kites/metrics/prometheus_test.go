@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	r.ObserveKite("machine.start", true, 20*time.Millisecond)
+	r.ObserveKite("machine.start", true, 2*time.Second)
+	r.ObserveKite("machine.start", false, 100*time.Millisecond)
+	r.ObserveHTTP("rest_handler", 502, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	out := rec.Body.String()
+
+	want := []string{
+		"# TYPE kite_request_duration_seconds histogram",
+		`kite_request_duration_seconds_bucket{method="machine.start",success="true",le="0.01"} 0`,
+		`kite_request_duration_seconds_bucket{method="machine.start",success="true",le="0.025"} 1`,
+		`kite_request_duration_seconds_bucket{method="machine.start",success="true",le="2.5"} 2`,
+		`kite_request_duration_seconds_bucket{method="machine.start",success="true",le="+Inf"} 2`,
+		`kite_request_duration_seconds_count{method="machine.start",success="true"} 2`,
+		`kite_request_duration_seconds_bucket{method="machine.start",success="false",le="0.1"} 1`,
+		`kite_request_duration_seconds_count{method="machine.start",success="false"} 1`,
+		`kite_request_duration_seconds_sum{method="machine.start",success="false"} 0.1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_count{handler="rest_handler",code="502"} 1`,
+	}
+
+	for _, line := range want {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("got %q, want text/plain content type", ct)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := formatLabels([]string{"method", "success"}, []string{`a"b\c` + "\n", "true"})
+	want := `method="a\"b\\c\n",success="true"`
+
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -21,6 +21,8 @@ func WrapKiteHandler(dd *dogstatsd.Client, metricName string, handler kite.Handl
 		resp, err := handler.ServeKite(r)
 		dur := time.Since(start)
 
+		DefaultRegistry.ObserveKite(metricName, err == nil, dur)
+
 		var tags []string
 		tags = AppendTag(tags, "success", err == nil)
 		tags = AppendTag(tags, "method", r.Method)
@@ -53,6 +55,8 @@ func WrapHTTPHandler(dd *dogstatsd.Client, metricName string, handler http.Handl
 		handler(rr, r)
 		dur := time.Since(start)
 
+		DefaultRegistry.ObserveHTTP(metricName, rr.code, dur)
+
 		var tags []string
 		tags = AppendTag(tags, "code", rr.code)
 		tags = AppendTag(tags, "request_type", "http")
@@ -25,6 +25,10 @@ type Config struct {
 	SecretKey string
 
 	KontrolURL string // if empty, default is used: "127.0.0.1:3000"
+
+	// TraceExporter is a file path or a collector URL, which
+	// spans are exported to; if empty, tracing is disabled.
+	TraceExporter string
 }
 
 // AWS holds config variables for remote AWS
@@ -0,0 +1,49 @@
+package terraformer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"koding/kites/terraformer/kodingcontext/pkg"
+)
+
+// checkPlugins ensures terraform provider plugins are available,
+// as without them no plan nor apply can succeed.
+func checkPlugins() error {
+	config := pkg.BuiltinConfig
+
+	if err := config.Discover(); err != nil {
+		return err
+	}
+
+	if len(config.Providers) == 0 {
+		return errors.New("no terraform provider plugins found")
+	}
+
+	for name, path := range config.Providers {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("provider %q: %s", name, err)
+		}
+
+		if fi.Mode()&0111 == 0 {
+			return fmt.Errorf("provider %q: %q is not executable", name, path)
+		}
+	}
+
+	return nil
+}
+
+// checkState fails when terraformer is closing and does not
+// accept new requests.
+func (t *Terraformer) checkState() error {
+	t.rwmu.RLock()
+	defer t.rwmu.RUnlock()
+
+	if t.closing {
+		return errors.New("terraformer is closing")
+	}
+
+	return nil
+}
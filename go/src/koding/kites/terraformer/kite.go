@@ -5,6 +5,7 @@ import (
 
 	"koding/artifact"
 	"koding/kites/config"
+	"koding/kites/health"
 	"koding/kites/metrics"
 
 	dogstatsd "github.com/DataDog/datadog-go/statsd"
@@ -37,6 +38,11 @@ func NewKite(t *Terraformer, conf *Config) (*kite.Kite, error) {
 	// artifact handling
 	k.HandleHTTPFunc("/healthCheck", artifact.HealthCheckHandler(Name))
 	k.HandleHTTPFunc("/version", artifact.VersionHandler())
+	k.HandleHTTP("/metrics", metrics.PrometheusHandler())
+
+	t.Health.Live("http", health.HTTP(k, "/healthCheck"))
+	t.Health.Ready("kontrol", health.Kontrol(k))
+	t.Health.Register(k)
 
 	secretKey := conf.SecretKey
 
@@ -184,3 +184,17 @@ func (f *File) errorf(err error, format string, args ...interface{}) error {
 	f.log.Error("%s: %s", fmt.Sprintf(format, args...), err)
 	return err
 }
+
+// Check implements the health.Checker interface.
+func (f *File) Check() error {
+	fi, err := os.Stat(f.basePath)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%q is not a directory", f.basePath)
+	}
+
+	return nil
+}
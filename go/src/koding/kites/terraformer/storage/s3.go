@@ -165,3 +165,11 @@ func (s *S3) ensureClosed(r io.Reader, path string) {
 		}
 	}
 }
+
+// Check implements the health.Checker interface.
+func (s *S3) Check() error {
+	_, err := s.s3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(s.bucketName),
+	})
+	return err
+}
@@ -14,13 +14,16 @@ import (
 	"syscall"
 
 	"koding/kites/common"
+	"koding/kites/health"
 	"koding/kites/terraformer/kodingcontext"
 	"koding/kites/terraformer/storage"
+	"koding/kites/tracing"
 
 	dogstatsd "github.com/DataDog/datadog-go/statsd"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/koding/kite"
 	"github.com/koding/logging"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -48,6 +51,13 @@ type Terraformer struct {
 	// Store app runtime config
 	Config *Config
 
+	// Health checks dependencies of terraformer
+	Health *health.Health
+
+	// Tracer exports spans of plan and apply requests; if nil,
+	// tracing is disabled
+	Tracer *tracing.Tracer
+
 	closeChan chan struct{} // To signal when terraformer is closing
 
 	closing bool
@@ -60,6 +70,9 @@ type TerraformRequest struct {
 	Variables map[string]interface{}
 	ContentID string
 	TraceID   string
+
+	// Trace continues the trace of the caller
+	Trace *tracing.SpanContext `json:",omitempty"`
 }
 
 // New creates a new terraformer
@@ -107,6 +120,28 @@ func New(conf *Config, log logging.Logger) (*Terraformer, error) {
 		Context:   c,
 		Config:    conf,
 		closeChan: make(chan struct{}),
+		Health:    health.New(Name),
+	}
+
+	t.Health.Ready("state", health.CheckFunc(t.checkState))
+	t.Health.Ready("plugins", health.CheckFunc(checkPlugins))
+	t.Health.Ready("localStorage", ls)
+
+	if c, ok := rs.(health.Checker); ok {
+		t.Health.Ready("remoteStorage", c)
+	}
+
+	if conf.TraceExporter != "" {
+		e, err := tracing.NewExporter(conf.TraceExporter)
+		if err != nil {
+			return nil, fmt.Errorf("error while creating trace exporter: %s", err)
+		}
+
+		t.Tracer = &tracing.Tracer{
+			Service:  Name,
+			Exporter: e,
+			Log:      log.Warning,
+		}
 	}
 
 	t.handleSignals()
@@ -133,6 +168,10 @@ func (t *Terraformer) Close() error {
 		}
 	}
 
+	if e := t.Tracer.Close(); e != nil {
+		t.Log.Warning("err while closing tracer %s", e)
+	}
+
 	close(t.closeChan)
 
 	// clean up global vars
@@ -169,8 +208,12 @@ func (t *Terraformer) Plan(r *kite.Request) (interface{}, error) {
 		return nil, err
 	}
 
+	span := t.startSpan(&args, "plan")
+	defer span.Finish()
+
 	c, err := t.Context.Get(args.ContentID, args.TraceID)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 	defer c.Close()
@@ -179,7 +222,10 @@ func (t *Terraformer) Plan(r *kite.Request) (interface{}, error) {
 	c.Variables = args.Variables
 
 	destroy := false
-	return c.Plan(strings.NewReader(args.Content), destroy)
+	plan, err := c.Plan(strings.NewReader(args.Content), destroy)
+	span.SetError(err)
+
+	return plan, err
 }
 
 // Apply provides a kite call for apply operation
@@ -200,8 +246,12 @@ func (t *Terraformer) apply(r *kite.Request, destroy bool) (*terraform.State, er
 		return nil, err
 	}
 
+	span := t.startSpan(&args, r.Method)
+	defer span.Finish()
+
 	c, err := t.Context.Get(args.ContentID, args.TraceID)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 	defer c.Close()
@@ -215,15 +265,24 @@ func (t *Terraformer) apply(r *kite.Request, destroy bool) (*terraform.State, er
 		content = strings.NewReader(args.Content)
 	}
 
-	return c.Apply(content, destroy)
+	state, err := c.Apply(content, destroy)
+	span.SetError(err)
+
+	return state, err
 }
 
-func (t *Terraformer) handleState(r *kite.Request) (interface{}, error) {
-	t.rwmu.RLock()
-	defer t.rwmu.RUnlock()
+// startSpan starts a span, which continues the trace
+// of the caller if it was sent with the request.
+func (t *Terraformer) startSpan(args *TerraformRequest, name string) *tracing.Span {
+	span, _ := t.Tracer.StartSpan(tracing.WithRemote(context.Background(), args.Trace), name)
+	span.SetTag("contentId", args.ContentID)
 
-	if t.closing {
-		return false, errors.New("terraformer is closing")
+	return span
+}
+
+func (t *Terraformer) handleState(r *kite.Request) (interface{}, error) {
+	if err := t.checkState(); err != nil {
+		return false, err
 	}
 
 	return true, nil
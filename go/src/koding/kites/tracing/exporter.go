@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewExporter gives an exporter for the given target.
+//
+// If the target is a http:// or https:// URL, it creates
+// a CollectorExporter, otherwise the target is treated as
+// a file path and a FileExporter is created.
+func NewExporter(target string) (Exporter, error) {
+	if target == "" {
+		return nil, errors.New("tracing: empty exporter target")
+	}
+
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return NewCollectorExporter(target), nil
+	}
+
+	return NewFileExporter(target)
+}
+
+// FileExporter writes spans to a file, one JSON-encoded span per line.
+type FileExporter struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+var _ Exporter = (*FileExporter)(nil)
+
+// NewFileExporter gives new file exporter, which appends spans
+// to the given file.
+func NewFileExporter(file string) (*FileExporter, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{
+		f:   f,
+		enc: json.NewEncoder(f),
+	}, nil
+}
+
+// Export implements the Exporter interface.
+func (fe *FileExporter) Export(s *Span) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	return fe.enc.Encode(s)
+}
+
+// Close implements the Exporter interface.
+func (fe *FileExporter) Close() error {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	return fe.f.Close()
+}
+
+// CollectorExporter sends spans in batches to a collector, which
+// accepts Zipkin v2 JSON format, e.g. Jaeger collector with
+// Zipkin endpoint enabled:
+//
+//	http://jaeger-collector:9411/api/v2/spans
+type CollectorExporter struct {
+	URL           string        // collector endpoint
+	BatchSize     int           // max number of buffered spans; 100 by default
+	FlushInterval time.Duration // 5s by default
+	Client        *http.Client  // http.DefaultClient by default
+
+	once  sync.Once
+	stop  sync.Once
+	mu    sync.Mutex
+	spans []*zipkinSpan
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+var _ Exporter = (*CollectorExporter)(nil)
+
+// NewCollectorExporter gives new collector exporter for the given URL.
+func NewCollectorExporter(url string) *CollectorExporter {
+	return &CollectorExporter{
+		URL: url,
+	}
+}
+
+// Export implements the Exporter interface.
+//
+// The span is buffered and sent either after FlushInterval
+// or when the buffer reaches BatchSize.
+func (ce *CollectorExporter) Export(s *Span) error {
+	ce.once.Do(ce.init)
+
+	ce.mu.Lock()
+	ce.spans = append(ce.spans, newZipkinSpan(s))
+	full := len(ce.spans) >= ce.batchSize()
+	ce.mu.Unlock()
+
+	if full {
+		select {
+		case ce.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close implements the Exporter interface.
+//
+// It sends all buffered spans.
+func (ce *CollectorExporter) Close() error {
+	ce.once.Do(ce.init)
+	ce.stop.Do(func() { close(ce.done) })
+	ce.wg.Wait()
+
+	return ce.send()
+}
+
+func (ce *CollectorExporter) init() {
+	ce.flush = make(chan struct{}, 1)
+	ce.done = make(chan struct{})
+
+	ce.wg.Add(1)
+	go ce.loop()
+}
+
+func (ce *CollectorExporter) loop() {
+	defer ce.wg.Done()
+
+	t := time.NewTicker(ce.flushInterval())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ce.done:
+			return
+		case <-t.C:
+		case <-ce.flush:
+		}
+
+		ce.send()
+	}
+}
+
+func (ce *CollectorExporter) send() error {
+	ce.mu.Lock()
+	spans := ce.spans
+	ce.spans = nil
+	ce.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	p, err := json.Marshal(spans)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ce.client().Post(ce.URL, "application/json", bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: sending %d spans failed: %s", len(spans), resp.Status)
+	}
+
+	return nil
+}
+
+func (ce *CollectorExporter) batchSize() int {
+	if ce.BatchSize > 0 {
+		return ce.BatchSize
+	}
+	return 100
+}
+
+func (ce *CollectorExporter) flushInterval() time.Duration {
+	if ce.FlushInterval > 0 {
+		return ce.FlushInterval
+	}
+	return 5 * time.Second
+}
+
+func (ce *CollectorExporter) client() *http.Client {
+	if ce.Client != nil {
+		return ce.Client
+	}
+	return http.DefaultClient
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"` // in microseconds
+	Duration      int64             `json:"duration"`  // in microseconds
+	LocalEndpoint map[string]string `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+func newZipkinSpan(s *Span) *zipkinSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zs := &zipkinSpan{
+		TraceID:   s.TraceID,
+		ID:        s.SpanID,
+		ParentID:  s.ParentID,
+		Name:      s.Name,
+		Timestamp: s.Start.UnixNano() / int64(time.Microsecond),
+		Duration:  int64(s.Duration / time.Microsecond),
+		LocalEndpoint: map[string]string{
+			"serviceName": s.Service,
+		},
+	}
+
+	if len(s.Tags) != 0 {
+		zs.Tags = make(map[string]string, len(s.Tags))
+
+		for k, v := range s.Tags {
+			zs.Tags[k] = v
+		}
+	}
+
+	return zs
+}
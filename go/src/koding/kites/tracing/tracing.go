@@ -0,0 +1,243 @@
+// Package tracing provides span-based request tracing for kites.
+//
+// Spans are propagated between kites with a SpanContext value, which
+// is sent as part of kite request arguments:
+//
+//	type Request struct {
+//		...
+//		Trace *tracing.SpanContext `json:"trace,omitempty"`
+//	}
+//
+// The callee continues the trace with:
+//
+//	ctx = tracing.WithRemote(ctx, req.Trace)
+//	span, ctx := tracing.StartSpan(ctx, "terraformer.apply")
+//	defer span.Finish()
+//
+// Finished spans are sent to an Exporter, which writes them to a local
+// file or to a Jaeger-compatible collector.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+// Span represents a single timed operation within a trace.
+//
+// All methods of Span are safe to call on a nil value, which
+// is returned by StartSpan when tracing is disabled.
+type Span struct {
+	TraceID  string            `json:"traceId"`
+	SpanID   string            `json:"spanId"`
+	ParentID string            `json:"parentId,omitempty"`
+	Service  string            `json:"service"`
+	Name     string            `json:"name"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+	Tags     map[string]string `json:"tags,omitempty"`
+
+	tracer *Tracer
+	mu     sync.Mutex
+	done   bool
+}
+
+// Context gives the span context, which is used to continue the trace
+// in a remote process.
+func (s *Span) Context() *SpanContext {
+	if s == nil {
+		return nil
+	}
+
+	return &SpanContext{
+		TraceID: s.TraceID,
+		SpanID:  s.SpanID,
+	}
+}
+
+// SetTag sets the value of the given tag.
+func (s *Span) SetTag(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.Tags == nil {
+		s.Tags = make(map[string]string)
+	}
+	s.Tags[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed, if err is non-nil.
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetTag("error", err.Error())
+	}
+}
+
+// Finish ends the span and sends it to the exporter.
+//
+// Calling Finish more than once is a nop.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.Duration = time.Now().Sub(s.Start)
+	s.mu.Unlock()
+
+	s.tracer.export(s)
+}
+
+// Exporter writes finished spans.
+type Exporter interface {
+	Export(*Span) error
+	Close() error
+}
+
+// Tracer creates spans for a single service.
+type Tracer struct {
+	Service  string   // name of the service, e.g. "kloud"
+	Exporter Exporter // if nil, tracing is disabled
+
+	// Log is used to report export failures; if nil, failures are ignored.
+	Log func(format string, args ...interface{})
+}
+
+// DefaultTracer is used by StartSpan for new traces, or when
+// the trace was started in a remote process.
+//
+// It is configured by the kite main, e.g.:
+//
+//	tracing.DefaultTracer.Service = "kloud"
+//	tracing.DefaultTracer.Exporter = exporter
+var DefaultTracer = &Tracer{}
+
+// Enabled tells whether the tracer exports spans.
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.Exporter != nil
+}
+
+// StartSpan creates a new span, which is a child of the span found
+// in the ctx - either local or remote one. If ctx holds no span,
+// a new trace is started.
+//
+// The returned context carries the new span.
+//
+// If the tracer is disabled, StartSpan returns nil span and
+// unmodified ctx.
+func (t *Tracer) StartSpan(ctx context.Context, name string) (*Span, context.Context) {
+	if !t.Enabled() {
+		return nil, ctx
+	}
+
+	s := &Span{
+		SpanID:  newID(8),
+		Service: t.Service,
+		Name:    name,
+		Start:   time.Now(),
+		tracer:  t,
+	}
+
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+	} else {
+		s.TraceID = newID(16)
+	}
+
+	return s, context.WithValue(ctx, spanKey, s)
+}
+
+// Close flushes and closes the exporter.
+func (t *Tracer) Close() error {
+	if !t.Enabled() {
+		return nil
+	}
+
+	return t.Exporter.Close()
+}
+
+func (t *Tracer) export(s *Span) {
+	if err := t.Exporter.Export(s); err != nil && t.Log != nil {
+		t.Log("failure exporting %q span: %s", s.Name, err)
+	}
+}
+
+// StartSpan creates a new span with the tracer of the parent span
+// found in ctx, or with the DefaultTracer otherwise.
+func StartSpan(ctx context.Context, name string) (*Span, context.Context) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.tracer.StartSpan(ctx, name)
+	}
+
+	return DefaultTracer.StartSpan(ctx, name)
+}
+
+var (
+	spanKey struct {
+		byte `key:"tracingSpan"`
+	}
+	remoteKey struct {
+		byte `key:"tracingRemote"`
+	}
+)
+
+// WithRemote gives a context, which continues the trace
+// started in a remote process.
+//
+// If sc is nil, the ctx is returned unmodified.
+func WithRemote(ctx context.Context, sc *SpanContext) context.Context {
+	if sc == nil || sc.TraceID == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// SpanFromContext gives a local span stored in the ctx.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey).(*Span)
+	return s
+}
+
+// FromContext gives a span context of the current span, which is
+// meant to be sent to a remote process in order to continue the
+// trace there.
+//
+// It returns nil, if ctx holds neither local nor remote span.
+func FromContext(ctx context.Context) *SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.Context()
+	}
+
+	sc, _ := ctx.Value(remoteKey).(*SpanContext)
+	return sc
+}
+
+func newID(n int) string {
+	p := make([]byte, n)
+
+	if _, err := rand.Read(p); err != nil {
+		panic("tracing: unable to read random bytes: " + err.Error())
+	}
+
+	return hex.EncodeToString(p)
+}
@@ -0,0 +1,208 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"koding/kites/tracing"
+
+	"golang.org/x/net/context"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*tracing.Span
+}
+
+func (r *recorder) Export(s *tracing.Span) error {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func TestStartSpan(t *testing.T) {
+	rec := &recorder{}
+	tr := &tracing.Tracer{Service: "kloud", Exporter: rec}
+
+	root, ctx := tr.StartSpan(context.Background(), "stack.apply")
+	child, _ := tracing.StartSpan(ctx, "terraformer.plan")
+
+	child.SetError(errors.New("plan failed"))
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(rec.spans))
+	}
+
+	if child.TraceID != root.TraceID {
+		t.Fatalf("got %q, want %q trace ID", child.TraceID, root.TraceID)
+	}
+
+	if child.ParentID != root.SpanID {
+		t.Fatalf("got %q, want %q parent ID", child.ParentID, root.SpanID)
+	}
+
+	if child.Service != "kloud" {
+		t.Fatalf("got %q, want %q service", child.Service, "kloud")
+	}
+
+	if child.Tags["error"] != "plan failed" {
+		t.Fatalf("got %q, want %q error tag", child.Tags["error"], "plan failed")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	rec := &recorder{}
+	tr := &tracing.Tracer{Service: "terraformer", Exporter: rec}
+
+	sc := &tracing.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	}
+
+	ctx := tracing.WithRemote(context.Background(), sc)
+
+	s, ctx := tr.StartSpan(ctx, "terraformer.apply")
+	s.Finish()
+
+	if s.TraceID != sc.TraceID || s.ParentID != sc.SpanID {
+		t.Fatalf("got %+v, want continuation of %+v", s, sc)
+	}
+
+	if got := tracing.FromContext(ctx); got.SpanID != s.SpanID {
+		t.Fatalf("got %q, want %q span ID", got.SpanID, s.SpanID)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	sc := &tracing.SpanContext{TraceID: "a", SpanID: "b"}
+	ctx := tracing.WithRemote(context.Background(), sc)
+
+	s, ctx := (&tracing.Tracer{}).StartSpan(ctx, "klient.dial")
+
+	if s != nil {
+		t.Fatalf("got %+v, want nil span", s)
+	}
+
+	// Nil span must be safe to use.
+	s.SetTag("kiteID", "123")
+	s.Finish()
+
+	// The remote context must still be propagated.
+	if got := tracing.FromContext(ctx); got != sc {
+		t.Fatalf("got %+v, want %+v", got, sc)
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "spans.json")
+
+	e, err := tracing.NewExporter(file)
+	if err != nil {
+		t.Fatalf("NewExporter()=%s", err)
+	}
+
+	tr := &tracing.Tracer{Service: "kd", Exporter: e}
+
+	s, _ := tr.StartSpan(context.Background(), "stack.create")
+	s.SetTag("team", "koding")
+	s.Finish()
+
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close()=%s", err)
+	}
+
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("ReadFile()=%s", err)
+	}
+
+	var got tracing.Span
+
+	if err := json.Unmarshal(p, &got); err != nil {
+		t.Fatalf("Unmarshal()=%s", err)
+	}
+
+	if got.SpanID != s.SpanID || got.Name != "stack.create" || got.Tags["team"] != "koding" {
+		t.Fatalf("got %+v, want %+v", &got, s)
+	}
+}
+
+func TestCollectorExporter(t *testing.T) {
+	var mu sync.Mutex
+	var got []map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []map[string]interface{}
+
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		got = append(got, spans...)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	e, err := tracing.NewExporter(srv.URL + "/api/v2/spans")
+	if err != nil {
+		t.Fatalf("NewExporter()=%s", err)
+	}
+
+	if _, ok := e.(*tracing.CollectorExporter); !ok {
+		t.Fatalf("got %T, want *tracing.CollectorExporter", e)
+	}
+
+	tr := &tracing.Tracer{Service: "kloud", Exporter: e}
+
+	root, ctx := tr.StartSpan(context.Background(), "stack.apply")
+	child, _ := tracing.StartSpan(ctx, "klient.dial")
+	child.Finish()
+	root.Finish()
+
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close()=%s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(got) != 2 {
+		t.Fatalf("got %d spans, want 2", len(got))
+	}
+
+	if got[0]["parentId"] != root.SpanID || got[0]["traceId"] != root.TraceID {
+		t.Fatalf("got %+v, want child of %q", got[0], root.SpanID)
+	}
+
+	ep, _ := got[1]["localEndpoint"].(map[string]interface{})
+	if ep["serviceName"] != "kloud" {
+		t.Fatalf("got %+v, want kloud service", ep)
+	}
+
+	if got[1]["name"] != "stack.apply" {
+		t.Fatalf("got %q, want %q", got[1]["name"], "stack.apply")
+	}
+}
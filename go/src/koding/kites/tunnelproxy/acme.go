@@ -0,0 +1,562 @@
+package tunnelproxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/koding/logging"
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	defaultRenewBefore   = 30 * 24 * time.Hour
+	defaultRenewInterval = 1 * time.Hour
+	defaultIssueTimeout  = 5 * time.Minute
+	defaultCertLog       = logging.NewCustom("certmanager", false)
+)
+
+// CertManager obtains TLS certificates for custom domains from an
+// RFC 8555 ACME certificate authority, using http-01 challenges,
+// and keeps them renewed.
+//
+// Challenge requests are served by a handler returned by HTTPHandler,
+// which must be reachable on port 80 of each managed domain.
+type CertManager struct {
+	// DirectoryURL is an ACME directory endpoint.
+	//
+	// If empty, acme.LetsEncryptURL is used.
+	DirectoryURL string
+
+	// Email is an optional contact address of the ACME account.
+	Email string
+
+	// Dir, when non-empty, is a directory where account key and issued
+	// certificates are cached, so they survive server restarts.
+	Dir string
+
+	// RenewBefore tells how long before expiration a certificate
+	// is renewed.
+	//
+	// If zero, 30 days is used.
+	RenewBefore time.Duration
+
+	// HTTPClient, when non-nil, is used for communicating
+	// with the ACME server.
+	HTTPClient *http.Client
+
+	Log logging.Logger
+
+	mu      sync.Mutex
+	key     *ecdsa.PrivateKey // ACME account key
+	client  *acme.Client
+	certs   map[string]*tls.Certificate // maps domain to its certificate
+	domains map[string]struct{}         // managed domains
+	tokens  map[string]string           // maps challenge path to key authorization
+	pending map[string]chan struct{}    // maps domain to in-flight issuance
+}
+
+func (m *CertManager) init() {
+	if m.certs == nil {
+		m.certs = make(map[string]*tls.Certificate)
+		m.domains = make(map[string]struct{})
+		m.tokens = make(map[string]string)
+		m.pending = make(map[string]chan struct{})
+	}
+}
+
+func (m *CertManager) log() logging.Logger {
+	if m.Log != nil {
+		return m.Log
+	}
+	return defaultCertLog
+}
+
+func (m *CertManager) renewBefore() time.Duration {
+	if m.RenewBefore > 0 {
+		return m.RenewBefore
+	}
+	return defaultRenewBefore
+}
+
+// Manage starts managing a certificate for the given domain.
+//
+// If there is no valid certificate for the domain, it is issued
+// in the background.
+func (m *CertManager) Manage(domain string) {
+	m.mu.Lock()
+	m.init()
+	m.domains[domain] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultIssueTimeout)
+		defer cancel()
+
+		if err := m.Issue(ctx, domain); err != nil {
+			m.log().Error("failed to issue certificate for %q: %s", domain, err)
+		}
+	}()
+}
+
+// Remove stops managing a certificate for the given domain.
+//
+// Cached certificate is kept, so it is reused when
+// the domain gets managed again.
+func (m *CertManager) Remove(domain string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.init()
+	delete(m.domains, domain)
+}
+
+// Issue obtains a certificate for the given domain, unless
+// a cached one is valid and not due for renewal.
+//
+// The domain becomes managed by m.
+func (m *CertManager) Issue(ctx context.Context, domain string) error {
+	m.mu.Lock()
+	m.init()
+	m.domains[domain] = struct{}{}
+	m.mu.Unlock()
+
+	if cert := m.cert(domain); cert != nil && !m.due(cert) {
+		return nil
+	}
+
+	return m.issue(ctx, domain)
+}
+
+// Renew renews all managed certificates that are going
+// to expire sooner than RenewBefore.
+//
+// Each certificate is given up to 5 minutes to get issued,
+// unless ctx is done sooner.
+func (m *CertManager) Renew(ctx context.Context) error {
+	m.mu.Lock()
+	m.init()
+	domains := make([]string, 0, len(m.domains))
+	for domain := range m.domains {
+		domains = append(domains, domain)
+	}
+	m.mu.Unlock()
+
+	var err error
+
+	for _, domain := range domains {
+		if cert := m.cert(domain); cert != nil && !m.due(cert) {
+			continue
+		}
+
+		m.log().Info("renewing certificate for %q", domain)
+
+		if e := m.renew(ctx, domain); e != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %s", domain, e))
+		}
+	}
+
+	return err
+}
+
+func (m *CertManager) renew(ctx context.Context, domain string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultIssueTimeout)
+	defer cancel()
+
+	return m.issue(ctx, domain)
+}
+
+func (m *CertManager) renewLoop() {
+	t := time.NewTicker(defaultRenewInterval)
+	defer t.Stop()
+
+	for range t.C {
+		if err := m.Renew(context.Background()); err != nil {
+			m.log().Error("failed to renew certificates: %s", err)
+		}
+	}
+}
+
+// GetCertificate looks up a certificate for the server name
+// requested by TLS client. It is used as tls.Config.GetCertificate.
+func (m *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	domain := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	if domain == "" {
+		return nil, errors.New("missing server name")
+	}
+
+	m.mu.Lock()
+	m.init()
+	_, ok := m.domains[domain]
+	cert := m.certs[domain]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("domain %q is not registered", domain)
+	}
+
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate available for %q", domain)
+	}
+
+	return cert, nil
+}
+
+// HTTPHandler gives a handler that responds to http-01 challenge
+// requests issued by the ACME server.
+//
+// All other requests are passed to the fallback handler. If fallback
+// is nil, they are replied with 404.
+func (m *CertManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			m.mu.Lock()
+			auth, ok := m.tokens[r.URL.Path]
+			m.mu.Unlock()
+
+			if ok {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte(auth))
+				return
+			}
+		}
+
+		if fallback == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+func (m *CertManager) issue(ctx context.Context, domain string) error {
+	m.mu.Lock()
+	if done, ok := m.pending[domain]; ok {
+		m.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if m.cert(domain) == nil {
+			return fmt.Errorf("failed to issue certificate for %q", domain)
+		}
+
+		return nil
+	}
+
+	done := make(chan struct{})
+	m.pending[domain] = done
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, domain)
+		m.mu.Unlock()
+
+		close(done)
+	}()
+
+	cert, err := m.obtain(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.certs[domain] = cert
+	m.mu.Unlock()
+
+	m.log().Info("issued certificate for %q, valid until %s", domain, cert.Leaf.NotAfter)
+
+	if err := m.save(domain, cert); err != nil {
+		m.log().Warning("unable to cache certificate for %q: %s", domain, err)
+	}
+
+	return nil
+}
+
+func (m *CertManager) obtain(ctx context.Context, domain string) (*tls.Certificate, error) {
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+
+		if authz.Status != acme.StatusPending {
+			continue
+		}
+
+		if err := m.fulfill(ctx, client, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCert(domain, der, key)
+}
+
+func (m *CertManager) fulfill(ctx context.Context, client *acme.Client, authz *acme.Authorization) error {
+	var chal *acme.Challenge
+
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+
+	if chal == nil {
+		return fmt.Errorf("no http-01 challenge offered for %q", authz.Identifier.Value)
+	}
+
+	auth, err := client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+
+	path := client.HTTP01ChallengePath(chal.Token)
+
+	m.mu.Lock()
+	m.tokens[path] = auth
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.tokens, path)
+		m.mu.Unlock()
+	}()
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return err
+	}
+
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+func (m *CertManager) acmeClient(ctx context.Context) (*acme.Client, error) {
+	m.mu.Lock()
+	if m.client != nil {
+		m.mu.Unlock()
+		return m.client, nil
+	}
+
+	if m.key == nil {
+		key, err := m.accountKey()
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+
+		m.key = key
+	}
+
+	client := &acme.Client{
+		Key:          m.key,
+		DirectoryURL: m.DirectoryURL,
+		HTTPClient:   m.HTTPClient,
+	}
+	m.mu.Unlock()
+
+	var account acme.Account
+
+	if m.Email != "" {
+		account.Contact = []string{"mailto:" + m.Email}
+	}
+
+	// Concurrent registrations use the same account key,
+	// so it is safe for them to race.
+	_, err := client.Register(ctx, &account, acme.AcceptTOS)
+	if err == acme.ErrAccountAlreadyExists {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to register ACME account: %s", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.client == nil {
+		m.client = client
+	}
+
+	return m.client, nil
+}
+
+func (m *CertManager) accountKey() (*ecdsa.PrivateKey, error) {
+	file := filepath.Join(m.Dir, "account.key")
+
+	if m.Dir != "" {
+		if p, err := ioutil.ReadFile(file); err == nil {
+			if block, _ := pem.Decode(p); block != nil {
+				return x509.ParseECPrivateKey(block.Bytes)
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Dir != "" {
+		p, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeFile(file, p); err != nil {
+			m.log().Warning("unable to cache ACME account key: %s", err)
+		}
+	}
+
+	return key, nil
+}
+
+// cert gives a certificate for the given domain, looking it up
+// in memory first and in the cache directory next.
+func (m *CertManager) cert(domain string) *tls.Certificate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cert, ok := m.certs[domain]; ok {
+		return cert
+	}
+
+	if m.Dir == "" {
+		return nil
+	}
+
+	p, err := ioutil.ReadFile(m.certFile(domain))
+	if err != nil {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(p, p)
+	if err != nil {
+		m.log().Warning("ignoring invalid cached certificate for %q: %s", domain, err)
+		return nil
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil
+	}
+
+	m.certs[domain] = &cert
+
+	return &cert
+}
+
+func (m *CertManager) due(cert *tls.Certificate) bool {
+	return time.Now().Add(m.renewBefore()).After(cert.Leaf.NotAfter)
+}
+
+func (m *CertManager) save(domain string, cert *tls.Certificate) error {
+	if m.Dir == "" {
+		return nil
+	}
+
+	p, err := encodeKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	for _, der := range cert.Certificate {
+		p = append(p, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	return writeFile(m.certFile(domain), p)
+}
+
+func (m *CertManager) certFile(domain string) string {
+	return filepath.Join(m.Dir, domain+".pem")
+}
+
+func newCert(domain string, der [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, errors.New("no certificate returned by ACME server")
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := leaf.VerifyHostname(domain); err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	p, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p}), nil
+}
+
+func writeFile(file string, p []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, p, 0600)
+}
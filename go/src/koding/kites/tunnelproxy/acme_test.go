@@ -0,0 +1,458 @@
+package tunnelproxy_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/kites/tunnelproxy"
+	"koding/kites/tunnelproxy/acmetest"
+
+	"github.com/koding/kite"
+	"github.com/koding/kite/dnode"
+	"github.com/koding/logging"
+)
+
+// testCA is an ACME server certificates are issued by in tests.
+//
+// By default it is an in-process acmetest.Server. Tests can run against
+// a Pebble ACME server instead:
+//
+//	pebble-challtestsrv -defaultIPv4 127.0.0.1 -defaultIPv6 "" &
+//	pebble -config test/config/pebble-config.json -dnsserver 127.0.0.1:8053 &
+//
+//	export PEBBLE_DIRECTORY=https://127.0.0.1:14000/dir
+//	export PEBBLE_CA=$GOPATH/src/github.com/letsencrypt/pebble/test/certs/pebble.minica.pem
+//
+// Pebble validates http-01 challenges by connecting to port 5002
+// of the challenged domain, which resolves to 127.0.0.1. Use the
+// PEBBLE_HTTP_ADDR variable if the httpPort of Pebble is different.
+type testCA struct {
+	Directory  string
+	HTTPAddr   string
+	HTTPClient *http.Client
+
+	fake *acmetest.Server // nil when Pebble is used
+}
+
+func newTestCA(t *testing.T) *testCA {
+	dir := os.Getenv("PEBBLE_DIRECTORY")
+	if dir == "" {
+		fake, err := acmetest.NewServer()
+		if err != nil {
+			t.Fatalf("NewServer()=%s", err)
+		}
+
+		return &testCA{
+			Directory: fake.URL,
+			fake:      fake,
+		}
+	}
+
+	ca := &testCA{
+		Directory:  dir,
+		HTTPAddr:   os.Getenv("PEBBLE_HTTP_ADDR"),
+		HTTPClient: http.DefaultClient,
+	}
+
+	if ca.HTTPAddr == "" {
+		ca.HTTPAddr = "127.0.0.1:5002"
+	}
+
+	if file := os.Getenv("PEBBLE_CA"); file != "" {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile()=%s", err)
+		}
+
+		roots := x509.NewCertPool()
+
+		if !roots.AppendCertsFromPEM(pem) {
+			t.Fatalf("no certificates found in %q", file)
+		}
+
+		ca.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			},
+		}
+	}
+
+	return ca
+}
+
+// serve serves the handler on the address the CA sends
+// http-01 challenge requests to.
+func (ca *testCA) serve(t *testing.T, h http.Handler) (stop func()) {
+	addr := ca.HTTPAddr
+	if ca.fake != nil {
+		addr = "127.0.0.1:0"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen()=%s", err)
+	}
+
+	if ca.fake != nil {
+		ca.fake.HTTPAddr = l.Addr().String()
+	}
+
+	go http.Serve(l, h)
+
+	return func() { l.Close() }
+}
+
+func (ca *testCA) Close() {
+	if ca.fake != nil {
+		ca.fake.Close()
+	}
+}
+
+// resolver is a fake DNS resolver, which keeps CNAME
+// and TXT records in memory.
+type resolver struct {
+	mu     sync.Mutex
+	cnames map[string]string
+	txts   map[string][]string
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		cnames: make(map[string]string),
+		txts:   make(map[string][]string),
+	}
+}
+
+func (r *resolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cname, ok := r.cnames[host]; ok {
+		return cname + ".", nil
+	}
+
+	return "", errors.New("no such host")
+}
+
+func (r *resolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if txts, ok := r.txts[name]; ok {
+		return txts, nil
+	}
+
+	return nil, errors.New("no such host")
+}
+
+func (r *resolver) cname(host, cname string) {
+	r.mu.Lock()
+	r.cnames[host] = cname
+	r.mu.Unlock()
+}
+
+func (r *resolver) txt(name string, txts ...string) {
+	r.mu.Lock()
+	r.txts[name] = txts
+	r.mu.Unlock()
+}
+
+func TestCertManager(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.Close()
+
+	dir, err := ioutil.TempDir("", "certmanager")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := &tunnelproxy.CertManager{
+		DirectoryURL: ca.Directory,
+		HTTPClient:   ca.HTTPClient,
+		Dir:          dir,
+		RenewBefore:  time.Hour,
+	}
+
+	stop := ca.serve(t, m.HTTPHandler(nil))
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := m.Issue(ctx, "example.com"); err != nil {
+		t.Fatalf("Issue()=%s", err)
+	}
+
+	cert := getCertificate(t, m, "example.com")
+
+	// Certificate not due for renewal is reused.
+	if err := m.Issue(ctx, "example.com"); err != nil {
+		t.Fatalf("Issue()=%s", err)
+	}
+
+	if got := getCertificate(t, m, "example.com"); got.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Fatalf("got %s serial, want reused %s", got.Leaf.SerialNumber, cert.Leaf.SerialNumber)
+	}
+
+	// Certificate is cached on disk.
+	cached := &tunnelproxy.CertManager{
+		DirectoryURL: ca.Directory,
+		HTTPClient:   ca.HTTPClient,
+		Dir:          dir,
+		RenewBefore:  time.Hour,
+	}
+
+	if err := cached.Issue(ctx, "example.com"); err != nil {
+		t.Fatalf("Issue()=%s", err)
+	}
+
+	if got := getCertificate(t, cached, "example.com"); got.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Fatalf("got %s serial, want cached %s", got.Leaf.SerialNumber, cert.Leaf.SerialNumber)
+	}
+
+	// Certificate expiring within RenewBefore is renewed.
+	m.RenewBefore = cert.Leaf.NotAfter.Sub(time.Now()) + time.Hour
+
+	if err := m.Renew(ctx); err != nil {
+		t.Fatalf("Renew()=%s", err)
+	}
+
+	if got := getCertificate(t, m, "example.com"); got.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) == 0 {
+		t.Fatal("expected certificate to be renewed")
+	}
+
+	// Removed domains are not served.
+	m.Remove("example.com")
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err == nil {
+		t.Fatal("expected GetCertificate to fail for removed domain")
+	}
+
+	// Failed challenges are reported.
+	stop()
+
+	if err := m.Issue(ctx, "unreachable.example.com"); err == nil {
+		t.Fatal("expected Issue to fail when challenge is not served")
+	}
+}
+
+func TestServerCustomDomain(t *testing.T) {
+	r := newResolver()
+
+	s, err := tunnelproxy.NewServer(&tunnelproxy.ServerOptions{
+		BaseVirtualHost: "tunnel.koding.local",
+		ServerAddr:      "127.0.0.1",
+		MaxDomains:      2,
+		Resolver:        r,
+		Log:             logging.NewCustom("tunnelserver", testing.Verbose()),
+		Test:            true,
+	})
+	if err != nil {
+		t.Fatalf("NewServer()=%s", err)
+	}
+
+	reg := register(t, s, "user")
+
+	// Domains without ownership proof are rejected.
+	if _, err := s.RegisterServices(request(t, &tunnelproxy.RegisterServicesRequest{
+		Ident:   reg.Ident,
+		Domains: []string{"app.example.com"},
+	})); err == nil {
+		t.Fatal("expected registering domain without DNS records to fail")
+	}
+
+	r.cname("app.example.com", reg.VirtualHost)
+	r.txt("_koding-tunnel.api.example.com", "v=spf1 -all", reg.VirtualHost)
+
+	res := registerServices(t, s, &tunnelproxy.RegisterServicesRequest{
+		Ident:   reg.Ident,
+		Domains: []string{"App.Example.com.", "api.example.com", "foo.user.tunnel.koding.local"},
+	})
+
+	if len(res.Domains) != 3 {
+		t.Fatalf("got %+v, want 3 domains", res.Domains)
+	}
+
+	for i, name := range []string{"app.example.com", "api.example.com"} {
+		if d := res.Domains[i]; d.Name != name || d.Err() != nil {
+			t.Fatalf("got %+v, want %s to be registered", d, name)
+		}
+	}
+
+	if d := res.Domains[2]; d.Err() == nil {
+		t.Fatalf("got %+v, want virtual host domain to be rejected", d)
+	}
+
+	// Number of domains per client is limited.
+	r.cname("www.example.com", reg.VirtualHost)
+
+	if _, err := s.RegisterServices(request(t, &tunnelproxy.RegisterServicesRequest{
+		Ident:   reg.Ident,
+		Domains: []string{"www.example.com"},
+	})); err == nil {
+		t.Fatal("expected registering domains over the limit to fail")
+	}
+
+	// Other clients can't take over registered domain, even
+	// if they point it to their virtual host.
+	other := register(t, s, "other")
+
+	r.txt("_koding-tunnel.app.example.com", other.VirtualHost)
+
+	if _, err := s.RegisterServices(request(t, &tunnelproxy.RegisterServicesRequest{
+		Ident:   other.Ident,
+		Domains: []string{"app.example.com"},
+	})); err == nil {
+		t.Fatal("expected registering domain of other client to fail")
+	}
+}
+
+func TestServerCustomDomainTLS(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.Close()
+
+	r := newResolver()
+
+	s, err := tunnelproxy.NewServer(&tunnelproxy.ServerOptions{
+		BaseVirtualHost: "tunnel.koding.local",
+		ServerAddr:      "127.0.0.1",
+		ACMEDirectory:   ca.Directory,
+		Resolver:        r,
+		Log:             logging.NewCustom("tunnelserver", testing.Verbose()),
+		Test:            true,
+	})
+	if err != nil {
+		t.Fatalf("NewServer()=%s", err)
+	}
+
+	s.Certs.HTTPClient = ca.HTTPClient
+
+	stop := ca.serve(t, s.Certs.HTTPHandler(s.Server))
+	defer stop()
+
+	reg := register(t, s, "user")
+
+	r.cname("app.example.com", reg.VirtualHost)
+
+	registerServices(t, s, &tunnelproxy.RegisterServicesRequest{
+		Ident:   reg.Ident,
+		Domains: []string{"app.example.com"},
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen()=%s", err)
+	}
+	defer l.Close()
+
+	go s.ServeTLS(l)
+
+	// Wait for the certificate to be issued in the background.
+	hello := &tls.ClientHelloInfo{ServerName: "app.example.com"}
+
+	for i := 0; ; i++ {
+		if _, err := s.Certs.GetCertificate(hello); err == nil {
+			break
+		}
+
+		if i == 300 {
+			t.Fatal("timed out waiting for certificate")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Pebble issues certificates from a random root,
+	// so only the leaf is verified.
+	client := &http.Client{
+		Transport: &http.Transport{
+			Dial: func(string, string) (net.Conn, error) {
+				return net.Dial("tcp", l.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	// The request is routed by SNI to the client's tunnel, which
+	// is not connected.
+	resp, err := client.Get("https://app.example.com/")
+	if err != nil {
+		t.Fatalf("Get()=%s", err)
+	}
+	defer resp.Body.Close()
+
+	if err := resp.TLS.PeerCertificates[0].VerifyHostname("app.example.com"); err != nil {
+		t.Fatalf("VerifyHostname()=%s", err)
+	}
+
+	p, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll()=%s", err)
+	}
+
+	if resp.StatusCode != http.StatusBadGateway || strings.Contains(string(p), "no virtual host") {
+		t.Fatalf("got %d (%s), want request to be routed to the client tunnel", resp.StatusCode, p)
+	}
+
+	// Unknown server names do not get a certificate.
+	if _, err := client.Get("https://unknown.example.com/"); err == nil {
+		t.Fatal("expected TLS handshake to fail for unknown domain")
+	}
+}
+
+func getCertificate(t *testing.T, m *tunnelproxy.CertManager, domain string) *tls.Certificate {
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	if err != nil {
+		t.Fatalf("GetCertificate()=%s", err)
+	}
+
+	if err := cert.Leaf.VerifyHostname(domain); err != nil {
+		t.Fatalf("VerifyHostname()=%s", err)
+	}
+
+	return cert
+}
+
+func register(t *testing.T, s *tunnelproxy.Server, username string) *tunnelproxy.RegisterResult {
+	v, err := s.Register(&kite.Request{Username: username})
+	if err != nil {
+		t.Fatalf("Register()=%s", err)
+	}
+
+	return v.(*tunnelproxy.RegisterResult)
+}
+
+func registerServices(t *testing.T, s *tunnelproxy.Server, req *tunnelproxy.RegisterServicesRequest) *tunnelproxy.RegisterServicesResult {
+	v, err := s.RegisterServices(request(t, req))
+	if err != nil {
+		t.Fatalf("RegisterServices()=%s", err)
+	}
+
+	return v.(*tunnelproxy.RegisterServicesResult)
+}
+
+func request(t *testing.T, v interface{}) *kite.Request {
+	p, err := json.Marshal([]interface{}{v})
+	if err != nil {
+		t.Fatalf("Marshal()=%s", err)
+	}
+
+	return &kite.Request{
+		Username: "user",
+		Args:     &dnode.Partial{Raw: p},
+	}
+}
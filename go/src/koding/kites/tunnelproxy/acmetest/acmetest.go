@@ -0,0 +1,798 @@
+// Package acmetest provides an in-memory ACME certificate authority,
+// which is used in tests as a local stand-in for Pebble or Let's Encrypt.
+//
+// The server implements the subset of RFC 8555 that is used by
+// golang.org/x/crypto/acme client: account registration, orders,
+// authorizations with http-01 challenges and certificate issuance.
+// Request signatures and nonces are not verified.
+package acmetest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Server is a fake ACME certificate authority.
+type Server struct {
+	// URL is the directory URL of the server.
+	URL string
+
+	// HTTPAddr is an address where http-01 challenge validation
+	// requests are sent to. The Host header of each request
+	// is set to the domain being validated.
+	//
+	// If empty, the domain is dialed on port 80.
+	HTTPAddr string
+
+	// Validity is a validity period of issued certificates.
+	//
+	// If zero, 90 days is used.
+	Validity time.Duration
+
+	// CA is a self-signed root certificate, which signs
+	// all issued certificates.
+	CA *x509.Certificate
+
+	srv   *httptest.Server
+	caKey *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	nonce    int
+	accounts []string // account thumbprints, account ID is an index
+	authz    []*authz // authz ID is an index
+	orders   []*order // order ID is an index
+	issued   int
+}
+
+type authz struct {
+	account int
+	domain  string
+	token   string
+	status  string
+	err     string
+}
+
+type order struct {
+	account int
+	domains []string
+	authz   []int
+	status  string
+	cert    []byte // DER-encoded certificate, once issued
+}
+
+// NewServer gives new started ACME server.
+func NewServer() (*Server, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "acmetest root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		CA:    ca,
+		caKey: key,
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL + "/directory"
+
+	return s, nil
+}
+
+// Roots gives a certificate pool, which can be used to verify
+// certificates issued by the server.
+func (s *Server) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.CA)
+	return pool
+}
+
+// Issued gives number of certificates issued by the server.
+func (s *Server) Issued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issued
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// request is a decoded JWS request.
+type request struct {
+	account int             // -1 for requests signed with a JWK
+	jwk     json.RawMessage // set for requests signed with a JWK
+	payload []byte          // empty for POST-as-GET requests
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.nonce++
+	w.Header().Set("Replay-Nonce", "nonce-"+strconv.Itoa(s.nonce))
+	s.mu.Unlock()
+
+	if r.Method == "HEAD" {
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	i := strings.IndexRune(path, '/')
+	if i == -1 {
+		i = len(path)
+	}
+
+	resource, id := path[:i], strings.TrimPrefix(path[i:], "/")
+
+	if resource == "directory" {
+		s.directory(w)
+		return
+	}
+
+	req, err := s.readJWS(r)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	if resource != "new-account" && req.account == -1 {
+		s.fail(w, http.StatusUnauthorized, "accountDoesNotExist", "request is not signed by an account")
+		return
+	}
+
+	switch resource {
+	case "new-nonce":
+	case "new-account":
+		s.newAccount(w, req)
+	case "account":
+		s.reply(w, http.StatusOK, map[string]string{"status": "valid"})
+	case "new-order":
+		s.newOrder(w, req)
+	case "order":
+		s.getOrder(w, req, id)
+	case "authz":
+		s.getAuthz(w, req, id)
+	case "challenge":
+		s.challenge(w, req, id)
+	case "finalize":
+		s.finalize(w, req, id)
+	case "cert":
+		s.cert(w, req, id)
+	default:
+		s.fail(w, http.StatusNotFound, "malformed", "resource not found: "+resource)
+	}
+}
+
+func (s *Server) directory(w http.ResponseWriter) {
+	s.reply(w, http.StatusOK, map[string]string{
+		"newNonce":   s.srv.URL + "/new-nonce",
+		"newAccount": s.srv.URL + "/new-account",
+		"newOrder":   s.srv.URL + "/new-order",
+		"revokeCert": s.srv.URL + "/revoke-cert",
+		"keyChange":  s.srv.URL + "/key-change",
+	})
+}
+
+func (s *Server) newAccount(w http.ResponseWriter, req *request) {
+	var acct struct {
+		OnlyReturnExisting bool `json:"onlyReturnExisting"`
+	}
+
+	if err := req.decode(&acct); err != nil {
+		s.fail(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	if req.jwk == nil {
+		s.fail(w, http.StatusBadRequest, "malformed", "new account request must be signed with a JWK")
+		return
+	}
+
+	thumbprint, err := jwkThumbprint(req.jwk)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "badPublicKey", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.accounts {
+		if t == thumbprint {
+			w.Header().Set("Location", s.accountURL(id))
+			s.reply(w, http.StatusOK, map[string]string{"status": "valid"})
+			return
+		}
+	}
+
+	if acct.OnlyReturnExisting {
+		s.fail(w, http.StatusBadRequest, "accountDoesNotExist", "account does not exist")
+		return
+	}
+
+	s.accounts = append(s.accounts, thumbprint)
+
+	w.Header().Set("Location", s.accountURL(len(s.accounts)-1))
+	s.reply(w, http.StatusCreated, map[string]string{"status": "valid"})
+}
+
+func (s *Server) newOrder(w http.ResponseWriter, req *request) {
+	var o struct {
+		Identifiers []struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		} `json:"identifiers"`
+	}
+
+	if err := req.decode(&o); err != nil {
+		s.fail(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	if len(o.Identifiers) == 0 {
+		s.fail(w, http.StatusBadRequest, "malformed", "no identifiers requested")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ord := &order{
+		account: req.account,
+		status:  acme.StatusPending,
+	}
+
+	for _, ident := range o.Identifiers {
+		if ident.Type != "dns" || ident.Value == "" {
+			s.fail(w, http.StatusBadRequest, "rejectedIdentifier", "invalid identifier")
+			return
+		}
+
+		n, err := s.newAuthz(req.account, ident.Value)
+		if err != nil {
+			s.fail(w, http.StatusInternalServerError, "serverInternal", err.Error())
+			return
+		}
+
+		ord.domains = append(ord.domains, ident.Value)
+		ord.authz = append(ord.authz, n)
+	}
+
+	s.orders = append(s.orders, ord)
+
+	id := len(s.orders) - 1
+
+	w.Header().Set("Location", s.orderURL(id))
+	s.reply(w, http.StatusCreated, s.wireOrder(id))
+}
+
+// newAuthz gives an authorization of the domain for the account,
+// reusing valid authorizations like real CAs do.
+func (s *Server) newAuthz(account int, domain string) (int, error) {
+	for id, z := range s.authz {
+		if z.account == account && z.domain == domain && z.status == acme.StatusValid {
+			return id, nil
+		}
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return 0, err
+	}
+
+	s.authz = append(s.authz, &authz{
+		account: account,
+		domain:  domain,
+		token:   base64.RawURLEncoding.EncodeToString(token),
+		status:  acme.StatusPending,
+	})
+
+	return len(s.authz) - 1, nil
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, req *request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookupOrder(req, id)
+	if !ok {
+		s.fail(w, http.StatusNotFound, "malformed", "order not found")
+		return
+	}
+
+	w.Header().Set("Location", s.orderURL(n))
+	s.reply(w, http.StatusOK, s.wireOrder(n))
+}
+
+func (s *Server) getAuthz(w http.ResponseWriter, req *request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookupAuthz(req, id)
+	if !ok {
+		s.fail(w, http.StatusNotFound, "malformed", "authorization not found")
+		return
+	}
+
+	s.reply(w, http.StatusOK, s.wireAuthz(n))
+}
+
+func (s *Server) challenge(w http.ResponseWriter, req *request, id string) {
+	s.mu.Lock()
+	n, ok := s.lookupAuthz(req, id)
+	var z authz
+	var keyAuth string
+	if ok {
+		z = *s.authz[n]
+		keyAuth = z.token + "." + s.accounts[z.account]
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		s.fail(w, http.StatusNotFound, "malformed", "challenge not found")
+		return
+	}
+
+	if z.status == acme.StatusPending {
+		status, errMsg := acme.StatusValid, ""
+
+		if err := s.validate(&z, keyAuth); err != nil {
+			status, errMsg = acme.StatusInvalid, err.Error()
+		}
+
+		s.mu.Lock()
+		s.authz[n].status = status
+		s.authz[n].err = errMsg
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	ch := s.wireAuthz(n)["challenges"]
+	s.mu.Unlock()
+
+	s.reply(w, http.StatusOK, ch.([]interface{})[0])
+}
+
+func (s *Server) validate(z *authz, keyAuth string) error {
+	addr := s.HTTPAddr
+	if addr == "" {
+		addr = z.domain
+	}
+
+	req, err := http.NewRequest("GET", "http://"+addr+"/.well-known/acme-challenge/"+z.token, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Host = z.domain
+
+	// Each validation dials the domain anew, like real CAs do.
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	p, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected challenge response status: %s", resp.Status)
+	}
+
+	if got := strings.TrimSpace(string(p)); got != keyAuth {
+		return fmt.Errorf("got %q key authorization, want %q", got, keyAuth)
+	}
+
+	return nil
+}
+
+func (s *Server) finalize(w http.ResponseWriter, req *request, id string) {
+	var f struct {
+		CSR string `json:"csr"`
+	}
+
+	if err := req.decode(&f); err != nil {
+		s.fail(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	p, err := base64.RawURLEncoding.DecodeString(f.CSR)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "badCSR", err.Error())
+		return
+	}
+
+	csr, err := x509.ParseCertificateRequest(p)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "badCSR", err.Error())
+		return
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		s.fail(w, http.StatusBadRequest, "badCSR", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookupOrder(req, id)
+	if !ok {
+		s.fail(w, http.StatusNotFound, "malformed", "order not found")
+		return
+	}
+
+	o := s.orders[n]
+
+	if status := s.orderStatus(o); status != acme.StatusReady {
+		s.fail(w, http.StatusForbidden, "orderNotReady", "order is "+status)
+		return
+	}
+
+	if !sameNames(csr.DNSNames, o.domains) {
+		s.fail(w, http.StatusBadRequest, "badCSR", "CSR names do not match the order")
+		return
+	}
+
+	validity := s.Validity
+	if validity == 0 {
+		validity = 90 * 24 * time.Hour
+	}
+
+	s.issued++
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(s.issued) + 1),
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, s.CA, csr.PublicKey, s.caKey)
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+
+	o.cert = der
+	o.status = acme.StatusValid
+
+	w.Header().Set("Location", s.orderURL(n))
+	s.reply(w, http.StatusOK, s.wireOrder(n))
+}
+
+func (s *Server) cert(w http.ResponseWriter, req *request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookupOrder(req, id)
+	if !ok || s.orders[n].cert == nil {
+		s.fail(w, http.StatusNotFound, "malformed", "certificate not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.orders[n].cert})
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.CA.Raw})
+}
+
+// orderStatus gives a status of the order, which becomes ready
+// once all its authorizations are valid.
+func (s *Server) orderStatus(o *order) string {
+	if o.status != acme.StatusPending {
+		return o.status
+	}
+
+	status := acme.StatusReady
+
+	for _, n := range o.authz {
+		switch s.authz[n].status {
+		case acme.StatusInvalid:
+			return acme.StatusInvalid
+		case acme.StatusPending:
+			status = acme.StatusPending
+		}
+	}
+
+	return status
+}
+
+func (s *Server) lookupOrder(req *request, id string) (int, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 0 || n >= len(s.orders) || s.orders[n].account != req.account {
+		return 0, false
+	}
+	return n, true
+}
+
+func (s *Server) lookupAuthz(req *request, id string) (int, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 0 || n >= len(s.authz) || s.authz[n].account != req.account {
+		return 0, false
+	}
+	return n, true
+}
+
+func (s *Server) accountURL(id int) string {
+	return s.srv.URL + "/account/" + strconv.Itoa(id)
+}
+
+func (s *Server) orderURL(id int) string {
+	return s.srv.URL + "/order/" + strconv.Itoa(id)
+}
+
+func (s *Server) wireOrder(id int) map[string]interface{} {
+	o := s.orders[id]
+
+	var (
+		idents []interface{}
+		urls   []string
+	)
+
+	for i, domain := range o.domains {
+		idents = append(idents, map[string]string{
+			"type":  "dns",
+			"value": domain,
+		})
+		urls = append(urls, s.srv.URL+"/authz/"+strconv.Itoa(o.authz[i]))
+	}
+
+	v := map[string]interface{}{
+		"status":         s.orderStatus(o),
+		"identifiers":    idents,
+		"authorizations": urls,
+		"finalize":       s.srv.URL + "/finalize/" + strconv.Itoa(id),
+	}
+
+	if o.cert != nil {
+		v["certificate"] = s.srv.URL + "/cert/" + strconv.Itoa(id)
+	}
+
+	return v
+}
+
+func (s *Server) wireAuthz(id int) map[string]interface{} {
+	z := s.authz[id]
+
+	ch := map[string]interface{}{
+		"type":   "http-01",
+		"url":    s.srv.URL + "/challenge/" + strconv.Itoa(id),
+		"token":  z.token,
+		"status": z.status,
+	}
+
+	if z.err != "" {
+		ch["error"] = map[string]interface{}{
+			"type":   "urn:ietf:params:acme:error:unauthorized",
+			"detail": z.err,
+		}
+	}
+
+	return map[string]interface{}{
+		"status": z.status,
+		"identifier": map[string]string{
+			"type":  "dns",
+			"value": z.domain,
+		},
+		"challenges": []interface{}{ch},
+	}
+}
+
+func (s *Server) reply(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) fail(w http.ResponseWriter, code int, typ, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":   "urn:ietf:params:acme:error:" + typ,
+		"detail": detail,
+		"status": code,
+	})
+}
+
+// readJWS decodes the JWS-encoded request body and looks up
+// the account, which the request is signed by.
+func (s *Server) readJWS(r *http.Request) (*request, error) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return nil, errors.New("invalid JWS: " + err.Error())
+	}
+
+	p, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return nil, errors.New("invalid JWS header: " + err.Error())
+	}
+
+	var hdr struct {
+		JWK json.RawMessage `json:"jwk"`
+		KID string          `json:"kid"`
+	}
+
+	if err := json.Unmarshal(p, &hdr); err != nil {
+		return nil, errors.New("invalid JWS header: " + err.Error())
+	}
+
+	req := &request{
+		account: -1,
+		jwk:     hdr.JWK,
+	}
+
+	if req.payload, err = base64.RawURLEncoding.DecodeString(jws.Payload); err != nil {
+		return nil, errors.New("invalid JWS payload: " + err.Error())
+	}
+
+	if hdr.KID != "" {
+		s.mu.Lock()
+		for id := range s.accounts {
+			if s.accountURL(id) == hdr.KID {
+				req.account = id
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		if req.account == -1 {
+			return nil, errors.New("unknown account: " + hdr.KID)
+		}
+	}
+
+	return req, nil
+}
+
+// decode decodes the request payload into v. Empty payloads
+// of POST-as-GET requests leave v untouched.
+func (req *request) decode(v interface{}) error {
+	if len(req.payload) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(req.payload, v)
+}
+
+// jwkThumbprint gives the RFC 7638 thumbprint of the JSON web key.
+func jwkThumbprint(p json.RawMessage) (string, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+
+	if err := json.Unmarshal(p, &jwk); err != nil {
+		return "", err
+	}
+
+	var pub crypto.PublicKey
+
+	switch jwk.Kty {
+	case "EC":
+		var curve elliptic.Curve
+
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", errors.New("unsupported curve: " + jwk.Crv)
+		}
+
+		x, err := decodeInt(jwk.X)
+		if err != nil {
+			return "", err
+		}
+
+		y, err := decodeInt(jwk.Y)
+		if err != nil {
+			return "", err
+		}
+
+		pub = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "RSA":
+		n, err := decodeInt(jwk.N)
+		if err != nil {
+			return "", err
+		}
+
+		e, err := decodeInt(jwk.E)
+		if err != nil {
+			return "", err
+		}
+
+		pub = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	default:
+		return "", errors.New("unsupported key type: " + jwk.Kty)
+	}
+
+	return acme.JWKThumbprint(pub)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	p, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(p), nil
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	names := make(map[string]struct{}, len(a))
+	for _, name := range a {
+		names[name] = struct{}{}
+	}
+
+	for _, name := range b {
+		if _, ok := names[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -75,6 +75,10 @@ type ClientOptions struct {
 	// Services is sent with RegisterRequest.
 	Services map[string]*Tunnel
 
+	// Domains are custom domains routed to the tunnel. They are
+	// registered each time the client connects to a tunnel server.
+	Domains []string
+
 	// PublicIP of the client.
 	PublicIP string
 
@@ -284,6 +288,7 @@ func (c *Client) eventloop() {
 		handlePending chan struct{}
 		ident         string
 		pending       = make(map[string]*Tunnel)
+		domains       []string // pending domains
 		backoff       = backoff.NewExponentialBackOff()
 	)
 
@@ -337,6 +342,10 @@ func (c *Client) eventloop() {
 
 				c.mu.Unlock()
 
+				// Domains are always deleted by tunnel server
+				// when client disconnects.
+				domains = c.opts.Domains
+
 				// when connected, start registering services
 				handlePending = make(chan struct{}, 1)
 				handlePending <- struct{}{}
@@ -369,13 +378,14 @@ func (c *Client) eventloop() {
 			}
 
 		case <-handlePending:
-			if len(pending) == 0 {
+			if len(pending) == 0 && len(domains) == 0 {
 				break
 			}
 
 			req := &RegisterServicesRequest{
 				Ident:    ident,
 				Services: pending,
+				Domains:  domains,
 			}
 
 			c.opts.Log.Debug("handling service registration: %+v", pending)
@@ -424,6 +434,13 @@ func (c *Client) eventloop() {
 			}
 			c.mu.Unlock()
 
+			domains = nil
+			for _, d := range resp.Domains {
+				if d.Err() != nil {
+					domains = append(domains, d.Name)
+				}
+			}
+
 			if err := resp.Err(); err != nil {
 				c.opts.Log.Error("failed to handle all services, retrying: %s", err)
 				go retry()
@@ -1,6 +1,7 @@
 package tunnelproxy
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -19,6 +20,7 @@ import (
 	"koding/artifact"
 	"koding/kites/common"
 	konfig "koding/kites/config"
+	"koding/kites/health"
 	"koding/kites/kloud/pkg/dnsclient"
 	"koding/kites/kloud/utils"
 	"koding/kites/metrics"
@@ -54,10 +56,51 @@ type ServerOptions struct {
 	Test         bool   `json:"test,omitempty"`
 	NoCNAME      bool   `json:"noCNAME,omitempty"`
 
+	// TLS termination for custom domains.
+	//
+	// Certificates are issued only when ACMEDirectory is non-empty.
+	TLSPort       int    `json:"tlsPort,omitempty"`
+	ACMEDirectory string `json:"acmeDirectory,omitempty"`
+	ACMEEmail     string `json:"acmeEmail,omitempty"`
+	CertDir       string `json:"certDir,omitempty"`
+
+	// MaxDomains limits the number of custom domains a single client
+	// can register, as each of them requires issuing a certificate.
+	//
+	// If zero, 5 is used.
+	MaxDomains int `json:"maxDomains,omitempty"`
+
+	// Resolver is used to verify ownership of custom domains.
+	//
+	// If nil, net.DefaultResolver is used.
+	Resolver Resolver `json:"-"`
+
 	Log     logging.Logger    `json:"-"`
 	Metrics *dogstatsd.Client `json:"-"`
 }
 
+func (opts *ServerOptions) maxDomains() int {
+	if opts.MaxDomains > 0 {
+		return opts.MaxDomains
+	}
+
+	return 5
+}
+
+func (opts *ServerOptions) resolver() Resolver {
+	if opts.Resolver != nil {
+		return opts.Resolver
+	}
+
+	return net.DefaultResolver
+}
+
+// Resolver looks up DNS records, which prove ownership of a custom domain.
+type Resolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
 func (opts *ServerOptions) registerURL() string {
 	if opts.RegisterURL != "" {
 		return opts.RegisterURL
@@ -79,6 +122,7 @@ func (opts *ServerOptions) kontrolURL() string {
 type Server struct {
 	Server *tunnel.Server
 	DNS    *dnsclient.Route53
+	Certs  *CertManager // nil when ACME is not configured
 
 	opts      *ServerOptions
 	record    *dnsclient.Record
@@ -86,7 +130,7 @@ type Server struct {
 	privateIP string
 
 	mu       sync.Mutex        // protects idents, services and tunnels
-	idents   map[string]string // maps vhost to ident
+	idents   map[string]string // maps vhost and custom domains to ident
 	services map[int]net.Listener
 	tunnels  *Tunnels
 
@@ -238,6 +282,17 @@ func NewServer(opts *ServerOptions) (*Server, error) {
 		}
 	}
 
+	if s.opts.ACMEDirectory != "" {
+		s.Certs = &CertManager{
+			DirectoryURL: s.opts.ACMEDirectory,
+			Email:        s.opts.ACMEEmail,
+			Dir:          s.opts.CertDir,
+			Log:          s.opts.Log.New("certmanager"),
+		}
+
+		go s.Certs.renewLoop()
+	}
+
 	return s, nil
 }
 
@@ -277,6 +332,27 @@ type RegisterResult struct {
 type RegisterServicesRequest struct {
 	Ident    string             `json:"identifier"`
 	Services map[string]*Tunnel `json:"services"`
+
+	// Domains are custom domains, which are routed to the client's
+	// virtual host. Each domain must resolve to the tunnel server.
+	//
+	// If the server has ACME configured, a TLS certificate
+	// is issued for each of the domains.
+	Domains []string `json:"domains,omitempty"`
+}
+
+// Domain describes a custom domain registered for a client.
+type Domain struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// Err
+func (d *Domain) Err() error {
+	if d.Error != "" {
+		return errors.New(d.Error)
+	}
+	return nil
 }
 
 func toList(services map[string]*Tunnel, vhost string) []*Tunnel {
@@ -301,7 +377,7 @@ func (req *RegisterServicesRequest) Valid() error {
 		return errors.New("empty identifier")
 	}
 
-	if len(req.Services) == 0 {
+	if len(req.Services) == 0 && len(req.Domains) == 0 {
 		return errors.New("empty services")
 	}
 
@@ -312,6 +388,7 @@ func (req *RegisterServicesRequest) Valid() error {
 type RegisterServicesResult struct {
 	VirtualHost string             `json:"virtualHost"`
 	Services    map[string]*Tunnel `json:"services"`
+	Domains     []*Domain          `json:"domains,omitempty"`
 }
 
 // Err
@@ -322,6 +399,12 @@ func (res *RegisterServicesResult) Err() (err error) {
 		}
 	}
 
+	for _, d := range res.Domains {
+		if e := d.Err(); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+
 	return err
 }
 
@@ -376,10 +459,132 @@ func (s *Server) delClient(ident, vhost string) {
 		l.Close() // TODO(rjeczalik): add 10m grace period for client reconnections
 	}
 
+	for domain, id := range s.idents {
+		if id != ident || domain == vhost {
+			continue
+		}
+
+		s.opts.Log.Debug("%s: deleting domain=%q", ident, domain)
+
+		s.Server.DeleteHost(domain)
+		delete(s.idents, domain)
+
+		if s.Certs != nil {
+			s.Certs.Remove(domain)
+		}
+	}
+
 	s.tunnels.delClient(ident)
 	delete(s.idents, vhost)
 }
 
+func (s *Server) addClientDomain(ident, domain string) error {
+	if err := s.validDomain(domain); err != nil {
+		return err
+	}
+
+	if err := s.verifyDomain(ident, domain); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.idents[domain]; ok {
+		if id != ident {
+			return fmt.Errorf("domain %q is already registered", domain)
+		}
+
+		return nil
+	}
+
+	if n := s.clientDomains(ident); n >= s.opts.maxDomains() {
+		return fmt.Errorf("limit of %d custom domains reached", n)
+	}
+
+	s.opts.Log.Debug("%s: adding domain=%q", ident, domain)
+
+	s.idents[domain] = ident
+	s.Server.AddHost(domain, ident)
+
+	if s.Certs != nil {
+		s.Certs.Manage(domain)
+	}
+
+	return nil
+}
+
+// verifyDomain ensures the client owns the domain - either the
+// domain is a CNAME of the client's virtual host, or a TXT record
+// of the _koding-tunnel subdomain holds the virtual host.
+func (s *Server) verifyDomain(ident, domain string) error {
+	s.mu.Lock()
+	tun := s.tunnels.tunnel(ident, "")
+	s.mu.Unlock()
+
+	if tun == nil {
+		return fmt.Errorf("client with ident %q does not exist", ident)
+	}
+
+	vhost := host(tun.VirtualHost)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r := s.opts.resolver()
+
+	if cname, err := r.LookupCNAME(ctx, domain); err == nil && fqdn(cname) == vhost {
+		return nil
+	}
+
+	if txts, err := r.LookupTXT(ctx, "_koding-tunnel."+domain); err == nil {
+		for _, txt := range txts {
+			if fqdn(txt) == vhost {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unable to verify ownership of %q: expected CNAME record or "+
+		"_koding-tunnel.%[1]s TXT record with %[2]q value", domain, vhost)
+}
+
+// clientDomains gives number of custom domains registered
+// by the client. It expects s.mu to be held.
+func (s *Server) clientDomains(ident string) (n int) {
+	tun := s.tunnels.tunnel(ident, "")
+
+	for domain, id := range s.idents {
+		if id == ident && (tun == nil || domain != tun.VirtualHost) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func fqdn(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+func (s *Server) validDomain(domain string) error {
+	if domain == "" {
+		return errors.New("empty domain")
+	}
+
+	if strings.ContainsAny(domain, ":/ *") || !strings.Contains(domain, ".") {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+
+	base := host(s.opts.BaseVirtualHost)
+
+	if domain == base || strings.HasSuffix(domain, "."+base) {
+		return fmt.Errorf("domain %q is reserved for virtual hosts", domain)
+	}
+
+	return nil
+}
+
 func (s *Server) addClientService(ident string, tun *Tunnel) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -542,6 +747,21 @@ func (s *Server) registerServices(r *kite.Request) (interface{}, error) {
 		res.Services[service.Name] = service
 	}
 
+	for _, domain := range req.Domains {
+		d := &Domain{
+			Name: strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), ".")),
+		}
+
+		if e := s.addClientDomain(req.Ident, d.Name); e != nil {
+			d.Error = e.Error()
+			err = multierror.Append(err, e)
+		} else {
+			ok = true
+		}
+
+		res.Domains = append(res.Domains, d)
+	}
+
 	if !ok && err != nil {
 		return nil, err
 	}
@@ -800,10 +1020,66 @@ func (s *Server) serverHandler() http.HandlerFunc {
 
 		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/klient")
 
+		// Answer ACME challenges for custom domains, pass
+		// all other requests to the tunnels.
+		if s.Certs != nil {
+			s.Certs.HTTPHandler(s.Server).ServeHTTP(w, r)
+			return
+		}
+
 		s.Server.ServeHTTP(w, r)
 	}
 }
 
+// tlsHandler routes requests received over TLS connections to the
+// client tunnel that owns the server name requested by TLS client.
+//
+// The Host header is ignored, so it's not possible to reach other
+// client's tunnel with a certificate of a different domain.
+func (s *Server) tlsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || r.TLS.ServerName == "" {
+			http.Error(w, "missing server name", http.StatusBadRequest)
+			return
+		}
+
+		r.Host = strings.ToLower(r.TLS.ServerName)
+
+		s.Server.ServeHTTP(w, r)
+	}
+}
+
+// ServeTLS terminates TLS connections for custom domains accepted
+// on the given listener and routes them by SNI to the client tunnels.
+func (s *Server) ServeTLS(l net.Listener) error {
+	if s.Certs == nil {
+		return errors.New("ACME is not configured")
+	}
+
+	cfg := &tls.Config{
+		GetCertificate: s.Certs.GetCertificate,
+	}
+
+	srv := &http.Server{
+		Handler:        metrics.WrapHTTPHandler(s.opts.Metrics, "tls_handler", s.tlsHandler()),
+		MaxHeaderBytes: 1 << 20,
+		TLSNextProto:   make(map[string]func(*http.Server, *tls.Conn, http.Handler)),
+	}
+
+	return srv.Serve(tls.NewListener(l, cfg))
+}
+
+// ListenAndServeTLS listens on TLSPort and serves custom domains
+// with ServeTLS.
+func (s *Server) ListenAndServeTLS() error {
+	l, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(s.opts.TLSPort)))
+	if err != nil {
+		return err
+	}
+
+	return s.ServeTLS(l)
+}
+
 // NewServerKite creates a server kite for the given server.
 func NewServerKite(s *Server, name, version string) (*kite.Kite, error) {
 	cfg, err := konfig.ReadKiteConfig(s.opts.Debug)
@@ -840,6 +1116,15 @@ func NewServerKite(s *Server, name, version string) (*kite.Kite, error) {
 	k.HandleHTTPFunc("/version", artifact.VersionHandler())
 
 	// Tunnel helper methods, like ports, stats etc.
+	//
+	// Prometheus metrics and health checks are served under /-/ prefix,
+	// as e.g. /metrics would shadow the path for tunneled services.
+	k.HandleHTTP("/-/metrics", metrics.PrometheusHandler())
+
+	h := health.New(name)
+	h.Live("http", health.HTTP(k, "/healthCheck"))
+	h.Ready("kontrol", health.Kontrol(k))
+	h.RegisterPrefix(k, "/-")
 	k.HandleHTTPFunc("/-/discover/{service}", metrics.WrapHTTPHandler(s.opts.Metrics, "discover_service_handler", s.discoverHandler()))
 
 	// Route all the rest requests (match all paths that does not begin with /-/).
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,10 +33,14 @@ import (
 	"koding/klient/info"
 	"koding/klient/info/publicip"
 	"koding/klient/logfetcher"
+	"koding/klient/logship"
 	mclient "koding/klient/machine/client"
 	"koding/klient/machine/index"
 	"koding/klient/machine/machinegroup"
+	"koding/klient/machine/mount/notify"
 	"koding/klient/machine/mount/notify/fuse"
+	"koding/klient/machine/mount/notify/native"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/machine/mount/sync/rsync"
 	kos "koding/klient/os"
 	"koding/klient/sshkeys"
@@ -110,6 +115,9 @@ type Klient struct {
 	uploader       *uploader.Uploader
 	logUploadDelay time.Duration
 
+	// logship ships configured log files; nil if not configured
+	logship *logship.Shipper
+
 	// publicIP is a cached public IP address of the klient.
 	publicIP net.IP
 
@@ -156,7 +164,9 @@ type KlientConfig struct {
 
 	LogBucketRegion   string
 	LogBucketName     string
+	LogBucketType     string
 	LogUploadInterval time.Duration
+	LogShipConfig     string
 	LogLevel          kite.Level
 
 	Metadata     string
@@ -197,6 +207,10 @@ func NewKlient(conf *KlientConfig) (*Klient, error) {
 		"fs.createDirectory":   true,
 		"fs.move":              true,
 		"fs.copy":              true,
+		"fs.search":            true,
+		"fs.watch":             true,
+		"fs.history":           true,
+		"fs.restore":           true,
 		"webterm.getSessions":  true,
 		"webterm.connect":      true,
 		"webterm.killSession":  true,
@@ -268,10 +282,20 @@ func NewKlient(conf *KlientConfig) (*Klient, error) {
 		Kite:      k,
 		Bucket:    conf.logBucketName(),
 		Region:    conf.logBucketRegion(),
+		Type:      conf.LogBucketType,
 		DB:        db,
 		Log:       k.Log,
 	})
 
+	var ls *logship.Shipper
+
+	if conf.LogShipConfig != "" {
+		ls, err = newLogShipper(conf, k, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	vagrantOpts := &vagrant.Options{
 		Home:   conf.VagrantHome,
 		DB:     db, // nil is ok, fallbacks to in-memory storage
@@ -304,7 +328,7 @@ func NewKlient(conf *KlientConfig) (*Klient, error) {
 	machinesOpts := &machinegroup.Options{
 		Storage:         storage.NewEncodingStorage(db, []byte("machines")),
 		Builder:         mclient.NewKiteBuilder(k),
-		NotifyBuilder:   fuse.Builder,
+		NotifyBuilder:   notify.FallbackBuilder{fuse.Builder, native.Builder},
 		SyncBuilder:     rsync.Builder{},
 		DynAddrInterval: 2 * time.Second,
 		PingInterval:    15 * time.Second,
@@ -353,6 +377,7 @@ func NewKlient(conf *KlientConfig) (*Klient, error) {
 		log:      k.Log,
 		config:   conf,
 		uploader: up,
+		logship:  ls,
 		machines: machines,
 		updater: &Updater{
 			Endpoint:       conf.UpdateURL,
@@ -399,6 +424,9 @@ func NewKlient(conf *KlientConfig) (*Klient, error) {
 			if err := kl.collab.Delete(user); err != nil {
 				kl.log.Warning("Couldn't delete user from storage: %s", err)
 			}
+			if err := sshkeys.RevokePrincipals(user); err != nil {
+				kl.log.Warning("Couldn't revoke SSH access of %q: %s", user, err)
+			}
 			kl.terminal.CloseSessions(user)
 		}
 	})
@@ -435,6 +463,9 @@ func (k *Klient) RegisterMethods() {
 	k.kite.PreHandleFunc(k.usage.Counter) // we measure every incoming request
 	k.handleFunc("klient.usage", k.usage.Current)
 
+	// Prometheus metrics of the kite methods.
+	k.kite.HandleHTTP("/metrics", metrics.PrometheusHandler())
+
 	// klient os method(s)
 	k.handleWithSub("os.home", kos.Home)
 	k.handleWithSub("os.currentUsername", kos.CurrentUsername)
@@ -447,13 +478,14 @@ func (k *Klient) RegisterMethods() {
 	// Collaboration, is used by our Koding.com browser client.
 	k.handleFunc("klient.disable", control.Disable)
 	k.handleFunc("klient.share", k.collab.Share)
-	k.handleFunc("klient.unshare", k.collab.Unshare)
+	k.handleFunc("klient.unshare", k.unshare)
 	k.handleFunc("klient.shared", k.collab.Shared)
 
 	// SSH keys
 	k.handleWithSub("sshkeys.list", sshkeys.List)
 	k.handleWithSub("sshkeys.add", sshkeys.Add)
 	k.handleWithSub("sshkeys.delete", sshkeys.Delete)
+	k.handleWithSub("sshkeys.trustCA", sshkeys.TrustCA)
 
 	// Storage
 	k.handleFunc("storage.set", k.storage.SetValue)
@@ -479,6 +511,12 @@ func (k *Klient) RegisterMethods() {
 	k.handleWithSub("fs.getDiskInfo", fs.GetDiskInfo)
 	k.handleWithSub("fs.getPathSize", fs.GetPathSize)
 	k.handleWithSub("fs.abs", fs.KiteHandlerAbs())
+	k.handleWithSub("fs.search", fs.Search)
+	k.handleWithSub("fs.cancelSearch", fs.CancelSearch)
+	k.handleWithSub("fs.watch", fs.Watch)
+	k.handleWithSub("fs.unwatch", fs.Unwatch)
+	k.handleWithSub("fs.history", fs.History)
+	k.handleWithSub("fs.restore", fs.Restore)
 
 	// Machine group handlers.
 	k.handleFunc("machine.create", machinegroup.KiteHandlerCreate(k.machines))
@@ -490,6 +528,7 @@ func (k *Klient) RegisterMethods() {
 	k.handleFunc("machine.mount.updateIndex", machinegroup.KiteHandlerUpdateIndex(k.machines))
 	k.handleFunc("machine.mount.list", machinegroup.KiteHandlerListMount(k.machines))
 	k.handleFunc("machine.mount.inspect", machinegroup.KiteHandlerInspectMount(k.machines))
+	k.handleFunc("machine.mount.log", machinegroup.KiteHandlerMountLog(k.machines))
 	k.handleFunc("machine.mount.waitIdle", k.machines.HandleWaitIdle)
 	k.handleFunc("machine.mount.id", machinegroup.KiteHandlerMountID(k.machines))
 	k.handleFunc("machine.mount.identifier.list", machinegroup.KiteHandlerMountIdentifierList(k.machines))
@@ -498,11 +537,17 @@ func (k *Klient) RegisterMethods() {
 	k.handleFunc("machine.cp", machinegroup.KiteHandlerCp(k.machines))
 	k.handleFunc("machine.exec", k.machines.HandleExec)
 	k.handleFunc("machine.kill", k.machines.HandleKill)
+	k.handleFunc("machine.search", k.machines.HandleSearch)
+	k.handleFunc("machine.cancelSearch", k.machines.HandleCancelSearch)
 
 	// Machine index handlers.
 	k.handleWithSub("machine.index.head", index.KiteHandlerHead())
 	k.handleWithSub("machine.index.get", index.KiteHandlerGet())
 
+	// Reverse mount handlers.
+	k.handleWithSub("machine.reverse.index", reverse.KiteHandlerIndex())
+	k.handleWithSub("machine.reverse.apply", reverse.KiteHandlerApply())
+
 	// Vagrant
 	k.handleFunc("vagrant.create", k.vagrant.Create)
 	k.handleFunc("vagrant.provider", k.vagrant.Provider)
@@ -513,6 +558,9 @@ func (k *Klient) RegisterMethods() {
 	k.handleFunc("vagrant.status", k.vagrant.Status)
 	k.handleFunc("vagrant.version", k.vagrant.Version)
 	k.handleFunc("vagrant.listForwardedPorts", k.vagrant.ForwardedPorts)
+	k.handleFunc("vagrant.package", k.vagrant.Package)
+	k.handleFunc("vagrant.restore", k.vagrant.Restore)
+	k.handleFunc("vagrant.removeSnapshot", k.vagrant.RemoveSnapshot)
 
 	// Tunnel
 	k.handleFunc("tunnel.info", k.tunnel.Info)
@@ -838,11 +886,56 @@ func (k *Klient) Close() {
 		k.metrics.Close()
 	}
 
+	if k.logship != nil {
+		k.logship.Close()
+	}
+
 	k.collabCloser.Close()
 	k.collab.Close()
 	k.kite.Close()
 }
 
+// newLogShipper creates log shipper from the configuration file
+// given by conf.LogShipConfig.
+func newLogShipper(conf *KlientConfig, k *kite.Kite, db *bolt.DB) (*logship.Shipper, error) {
+	lsConf, err := logship.ReadConfig(conf.LogShipConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read log shipping config: %s", err)
+	}
+
+	var bucket logrotate.Bucket
+
+	if lsConf.Sink.Type == logship.SinkS3 {
+		bucket = uploader.NewBucket(&uploader.Options{
+			KeygenURL: konfig.Konfig.Endpoints.Kloud().Public.String(),
+			Kite:      k,
+			Bucket:    conf.logBucketName(),
+			Region:    conf.logBucketRegion(),
+			Type:      conf.LogBucketType,
+			Log:       k.Log,
+		})
+	}
+
+	sink, err := logship.NewSink(&lsConf.Sink, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := lsConf.Options(sink)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.BufferDir == "" {
+		opts.BufferDir = filepath.Join(cfg.KodingHome(), "logship")
+	}
+
+	opts.MetaStore = storage.NewEncodingStorage(db, []byte("logship"))
+	opts.Log = k.Log.(logging.Logger)
+
+	return logship.New(opts)
+}
+
 // NewUploader creates new uploader value from the given klient configuration.
 func NewUploader(kconf *KlientConfig) *uploader.Uploader {
 	k := newKite(kconf)
@@ -853,6 +946,7 @@ func NewUploader(kconf *KlientConfig) *uploader.Uploader {
 		Kite:      k,
 		Bucket:    kconf.logBucketName(),
 		Region:    kconf.logBucketRegion(),
+		Type:      kconf.LogBucketType,
 		Log:       k.Log,
 	})
 }
@@ -884,6 +978,29 @@ func newKite(kconf *KlientConfig) *kite.Kite {
 	return k
 }
 
+// unshare removes the user from the shared list and revokes SSH certificate
+// principals issued for the user, so the user can no longer log in.
+func (k *Klient) unshare(r *kite.Request) (interface{}, error) {
+	resp, err := k.collab.Unshare(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var params struct {
+		Username string
+	}
+
+	if err := r.Args.One().Unmarshal(&params); err != nil {
+		return nil, err
+	}
+
+	if err := sshkeys.RevokePrincipals(params.Username); err != nil {
+		return nil, fmt.Errorf("user unshared, but SSH access not revoked: %s", err)
+	}
+
+	return resp, nil
+}
+
 // checkAuth checks whether the given incoming request is authenticated or not.
 // It don't pass any request if the caller is outside of our scope.
 func (k *Klient) checkAuth(r *kite.Request) (interface{}, error) {
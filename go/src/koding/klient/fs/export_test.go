@@ -0,0 +1,17 @@
+package fs
+
+// Dirs gives the number of directories watched by the shared watcher.
+func (w *Watcher) Dirs() int {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	return len(w.refs)
+}
+
+// CancelOwner stops all background searches of the given owner, like
+// its disconnect hook does.
+func (s *Searcher) CancelOwner(owner string) { s.cancelOwner(owner) }
+
+// StopOwner removes all watches of the given owner, like its disconnect
+// hook does.
+func (w *Watcher) StopOwner(owner string) { w.stopOwner(owner) }
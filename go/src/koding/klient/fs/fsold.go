@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/koding/kite"
 	"github.com/koding/kite/dnode"
@@ -225,9 +226,18 @@ type writeFileParams struct {
 	// The current hashing algorithm is md5
 	LastContentHash string
 
+	// If specified, fs.writeFile returns a conflict error when modification
+	// time of the file on filesystem is different than this value. Times
+	// are compared with millisecond precision.
+	LastModTime time.Time
+
 	// Offset optionally writes the given data at the offset location, using
 	// file.WriteAt(data,offset) instead of file.Write(data)
 	Offset int64
+
+	// History is the number of previous revisions of the file to keep,
+	// see fs.history and fs.restore. If zero, no revision is saved.
+	History int
 }
 
 func WriteFile(r *kite.Request) (interface{}, error) {
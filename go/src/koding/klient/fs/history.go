@@ -0,0 +1,249 @@
+package fs
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"koding/kites/config"
+
+	"github.com/koding/kite"
+)
+
+// DefaultHistory is the number of revisions kept by fs.restore, when
+// the request does not specify it.
+const DefaultHistory = 10
+
+// HistoryDir is a directory where previous revisions of files overwritten
+// by fs.writeFile and fs.restore are kept.
+//
+// Revisions are kept outside of the files' directories, so they do not
+// show up in directory listings, searches and watches.
+var HistoryDir = filepath.Join(config.KodingHome(), "history")
+
+// Revision describes a previous content of a file.
+type Revision struct {
+	ID   string    `json:"id"`   // identifies the revision
+	Time time.Time `json:"time"` // when the content was replaced
+	Size int64     `json:"size"` // size of the content
+	Hash string    `json:"hash"` // md5 of the content
+}
+
+// HistoryRequest represents a request value for the "fs.history" kite method.
+type HistoryRequest struct {
+	Path string `json:"path"`
+}
+
+// Valid implements the stack.Validator interface.
+func (r *HistoryRequest) Valid() error {
+	if r.Path == "" {
+		return errors.New("invalid empty path")
+	}
+	return nil
+}
+
+// HistoryResponse represents a response value for the "fs.history" kite method.
+type HistoryResponse struct {
+	Revisions []*Revision `json:"revisions"` // newest first
+}
+
+// RestoreRequest represents a request value for the "fs.restore" kite method.
+type RestoreRequest struct {
+	Path string `json:"path"`
+	ID   string `json:"id"` // revision to restore
+
+	// History is the number of revisions to keep; the replaced content is
+	// saved as a new revision, so restore can be undone.
+	//
+	// If zero, DefaultHistory is used.
+	History int `json:"history"`
+}
+
+// Valid implements the stack.Validator interface.
+func (r *RestoreRequest) Valid() error {
+	if r.Path == "" {
+		return errors.New("invalid empty path")
+	}
+	if r.ID == "" || strings.ContainsAny(r.ID, `/\`) {
+		return errors.New("invalid revision ID")
+	}
+	return nil
+}
+
+// History is a kite handler for "fs.history" method.
+func History(r *kite.Request) (interface{}, error) {
+	var req HistoryRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	revs, err := history(req.Path)
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	return &HistoryResponse{Revisions: revs}, nil
+}
+
+// Restore is a kite handler for "fs.restore" method.
+func Restore(r *kite.Request) (interface{}, error) {
+	var req RestoreRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	if err := restore(req.Path, req.ID, req.History); err != nil {
+		return nil, newError(err)
+	}
+
+	return true, nil
+}
+
+// historyDir gives a directory where revisions of the given file are kept.
+func historyDir(path string) (string, error) {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(path))
+
+	return filepath.Join(HistoryDir, hex.EncodeToString(sum[:])), nil
+}
+
+// history gives revisions of the given file, newest first.
+func history(path string) ([]*Revision, error) {
+	dir, err := historyDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revs []*Revision
+
+	for _, fi := range fis {
+		// Revision files are named <unix nano>-<md5>.
+		i := strings.IndexByte(fi.Name(), '-')
+		if i == -1 || fi.IsDir() {
+			continue
+		}
+
+		nsec, err := strconv.ParseInt(fi.Name()[:i], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		revs = append(revs, &Revision{
+			ID:   fi.Name(),
+			Time: time.Unix(0, nsec),
+			Size: fi.Size(),
+			Hash: fi.Name()[i+1:],
+		})
+	}
+
+	// Names are zero-padded, so lexical order is the time order.
+	sort.Slice(revs, func(i, j int) bool { return revs[i].ID > revs[j].ID })
+
+	return revs, nil
+}
+
+// saveRevision saves current content of the file in its history and
+// removes the oldest revisions, so at most keep of them are left.
+//
+// If link is true, the revision is a hard link to the file, which is
+// enough when the file is going to be replaced rather than modified.
+// Otherwise, or when history resides on a different device, the content
+// is copied.
+func saveRevision(path string, keep int, link bool) error {
+	dir, err := historyDir(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	hash, err := md5File(path)
+	if err != nil {
+		return err
+	}
+
+	rev := filepath.Join(dir, fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hash))
+
+	if !link || os.Link(path, rev) != nil {
+		if err := copyFile(path, rev); err != nil {
+			return err
+		}
+	}
+
+	revs, err := history(path)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(revs); i++ {
+		if err := os.Remove(filepath.Join(dir, revs[i].ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restore replaces content of the file with the given revision.
+func restore(path, id string, keep int) error {
+	dir, err := historyDir(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Join(dir, id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("revision %q not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if keep <= 0 {
+		keep = DefaultHistory
+	}
+
+	_, err = atomicWrite(path, f, &writeOptions{keep: keep})
+	return err
+}
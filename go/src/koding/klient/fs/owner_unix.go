@@ -0,0 +1,20 @@
+// +build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown changes the owner of the file to the owner described by fi. It
+// fails with permission error when the process is not allowed to do so,
+// e.g. when klient is not run by root and the owner is other user.
+func chown(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return os.Lchown(path, int(st.Uid), int(st.Gid))
+}
@@ -0,0 +1,546 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/koding/kite"
+	"github.com/koding/kite/dnode"
+)
+
+// Default limits of the search request.
+const (
+	DefaultSearchMaxResults  = 1000
+	DefaultSearchMaxFileSize = 10 * 1024 * 1024
+	DefaultSearchTimeout     = 30 * time.Second
+)
+
+// maxLineLength limits the length of a line that is reported in a match.
+const maxLineLength = 512
+
+// binaryCheckSize is the number of leading bytes of a file that are looked
+// up for NUL characters, in order to tell binary files from text ones.
+const binaryCheckSize = 8000
+
+var (
+	// errSearchCanceled is returned when search was canceled.
+	errSearchCanceled = errors.New("search was canceled")
+
+	// errSearchTimeout is reported when synchronous search took too long.
+	errSearchTimeout = errors.New("search timed out")
+)
+
+// DefaultSearcher is a searcher used by Search and CancelSearch handlers.
+var DefaultSearcher = NewSearcher()
+
+// SearchRequest represents a request value for the "fs.search" kite method.
+type SearchRequest struct {
+	Path       string `json:"path"`       // file or directory to search in; required
+	Pattern    string `json:"pattern"`    // regular expression to look for; required
+	Literal    bool   `json:"literal"`    // if true, Pattern is a plain string
+	IgnoreCase bool   `json:"ignoreCase"` // if true, search is case insensitive
+
+	// Include, when not empty, limits the search to files whose names or
+	// paths relative to Path match at least one of the glob patterns.
+	Include []string `json:"include"`
+
+	// Exclude skips files and directories whose names or paths relative
+	// to Path match any of the glob patterns.
+	Exclude []string `json:"exclude"`
+
+	// SkipBinary makes the search ignore binary files. Otherwise a single
+	// match without line content is reported for each matching binary file.
+	SkipBinary bool `json:"skipBinary"`
+
+	MaxResults  int   `json:"maxResults"`  // if zero, DefaultSearchMaxResults is used
+	MaxFileSize int64 `json:"maxFileSize"` // larger files are skipped; if zero, DefaultSearchMaxFileSize is used
+
+	OnMatch dnode.Function `json:"onMatch"` // func(*SearchMatch): if valid, matches are streamed and search runs in background
+	OnDone  dnode.Function `json:"onDone"`  // func(*SearchResult): if valid, called when background search is finished
+}
+
+// Valid implements the stack.Validator interface.
+func (r *SearchRequest) Valid() error {
+	if r.Path == "" {
+		return errors.New("invalid empty path")
+	}
+	if r.Pattern == "" {
+		return errors.New("invalid empty pattern")
+	}
+	if err := validGlobs(r.Include); err != nil {
+		return err
+	}
+	return validGlobs(r.Exclude)
+}
+
+// SearchMatch describes a single line that matches searched pattern.
+type SearchMatch struct {
+	Path   string `json:"path"`             // absolute path of the file
+	Line   int    `json:"line,omitempty"`   // line number, starting from 1
+	Column int    `json:"column,omitempty"` // byte offset of the match in line, starting from 1
+	Text   string `json:"text,omitempty"`   // matching line, truncated if too long
+	Binary bool   `json:"binary,omitempty"` // set when the file is binary
+}
+
+// SearchResult summarizes finished search.
+type SearchResult struct {
+	Matches   []*SearchMatch `json:"matches,omitempty"` // set only when matches are not streamed
+	Count     int            `json:"count"`             // number of matches found
+	Files     int            `json:"files"`             // number of searched files
+	Truncated bool           `json:"truncated"`         // set when MaxResults was reached
+	Canceled  bool           `json:"canceled"`          // set when search was canceled
+	Err       string         `json:"err,omitempty"`     // error which stopped the search, if any
+}
+
+// SearchResponse represents a response value for the "fs.search" kite method.
+type SearchResponse struct {
+	// ID identifies background search and can be used to cancel it.
+	// It is zero if the search was run synchronously.
+	ID int `json:"id,omitempty"`
+
+	// Result is set if the search was run synchronously.
+	Result *SearchResult `json:"result,omitempty"`
+}
+
+// CancelSearchRequest represents a request value for the "fs.cancelSearch"
+// kite method.
+type CancelSearchRequest struct {
+	ID int `json:"id"`
+}
+
+// Valid implements the stack.Validator interface.
+func (r *CancelSearchRequest) Valid() error {
+	if r.ID == 0 {
+		return errors.New("invalid zero search ID")
+	}
+	return nil
+}
+
+// Searcher runs content searches over file system.
+type Searcher struct {
+	// Timeout limits the duration of synchronous searches, which
+	// can't be canceled. If zero, DefaultSearchTimeout is used.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	id       int
+	searches map[int]*search
+	owners   map[string]struct{} // owners with registered disconnect hook
+}
+
+// NewSearcher creates a new searcher.
+func NewSearcher() *Searcher {
+	return &Searcher{
+		searches: make(map[int]*search),
+		owners:   make(map[string]struct{}),
+	}
+}
+
+// Search is a kite handler for "fs.search" method.
+//
+// The request value is expected to be of *SearchRequest type. Background
+// searches are owned by the calling client and are canceled when it
+// disconnects.
+func (s *Searcher) Search(r *kite.Request) (interface{}, error) {
+	var req SearchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newSearchError(err)
+	}
+
+	var owner string
+	if r.Client != nil {
+		owner = r.Client.ID
+	}
+
+	resp, err := s.Start(owner, &req)
+	if err != nil {
+		return nil, newSearchError(err)
+	}
+
+	if resp.ID != 0 && r.Client != nil && s.addOwner(owner) {
+		r.Client.OnDisconnect(func() { s.cancelOwner(owner) })
+	}
+
+	return resp, nil
+}
+
+// CancelSearch is a kite handler for "fs.cancelSearch" method.
+//
+// The request value is expected to be of *CancelSearchRequest type. Only
+// the client which started the search can cancel it.
+func (s *Searcher) CancelSearch(r *kite.Request) (interface{}, error) {
+	var req CancelSearchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newSearchError(err)
+	}
+
+	var owner string
+	if r.Client != nil {
+		owner = r.Client.ID
+	}
+
+	if !s.Cancel(owner, req.ID) {
+		return nil, newSearchError(errors.New("search not found"))
+	}
+
+	return true, nil
+}
+
+// Start runs the search described by the given request. If the request has
+// valid OnMatch callback, the search is run in background and can be
+// canceled by its owner with the returned ID. Otherwise Start blocks until
+// the search is done or times out and returns all matches found.
+func (s *Searcher) Start(owner string, req *SearchRequest) (*SearchResponse, error) {
+	root, _, exist, err := DefaultFS.Abs(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, os.ErrNotExist
+	}
+
+	re, err := compilePattern(req)
+	if err != nil {
+		return nil, err
+	}
+
+	sr := &search{
+		owner:  owner,
+		req:    req,
+		root:   root,
+		re:     re,
+		cancel: make(chan struct{}),
+		result: &SearchResult{},
+	}
+
+	if !req.OnMatch.IsValid() {
+		sr.deadline = time.Now().Add(s.timeout())
+		sr.run()
+		return &SearchResponse{Result: sr.result}, nil
+	}
+
+	s.mu.Lock()
+	s.id++
+	id := s.id
+	s.searches[id] = sr
+	s.mu.Unlock()
+
+	go func() {
+		sr.run()
+
+		s.mu.Lock()
+		delete(s.searches, id)
+		s.mu.Unlock()
+
+		if req.OnDone.IsValid() {
+			req.OnDone.Call(sr.result)
+		}
+	}()
+
+	return &SearchResponse{ID: id}, nil
+}
+
+// Cancel stops the background search with the given ID. It returns false
+// if there's no such search running or it was started by other owner.
+func (s *Searcher) Cancel(owner string, id int) bool {
+	s.mu.Lock()
+	sr, ok := s.searches[id]
+	if ok && sr.owner == owner {
+		delete(s.searches, id)
+	}
+	s.mu.Unlock()
+
+	if !ok || sr.owner != owner {
+		return false
+	}
+
+	close(sr.cancel)
+
+	return true
+}
+
+// addOwner tells whether the owner is a new one, which disconnect hook
+// needs to be registered.
+func (s *Searcher) addOwner(owner string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.owners[owner]; ok {
+		return false
+	}
+
+	s.owners[owner] = struct{}{}
+	return true
+}
+
+// cancelOwner stops all background searches of the given owner.
+func (s *Searcher) cancelOwner(owner string) {
+	var canceled []*search
+
+	s.mu.Lock()
+	delete(s.owners, owner)
+	for id, sr := range s.searches {
+		if sr.owner == owner {
+			delete(s.searches, id)
+			canceled = append(canceled, sr)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sr := range canceled {
+		close(sr.cancel)
+	}
+}
+
+func (s *Searcher) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultSearchTimeout
+}
+
+// Search is a kite handler for "fs.search" method that uses DefaultSearcher.
+func Search(r *kite.Request) (interface{}, error) { return DefaultSearcher.Search(r) }
+
+// CancelSearch is a kite handler for "fs.cancelSearch" method that uses
+// DefaultSearcher.
+func CancelSearch(r *kite.Request) (interface{}, error) { return DefaultSearcher.CancelSearch(r) }
+
+func compilePattern(req *SearchRequest) (*regexp.Regexp, error) {
+	pattern := req.Pattern
+
+	if req.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	if req.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+type search struct {
+	owner    string
+	req      *SearchRequest
+	root     string
+	re       *regexp.Regexp
+	cancel   chan struct{}
+	deadline time.Time // set for synchronous searches only
+	result   *SearchResult
+}
+
+func (sr *search) run() {
+	err := filepath.Walk(sr.root, func(path string, fi os.FileInfo, err error) error {
+		if sr.canceled() {
+			return errSearchCanceled
+		}
+
+		if err != nil {
+			// Unreadable files and directories are skipped.
+			if fi != nil && fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if path != sr.root && sr.excluded(path, fi.Name()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if fi.IsDir() || !fi.Mode().IsRegular() || fi.Size() > sr.maxFileSize() {
+			return nil
+		}
+
+		if path != sr.root && !sr.included(path, fi.Name()) {
+			return nil
+		}
+
+		return sr.searchFile(path)
+	})
+
+	switch err {
+	case nil, io.EOF:
+	case errSearchCanceled:
+		if sr.expired() {
+			sr.result.Err = errSearchTimeout.Error()
+		} else {
+			sr.result.Canceled = true
+		}
+	default:
+		sr.result.Err = err.Error()
+	}
+}
+
+// searchFile looks for matches in the given file. It returns io.EOF when
+// the maximum number of results was reached.
+func (sr *search) searchFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil // skip unreadable files
+	}
+	defer f.Close()
+
+	sr.result.Files++
+
+	br := bufio.NewReader(f)
+
+	if head, _ := br.Peek(binaryCheckSize); bytes.IndexByte(head, 0) != -1 {
+		if sr.req.SkipBinary {
+			return nil
+		}
+
+		content, err := readAll(br, sr.cancel)
+		if err == errSearchCanceled {
+			return err
+		}
+		if err != nil {
+			return nil // skip files that fail to read
+		}
+
+		if sr.re.Match(content) {
+			return sr.match(&SearchMatch{Path: path, Binary: true})
+		}
+
+		return nil
+	}
+
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) != 0 {
+			line = bytes.TrimRight(line, "\r\n")
+
+			if loc := sr.re.FindIndex(line); loc != nil {
+				if len(line) > maxLineLength {
+					line = line[:maxLineLength]
+				}
+
+				m := &SearchMatch{
+					Path:   path,
+					Line:   n,
+					Column: loc[0] + 1,
+					Text:   string(line),
+				}
+
+				if err := sr.match(m); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return nil // skip files that fail to read
+		}
+
+		if n%1000 == 0 && sr.canceled() {
+			return errSearchCanceled
+		}
+	}
+}
+
+func (sr *search) match(m *SearchMatch) error {
+	sr.result.Count++
+
+	if sr.req.OnMatch.IsValid() {
+		sr.req.OnMatch.Call(m)
+	} else {
+		sr.result.Matches = append(sr.result.Matches, m)
+	}
+
+	if sr.result.Count >= sr.maxResults() {
+		sr.result.Truncated = true
+		return io.EOF
+	}
+
+	return nil
+}
+
+func (sr *search) included(path, name string) bool {
+	return len(sr.req.Include) == 0 || matchGlobs(sr.req.Include, sr.root, path, name)
+}
+
+func (sr *search) excluded(path, name string) bool {
+	return matchGlobs(sr.req.Exclude, sr.root, path, name)
+}
+
+func (sr *search) canceled() bool {
+	select {
+	case <-sr.cancel:
+		return true
+	default:
+		return sr.expired()
+	}
+}
+
+func (sr *search) expired() bool {
+	return !sr.deadline.IsZero() && time.Now().After(sr.deadline)
+}
+
+func (sr *search) maxResults() int {
+	if sr.req.MaxResults > 0 {
+		return sr.req.MaxResults
+	}
+	return DefaultSearchMaxResults
+}
+
+func (sr *search) maxFileSize() int64 {
+	if sr.req.MaxFileSize > 0 {
+		return sr.req.MaxFileSize
+	}
+	return DefaultSearchMaxFileSize
+}
+
+// readAll reads the whole r unless cancel gets closed.
+func readAll(r io.Reader, cancel <-chan struct{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for {
+		select {
+		case <-cancel:
+			return nil, errSearchCanceled
+		default:
+		}
+
+		_, err := io.CopyN(&buf, r, 1024*1024)
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newSearchError(err error) error {
+	return &kite.Error{
+		Type:    "fsError",
+		Message: err.Error(),
+	}
+}
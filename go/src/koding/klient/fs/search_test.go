@@ -0,0 +1,352 @@
+package fs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"koding/klient/fs"
+
+	"github.com/koding/kite/dnode"
+)
+
+func TestSearch(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"main.go":               "package main\n\nfunc main() {\n\tlib.Hello()\n}\n",
+		"README.md":             "hello world\nHELLO again\n",
+		"lib/util.go":           "package lib\n\n// hello is not exported\nfunc hello() {}\n",
+		"vendor/dep/dep.go":     "package dep // hello\n",
+		"bin/app":               "\x00\x01hello\x02",
+		"node_modules/x/x.js":   "hello()\n",
+		"lib/testdata/a.golden": "hello\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll()=%s", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+
+	cases := map[string]struct {
+		req  *fs.SearchRequest
+		want []string // "<rel path>:<line>" or "<rel path>:binary"
+	}{
+		"regexp": {
+			&fs.SearchRequest{
+				Pattern: "^func h",
+			},
+			[]string{"lib/util.go:4"},
+		},
+		"literal ignore case": {
+			&fs.SearchRequest{
+				Pattern:    "hello(",
+				Literal:    true,
+				IgnoreCase: true,
+			},
+			[]string{"lib/util.go:4", "main.go:4", "node_modules/x/x.js:1"},
+		},
+		"include exclude": {
+			&fs.SearchRequest{
+				Pattern: "hello",
+				Include: []string{"*.go"},
+				Exclude: []string{"vendor"},
+			},
+			[]string{"lib/util.go:3", "lib/util.go:4"},
+		},
+		"binary": {
+			&fs.SearchRequest{
+				Pattern: "hello",
+				Include: []string{"bin/*"},
+			},
+			[]string{"bin/app:binary"},
+		},
+		"skip binary": {
+			&fs.SearchRequest{
+				Pattern:    "hello",
+				Include:    []string{"bin/*"},
+				SkipBinary: true,
+			},
+			nil,
+		},
+		"exclude nested path": {
+			&fs.SearchRequest{
+				Pattern: "hello",
+				Exclude: []string{"bin", "vendor", "node_modules", "lib/testdata", "*.go"},
+			},
+			[]string{"README.md:1"},
+		},
+	}
+
+	for name, cas := range cases {
+		t.Run(name, func(t *testing.T) {
+			cas.req.Path = root
+
+			if err := cas.req.Valid(); err != nil {
+				t.Fatalf("Valid()=%s", err)
+			}
+
+			resp, err := fs.NewSearcher().Start("", cas.req)
+			if err != nil {
+				t.Fatalf("Start()=%s", err)
+			}
+
+			if resp.ID != 0 || resp.Result == nil {
+				t.Fatalf("want synchronous search, got %+v", resp)
+			}
+
+			if got := matches(t, root, resp.Result.Matches); !reflect.DeepEqual(got, cas.want) {
+				t.Fatalf("got %v, want %v", got, cas.want)
+			}
+
+			if resp.Result.Count != len(cas.want) {
+				t.Fatalf("got %d count, want %d", resp.Result.Count, len(cas.want))
+			}
+		})
+	}
+}
+
+func TestSearchMaxResults(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("x\nx\nx\nx\n"), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	resp, err := fs.NewSearcher().Start("", &fs.SearchRequest{
+		Path:       root,
+		Pattern:    "x",
+		MaxResults: 2,
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	if !resp.Result.Truncated || len(resp.Result.Matches) != 2 {
+		t.Fatalf("want 2 matches and truncated result, got %+v", resp.Result)
+	}
+
+	if m := resp.Result.Matches[1]; m.Line != 2 || m.Column != 1 || m.Text != "x" {
+		t.Fatalf("unexpected match: %+v", m)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte("foo\nbar\n"), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+
+	var (
+		found = make(chan *fs.SearchMatch, 3)
+		done  = make(chan *fs.SearchResult, 1)
+	)
+
+	resp, err := fs.NewSearcher().Start("", &fs.SearchRequest{
+		Path:    root,
+		Pattern: "bar",
+		OnMatch: dnode.Function{Caller: caller(func(v interface{}) { found <- v.(*fs.SearchMatch) })},
+		OnDone:  dnode.Function{Caller: caller(func(v interface{}) { done <- v.(*fs.SearchResult) })},
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	if resp.ID == 0 || resp.Result != nil {
+		t.Fatalf("want background search, got %+v", resp)
+	}
+
+	select {
+	case res := <-done:
+		if res.Count != 3 || res.Files != 3 || res.Canceled || len(res.Matches) != 0 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for search to finish")
+	}
+
+	if len(found) != 3 {
+		t.Fatalf("want 3 streamed matches, got %d", len(found))
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte("foo\n"), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+
+	var (
+		s     = fs.NewSearcher()
+		block = make(chan struct{})
+		done  = make(chan *fs.SearchResult, 1)
+	)
+
+	resp, err := s.Start("client", &fs.SearchRequest{
+		Path:    root,
+		Pattern: "foo",
+		OnMatch: dnode.Function{Caller: caller(func(interface{}) { <-block })},
+		OnDone:  dnode.Function{Caller: caller(func(v interface{}) { done <- v.(*fs.SearchResult) })},
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	if s.Cancel("other", resp.ID) {
+		t.Fatal("want search to be canceled by its owner only")
+	}
+
+	if !s.Cancel("client", resp.ID) {
+		t.Fatal("want search to be canceled")
+	}
+
+	if s.Cancel("client", resp.ID) {
+		t.Fatal("want search to be canceled only once")
+	}
+
+	close(block)
+
+	select {
+	case res := <-done:
+		if !res.Canceled || res.Count > 1 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for search to finish")
+	}
+}
+
+func TestSearchCancelOwner(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("foo\nfoo\n"), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	var (
+		s     = fs.NewSearcher()
+		block = make(chan struct{})
+		done  = make(chan *fs.SearchResult, 2)
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Start("client", &fs.SearchRequest{
+			Path:    root,
+			Pattern: "foo",
+			OnMatch: dnode.Function{Caller: caller(func(interface{}) { <-block })},
+			OnDone:  dnode.Function{Caller: caller(func(v interface{}) { done <- v.(*fs.SearchResult) })},
+		})
+		if err != nil {
+			t.Fatalf("Start()=%s", err)
+		}
+	}
+
+	s.CancelOwner("client")
+	close(block)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-done:
+			if !res.Canceled {
+				t.Fatalf("unexpected result: %+v", res)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for search to finish")
+		}
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.search")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	for i := 0; i < 100; i++ {
+		if err := ioutil.WriteFile(filepath.Join(root, fmt.Sprintf("%d.txt", i)), []byte("foo\n"), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+
+	s := fs.NewSearcher()
+	s.Timeout = time.Nanosecond
+
+	resp, err := s.Start("", &fs.SearchRequest{
+		Path:    root,
+		Pattern: "foo",
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	if res := resp.Result; res.Err != "search timed out" || res.Canceled || res.Count == 100 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+type caller func(interface{})
+
+func (c caller) Call(args ...interface{}) error {
+	c(args[0])
+	return nil
+}
+
+func matches(t *testing.T, root string, ms []*fs.SearchMatch) []string {
+	var got []string
+
+	for _, m := range ms {
+		rel, err := filepath.Rel(root, m.Path)
+		if err != nil {
+			t.Fatalf("Rel()=%s", err)
+		}
+
+		if m.Binary {
+			got = append(got, filepath.ToSlash(rel)+":binary")
+		} else {
+			got = append(got, fmt.Sprintf("%s:%d", filepath.ToSlash(rel), m.Line))
+		}
+	}
+
+	sort.Strings(got)
+
+	return got
+}
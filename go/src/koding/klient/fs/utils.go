@@ -1,8 +1,7 @@
 package fs
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/koding/kite"
 )
 
 type FileEntry struct {
@@ -149,58 +150,42 @@ func readFile(path string, offset, blockSize int64) (map[string]interface{}, err
 	return map[string]interface{}{"content": buf}, nil
 }
 
-// compareFileWithHash reads from the given file, comparing it with te given hash.
-// If the given hash and the hashed contents of the file do not match, an error is
-// returned. If there is any problem reading, an error is also returned.
-func compareFileWithHash(f string, h string) error {
-	file, err := os.OpenFile(f, os.O_RDONLY, 0666)
-	if err != nil {
-		return err
+// writeFile implements writing files for the fs.writeFile handler.
+//
+// The file content is replaced atomically, unless data is appended or
+// written at the given offset.
+func writeFile(params writeFileParams) (int, error) {
+	// Only check the precondition if doNotOverwrite is false. If we're not
+	// able to overwrite the file there's no point in comparing it since no
+	// damage can be done.
+	var pc precondition
+	if !params.DoNotOverwrite {
+		pc.hash = params.LastContentHash
+		pc.modTime = params.LastModTime
 	}
-	defer file.Close()
 
-	// Grab the current hash, and compare it to the expectedHash
-	hash := md5.New()
-	_, err = io.Copy(hash, file)
-	if err != nil {
-		return err
-	}
+	if params.Offset == 0 && !params.Append {
+		n, err := atomicWrite(params.Path, bytes.NewReader(params.Content), &writeOptions{
+			exclusive: params.DoNotOverwrite,
+			pc:        pc,
+			keep:      params.History,
+		})
 
-	if h != hex.EncodeToString(hash.Sum(nil)) {
-		return errors.New(fmt.Sprintf(
-			"expected %q's contents to match the %q hash, it does not.",
-			file.Name(), h,
-		))
+		return int(n), err
 	}
 
-	return nil
-}
-
-// writeFile implements writing files for the fs.writeFile handler.
-func writeFile(params writeFileParams) (int, error) {
 	flags := os.O_RDWR | os.O_CREATE
 	if params.DoNotOverwrite {
 		flags |= os.O_EXCL
 	}
 
-	// Only add TRUNC / APPEND flags if no offset has been given.
+	// Only add APPEND flag if no offset has been given.
 	if params.Offset == 0 {
-		if !params.Append {
-			flags |= os.O_TRUNC
-		} else {
-			flags |= os.O_APPEND
-		}
+		flags |= os.O_APPEND
 	}
 
-	// if lastHash isn't empty, the caller is requesting to compare it to a hash before
-	// being modified. Nothing to do.
-	//
-	// Only hash the file if doNotOverwrite is false. If we're not able to overwrite
-	// it there's no point in comparing hashes since no damage can be done.
-	if params.LastContentHash != "" && !params.DoNotOverwrite {
-		if err := compareFileWithHash(params.Path, params.LastContentHash); err != nil {
-			return 0, err
-		}
+	if err := pc.check(params.Path); err != nil {
+		return 0, err
 	}
 
 	file, err := os.OpenFile(params.Path, flags, 0666)
@@ -507,3 +492,49 @@ func copyFile(src, dst string) error {
 
 	return nil
 }
+
+// matchGlobs tells whether either file name or its slash-separated path
+// relative to root matches any of the glob patterns.
+func matchGlobs(patterns []string, root, path, name string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.New("invalid glob pattern: " + pattern)
+		}
+	}
+	return nil
+}
+
+func newError(err error) error {
+	if _, ok := err.(*kite.Error); ok {
+		return err
+	}
+
+	return &kite.Error{
+		Type:    "fsError",
+		Message: err.Error(),
+	}
+}
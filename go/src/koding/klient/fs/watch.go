@@ -0,0 +1,689 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/koding/kite"
+	"github.com/koding/kite/dnode"
+	"gopkg.in/fsnotify.v1"
+)
+
+// Default limits of the Watcher.
+const (
+	DefaultWatchCoalesce       = 200 * time.Millisecond
+	DefaultMaxWatchesPerClient = 32
+	DefaultMaxWatchDirs        = 8192
+)
+
+// Types of watch events.
+const (
+	WatchAdded    = "added"
+	WatchRemoved  = "removed"
+	WatchModified = "modified"
+)
+
+var (
+	errWatchLimit    = errors.New("too many directories to watch")
+	errWatchNotFound = errors.New("watch not found")
+)
+
+// DefaultWatcher is a watcher used by Watch and Unwatch handlers.
+var DefaultWatcher = &Watcher{}
+
+// WatchRequest represents a request value for the "fs.watch" kite method.
+type WatchRequest struct {
+	Path string `json:"path"` // directory to watch recursively; required
+
+	// Exclude skips files and directories whose names or paths relative
+	// to Path match any of the glob patterns.
+	Exclude []string `json:"exclude"`
+
+	OnChange dnode.Function `json:"onChange"` // func([]*WatchEvent): called with coalesced changes; required
+	OnError  dnode.Function `json:"onError"`  // func(string): if valid, called when the watch stops due to an error
+}
+
+// Valid implements the stack.Validator interface.
+func (r *WatchRequest) Valid() error {
+	if r.Path == "" {
+		return errors.New("invalid empty path")
+	}
+	if !r.OnChange.IsValid() {
+		return errors.New("invalid onChange callback")
+	}
+	return validGlobs(r.Exclude)
+}
+
+// WatchEvent describes a change of a single file.
+type WatchEvent struct {
+	Path  string `json:"path"`  // absolute path of the file
+	Type  string `json:"type"`  // one of Watch* constants
+	IsDir bool   `json:"isDir"` // set when the file is a directory
+}
+
+// WatchResponse represents a response value for the "fs.watch" kite method.
+type WatchResponse struct {
+	ID int `json:"id"` // identifies the watch, used to unwatch it
+}
+
+// UnwatchRequest represents a request value for the "fs.unwatch" kite method.
+type UnwatchRequest struct {
+	ID int `json:"id"`
+}
+
+// Valid implements the stack.Validator interface.
+func (r *UnwatchRequest) Valid() error {
+	if r.ID == 0 {
+		return errors.New("invalid zero watch ID")
+	}
+	return nil
+}
+
+// Watcher manages recursive file system watches of remote subscribers.
+//
+// Changes are coalesced, so a burst of events is sent to the subscriber
+// as a single batch. All watches of a subscriber are removed when its
+// kite connection drops.
+//
+// All watches share single fsnotify watcher, which is created when the
+// first directory is watched and closed when the last one is released.
+type Watcher struct {
+	Coalesce     time.Duration // if zero, DefaultWatchCoalesce is used
+	MaxPerClient int           // if zero, DefaultMaxWatchesPerClient is used
+	MaxDirs      int           // per single watch; if zero, DefaultMaxWatchDirs is used
+
+	mu      sync.Mutex
+	id      int
+	watches map[int]*watch
+	owners  map[string]struct{} // owners with registered disconnect hook
+
+	// dirsMu guards the shared watcher. It must not be acquired with mu
+	// held, since removing a watch waits for the dispatcher, which
+	// acquires mu.
+	dirsMu sync.Mutex
+	fw     *fsnotify.Watcher
+	refs   map[string]int // number of watches per directory
+}
+
+// Watch is a kite handler for "fs.watch" method.
+//
+// The request value is expected to be of *WatchRequest type.
+func (w *Watcher) Watch(r *kite.Request) (interface{}, error) {
+	var req WatchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	var owner string
+	if r.Client != nil {
+		owner = r.Client.ID
+	}
+
+	resp, err := w.Start(owner, &req)
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	if r.Client != nil && w.addOwner(owner) {
+		r.Client.OnDisconnect(func() { w.stopOwner(owner) })
+	}
+
+	return resp, nil
+}
+
+// Unwatch is a kite handler for "fs.unwatch" method.
+//
+// The request value is expected to be of *UnwatchRequest type. Only the
+// subscriber that created the watch is allowed to remove it.
+func (w *Watcher) Unwatch(r *kite.Request) (interface{}, error) {
+	var req UnwatchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	var owner string
+	if r.Client != nil {
+		owner = r.Client.ID
+	}
+
+	if err := w.Stop(owner, req.ID); err != nil {
+		return nil, newError(err)
+	}
+
+	return true, nil
+}
+
+// Start starts watching the directory tree described by the request on
+// behalf of the given owner.
+func (w *Watcher) Start(owner string, req *WatchRequest) (*WatchResponse, error) {
+	root, isDir, exist, err := DefaultFS.Abs(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, os.ErrNotExist
+	}
+	if !isDir {
+		return nil, errors.New("path is not a directory")
+	}
+
+	wt := &watch{
+		owner:    owner,
+		root:     root,
+		req:      req,
+		coalesce: w.coalesce(),
+		max:      w.maxDirs(),
+		w:        w,
+		dirs:     make(map[string]struct{}),
+		notify:   make(chan struct{}, 1),
+		closeC:   make(chan struct{}),
+	}
+
+	// The watch is registered before its directories are added, so
+	// events that happen in the meantime are queued.
+	w.mu.Lock()
+	if w.count(owner) >= w.maxPerClient() {
+		w.mu.Unlock()
+		return nil, errors.New("too many watches")
+	}
+	if w.watches == nil {
+		w.watches = make(map[int]*watch)
+	}
+	w.id++
+	id := w.id
+	w.watches[id] = wt
+	w.mu.Unlock()
+
+	if err := wt.addTree(root, nil); err != nil {
+		w.remove(id, wt)
+		return nil, err
+	}
+
+	go func() {
+		err := wt.loop()
+
+		w.remove(id, wt)
+
+		if err != nil && req.OnError.IsValid() {
+			req.OnError.Call(err.Error())
+		}
+	}()
+
+	return &WatchResponse{ID: id}, nil
+}
+
+// Stop removes the watch with the given ID, which must belong to the owner.
+func (w *Watcher) Stop(owner string, id int) error {
+	w.mu.Lock()
+	wt, ok := w.watches[id]
+	if ok && wt.owner == owner {
+		delete(w.watches, id)
+	}
+	w.mu.Unlock()
+
+	if !ok || wt.owner != owner {
+		return errWatchNotFound
+	}
+
+	wt.close()
+
+	return nil
+}
+
+// addOwner tells whether the owner is a new one, which disconnect hook
+// needs to be registered.
+func (w *Watcher) addOwner(owner string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.owners[owner]; ok {
+		return false
+	}
+
+	if w.owners == nil {
+		w.owners = make(map[string]struct{})
+	}
+
+	w.owners[owner] = struct{}{}
+	return true
+}
+
+// stopOwner removes all watches of the given owner.
+func (w *Watcher) stopOwner(owner string) {
+	var stopped []*watch
+
+	w.mu.Lock()
+	delete(w.owners, owner)
+	for id, wt := range w.watches {
+		if wt.owner == owner {
+			delete(w.watches, id)
+			stopped = append(stopped, wt)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, wt := range stopped {
+		wt.close()
+	}
+}
+
+// Len gives the number of active watches.
+func (w *Watcher) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.watches)
+}
+
+// remove unregisters the watch and releases its directories.
+func (w *Watcher) remove(id int, wt *watch) {
+	w.mu.Lock()
+	delete(w.watches, id)
+	w.mu.Unlock()
+
+	for dir := range wt.dirs {
+		w.unwatchDir(dir)
+	}
+
+	wt.dirs = nil
+}
+
+// watchDir adds the directory to the shared watcher.
+func (w *Watcher) watchDir(dir string) error {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	if w.fw == nil {
+		fw, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+
+		w.fw = fw
+		w.refs = make(map[string]int)
+
+		go w.dispatch(fw)
+	}
+
+	// Directory is added even when it's already watched, as it may have
+	// been replaced by a new one with the same path.
+	if err := w.fw.Add(dir); err != nil {
+		if len(w.refs) == 0 {
+			w.fw.Close()
+			w.fw = nil
+		}
+
+		return err
+	}
+
+	w.refs[dir]++
+
+	return nil
+}
+
+// unwatchDir removes the directory from the shared watcher once no watch
+// uses it.
+func (w *Watcher) unwatchDir(dir string) {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	if w.refs[dir]--; w.refs[dir] > 0 {
+		return
+	}
+
+	delete(w.refs, dir)
+
+	// Watches of removed directories are already released by the kernel,
+	// thus the error is ignored.
+	w.fw.Remove(dir)
+
+	if len(w.refs) == 0 {
+		w.fw.Close()
+		w.fw = nil
+	}
+}
+
+// dispatch routes events of the shared watcher to watches of the trees
+// they happened in, until the watcher is closed. Errors stop all watches.
+func (w *Watcher) dispatch(fw *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+
+			w.mu.Lock()
+			for _, wt := range w.watches {
+				if within(wt.root, ev.Name) {
+					wt.push(ev, nil)
+				}
+			}
+			w.mu.Unlock()
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+
+			w.mu.Lock()
+			for _, wt := range w.watches {
+				wt.push(fsnotify.Event{}, err)
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *Watcher) count(owner string) (n int) {
+	for _, wt := range w.watches {
+		if wt.owner == owner {
+			n++
+		}
+	}
+	return n
+}
+
+func (w *Watcher) coalesce() time.Duration {
+	if w.Coalesce > 0 {
+		return w.Coalesce
+	}
+	return DefaultWatchCoalesce
+}
+
+func (w *Watcher) maxPerClient() int {
+	if w.MaxPerClient > 0 {
+		return w.MaxPerClient
+	}
+	return DefaultMaxWatchesPerClient
+}
+
+func (w *Watcher) maxDirs() int {
+	if w.MaxDirs > 0 {
+		return w.MaxDirs
+	}
+	return DefaultMaxWatchDirs
+}
+
+// Watch is a kite handler for "fs.watch" method that uses DefaultWatcher.
+func Watch(r *kite.Request) (interface{}, error) { return DefaultWatcher.Watch(r) }
+
+// Unwatch is a kite handler for "fs.unwatch" method that uses DefaultWatcher.
+func Unwatch(r *kite.Request) (interface{}, error) { return DefaultWatcher.Unwatch(r) }
+
+// watch is a single recursive watch. Since inotify is not recursive, each
+// directory of the tree is added to the shared watcher.
+type watch struct {
+	owner    string
+	root     string
+	req      *WatchRequest
+	coalesce time.Duration
+	max      int
+	w        *Watcher
+
+	dirs    map[string]struct{}    // watched directories
+	pending map[string]*WatchEvent // changes not yet sent, by path
+
+	mu     sync.Mutex
+	queue  []fsnotify.Event // events received from the dispatcher
+	err    error            // error received from the dispatcher
+	notify chan struct{}
+
+	once   sync.Once
+	closeC chan struct{}
+}
+
+// loop processes file system events until the watch is closed. It returns
+// non-nil error when the watch stopped because of a failure.
+func (wt *watch) loop() error {
+	var flush <-chan time.Time
+
+	wt.pending = make(map[string]*WatchEvent)
+
+	for {
+		select {
+		case <-wt.notify:
+			events, err := wt.pop()
+
+			for _, ev := range events {
+				if e := wt.handle(ev); e != nil && err == nil {
+					err = e
+				}
+			}
+
+			if err != nil {
+				wt.flush()
+				return err
+			}
+
+			if flush == nil && len(wt.pending) != 0 {
+				flush = time.After(wt.coalesce)
+			}
+		case <-flush:
+			wt.flush()
+			flush = nil
+		case <-wt.closeC:
+			return nil
+		}
+	}
+}
+
+// push queues the event or error received from the dispatcher. It never
+// blocks, so a slow subscriber does not hold up other watches.
+func (wt *watch) push(ev fsnotify.Event, err error) {
+	wt.mu.Lock()
+	if err != nil {
+		wt.err = err
+	} else {
+		wt.queue = append(wt.queue, ev)
+	}
+	wt.mu.Unlock()
+
+	select {
+	case wt.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (wt *watch) pop() ([]fsnotify.Event, error) {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+
+	events, err := wt.queue, wt.err
+	wt.queue = nil
+
+	return events, err
+}
+
+func (wt *watch) handle(ev fsnotify.Event) error {
+	// Shared watcher also reports changes of directories, which are
+	// watched only by other watches.
+	if !wt.watched(ev.Name) || wt.excluded(ev.Name) {
+		return nil
+	}
+
+	switch {
+	case ev.Op&fsnotify.Create != 0:
+		fi, err := os.Lstat(ev.Name)
+		if err != nil {
+			// File was removed in the meantime, the pending removal
+			// event is going to cancel this one.
+			wt.add(&WatchEvent{Path: ev.Name, Type: WatchAdded})
+			return nil
+		}
+
+		wt.add(&WatchEvent{Path: ev.Name, Type: WatchAdded, IsDir: fi.IsDir()})
+
+		if fi.IsDir() {
+			// Files may have been created in the new directory before
+			// the watch was added, so report all of them.
+			return wt.addTree(ev.Name, func(path string, fi os.FileInfo) {
+				wt.add(&WatchEvent{Path: path, Type: WatchAdded, IsDir: fi.IsDir()})
+			})
+		}
+	case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		_, isDir := wt.dirs[ev.Name]
+
+		if isDir {
+			wt.removeTree(ev.Name)
+		}
+
+		wt.add(&WatchEvent{Path: ev.Name, Type: WatchRemoved, IsDir: isDir})
+	case ev.Op&fsnotify.Write != 0:
+		_, isDir := wt.dirs[ev.Name]
+
+		wt.add(&WatchEvent{Path: ev.Name, Type: WatchModified, IsDir: isDir})
+	}
+
+	return nil
+}
+
+// add merges the event with pending change of the same file.
+func (wt *watch) add(ev *WatchEvent) {
+	prev, ok := wt.pending[ev.Path]
+	if !ok {
+		wt.pending[ev.Path] = ev
+		return
+	}
+
+	switch {
+	case prev.Type == WatchAdded && ev.Type == WatchModified:
+		// File is still new to the subscriber.
+	case prev.Type == WatchAdded && ev.Type == WatchRemoved:
+		// File was created and removed within a burst.
+		delete(wt.pending, ev.Path)
+	case prev.Type == WatchRemoved && ev.Type == WatchRemoved:
+		// Removed directory is reported both by itself and its parent.
+	case prev.Type == WatchRemoved && ev.Type == WatchAdded:
+		// File was replaced.
+		ev.Type = WatchModified
+		wt.pending[ev.Path] = ev
+	default:
+		wt.pending[ev.Path] = ev
+	}
+}
+
+// flush sends pending changes to the subscriber.
+func (wt *watch) flush() {
+	if len(wt.pending) == 0 {
+		return
+	}
+
+	events := make([]*WatchEvent, 0, len(wt.pending))
+	for _, ev := range wt.pending {
+		events = append(events, ev)
+	}
+
+	sort.Sort(watchEvents(events))
+
+	wt.pending = make(map[string]*WatchEvent)
+
+	wt.req.OnChange.Call(events)
+}
+
+// addTree adds watches for dir and all its subdirectories. If fn is
+// non-nil, it is called with each file found in the tree.
+func (wt *watch) addTree(dir string, fn func(string, os.FileInfo)) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil // file was removed in the meantime
+		}
+
+		if path != dir && wt.excluded(path) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if fn != nil && path != dir {
+			fn(path, fi)
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		if _, ok := wt.dirs[path]; ok {
+			return nil
+		}
+
+		if len(wt.dirs) >= wt.max {
+			return errWatchLimit
+		}
+
+		if err := wt.w.watchDir(path); err != nil {
+			if err == syscall.ENOSPC {
+				return errWatchLimit
+			}
+
+			return err
+		}
+
+		wt.dirs[path] = struct{}{}
+
+		return nil
+	})
+}
+
+// removeTree stops watching dir and all its subdirectories. Removed
+// directory was either deleted or moved elsewhere, in which case its
+// subdirectories are still watched by the kernel.
+func (wt *watch) removeTree(dir string) {
+	for path := range wt.dirs {
+		if within(dir, path) {
+			delete(wt.dirs, path)
+			wt.w.unwatchDir(path)
+		}
+	}
+}
+
+// watched checks if the file or its parent directory is watched.
+func (wt *watch) watched(path string) bool {
+	if _, ok := wt.dirs[path]; ok {
+		return true
+	}
+
+	_, ok := wt.dirs[filepath.Dir(path)]
+	return ok
+}
+
+func (wt *watch) excluded(path string) bool {
+	return matchGlobs(wt.req.Exclude, wt.root, path, filepath.Base(path))
+}
+
+func (wt *watch) close() {
+	wt.once.Do(func() {
+		close(wt.closeC)
+	})
+}
+
+// within checks if path is equal to or located inside of the dir.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+type watchEvents []*WatchEvent
+
+func (e watchEvents) Len() int           { return len(e) }
+func (e watchEvents) Less(i, j int) bool { return e[i].Path < e[j].Path }
+func (e watchEvents) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
@@ -0,0 +1,264 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"koding/klient/fs"
+
+	"github.com/koding/kite/dnode"
+)
+
+func TestWatch(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.watch")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "a", "node_modules"), 0755); err != nil {
+		t.Fatalf("MkdirAll()=%s", err)
+	}
+
+	var (
+		w       = &fs.Watcher{Coalesce: 50 * time.Millisecond}
+		changes = make(chan []*fs.WatchEvent, 16)
+	)
+
+	resp, err := w.Start("client", &fs.WatchRequest{
+		Path:     root,
+		Exclude:  []string{"node_modules"},
+		OnChange: dnode.Function{Caller: caller(func(v interface{}) { changes <- v.([]*fs.WatchEvent) })},
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	// Creating a directory with files inside is reported as a single batch
+	// of added files, even when files are created before the directory is
+	// watched.
+	if err := os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755); err != nil {
+		t.Fatalf("MkdirAll()=%s", err)
+	}
+
+	write(t, filepath.Join(root, "a", "b", "c", "file.txt"), "foo")
+	write(t, filepath.Join(root, "a", "node_modules", "dep.js"), "bar")
+
+	waitEvents(t, changes, root,
+		"added a/b dir",
+		"added a/b/c dir",
+		"added a/b/c/file.txt",
+	)
+
+	write(t, filepath.Join(root, "a", "b", "c", "file.txt"), "foobar")
+
+	waitEvents(t, changes, root,
+		"modified a/b/c/file.txt",
+	)
+
+	if err := os.Remove(filepath.Join(root, "a", "b", "c", "file.txt")); err != nil {
+		t.Fatalf("Remove()=%s", err)
+	}
+
+	waitEvents(t, changes, root,
+		"removed a/b/c/file.txt",
+	)
+
+	if err := w.Stop("other", resp.ID); err == nil {
+		t.Fatal("want watch to be removed by its owner only")
+	}
+
+	if err := w.Stop("client", resp.ID); err != nil {
+		t.Fatalf("Stop()=%s", err)
+	}
+
+	if n := w.Len(); n != 0 {
+		t.Fatalf("want no watches, got %d", n)
+	}
+}
+
+func TestWatchLimits(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.watch")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Mkdir()=%s", err)
+		}
+	}
+
+	w := &fs.Watcher{
+		MaxPerClient: 1,
+		MaxDirs:      3,
+	}
+
+	req := &fs.WatchRequest{
+		Path:     filepath.Join(root, "a"),
+		OnChange: dnode.Function{Caller: caller(func(interface{}) {})},
+	}
+
+	if _, err := w.Start("client", req); err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	if _, err := w.Start("client", req); err == nil {
+		t.Fatal("want per client limit to be exceeded")
+	}
+
+	if _, err := w.Start("other", req); err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	req = &fs.WatchRequest{
+		Path:     root,
+		OnChange: dnode.Function{Caller: caller(func(interface{}) {})},
+	}
+
+	if _, err := w.Start("another", req); err == nil {
+		t.Fatal("want directory limit to be exceeded")
+	}
+
+	if n := w.Len(); n != 2 {
+		t.Fatalf("want 2 watches, got %d", n)
+	}
+
+	w.StopOwner("client")
+
+	if n := w.Len(); n != 1 {
+		t.Fatalf("want 1 watch, got %d", n)
+	}
+}
+
+func TestWatchShared(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.watch")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	out, err := ioutil.TempDir("", "fs.watch")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(out)
+
+	if err := os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755); err != nil {
+		t.Fatalf("MkdirAll()=%s", err)
+	}
+
+	var (
+		w        = &fs.Watcher{Coalesce: 50 * time.Millisecond}
+		changes  = make(chan []*fs.WatchEvent, 16)
+		changesA = make(chan []*fs.WatchEvent, 16)
+	)
+
+	resp, err := w.Start("client", &fs.WatchRequest{
+		Path:     root,
+		OnChange: dnode.Function{Caller: caller(func(v interface{}) { changes <- v.([]*fs.WatchEvent) })},
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	respA, err := w.Start("client", &fs.WatchRequest{
+		Path:     filepath.Join(root, "a"),
+		OnChange: dnode.Function{Caller: caller(func(v interface{}) { changesA <- v.([]*fs.WatchEvent) })},
+	})
+	if err != nil {
+		t.Fatalf("Start()=%s", err)
+	}
+
+	// Directories watched by both watches are watched once.
+	if n := w.Dirs(); n != 4 {
+		t.Fatalf("want 4 watched directories, got %d", n)
+	}
+
+	write(t, filepath.Join(root, "a", "b", "file.txt"), "foo")
+
+	waitEvents(t, changes, root, "added a/b/file.txt")
+	waitEvents(t, changesA, filepath.Join(root, "a"), "added b/file.txt")
+
+	if err := w.Stop("client", respA.ID); err != nil {
+		t.Fatalf("Stop()=%s", err)
+	}
+
+	// Directories moved out of the watched tree are no longer watched,
+	// together with their subdirectories.
+	if err := os.Rename(filepath.Join(root, "a", "b"), filepath.Join(out, "b")); err != nil {
+		t.Fatalf("Rename()=%s", err)
+	}
+
+	waitEvents(t, changes, root, "removed a/b dir")
+
+	write(t, filepath.Join(out, "b", "c", "file.txt"), "bar")
+	write(t, filepath.Join(root, "a", "file.txt"), "baz")
+
+	waitEvents(t, changes, root, "added a/file.txt")
+
+	if n := w.Dirs(); n != 2 {
+		t.Fatalf("want 2 watched directories, got %d", n)
+	}
+
+	if err := w.Stop("client", resp.ID); err != nil {
+		t.Fatalf("Stop()=%s", err)
+	}
+
+	timeout := time.After(10 * time.Second)
+
+	for w.Dirs() != 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("want no watched directories, got %d", w.Dirs())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func write(t *testing.T, file, content string) {
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+}
+
+// waitEvents waits for the given events, which may be spread over multiple
+// batches.
+func waitEvents(t *testing.T, changes <-chan []*fs.WatchEvent, root string, want ...string) {
+	var got []string
+
+	timeout := time.After(10 * time.Second)
+
+	for len(got) < len(want) {
+		select {
+		case events := <-changes:
+			for _, ev := range events {
+				rel, err := filepath.Rel(root, ev.Path)
+				if err != nil {
+					t.Fatalf("Rel()=%s", err)
+				}
+
+				s := ev.Type + " " + filepath.ToSlash(rel)
+				if ev.IsDir {
+					s += " dir"
+				}
+
+				got = append(got, s)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v, got %v", want, got)
+		}
+	}
+
+	sort.Strings(got)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
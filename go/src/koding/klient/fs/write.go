@@ -0,0 +1,238 @@
+package fs
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/koding/kite"
+)
+
+// IsConflict tells whether the error means that a file was changed by
+// someone else since the caller last read it.
+func IsConflict(err error) bool {
+	e, ok := err.(*kite.Error)
+	return ok && e.Type == "fsConflict"
+}
+
+func newConflictError(path, format string, args ...interface{}) error {
+	return &kite.Error{
+		Type:    "fsConflict",
+		Message: fmt.Sprintf("%s: file was changed: %s", path, fmt.Sprintf(format, args...)),
+	}
+}
+
+// precondition describes the state of a file the caller expects to
+// overwrite. Zero fields are not checked.
+type precondition struct {
+	hash    string    // md5 of the file content
+	modTime time.Time // modification time of the file, with millisecond precision
+}
+
+func (pc *precondition) empty() bool {
+	return pc.hash == "" && pc.modTime.IsZero()
+}
+
+// check ensures the file at the given path is in the expected state.
+func (pc *precondition) check(path string) error {
+	if pc.empty() {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return newConflictError(path, "file no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Modification time is compared with millisecond precision, since
+	// JavaScript clients round-trip it through Date, which drops the rest.
+	modTime := fi.ModTime().Truncate(time.Millisecond)
+
+	if !pc.modTime.IsZero() && !modTime.Equal(pc.modTime.Truncate(time.Millisecond)) {
+		return newConflictError(path, "modification time is %s, expected %s",
+			fi.ModTime().Format(time.RFC3339Nano), pc.modTime.Format(time.RFC3339Nano))
+	}
+
+	if pc.hash != "" {
+		hash, err := md5File(path)
+		if err != nil {
+			return err
+		}
+
+		if hash != pc.hash {
+			return newConflictError(path, "content hash is %q, expected %q", hash, pc.hash)
+		}
+	}
+
+	return nil
+}
+
+// writeOptions configures atomicWrite.
+type writeOptions struct {
+	exclusive bool         // fail if the file already exists
+	mode      os.FileMode  // permissions of a new file, 0666 if zero; subject to umask
+	pc        precondition // expected state of existing file
+	keep      int          // number of revisions to keep; 0 disables history
+}
+
+// atomicWrite writes content to a temporary file in the same directory
+// as path and renames it over path, so readers never see a partially
+// written file.
+//
+// Mode and ownership of an existing file are preserved. If the path is
+// a symlink, its target is replaced. When history is enabled, the previous
+// content of the file is saved as a revision.
+//
+// Existing file is overwritten in place instead when the directory is not
+// writable or the temporary file can't be given the owner of the file.
+func atomicWrite(path string, content io.Reader, opts *writeOptions) (int64, error) {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	// Fail early before writing the content.
+	if err := opts.pc.check(path); err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil && opts.exclusive:
+		return 0, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+	case os.IsNotExist(err):
+		fi = nil
+	case err != nil:
+		return 0, err
+	}
+
+	mode := opts.mode
+	if mode == 0 {
+		mode = 0666
+	}
+
+	tmp, err := tempFile(path, mode)
+	if os.IsPermission(err) && fi != nil {
+		return writeInPlace(path, content, opts)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if fi != nil {
+		if err = os.Chmod(tmp.Name(), fi.Mode().Perm()); err == nil {
+			err = chown(tmp.Name(), fi)
+		}
+
+		// Replacing the file would change its owner.
+		if os.IsPermission(err) {
+			tmp.Close()
+			os.Remove(tmp.Name())
+
+			return writeInPlace(path, content, opts)
+		}
+	}
+
+	var n int64
+	if err == nil {
+		n, err = io.Copy(tmp, content)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		// Check again right before replacing the file, as it could have
+		// been changed while the content was written.
+		err = opts.pc.check(path)
+	}
+	if err == nil && fi != nil && opts.keep > 0 {
+		err = saveRevision(path, opts.keep, true)
+	}
+	if err == nil {
+		if opts.exclusive {
+			// Unlike rename, link fails if the file already exists.
+			if err = os.Link(tmp.Name(), path); err == nil {
+				err = os.Remove(tmp.Name())
+			}
+		} else {
+			err = os.Rename(tmp.Name(), path)
+		}
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// writeInPlace truncates the existing file and writes content to it, which
+// keeps its mode and ownership, but readers may see a partially written
+// file.
+func writeInPlace(path string, content io.Reader, opts *writeOptions) (int64, error) {
+	if err := opts.pc.check(path); err != nil {
+		return 0, err
+	}
+
+	if opts.keep > 0 {
+		if err := saveRevision(path, opts.keep, false); err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	return n, err
+}
+
+// tempFile creates a new hidden file next to the given path.
+func tempFile(path string, mode os.FileMode) (f *os.File, err error) {
+	dir, base := filepath.Split(path)
+
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", base, time.Now().UnixNano()))
+
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+
+	return nil, err
+}
+
+func md5File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
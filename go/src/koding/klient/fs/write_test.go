@@ -0,0 +1,257 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.write")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	file := filepath.Join(root, "file.txt")
+
+	if err := ioutil.WriteFile(file, []byte("foo"), 0600); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	if _, err := writeFile(writeFileParams{Path: file, Content: []byte("foobar")}); err != nil {
+		t.Fatalf("writeFile()=%s", err)
+	}
+
+	testContent(t, file, "foobar")
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat()=%s", err)
+	}
+
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("want mode to be preserved, got %s", fi.Mode())
+	}
+
+	if _, err := writeFile(writeFileParams{Path: file, Content: []byte("bar"), DoNotOverwrite: true}); !os.IsExist(err) {
+		t.Fatalf("want os.ErrExist, got %v", err)
+	}
+
+	// A failed write must not leave a temporary file behind.
+	fis, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatalf("ReadDir()=%s", err)
+	}
+
+	if len(fis) != 1 {
+		t.Fatalf("want 1 file, got %d", len(fis))
+	}
+
+	testContent(t, file, "foobar")
+}
+
+func TestWriteFileConflict(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.write")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	file := filepath.Join(root, "file.txt")
+
+	if err := ioutil.WriteFile(file, []byte("foo"), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat()=%s", err)
+	}
+
+	hash, err := md5File(file)
+	if err != nil {
+		t.Fatalf("md5File()=%s", err)
+	}
+
+	cases := map[string]writeFileParams{
+		"modification time": {
+			LastModTime: fi.ModTime().Add(-time.Millisecond),
+		},
+		"content hash": {
+			LastContentHash: "d41d8cd98f00b204e9800998ecf8427e",
+		},
+		"appended content hash": {
+			LastContentHash: "d41d8cd98f00b204e9800998ecf8427e",
+			Append:          true,
+		},
+	}
+
+	for name, params := range cases {
+		t.Run(name, func(t *testing.T) {
+			params.Path = file
+			params.Content = []byte("bar")
+
+			if _, err := writeFile(params); !IsConflict(err) {
+				t.Fatalf("want conflict error, got %v", err)
+			}
+
+			testContent(t, file, "foo")
+		})
+	}
+
+	// Clients written in JavaScript send modification time back with
+	// millisecond precision.
+	params := writeFileParams{
+		Path:            file,
+		Content:         []byte("bar"),
+		LastModTime:     fi.ModTime().Truncate(time.Millisecond),
+		LastContentHash: hash,
+	}
+
+	if _, err := writeFile(params); err != nil {
+		t.Fatalf("writeFile()=%s", err)
+	}
+
+	testContent(t, file, "bar")
+}
+
+func TestWriteInPlace(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.write")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	defer func(dir string) { HistoryDir = dir }(HistoryDir)
+	HistoryDir = filepath.Join(root, "history")
+
+	file := filepath.Join(root, "file.txt")
+
+	if err := ioutil.WriteFile(file, []byte("foobar"), 0600); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat()=%s", err)
+	}
+
+	if _, err := writeInPlace(file, strings.NewReader("bar"), &writeOptions{keep: 1}); err != nil {
+		t.Fatalf("writeInPlace()=%s", err)
+	}
+
+	testContent(t, file, "bar")
+
+	newFi, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat()=%s", err)
+	}
+
+	if !os.SameFile(fi, newFi) {
+		t.Fatal("want file to be overwritten in place")
+	}
+
+	// Revision is a copy, since the file was modified.
+	revs, err := history(file)
+	if err != nil {
+		t.Fatalf("history()=%s", err)
+	}
+
+	if len(revs) != 1 {
+		t.Fatalf("want 1 revision, got %d", len(revs))
+	}
+
+	testContent(t, filepath.Join(mustHistoryDir(t, file), revs[0].ID), "foobar")
+}
+
+func TestHistory(t *testing.T) {
+	root, err := ioutil.TempDir("", "fs.history")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(root)
+
+	defer func(dir string) { HistoryDir = dir }(HistoryDir)
+	HistoryDir = filepath.Join(root, "history")
+
+	file := filepath.Join(root, "file.txt")
+
+	for _, content := range []string{"1", "2", "3", "4"} {
+		params := writeFileParams{
+			Path:    file,
+			Content: []byte(content),
+			History: 2,
+		}
+
+		if _, err := writeFile(params); err != nil {
+			t.Fatalf("writeFile()=%s", err)
+		}
+	}
+
+	revs, err := history(file)
+	if err != nil {
+		t.Fatalf("history()=%s", err)
+	}
+
+	if len(revs) != 2 {
+		t.Fatalf("want 2 revisions, got %d", len(revs))
+	}
+
+	// Revisions are ordered newest first.
+	for i, want := range []string{"3", "2"} {
+		testContent(t, filepath.Join(mustHistoryDir(t, file), revs[i].ID), want)
+	}
+
+	if err := restore(file, revs[1].ID, 0); err != nil {
+		t.Fatalf("restore()=%s", err)
+	}
+
+	testContent(t, file, "2")
+
+	// Restore saves replaced content, so it can be undone.
+	revs, err = history(file)
+	if err != nil {
+		t.Fatalf("history()=%s", err)
+	}
+
+	if len(revs) != 3 {
+		t.Fatalf("want 3 revisions, got %d", len(revs))
+	}
+
+	if revs[0].Size != 1 || revs[0].Hash == "" {
+		t.Fatalf("unexpected revision: %+v", revs[0])
+	}
+
+	if err := restore(file, revs[0].ID, 0); err != nil {
+		t.Fatalf("restore()=%s", err)
+	}
+
+	testContent(t, file, "4")
+
+	if err := restore(file, "00000000000000000000-nonexisting", 0); err == nil {
+		t.Fatal("want restore of non-existing revision to fail")
+	}
+}
+
+func mustHistoryDir(t *testing.T, path string) string {
+	dir, err := historyDir(path)
+	if err != nil {
+		t.Fatalf("historyDir()=%s", err)
+	}
+	return dir
+}
+
+func testContent(t *testing.T, file, want string) {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("ReadFile()=%s", err)
+	}
+
+	if got := string(p); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+package logship
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// batchExt is an extension of files that store buffered batches.
+const batchExt = ".batch"
+
+// Buffer is a disk-backed FIFO queue of record batches. It allows shipping
+// to survive sink outages and klient restarts.
+//
+// Buffer is not bounded by itself - it is the caller's responsibility to
+// stop pushing new batches when Full reports true.
+type Buffer struct {
+	dir string
+	max int64
+
+	mu      sync.Mutex
+	batches []string         // names of buffered batches, oldest first
+	sizes   map[string]int64 // size of each buffered batch
+	size    int64            // overall size of buffered batches
+	seq     uint64           // sequence number of the last batch
+
+	notify chan struct{}
+}
+
+// NewBuffer creates a buffer which stores batches in the given directory.
+// Batches left in the directory by previous runs are restored.
+//
+// The max defines the size in bytes after which the buffer is considered
+// full.
+func NewBuffer(dir string, max int64) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Buffer{
+		dir:    dir,
+		max:    max,
+		sizes:  make(map[string]int64),
+		notify: make(chan struct{}, 1),
+	}
+
+	for _, fi := range fis {
+		name := fi.Name()
+
+		// Remove leftovers of interrupted writes.
+		if strings.HasSuffix(name, batchExt+".tmp") {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		if fi.IsDir() || filepath.Ext(name) != batchExt {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if seq > b.seq {
+			b.seq = seq
+		}
+
+		b.batches = append(b.batches, name)
+		b.sizes[name] = fi.Size()
+		b.size += fi.Size()
+	}
+
+	// Names are zero-padded, so lexical order is the sequence order.
+	sort.Strings(b.batches)
+
+	return b, nil
+}
+
+// Push adds a new batch of records to the buffer.
+func (b *Buffer) Push(recs []*Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	p, err := encode(recs)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.seq++
+	name := fmt.Sprintf("%020d%s", b.seq, batchExt)
+	b.mu.Unlock()
+
+	// Write to a temporary file first, so partially written batches are
+	// not restored after a crash.
+	tmp := filepath.Join(b.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, p, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(b.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	b.mu.Lock()
+	b.batches = append(b.batches, name)
+	b.sizes[name] = int64(len(p))
+	b.size += int64(len(p))
+	b.mu.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Peek gives the oldest batch in the buffer. If the buffer is empty, the
+// returned name is empty.
+func (b *Buffer) Peek() (name string, recs []*Record, err error) {
+	b.mu.Lock()
+	if len(b.batches) != 0 {
+		name = b.batches[0]
+	}
+	b.mu.Unlock()
+
+	if name == "" {
+		return "", nil, nil
+	}
+
+	p, err := ioutil.ReadFile(filepath.Join(b.dir, name))
+	if err != nil {
+		return name, nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	scanner.Buffer(nil, len(p)+1)
+
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return name, nil, err
+		}
+
+		recs = append(recs, &rec)
+	}
+
+	return name, recs, scanner.Err()
+}
+
+// Pop removes the batch with the given name from the buffer.
+func (b *Buffer) Pop(name string) error {
+	b.mu.Lock()
+	for i, batch := range b.batches {
+		if batch == name {
+			b.batches = append(b.batches[:i], b.batches[i+1:]...)
+			b.size -= b.sizes[name]
+			delete(b.sizes, name)
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(b.dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Size gives the overall size of buffered batches in bytes.
+func (b *Buffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size
+}
+
+// Len gives the number of buffered batches.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.batches)
+}
+
+// Full tells whether the buffer reached its maximum size.
+func (b *Buffer) Full() bool {
+	return b.max > 0 && b.Size() >= b.max
+}
+
+// Notify gives a channel which receives a value when a new batch is pushed.
+func (b *Buffer) Notify() <-chan struct{} {
+	return b.notify
+}
@@ -0,0 +1,545 @@
+// Package logship continuously ships log files to a sink.
+//
+// New content of each configured file is detected with logrotate metadata,
+// so shipping resumes where it stopped after klient restart and starts from
+// the beginning when a log file gets rotated. Lines are parsed into
+// structured records, buffered on disk and sent to the sink in batches.
+//
+// When the sink is not able to keep up and the disk buffer fills up, reading
+// of log files is paused until buffered batches are sent.
+package logship
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"koding/klient/storage"
+	"koding/logrotate"
+
+	"github.com/koding/logging"
+)
+
+var defaultLog = logging.NewCustom("logship", false)
+
+// Default values used when Options fields are zero.
+const (
+	DefaultMaxBufferSize = 64 * 1024 * 1024
+	DefaultMaxReadSize   = 1024 * 1024
+	DefaultBatchSize     = 500
+	DefaultPollInterval  = 5 * time.Second
+)
+
+// Delays between retries of failed sink writes.
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// File describes a log file to ship.
+type File struct {
+	// Path is a path to the log file.
+	Path string `json:"path"`
+
+	// Format is the format of the log file, one of Format* constants.
+	// If empty, FormatRaw is used.
+	Format string `json:"format,omitempty"`
+
+	// Fields are added to each record read from the file.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Config is a log shipping configuration, as read from configuration file.
+type Config struct {
+	Files         []*File    `json:"files"`
+	Sink          SinkConfig `json:"sink"`
+	BufferDir     string     `json:"bufferDir,omitempty"`
+	MaxBufferSize int64      `json:"maxBufferSize,omitempty"`
+	PollInterval  string     `json:"pollInterval,omitempty"`
+}
+
+// ReadConfig reads log shipping configuration from the given JSON file.
+func ReadConfig(file string) (*Config, error) {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(p, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Files) == 0 {
+		return nil, errors.New("no log files configured")
+	}
+
+	return &cfg, nil
+}
+
+// Options creates shipper options from the configuration. Fields that are
+// not part of the configuration must be set by the caller.
+func (cfg *Config) Options(sink Sink) (*Options, error) {
+	opts := &Options{
+		Files:         cfg.Files,
+		Sink:          sink,
+		BufferDir:     cfg.BufferDir,
+		MaxBufferSize: cfg.MaxBufferSize,
+	}
+
+	if cfg.PollInterval != "" {
+		d, err := time.ParseDuration(cfg.PollInterval)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.PollInterval = d
+	}
+
+	return opts, nil
+}
+
+// Options are used to configure Shipper.
+type Options struct {
+	Files []*File // required
+	Sink  Sink    // required
+
+	// BufferDir is a directory where batches are buffered before they are
+	// sent to the sink.
+	//
+	// Required.
+	BufferDir string
+
+	// MaxBufferSize is the size of buffered batches in bytes after which
+	// reading of log files is paused.
+	//
+	// If zero, DefaultMaxBufferSize is used.
+	MaxBufferSize int64
+
+	// MaxReadSize limits the number of bytes read from single file
+	// at once.
+	//
+	// If zero, DefaultMaxReadSize is used.
+	MaxReadSize int64
+
+	// BatchSize is the maximum number of records in a single batch.
+	//
+	// If zero, DefaultBatchSize is used.
+	BatchSize int
+
+	// PollInterval tells how often log files are checked for new content.
+	//
+	// If zero, DefaultPollInterval is used.
+	PollInterval time.Duration
+
+	// MetaStore is used to store read offsets of log files.
+	//
+	// If nil, in-memory storage is used.
+	MetaStore storage.ValueInterface
+
+	// Host is set on each record. If empty, os.Hostname is used.
+	Host string
+
+	// Log is used for logging. If nil, defaultLog is used.
+	Log logging.Logger
+}
+
+// Shipper ships log files to a sink.
+type Shipper struct {
+	opts    Options
+	buf     *Buffer
+	parsers map[string]Parser // parser per file path
+	log     logging.Logger
+
+	wg    sync.WaitGroup
+	once  sync.Once
+	close chan struct{}
+}
+
+// New creates a new shipper and starts shipping log files in background.
+func New(opts *Options) (*Shipper, error) {
+	if len(opts.Files) == 0 {
+		return nil, errors.New("no log files to ship")
+	}
+
+	if opts.Sink == nil {
+		return nil, errors.New("sink is required")
+	}
+
+	if opts.BufferDir == "" {
+		return nil, errors.New("buffer directory is required")
+	}
+
+	s := &Shipper{
+		opts:    *opts,
+		parsers: make(map[string]Parser, len(opts.Files)),
+		log:     opts.Log,
+		close:   make(chan struct{}),
+	}
+
+	if s.log == nil {
+		s.log = defaultLog
+	}
+
+	for _, f := range opts.Files {
+		p, err := NewParser(f.Format)
+		if err != nil {
+			return nil, err
+		}
+
+		s.parsers[f.Path] = p
+	}
+
+	if s.opts.MaxBufferSize == 0 {
+		s.opts.MaxBufferSize = DefaultMaxBufferSize
+	}
+
+	if s.opts.MaxReadSize == 0 {
+		s.opts.MaxReadSize = DefaultMaxReadSize
+	}
+
+	if s.opts.BatchSize == 0 {
+		s.opts.BatchSize = DefaultBatchSize
+	}
+
+	if s.opts.PollInterval == 0 {
+		s.opts.PollInterval = DefaultPollInterval
+	}
+
+	if s.opts.MetaStore == nil {
+		s.opts.MetaStore = storage.NewEncodingStorage(nil, []byte("logship"))
+	}
+
+	if s.opts.Host == "" {
+		s.opts.Host, _ = os.Hostname()
+	}
+
+	buf, err := NewBuffer(s.opts.BufferDir, s.opts.MaxBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s.buf = buf
+
+	s.wg.Add(2)
+	go s.read()
+	go s.send()
+
+	return s, nil
+}
+
+// Close stops shipping and closes the sink. Records that were not yet sent
+// stay in the disk buffer and are sent after the shipper is created again.
+func (s *Shipper) Close() error {
+	s.once.Do(func() {
+		close(s.close)
+	})
+
+	s.wg.Wait()
+
+	return s.opts.Sink.Close()
+}
+
+func (s *Shipper) read() {
+	defer s.wg.Done()
+
+	t := time.NewTicker(s.opts.PollInterval)
+	defer t.Stop()
+
+	for {
+		s.shipAll()
+
+		select {
+		case <-s.close:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Shipper) shipAll() {
+	for _, f := range s.opts.Files {
+		if s.buf.Full() {
+			s.log.Debug("buffer is full, pausing reading of log files")
+			return
+		}
+
+		// Read the file until there's nothing new left or the buffer
+		// gets full.
+		for {
+			n, err := s.ship(f)
+			if err != nil && !os.IsNotExist(err) {
+				s.log.Warning("%s: failed to ship: %s", f.Path, err)
+			}
+
+			if n == 0 || s.buf.Full() {
+				break
+			}
+
+			select {
+			case <-s.close:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// ship reads new content of the given file, parses it and pushes it to the
+// buffer. It returns the number of bytes read.
+func (s *Shipper) ship(f *File) (int64, error) {
+	fd, err := os.Open(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	meta := s.meta(f.Path)
+
+	// Ship only complete lines starting from the position where the
+	// previous part ended. If the file was truncated or replaced,
+	// logrotate detects it and we start from the beginning.
+	var start int64
+	if last := meta.LastPart(); last != nil && last.Size <= fi.Size() {
+		start = last.Size
+	}
+
+	limit := fi.Size()
+	if limit-start > s.opts.MaxReadSize {
+		limit = start + s.opts.MaxReadSize
+	}
+
+	end, err := lineEnd(fd, start, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if end == -1 {
+		if limit-start < s.opts.MaxReadSize {
+			return 0, nil // wait for the line to be completed
+		}
+
+		end = limit // line is too long, split it
+	}
+
+	content := io.NewSectionReader(fd, 0, end)
+
+	part, err := logrotate.Rotate(content, meta)
+	if logrotate.IsNop(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	recs, ends, err := s.parse(f, io.NewSectionReader(fd, part.Offset, end-part.Offset), part.Offset)
+	if err != nil {
+		return 0, err
+	}
+
+	for len(recs) != 0 {
+		n := len(recs)
+		if n > s.opts.BatchSize {
+			n = s.opts.BatchSize
+		}
+
+		if err := s.buf.Push(recs[:n]); err != nil {
+			return 0, err
+		}
+
+		// Store the position after each pushed batch, so its records
+		// are not shipped again when pushing the next batch fails.
+		if n < len(recs) {
+			if err := s.commit(f.Path, meta, fd, ends[n-1]); err != nil {
+				return 0, err
+			}
+		}
+
+		recs, ends = recs[n:], ends[n:]
+	}
+
+	// Only the last part is needed to detect new content.
+	meta.Parts = []*logrotate.MetadataPart{part}
+
+	if err := s.opts.MetaStore.SetValue(f.Path, meta); err != nil {
+		return 0, err
+	}
+
+	return part.Size - part.Offset, nil
+}
+
+// commit stores the metadata of the file, which content was shipped up to
+// the given size.
+func (s *Shipper) commit(path string, meta *logrotate.Metadata, fd *os.File, size int64) error {
+	part, err := logrotate.Rotate(io.NewSectionReader(fd, 0, size), meta)
+	if err != nil {
+		return err
+	}
+
+	return s.opts.MetaStore.SetValue(path, &logrotate.Metadata{
+		Key:   meta.Key,
+		Parts: []*logrotate.MetadataPart{part},
+	})
+}
+
+// parse parses lines read from r, which starts at the given offset of the
+// file. For each record it also returns the file offset its line ends at.
+func (s *Shipper) parse(f *File, r io.Reader, offset int64) ([]*Record, []int64, error) {
+	var (
+		recs []*Record
+		ends []int64
+		now  = time.Now().UTC()
+		br   = bufio.NewReader(r)
+		p    = s.parsers[f.Path]
+	)
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+
+		offset += int64(len(line))
+
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			rec, perr := p.Parse(line)
+			if perr != nil {
+				rec = &Record{
+					Message: line,
+					Fields: map[string]interface{}{
+						"parseError": perr.Error(),
+					},
+				}
+			}
+
+			if rec.Time.IsZero() {
+				rec.Time = now
+			}
+
+			if rec.Host == "" {
+				rec.Host = s.opts.Host
+			}
+
+			rec.Source = f.Path
+
+			for k, v := range f.Fields {
+				if rec.Fields == nil {
+					rec.Fields = make(map[string]interface{}, len(f.Fields))
+				}
+
+				rec.Fields[k] = v
+			}
+
+			recs = append(recs, rec)
+			ends = append(ends, offset)
+		}
+
+		if err == io.EOF {
+			return recs, ends, nil
+		}
+	}
+}
+
+func (s *Shipper) send() {
+	defer s.wg.Done()
+
+	backoff := minBackoff
+
+	for {
+		name, recs, err := s.buf.Peek()
+
+		switch {
+		case name == "":
+			select {
+			case <-s.close:
+				return
+			case <-s.buf.Notify():
+			}
+
+			continue
+		case err != nil:
+			// Corrupted batch can't be recovered, drop it.
+			s.log.Error("dropping invalid batch %q: %s", name, err)
+		default:
+			if err := s.opts.Sink.Write(recs); err != nil {
+				s.log.Warning("failed to send %d records, retrying in %s: %s", len(recs), backoff, err)
+
+				select {
+				case <-s.close:
+					return
+				case <-time.After(backoff):
+				}
+
+				if backoff *= 2; backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+
+				continue
+			}
+
+			backoff = minBackoff
+		}
+
+		if err := s.buf.Pop(name); err != nil {
+			s.log.Error("failed to remove batch %q: %s", name, err)
+		}
+
+		select {
+		case <-s.close:
+			return
+		default:
+		}
+	}
+}
+
+func (s *Shipper) meta(path string) *logrotate.Metadata {
+	var meta logrotate.Metadata
+
+	err := s.opts.MetaStore.GetValue(path, &meta)
+	if err == nil {
+		return &meta
+	}
+
+	if err != storage.ErrKeyNotFound {
+		s.log.Warning("%s: failure reading metadata: %s", path, err)
+	}
+
+	return &logrotate.Metadata{Key: path}
+}
+
+// lineEnd gives the position right after the last new line character in
+// the [start, limit) range of r. If there's none, it returns -1.
+func lineEnd(r io.ReaderAt, start, limit int64) (int64, error) {
+	const chunk = 4096
+
+	p := make([]byte, chunk)
+
+	for end := limit; end > start; end -= chunk {
+		off := end - chunk
+		if off < start {
+			off = start
+		}
+
+		n, err := r.ReadAt(p[:end-off], off)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if i := strings.LastIndexByte(string(p[:n]), '\n'); i != -1 {
+			return off + int64(i) + 1, nil
+		}
+	}
+
+	return -1, nil
+}
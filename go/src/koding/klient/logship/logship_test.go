@@ -0,0 +1,417 @@
+package logship_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/klient/logship"
+	"koding/klient/storage"
+)
+
+func TestParse(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := map[string]struct {
+		parser logship.Parser
+		line   string
+		want   *logship.Record
+	}{
+		"json": {
+			logship.JSONParser{},
+			`{"time":"2017-02-03T04:05:06Z","level":"INFO","msg":"started","port":56789}`,
+			&logship.Record{
+				Time:    time.Date(2017, 2, 3, 4, 5, 6, 0, time.UTC),
+				Level:   "info",
+				Message: "started",
+				Fields:  map[string]interface{}{"port": float64(56789)},
+			},
+		},
+		"syslog": {
+			&logship.SyslogParser{Now: now},
+			`<30>Feb  3 04:05:06 vm-0 systemd[1]: Started klient.`,
+			&logship.Record{
+				Time:    time.Date(2017, 2, 3, 4, 5, 6, 0, time.UTC),
+				Host:    "vm-0",
+				Level:   "info",
+				Message: "Started klient.",
+				Fields:  map[string]interface{}{"app": "systemd", "pid": 1, "facility": 3},
+			},
+		},
+		"syslog previous year": {
+			&logship.SyslogParser{Now: now},
+			`Dec 31 23:59:59 vm-0 cron: done`,
+			&logship.Record{
+				Time:    time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC),
+				Host:    "vm-0",
+				Message: "done",
+				Fields:  map[string]interface{}{"app": "cron"},
+			},
+		},
+		"nginx": {
+			logship.NginxParser{},
+			`10.0.0.1 - - [03/Feb/2017:04:05:06 +0000] "GET /index.html HTTP/1.1" 404 12 "-" "curl/7.47.0"`,
+			&logship.Record{
+				Time:    time.Date(2017, 2, 3, 4, 5, 6, 0, time.FixedZone("", 0)),
+				Level:   "warning",
+				Message: "GET /index.html HTTP/1.1",
+				Fields: map[string]interface{}{
+					"remote_addr":     "10.0.0.1",
+					"status":          404,
+					"body_bytes_sent": 12,
+					"http_user_agent": "curl/7.47.0",
+					"method":          "GET",
+					"path":            "/index.html",
+					"protocol":        "HTTP/1.1",
+				},
+			},
+		},
+	}
+
+	for name, cas := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec, err := cas.parser.Parse(cas.line)
+			if err != nil {
+				t.Fatalf("Parse()=%s", err)
+			}
+
+			if !rec.Time.Equal(cas.want.Time) {
+				t.Fatalf("got %s, want %s", rec.Time, cas.want.Time)
+			}
+
+			rec.Time = cas.want.Time
+
+			if !reflect.DeepEqual(rec, cas.want) {
+				t.Fatalf("got %+v, want %+v", rec, cas.want)
+			}
+		})
+	}
+}
+
+func TestShipper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		log  = filepath.Join(dir, "app.log")
+		out  = filepath.Join(dir, "out.log")
+		meta = &storage.EncodingStorage{Interface: storage.NewMemoryStorage()}
+	)
+
+	newShipper := func() *logship.Shipper {
+		s, err := logship.New(&logship.Options{
+			Files: []*logship.File{{
+				Path:   log,
+				Format: logship.FormatJSON,
+				Fields: map[string]interface{}{"service": "app"},
+			}},
+			Sink:         &logship.FileSink{Path: out},
+			BufferDir:    filepath.Join(dir, "buffer"),
+			PollInterval: 10 * time.Millisecond,
+			MetaStore:    meta,
+			Host:         "test",
+		})
+		if err != nil {
+			t.Fatalf("New()=%s", err)
+		}
+
+		return s
+	}
+
+	// The last line is not completed yet, so it must not be shipped.
+	write(t, log, os.O_CREATE, `{"msg":"a"}`+"\n"+`{"msg":"b"}`+"\n"+`{"msg":`)
+
+	s := newShipper()
+	waitMessages(t, out, "a", "b")
+
+	write(t, log, os.O_APPEND, `"c"}`+"\n")
+	waitMessages(t, out, "a", "b", "c")
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close()=%s", err)
+	}
+
+	// Shipping must resume from the previous position.
+	write(t, log, os.O_APPEND, `{"msg":"d"}`+"\n")
+
+	s = newShipper()
+	defer s.Close()
+
+	waitMessages(t, out, "a", "b", "c", "d")
+
+	// Rotated file must be shipped from the beginning.
+	if err := os.Rename(log, log+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, log, os.O_CREATE, `{"msg":"e"}`+"\n")
+	recs := waitMessages(t, out, "a", "b", "c", "d", "e")
+
+	for _, rec := range recs {
+		if rec.Source != log || rec.Host != "test" || rec.Fields["service"] != "app" {
+			t.Fatalf("unexpected record: %+v", rec)
+		}
+	}
+}
+
+func TestShipperBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "app.log")
+	write(t, log, os.O_CREATE, "a\nb\nc\n")
+
+	sink := &failingSink{fail: 1}
+
+	s, err := logship.New(&logship.Options{
+		Files:        []*logship.File{{Path: log}},
+		Sink:         sink,
+		BufferDir:    filepath.Join(dir, "buffer"),
+		BatchSize:    2,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New()=%s", err)
+	}
+	defer s.Close()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		if msgs := sink.messages(); len(msgs) == 3 {
+			if want := []string{"a", "b", "c"}; !reflect.DeepEqual(msgs, want) {
+				t.Fatalf("got %v, want %v", msgs, want)
+			}
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for records: %v", sink.messages())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(dir, "buffer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fis) != 0 {
+		t.Fatalf("want buffer to be empty, got %d batches", len(fis))
+	}
+}
+
+func TestShipperPushFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "app.log")
+	write(t, log, os.O_CREATE, "a\nb\nc\n")
+
+	// Make pushing of the second batch fail, as its temporary file
+	// can't be written.
+	buffer := filepath.Join(dir, "buffer")
+	if err := os.MkdirAll(filepath.Join(buffer, "00000000000000000002.batch.tmp", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &failingSink{}
+
+	s, err := logship.New(&logship.Options{
+		Files:        []*logship.File{{Path: log}},
+		Sink:         sink,
+		BufferDir:    buffer,
+		BatchSize:    1,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New()=%s", err)
+	}
+	defer s.Close()
+
+	timeout := time.After(10 * time.Second)
+	for len(sink.messages()) < 3 {
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for records: %v", sink.messages())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Wait for records that could be shipped again.
+	time.Sleep(100 * time.Millisecond)
+
+	if msgs, want := sink.messages(), []string{"a", "b", "c"}; !reflect.DeepEqual(msgs, want) {
+		t.Fatalf("got %v, want %v", msgs, want)
+	}
+}
+
+func TestBucketSink(t *testing.T) {
+	bucket := &memBucket{objects: make(map[string]int)}
+	sink := &logship.BucketSink{Bucket: bucket, Prefix: "logs", Host: "vm-0"}
+
+	// Batches starting with records of the same time must not overwrite
+	// each other.
+	recs := []*logship.Record{{
+		Time:    time.Date(2017, 2, 3, 4, 5, 6, 0, time.UTC),
+		Message: "started",
+	}}
+
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(recs); err != nil {
+			t.Fatalf("Write()=%s", err)
+		}
+	}
+
+	if len(bucket.objects) != 3 {
+		t.Fatalf("want 3 objects, got %v", bucket.objects)
+	}
+
+	for key, n := range bucket.objects {
+		if n != 1 {
+			t.Errorf("%s: want object to be written once, got %d", key, n)
+		}
+
+		if filepath.Dir(key) != "logs" {
+			t.Errorf("%s: want object under logs prefix", key)
+		}
+	}
+}
+
+type memBucket struct {
+	mu      sync.Mutex
+	objects map[string]int
+}
+
+func (mb *memBucket) Put(key string, _ io.ReadSeeker) (*url.URL, error) {
+	mb.mu.Lock()
+	mb.objects[key]++
+	mb.mu.Unlock()
+
+	return mb.URL(key), nil
+}
+
+func (mb *memBucket) URL(key string) *url.URL {
+	return &url.URL{Scheme: "s3", Host: "bucket", Path: "/" + key}
+}
+
+type failingSink struct {
+	mu   sync.Mutex
+	fail int
+	recs []*logship.Record
+}
+
+func (fs *failingSink) Write(recs []*logship.Record) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.fail > 0 {
+		fs.fail--
+		return errors.New("sink is down")
+	}
+
+	fs.recs = append(fs.recs, recs...)
+	return nil
+}
+
+func (fs *failingSink) Close() error { return nil }
+
+func (fs *failingSink) messages() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var msgs []string
+	for _, rec := range fs.recs {
+		msgs = append(msgs, rec.Message)
+	}
+
+	return msgs
+}
+
+func write(t *testing.T, file string, flag int, content string) {
+	f, err := os.OpenFile(file, os.O_WRONLY|flag, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.WriteString(content)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitMessages(t *testing.T, file string, want ...string) []*logship.Record {
+	timeout := time.After(10 * time.Second)
+
+	for {
+		recs := readRecords(t, file)
+
+		var got []string
+		for _, rec := range recs {
+			got = append(got, rec.Message)
+		}
+
+		if reflect.DeepEqual(got, want) {
+			return recs
+		}
+
+		if len(got) > len(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v, got %v", want, got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func readRecords(t *testing.T, file string) []*logship.Record {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var recs []*logship.Record
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var rec logship.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+
+		recs = append(recs, &rec)
+	}
+
+	return recs
+}
@@ -0,0 +1,246 @@
+package logship
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported log formats.
+const (
+	FormatRaw    = "raw"    // each line is a message
+	FormatJSON   = "json"   // JSON lines
+	FormatSyslog = "syslog" // RFC 3164 syslog messages
+	FormatNginx  = "nginx"  // nginx combined access log
+)
+
+// Record is a single structured log entry.
+type Record struct {
+	Time    time.Time              `json:"time"`
+	Source  string                 `json:"source"`
+	Host    string                 `json:"host,omitempty"`
+	Level   string                 `json:"level,omitempty"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Parser converts a single log line into a structured record.
+type Parser interface {
+	Parse(line string) (*Record, error)
+}
+
+// NewParser gives a parser for the given log format. Empty format is
+// treated as FormatRaw.
+func NewParser(format string) (Parser, error) {
+	switch strings.ToLower(format) {
+	case "", FormatRaw:
+		return RawParser{}, nil
+	case FormatJSON:
+		return JSONParser{}, nil
+	case FormatSyslog:
+		return &SyslogParser{}, nil
+	case FormatNginx:
+		return NginxParser{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", format)
+	}
+}
+
+// RawParser uses the whole line as a record message.
+type RawParser struct{}
+
+// Parse implements the Parser interface.
+func (RawParser) Parse(line string) (*Record, error) {
+	return &Record{Message: line}, nil
+}
+
+// JSONParser parses JSON lines. Well-known time, level and message keys are
+// moved to record fields, all others are kept in Fields.
+type JSONParser struct{}
+
+var (
+	jsonTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp"}
+	jsonLevelKeys   = []string{"level", "severity", "lvl"}
+	jsonMessageKeys = []string{"message", "msg"}
+)
+
+// Parse implements the Parser interface.
+func (JSONParser) Parse(line string) (*Record, error) {
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, err
+	}
+
+	rec := &Record{}
+
+	for _, key := range jsonTimeKeys {
+		if t, ok := parseTime(fields[key]); ok {
+			rec.Time = t
+			delete(fields, key)
+			break
+		}
+	}
+
+	for _, key := range jsonLevelKeys {
+		if s, ok := fields[key].(string); ok {
+			rec.Level = strings.ToLower(s)
+			delete(fields, key)
+			break
+		}
+	}
+
+	for _, key := range jsonMessageKeys {
+		if s, ok := fields[key].(string); ok {
+			rec.Message = s
+			delete(fields, key)
+			break
+		}
+	}
+
+	if len(fields) != 0 {
+		rec.Fields = fields
+	}
+
+	return rec, nil
+}
+
+func parseTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	case float64:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)).UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// SyslogParser parses messages in RFC 3164 format, with or without leading
+// priority value.
+type SyslogParser struct {
+	// Now is used to infer the year of the message, which is not part of
+	// the format. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+var syslogRe = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[(\d+)\])?: ?(.*)$`)
+
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Parse implements the Parser interface.
+func (p *SyslogParser) Parse(line string) (*Record, error) {
+	m := syslogRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("invalid syslog message")
+	}
+
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+
+	t, err := time.ParseInLocation(time.Stamp, m[2], now.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages from the future belong to the previous year.
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+
+	rec := &Record{
+		Time:    t,
+		Host:    m[3],
+		Message: m[6],
+		Fields: map[string]interface{}{
+			"app": m[4],
+		},
+	}
+
+	if m[1] != "" {
+		pri, err := strconv.Atoi(m[1])
+		if err != nil || pri > 191 {
+			return nil, fmt.Errorf("invalid syslog priority %q", m[1])
+		}
+
+		rec.Level = syslogSeverities[pri%8]
+		rec.Fields["facility"] = pri / 8
+	}
+
+	if m[5] != "" {
+		pid, _ := strconv.Atoi(m[5])
+		rec.Fields["pid"] = pid
+	}
+
+	return rec, nil
+}
+
+// NginxParser parses nginx access log in the default combined format.
+type NginxParser struct{}
+
+var nginxRe = regexp.MustCompile(`^(\S+) - (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-) "([^"]*)" "([^"]*)"`)
+
+const nginxTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Parse implements the Parser interface.
+func (NginxParser) Parse(line string) (*Record, error) {
+	m := nginxRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("invalid nginx access log entry")
+	}
+
+	t, err := time.Parse(nginxTimeLayout, m[3])
+	if err != nil {
+		return nil, err
+	}
+
+	status, _ := strconv.Atoi(m[5])
+	bytes, _ := strconv.Atoi(m[6]) // "-" means no body
+
+	rec := &Record{
+		Time:    t,
+		Level:   "info",
+		Message: m[4],
+		Fields: map[string]interface{}{
+			"remote_addr":     m[1],
+			"status":          status,
+			"body_bytes_sent": bytes,
+		},
+	}
+
+	switch {
+	case status >= 500:
+		rec.Level = "error"
+	case status >= 400:
+		rec.Level = "warning"
+	}
+
+	if m[2] != "-" {
+		rec.Fields["remote_user"] = m[2]
+	}
+
+	if m[7] != "-" {
+		rec.Fields["http_referer"] = m[7]
+	}
+
+	if m[8] != "-" {
+		rec.Fields["http_user_agent"] = m[8]
+	}
+
+	if req := strings.Fields(m[4]); len(req) == 3 {
+		rec.Fields["method"] = req[0]
+		rec.Fields["path"] = req[1]
+		rec.Fields["protocol"] = req[2]
+	}
+
+	return rec, nil
+}
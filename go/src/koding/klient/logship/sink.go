@@ -0,0 +1,257 @@
+package logship
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"koding/logrotate"
+)
+
+// Sink is a destination of shipped records.
+//
+// If Write fails, the same batch is retried later, so sinks should
+// not keep partially written batches when possible.
+type Sink interface {
+	Write(recs []*Record) error
+	Close() error
+}
+
+// Supported sink types.
+const (
+	SinkFile = "file" // local file
+	SinkS3   = "s3"   // S3-compatible storage
+	SinkHTTP = "http" // HTTP endpoint
+)
+
+// SinkConfig describes a sink.
+type SinkConfig struct {
+	Type    string            `json:"type"`
+	Path    string            `json:"path,omitempty"`    // file sink
+	Prefix  string            `json:"prefix,omitempty"`  // s3 sink
+	URL     string            `json:"url,omitempty"`     // http sink
+	Headers map[string]string `json:"headers,omitempty"` // http sink
+}
+
+// NewSink creates a sink from the given configuration. The bucket is used
+// by SinkS3 sinks.
+func NewSink(cfg *SinkConfig, bucket logrotate.Bucket) (Sink, error) {
+	switch cfg.Type {
+	case SinkFile:
+		if cfg.Path == "" {
+			return nil, errors.New("file sink requires path")
+		}
+
+		return &FileSink{Path: cfg.Path}, nil
+	case SinkS3:
+		if bucket == nil {
+			return nil, errors.New("s3 sink requires bucket")
+		}
+
+		return &BucketSink{Bucket: bucket, Prefix: cfg.Prefix}, nil
+	case SinkHTTP:
+		if cfg.URL == "" {
+			return nil, errors.New("http sink requires url")
+		}
+
+		s := &HTTPSink{
+			URL:    cfg.URL,
+			Header: make(http.Header),
+		}
+
+		for k, v := range cfg.Headers {
+			s.Header.Set(k, v)
+		}
+
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q", cfg.Type)
+	}
+}
+
+// FileSink appends records as JSON lines to a local file.
+type FileSink struct {
+	Path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// Write implements the Sink interface.
+func (fs *FileSink) Write(recs []*Record) error {
+	p, err := encode(recs)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		if err := os.MkdirAll(filepath.Dir(fs.Path), 0755); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(fs.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+
+		fs.f = f
+	}
+
+	_, err = fs.f.Write(p)
+	return err
+}
+
+// Close implements the Sink interface.
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return nil
+	}
+
+	err := fs.f.Close()
+	fs.f = nil
+	return err
+}
+
+// BucketSink uploads each batch of records as gzipped JSON lines object
+// to a bucket.
+type BucketSink struct {
+	Bucket logrotate.Bucket
+	Prefix string
+	Host   string // if empty, os.Hostname is used
+
+	once sync.Once
+	seq  uint64
+}
+
+var _ Sink = (*BucketSink)(nil)
+
+// Write implements the Sink interface.
+func (bs *BucketSink) Write(recs []*Record) error {
+	p, err := encode(recs)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write(p); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	bs.once.Do(bs.init)
+
+	// Name objects after the first record, so they are ordered by time.
+	// Batches starting at the same time, either shipped by this sink or
+	// other hosts sharing the bucket, are told apart by the host name
+	// and sequence number.
+	key := fmt.Sprintf("%d-%s-%d.json.gz", recs[0].Time.UnixNano(), bs.Host,
+		atomic.AddUint64(&bs.seq, 1))
+	if bs.Prefix != "" {
+		key = path.Join(bs.Prefix, key)
+	}
+
+	_, err = bs.Bucket.Put(key, bytes.NewReader(buf.Bytes()))
+	return err
+}
+
+// Close implements the Sink interface.
+func (*BucketSink) Close() error { return nil }
+
+func (bs *BucketSink) init() {
+	if bs.Host == "" {
+		bs.Host, _ = os.Hostname()
+	}
+}
+
+// HTTPSink posts batches of records as JSON lines to an HTTP endpoint.
+type HTTPSink struct {
+	URL    string
+	Header http.Header
+	Client *http.Client // if nil, client with 30s timeout is used
+}
+
+var _ Sink = (*HTTPSink)(nil)
+
+var defaultHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// Write implements the Sink interface.
+func (hs *HTTPSink) Write(recs []*Record) error {
+	p, err := encode(recs)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", hs.URL, bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range hs.Header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := hs.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", hs.URL, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// Close implements the Sink interface.
+func (*HTTPSink) Close() error { return nil }
+
+func (hs *HTTPSink) client() *http.Client {
+	if hs.Client != nil {
+		return hs.Client
+	}
+
+	return defaultHTTPClient
+}
+
+func encode(recs []*Record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"koding/klient/fs"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -108,6 +110,13 @@ func sshAddKeys(c Client, interval time.Duration) func(string, ...string) error
 	}
 }
 
+// SSHTrustCA calls registered Client's SSHTrustCA method.
+//
+// The method does not cache the result.
+func (c *Cached) SSHTrustCA(username, caKey, principal string) error {
+	return c.c.SSHTrustCA(username, caKey, principal)
+}
+
 // MountHeadIndex calls registered Client's MountHeadIndex method and caches its
 // result for the specified interval. It doesn't cache results from disconnected
 // client. If call arguments change, the cache will be invalidated.
@@ -176,6 +185,27 @@ func mountGetIndex(c Client, interval time.Duration) func(string) (*index.Index,
 	}
 }
 
+// ReadFile calls registered Client's ReadFile method.
+//
+// The method does not cache the result.
+func (c *Cached) ReadFile(path string) ([]byte, error) {
+	return c.c.ReadFile(path)
+}
+
+// MountPutIndex calls registered Client's MountPutIndex method.
+//
+// The method does not cache the result.
+func (c *Cached) MountPutIndex(req *reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	return c.c.MountPutIndex(req)
+}
+
+// MountApply calls registered Client's MountApply method.
+//
+// The method does not cache the result.
+func (c *Cached) MountApply(req *reverse.ApplyRequest) error {
+	return c.c.MountApply(req)
+}
+
 // Exec calls registered Client's Exec method.
 //
 // The method does not cache the result.
@@ -190,6 +220,20 @@ func (c *Cached) Kill(r *os.KillRequest) (*os.KillResponse, error) {
 	return c.c.Kill(r)
 }
 
+// Search calls registered Client's Search method.
+//
+// The method does not cache the result.
+func (c *Cached) Search(r *fs.SearchRequest) (*fs.SearchResponse, error) {
+	return c.c.Search(r)
+}
+
+// CancelSearch calls registered Client's CancelSearch method.
+//
+// The method does not cache the result.
+func (c *Cached) CancelSearch(r *fs.CancelSearchRequest) error {
+	return c.c.CancelSearch(r)
+}
+
 // Context calls registered Client's Context without any cache.
 func (c *Cached) Context() context.Context {
 	return c.c.Context()
@@ -3,7 +3,9 @@ package client
 import (
 	"context"
 
+	"koding/klient/fs"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -18,6 +20,10 @@ type Client interface {
 	// SSHAddKeys adds SSH public keys to user's authorized_keys file.
 	SSHAddKeys(string, ...string) error
 
+	// SSHTrustCA makes remote machine accept SSH certificates signed by
+	// a given certificate authority for the principal logging in as user.
+	SSHTrustCA(string, string, string) error
+
 	// MountHeadIndex returns the number and the overall size of files in a
 	// given remote directory.
 	MountHeadIndex(string) (string, int, int64, error)
@@ -26,12 +32,28 @@ type Client interface {
 	// directory.
 	MountGetIndex(string) (*index.Index, error)
 
+	// ReadFile returns the content of a given remote file.
+	ReadFile(string) ([]byte, error)
+
+	// MountPutIndex sends the index of local directory to remote machine and
+	// returns the paths of files whose content is needed by remote.
+	MountPutIndex(*reverse.IndexRequest) (*reverse.IndexResponse, error)
+
+	// MountApply materializes a local file change on remote machine.
+	MountApply(*reverse.ApplyRequest) error
+
 	// Exec runs a command on a remote machine.
 	Exec(*os.ExecRequest) (*os.ExecResponse, error)
 
 	// Kill terminates previously started command on a remote machine.
 	Kill(*os.KillRequest) (*os.KillResponse, error)
 
+	// Search looks for files content matching a pattern on a remote machine.
+	Search(*fs.SearchRequest) (*fs.SearchResponse, error)
+
+	// CancelSearch stops previously started search on a remote machine.
+	CancelSearch(*fs.CancelSearchRequest) error
+
 	// Context returns client's Context.
 	Context() context.Context
 }
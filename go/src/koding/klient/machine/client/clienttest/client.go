@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os/user"
 	"path/filepath"
 	"sync"
@@ -14,6 +15,7 @@ import (
 	"koding/klient/machine"
 	"koding/klient/machine/client"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -125,6 +127,11 @@ func (c *Client) SSHAddKeys(_ string, _ ...string) error {
 	return nil
 }
 
+// SSHTrustCA is a no-op method and always returns nil.
+func (c *Client) SSHTrustCA(_, _, _ string) error {
+	return nil
+}
+
 // MountHeadIndex gets basic info about the index generated from local path.
 func (c *Client) MountHeadIndex(path string) (string, int, int64, error) {
 	absPath, err := filepath.Abs(path)
@@ -146,6 +153,23 @@ func (c *Client) MountGetIndex(path string) (*index.Index, error) {
 	return index.NewIndexFiles(path, nil)
 }
 
+// ReadFile reads the content of a local file.
+func (c *Client) ReadFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+// MountPutIndex prepares local directory as if it was a remote one.
+func (c *Client) MountPutIndex(req *reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	return reverse.Index(req)
+}
+
+// MountApply materializes the change in local directory as if it was
+// a remote one.
+func (c *Client) MountApply(req *reverse.ApplyRequest) error {
+	_, err := reverse.Apply(req)
+	return err
+}
+
 // Exec mocks running process on a remote, always succeeds.
 func (c *Client) Exec(*os.ExecRequest) (*os.ExecResponse, error) {
 	return &os.ExecResponse{PID: 0xD}, nil
@@ -156,6 +180,20 @@ func (c *Client) Kill(*os.KillRequest) (*os.KillResponse, error) {
 	return &os.KillResponse{}, nil
 }
 
+// Search runs the search on local file system.
+func (c *Client) Search(req *fs.SearchRequest) (*fs.SearchResponse, error) {
+	return fs.DefaultSearcher.Start("", req)
+}
+
+// CancelSearch cancels the search started on local file system.
+func (c *Client) CancelSearch(req *fs.CancelSearchRequest) error {
+	if !fs.DefaultSearcher.Cancel("", req.ID) {
+		return errors.New("search not found")
+	}
+
+	return nil
+}
+
 // SetContext sets provided context to test client.
 func (c *Client) SetContext(ctx context.Context) {
 	c.mu.Lock()
@@ -8,6 +8,7 @@ import (
 	"koding/klient/fs"
 	"koding/klient/machine/client"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -51,6 +52,11 @@ func (c *Counter) SSHAddKeys(_ string, _ ...string) error {
 	return invCounter(atomic.AddInt64(&c.curr, 1))
 }
 
+// SSHTrustCA increases function call counter and returns it as an error.
+func (c *Counter) SSHTrustCA(_, _, _ string) error {
+	return invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
 // MountHeadIndex increases function call counter and returns it as an error.
 func (c *Counter) MountHeadIndex(path string) (string, int, int64, error) {
 	return "", 0, 0, invCounter(atomic.AddInt64(&c.curr, 1))
@@ -66,6 +72,21 @@ func (c *Counter) DiskInfo(path string) (fs.DiskInfo, error) {
 	return fs.DiskInfo{}, invCounter(atomic.AddInt64(&c.curr, 1))
 }
 
+// ReadFile increases function call counter and returns it as an error.
+func (c *Counter) ReadFile(path string) ([]byte, error) {
+	return nil, invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
+// MountPutIndex increases function call counter and returns it as an error.
+func (c *Counter) MountPutIndex(*reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	return nil, invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
+// MountApply increases function call counter and returns it as an error.
+func (c *Counter) MountApply(*reverse.ApplyRequest) error {
+	return invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
 // Exec increases function call counter and returns it as an error.
 func (c *Counter) Exec(*os.ExecRequest) (*os.ExecResponse, error) {
 	return nil, invCounter(atomic.AddInt64(&c.curr, 1))
@@ -76,6 +97,16 @@ func (c *Counter) Kill(*os.KillRequest) (*os.KillResponse, error) {
 	return nil, invCounter(atomic.AddInt64(&c.curr, 1))
 }
 
+// Search increases function call counter and returns it as an error.
+func (c *Counter) Search(*fs.SearchRequest) (*fs.SearchResponse, error) {
+	return nil, invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
+// CancelSearch increases function call counter and returns it as an error.
+func (c *Counter) CancelSearch(*fs.CancelSearchRequest) error {
+	return invCounter(atomic.AddInt64(&c.curr, 1))
+}
+
 // Context increases function call counter and returns background context.
 func (c *Counter) Context() context.Context {
 	atomic.AddInt64(&c.curr, 1)
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 
+	"koding/klient/fs"
 	"koding/klient/machine"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -67,6 +69,11 @@ func (*Disconnected) SSHAddKeys(_ string, _ ...string) error {
 	return ErrDisconnected
 }
 
+// SSHTrustCA always returns ErrDisconnected error.
+func (*Disconnected) SSHTrustCA(_, _, _ string) error {
+	return ErrDisconnected
+}
+
 // MountHeadIndex always returns ErrDisconnected error.
 func (*Disconnected) MountHeadIndex(_ string) (string, int, int64, error) {
 	return "", 0, 0, ErrDisconnected
@@ -77,6 +84,21 @@ func (*Disconnected) MountGetIndex(_ string) (*index.Index, error) {
 	return nil, ErrDisconnected
 }
 
+// ReadFile always returns ErrDisconnected error.
+func (*Disconnected) ReadFile(_ string) ([]byte, error) {
+	return nil, ErrDisconnected
+}
+
+// MountPutIndex always returns ErrDisconnected error.
+func (*Disconnected) MountPutIndex(*reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	return nil, ErrDisconnected
+}
+
+// MountApply always returns ErrDisconnected error.
+func (*Disconnected) MountApply(*reverse.ApplyRequest) error {
+	return ErrDisconnected
+}
+
 // Exec always returns ErrDisconnected error.
 func (*Disconnected) Exec(*os.ExecRequest) (*os.ExecResponse, error) {
 	return nil, ErrDisconnected
@@ -87,6 +109,16 @@ func (*Disconnected) Kill(*os.KillRequest) (*os.KillResponse, error) {
 	return nil, ErrDisconnected
 }
 
+// Search always returns ErrDisconnected error.
+func (*Disconnected) Search(*fs.SearchRequest) (*fs.SearchResponse, error) {
+	return nil, ErrDisconnected
+}
+
+// CancelSearch always returns ErrDisconnected error.
+func (*Disconnected) CancelSearch(*fs.CancelSearchRequest) error {
+	return ErrDisconnected
+}
+
 // Context returns disconnected client's context.
 func (d *Disconnected) Context() context.Context {
 	return d.ctx
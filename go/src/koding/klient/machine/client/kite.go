@@ -3,30 +3,144 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"koding/kites/kloud/klient"
+	"koding/klient/fs"
 	"koding/klient/machine"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/koding/kite"
+	"github.com/koding/kite/kitekey"
 )
 
+// DeploymentKite describes how to connect to machines of Koding deployment
+// other than the one klient is registered to.
+type DeploymentKite struct {
+	KontrolURL string `json:"kontrolURL"` // Kontrol of the deployment.
+	KiteKey    string `json:"kiteKey"`    // User's kite key issued by the kontrol.
+}
+
+// DeploymentBuilder is implemented by builders which are able to connect to
+// machines of multiple Koding deployments.
+type DeploymentBuilder interface {
+	// AddDeployment makes the builder connect to kites with provided query
+	// strings using kontrol and kite key of the given deployment.
+	AddDeployment(deployment string, dk *DeploymentKite, queries ...string) error
+}
+
+var _ DeploymentBuilder = (*KiteBuilder)(nil)
+
 // KiteBuilder implements Builder interface. It creates Kite clients that use
 // kite query string as their source address.
 type KiteBuilder struct {
+	kite *kite.Kite
+	pool *klient.KlientPool
+
+	mu      sync.Mutex
+	deploys map[string]*deploymentPool // maps deployment name to its pool
+	queries map[string]*deploymentPool // maps kite query string to its deployment pool
+}
+
+type deploymentPool struct {
+	dk   DeploymentKite
+	kite *kite.Kite
 	pool *klient.KlientPool
 }
 
 // NewKiteBuilder creates a new KiteBuilder instance.
 func NewKiteBuilder(k *kite.Kite) *KiteBuilder {
 	return &KiteBuilder{
-		pool: klient.NewPool(k),
+		kite:    k,
+		pool:    klient.NewPool(k),
+		deploys: make(map[string]*deploymentPool),
+		queries: make(map[string]*deploymentPool),
+	}
+}
+
+// AddDeployment implements DeploymentBuilder interface. Kites of other
+// deployments do not accept tokens issued by kontrol klient is registered
+// to, so they are looked up with a separate kite that uses the deployment's
+// kontrol and kite key.
+func (kb *KiteBuilder) AddDeployment(deployment string, dk *DeploymentKite, queries ...string) error {
+	if deployment == "" || dk == nil || dk.KiteKey == "" {
+		return errors.New("invalid deployment kite")
+	}
+
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	dp, ok := kb.deploys[deployment]
+	if !ok || dp.dk != *dk {
+		k, err := newDeploymentKite(kb.kite, dk)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			dp.kite.Close()
+		}
+
+		dp = &deploymentPool{
+			dk:   *dk,
+			kite: k,
+			pool: klient.NewPool(k),
+		}
+
+		kb.deploys[deployment] = dp
+	}
+
+	for _, query := range queries {
+		kb.queries[query] = dp
+	}
+
+	return nil
+}
+
+// newDeploymentKite creates a kite which is authenticated to the
+// deployment's kontrol with the deployment's kite key.
+func newDeploymentKite(k *kite.Kite, dk *DeploymentKite) (*kite.Kite, error) {
+	tok, err := jwt.ParseWithClaims(dk.KiteKey, &kitekey.KiteClaims{}, kitekey.GetKontrolKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment kite key: %s", err)
+	}
+
+	cfg := k.Config.Copy()
+	cfg.Port = 0
+
+	if err := cfg.ReadToken(tok); err != nil {
+		return nil, err
+	}
+
+	if dk.KontrolURL != "" {
+		cfg.KontrolURL = dk.KontrolURL
+	}
+
+	kk := kite.NewWithConfig(k.Kite().Name, k.Kite().Version, cfg)
+	kk.Log = k.Log
+
+	return kk, nil
+}
+
+// klientPool gives the pool of deployment the kite with provided query
+// string belongs to.
+func (kb *KiteBuilder) klientPool(query string) *klient.KlientPool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	if dp, ok := kb.queries[query]; ok {
+		return dp.pool
 	}
+
+	return kb.pool
 }
 
 // Ping uses kite network that stores kite's query string to ping the machine.
@@ -36,7 +150,7 @@ func (kb *KiteBuilder) Ping(dynAddr DynamicAddrFunc) (machine.Status, machine.Ad
 		return machine.Status{}, machine.Addr{}, err
 	}
 
-	if _, err := kb.pool.Get(addr.Value); err != nil {
+	if _, err := kb.klientPool(addr.Value).Get(addr.Value); err != nil {
 		return machine.Status{}, machine.Addr{}, err
 	}
 
@@ -52,7 +166,7 @@ func (kb *KiteBuilder) Build(ctx context.Context, addr machine.Addr) Client {
 	return &kiteClient{
 		ctx:  ctx,
 		addr: addr.Value,
-		pool: kb.pool,
+		pool: kb.klientPool(addr.Value),
 	}
 }
 
@@ -66,7 +180,7 @@ func (kb *KiteBuilder) IP(addr machine.Addr) (machine.Addr, error) {
 		return machine.Addr{}, errors.New("invalid network")
 	}
 
-	k, err := kb.pool.Get(addr.Value)
+	k, err := kb.klientPool(addr.Value).Get(addr.Value)
 	if err != nil {
 		return machine.Addr{}, err
 	}
@@ -134,6 +248,12 @@ func (kc *kiteClient) SSHAddKeys(username string, keys ...string) error {
 	return kc.get().SSHAddKeys(username, keys...)
 }
 
+// SSHTrustCA makes remote machine accept SSH certificates signed by a given
+// certificate authority for the principal logging in as user.
+func (kc *kiteClient) SSHTrustCA(username, caKey, principal string) error {
+	return kc.get().SSHTrustCA(username, caKey, principal)
+}
+
 // MountHeadIndex returns the number and the overall size of files in a
 // given remote directory.
 func (kc *kiteClient) MountHeadIndex(path string) (string, int, int64, error) {
@@ -146,6 +266,22 @@ func (kc *kiteClient) MountGetIndex(path string) (*index.Index, error) {
 	return kc.get().MountGetIndex(path)
 }
 
+// ReadFile returns the content of a given remote file.
+func (kc *kiteClient) ReadFile(path string) ([]byte, error) {
+	return kc.get().ReadFile(path)
+}
+
+// MountPutIndex sends the index of local directory to remote machine and
+// returns the paths of files whose content is needed by remote.
+func (kc *kiteClient) MountPutIndex(req *reverse.IndexRequest) (*reverse.IndexResponse, error) {
+	return kc.get().MountPutIndex(req)
+}
+
+// MountApply materializes a local file change on remote machine.
+func (kc *kiteClient) MountApply(req *reverse.ApplyRequest) error {
+	return kc.get().MountApply(req)
+}
+
 // Exec runs a command on a remote machine.
 func (kc *kiteClient) Exec(req *os.ExecRequest) (*os.ExecResponse, error) {
 	return kc.get().Exec(req)
@@ -156,6 +292,16 @@ func (kc *kiteClient) Kill(req *os.KillRequest) (*os.KillResponse, error) {
 	return kc.get().Kill(req)
 }
 
+// Search looks for files content matching a pattern on a remote machine.
+func (kc *kiteClient) Search(req *fs.SearchRequest) (*fs.SearchResponse, error) {
+	return kc.get().Search(req)
+}
+
+// CancelSearch stops previously started search on a remote machine.
+func (kc *kiteClient) CancelSearch(req *fs.CancelSearchRequest) error {
+	return kc.get().CancelSearch(req)
+}
+
 // Context returns client's Context.
 func (kc *kiteClient) Context() context.Context {
 	return kc.get().Context()
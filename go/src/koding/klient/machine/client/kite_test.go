@@ -0,0 +1,232 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/klient/machine"
+	"koding/klient/machine/client"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/koding/kite"
+	"github.com/koding/kite/config"
+	"github.com/koding/kite/kitekey"
+	"github.com/koding/kite/kitetest"
+	"github.com/koding/kite/protocol"
+)
+
+func TestKiteBuilderDeployment(t *testing.T) {
+	local, remote := newDeployment(t), newDeployment(t)
+	defer local.Close()
+	defer remote.Close()
+
+	// Remote klient is registered to other Koding deployment than the
+	// local one.
+	rk := remote.newKite(t, "klient", "remote")
+	defer rk.Close()
+
+	rk.HandleFunc("os.currentUsername", func(r *kite.Request) (interface{}, error) {
+		return r.Username, nil
+	})
+
+	go rk.Run()
+	<-rk.ServerReadyNotify()
+
+	remote.add(rk)
+
+	lk := local.newKite(t, "klient", "user")
+	defer lk.Close()
+
+	addr := machine.Addr{
+		Network: "kite",
+		Value:   rk.Kite().String(),
+	}
+	dynAddr := func(string) (machine.Addr, error) { return addr, nil }
+
+	kb := client.NewKiteBuilder(lk)
+
+	// Remote klient is not known to local kontrol.
+	if _, _, err := kb.Ping(dynAddr); err == nil {
+		t.Fatal("want err != nil; got nil")
+	}
+
+	dk := &client.DeploymentKite{
+		KontrolURL: remote.URL,
+		KiteKey:    remote.kiteKey(t, "user"),
+	}
+
+	if err := kb.AddDeployment("remote.example.com", dk, addr.Value); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	stat, a, err := kb.Ping(dynAddr)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	if stat.State != machine.StateConnected {
+		t.Fatalf("want state = %s; got %s", machine.StateConnected, stat.State)
+	}
+	if a != addr {
+		t.Fatalf("want addr = %v; got %v", addr, a)
+	}
+
+	// Token issued by remote kontrol is accepted by remote klient.
+	user, err := kb.Build(context.Background(), addr).CurrentUser()
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	if user != "user" {
+		t.Fatalf("want user = %q; got %q", "user", user)
+	}
+}
+
+// deployment is a Koding deployment with a minimal kontrol, which serves
+// getKites requests for the kites added to it.
+type deployment struct {
+	*kite.Kite // kontrol kite
+	URL        string
+
+	keys  *kitetest.KeyPair
+	mu    sync.Mutex
+	kites []*protocol.KiteWithToken
+}
+
+func newDeployment(t *testing.T) *deployment {
+	keys, err := kitetest.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	port := freePort(t)
+
+	d := &deployment{
+		URL:  fmt.Sprintf("http://127.0.0.1:%d/kite", port),
+		keys: keys,
+	}
+
+	cfg := d.config(t, "koding")
+	cfg.Port = port
+
+	d.Kite = kite.NewWithConfig("kontrol", "0.1.0", cfg)
+	d.HandleFunc("getKites", d.getKites)
+
+	go d.Run()
+	<-d.ServerReadyNotify()
+
+	return d
+}
+
+// add makes the kite discoverable by the deployment's kontrol.
+func (d *deployment) add(k *kite.Kite) {
+	d.mu.Lock()
+	d.kites = append(d.kites, &protocol.KiteWithToken{
+		Kite: *k.Kite(),
+		URL:  "http://" + k.Addr() + "/kite",
+	})
+	d.mu.Unlock()
+}
+
+func (d *deployment) getKites(r *kite.Request) (interface{}, error) {
+	var args protocol.GetKitesArgs
+
+	if err := r.Args.One().Unmarshal(&args); err != nil {
+		return nil, err
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(d.keys.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	q := args.Query
+	now := time.Now()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &kitekey.KiteClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "koding",
+			Subject:   r.Username,
+			Audience:  "/" + q.Username + "/" + q.Environment + "/" + q.Name,
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Add(-time.Minute).Unix(),
+		},
+	}).SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res := &protocol.GetKitesResult{}
+
+	for _, k := range d.kites {
+		if match(q, &k.Kite) {
+			kCopy := *k
+			kCopy.Token = token
+			res.Kites = append(res.Kites, &kCopy)
+		}
+	}
+
+	return res, nil
+}
+
+func (d *deployment) kiteKey(t *testing.T, username string) string {
+	tok, err := kitetest.GenerateKiteKey(&kitetest.KiteKey{
+		Username:   username,
+		KontrolURL: d.URL,
+	}, d.keys)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	return tok.Raw
+}
+
+func (d *deployment) config(t *testing.T, username string) *config.Config {
+	tok, err := jwt.ParseWithClaims(d.kiteKey(t, username), &kitekey.KiteClaims{}, kitekey.GetKontrolKey)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	cfg := config.New()
+	cfg.IP = "127.0.0.1"
+
+	if err := cfg.ReadToken(tok); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	return cfg
+}
+
+func (d *deployment) newKite(t *testing.T, name, username string) *kite.Kite {
+	cfg := d.config(t, username)
+	cfg.Port = freePort(t)
+
+	return kite.NewWithConfig(name, "0.0.1", cfg)
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func match(q *protocol.KontrolQuery, k *protocol.Kite) bool {
+	fields := k.Query().Fields()
+
+	for key, value := range q.Fields() {
+		if value != "" && fields[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
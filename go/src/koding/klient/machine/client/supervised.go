@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	"koding/klient/fs"
 	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
 	"koding/klient/os"
 )
 
@@ -93,6 +95,54 @@ func (s *Supervised) MountGetIndex(path string) (idx *index.Index, err error) {
 	return
 }
 
+// ReadFile calls registered Client's ReadFile method and returns its result
+// if it's not produced by Disconnected client. If it is, this function will
+// wait until valid client is available or timeout is reached.
+func (s *Supervised) ReadFile(path string) (content []byte, err error) {
+	fn := func(c Client) error {
+		content, err = c.ReadFile(path)
+		return err
+	}
+
+	err = s.call(fn)
+	return
+}
+
+// MountPutIndex calls registered Client's MountPutIndex method and returns its
+// result if it's not produced by Disconnected client. If it is, this function
+// will wait until valid client is available or timeout is reached.
+func (s *Supervised) MountPutIndex(req *reverse.IndexRequest) (resp *reverse.IndexResponse, err error) {
+	fn := func(c Client) error {
+		resp, err = c.MountPutIndex(req)
+		return err
+	}
+
+	err = s.call(fn)
+	return
+}
+
+// SSHTrustCA calls registered Client's SSHTrustCA method and returns its
+// result if it's not produced by Disconnected client. If it is, this function
+// will wait until valid client is available or timeout is reached.
+func (s *Supervised) SSHTrustCA(username, caKey, principal string) error {
+	fn := func(c Client) error {
+		return c.SSHTrustCA(username, caKey, principal)
+	}
+
+	return s.call(fn)
+}
+
+// MountApply calls registered Client's MountApply method and returns its
+// result if it's not produced by Disconnected client. If it is, this function
+// will wait until valid client is available or timeout is reached.
+func (s *Supervised) MountApply(req *reverse.ApplyRequest) error {
+	fn := func(c Client) error {
+		return c.MountApply(req)
+	}
+
+	return s.call(fn)
+}
+
 // Exec calls registered Client's Exec method and returns its result if
 // it's not produced by Disconnected client. If it is, this function will wait
 // until valid client is available or timeout is reached.
@@ -119,6 +169,30 @@ func (s *Supervised) Kill(req *os.KillRequest) (resp *os.KillResponse, err error
 	return
 }
 
+// Search calls registered Client's Search method and returns its result if
+// it's not produced by Disconnected client. If it is, this function will wait
+// until valid client is available or timeout is reached.
+func (s *Supervised) Search(req *fs.SearchRequest) (resp *fs.SearchResponse, err error) {
+	fn := func(c Client) error {
+		resp, err = c.Search(req)
+		return err
+	}
+
+	err = s.call(fn)
+	return
+}
+
+// CancelSearch calls registered Client's CancelSearch method and returns its
+// result if it's not produced by Disconnected client. If it is, this function
+// will wait until valid client is available or timeout is reached.
+func (s *Supervised) CancelSearch(req *fs.CancelSearchRequest) error {
+	fn := func(c Client) error {
+		return c.CancelSearch(req)
+	}
+
+	return s.call(fn)
+}
+
 // Context calls registered Client's Context method and returns its result. If
 // there is an error during client retrieving, this function will return
 // canceled context.
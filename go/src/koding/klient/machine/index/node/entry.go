@@ -69,6 +69,7 @@ func (ep *EntryPromise) Swap(set, unset EntryPromise) {
 type File struct {
 	CTime int64       `json:"c"`           // Metadata change time since EPOCH.
 	MTime int64       `json:"m"`           // File data change time since EPOCH.
+	ATime int64       `json:"a,omitempty"` // File access time since EPOCH.
 	Size  int64       `json:"s"`           // Size of the file.
 	Mode  os.FileMode `json:"o"`           // File mode and permission bits.
 	Inode uint64      `json:"i,omitempty"` // Inode ID of a mounted file.
@@ -137,13 +138,17 @@ func NewEntry(size int64, mode os.FileMode, inode uint64) *Entry {
 
 // NewEntryFileInfo creates a new entry from a given file info.
 func NewEntryFileInfo(info os.FileInfo) *Entry {
-	return NewEntryTime(
+	e := NewEntryTime(
 		ctime(info),
 		info.ModTime().UTC().UnixNano(),
 		info.Size(),
 		info.Mode(),
 		Inode(info),
 	)
+
+	e.File.ATime = atime(info)
+
+	return e
 }
 
 // NewEntryTime creates a new entry with custom file change and modify times.
@@ -204,6 +209,9 @@ func (e *Entry) MergeIn(f *Entry) {
 	if t := f.File.MTime; t != 0 {
 		e.File.MTime = t
 	}
+	if t := f.File.ATime; t != 0 {
+		e.File.ATime = t
+	}
 	if n := f.File.Size; n != 0 {
 		e.File.Size = n
 	}
@@ -262,3 +270,8 @@ func ctime(fi os.FileInfo) int64 {
 
 	return 0
 }
+
+// atime gets file's access time in UNIX Nano format.
+func atime(fi os.FileInfo) int64 {
+	return times.Get(fi).AccessTime().UnixNano()
+}
@@ -55,4 +55,8 @@ type Metadata struct {
 	Label string `json:"label"`
 	Stack string `json:"stack"`
 	Team  string `json:"team"`
+
+	// Deployment is a name of Koding deployment the machine belongs to. It
+	// can be used to qualify machine identifiers in form deployment:alias.
+	Deployment string `json:"deployment,omitempty"`
 }
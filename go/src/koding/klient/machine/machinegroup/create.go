@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"koding/klient/machine"
+	"koding/klient/machine/client"
 	"koding/klient/machine/machinegroup/idset"
 )
 
@@ -14,6 +15,16 @@ type CreateRequest struct {
 
 	// Metadata stores additional information about the machine.
 	Metadata map[machine.ID]*machine.Metadata `json:"metadata"`
+
+	// Deployment is a name of Koding deployment which provided machines
+	// come from. Machines of other deployments are not affected by the
+	// request.
+	Deployment string `json:"deployment,omitempty"`
+
+	// DeploymentKite, when non-nil, is used to connect to machines of the
+	// deployment. It is required for deployments other than the one klient
+	// is registered to, as their machines would reject klient's kite key.
+	DeploymentKite *client.DeploymentKite `json:"deploymentKite,omitempty"`
 }
 
 // CreateResponse defines machine group create response.
@@ -39,6 +50,10 @@ func (g *Group) Create(req *CreateRequest) (*CreateResponse, error) {
 		Aliases:  make(map[machine.ID]string),
 	}
 
+	if req.DeploymentKite != nil {
+		g.addDeployment(req)
+	}
+
 	for id, addrs := range req.Addresses {
 		// Add addresses.
 		for _, a := range addrs {
@@ -65,6 +80,10 @@ func (g *Group) Create(req *CreateRequest) (*CreateResponse, error) {
 	}
 
 	for id, meta := range req.Metadata {
+		if meta != nil && meta.Deployment == "" {
+			meta.Deployment = req.Deployment
+		}
+
 		// Add machine metadata.
 		if err := g.meta.Add(id, meta); err != nil {
 			g.log.Error("Cannot add metadata for %s machine: %s", id, err)
@@ -105,14 +124,38 @@ func (g *Group) Create(req *CreateRequest) (*CreateResponse, error) {
 	}
 
 	// Update and clean up stale machines. No need to block here.
-	go g.balance(ids)
+	go g.balance(ids, req.Deployment)
 
 	return res, nil
 }
 
+// addDeployment makes client builder use deployment's kite when connecting
+// to kites of provided machines.
+func (g *Group) addDeployment(req *CreateRequest) {
+	db, ok := g.builder.(client.DeploymentBuilder)
+	if !ok {
+		g.log.Warning("Machines of %s deployment are not supported by client builder", req.Deployment)
+		return
+	}
+
+	var queries []string
+	for _, addrs := range req.Addresses {
+		for _, a := range addrs {
+			if a.Network == "kite" {
+				queries = append(queries, a.Value)
+			}
+		}
+	}
+
+	if err := db.AddDeployment(req.Deployment, req.DeploymentKite, queries...); err != nil {
+		g.log.Error("Cannot add %s deployment: %s", req.Deployment, err)
+	}
+}
+
 // balance ensures that stale clients and other resources will be closed and
-// removed. Mounted machines are not deleted.
-func (g *Group) balance(ids machine.IDSlice) {
+// removed. Mounted machines and machines which belong to other deployments
+// are not deleted.
+func (g *Group) balance(ids machine.IDSlice, deployment string) {
 	var (
 		regAlias   = g.alias.Registered()
 		regMeta    = g.meta.Registered()
@@ -121,7 +164,7 @@ func (g *Group) balance(ids machine.IDSlice) {
 		regMount   = g.mount.Registered()
 	)
 
-	union := idset.Union(idset.Union(regAlias, regAddress), idset.Union(regClient, regMeta))
+	union := g.inDeployment(idset.Union(idset.Union(regAlias, regAddress), idset.Union(regClient, regMeta)), deployment)
 
 	// Remove machines that are no longer available. Leave these with mounts
 	// untouched.
@@ -158,7 +201,7 @@ func (g *Group) balance(ids machine.IDSlice) {
 	}
 
 	// Log machines that have stale mounts.
-	for _, id := range idset.Diff(regMount, ids) {
+	for _, id := range idset.Diff(g.inDeployment(regMount, deployment), ids) {
 		mounts, err := g.mount.All(id)
 		if err != nil {
 			g.log.Error("Cannot retrieve stale mounts for %s machine: %s", id, err)
@@ -170,3 +213,18 @@ func (g *Group) balance(ids machine.IDSlice) {
 		}
 	}
 }
+
+// inDeployment filters out machines that belong to other deployments than the
+// provided one. Machines without known deployment are always kept.
+func (g *Group) inDeployment(ids machine.IDSlice, deployment string) machine.IDSlice {
+	filtered := make(machine.IDSlice, 0, len(ids))
+	for _, id := range ids {
+		if meta, err := g.meta.Get(id); err == nil && meta.Deployment != "" && meta.Deployment != deployment {
+			continue
+		}
+
+		filtered = append(filtered, id)
+	}
+
+	return filtered
+}
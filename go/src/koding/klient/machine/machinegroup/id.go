@@ -47,6 +47,10 @@ func (res *IDResponse) ItemDesc() (ds []string) {
 			d += fmt.Sprintf(", owner: %q", meta.Owner)
 		}
 
+		if meta.Deployment != "" {
+			d += fmt.Sprintf(", deployment: %q", meta.Deployment)
+		}
+
 		ds = append(ds, d)
 	}
 
@@ -59,11 +63,57 @@ func (res *IDResponse) ItemDesc() (ds []string) {
 //  - machine alias.
 //  - machine label in form [owner@]label.
 //  - machine IP address.
+//
+// Each of the above can be qualified with Koding deployment name in form
+// deployment:identifier in order to limit machines to a given deployment.
 func (g *Group) ID(req *IDRequest) (*IDResponse, error) {
 	if req == nil {
 		return nil, errors.New("invalid nil request")
 	}
 
+	deployment, identifier := g.splitDeployment(req.Identifier)
+
+	res, err := g.id(identifier)
+	if err != nil || deployment == "" {
+		return res, err
+	}
+
+	for id, meta := range res.IDs {
+		if meta.Deployment != deployment {
+			delete(res.IDs, id)
+		}
+	}
+
+	if len(res.IDs) == 0 {
+		g.log.Error("Cannot find machine with identifier: %s", req.Identifier)
+		return nil, machine.ErrMachineNotFound
+	}
+
+	return res, nil
+}
+
+// splitDeployment splits deployment qualified identifier. The deployment is
+// returned only if there are machines which belong to it, so IPv6 addresses
+// are not split.
+func (g *Group) splitDeployment(identifier string) (deployment, ident string) {
+	i := strings.IndexRune(identifier, ':')
+	if i <= 0 {
+		return "", identifier
+	}
+
+	deployment = identifier[:i]
+	for _, id := range g.meta.Registered() {
+		if meta, err := g.meta.Get(id); err == nil && meta.Deployment == deployment {
+			return deployment, identifier[i+1:]
+		}
+	}
+
+	return "", identifier
+}
+
+// id gets machine IDs for provided unqualified identifier.
+func (g *Group) id(identifier string) (*IDResponse, error) {
+
 	getMeta := func(id machine.ID) machine.Metadata {
 		meta, err := g.meta.Get(id)
 		if err != nil {
@@ -77,7 +127,7 @@ func (g *Group) ID(req *IDRequest) (*IDResponse, error) {
 		IDs: make(map[machine.ID]machine.Metadata),
 	}
 
-	owner, identifier := "", req.Identifier
+	owner := ""
 	if toks := strings.SplitN(identifier, "@", 2); len(toks) > 1 {
 		owner, identifier = toks[0], toks[1]
 	}
@@ -106,7 +156,7 @@ func (g *Group) ID(req *IDRequest) (*IDResponse, error) {
 		return res, nil
 	}
 
-	g.log.Error("Cannot find machine with identifier: %s", req.Identifier)
+	g.log.Error("Cannot find machine with identifier: %s", identifier)
 
 	return nil, machine.ErrMachineNotFound
 }
@@ -160,6 +210,10 @@ func (g *Group) IdentifierList(req *IdentifierListRequest) (*IdentifierListRespo
 		for _, id := range regAlias {
 			if alias, err := g.alias.Create(id); err == nil {
 				identifiers = append(identifiers, alias)
+
+				if meta, err := g.meta.Get(id); err == nil && meta.Deployment != "" {
+					identifiers = append(identifiers, meta.Deployment+":"+alias)
+				}
 			}
 		}
 	}
@@ -3,6 +3,7 @@ package machinegroup
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -146,3 +147,86 @@ func TestID(t *testing.T) {
 		})
 	}
 }
+
+func TestIDDeployment(t *testing.T) {
+	var (
+		idA = machine.ID("servA")
+		idB = machine.ID("servB")
+	)
+
+	wd, err := ioutil.TempDir("", "id")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(wd)
+
+	g, err := New(testOptions(wd, clienttest.NewBuilder(nil)))
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer g.Close()
+
+	// Both deployments have a machine with the same label.
+	for id, deployment := range map[machine.ID]string{idA: "koding.com", idB: "team.example.com"} {
+		req := &CreateRequest{
+			Addresses: map[machine.ID][]machine.Addr{
+				id: {clienttest.TurnOnAddr()},
+			},
+			Metadata: map[machine.ID]*machine.Metadata{
+				id: &machine.Metadata{Label: "dev"},
+			},
+			Deployment: deployment,
+		}
+
+		if _, err := g.Create(req); err != nil {
+			t.Fatalf("want err = nil; got %v", err)
+		}
+	}
+
+	// Give balance goroutines time to finish. Machines of other deployment
+	// must not be removed.
+	time.Sleep(100 * time.Millisecond)
+
+	tests := map[string]struct {
+		Identifier string
+		IDs        machine.IDSlice
+	}{
+		"unqualified": {
+			Identifier: "dev",
+			IDs:        machine.IDSlice{idA, idB},
+		},
+		"qualified": {
+			Identifier: "team.example.com:dev",
+			IDs:        machine.IDSlice{idB},
+		},
+		"qualified ID": {
+			Identifier: "koding.com:" + string(idA),
+			IDs:        machine.IDSlice{idA},
+		},
+		"other deployment": {
+			Identifier: "team.example.com:" + string(idA),
+			IDs:        nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test // capture range variable.
+		t.Run(name, func(t *testing.T) {
+			res, err := g.ID(&IDRequest{Identifier: test.Identifier})
+			if test.IDs == nil {
+				if err == nil {
+					t.Fatalf("want err != nil; got %v", res.IDs)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want err = nil; got %v", err)
+			}
+
+			if got := res.IDSlice(); !reflect.DeepEqual(got, test.IDs) {
+				t.Fatalf("want ids = %v; got %v", test.IDs, got)
+			}
+		})
+	}
+}
@@ -230,6 +230,27 @@ func KiteHandlerInspectMount(g *Group) kite.HandlerFunc {
 	}
 }
 
+// KiteHandlerMountLog creates a kite handler function that, when called,
+// invokes machine group MountLog method.
+func KiteHandlerMountLog(g *Group) kite.HandlerFunc {
+	return func(r *kite.Request) (interface{}, error) {
+		req := &MountLogRequest{}
+
+		if r.Args != nil {
+			if err := r.Args.One().Unmarshal(req); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := g.MountLog(req)
+		if err != nil {
+			return nil, newError(err)
+		}
+
+		return res, nil
+	}
+}
+
 // KiteHandlerCp creates a kite handler function that, when called, invokes
 // machine group Cp method.
 func KiteHandlerCp(g *Group) kite.HandlerFunc {
@@ -358,6 +379,50 @@ func (g *Group) HandleKill(r *kite.Request) (interface{}, error) {
 	return resp, nil
 }
 
+// HandleSearch is a handler for "machine.search" kite requests.
+func (g *Group) HandleSearch(r *kite.Request) (interface{}, error) {
+	var req SearchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	resp, err := g.Search(&req)
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	return resp, nil
+}
+
+// HandleCancelSearch is a handler for "machine.cancelSearch" kite requests.
+func (g *Group) HandleCancelSearch(r *kite.Request) (interface{}, error) {
+	var req CancelSearchRequest
+
+	if r.Args != nil {
+		if err := r.Args.One().Unmarshal(&req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, newError(err)
+	}
+
+	resp, err := g.CancelSearch(&req)
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	return resp, nil
+}
+
 // HandleWaitIdle is a handler for "machine.mount.waitIdle" kite requests.
 func (g *Group) HandleWaitIdle(r *kite.Request) (interface{}, error) {
 	var req WaitIdleRequest
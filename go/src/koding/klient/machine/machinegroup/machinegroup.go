@@ -18,7 +18,9 @@ import (
 	"koding/klient/machine/machinegroup/syncs"
 	"koding/klient/machine/mount"
 	"koding/klient/machine/mount/notify"
+	"koding/klient/machine/mount/notify/native"
 	msync "koding/klient/machine/mount/sync"
+	"koding/klient/machine/mount/sync/push"
 	"koding/klient/storage"
 
 	"github.com/koding/logging"
@@ -87,9 +89,10 @@ func (opts *Options) Valid() error {
 
 // Group allows to manage one or more machines.
 type Group struct {
-	nb  notify.Builder
-	sb  msync.Builder
-	log logging.Logger
+	builder client.Builder
+	nb      notify.Builder
+	sb      msync.Builder
+	log     logging.Logger
 
 	client  *clients.Clients
 	address addresses.Addresser
@@ -109,8 +112,9 @@ func New(opts *Options) (*Group, error) {
 
 	// Initialize group with builders.
 	g := &Group{
-		nb: opts.NotifyBuilder,
-		sb: opts.SyncBuilder,
+		builder: opts.Builder,
+		nb:      opts.NotifyBuilder,
+		sb:      opts.SyncBuilder,
 	}
 
 	// Add logger to group.
@@ -143,6 +147,7 @@ func New(opts *Options) (*Group, error) {
 	// Create syncs object for synced mounts.
 	syncsOpts := syncs.Options{
 		WorkDir: opts.WorkDir,
+		Storage: opts.Storage,
 		Log:     g.log,
 	}
 	g.sync, err = syncs.New(syncsOpts)
@@ -252,6 +257,17 @@ func (g *Group) bootstrap() {
 	g.mountSync(mountsIDs)
 }
 
+// builders returns notification and synchronization factories for a given
+// mount. Reverse mounts watch local directory and push its files to remote
+// machine.
+func (g *Group) builders(m mount.Mount) (notify.Builder, msync.Builder) {
+	if m.Reverse {
+		return native.Builder, push.Builder{}
+	}
+
+	return g.nb, g.sb
+}
+
 // mountsSync tries to add all available mounts to mount syncer.
 func (g *Group) mountSync(ids machine.IDSlice) {
 	mountsN, errN := 0, int64(0)
@@ -270,11 +286,12 @@ func (g *Group) mountSync(ids machine.IDSlice) {
 			go func() {
 				defer wg.Done()
 
+				nb, sb := g.builders(m)
 				addReq := &syncs.AddRequest{
 					MountID:       mountID,
 					Mount:         m,
-					NotifyBuilder: g.nb,
-					SyncBuilder:   g.sb,
+					NotifyBuilder: nb,
+					SyncBuilder:   sb,
 					ClientFunc:    g.dynamicClient(mountID),
 					SSHFunc:       g.dynamicSSH(id),
 				}
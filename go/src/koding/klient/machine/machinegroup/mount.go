@@ -121,6 +121,10 @@ type AddMountRequest struct {
 	// Strategies contains a set of prefetching strategies that can be used on
 	// local machine.
 	Strategies []string `json:"strategies"`
+
+	// Budget limits the size of files downloaded by selective prefetching
+	// strategies. If zero, the configured value is used.
+	Budget int64 `json:"budget,omitempty"`
 }
 
 // AddMountResponse defines machine group add mount response.
@@ -154,11 +158,12 @@ func (g *Group) AddMount(req *AddMountRequest) (res *AddMountResponse, err error
 		}
 	}()
 
+	nb, sb := g.builders(req.Mount)
 	addReq := &syncs.AddRequest{
 		MountID:       mountID,
 		Mount:         req.Mount,
-		NotifyBuilder: g.nb,
-		SyncBuilder:   g.sb,
+		NotifyBuilder: nb,
+		SyncBuilder:   sb,
 		ClientFunc:    g.dynamicClient(mountID),
 		SSHFunc:       g.dynamicSSH(req.ID),
 	}
@@ -177,7 +182,14 @@ func (g *Group) AddMount(req *AddMountRequest) (res *AddMountResponse, err error
 
 	g.log.Info("Successfully created mount %s for %s", mountID, req.Mount)
 
-	p, err := sc.Prefetch(req.Strategies)
+	// Reverse mounts don't download any files.
+	if req.Mount.Reverse {
+		return &AddMountResponse{
+			MountID: mountID,
+		}, nil
+	}
+
+	p, err := sc.Prefetch(req.Strategies, req.Budget)
 	if err != nil {
 		g.log.Error("Cannot prefetch mount data: %s", err)
 	}
@@ -569,6 +581,9 @@ type InspectMountRequest struct {
 
 	// Filesystem indicates whether inspect should run filesystem diagnostic.
 	Filesystem bool `json:"filesystem"`
+
+	// Prefetch indicates whether inspect should attach prefetched files.
+	Prefetch bool `json:"prefetch"`
 }
 
 // InspectMountResponse defines machine group mount inspect response.
@@ -581,6 +596,9 @@ type InspectMountResponse struct {
 
 	// Filesystem contains issues found by filesystem diagnostic.
 	Filesystem []string `json:"filesystem,omitempty"`
+
+	// Prefetch describes files prefetched when the mount was created.
+	Prefetch *prefetch.Prefetch `json:"prefetch,omitempty"`
 }
 
 // InspectMount gets detailed information about mount current state.
@@ -619,5 +637,61 @@ func (g *Group) InspectMount(req *InspectMountRequest) (*InspectMountResponse, e
 		res.Filesystem = sc.Diagnose()
 	}
 
+	// Get prefetched files if requested.
+	if req.Prefetch {
+		if res.Prefetch, err = sc.PrefetchInfo(); err != nil {
+			g.log.Error("Cannot get mount %s prefetch description: %s", mountID, err)
+		}
+	}
+
 	return res, nil
 }
+
+// MountLogRequest defines machine group mount log request.
+type MountLogRequest struct {
+	// Identifier is a string that identifiers requested mount. It can be either
+	// mount ID or local path which is going to be inspected.
+	Identifier string `json:"identifier"`
+
+	// Filter describes which synchronization records should be returned.
+	Filter history.Filter `json:"filter"`
+}
+
+// MountLogResponse defines machine group mount log response.
+type MountLogResponse struct {
+	// Records contains synchronization history records ordered from the
+	// oldest to the newest one.
+	Records []*history.Record `json:"records,omitempty"`
+
+	// Stats contains statistics computed from returned records.
+	Stats *history.Stats `json:"stats"`
+}
+
+// MountLog gets filtered synchronization activity of a given mount.
+func (g *Group) MountLog(req *MountLogRequest) (*MountLogResponse, error) {
+	if req == nil {
+		return nil, errors.New("invalid nil request")
+	}
+
+	// Get mount ID from identifier.
+	mountID, err := g.getMountID(req.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := g.sync.Sync(mountID)
+	if err != nil {
+		g.log.Warning("Mount %s is not synchronized: %s", mountID, err)
+		return nil, err
+	}
+
+	recs, stats, err := sc.Activity(&req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MountLogResponse{
+		Records: recs,
+		Stats:   stats,
+	}, nil
+}
@@ -0,0 +1,168 @@
+package machinegroup
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"koding/klient/fs"
+	"koding/klient/machine"
+	"koding/klient/machine/mount"
+
+	"github.com/koding/kite/dnode"
+)
+
+// SearchRequest is a request value of "machine.search" kite method.
+type SearchRequest struct {
+	// SearchRequest is a request value for remote "fs.search" call. If
+	// MachineID is empty, its Path is a local path inside existing mount,
+	// which is used to look up remote machine and remote path. Paths of
+	// found matches are then translated back to local ones.
+	fs.SearchRequest
+
+	MachineID machine.ID `json:"machineID"`
+}
+
+// Valid implements the stack.Validator interface.
+func (r *SearchRequest) Valid() error {
+	if err := r.SearchRequest.Valid(); err != nil {
+		return err
+	}
+	if r.MachineID == "" && !filepath.IsAbs(r.Path) {
+		return errors.New("invalid relative path")
+	}
+	return nil
+}
+
+// SearchResponse is a response value of "machine.search" kite method.
+type SearchResponse struct {
+	fs.SearchResponse // response value from remote "fs.search" call
+}
+
+// CancelSearchRequest is a request value of "machine.cancelSearch" kite method.
+type CancelSearchRequest struct {
+	fs.CancelSearchRequest // request value for remote "fs.cancelSearch" call
+	MachineRequest         // used to look up remote
+}
+
+// Valid implements the stack.Validator interface.
+func (r *CancelSearchRequest) Valid() error {
+	if err := r.CancelSearchRequest.Valid(); err != nil {
+		return err
+	}
+	return r.MachineRequest.Valid()
+}
+
+// CancelSearchResponse is a response value of "machine.cancelSearch" kite method.
+type CancelSearchResponse struct{}
+
+// Search is a handler implementation for "machine.search" kite method.
+func (g *Group) Search(r *SearchRequest) (*SearchResponse, error) {
+	machineID := r.MachineID
+	req := r.SearchRequest
+
+	// Translates remote paths of found matches to local ones.
+	localPath := func(path string) string { return path }
+
+	if machineID == "" {
+		id, err := g.lookup(r.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if machineID, err = g.mount.MachineID(id); err != nil {
+			return nil, err
+		}
+
+		mounts, err := g.mount.All(machineID)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := mounts[id]
+		if !ok {
+			return nil, mount.ErrMountNotFound
+		}
+
+		rel, err := filepath.Rel(m.Path, r.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Path = filepath.Join(m.RemotePath, rel)
+
+		localPath = func(path string) string {
+			rel, err := filepath.Rel(m.RemotePath, path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				return path
+			}
+			return filepath.Join(m.Path, rel)
+		}
+	}
+
+	// dnode.Function cannot be forwarded, they need to be
+	// wrapped again in a callback.
+
+	if fn := r.OnMatch; fn.IsValid() {
+		req.OnMatch = dnode.Callback(func(r *dnode.Partial) {
+			var m fs.SearchMatch
+			r.One().MustUnmarshal(&m)
+			m.Path = localPath(m.Path)
+			fn.Call(&m)
+		})
+	}
+	if fn := r.OnDone; fn.IsValid() {
+		req.OnDone = dnode.Callback(func(r *dnode.Partial) {
+			var res fs.SearchResult
+			r.One().MustUnmarshal(&res)
+			fn.Call(&res)
+		})
+	}
+
+	c, err := g.client.Client(machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Search(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Result != nil {
+		for _, m := range resp.Result.Matches {
+			m.Path = localPath(m.Path)
+		}
+	}
+
+	return &SearchResponse{
+		SearchResponse: *resp,
+	}, nil
+}
+
+// CancelSearch is a handler implementation for "machine.cancelSearch" kite method.
+func (g *Group) CancelSearch(r *CancelSearchRequest) (*CancelSearchResponse, error) {
+	machineID := r.MachineID
+
+	if machineID == "" {
+		id, err := g.lookup(r.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if machineID, err = g.mount.MachineID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := g.client.Client(machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.CancelSearch(&r.CancelSearchRequest); err != nil {
+		return nil, err
+	}
+
+	return &CancelSearchResponse{}, nil
+}
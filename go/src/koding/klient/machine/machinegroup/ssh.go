@@ -29,6 +29,17 @@ type SSHRequest struct {
 	// added to remote machine authorized_keys file. This field is optional, if
 	// not set, no keys will be added.
 	PublicKey string `json:"public_key"`
+
+	// CAPublicKey contains the public key of a certificate authority which
+	// signed local machine's SSH certificate. If set, remote machine is
+	// configured to trust the authority and PublicKey is added only when
+	// remote machine cannot be configured. This field is optional.
+	CAPublicKey string `json:"ca_public_key,omitempty"`
+
+	// Principal is the principal of local machine's SSH certificate, which
+	// remote machine is configured to accept. It is required when
+	// CAPublicKey is set.
+	Principal string `json:"principal,omitempty"`
 }
 
 // SSHResponse defines machine group ssh info response.
@@ -50,7 +61,19 @@ func (g *Group) SSH(req *SSHRequest) (*SSHResponse, error) {
 		return nil, errors.New("invalid nil request")
 	}
 
-	username, err := g.ensureSSHPubKey(req.ID, req.Username, req.PublicKey)
+	var username string
+	var err error
+
+	if req.CAPublicKey != "" {
+		username, err = g.ensureSSHCA(req.ID, req.Username, req.CAPublicKey, req.Principal)
+		if err != nil && req.PublicKey != "" {
+			g.log.Warning("Cannot configure SSH certificates on %s machine: %s", req.ID, err)
+			username, err = g.ensureSSHPubKey(req.ID, req.Username, req.PublicKey)
+		}
+	} else {
+		username, err = g.ensureSSHPubKey(req.ID, req.Username, req.PublicKey)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -108,6 +131,39 @@ func (g *Group) sshKey(id machine.ID, timeout time.Duration) <-chan remoteUser {
 }
 
 func (g *Group) ensureSSHPubKey(id machine.ID, username, pubKey string) (string, error) {
+	c, username, err := g.sshClient(id, username)
+	if err != nil {
+		return "", err
+	}
+
+	// Add pubic key to remote machine authorized keys.
+	if pubKey != "" {
+		if err := c.SSHAddKeys(username, pubKey); err != nil {
+			return "", err
+		}
+	}
+
+	return username, nil
+}
+
+// ensureSSHCA makes remote machine trust certificates signed by provided
+// certificate authority for the given principal.
+func (g *Group) ensureSSHCA(id machine.ID, username, caKey, principal string) (string, error) {
+	c, username, err := g.sshClient(id, username)
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.SSHTrustCA(username, caKey, principal); err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// sshClient creates a client to remote machine and gets remote username if
+// the provided one is empty.
+func (g *Group) sshClient(id machine.ID, username string) (client.Client, string, error) {
 	// How long to wait for a valid client.
 	const timeout = 30 * time.Second
 
@@ -118,18 +174,11 @@ func (g *Group) ensureSSHPubKey(id machine.ID, username, pubKey string) (string,
 	c := client.NewSupervised(dynClient, timeout)
 	if username == "" {
 		if username, err = c.CurrentUser(); err != nil {
-			return "", err
-		}
-	}
-
-	// Add pubic key to remote machine authorized keys.
-	if pubKey != "" {
-		if err := c.SSHAddKeys(username, pubKey); err != nil {
-			return "", err
+			return nil, "", err
 		}
 	}
 
-	return username, nil
+	return c, username, nil
 }
 
 func (g *Group) dynamicSSH(id machine.ID) msync.DynamicSSHFunc {
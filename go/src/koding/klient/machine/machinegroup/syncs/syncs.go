@@ -13,6 +13,7 @@ import (
 	"koding/klient/machine/mount"
 	"koding/klient/machine/mount/notify"
 	msync "koding/klient/machine/mount/sync"
+	"koding/klient/storage"
 
 	"github.com/koding/logging"
 )
@@ -37,6 +38,10 @@ type Options struct {
 	//
 	WorkDir string
 
+	// Storage is used to persist mounts synchronization history. If nil,
+	// history is kept only in memory.
+	Storage storage.ValueInterface
+
 	// Log is used for logging. If nil, default logger will be created.
 	Log logging.Logger
 }
@@ -57,6 +62,7 @@ func (opts *Options) Valid() error {
 // sync is bound to unique mount ID.
 type Syncs struct {
 	wd  string
+	st  storage.ValueInterface
 	log logging.Logger
 
 	once   sync.Once
@@ -77,6 +83,7 @@ func New(opts Options) (*Syncs, error) {
 
 	s := &Syncs{
 		wd:  opts.WorkDir,
+		st:  opts.Storage,
 		log: opts.Log,
 
 		exC:   make(chan msync.Execer),
@@ -194,6 +201,7 @@ func (s *Syncs) Add(req *AddRequest) error {
 		WorkDir:       filepath.Join(s.wd, "mount-"+string(req.MountID)),
 		NotifyBuilder: req.NotifyBuilder,
 		SyncBuilder:   req.SyncBuilder,
+		Storage:       s.st,
 		Log:           s.log.New(string(req.MountID)),
 	})
 	if err != nil {
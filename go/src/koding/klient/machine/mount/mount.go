@@ -57,6 +57,10 @@ func (ids IDSlice) StringSlice() (res []string) {
 type Mount struct {
 	Path       string `json:"path"`       // Mount point.
 	RemotePath string `json:"remotePath"` // Remote directory path.
+
+	// Reverse indicates that local directory is exposed on remote machine.
+	// In this mode Path is not a mount point but a synchronization source.
+	Reverse bool `json:"reverse,omitempty"`
 }
 
 // String return a string form of stored mount.
@@ -70,6 +74,10 @@ func (m Mount) String() string {
 		path = m.Path
 	}
 
+	if m.Reverse {
+		return path + " -> " + remotePath
+	}
+
 	return remotePath + " -> " + path
 }
 
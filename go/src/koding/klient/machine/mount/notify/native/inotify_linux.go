@@ -0,0 +1,159 @@
+// +build linux
+
+package native
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"gopkg.in/fsnotify.v1"
+)
+
+// inotify watches a directory tree with inotify(7). Since inotify is not
+// recursive, each directory of the tree has its own watch.
+type inotify struct {
+	root string
+	max  int // maximum number of watches; 0 means no limit
+	w    *fsnotify.Watcher
+
+	dirs   map[string]struct{} // watched directories
+	events chan string
+	err    error
+
+	once   sync.Once
+	closeC chan struct{}
+}
+
+var _ watcher = (*inotify)(nil)
+
+func newInotify(root string, max int) (watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	in := &inotify{
+		root:   root,
+		max:    max,
+		w:      w,
+		dirs:   make(map[string]struct{}),
+		events: make(chan string),
+		closeC: make(chan struct{}),
+	}
+
+	if err := in.addTree(root, nil); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go in.loop()
+
+	return in, nil
+}
+
+func (in *inotify) loop() {
+	defer close(in.events)
+
+	for {
+		select {
+		case ev, ok := <-in.w.Events:
+			if !ok {
+				return
+			}
+
+			in.send(ev.Name)
+
+			if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// Watches of removed directories are released by the kernel.
+				delete(in.dirs, ev.Name)
+			}
+
+			if ev.Op&fsnotify.Create != 0 {
+				fi, err := os.Lstat(ev.Name)
+				if err != nil || !fi.IsDir() {
+					continue
+				}
+
+				// Files may have been created in the new directory before
+				// the watch was added, so report all of them.
+				if err := in.addTree(ev.Name, in.send); err != nil {
+					in.err = err
+					return
+				}
+			}
+		case err, ok := <-in.w.Errors:
+			if !ok {
+				return
+			}
+
+			in.err = err
+			return
+		case <-in.closeC:
+			return
+		}
+	}
+}
+
+// addTree adds watches for dir and all its subdirectories. If fn is
+// non-nil, it is called with each file found in the tree.
+func (in *inotify) addTree(dir string, fn func(string)) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil // file was removed in the meantime
+		}
+
+		if fn != nil && path != dir {
+			fn(path)
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		if _, ok := in.dirs[path]; ok {
+			return nil
+		}
+
+		if in.max > 0 && len(in.dirs) >= in.max {
+			return errWatchLimit
+		}
+
+		if err := in.w.Add(path); err != nil {
+			if err == syscall.ENOSPC {
+				return errWatchLimit
+			}
+
+			return err
+		}
+
+		in.dirs[path] = struct{}{}
+
+		return nil
+	})
+}
+
+func (in *inotify) send(path string) {
+	rel, err := filepath.Rel(in.root, path)
+	if err != nil || rel == "." {
+		return
+	}
+
+	select {
+	case in.events <- filepath.ToSlash(rel):
+	case <-in.closeC:
+	}
+}
+
+func (in *inotify) Events() <-chan string { return in.events }
+
+func (in *inotify) Err() error { return in.err }
+
+func (in *inotify) Close() error {
+	in.once.Do(func() {
+		close(in.closeC)
+	})
+
+	return in.w.Close()
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package native
+
+import "errors"
+
+func newInotify(string, int) (watcher, error) {
+	return nil, errors.New("inotify is not supported on this platform")
+}
@@ -0,0 +1,481 @@
+// Package native implements file system notifications for mounts that
+// cannot use FUSE, like the ones created inside containers.
+//
+// Notifier watches cache directory of the mount with inotify, if it is
+// available, and falls back to polling otherwise. Since the cache
+// directory is modified directly, the mount path becomes a symbolic
+// link to it.
+package native
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"koding/klient/machine"
+	"koding/klient/machine/index"
+	"koding/klient/machine/index/node"
+	"koding/klient/machine/mount/notify"
+
+	"github.com/koding/logging"
+)
+
+var (
+	defaultDebounce     = 200 * time.Millisecond
+	defaultPollInterval = 2 * time.Second
+)
+
+// errWatchLimit is returned by watchers, when the limit of watched
+// directories was exceeded.
+var errWatchLimit = errors.New("watch limit exceeded")
+
+// Builder provides a default notify.Builder for native notifications.
+var Builder notify.Builder = builder{}
+
+type builder struct{}
+
+// Build implements the notify.Builder interface.
+func (builder) Build(opts *notify.BuildOpts) (notify.Notifier, error) {
+	o := &Options{
+		Index:    opts.Index,
+		Cache:    opts.Cache,
+		CacheDir: opts.CacheDir,
+		MountDir: opts.Path,
+		Log:      opts.Log,
+	}
+
+	// Reverse mounts watch mount directory directly.
+	if o.MountDir == o.CacheDir {
+		o.MountDir = ""
+	}
+
+	if err := o.Valid(); err != nil {
+		return nil, err
+	}
+
+	return NewNotifier(o)
+}
+
+// Options configures native notifier.
+type Options struct {
+	Index    *index.Index // metadata index
+	Cache    notify.Cache // used to request cache updates
+	CacheDir string       // path of the cache directory of the mount
+	MountDir string       // path of the mount directory; if empty, no link is created
+
+	// Debounce is a period during which file system events are gathered
+	// before they are committed to the cache. If zero, 200ms is used.
+	Debounce time.Duration
+
+	// PollInterval is an interval between subsequent scans of cache
+	// directory when polling is used. If zero, 2s is used.
+	PollInterval time.Duration
+
+	// Poll forces polling even if inotify is available.
+	Poll bool
+
+	// MaxWatches limits the number of directories watched with inotify.
+	// When exceeded, notifier falls back to polling. If zero, only the
+	// fs.inotify.max_user_watches kernel limit applies.
+	MaxWatches int
+
+	Log logging.Logger // log mount specific info
+}
+
+// Valid checks if provided options are valid.
+func (o *Options) Valid() error {
+	if o.Index == nil {
+		return errors.New("index is nil")
+	}
+	if o.Cache == nil {
+		return errors.New("cache is nil")
+	}
+	if o.CacheDir == "" {
+		return errors.New("cache directory is empty")
+	}
+
+	return nil
+}
+
+func (o *Options) debounce() time.Duration {
+	if o.Debounce > 0 {
+		return o.Debounce
+	}
+	return defaultDebounce
+}
+
+func (o *Options) pollInterval() time.Duration {
+	if o.PollInterval > 0 {
+		return o.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// watcher is a source of changed paths, relative to the cache directory.
+type watcher interface {
+	// Events gives paths of changed files. The channel is closed when the
+	// watcher stops, in which case Err describes the reason.
+	Events() <-chan string
+
+	// Err gives an error, which caused the watcher to stop.
+	Err() error
+
+	Close() error
+}
+
+// Notifier watches cache directory of a mount and commits each local
+// change to the cache.
+type Notifier struct {
+	opts Options
+	log  logging.Logger
+	link bool // whether mount directory is a link created by notifier
+
+	mu      sync.Mutex
+	w       watcher
+	pending map[string]struct{} // paths of changed files to commit
+
+	once   sync.Once
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// NewNotifier creates a new notifier for the given options and
+// starts watching cache directory.
+func NewNotifier(opts *Options) (*Notifier, error) {
+	if err := opts.Valid(); err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		opts:    *opts,
+		pending: make(map[string]struct{}),
+		closeC:  make(chan struct{}),
+		doneC:   make(chan struct{}),
+	}
+
+	if opts.Log != nil {
+		n.log = opts.Log.New("native")
+	} else {
+		n.log = machine.DefaultLogger.New("native")
+	}
+
+	if err := os.MkdirAll(n.opts.CacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := n.linkMountDir(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if !n.opts.Poll {
+		n.w, err = newInotify(n.opts.CacheDir, n.opts.MaxWatches)
+	}
+
+	if n.opts.Poll || err != nil {
+		if err != nil {
+			n.warnFallback(err)
+		}
+
+		if n.w, err = newPoller(n.opts.CacheDir, n.opts.pollInterval()); err != nil {
+			return nil, nonil(err, n.unlinkMountDir())
+		}
+	}
+
+	go n.loop()
+
+	return n, nil
+}
+
+func (n *Notifier) loop() {
+	defer close(n.doneC)
+
+	var (
+		flush  <-chan time.Time
+		events = n.w.Events()
+	)
+
+	for {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				events = n.fallback()
+				continue
+			}
+
+			n.mu.Lock()
+			n.pending[path] = struct{}{}
+			n.mu.Unlock()
+
+			// The debounce period is not extended by consecutive events,
+			// so a file that is constantly written does not starve others.
+			if flush == nil {
+				flush = time.After(n.opts.debounce())
+			}
+		case <-flush:
+			flush = nil
+			n.flush()
+		case <-n.closeC:
+			return
+		}
+	}
+}
+
+// fallback replaces stopped inotify watcher with a poller.
+func (n *Notifier) fallback() <-chan string {
+	n.warnFallback(n.w.Err())
+	n.w.Close()
+
+	w, err := newPoller(n.opts.CacheDir, n.opts.pollInterval())
+	if err != nil {
+		n.log.Error("Unable to start polling, local changes will not be synced: %s", err)
+		return nil
+	}
+
+	n.mu.Lock()
+	n.w = w
+	n.mu.Unlock()
+
+	// Poller treats all existing files as known, so changes made
+	// before it took a snapshot need to be looked up in the index.
+	go n.rescan()
+
+	return w.Events()
+}
+
+func (n *Notifier) warnFallback(err error) {
+	if err == errWatchLimit {
+		n.log.Warning("Inotify watch limit exceeded for %s, falling back to polling. Consider "+
+			"increasing the fs.inotify.max_user_watches sysctl value.", n.opts.CacheDir)
+		return
+	}
+
+	n.log.Warning("Unable to use inotify for %s, falling back to polling: %v", n.opts.CacheDir, err)
+}
+
+// isTemp reports whether the file is a temporary one created by rsync
+// during remote->local synchronization, like .file.txt.Z1Hc4q.
+func isTemp(path string) bool {
+	name := filepath.Base(filepath.FromSlash(path))
+
+	if len(name) < 9 || name[0] != '.' || name[len(name)-7] != '.' {
+		return false
+	}
+
+	for _, r := range name[len(name)-6:] {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rescan queues all files that differ from their index entries.
+func (n *Notifier) rescan() {
+	var paths []string
+
+	filepath.Walk(n.opts.CacheDir, func(path string, _ os.FileInfo, err error) error {
+		if err == nil {
+			if rel, err := n.rel(path); err == nil && rel != "" {
+				paths = append(paths, rel)
+			}
+		}
+		return nil
+	})
+
+	n.opts.Index.Tree().DoPath("", node.WalkPath(func(path string, _ node.Guard, nd *node.Node) {
+		if path != "" && !nd.IsShadowed() {
+			paths = append(paths, path)
+		}
+	}))
+
+	n.mu.Lock()
+	for _, path := range paths {
+		n.pending[path] = struct{}{}
+	}
+	n.mu.Unlock()
+
+	n.flush()
+}
+
+// flush commits all pending changes to the cache.
+func (n *Notifier) flush() {
+	n.mu.Lock()
+	pending := n.pending
+	n.pending = make(map[string]struct{})
+	n.mu.Unlock()
+
+	for path := range pending {
+		if c := n.change(path); c != nil {
+			n.log.Debug("Committing local change: %s", c)
+
+			n.opts.Cache.Commit(c)
+		}
+	}
+}
+
+// change compares the file on disk with its index entry and gives
+// a local change, which describes the difference. If the file is
+// up to date, nil is returned.
+//
+// Files written by remote->local synchronization are already
+// in the index, thus they do not produce any changes.
+func (n *Notifier) change(path string) *index.Change {
+	if isTemp(path) {
+		return nil
+	}
+
+	info, err := os.Lstat(filepath.Join(n.opts.CacheDir, filepath.FromSlash(path)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil
+	}
+
+	var entry *node.Entry
+
+	n.opts.Index.Tree().DoPath(path, func(_ node.Guard, nd *node.Node) bool {
+		if nd.IsShadowed() {
+			return false // do not add a shadow node to the tree
+		}
+
+		if nd.Exist() {
+			entry = nd.Entry.Clone()
+		}
+
+		return true
+	})
+
+	var meta index.ChangeMeta
+
+	switch {
+	case os.IsNotExist(err) && entry == nil:
+		return nil
+	case os.IsNotExist(err):
+		meta = index.ChangeMetaRemove
+	case entry == nil:
+		meta = index.ChangeMetaAdd
+	case info.IsDir():
+		// Directory modification time changes together with its
+		// content, which is handled with separate changes.
+		return nil
+	case !modified(entry, info):
+		return nil
+	default:
+		meta = index.ChangeMetaUpdate
+	}
+
+	return index.NewChange(path, index.PriorityMedium, meta|index.ChangeMetaLocal)
+}
+
+func modified(e *node.Entry, info os.FileInfo) bool {
+	f := node.NewEntryFileInfo(info)
+
+	return e.File.Size != f.File.Size ||
+		e.File.MTime != f.File.MTime ||
+		e.File.Mode != f.File.Mode
+}
+
+func (n *Notifier) rel(path string) (string, error) {
+	rel, err := filepath.Rel(n.opts.CacheDir, path)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == "." {
+		return "", nil
+	}
+
+	if strings.HasPrefix(rel, "..") {
+		return "", errors.New("path is outside of cache directory: " + path)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// linkMountDir makes mount directory a symbolic link to the cache
+// directory, so local changes are made directly in the cache.
+//
+// Non-empty mount directories are left intact.
+func (n *Notifier) linkMountDir() error {
+	if n.opts.MountDir == "" {
+		return nil
+	}
+
+	fi, err := os.Lstat(n.opts.MountDir)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case fi.Mode()&os.ModeSymlink != 0:
+		if target, err := os.Readlink(n.opts.MountDir); err == nil && target == n.opts.CacheDir {
+			n.link = true
+			return nil
+		}
+
+		return errors.New("mount directory is a link: " + n.opts.MountDir)
+	case !fi.IsDir():
+		return errors.New("mount path is not a directory: " + n.opts.MountDir)
+	default:
+		// Remove fails for non-empty directories.
+		if err := os.Remove(n.opts.MountDir); err != nil {
+			n.log.Warning("Mount directory %s is not empty, changes must be made in %s",
+				n.opts.MountDir, n.opts.CacheDir)
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(n.opts.MountDir), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Symlink(n.opts.CacheDir, n.opts.MountDir); err != nil {
+		return err
+	}
+
+	n.link = true
+
+	return nil
+}
+
+// unlinkMountDir restores mount directory replaced by linkMountDir.
+func (n *Notifier) unlinkMountDir() error {
+	if !n.link {
+		return nil
+	}
+
+	if err := os.Remove(n.opts.MountDir); err != nil {
+		return err
+	}
+
+	return os.Mkdir(n.opts.MountDir, 0755)
+}
+
+// Close stops watching cache directory and removes
+// the link from mount directory.
+func (n *Notifier) Close() (err error) {
+	n.once.Do(func() {
+		close(n.closeC)
+		<-n.doneC
+
+		n.mu.Lock()
+		w := n.w
+		n.mu.Unlock()
+
+		err = nonil(w.Close(), n.unlinkMountDir())
+	})
+
+	return err
+}
+
+func nonil(err ...error) error {
+	for _, e := range err {
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
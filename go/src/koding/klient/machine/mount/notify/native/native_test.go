@@ -0,0 +1,160 @@
+package native_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"koding/klient/machine/index"
+	"koding/klient/machine/mount/notify/native"
+)
+
+type changeCache struct {
+	mu      sync.Mutex
+	changes []*index.Change
+}
+
+func (cc *changeCache) Commit(c *index.Change) context.Context {
+	cc.mu.Lock()
+	cc.changes = append(cc.changes, c)
+	cc.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func (cc *changeCache) wait(t *testing.T, path string, meta index.ChangeMeta) {
+	timeout := time.After(10 * time.Second)
+
+	for {
+		cc.mu.Lock()
+		for _, c := range cc.changes {
+			if c.Path() == path && c.Meta()&meta == meta {
+				cc.mu.Unlock()
+				return
+			}
+		}
+		cc.mu.Unlock()
+
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s change of %q", &meta, path)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func (cc *changeCache) has(path string) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for _, c := range cc.changes {
+		if c.Path() == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestNotifier(t *testing.T) {
+	tests := map[string]*native.Options{
+		"inotify": {},
+		"polling": {
+			Poll:         true,
+			PollInterval: 50 * time.Millisecond,
+		},
+		"watch limit": {
+			MaxWatches:   2,
+			PollInterval: 50 * time.Millisecond,
+		},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			wd, err := ioutil.TempDir("", "native")
+			if err != nil {
+				t.Fatalf("TempDir()=%s", err)
+			}
+			defer os.RemoveAll(wd)
+
+			cacheDir := filepath.Join(wd, "cache")
+			mountDir := filepath.Join(wd, "mount")
+
+			for _, file := range []string{"a/synced.txt", "a/removed.txt"} {
+				writeFile(t, filepath.Join(cacheDir, file), "remote")
+			}
+
+			idx, err := index.NewIndexFiles(cacheDir, nil)
+			if err != nil {
+				t.Fatalf("NewIndexFiles()=%s", err)
+			}
+
+			cache := &changeCache{}
+
+			opts.Index = idx
+			opts.Cache = cache
+			opts.CacheDir = cacheDir
+			opts.MountDir = mountDir
+			opts.Debounce = 20 * time.Millisecond
+
+			n, err := native.NewNotifier(opts)
+			if err != nil {
+				t.Fatalf("NewNotifier()=%s", err)
+			}
+
+			if target, err := os.Readlink(mountDir); err != nil || target != cacheDir {
+				t.Fatalf("got %q link (%v), want %q", target, err, cacheDir)
+			}
+
+			// Files are written through the mount directory.
+			writeFile(t, filepath.Join(mountDir, "a", "added.txt"), "local")
+			cache.wait(t, "a/added.txt", index.ChangeMetaAdd|index.ChangeMetaLocal)
+
+			// Creating nested directories exceeds the limit of watches.
+			writeFile(t, filepath.Join(mountDir, "b", "c", "nested.txt"), "local")
+			cache.wait(t, "b/c/nested.txt", index.ChangeMetaAdd|index.ChangeMetaLocal)
+
+			time.Sleep(10 * time.Millisecond) // ensure mtime differs
+			writeFile(t, filepath.Join(mountDir, "a", "synced.txt"), "local change")
+			cache.wait(t, "a/synced.txt", index.ChangeMetaUpdate|index.ChangeMetaLocal)
+
+			if err := os.Remove(filepath.Join(mountDir, "a", "removed.txt")); err != nil {
+				t.Fatalf("Remove()=%s", err)
+			}
+			cache.wait(t, "a/removed.txt", index.ChangeMetaRemove|index.ChangeMetaLocal)
+
+			// Temporary files created by rsync are ignored.
+			writeFile(t, filepath.Join(mountDir, "a", ".tmp.txt.Ab3dE9"), "remote")
+			writeFile(t, filepath.Join(mountDir, "a", "last.txt"), "local")
+			cache.wait(t, "a/last.txt", index.ChangeMetaAdd|index.ChangeMetaLocal)
+
+			if cache.has("a/.tmp.txt.Ab3dE9") {
+				t.Fatal("expected rsync temporary file to be ignored")
+			}
+
+			if err := n.Close(); err != nil {
+				t.Fatalf("Close()=%s", err)
+			}
+
+			if fi, err := os.Lstat(mountDir); err != nil || !fi.IsDir() {
+				t.Fatalf("expected mount directory to be restored: %v", err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll()=%s", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+}
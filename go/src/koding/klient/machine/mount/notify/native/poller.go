@@ -0,0 +1,147 @@
+package native
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileState describes a file observed by poller.
+type fileState struct {
+	size  int64
+	mtime time.Time
+	mode  os.FileMode
+}
+
+// poller watches a directory tree by scanning it periodically and
+// comparing the result with the previous scan. It is used when
+// inotify is not available or its limits were exhausted.
+type poller struct {
+	root     string
+	interval time.Duration
+	files    map[string]fileState // maps path relative to root to its state
+
+	events chan string
+
+	once   sync.Once
+	closeC chan struct{}
+}
+
+var _ watcher = (*poller)(nil)
+
+func newPoller(root string, interval time.Duration) (watcher, error) {
+	files, err := scan(root)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &poller{
+		root:     root,
+		interval: interval,
+		files:    files,
+		events:   make(chan string),
+		closeC:   make(chan struct{}),
+	}
+
+	go p.loop()
+
+	return p, nil
+}
+
+func (p *poller) loop() {
+	defer close(p.events)
+
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			files, err := scan(p.root)
+			if err != nil {
+				continue
+			}
+
+			for path, st := range files {
+				if old, ok := p.files[path]; !ok || old != st {
+					if !p.send(path) {
+						return
+					}
+				}
+			}
+
+			for path := range p.files {
+				if _, ok := files[path]; !ok {
+					if !p.send(path) {
+						return
+					}
+				}
+			}
+
+			p.files = files
+		case <-p.closeC:
+			return
+		}
+	}
+}
+
+func (p *poller) send(path string) bool {
+	select {
+	case p.events <- path:
+		return true
+	case <-p.closeC:
+		return false
+	}
+}
+
+func (p *poller) Events() <-chan string { return p.events }
+
+func (p *poller) Err() error { return nil }
+
+func (p *poller) Close() error {
+	p.once.Do(func() {
+		close(p.closeC)
+	})
+
+	return nil
+}
+
+// scan reads states of all files in the tree rooted at root.
+func scan(root string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+
+			return nil // file was removed in the meantime
+		}
+
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		st := fileState{
+			mtime: fi.ModTime(),
+			mode:  fi.Mode(),
+		}
+
+		if !fi.IsDir() {
+			st.size = fi.Size()
+		}
+
+		files[filepath.ToSlash(rel)] = st
+
+		return nil
+	})
+
+	return files, err
+}
@@ -2,7 +2,9 @@ package notify
 
 import (
 	"context"
+	"errors"
 	"io"
+	"strings"
 
 	"koding/klient/machine/index"
 
@@ -31,6 +33,35 @@ type Builder interface {
 	Build(opts *BuildOpts) (Notifier, error)
 }
 
+// FallbackBuilder is a notify.Builder that tries each of the builders in
+// order and uses the first one that succeeds. It is useful when the preferred
+// notifier may be unavailable, like FUSE inside containers.
+type FallbackBuilder []Builder
+
+// Build implements the Builder interface.
+func (fb FallbackBuilder) Build(opts *BuildOpts) (Notifier, error) {
+	var errs []string
+
+	for i, b := range fb {
+		n, err := b.Build(opts)
+		if err == nil {
+			return n, nil
+		}
+
+		if opts.Log != nil && i < len(fb)-1 {
+			opts.Log.Warning("Unable to build notifier, trying next one: %s", err)
+		}
+
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("no notify builders provided")
+	}
+
+	return nil, errors.New("unable to build notifier: " + strings.Join(errs, "; "))
+}
+
 // Notifier is an interface which must be implemented by external notifiers.
 type Notifier interface {
 	// Close cleans up notifier resources, if any.
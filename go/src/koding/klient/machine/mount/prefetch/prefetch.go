@@ -1,12 +1,17 @@
 package prefetch
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 
 	"koding/klient/machine/index"
 	"koding/klient/machine/transport/rsync"
+
+	humanize "github.com/dustin/go-humanize"
 )
 
 // Prefetcher defines a set of methods that are needed to safely prefetch
@@ -46,6 +51,13 @@ type Options struct {
 
 	// SSHPort defines custom remote shell port.
 	SSHPort int `json:"sshPort"`
+
+	// Budget defines the maximum size of files downloaded by selective
+	// prefetchers. If zero, selective prefetchers are not used.
+	Budget int64 `json:"budget,omitempty"`
+
+	// Manifest contains patterns read from remote directory manifest file.
+	Manifest []string `json:"manifest,omitempty"`
 }
 
 // Prefetch is used to initially prefetch files from remote machine to local
@@ -64,6 +76,10 @@ type Prefetch struct {
 
 	// DiskSize stores the size of all fetched files.
 	DiskSize int64 `json:"diskSize"`
+
+	// Files stores files chosen by selective prefetcher. If empty, all files
+	// from source path are downloaded.
+	Files []File `json:"files,omitempty"`
 }
 
 // Run ues rsync to prefetch files. It writes information about prefetching
@@ -97,12 +113,50 @@ func (p *Prefetch) Run(w io.Writer, s Strategy, privPath string) error {
 		Progress:        rsync.Progress(w, p.Count, p.DiskSize),
 	}
 
+	if len(p.Files) != 0 {
+		path, err := p.writeFiles()
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+
+		cmd.FilesFrom = path
+		fmt.Fprintf(w, "Prefetching %d file(s) within %s budget.\n", p.Count, humanize.IBytes(uint64(p.Budget)))
+	}
+
 	// Create initial progess report and run the command.
 	cmd.Progress(0, 0, 0, nil)
 
 	return nonil(cmd.Run(context.Background()), pref.PostRun(p.WorkDir))
 }
 
+// writeFiles creates a temporary file with a list of prefetched files.
+func (p *Prefetch) writeFiles() (string, error) {
+	f, err := ioutil.TempFile("", "kd-prefetch")
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, file := range p.Files {
+		buf.WriteString(file.Path)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
 func nonil(err ...error) error {
 	for _, e := range err {
 		if e != nil {
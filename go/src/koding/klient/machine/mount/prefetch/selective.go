@@ -0,0 +1,332 @@
+package prefetch
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"koding/klient/machine/index"
+	"koding/klient/machine/index/node"
+)
+
+// ManifestFileName is the name of per-project prefetch manifest file. It is
+// looked up in the root of mounted remote directory.
+const ManifestFileName = ".kdprefetch"
+
+// DefaultSourcePatterns defines patterns of files which Source prefetcher
+// downloads.
+var DefaultSourcePatterns = []string{
+	"*.go", "*.c", "*.h", "*.cc", "*.cpp", "*.hpp", "*.java", "*.kt", "*.scala",
+	"*.py", "*.rb", "*.php", "*.js", "*.jsx", "*.ts", "*.tsx", "*.coffee",
+	"*.rs", "*.swift", "*.sh", "*.sql", "*.proto", "*.html", "*.css", "*.scss",
+	"*.json", "*.yml", "*.yaml", "*.toml", "*.xml", "*.md", "*.txt",
+	"Makefile", "Dockerfile", "Vagrantfile", "Gemfile", "*.gradle",
+}
+
+// DefaultBinaryPatterns defines patterns of files which are never downloaded
+// by Source prefetcher.
+var DefaultBinaryPatterns = []string{
+	"*.o", "*.a", "*.so", "*.dylib", "*.dll", "*.exe", "*.class", "*.jar",
+	"*.pyc", "*.zip", "*.tar", "*.gz", "*.tgz", "*.bz2", "*.xz", "*.7z",
+	"*.iso", "*.img", "*.dmg", "*.png", "*.jpg", "*.jpeg", "*.gif", "*.ico",
+	"*.pdf", "*.mp3", "*.mp4", "*.mov", "*.avi", "*.bin", "*.db", "*.sqlite",
+	".git/",
+}
+
+// File describes a single file chosen by selective prefetcher.
+type File struct {
+	Path   string `json:"path"`   // Path relative to mount root.
+	Size   int64  `json:"size"`   // Size of the file.
+	Reason string `json:"reason"` // Why the file was chosen.
+}
+
+// Selector is implemented by prefetchers which download only selected files
+// that fit in a configured byte budget.
+type Selector interface {
+	Prefetcher
+
+	// Select chooses files to prefetch from provided index. Files are ordered
+	// by their priority. Non-nil error is returned when the prefetcher cannot
+	// be applied.
+	Select(idx *index.Index, opts *Options) ([]File, error)
+}
+
+var (
+	_ Selector = Manifest{}
+	_ Selector = Source{}
+	_ Selector = Recent{}
+)
+
+// errSelective is returned by Scan method of selective prefetchers.
+var errSelective = errors.New("selective prefetcher requires a budget")
+
+// Manifest prefetcher downloads files listed in per-project .kdprefetch
+// manifest. The manifest contains one pattern per line and lines starting
+// with '#' are comments. Patterns use path.Match syntax:
+//
+//   - patterns without slash are matched against file names,
+//   - patterns with trailing slash match all files in named directory,
+//   - other patterns are matched against paths relative to mount root,
+//   - patterns starting with '!' exclude matching files.
+//
+// Files matching earlier patterns are prefetched first.
+type Manifest struct{}
+
+// Available always returns true since Manifest prefetcher doesn't need any
+// additional third-party tools.
+func (Manifest) Available() bool { return true }
+
+// Weight returns Manifest prefetcher weight.
+func (Manifest) Weight() int { return 90 }
+
+// Scan always fails since Manifest prefetcher needs to be selected.
+func (Manifest) Scan(_ *index.Index) (string, int64, int64, error) {
+	return "", 0, 0, errSelective
+}
+
+// Select chooses files listed in the manifest.
+func (Manifest) Select(idx *index.Index, opts *Options) ([]File, error) {
+	if len(opts.Manifest) == 0 {
+		return nil, errors.New("remote directory has no " + ManifestFileName + " manifest")
+	}
+
+	var include, exclude []string
+	for _, p := range opts.Manifest {
+		if strings.HasPrefix(p, "!") {
+			exclude = append(exclude, p[1:])
+		} else {
+			include = append(include, p)
+		}
+	}
+
+	return selectFiles(idx, opts.Budget, func(path string, _ *node.Entry) (int, string, bool) {
+		if match(exclude, path) != "" {
+			return 0, "", false
+		}
+
+		for i, p := range include {
+			if matchPattern(p, path) {
+				return len(include) - i, "listed in " + ManifestFileName + " (" + p + ")", true
+			}
+		}
+
+		return 0, "", false
+	})
+}
+
+// PostRun is a no-op for Manifest prefetcher.
+func (Manifest) PostRun(_ string) error { return nil }
+
+// Source prefetcher downloads source files only, except the ones matching
+// binary patterns. Recently used files are preferred. When there are no source
+// files, other prefetchers are tried.
+type Source struct {
+	Include []string // Patterns of source files. If nil, DefaultSourcePatterns are used.
+	Exclude []string // Patterns of binary files. If nil, DefaultBinaryPatterns are used.
+}
+
+// Available always returns true since Source prefetcher doesn't need any
+// additional third-party tools.
+func (Source) Available() bool { return true }
+
+// Weight returns Source prefetcher weight.
+func (Source) Weight() int { return 60 }
+
+// Scan always fails since Source prefetcher needs to be selected.
+func (Source) Scan(_ *index.Index) (string, int64, int64, error) {
+	return "", 0, 0, errSelective
+}
+
+// Select chooses source files.
+func (s Source) Select(idx *index.Index, opts *Options) ([]File, error) {
+	include, exclude := s.Include, s.Exclude
+	if include == nil {
+		include = DefaultSourcePatterns
+	}
+	if exclude == nil {
+		exclude = DefaultBinaryPatterns
+	}
+
+	return selectFiles(idx, opts.Budget, func(path string, _ *node.Entry) (int, string, bool) {
+		if match(exclude, path) != "" {
+			return 0, "", false
+		}
+
+		if p := match(include, path); p != "" {
+			return 0, "source file (" + p + ")", true
+		}
+
+		return 0, "", false
+	})
+}
+
+// PostRun is a no-op for Source prefetcher.
+func (Source) PostRun(_ string) error { return nil }
+
+// Recent prefetcher downloads the most recently modified or accessed files.
+type Recent struct{}
+
+// Available always returns true since Recent prefetcher doesn't need any
+// additional third-party tools.
+func (Recent) Available() bool { return true }
+
+// Weight returns Recent prefetcher weight.
+func (Recent) Weight() int { return 50 }
+
+// Scan always fails since Recent prefetcher needs to be selected.
+func (Recent) Scan(_ *index.Index) (string, int64, int64, error) {
+	return "", 0, 0, errSelective
+}
+
+// Select chooses the most recently used files.
+func (Recent) Select(idx *index.Index, opts *Options) ([]File, error) {
+	return selectFiles(idx, opts.Budget, func(_ string, e *node.Entry) (int, string, bool) {
+		if e.File.ATime > e.File.MTime {
+			return 0, "accessed at " + formatTime(e.File.ATime), true
+		}
+
+		return 0, "modified at " + formatTime(e.File.MTime), true
+	})
+}
+
+// PostRun is a no-op for Recent prefetcher.
+func (Recent) PostRun(_ string) error { return nil }
+
+// ParseManifest reads patterns from manifest file content.
+func ParseManifest(data []byte) (patterns []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, line)
+	}
+
+	return patterns
+}
+
+// rankFunc gives the priority of a given file and the reason why it should be
+// prefetched. Files with higher priority are prefetched first. If false is
+// returned, the file is not prefetched at all.
+type rankFunc func(path string, e *node.Entry) (prio int, reason string, ok bool)
+
+type candidate struct {
+	File
+	prio int
+	used int64
+}
+
+// selectFiles ranks all regular files stored in the index and chooses
+// the ones that fit in provided budget. It fails when the budget is not set
+// or it is large enough to fetch all files.
+func selectFiles(idx *index.Index, budget int64, rank rankFunc) ([]File, error) {
+	if budget <= 0 {
+		return nil, errors.New("prefetch budget is not set")
+	}
+
+	if size := idx.Tree().DiskSize(); size <= budget {
+		return nil, fmt.Errorf("all files (%d bytes) fit in the budget", size)
+	}
+
+	var cs []candidate
+	idx.Tree().DoPath("", node.WalkPath(func(path string, _ node.Guard, n *node.Node) {
+		if n.IsShadowed() || n.Entry.File.Mode.IsDir() || path == "" {
+			return
+		}
+
+		prio, reason, ok := rank(path, n.Entry)
+		if !ok {
+			return
+		}
+
+		used := n.Entry.File.MTime
+		if n.Entry.File.ATime > used {
+			used = n.Entry.File.ATime
+		}
+
+		cs = append(cs, candidate{
+			File: File{
+				Path:   path,
+				Size:   n.Entry.File.Size,
+				Reason: reason,
+			},
+			prio: prio,
+			used: used,
+		})
+	}))
+
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].prio != cs[j].prio {
+			return cs[i].prio > cs[j].prio
+		}
+		if cs[i].used != cs[j].used {
+			return cs[i].used > cs[j].used
+		}
+		return cs[i].Path < cs[j].Path
+	})
+
+	var files []File
+	for _, c := range cs {
+		// Skip files larger than the remaining budget, smaller ones may
+		// still fit.
+		if c.Size > budget {
+			continue
+		}
+
+		budget -= c.Size
+		files = append(files, c.File)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no files to prefetch")
+	}
+
+	return files, nil
+}
+
+// match gives the first pattern which matches provided path.
+func match(patterns []string, path string) string {
+	for _, p := range patterns {
+		if matchPattern(p, path) {
+			return p
+		}
+	}
+
+	return ""
+}
+
+func matchPattern(pattern, file string) bool {
+	if dir := strings.TrimSuffix(pattern, "/"); dir != pattern {
+		if !strings.Contains(dir, "/") {
+			// Match directory name at any depth.
+			for _, name := range strings.Split(path.Dir(file), "/") {
+				if ok, _ := path.Match(dir, name); ok {
+					return true
+				}
+			}
+			return false
+		}
+
+		dir = strings.TrimPrefix(dir, "/")
+		return strings.HasPrefix(file, dir+"/")
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+
+	ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), file)
+	return ok
+}
+
+func formatTime(t int64) string {
+	return time.Unix(0, t).UTC().Format(time.RFC3339)
+}
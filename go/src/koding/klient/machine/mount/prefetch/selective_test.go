@@ -0,0 +1,153 @@
+package prefetch_test
+
+import (
+	"reflect"
+	"testing"
+
+	"koding/klient/machine/index"
+	"koding/klient/machine/index/node"
+	"koding/klient/machine/mount/prefetch"
+)
+
+func testIndex() *index.Index {
+	files := []struct {
+		path  string
+		size  int64
+		mtime int64
+		atime int64
+	}{
+		{"main.go", 10, 100, 0},
+		{"lib/util.go", 20, 300, 0},
+		{"lib/old.go", 20, 50, 0},
+		{"docs/guide.pdf", 40, 400, 0},
+		{"vendor/dep/dep.go", 30, 200, 500},
+		{"data/blob", 35, 150, 0},
+		{".git/objects/pack", 150, 600, 0},
+	}
+
+	idx := index.NewIndex()
+	for _, f := range files {
+		e := node.NewEntryTime(f.mtime, f.mtime, f.size, 0644, 0)
+		e.File.ATime = f.atime
+		idx.Tree().DoPath(f.path, node.Insert(e))
+	}
+
+	return idx
+}
+
+func paths(files []prefetch.File) []string {
+	var ps []string
+	for _, f := range files {
+		ps = append(ps, f.Path)
+	}
+	return ps
+}
+
+func TestSelective(t *testing.T) {
+	tests := map[string]struct {
+		Sel      prefetch.Selector
+		Opts     prefetch.Options
+		Expected []string
+		Err      bool
+	}{
+		"recent": {
+			Sel:      prefetch.Recent{},
+			Opts:     prefetch.Options{Budget: 100},
+			Expected: []string{"vendor/dep/dep.go", "docs/guide.pdf", "lib/util.go", "main.go"},
+		},
+		"source": {
+			Sel:      prefetch.Source{},
+			Opts:     prefetch.Options{Budget: 100},
+			Expected: []string{"vendor/dep/dep.go", "lib/util.go", "main.go", "lib/old.go"},
+		},
+		"source without other files": {
+			Sel:      prefetch.Source{},
+			Opts:     prefetch.Options{Budget: 125},
+			Expected: []string{"vendor/dep/dep.go", "lib/util.go", "main.go", "lib/old.go"},
+		},
+		"source missing": {
+			Sel:  prefetch.Source{Include: []string{"*.rs"}},
+			Opts: prefetch.Options{Budget: 100},
+			Err:  true,
+		},
+		"manifest": {
+			Sel: prefetch.Manifest{},
+			Opts: prefetch.Options{
+				Budget:   100,
+				Manifest: prefetch.ParseManifest([]byte("# comment\n\nlib/\n*.go\n!vendor/\n")),
+			},
+			Expected: []string{"lib/util.go", "lib/old.go", "main.go"},
+		},
+		"manifest missing": {
+			Sel:  prefetch.Manifest{},
+			Opts: prefetch.Options{Budget: 100},
+			Err:  true,
+		},
+		"no budget": {
+			Sel:  prefetch.Recent{},
+			Opts: prefetch.Options{},
+			Err:  true,
+		},
+		"fits in budget": {
+			Sel:  prefetch.Recent{},
+			Opts: prefetch.Options{Budget: 1000},
+			Err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test // Capture range variable.
+		t.Run(name, func(t *testing.T) {
+			files, err := test.Sel.Select(testIndex(), &test.Opts)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("want err != nil; got files %v", paths(files))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want err = nil; got %v", err)
+			}
+
+			if got := paths(files); !reflect.DeepEqual(got, test.Expected) {
+				t.Fatalf("want files = %v; got %v", test.Expected, got)
+			}
+
+			for _, f := range files {
+				if f.Reason == "" {
+					t.Fatalf("want non-empty reason for %s", f.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestStrategySelective(t *testing.T) {
+	s := prefetch.Strategy{
+		"source": prefetch.Source{},
+		"recent": prefetch.Recent{},
+		"all":    prefetch.All{},
+	}
+
+	p := s.Select(prefetch.Options{Budget: 50}, s.Available(), testIndex())
+	if p.Strategy != "source" {
+		t.Fatalf("want strategy = source; got %q", p.Strategy)
+	}
+
+	if p.Count != int64(len(p.Files)) || p.DiskSize > 50 {
+		t.Fatalf("want %d files within budget; got count = %d, size = %d", len(p.Files), p.Count, p.DiskSize)
+	}
+
+	// Recent prefetcher is used when there are no source files.
+	s["source"] = prefetch.Source{Include: []string{"*.rs"}}
+
+	if p = s.Select(prefetch.Options{Budget: 50}, s.Available(), testIndex()); p.Strategy != "recent" {
+		t.Fatalf("want strategy = recent; got %q", p.Strategy)
+	}
+
+	// Without budget, all files are prefetched.
+	if p = s.Select(prefetch.Options{}, s.Available(), testIndex()); p.Strategy != "all" || len(p.Files) != 0 {
+		t.Fatalf("want strategy = all; got %q with %d files", p.Strategy, len(p.Files))
+	}
+}
@@ -11,7 +11,10 @@ import (
 var DefaultStrategy = Strategy{
 	// TODO(rjeczalik): disabled due to #11135
 	// "git": Git{},
-	"all": All{},
+	"manifest": Manifest{},
+	"source":   Source{},
+	"recent":   Recent{},
+	"all":      All{},
 }
 
 // Strategy defines a way of choosing proper prefetching strategy.
@@ -72,6 +75,21 @@ func (s Strategy) Select(opts Options, av []string, idx *index.Index) Prefetch {
 			continue
 		}
 
+		if sel, ok := pref.(Selector); ok {
+			files, err := sel.Select(idx, &opts)
+			if err != nil {
+				continue
+			}
+
+			p.Strategy = name
+			p.Files = files
+			for _, f := range files {
+				p.Count++
+				p.DiskSize += f.Size
+			}
+			break
+		}
+
 		if suffix, count, diskSize, err := pref.Scan(idx); err == nil {
 			p.Strategy = name
 			p.SourcePath += suffix
@@ -0,0 +1,57 @@
+package reverse
+
+import (
+	"koding/klient/machine/index"
+
+	"github.com/koding/kite"
+)
+
+// KiteHandlerIndex creates a kite handler function that, when called, invokes
+// reverse package Index method.
+func KiteHandlerIndex() kite.HandlerFunc {
+	return func(r *kite.Request) (interface{}, error) {
+		req := &IndexRequest{
+			Index: index.NewIndex(),
+		}
+
+		if r.Args != nil {
+			if err := r.Args.One().Unmarshal(req); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := Index(req)
+		if err != nil {
+			return nil, &kite.Error{
+				Type:    "reverseError",
+				Message: err.Error(),
+			}
+		}
+
+		return res, nil
+	}
+}
+
+// KiteHandlerApply creates a kite handler function that, when called, invokes
+// reverse package Apply method.
+func KiteHandlerApply() kite.HandlerFunc {
+	return func(r *kite.Request) (interface{}, error) {
+		req := &ApplyRequest{}
+
+		if r.Args != nil {
+			if err := r.Args.One().Unmarshal(req); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := Apply(req)
+		if err != nil {
+			return nil, &kite.Error{
+				Type:    "reverseError",
+				Message: err.Error(),
+			}
+		}
+
+		return res, nil
+	}
+}
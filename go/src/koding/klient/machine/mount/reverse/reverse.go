@@ -0,0 +1,321 @@
+// Package reverse implements the remote side of reverse mounts. Reverse mounts
+// expose a local directory on remote machine - local klient sends the index
+// of its directory and the content of changed files, which are materialized
+// by remote klient.
+package reverse
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"koding/klient/fs"
+	"koding/klient/machine/index"
+	"koding/klient/machine/index/node"
+)
+
+// IndexRequest contains the index of local directory which is going to be
+// exposed on remote machine.
+type IndexRequest struct {
+	Path  string       `json:"remotePath"` // Remote directory path.
+	Index *index.Index `json:"index"`      // Index of local directory.
+}
+
+// IndexResponse describes the state of remote directory.
+type IndexResponse struct {
+	// AbsPath stores absolute representation of remote directory path.
+	AbsPath string `json:"absPath"`
+
+	// Needed stores paths of files which are either missing or differ from
+	// the ones described by received index. Their content must be applied.
+	Needed []string `json:"needed,omitempty"`
+}
+
+// Index creates remote directory together with all directories stored in
+// the index and finds files whose content needs to be sent. Files which
+// exist only in remote directory are left intact.
+func Index(req *IndexRequest) (*IndexResponse, error) {
+	if req == nil || req.Index == nil {
+		return nil, errors.New("invalid empty request")
+	}
+
+	absPath, err := preparePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return nil, err
+	}
+
+	res := &IndexResponse{
+		AbsPath: absPath,
+	}
+
+	req.Index.Tree().DoPath("", node.WalkPath(func(path string, _ node.Guard, n *node.Node) {
+		if path == "" || n.IsShadowed() {
+			return
+		}
+
+		file := n.Entry.File
+
+		name, e := join(absPath, path)
+		if e != nil {
+			return
+		}
+
+		if file.Mode.IsDir() {
+			if e := os.MkdirAll(name, file.Mode.Perm()|0700); e != nil && err == nil {
+				err = e
+			}
+
+			return
+		}
+
+		if info, e := os.Lstat(name); e != nil || !same(info, file) {
+			res.Needed = append(res.Needed, path)
+		}
+	}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ApplyRequest describes a single change of local file which needs to be
+// materialized in remote directory.
+type ApplyRequest struct {
+	Path string `json:"remotePath"` // Remote directory path.
+	Name string `json:"name"`       // Slash separated path of the file relative to Path.
+
+	// Remove indicates that the file no longer exists and should be removed.
+	Remove bool `json:"remove,omitempty"`
+
+	Mode    os.FileMode `json:"mode,omitempty"`    // File mode and permission bits.
+	MTime   int64       `json:"mtime,omitempty"`   // File data change time since EPOCH.
+	Content []byte      `json:"content,omitempty"` // File content or symlink target.
+}
+
+// ApplyResponse is a response value of remote change application.
+type ApplyResponse struct{}
+
+// Apply materializes provided change in remote directory. Regular files are
+// written atomically, so remote processes never see partially written files.
+func Apply(req *ApplyRequest) (*ApplyResponse, error) {
+	if req == nil {
+		return nil, errors.New("invalid empty request")
+	}
+
+	absPath, err := preparePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := join(absPath, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Remove:
+		err = os.RemoveAll(name)
+	case req.Mode.IsDir():
+		err = writeDir(name, req.Mode.Perm())
+	case req.Mode&os.ModeSymlink != 0:
+		err = writeSymlink(name, string(req.Content))
+	case req.Mode.IsRegular():
+		err = writeFile(name, req.Content, req.Mode.Perm(), req.MTime)
+	default:
+		err = fmt.Errorf("unsupported file type: %s", req.Mode)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApplyResponse{}, nil
+}
+
+func writeFile(name string, content []byte, perm os.FileMode, mtime int64) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := nonil(f.Chmod(perm), f.Close()); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if mtime != 0 {
+		t := time.Unix(0, mtime)
+		if err := os.Chtimes(f.Name(), t, t); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+func writeDir(name string, perm os.FileMode) error {
+	// Symbolic link replaced by a directory must not be followed.
+	if info, err := os.Lstat(name); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(name, perm|0700); err != nil {
+		return err
+	}
+
+	return os.Chmod(name, perm)
+}
+
+func writeSymlink(name, target string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(target, name)
+}
+
+// same checks if remote file matches its index entry.
+func same(info os.FileInfo, file node.File) bool {
+	if info.Mode()&os.ModeType != file.Mode&os.ModeType {
+		return false
+	}
+
+	// Symbolic links are not compared by their times.
+	if file.Mode&os.ModeSymlink != 0 {
+		return info.Size() == file.Size
+	}
+
+	return info.Size() == file.Size && info.ModTime().UnixNano() == file.MTime
+}
+
+// join joins slash separated file name with remote directory path. It fails
+// when the name points outside of the directory, either directly or through
+// symbolic links created by previously applied changes.
+//
+// The last element of the name is not resolved, since links are replaced
+// or removed by Apply rather than followed.
+func join(root, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file name: %q", name)
+	}
+
+	dir, err := resolve(root, filepath.Dir(clean))
+	if err != nil {
+		return "", fmt.Errorf("invalid file name: %q: %s", name, err)
+	}
+
+	return filepath.Join(dir, filepath.Base(clean)), nil
+}
+
+// resolve evaluates symbolic links in dir, which is relative to root. The
+// returned path is guaranteed to stay inside of root. Elements which do not
+// exist yet are joined as they are.
+func resolve(root, dir string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if os.IsNotExist(err) {
+		return filepath.Join(root, dir), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	path := realRoot
+
+	elems := strings.Split(dir, string(os.PathSeparator))
+
+	for i, elem := range elems {
+		if elem == "." {
+			continue
+		}
+
+		next := filepath.Join(path, elem)
+
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			return filepath.Join(append([]string{next}, elems[i+1:]...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if next, err = filepath.EvalSymlinks(next); err != nil {
+				return "", err
+			}
+
+			if !within(realRoot, next) {
+				return "", fmt.Errorf("%s points outside of %s", filepath.Join(elems[:i+1]...), root)
+			}
+		}
+
+		path = next
+	}
+
+	return path, nil
+}
+
+// within checks if path is equal to or located inside of the root directory.
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(os.PathSeparator))
+}
+
+func preparePath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("remote path is not set")
+	}
+
+	absPath, isDir, exist, err := fs.DefaultFS.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if exist && !isDir {
+		return "", fmt.Errorf("remote path %s is not a directory", absPath)
+	}
+
+	return absPath, nil
+}
+
+func nonil(err ...error) error {
+	for _, e := range err {
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package reverse_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
+)
+
+func TestIndex(t *testing.T) {
+	local, err := ioutil.TempDir("", "reverse.local")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(local)
+
+	remote, err := ioutil.TempDir("", "reverse.remote")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(remote)
+
+	files := map[string]string{
+		"a.txt":     "a",
+		"b.txt":     "b",
+		"dir/c.txt": "c",
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(local, name), content)
+	}
+
+	// Remote file b.txt is identical, a.txt differs and extra.txt exists
+	// only remotely.
+	writeFile(t, filepath.Join(remote, "a.txt"), "different")
+	copyFile(t, filepath.Join(local, "b.txt"), filepath.Join(remote, "b.txt"))
+	writeFile(t, filepath.Join(remote, "extra.txt"), "extra")
+
+	idx, err := index.NewIndexFiles(local, nil)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	res, err := reverse.Index(&reverse.IndexRequest{
+		Path:  filepath.Join(remote, "sub"),
+		Index: idx,
+	})
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	// Non existing remote directory should be created together with all
+	// files being needed.
+	want := []string{"a.txt", "b.txt", "dir/c.txt"}
+	if sort.Strings(res.Needed); !reflect.DeepEqual(res.Needed, want) {
+		t.Fatalf("want needed = %v; got %v", want, res.Needed)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "sub", "dir")); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	if res, err = reverse.Index(&reverse.IndexRequest{Path: remote, Index: idx}); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	want = []string{"a.txt", "dir/c.txt"}
+	if sort.Strings(res.Needed); !reflect.DeepEqual(res.Needed, want) {
+		t.Fatalf("want needed = %v; got %v", want, res.Needed)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "extra.txt")); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	remote, err := ioutil.TempDir("", "reverse.remote")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(remote)
+
+	outside, err := ioutil.TempDir("", "reverse.outside")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(outside)
+
+	if err := os.Chmod(outside, 0755); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	mtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		Name  string
+		Req   reverse.ApplyRequest
+		Check func(t *testing.T)
+		Err   bool
+	}{{
+		Name: "write file",
+		Req: reverse.ApplyRequest{
+			Name:    "dir/file.txt",
+			Mode:    0600,
+			MTime:   mtime.UnixNano(),
+			Content: []byte("content"),
+		},
+		Check: func(t *testing.T) {
+			name := filepath.Join(remote, "dir", "file.txt")
+			if data := readFile(t, name); data != "content" {
+				t.Fatalf("want content = %q; got %q", "content", data)
+			}
+
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatalf("want err = nil; got %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("want mode = %v; got %v", os.FileMode(0600), info.Mode().Perm())
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("want mtime = %v; got %v", mtime, info.ModTime())
+			}
+		},
+	}, {
+		Name: "symlink",
+		Req: reverse.ApplyRequest{
+			Name:    "link",
+			Mode:    os.ModeSymlink | 0777,
+			Content: []byte("dir/file.txt"),
+		},
+		Check: func(t *testing.T) {
+			if data := readFile(t, filepath.Join(remote, "link")); data != "content" {
+				t.Fatalf("want content = %q; got %q", "content", data)
+			}
+		},
+	}, {
+		Name: "remove directory",
+		Req: reverse.ApplyRequest{
+			Name:   "dir",
+			Remove: true,
+		},
+		Check: func(t *testing.T) {
+			if _, err := os.Stat(filepath.Join(remote, "dir")); !os.IsNotExist(err) {
+				t.Fatalf("want err = os.ErrNotExist; got %v", err)
+			}
+		},
+	}, {
+		Name: "outside directory",
+		Req: reverse.ApplyRequest{
+			Name:    "../escaped.txt",
+			Mode:    0644,
+			Content: []byte("content"),
+		},
+		Err: true,
+	}, {
+		Name: "symlink outside directory",
+		Req: reverse.ApplyRequest{
+			Name:    "escape",
+			Mode:    os.ModeSymlink | 0777,
+			Content: []byte(outside),
+		},
+		Check: func(t *testing.T) {},
+	}, {
+		Name: "write through symlink outside directory",
+		Req: reverse.ApplyRequest{
+			Name:    "escape/escaped.txt",
+			Mode:    0644,
+			Content: []byte("content"),
+		},
+		Err: true,
+	}, {
+		Name: "create directory through symlink outside directory",
+		Req: reverse.ApplyRequest{
+			Name: "escape/dir",
+			Mode: os.ModeDir | 0755,
+		},
+		Err: true,
+	}, {
+		Name: "replace symlink outside directory with directory",
+		Req: reverse.ApplyRequest{
+			Name: "escape",
+			Mode: os.ModeDir | 0700,
+		},
+		Check: func(t *testing.T) {
+			info, err := os.Lstat(filepath.Join(remote, "escape"))
+			if err != nil {
+				t.Fatalf("want err = nil; got %v", err)
+			}
+			if !info.IsDir() {
+				t.Fatalf("want escape to be a directory; got %v", info.Mode())
+			}
+
+			if info, err = os.Stat(outside); err != nil {
+				t.Fatalf("want err = nil; got %v", err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Fatalf("want outside mode = %v; got %v", os.FileMode(0755), info.Mode().Perm())
+			}
+		},
+	}, {
+		Name: "symlink inside directory",
+		Req: reverse.ApplyRequest{
+			Name:    "inside",
+			Mode:    os.ModeSymlink | 0777,
+			Content: []byte("escape"),
+		},
+		Check: func(t *testing.T) {},
+	}, {
+		Name: "write through symlink inside directory",
+		Req: reverse.ApplyRequest{
+			Name:    "inside/file.txt",
+			Mode:    0644,
+			Content: []byte("content"),
+		},
+		Check: func(t *testing.T) {
+			if data := readFile(t, filepath.Join(remote, "escape", "file.txt")); data != "content" {
+				t.Fatalf("want content = %q; got %q", "content", data)
+			}
+		},
+	}}
+
+	// Tests depend on each other, so they must be run sequentially.
+	for _, test := range tests {
+		test.Req.Path = remote
+		_, err := reverse.Apply(&test.Req)
+		if test.Err {
+			if err == nil {
+				t.Fatalf("%s: want err != nil; got nil", test.Name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: want err = nil; got %v", test.Name, err)
+		}
+
+		test.Check(t)
+	}
+
+	files, err := ioutil.ReadDir(outside)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("want no files outside remote directory; got %d", len(files))
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	return string(data)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	writeFile(t, dst, readFile(t, src))
+
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+}
@@ -3,7 +3,9 @@ package mount
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sync"
 	"time"
@@ -13,11 +15,14 @@ import (
 	"koding/klient/machine/client"
 	"koding/klient/machine/index"
 	"koding/klient/machine/index/filter"
+	"koding/klient/machine/index/node"
 	"koding/klient/machine/mount/notify"
 	"koding/klient/machine/mount/prefetch"
+	"koding/klient/machine/mount/reverse"
 	msync "koding/klient/machine/mount/sync"
 	"koding/klient/machine/mount/sync/history"
 	"koding/klient/machine/mount/sync/supervised"
+	"koding/klient/storage"
 
 	"github.com/koding/logging"
 )
@@ -25,6 +30,13 @@ import (
 // IndexFileName is a file name of managed directory index.
 const IndexFileName = "index"
 
+// PrefetchFileName is a file name of the last prefetch description.
+const PrefetchFileName = "prefetch"
+
+// HistoryKeyPrefix is a prefix of storage key under which synchronization
+// history of a mount is saved.
+const HistoryKeyPrefix = "history_"
+
 // DefaultFilter defines a default filter used to skip changes from being
 // synchronized.
 var DefaultFilter filter.Filter = filter.MultiFilter{
@@ -70,7 +82,8 @@ type Options struct {
 	//   WorkDir
 	//   |-data
 	//   | +-... // mounted directory cache.
-	//   +-index
+	//   |-index
+	//   +-prefetch
 	//
 	WorkDir string
 
@@ -86,6 +99,10 @@ type Options struct {
 	// will be used.
 	Filter filter.Filter
 
+	// Storage is used to persist synchronization history. If nil, history is
+	// kept only in memory.
+	Storage storage.ValueInterface
+
 	// Log is used for logging. If nil, default logger will be created.
 	Log logging.Logger
 }
@@ -171,8 +188,11 @@ func NewSync(mountID ID, m Mount, opts Options) (*Sync, error) {
 	// Path to index file.
 	idxPath := filepath.Join(s.opts.WorkDir, IndexFileName)
 
+	// Fresh mounts have no index file stored.
+	_, err := os.Stat(idxPath)
+	fresh := os.IsNotExist(err)
+
 	// Fetch remote index which will become managed one.
-	var err error
 	if s.idx, err = s.loadIdx(idxPath); err != nil {
 		return nil, err
 	}
@@ -183,6 +203,13 @@ func NewSync(mountID ID, m Mount, opts Options) (*Sync, error) {
 	// Create FS event consumer queue.
 	s.a = NewAnteroom()
 
+	// Send local files which are missing on remote machine.
+	if m.Reverse && fresh {
+		if err := s.putIdx(); err != nil {
+			return nil, nonil(err, s.a.Close(), s.iu.Close())
+		}
+	}
+
 	// Create file system notification object.
 	s.n, err = opts.NotifyBuilder.Build(&notify.BuildOpts{
 		ID:         string(mountID),
@@ -215,7 +242,16 @@ func NewSync(mountID ID, m Mount, opts Options) (*Sync, error) {
 	}
 
 	// Enable syncing history for all mounts.
-	s.s = history.NewHistory(syncer, config.Konfig.Mount.Inspect.History)
+	s.s, err = history.New(syncer, &history.Options{
+		Size:     config.Konfig.Mount.Inspect.History,
+		Storage:  opts.Storage,
+		Key:      HistoryKeyPrefix + string(mountID),
+		SizeFunc: s.fileSize,
+		Log:      s.log,
+	})
+	if err != nil {
+		return nil, nonil(err, syncer.Close(), s.n.Close(), s.a.Close(), s.iu.Close())
+	}
 
 	return s, nil
 }
@@ -272,6 +308,18 @@ func (s *Sync) History() ([]*history.Record, error) {
 	return nil, errors.New("synchronization history is unavailable")
 }
 
+// Activity gets history records that match provided filter together with
+// synchronization statistics computed from them.
+func (s *Sync) Activity(f *history.Filter) ([]*history.Record, *history.Stats, error) {
+	h, ok := s.s.(*history.History)
+	if !ok {
+		return nil, nil, errors.New("synchronization history is unavailable")
+	}
+
+	recs := h.Query(f)
+	return recs, history.NewStats(recs), nil
+}
+
 // IndexDebug gets current index tree debug information.
 func (s *Sync) IndexDebug() []index.Debug {
 	return s.idx.Debug()
@@ -282,8 +330,13 @@ func (s *Sync) Diagnose() []string {
 	return s.idx.Diagnose(s.CacheDir())
 }
 
-// CacheDir returns the name of mount cache directory.
+// CacheDir returns the name of mount cache directory. Reverse mounts use
+// their local directory as a cache.
 func (s *Sync) CacheDir() string {
+	if s.m.Reverse {
+		return s.m.Path
+	}
+
 	return filepath.Join(s.opts.WorkDir, "data")
 }
 
@@ -307,8 +360,10 @@ func (s *Sync) UpdateIndex() {
 	}
 }
 
-// Prefetch creates a strategy with prefetch command to run.
-func (s *Sync) Prefetch(av []string) (p prefetch.Prefetch, err error) {
+// Prefetch creates a strategy with prefetch command to run. Selective
+// strategies download at most budget bytes, if it is zero, the configured
+// value is used.
+func (s *Sync) Prefetch(av []string, budget int64) (p prefetch.Prefetch, err error) {
 	spv := client.NewSupervised(s.opts.ClientFunc, 30*time.Second)
 	// Get remote username.
 	username, err := spv.CurrentUser()
@@ -328,14 +383,88 @@ func (s *Sync) Prefetch(av []string) (p prefetch.Prefetch, err error) {
 		Username:        username,
 		Host:            host,
 		SSHPort:         port,
+		Budget:          budget,
+	}
+
+	if opts.Budget == 0 && config.Konfig.Mount.Prefetch != nil {
+		opts.Budget = config.Konfig.Mount.Prefetch.Budget
+	}
+
+	if s.hasFile(prefetch.ManifestFileName) {
+		data, err := spv.ReadFile(path.Join(s.m.RemotePath, prefetch.ManifestFileName))
+		if err != nil {
+			s.log.Warning("Cannot read prefetch manifest: %s", err)
+		} else {
+			opts.Manifest = prefetch.ParseManifest(data)
+		}
+	}
+
+	p = prefetch.DefaultStrategy.Select(opts, av, s.idx)
+
+	if err := s.savePrefetch(&p); err != nil {
+		s.log.Warning("Cannot save prefetch description: %s", err)
+	}
+
+	return p, nil
+}
+
+// PrefetchInfo gets the description of files prefetched for the mount.
+func (s *Sync) PrefetchInfo() (*prefetch.Prefetch, error) {
+	f, err := os.Open(filepath.Join(s.opts.WorkDir, PrefetchFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &prefetch.Prefetch{}
+	if err := json.NewDecoder(f).Decode(p); err != nil {
+		return nil, err
 	}
 
-	return prefetch.DefaultStrategy.Select(opts, av, s.idx), nil
+	return p, nil
+}
+
+func (s *Sync) savePrefetch(p *prefetch.Prefetch) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.opts.WorkDir, PrefetchFileName), data, 0644)
+}
+
+// fileSize gets the size of a given file stored in managed index.
+func (s *Sync) fileSize(name string) (size int64) {
+	s.idx.Tree().DoPath(name, func(_ node.Guard, n *node.Node) bool {
+		if !n.IsShadowed() && !n.Entry.File.Mode.IsDir() {
+			size = n.Entry.File.Size
+		}
+		return !n.IsShadowed()
+	})
+
+	return size
+}
+
+// hasFile checks if managed index contains a given regular file.
+func (s *Sync) hasFile(name string) (ok bool) {
+	s.idx.Tree().DoPath(name, func(_ node.Guard, n *node.Node) bool {
+		ok = !n.IsShadowed() && !n.Entry.File.Mode.IsDir()
+		return !n.IsShadowed()
+	})
+
+	return ok
 }
 
 // Drop closes synced mount and cleans up all resources acquired by it.
 func (s *Sync) Drop() error {
 	s.Close()
+
+	if h, ok := s.s.(*history.History); ok {
+		if err := h.Drop(); err != nil {
+			s.log.Warning("Cannot remove synchronization history: %s", err)
+		}
+	}
+
 	return os.RemoveAll(s.opts.WorkDir)
 }
 
@@ -352,7 +481,15 @@ func (s *Sync) Close() error {
 // not exist, it will be downloaded from remote machine and saved.
 func (s *Sync) loadIdx(path string) (*index.Index, error) {
 	f, err := os.Open(path)
-	if os.IsNotExist(err) {
+	if os.IsNotExist(err) && s.m.Reverse {
+		// Reverse mounts manage local directory.
+		idx, err := index.NewIndexFiles(s.m.Path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return idx, index.SaveIndex(idx, path)
+	} else if os.IsNotExist(err) {
 		// Downloads remote index.
 		spv := client.NewSupervised(s.opts.ClientFunc, 30*time.Second)
 		idx, err := spv.MountGetIndex(s.m.RemotePath)
@@ -370,8 +507,27 @@ func (s *Sync) loadIdx(path string) (*index.Index, error) {
 	return idx, json.NewDecoder(f).Decode(idx)
 }
 
+// putIdx sends local index to remote machine and queues files which remote
+// directory lacks.
+func (s *Sync) putIdx() error {
+	spv := client.NewSupervised(s.opts.ClientFunc, 30*time.Second)
+	res, err := spv.MountPutIndex(&reverse.IndexRequest{
+		Path:  s.m.RemotePath,
+		Index: s.idx,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range res.Needed {
+		s.a.Commit(index.NewChange(path, index.PriorityMedium, index.ChangeMetaAdd|index.ChangeMetaLocal))
+	}
+
+	return nil
+}
+
 func (s *Sync) indexSync() msync.IndexSyncFunc {
-	cacheDir := filepath.Join(s.opts.WorkDir, "data")
+	cacheDir := s.CacheDir()
 
 	return func(c *index.Change) {
 		s.idx.Sync(cacheDir, c)
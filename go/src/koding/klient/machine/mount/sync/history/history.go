@@ -6,35 +6,128 @@ import (
 	"sync"
 	"time"
 
+	"koding/klient/machine"
 	msync "koding/klient/machine/mount/sync"
+	"koding/klient/storage"
+
+	"github.com/koding/logging"
+)
+
+// Status describes the state of synchronization job when the record was
+// created.
+type Status string
+
+// Synchronization job statuses.
+const (
+	StatusReceived  Status = "received"  // job was received from event stream.
+	StatusStarted   Status = "started"   // job execution has started.
+	StatusSucceeded Status = "succeeded" // job was executed successfully.
+	StatusFailed    Status = "failed"    // job execution failed.
 )
 
 // Record stores a single history record.
 type Record struct {
+	ID        uint64    `json:"id"`               // sequential record number.
 	CreatedAt time.Time `json:"createdAt"`        // creation time.
 	Message   string    `json:"message"`          // short summary.
 	Details   string    `json:"detail,omitempty"` // detailed description.
+
+	Path     string        `json:"path,omitempty"`     // synchronized file path.
+	Status   Status        `json:"status,omitempty"`   // synchronization job status.
+	Size     int64         `json:"size,omitempty"`     // transferred bytes.
+	Duration time.Duration `json:"duration,omitempty"` // job execution time.
+	Error    string        `json:"error,omitempty"`    // failure reason.
+}
+
+// Options are the options used to configure History object.
+type Options struct {
+	// Size defines the maximum length of history records.
+	Size int
+
+	// Storage is used to persist history records. If nil, records are kept
+	// only in memory.
+	Storage storage.ValueInterface
+
+	// Key is a storage key under which records are saved.
+	Key string
+
+	// FlushInterval defines how often records are saved to storage. If zero,
+	// DefaultFlushInterval is used.
+	FlushInterval time.Duration
+
+	// SizeFunc returns the size of synchronized file. If nil, transferred
+	// bytes are not recorded.
+	SizeFunc func(path string) int64
+
+	// Log is used for logging. If nil, default logger will be created.
+	Log logging.Logger
 }
 
+// DefaultFlushInterval defines how often history records are saved when
+// persistent storage is used.
+const DefaultFlushInterval = 5 * time.Second
+
 // History gathers synchronization history of results produced by stored Syncer.
 type History struct {
-	s msync.Syncer // underlying Syncer.
+	s    msync.Syncer // underlying Syncer.
+	opts Options
+	log  logging.Logger
 
-	mu sync.Mutex
-	r  *ring.Ring
+	mu    sync.Mutex
+	r     *ring.Ring
+	seq   uint64 // ID of the last added record.
+	dirty bool   // set when records were not saved yet.
 
 	once  sync.Once
+	wg    sync.WaitGroup
 	stopC chan struct{} // channel used to close any opened exec streams.
 }
 
 // NewHistory creates a new History instance. Provided size defines the maximum
 // length of history records.
 func NewHistory(s msync.Syncer, size int) *History {
-	return &History{
+	h, _ := New(s, &Options{Size: size})
+	return h
+}
+
+// New creates a new History instance from the given options. If storage is
+// provided, records saved there are restored.
+func New(s msync.Syncer, opts *Options) (*History, error) {
+	h := &History{
 		s:     s,
-		r:     ring.New(size),
+		opts:  *opts,
+		log:   opts.Log,
+		r:     ring.New(opts.Size),
 		stopC: make(chan struct{}),
 	}
+
+	if h.log == nil {
+		h.log = machine.DefaultLogger.New("history")
+	}
+
+	if h.opts.FlushInterval == 0 {
+		h.opts.FlushInterval = DefaultFlushInterval
+	}
+
+	if h.opts.Storage == nil {
+		return h, nil
+	}
+
+	var recs []*Record
+	if err := h.opts.Storage.GetValue(h.opts.Key, &recs); err != nil && err != storage.ErrKeyNotFound {
+		return nil, err
+	}
+
+	for _, rec := range recs {
+		if rec != nil {
+			h.put(rec)
+		}
+	}
+
+	h.wg.Add(1)
+	go h.flusher()
+
+	return h, nil
 }
 
 // ExecStream wraps underlying execers with history gathering logic.
@@ -53,8 +146,9 @@ func (h *History) ExecStream(evC <-chan *msync.Event) <-chan msync.Execer {
 				}
 
 				h.add(&Record{
-					CreatedAt: time.Now().UTC(),
-					Message:   "received: " + ex.String(),
+					Message: "received: " + ex.String(),
+					Path:    execPath(ex),
+					Status:  StatusReceived,
 				})
 
 				exh := &histExec{
@@ -76,13 +170,35 @@ func (h *History) ExecStream(evC <-chan *msync.Event) <-chan msync.Execer {
 	return exhC
 }
 
-// Close stops all created synchronization streams.
+// Close stops all created synchronization streams and saves history records.
 func (h *History) Close() error {
 	h.once.Do(func() {
 		close(h.stopC)
 	})
 
-	return nil
+	h.wg.Wait()
+	return h.flush()
+}
+
+// Drop closes the history and removes its records from storage.
+func (h *History) Drop() error {
+	h.once.Do(func() {
+		close(h.stopC)
+	})
+
+	h.wg.Wait()
+
+	if h.opts.Storage == nil {
+		return nil
+	}
+
+	if d, ok := h.opts.Storage.(interface {
+		Delete(string) error
+	}); ok {
+		return d.Delete(h.opts.Key)
+	}
+
+	return h.opts.Storage.SetValue(h.opts.Key, []*Record{})
 }
 
 // Get gets recorded history. Returned slice length will not exceed history
@@ -112,10 +228,75 @@ func (h *History) Get() []*Record {
 
 // add adds new record to history.
 func (h *History) add(rec *Record) {
+	rec.CreatedAt = time.Now().UTC()
+
 	h.mu.Lock()
+	rec.ID = h.seq + 1
+	h.put(rec)
+	h.dirty = true
+	h.mu.Unlock()
+}
+
+// put stores provided record in the ring.
+func (h *History) put(rec *Record) {
 	h.r.Value = rec
 	h.r = h.r.Next()
+	if rec.ID > h.seq {
+		h.seq = rec.ID
+	}
+}
+
+// flusher periodically saves history records to storage.
+func (h *History) flusher() {
+	defer h.wg.Done()
+
+	t := time.NewTicker(h.opts.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := h.flush(); err != nil {
+				h.log.Warning("Cannot save synchronization history: %s", err)
+			}
+		case <-h.stopC:
+			return
+		}
+	}
+}
+
+// flush saves history records to storage if they changed.
+func (h *History) flush() error {
+	if h.opts.Storage == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	dirty := h.dirty
+	h.dirty = false
 	h.mu.Unlock()
+
+	if !dirty {
+		return nil
+	}
+
+	if err := h.opts.Storage.SetValue(h.opts.Key, h.Get()); err != nil {
+		h.mu.Lock()
+		h.dirty = true
+		h.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// size returns the number of bytes transferred by synchronizing a given file.
+func (h *History) size(path string) int64 {
+	if h.opts.SizeFunc == nil || path == "" {
+		return 0
+	}
+
+	return h.opts.SizeFunc(path)
 }
 
 // histExec wraps Execer interface in order to track its invocation status.
@@ -131,23 +312,33 @@ func (he *histExec) Event() *msync.Event {
 
 // Exec starts synchronization of stored syncing job.
 func (he *histExec) Exec() (err error) {
+	p := execPath(he.ex)
 	he.parent.add(&Record{
-		CreatedAt: time.Now().UTC(),
-		Message:   "started: " + he.ex.String(),
+		Message: "started: " + he.ex.String(),
+		Path:    p,
+		Status:  StatusStarted,
 	})
 
-	var msg string
-	if err = he.ex.Exec(); err != nil {
-		msg = "failed: " + he.ex.String() + "; err: " + err.Error()
+	start := time.Now()
+	err = he.ex.Exec()
+
+	rec := &Record{
+		Details:  he.ex.Debug(),
+		Path:     p,
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		rec.Message = "failed: " + he.ex.String() + "; err: " + err.Error()
+		rec.Status = StatusFailed
+		rec.Error = err.Error()
 	} else {
-		msg = "succeeded: " + he.ex.String()
+		rec.Message = "succeeded: " + he.ex.String()
+		rec.Status = StatusSucceeded
+		rec.Size = he.parent.size(p)
 	}
 
-	he.parent.add(&Record{
-		CreatedAt: time.Now().UTC(),
-		Message:   msg,
-		Details:   he.ex.Debug(),
-	})
+	he.parent.add(rec)
 
 	return
 }
@@ -161,3 +352,12 @@ func (he *histExec) Debug() string {
 func (he *histExec) String() string {
 	return he.ex.String()
 }
+
+// execPath gets the path of file synchronized by a given execer.
+func execPath(ex msync.Execer) string {
+	if ev := ex.Event(); ev != nil && ev.Change() != nil {
+		return ev.Change().Path()
+	}
+
+	return ""
+}
@@ -2,6 +2,7 @@ package history_test
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"koding/klient/machine/mount/sync/discard"
 	"koding/klient/machine/mount/sync/history"
 	"koding/klient/machine/mount/sync/synctest"
+	"koding/klient/storage"
 )
 
 func TestHistory(t *testing.T) {
@@ -64,6 +66,112 @@ func TestHistory(t *testing.T) {
 	}
 }
 
+func TestHistoryPersistent(t *testing.T) {
+	const key = "history_test"
+
+	opts := &history.Options{
+		Size:          10,
+		Storage:       &storage.EncodingStorage{Interface: storage.NewMemoryStorage()},
+		Key:           key,
+		FlushInterval: time.Hour,
+		SizeFunc:      func(path string) int64 { return int64(len(path)) },
+	}
+
+	h, err := history.New(discard.NewDiscard(), opts)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	for _, path := range []string{"a/b.txt", "a/c.txt", "d.txt"} {
+		if err := synctest.ExecChange(h, index.NewChange(path, index.PriorityLow, 0), time.Second); err != nil {
+			t.Fatalf("want err = nil; got %v", err)
+		}
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	// Records should be restored from storage.
+	if h, err = history.New(discard.NewDiscard(), opts); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer h.Close()
+
+	recs := h.Get()
+	if len(recs) != 9 {
+		t.Fatalf("want recs len = 9; got %d", len(recs))
+	}
+
+	for i, rec := range recs {
+		if rec.ID != uint64(i+1) {
+			t.Fatalf("want rec ID = %d; got %d", i+1, rec.ID)
+		}
+	}
+
+	tests := map[string]struct {
+		Filter *history.Filter
+		Paths  []string
+	}{
+		"directory": {
+			Filter: &history.Filter{Path: "a", Status: history.StatusSucceeded},
+			Paths:  []string{"a/b.txt", "a/c.txt"},
+		},
+		"pattern": {
+			Filter: &history.Filter{Path: "*.txt", Status: history.StatusStarted},
+			Paths:  []string{"d.txt"},
+		},
+		"after": {
+			Filter: &history.Filter{After: 6},
+			Paths:  []string{"d.txt", "d.txt", "d.txt"},
+		},
+		"limit": {
+			Filter: &history.Filter{Status: history.StatusSucceeded, Limit: 1},
+			Paths:  []string{"d.txt"},
+		},
+		"until": {
+			Filter: &history.Filter{Until: recs[0].CreatedAt.Add(-time.Second)},
+			Paths:  nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test // Capture range variable.
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			for _, rec := range h.Query(test.Filter) {
+				paths = append(paths, rec.Path)
+			}
+
+			if !reflect.DeepEqual(paths, test.Paths) {
+				t.Fatalf("want paths = %v; got %v", test.Paths, paths)
+			}
+		})
+	}
+
+	stats := h.Stats(nil)
+	if stats.Succeeded != 3 || stats.Failed != 0 {
+		t.Fatalf("want 3 succeeded and 0 failed jobs; got %d and %d", stats.Succeeded, stats.Failed)
+	}
+	if want := int64(len("a/b.txt") + len("a/c.txt") + len("d.txt")); stats.Bytes != want {
+		t.Fatalf("want bytes = %d; got %d", want, stats.Bytes)
+	}
+
+	// Dropped history should not be restored.
+	if err := h.Drop(); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	if h, err = history.New(discard.NewDiscard(), opts); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer h.Close()
+
+	if recs := h.Get(); len(recs) != 0 {
+		t.Fatalf("want recs len = 0; got %d", len(recs))
+	}
+}
+
 // timeBetween returns error when provided time stamp is not between tLeft and
 // tRight. tRight must be greater than tLeft.
 func timeBetween(t, tLeft, tRight time.Time) error {
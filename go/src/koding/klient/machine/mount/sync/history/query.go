@@ -0,0 +1,117 @@
+package history
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Filter describes which history records should be returned. Zero value
+// fields are not used during filtering.
+type Filter struct {
+	// After limits records to the ones that were added after a record with
+	// a given ID. It allows to follow new records.
+	After uint64 `json:"after,omitempty"`
+
+	// Path limits records to files which path matches this value. It can be
+	// either a path.Match pattern or a directory prefix.
+	Path string `json:"path,omitempty"`
+
+	// Status limits records to the ones with a given status.
+	Status Status `json:"status,omitempty"`
+
+	// Since and Until limit records to a given time range.
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+
+	// Limit defines the maximum number of the most recent records returned.
+	Limit int `json:"limit,omitempty"`
+}
+
+// Match checks if provided record satisfies the filter.
+func (f *Filter) Match(rec *Record) bool {
+	switch {
+	case f == nil:
+		return true
+	case rec.ID <= f.After:
+		return false
+	case f.Status != "" && rec.Status != f.Status:
+		return false
+	case !f.Since.IsZero() && rec.CreatedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && rec.CreatedAt.After(f.Until):
+		return false
+	case f.Path != "" && !matchPath(f.Path, rec.Path):
+		return false
+	}
+
+	return true
+}
+
+// Query gets history records which match provided filter. If filter is nil,
+// all records are returned.
+func (h *History) Query(f *Filter) []*Record {
+	var recs []*Record
+	for _, rec := range h.Get() {
+		if f.Match(rec) {
+			recs = append(recs, rec)
+		}
+	}
+
+	if f != nil && f.Limit > 0 && len(recs) > f.Limit {
+		recs = recs[len(recs)-f.Limit:]
+	}
+
+	return recs
+}
+
+// Stats contains aggregated statistics of synchronization jobs.
+type Stats struct {
+	Succeeded  int           `json:"succeeded"`  // number of successful jobs.
+	Failed     int           `json:"failed"`     // number of failed jobs.
+	Bytes      int64         `json:"bytes"`      // total size of synchronized files.
+	AvgLatency time.Duration `json:"avgLatency"` // average job execution time.
+}
+
+// Stats computes synchronization statistics from finished jobs stored in
+// records that match provided filter.
+func (h *History) Stats(f *Filter) *Stats {
+	return NewStats(h.Query(f))
+}
+
+// NewStats computes synchronization statistics from provided records.
+func NewStats(recs []*Record) *Stats {
+	var (
+		stats = &Stats{}
+		total time.Duration
+	)
+
+	for _, rec := range recs {
+		switch rec.Status {
+		case StatusSucceeded:
+			stats.Succeeded++
+			stats.Bytes += rec.Size
+		case StatusFailed:
+			stats.Failed++
+		default:
+			continue
+		}
+
+		total += rec.Duration
+	}
+
+	if n := stats.Succeeded + stats.Failed; n != 0 {
+		stats.AvgLatency = total / time.Duration(n)
+	}
+
+	return stats
+}
+
+func matchPath(pattern, file string) bool {
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+
+	dir := strings.TrimSuffix(pattern, "/")
+	return file == dir || strings.HasPrefix(file, dir+"/")
+}
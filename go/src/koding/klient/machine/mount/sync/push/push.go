@@ -0,0 +1,190 @@
+// Package push implements a syncer used by reverse mounts. It sends content
+// of locally changed files to remote klient, which materializes them in
+// remote directory.
+package push
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"koding/klient/machine/client"
+	"koding/klient/machine/index"
+	"koding/klient/machine/mount/reverse"
+	msync "koding/klient/machine/mount/sync"
+)
+
+// MaxFileSize is the maximum size of a file that can be pushed to remote
+// machine.
+const MaxFileSize = 64 * 1024 * 1024
+
+// Builder is a factory for push synchronization objects.
+type Builder struct{}
+
+// Build satisfies msync.Builder interface. It produces Push objects from
+// a given options.
+func (Builder) Build(opts *msync.BuildOpts) (msync.Syncer, error) {
+	return NewPush(opts), nil
+}
+
+// Event is a push synchronization object that sends local file changes to
+// remote machine.
+type Event struct {
+	ev     *msync.Event
+	parent *Push
+}
+
+// Event returns base event which is going to be synchronized.
+func (e *Event) Event() *msync.Event {
+	return e.ev
+}
+
+// Exec satisfies msync.Execer interface. It sends the current state of
+// changed local file to remote machine.
+func (e *Event) Exec() error {
+	defer e.ev.Done()
+	if !e.ev.Valid() {
+		return nil
+	}
+
+	change := e.ev.Change()
+
+	// Reverse mounts are one way only, changes made on remote machine are
+	// not synchronized.
+	if change.Meta()&index.ChangeMetaLocal == 0 {
+		return nil
+	}
+
+	req, err := e.parent.request(change.Path())
+	if err != nil {
+		return err
+	}
+
+	if err := e.parent.c.MountApply(req); err != nil {
+		return err
+	}
+
+	e.parent.indexSync(change)
+
+	return nil
+}
+
+// String implements fmt.Stringer interface. It pretty prints internal event.
+func (e *Event) String() string {
+	return e.ev.String() + " - " + "push"
+}
+
+// Debug returns debug information about the event.
+func (e *Event) Debug() string {
+	return e.ev.String()
+}
+
+// Push sends local files to remote machine.
+type Push struct {
+	remote string // remote directory root.
+	local  string // local directory root.
+
+	c         client.Client       // client used to send changes.
+	indexSync msync.IndexSyncFunc // callback used to update index.
+
+	once  sync.Once
+	stopC chan struct{} // channel used to close any opened exec streams.
+}
+
+// NewPush creates a new Push object from given options.
+func NewPush(opts *msync.BuildOpts) *Push {
+	return &Push{
+		remote:    opts.RemoteDir,
+		local:     opts.CacheDir,
+		c:         client.NewSupervised(opts.ClientFunc, 30*time.Second),
+		indexSync: opts.IndexSyncFunc,
+		stopC:     make(chan struct{}),
+	}
+}
+
+// request creates apply request that describes the current state of local
+// file.
+func (p *Push) request(path string) (*reverse.ApplyRequest, error) {
+	req := &reverse.ApplyRequest{
+		Path: p.remote,
+		Name: path,
+	}
+
+	name := filepath.Join(p.local, filepath.FromSlash(path))
+
+	info, err := os.Lstat(name)
+	if os.IsNotExist(err) {
+		req.Remove = true
+		return req, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req.Mode = info.Mode()
+	req.MTime = info.ModTime().UnixNano()
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(name)
+		if err != nil {
+			return nil, err
+		}
+		req.Content = []byte(target)
+	case info.Mode().IsRegular():
+		if info.Size() > MaxFileSize {
+			return nil, fmt.Errorf("file %s is too large to be pushed: %d bytes", path, info.Size())
+		}
+
+		if req.Content, err = ioutil.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// ExecStream wraps incoming msync events with Push event logic that is
+// responsible for sending changes and ensuring final index state.
+func (p *Push) ExecStream(evC <-chan *msync.Event) <-chan msync.Execer {
+	exC := make(chan msync.Execer)
+
+	go func() {
+		defer close(exC)
+		for {
+			select {
+			case ev, ok := <-evC:
+				if !ok {
+					return
+				}
+
+				ex := &Event{
+					ev:     ev,
+					parent: p,
+				}
+				select {
+				case exC <- ex:
+				case <-p.stopC:
+					ex.ev.Done()
+					return
+				}
+			case <-p.stopC:
+				return
+			}
+		}
+	}()
+
+	return exC
+}
+
+// Close stops all created synchronization streams.
+func (p *Push) Close() error {
+	p.once.Do(func() {
+		close(p.stopC)
+	})
+
+	return nil
+}
@@ -73,6 +73,10 @@ type Command struct {
 	// Output specifies an optional writer which, if set, will receive rsync
 	// command output.
 	Output io.Writer `json:"-"`
+
+	// FilesFrom if set, defines a path to the file which contains a list of
+	// files to transfer. Listed paths must be relative to source path.
+	FilesFrom string `json:"filesFrom,omitempty"`
 }
 
 // valid checks if command fields are valid.
@@ -184,6 +188,11 @@ func (c *Command) run(ctx context.Context, scan func(r io.Reader)) error {
 		c.Cmd.Args = append(c.Cmd.Args, "--include='/"+filepath.Base(c.SourcePath)+"'", "--exclude='*'")
 	}
 
+	// Transfer only listed files.
+	if c.FilesFrom != "" {
+		c.Cmd.Args = append(c.Cmd.Args, "--files-from="+c.FilesFrom)
+	}
+
 	// Progress logic needs verbose mode with itemized changes.
 	if c.Progress != nil {
 		c.Cmd.Args = append(c.Cmd.Args, "-Piv")
@@ -93,6 +93,29 @@ func TestRsyncArgs(t *testing.T) {
 	}
 }
 
+func TestRsyncFilesFrom(t *testing.T) {
+	var buf = &bytes.Buffer{}
+	cmd := &rsync.Command{
+		Cmd:             dumpArgs(),
+		Download:        true,
+		SourcePath:      "/A/",
+		DestinationPath: "/B/",
+		Username:        "usr",
+		Host:            "host",
+		FilesFrom:       "/tmp/files",
+		Output:          buf,
+	}
+
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	want := []string{"-zlptgoDd", "--files-from=/tmp/files", "usr@host:/A/", "/B/"}
+	if got := strings.Split(buf.String(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want exec args = %v; got %v", want, got)
+	}
+}
+
 func TestRsyncProgress(t *testing.T) {
 	files, err := ioutil.ReadDir(dataDir)
 	if err != nil {
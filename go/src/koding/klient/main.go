@@ -61,7 +61,9 @@ var (
 	// Upload log flags
 	flagLogBucketRegion   = f.String("log-bucket-region", "", "Change bucket region to upload logs")
 	flagLogBucketName     = f.String("log-bucket-name", "", "Change bucket name to upload logs")
+	flagLogBucketType     = f.String("log-bucket-type", "", "Change keygen auth type used to upload logs (s3, gcs or minio)")
 	flagLogUploadInterval = f.Duration("log-upload-interval", 90*time.Minute, "Change interval of upload logs")
+	flagLogShipConfig     = f.String("log-ship-config", "", "Configuration file of log files to ship")
 
 	// Metadata flags.
 	flagMetadata     = f.String("metadata", "", "Base64-encoded Koding metadata")
@@ -146,7 +148,9 @@ func realMain() int {
 		Autoupdate:        *flagAutoupdate,
 		LogBucketRegion:   *flagLogBucketRegion,
 		LogBucketName:     *flagLogBucketName,
+		LogBucketType:     *flagLogBucketType,
 		LogUploadInterval: *flagLogUploadInterval,
+		LogShipConfig:     *flagLogShipConfig,
 		Metadata:          *flagMetadata,
 		MetadataFile:      *flagMetadataFile,
 	}
@@ -0,0 +1,455 @@
+package sshkeys
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/koding/kite"
+	"golang.org/x/crypto/ssh"
+)
+
+// Paths used when klient is allowed to configure sshd. They are variables, so
+// they can be changed in tests or for non-standard sshd installations.
+var (
+	SSHDConfigFile = "/etc/ssh/sshd_config"
+	SSHDPidFile    = "/var/run/sshd.pid"
+	TrustedCAFile  = "/etc/ssh/koding_user_ca.pub"
+	PrincipalsDir  = "/etc/ssh/koding_principals"
+)
+
+// caComment is appended to certificate authority lines written to
+// authorized_keys file.
+const caComment = "koding-ca"
+
+// TrustCAOptions is the option struct for the TrustCA method of klient.
+type TrustCAOptions struct {
+	Username  string `json:"username"`
+	Key       string `json:"key"`
+	Principal string `json:"principal"`
+}
+
+// TrustCA configures remote machine to accept user certificates signed by the
+// given certificate authority. Certificates are accepted only for the given
+// principal, which must belong to the caller, see sshcert.Principal.
+func TrustCA(r *kite.Request) (interface{}, error) {
+	var opts TrustCAOptions
+
+	if err := r.Args.One().Unmarshal(&opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Key == "" {
+		return nil, errors.New("certificate authority key is empty")
+	}
+
+	if !strings.HasPrefix(opts.Principal, r.Username+"@") {
+		return nil, fmt.Errorf("certificate principal %q does not belong to %q", opts.Principal, r.Username)
+	}
+
+	username := opts.Username
+	if username == "" {
+		username = r.Username
+	}
+
+	if err := TrustUserCA(username, opts.Principal, opts.Key); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+// TrustUserCA allows principal to log in as user with certificates signed by
+// the caKey certificate authority.
+//
+// When klient is able to modify sshd configuration, the authority is added to
+// sshd TrustedUserCAKeys and the principal is written to user's principals
+// file. Otherwise a cert-authority line is added to user's authorized_keys
+// file. In both cases the change is done once per authority and principal,
+// so keys do not accumulate over time.
+func TrustUserCA(user, principal, caKey string) error {
+	if principal == "" || strings.ContainsAny(principal, "\",\n ") {
+		return fmt.Errorf("invalid certificate principal %q", principal)
+	}
+
+	if err := validUser(user); err != nil {
+		return err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(caKey))
+	if err != nil {
+		return fmt.Errorf("invalid certificate authority key: %v", err)
+	}
+
+	key := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if canConfigureSSHD() {
+		err := trustSSHD(user, principal, key)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("unable to configure sshd, falling back to authorized_keys: %s", err)
+	}
+
+	return trustAuthorisedKeys(user, principal, key)
+}
+
+// RevokePrincipals removes all certificate principals of the given Koding
+// user from sshd principals files and from cert-authority lines of
+// authorized_keys files, so the user can no longer log in to this machine
+// with certificates issued before.
+func RevokePrincipals(username string) error {
+	if username == "" {
+		return errors.New("username is empty")
+	}
+
+	prefix := username + "@"
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	users := make(map[string]struct{})
+	if u, err := user.Current(); err == nil {
+		users[u.Username] = struct{}{}
+	}
+
+	fis, err := ioutil.ReadDir(PrincipalsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+
+		if err := removeLines(filepath.Join(PrincipalsDir, fi.Name()), prefix); err != nil {
+			return err
+		}
+
+		users[fi.Name()] = struct{}{}
+	}
+
+	for user := range users {
+		if validUser(user) != nil {
+			continue
+		}
+
+		if err := revokeAuthorisedKeys(user, prefix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validUser ensures the given name is an existing system user that can be
+// safely used as a file name in PrincipalsDir.
+func validUser(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid username %q", name)
+	}
+
+	if _, err := user.Lookup(name); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func canConfigureSSHD() bool {
+	if os.Geteuid() != 0 {
+		return false
+	}
+
+	_, err := os.Stat(SSHDConfigFile)
+	return err == nil
+}
+
+func trustSSHD(user, principal, key string) error {
+	changed, err := ensureSSHDConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := addLine(TrustedCAFile, key); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(PrincipalsDir, 0755); err != nil {
+		return err
+	}
+
+	if err := addLine(filepath.Join(PrincipalsDir, user), principal); err != nil {
+		return err
+	}
+
+	if changed {
+		return reloadSSHD()
+	}
+
+	return nil
+}
+
+// ensureSSHDConfig adds TrustedUserCAKeys and AuthorizedPrincipalsFile
+// directives to sshd configuration. It fails if any of them is already
+// configured differently, as sshd uses only the first occurrence.
+func ensureSSHDConfig() (changed bool, err error) {
+	p, err := ioutil.ReadFile(SSHDConfigFile)
+	if err != nil {
+		return false, err
+	}
+
+	directives := []struct {
+		name, value string
+		found       bool
+	}{
+		{name: "TrustedUserCAKeys", value: TrustedCAFile},
+		{name: "AuthorizedPrincipalsFile", value: filepath.Join(PrincipalsDir, "%u")},
+	}
+
+	var lines []string
+	match := -1 // index of the first Match block
+
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+
+		if len(fields) != 0 && match == -1 {
+			if strings.EqualFold(fields[0], "Match") {
+				match = len(lines)
+			}
+
+			for i := range directives {
+				d := &directives[i]
+
+				if !strings.EqualFold(fields[0], d.name) {
+					continue
+				}
+
+				if len(fields) != 2 || fields[1] != d.value {
+					return false, fmt.Errorf("%s is already set to %q", d.name, strings.Join(fields[1:], " "))
+				}
+
+				d.found = true
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	// Global directives must be placed before any Match block.
+	if match == -1 {
+		match = len(lines)
+	}
+
+	var added []string
+	for _, d := range directives {
+		if !d.found {
+			added = append(added, d.name+" "+d.value)
+		}
+	}
+
+	if len(added) == 0 {
+		return false, nil
+	}
+
+	added = append([]string{"# Added by klient to allow Koding SSH certificates."}, added...)
+	lines = append(lines[:match], append(added, lines[match:]...)...)
+
+	info, err := os.Stat(SSHDConfigFile)
+	if err != nil {
+		return false, err
+	}
+
+	if err := AtomicWriteFile(SSHDConfigFile, []byte(strings.Join(lines, "\n")+"\n"), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// reloadSSHD makes sshd reread its configuration.
+func reloadSSHD() error {
+	p, err := ioutil.ReadFile(SSHDPidFile)
+	if err != nil {
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(p)))
+	if err != nil {
+		return fmt.Errorf("invalid sshd pid file: %v", err)
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	return proc.Signal(syscall.SIGHUP)
+}
+
+// addLine adds a line to the given file unless it already exists.
+func addLine(file, line string) error {
+	p, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, l := range strings.Split(string(p), "\n") {
+		if strings.TrimSpace(l) == line {
+			return nil
+		}
+	}
+
+	if len(p) != 0 && p[len(p)-1] != '\n' {
+		p = append(p, '\n')
+	}
+
+	return AtomicWriteFile(file, append(p, line+"\n"...), 0644)
+}
+
+// removeLines removes lines starting with the given prefix from the file.
+func removeLines(file, prefix string) error {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, l := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(l), prefix) {
+			lines = append(lines, l)
+		}
+	}
+
+	q := []byte(strings.Join(lines, "\n") + "\n")
+	if len(lines) == 0 {
+		q = nil
+	}
+
+	if bytes.Equal(p, q) {
+		return nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	return AtomicWriteFile(file, q, info.Mode().Perm())
+}
+
+// trustAuthorisedKeys adds a cert-authority line to user's authorized_keys
+// file. If the authority is already trusted, the principal is added to its
+// principals list.
+func trustAuthorisedKeys(user, principal, key string) error {
+	keys, err := readAuthorisedKeys(user)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range keys {
+		principals, k, ok := parseCALine(line)
+		if !ok || k != key {
+			continue
+		}
+
+		for _, p := range principals {
+			if p == principal {
+				return nil
+			}
+		}
+
+		keys[i] = caLine(append(principals, principal), key)
+		return writeAuthorisedKeys(user, keys)
+	}
+
+	return writeAuthorisedKeys(user, append(keys, caLine([]string{principal}, key)))
+}
+
+// revokeAuthorisedKeys removes principals starting with the given prefix from
+// cert-authority lines of user's authorized_keys file. Lines left without
+// principals are removed.
+func revokeAuthorisedKeys(user, prefix string) error {
+	keys, err := readAuthorisedKeys(user)
+	if err != nil {
+		return err
+	}
+
+	var kept []string
+	changed := false
+
+	for _, line := range keys {
+		principals, key, ok := parseCALine(line)
+		if !ok {
+			kept = append(kept, line)
+			continue
+		}
+
+		var left []string
+		for _, p := range principals {
+			if !strings.HasPrefix(p, prefix) {
+				left = append(left, p)
+			}
+		}
+
+		if len(left) == len(principals) {
+			kept = append(kept, line)
+			continue
+		}
+
+		changed = true
+		if len(left) != 0 {
+			kept = append(kept, caLine(left, key))
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return writeAuthorisedKeys(user, kept)
+}
+
+// parseCALine gives the principals and the key of a cert-authority line.
+// The ok is false if the line is not a cert-authority one.
+func parseCALine(line string) (principals []string, key string, ok bool) {
+	pub, _, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return nil, "", false
+	}
+
+	for _, opt := range options {
+		switch {
+		case opt == "cert-authority":
+			ok = true
+		case strings.HasPrefix(opt, "principals="):
+			principals = strings.Split(strings.Trim(strings.TrimPrefix(opt, "principals="), `"`), ",")
+		}
+	}
+
+	return principals, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))), ok
+}
+
+func caLine(principals []string, key string) string {
+	return fmt.Sprintf("cert-authority,principals=%q %s %s", strings.Join(principals, ","), key, caComment)
+}
@@ -0,0 +1,192 @@
+package sshkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEnsureSSHDConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(config, ca, principals string) {
+		SSHDConfigFile, TrustedCAFile, PrincipalsDir = config, ca, principals
+	}(SSHDConfigFile, TrustedCAFile, PrincipalsDir)
+
+	SSHDConfigFile = filepath.Join(dir, "sshd_config")
+	TrustedCAFile = filepath.Join(dir, "ca.pub")
+	PrincipalsDir = filepath.Join(dir, "principals")
+
+	config := "Port 22\nPasswordAuthentication no\n\nMatch User guest\n\tForceCommand /bin/false\n"
+	if err := ioutil.WriteFile(SSHDConfigFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ensureSSHDConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !changed {
+		t.Fatal("expected sshd config to be changed")
+	}
+
+	p, err := ioutil.ReadFile(SSHDConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(p)
+	match := strings.Index(got, "Match User guest")
+	for _, directive := range []string{
+		"TrustedUserCAKeys " + TrustedCAFile,
+		"AuthorizedPrincipalsFile " + filepath.Join(PrincipalsDir, "%u"),
+	} {
+		i := strings.Index(got, directive)
+		if i == -1 {
+			t.Fatalf("%q not found in sshd config:\n%s", directive, got)
+		}
+
+		if i > match {
+			t.Fatalf("%q was added inside Match block:\n%s", directive, got)
+		}
+	}
+
+	// Configuration must not be changed twice.
+	if changed, err = ensureSSHDConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed {
+		t.Fatal("expected sshd config to be unchanged")
+	}
+
+	// Conflicting directives must not be overwritten.
+	config = "TrustedUserCAKeys /etc/ssh/other_ca.pub\n"
+	if err := ioutil.WriteFile(SSHDConfigFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ensureSSHDConfig(); err == nil {
+		t.Fatal("expected conflicting TrustedUserCAKeys to fail")
+	}
+}
+
+func TestAddLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "principals")
+
+	for _, line := range []string{"alice", "bob", "alice"} {
+		if err := addLine(file, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "alice\nbob\n"; string(p) != want {
+		t.Fatalf("got %q, want %q", p, want)
+	}
+}
+
+func TestCALine(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := caLine([]string{"alice", "bob"}, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))))
+
+	_, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if comment != caComment {
+		t.Fatalf("got %q, want %q", comment, caComment)
+	}
+
+	want := []string{"cert-authority", `principals="alice,bob"`}
+	if !reflect.DeepEqual(options, want) {
+		t.Fatalf("got %v, want %v", options, want)
+	}
+}
+
+func TestRevokePrincipals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(principals string) {
+		PrincipalsDir = principals
+	}(PrincipalsDir)
+
+	PrincipalsDir = dir
+
+	file := filepath.Join(PrincipalsDir, "nonexisting-user")
+	principals := "alice@machine1\nbob@machine1\nalice@machine2\nalicia@machine1\n"
+
+	if err := ioutil.WriteFile(file, []byte(principals), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokePrincipals("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "bob@machine1\nalicia@machine1\n"; string(p) != want {
+		t.Fatalf("got %q, want %q", p, want)
+	}
+}
+
+func TestTrustUserCAInvalidUser(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caKey := string(ssh.MarshalAuthorizedKey(pub))
+
+	for _, user := range []string{"", "..", "../x", "x/y", `x\y`, "nonexisting-user"} {
+		if err := TrustUserCA(user, "alice@machine1", caKey); err == nil {
+			t.Errorf("%q: expected error", user)
+		}
+	}
+}
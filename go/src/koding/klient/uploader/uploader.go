@@ -36,6 +36,7 @@ type Options struct {
 	Kite      *kite.Kite  // required
 	Bucket    string      // required
 	Region    string      // required
+	Type      string      // optional; keygen auth type, "s3" by default
 	DB        *bolt.DB    // optional; in-memory store if nil
 	Log       kite.Logger // optional; defaultLog if nil
 }
@@ -50,22 +51,11 @@ type Uploader struct {
 
 // New gives new uploader built from the given options.
 func New(cfg *Options) *Uploader {
-	log := defaultLog
-	if l, ok := cfg.Log.(logging.Logger); ok {
-		log = l
-	}
-
 	up := &Uploader{
 		cfg: cfg,
 		rotate: &logrotate.Uploader{
-			UserBucket: keygen.NewUserBucket(&keygen.Config{
-				ServerURL: cfg.KeygenURL,
-				Kite:      cfg.Kite,
-				Bucket:    cfg.Bucket,
-				Region:    cfg.Region,
-				Log:       log,
-			}),
-			MetaStore: storage.NewEncodingStorage(cfg.DB, []byte("uploader.metadata")),
+			UserBucket: NewBucket(cfg),
+			MetaStore:  storage.NewEncodingStorage(cfg.DB, []byte("uploader.metadata")),
 		},
 		req:   make(chan *request),
 		close: make(chan struct{}),
@@ -76,6 +66,31 @@ func New(cfg *Options) *Uploader {
 	return up
 }
 
+// NewBucket gives a keygen bucket built from the given options. The DB
+// field is ignored.
+func NewBucket(cfg *Options) logrotate.Bucket {
+	log := defaultLog
+	if l, ok := cfg.Log.(logging.Logger); ok {
+		log = l
+	}
+
+	keygenCfg := &keygen.Config{
+		ServerURL:    cfg.KeygenURL,
+		Kite:         cfg.Kite,
+		Bucket:       cfg.Bucket,
+		Region:       cfg.Region,
+		ProviderType: cfg.Type,
+		Log:          log,
+	}
+
+	if cfg.Type == "" || cfg.Type == "s3" {
+		return keygen.NewUserBucket(keygenCfg)
+	}
+
+	// Other auth types, like "gcs" or "minio", issue signed URLs.
+	return keygen.NewURLBucket(keygenCfg)
+}
+
 // UploadRequest represents a request of the "log.upload" kite method.
 type UploadRequest struct {
 	// File is a path to a file to upload.
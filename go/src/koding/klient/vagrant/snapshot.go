@@ -0,0 +1,223 @@
+package vagrant
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/koding/kite"
+	"github.com/koding/vagrantutil"
+)
+
+// SnapshotRequest represents a request value for "vagrant.package",
+// "vagrant.restore" and "vagrant.removeSnapshot" kite methods.
+type SnapshotRequest struct {
+	FilePath string `json:"filePath"`
+	Name     string `json:"name"`
+}
+
+func (req *SnapshotRequest) Valid() error {
+	if req.Name == "" {
+		return errors.New("snapshot name is empty")
+	}
+
+	if strings.ContainsAny(req.Name, `/\`) {
+		return errors.New("invalid snapshot name: " + req.Name)
+	}
+
+	return nil
+}
+
+var boxLine = regexp.MustCompile(`(?m)^(\s*config\.vm\.box\s*=\s*)".*"`)
+
+// step is a single action of a snapshot command, it writes its output
+// to the out channel.
+type step func(out chan<- *vagrantutil.CommandOutput) error
+
+func (h *Handlers) pack(r *kite.Request, v *vagrantutil.Vagrant) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	box := h.snapshotPath(v.VagrantfilePath, req.Name)
+
+	if err := os.MkdirAll(filepath.Dir(box), 0755); err != nil {
+		return nil, err
+	}
+
+	return h.watchCommand(r, v.VagrantfilePath, steps(
+		vagrantStep(v.VagrantfilePath, "package", "--output", box),
+	))
+}
+
+// Package creates a box snapshot of the given Vagrant box specified in the path.
+func (h *Handlers) Package(r *kite.Request) (interface{}, error) {
+	return h.withPath(r, h.pack)
+}
+
+func (h *Handlers) restore(r *kite.Request, v *vagrantutil.Vagrant) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	boxPath := h.snapshotPath(v.VagrantfilePath, req.Name)
+
+	if _, err := os.Stat(boxPath); err != nil {
+		return nil, err
+	}
+
+	box := snapshotBox(v.VagrantfilePath, req.Name)
+
+	up := []string{"up", "--no-provision"}
+	if v.ProviderName != "" {
+		up = append(up, "--provider", v.ProviderName)
+	}
+
+	return h.watchCommand(r, v.VagrantfilePath, steps(
+		vagrantStep(v.VagrantfilePath, "box", "add", "--force", "--name", box, boxPath),
+		func(chan<- *vagrantutil.CommandOutput) error {
+			return replaceBox(v.VagrantfilePath, box)
+		},
+		vagrantStep(v.VagrantfilePath, "destroy", "--force"),
+		vagrantStep(v.VagrantfilePath, up...),
+	))
+}
+
+// Restore recreates the given Vagrant box specified in the path from
+// its box snapshot.
+func (h *Handlers) Restore(r *kite.Request) (interface{}, error) {
+	return h.withPath(r, h.restore)
+}
+
+func (h *Handlers) removeSnapshot(r *kite.Request, v *vagrantutil.Vagrant) (interface{}, error) {
+	req, err := snapshotRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(h.snapshotPath(v.VagrantfilePath, req.Name)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The box is added only when the snapshot was restored, ignore the error.
+	exec.Command("vagrant", "box", "remove", "--force", snapshotBox(v.VagrantfilePath, req.Name)).Run()
+
+	return true, nil
+}
+
+// RemoveSnapshot removes the box snapshot of the given Vagrant box
+// specified in the path.
+func (h *Handlers) RemoveSnapshot(r *kite.Request) (interface{}, error) {
+	return h.withPath(r, h.removeSnapshot)
+}
+
+func (h *Handlers) snapshotPath(filePath, name string) string {
+	return filepath.Join(h.opts.Home, "snapshots", filepath.Base(filePath), name+".box")
+}
+
+func snapshotBox(filePath, name string) string {
+	return "koding-snapshot-" + filepath.Base(filePath) + "-" + name
+}
+
+func snapshotRequest(r *kite.Request) (*SnapshotRequest, error) {
+	var req SnapshotRequest
+
+	if err := r.Args.One().Unmarshal(&req); err != nil {
+		return nil, err
+	}
+
+	if err := req.Valid(); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// replaceBox rewrites Vagrantfile in the given directory to use
+// the given base box.
+func replaceBox(dir, box string) error {
+	path := filepath.Join(dir, "Vagrantfile")
+
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if !boxLine.Match(p) {
+		return errors.New("unable to find base box in " + path)
+	}
+
+	p = boxLine.ReplaceAll(p, []byte(`${1}"`+box+`"`))
+
+	return ioutil.WriteFile(path, p, 0644)
+}
+
+// steps gives a commandFunc, which executes the steps sequentially
+// until first failure.
+func steps(steps ...step) commandFunc {
+	return func() (<-chan *vagrantutil.CommandOutput, error) {
+		out := make(chan *vagrantutil.CommandOutput)
+
+		go func() {
+			defer close(out)
+
+			for _, step := range steps {
+				if err := step(out); err != nil {
+					out <- &vagrantutil.CommandOutput{Error: err}
+					return
+				}
+			}
+		}()
+
+		return out, nil
+	}
+}
+
+// vagrantStep gives a step, which executes vagrant command with the
+// given arguments.
+//
+// The vagrantutil package does not allow for running arbitrary
+// commands, thus this one.
+func vagrantStep(dir string, args ...string) step {
+	return func(out chan<- *vagrantutil.CommandOutput) error {
+		pr, pw := io.Pipe()
+
+		cmd := exec.Command("vagrant", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "VAGRANT_CHECKPOINT_DISABLE=1")
+		cmd.Stdout = pw
+		cmd.Stderr = pw
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		done := make(chan error, 1)
+
+		go func() {
+			err := cmd.Wait()
+			pw.Close()
+			done <- err
+		}()
+
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				out <- &vagrantutil.CommandOutput{Line: line}
+			}
+		}
+
+		// Drain the rest of the output, if any, so the command does not block.
+		io.Copy(ioutil.Discard, pr)
+
+		return <-done
+	}
+}
@@ -0,0 +1,45 @@
+package vagrant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceBox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vagrant")
+	if err != nil {
+		t.Fatalf("TempDir()=%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Vagrantfile")
+
+	vagrantfile, err := createTemplate(&VagrantCreateOptions{Box: "ubuntu/trusty64"})
+	if err != nil {
+		t.Fatalf("createTemplate()=%s", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(vagrantfile), 0644); err != nil {
+		t.Fatalf("WriteFile()=%s", err)
+	}
+
+	if err := replaceBox(dir, "koding-snapshot-box"); err != nil {
+		t.Fatalf("replaceBox()=%s", err)
+	}
+
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile()=%s", err)
+	}
+
+	if !strings.Contains(string(p), `config.vm.box = "koding-snapshot-box"`) {
+		t.Fatalf("box was not replaced:\n%s", p)
+	}
+
+	if strings.Contains(string(p), "ubuntu/trusty64") {
+		t.Fatalf("old box was not removed:\n%s", p)
+	}
+}
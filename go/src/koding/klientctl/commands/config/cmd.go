@@ -44,6 +44,8 @@ func NewCommand(c *cli.CLI) *cobra.Command {
 
 	// Subcommands.
 	cmd.AddCommand(
+		NewCreateCommand(c),
+		NewDiffCommand(c),
 		NewListCommand(c),
 		NewResetCommand(c),
 		NewSetCommand(c),
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	konfig "koding/kites/config"
+	"koding/kites/config/configstore"
+	"koding/klientctl/commands/cli"
+
+	"github.com/spf13/cobra"
+)
+
+type createOptions struct {
+	inherits string
+}
+
+// NewCreateCommand creates a command that creates new configuration profile.
+func NewCreateCommand(c *cli.CLI) *cobra.Command {
+	opts := &createOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create <profile>",
+		Short: "Create new configuration profile",
+		Long: `Creates new named configuration profile and switches to it.
+
+The profile inherits endpoints, mount and template configuration from the
+base one, which by default is the active configuration. Values changed with
+"kd config set" are stored in the profile and take precedence over inherited
+ones.`,
+		RunE: createCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.StringVar(&opts.inherits, "inherits", "", "base configuration ID or profile name")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.ExactArgs(1), // One argument is accepted.
+	)(c, cmd)
+
+	return cmd
+}
+
+func createCommand(c *cli.CLI, opts *createOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		if name == "" {
+			return errors.New("profile name is empty")
+		}
+
+		if _, ok := configstore.List()[name]; ok {
+			return fmt.Errorf("configuration %q already exists", name)
+		}
+
+		base := opts.inherits
+
+		if base == "" {
+			used, err := configstore.Used()
+			if err != nil {
+				return fmt.Errorf("unable to read active configuration: %v", err)
+			}
+
+			base = used.Key()
+		}
+
+		k := &konfig.Konfig{
+			Name:     name,
+			Inherits: base,
+		}
+
+		if err := configstore.Use(k); err != nil {
+			return fmt.Errorf("error creating profile: %v", err)
+		}
+
+		fmt.Fprintf(c.Out(), "Created %q profile, which inherits from %q, and switched to it.\n\nPlease run \"sudo kd restart\" for the new configuration to take effect.\n", name, base)
+
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	konfig "koding/kites/config"
+	"koding/kites/config/configstore"
+	"koding/klientctl/commands/cli"
+
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	jsonOutput bool
+}
+
+// NewDiffCommand creates a command that compares two configurations.
+func NewDiffCommand(c *cli.CLI) *cobra.Command {
+	opts := &diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff <config-id|profile> [config-id|profile]",
+		Short: "Compare configurations",
+		Long: `Compares two configurations after resolving values inherited by profiles
+and displays keys which differ.
+
+If the second configuration is not given, the active one is used.`,
+		RunE: diffCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.RangeArgs(1, 2), // One or two arguments are accepted.
+	)(c, cmd)
+
+	return cmd
+}
+
+// keyDiff describes a single configuration key, which
+// has different values in the compared configurations.
+type keyDiff struct {
+	Key string      `json:"key"`
+	A   interface{} `json:"a"`
+	B   interface{} `json:"b"`
+}
+
+func diffCommand(c *cli.CLI, opts *diffOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		konfigs := configstore.List()
+
+		a, err := konfigs.Resolve(args[0])
+		if err != nil {
+			return err
+		}
+
+		var b *konfig.Konfig
+
+		if len(args) == 2 {
+			if b, err = konfigs.Resolve(args[1]); err != nil {
+				return err
+			}
+		} else if b, err = configstore.Used(); err != nil {
+			return fmt.Errorf("unable to read active configuration: %v", err)
+		}
+
+		diff := diffKonfigs(a, b)
+
+		if opts.jsonOutput {
+			cli.PrintJSON(c.Out(), diff)
+			return nil
+		}
+
+		if len(diff) == 0 {
+			fmt.Fprintln(c.Out(), "Configurations are identical.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(c.Out(), 2, 0, 2, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprintf(w, "KEY\t%s\t%s\n", a.Key(), b.Key())
+
+		for _, d := range diff {
+			fmt.Fprintf(w, "%s\t%v\t%v\n", d.Key, orNone(d.A), orNone(d.B))
+		}
+
+		return nil
+	}
+}
+
+func diffKonfigs(lhs, rhs *konfig.Konfig) []*keyDiff {
+	ignored := append([]string{"name", "inherits"}, ignoredFields...)
+
+	objA := b.Build(lhs, ignored...)
+	objB := b.Build(rhs, ignored...)
+
+	keys := make(map[string]struct{}, len(objA)+len(objB))
+
+	for key := range objA {
+		keys[key] = struct{}{}
+	}
+
+	for key := range objB {
+		keys[key] = struct{}{}
+	}
+
+	var diff []*keyDiff
+
+	for key := range keys {
+		if fmt.Sprintf("%v", orNone(objA[key])) != fmt.Sprintf("%v", orNone(objB[key])) {
+			diff = append(diff, &keyDiff{
+				Key: key,
+				A:   objA[key],
+				B:   objB[key],
+			})
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Key < diff[j].Key })
+
+	return diff
+}
+
+func orNone(v interface{}) interface{} {
+	if s := fmt.Sprintf("%v", v); v == nil || s == "" || s == "0" {
+		return "-"
+	}
+	return v
+}
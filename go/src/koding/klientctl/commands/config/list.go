@@ -47,19 +47,32 @@ func listCommand(c *cli.CLI, opts *listOptions) cli.CobraFuncE {
 			return nil
 		}
 
-		printKonfigs(c, konfigs.Slice())
+		printKonfigs(c, konfigs)
 
 		return nil
 	}
 }
 
-func printKonfigs(c *cli.CLI, konfigs []*konfig.Konfig) {
+func printKonfigs(c *cli.CLI, konfigs konfig.Konfigs) {
 	w := tabwriter.NewWriter(c.Out(), 2, 0, 2, ' ', 0)
 	defer w.Flush()
 
-	fmt.Fprintln(w, "ID\tKODING URL")
+	fmt.Fprintln(w, "ID\tNAME\tINHERITS\tKODING URL")
 
-	for _, konfig := range konfigs {
-		fmt.Fprintf(w, "%s\t%s\n", konfig.ID(), konfig.KodingPublic())
+	for _, k := range konfigs.Slice() {
+		id, kodingURL := "-", "-"
+
+		if resolved, err := konfigs.Resolve(k.Key()); err == nil && resolved.KodingPublic() != nil {
+			id, kodingURL = resolved.ID(), resolved.KodingPublic().String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, orDash(k.Name), orDash(k.Inherits), kodingURL)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
@@ -16,9 +16,15 @@ func NewUseCommand(c *cli.CLI) *cobra.Command {
 	opts := &useOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "use <config-id>",
+		Use:   "use <config-id|profile>",
 		Short: "Change active configuration",
-		RunE:  useCommand(c, opts),
+		Long: `Changes active configuration to the one with the given ID or profile name.
+
+The active configuration can be overridden per directory tree with a .kd.yml
+file, which contains a name of the profile to use, e.g.:
+
+	profile: staging`,
+		RunE: useCommand(c, opts),
 	}
 
 	// Middlewares.
@@ -33,7 +39,9 @@ func useCommand(c *cli.CLI, opts *useOptions) cli.CobraFuncE {
 	return func(cmd *cobra.Command, args []string) error {
 		arg := args[0]
 
-		k, ok := configstore.List()[arg]
+		konfigs := configstore.List()
+
+		k, ok := konfigs[arg]
 		if !ok {
 			fmt.Fprintf(c.Err(), "Configuration %q was not found. Please use \"kd config list"+
 				"\" to list available configurations.\n", arg)
@@ -44,7 +52,16 @@ func useCommand(c *cli.CLI, opts *useOptions) cli.CobraFuncE {
 			return fmt.Errorf("error switching configuration: %v", err)
 		}
 
-		fmt.Fprintf(c.Out(), "Switched to %s.\n\nPlease run \"sudo kd restart\" for the new configuration to take effect.\n", k.KodingPublic())
+		resolved, err := konfigs.Resolve(arg)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.Out(), "Switched to %s.\n\nPlease run \"sudo kd restart\" for the new configuration to take effect.\n", resolved.KodingPublic())
+
+		if file, p, err := configstore.DefaultClient.Profile(); err == nil && p.Profile != arg {
+			fmt.Fprintf(c.Err(), "\nNote: %q profile set by %s is still used within this directory.\n", p.Profile, file)
+		}
 
 		return nil
 	}
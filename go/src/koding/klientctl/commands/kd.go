@@ -14,6 +14,7 @@ import (
 	"koding/klientctl/commands/machine/mount"
 	"koding/klientctl/commands/machine/mount/sync"
 	"koding/klientctl/commands/metrics"
+	"koding/klientctl/commands/offline"
 	"koding/klientctl/commands/open"
 	"koding/klientctl/commands/stack"
 	"koding/klientctl/commands/status"
@@ -57,6 +58,7 @@ func NewKdCommand(c *cli.CLI) *cobra.Command {
 		cli.Alias(machine.NewUmountCommand(c), "kd machine"),
 		metrics.NewCommand(c),
 		cli.Alias(mount.NewCommand(c), "kd machine"),
+		offline.NewCommand(c),
 		open.NewCommand(c),
 		stack.NewCommand(c),
 		status.NewCommand(c),
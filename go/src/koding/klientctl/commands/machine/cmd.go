@@ -4,6 +4,7 @@ import (
 	"koding/klientctl/commands/cli"
 	"koding/klientctl/commands/machine/config"
 	"koding/klientctl/commands/machine/mount"
+	"koding/klientctl/commands/machine/snapshot"
 
 	"github.com/spf13/cobra"
 )
@@ -21,9 +22,11 @@ func NewCommand(c *cli.CLI) *cobra.Command {
 		config.NewCommand(c),
 		NewCpCommand(c),
 		NewExecCommand(c),
+		NewGrepCommand(c),
 		NewListCommand(c),
 		NewIdentifiersCommand(c),
 		mount.NewCommand(c),
+		snapshot.NewCommand(c),
 		NewSSHCommand(c),
 		NewStartCommand(c),
 		NewStopCommand(c),
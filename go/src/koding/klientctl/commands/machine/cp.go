@@ -62,7 +62,7 @@ func cpCommand(c *cli.CLI, opts *cpOptions) cli.CobraFuncE {
 // cpAddress checks if provided identifiers are valid from the cp command
 // perspective. The identifiers should satisfy the following format:
 //
-//  [(ID|Alias|IP):]source_directory/path [(ID|Alias|IP):]remote_directory/path
+//  [[deployment:](ID|Alias|IP):]source_directory/path [[deployment:](ID|Alias|IP):]remote_directory/path
 //
 func cpAddress(idents []string) (download bool, ident, source, dest string, err error) {
 	if len(idents) != 2 {
@@ -70,24 +70,24 @@ func cpAddress(idents []string) (download bool, ident, source, dest string, err
 		return
 	}
 
-	srcs, dsts := strings.Split(idents[0], ":"), strings.Split(idents[1], ":")
-	switch srcl, dstl := len(srcs), len(dsts); {
-	case srcl == 1 && dstl == 1 || srcl >= 2 && dstl >= 2:
+	srcRemote, dstRemote := strings.ContainsRune(idents[0], ':'), strings.ContainsRune(idents[1], ':')
+	switch {
+	case srcRemote == dstRemote:
 		err = fmt.Errorf("invalid address format: %s %s", idents[0], idents[1])
 		return
-	case srcl == 2 && dstl == 1:
-		if dest, err = filepath.Abs(dsts[0]); err != nil {
-			err = fmt.Errorf("invalid format of local path %q: %s", dsts[0], err)
+	case srcRemote:
+		if dest, err = filepath.Abs(idents[1]); err != nil {
+			err = fmt.Errorf("invalid format of local path %q: %s", idents[1], err)
 			return
 		}
-		ident, source = srcs[0], srcs[1]
+		ident, source = machine.SplitRemote(idents[0])
 		download = true
-	case srcl == 1 && dstl == 2: // upload.
-		if source, err = filepath.Abs(srcs[0]); err != nil {
-			err = fmt.Errorf("invalid format of local path %q: %s", srcs[0], err)
+	default: // upload.
+		if source, err = filepath.Abs(idents[0]); err != nil {
+			err = fmt.Errorf("invalid format of local path %q: %s", idents[0], err)
 			return
 		}
-		ident, dest = dsts[0], dsts[1]
+		ident, dest = machine.SplitRemote(idents[1])
 	}
 
 	return
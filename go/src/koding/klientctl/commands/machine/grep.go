@@ -0,0 +1,129 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"koding/klient/fs"
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/ctlcli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+type grepOptions struct {
+	ignoreCase bool
+	fixed      bool
+	include    []string
+	exclude    []string
+	skipBinary bool
+	maxCount   int
+}
+
+// NewGrepCommand creates a command that searches file contents on remote
+// machine.
+func NewGrepCommand(c *cli.CLI) *cobra.Command {
+	opts := &grepOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "grep <pattern> (<local-mount-path> | <machine-identifier>:<remote-path>)",
+		Short: "Search files content on remote machine",
+		Long: `Search for lines matching <pattern> in files on a remote machine.
+
+The search is run on the remote side, so files do not need to be downloaded.
+If <local-mount-path> is provided, the remote machine and the remote path are
+looked up by reading the mount, and found files are printed as local paths.
+
+Each matching line is printed as <path>:<line>:<text>. The command exits with
+status 1 when no matches were found.`,
+		RunE: grepCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVarP(&opts.ignoreCase, "ignore-case", "i", false, "case insensitive matching")
+	flags.BoolVarP(&opts.fixed, "fixed-strings", "F", false, "treat pattern as a plain string")
+	flags.StringSliceVar(&opts.include, "include", nil, "search only files that match glob pattern")
+	flags.StringSliceVar(&opts.exclude, "exclude", nil, "skip files and directories that match glob pattern")
+	flags.BoolVarP(&opts.skipBinary, "skip-binary", "I", false, "ignore binary files")
+	flags.IntVarP(&opts.maxCount, "max-count", "m", 0, "stop after the given number of matches")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(2),   // Two arguments are required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func grepCommand(c *cli.CLI, opts *grepOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) (err error) {
+		done := make(chan *fs.SearchResult, 1)
+
+		searchOpts := &machine.SearchOptions{
+			Pattern:    args[0],
+			Literal:    opts.fixed,
+			IgnoreCase: opts.ignoreCase,
+			Include:    opts.include,
+			Exclude:    opts.exclude,
+			SkipBinary: opts.skipBinary,
+			MaxResults: opts.maxCount,
+			Match: func(m *fs.SearchMatch) {
+				if m.Binary {
+					fmt.Fprintf(c.Out(), "Binary file %s matches\n", m.Path)
+				} else {
+					fmt.Fprintf(c.Out(), "%s:%d:%s\n", m.Path, m.Line, m.Text)
+				}
+			},
+			Done: func(res *fs.SearchResult) {
+				done <- res
+				close(done)
+			},
+			AskList: cli.AskList(c, cmd),
+		}
+
+		if s := args[1]; strings.ContainsRune(s, ':') {
+			searchOpts.Identifier, searchOpts.Path = machine.SplitRemote(s)
+			if searchOpts.Path == "" {
+				return fmt.Errorf("invalid empty remote path: %q", s)
+			}
+		} else {
+			if searchOpts.Path, err = filepath.Abs(s); err != nil {
+				return err
+			}
+		}
+
+		cancel, err := machine.Search(searchOpts)
+		if err != nil {
+			return err
+		}
+
+		ctlcli.CloseOnExit(ctlcli.CloseFunc(func() error {
+			select {
+			case <-done:
+				return nil
+			default:
+				return cancel()
+			}
+		}))
+
+		res := <-done
+
+		switch {
+		case res.Err != "":
+			return errors.New(res.Err)
+		case res.Canceled:
+			return errors.New("search was canceled")
+		case res.Truncated:
+			fmt.Fprintf(c.Err(), "Search stopped after %d matches.\n", res.Count)
+		case res.Count == 0:
+			return cli.NewError(1, errors.New("no matches found"))
+		}
+
+		return nil
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"koding/klientctl/commands/cli"
+	"koding/klientctl/config"
 	"koding/klientctl/endpoint/machine"
 	"koding/klientctl/endpoint/team"
 
@@ -50,12 +51,13 @@ func listCommand(c *cli.CLI, opts *listOptions) cli.CobraFuncE {
 			return err
 		}
 
+		// Team filter applies only to machines of the used deployment.
 		if t := team.Used(); t.Valid() == nil {
-			all := infos
+			all, used := infos, config.Konfig.Deployment()
 			infos = infos[:0]
 
 			for _, i := range all {
-				if i.Team == t.Name {
+				if i.Team == t.Name || i.Deployment != used {
 					infos = append(infos, i)
 				}
 			}
@@ -76,8 +78,23 @@ func tabListFormatter(w io.Writer, infos []*machine.Info) {
 	now := time.Now()
 	tw := tabwriter.NewWriter(w, 2, 0, 2, ' ', 0)
 
+	// Show deployment column only when machines come from many deployments.
+	multi := false
+	for _, info := range infos {
+		if info.Deployment != infos[0].Deployment {
+			multi = true
+			break
+		}
+	}
+
+	if multi {
+		fmt.Fprintf(tw, "DEPLOYMENT\t")
+	}
 	fmt.Fprintf(tw, "ID\tLABEL\tOWNER\tTEAM\tSTACK\tPROVIDER\tAGE\tIP\tSTATUS\n")
 	for _, info := range infos {
+		if multi {
+			fmt.Fprintf(tw, "%s\t", dashIfEmpty(info.Deployment))
+		}
 		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			info.ID,
 			info.Label,
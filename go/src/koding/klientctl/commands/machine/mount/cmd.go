@@ -9,10 +9,14 @@ import (
 	msync "koding/klientctl/commands/machine/mount/sync"
 	"koding/klientctl/endpoint/machine"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
-type options struct{}
+type options struct {
+	prefetchBudget string
+	reverse        bool
+}
 
 // NewCommand creates a command that allows to create mounts and manage their
 // properties.
@@ -27,16 +31,26 @@ func NewCommand(c *cli.CLI) *cobra.Command {
 
 With <machine-identifier> argument, kd machine mount identifies requested machine.
 Either machine ID, machine alias or IP can be used as identifier and all of them
-can by obtained by running "kd machine list" command.
+can by obtained by running "kd machine list" command. Machines of other Koding
+deployments can be selected with <deployment>:<machine-identifier> form.
 
 <local-path> can be relative or absolute, if the folder does not exit, it will
-be created.`,
+be created.
+
+With --reverse flag, existing <local-path> is exposed on remote machine as
+<remote-path>. Local files are sent to remote machine whenever they change.`,
 		RunE: command(c, opts),
 	}
 
+	// Flags.
+	flags := cmd.Flags()
+	flags.StringVar(&opts.prefetchBudget, "prefetch-budget", "", "maximum size of prefetched files, eg. 512MiB")
+	flags.BoolVar(&opts.reverse, "reverse", false, "expose local directory on remote machine")
+
 	// Subcommands.
 	cmd.AddCommand(
 		NewInspectCommand(c),
+		NewLogCommand(c),
 		NewListCommand(c),
 		NewIdentifiersCommand(c),
 		msync.NewCommand(c),
@@ -58,14 +72,23 @@ func command(c *cli.CLI, opts *options) cli.CobraFuncE {
 			return err
 		}
 
-		opts := &machine.MountOptions{
-			Identifier: ident,
-			Path:       path,
-			RemotePath: remotePath,
-			AskList:    cli.AskList(c, cmd),
+		var budget uint64
+		if opts.prefetchBudget != "" {
+			if budget, err = humanize.ParseBytes(opts.prefetchBudget); err != nil {
+				return fmt.Errorf("invalid prefetch budget %q: %s", opts.prefetchBudget, err)
+			}
 		}
 
-		if err := machine.Mount(opts); err != nil {
+		mountOpts := &machine.MountOptions{
+			Identifier:     ident,
+			Path:           path,
+			RemotePath:     remotePath,
+			PrefetchBudget: int64(budget),
+			Reverse:        opts.reverse,
+			AskList:        cli.AskList(c, cmd),
+		}
+
+		if err := machine.Mount(mountOpts); err != nil {
 			return err
 		}
 
@@ -76,18 +99,14 @@ func command(c *cli.CLI, opts *options) cli.CobraFuncE {
 // mountExport checks if provided identifiers are valid from the mount
 // perspective. The identifiers should satisfy the following format:
 //
-//   (ID|Alias|IP)[:remote_directory/path] [local_directory/path]
+//   [deployment:](ID|Alias|IP)[:remote_directory/path] [local_directory/path]
 //
 func mountExport(idents []string) (ident, remotePath, path string, err error) {
 	if len(idents) != 1 && len(idents) != 2 {
 		return "", "", "", fmt.Errorf("invalid number of arguments: %s", strings.Join(idents, ", "))
 	}
 
-	ident = idents[0]
-
-	if i := strings.IndexRune(ident, ':'); i != -1 {
-		ident, remotePath = ident[:i], ident[i+1:]
-	}
+	ident, remotePath = machine.SplitRemote(idents[0])
 
 	if len(idents) == 2 {
 		if path, err = filepath.Abs(idents[1]); err != nil {
@@ -11,6 +11,7 @@ type inspectOptions struct {
 	filesystem bool
 	tree       bool
 	sync       bool
+	prefetch   bool
 }
 
 // NewInspectCommand creates a command that allows to debug existing mount state.
@@ -29,6 +30,7 @@ func NewInspectCommand(c *cli.CLI) *cobra.Command {
 	flags.BoolVar(&opts.filesystem, "filesystem", false, "filesystem diagnostic")
 	flags.BoolVar(&opts.tree, "tree", false, "index internal state")
 	flags.BoolVar(&opts.sync, "sync", true, "sync events history")
+	flags.BoolVar(&opts.prefetch, "prefetch", false, "prefetched files and reasons")
 
 	// Middlewares.
 	cli.MultiCobraCmdMiddleware(
@@ -43,7 +45,7 @@ func inspectCommand(c *cli.CLI, opts *inspectOptions) cli.CobraFuncE {
 	return func(cmd *cobra.Command, args []string) error {
 		// Enable sync option when there is none set explicitly. Tree may be too
 		// large to show it implicitly.
-		if !opts.sync && !opts.tree && !opts.filesystem {
+		if !opts.sync && !opts.tree && !opts.filesystem && !opts.prefetch {
 			opts.sync = true
 		}
 
@@ -52,6 +54,7 @@ func inspectCommand(c *cli.CLI, opts *inspectOptions) cli.CobraFuncE {
 			Sync:       opts.sync,
 			Tree:       opts.tree,
 			Filesystem: opts.filesystem,
+			Prefetch:   opts.prefetch,
 		}
 
 		records, err := machine.InspectMount(inspectOpts)
@@ -0,0 +1,160 @@
+package mount
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"koding/klient/machine/mount/sync/history"
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+type logOptions struct {
+	path       string
+	status     string
+	since      time.Duration
+	limit      int
+	follow     bool
+	interval   time.Duration
+	stats      bool
+	jsonOutput bool
+}
+
+// NewLogCommand creates a command that displays mount synchronization
+// activity.
+func NewLogCommand(c *cli.CLI) *cobra.Command {
+	opts := &logOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "log <mount-id>",
+		Short: "Show mount synchronization activity",
+		Long: `Show synchronization activity of a given mount.
+
+Synchronization history is stored by klient, so it is available also after
+klient restart. With --follow flag, new records are displayed as they appear.`,
+		RunE: logCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.StringVar(&opts.path, "path", "", "limit to files matching pattern or directory")
+	flags.StringVar(&opts.status, "status", "", "limit to records with status: received, started, succeeded or failed")
+	flags.DurationVar(&opts.since, "since", 0, "limit to records newer than a relative duration, eg. 1h")
+	flags.IntVar(&opts.limit, "limit", 0, "maximum number of displayed records")
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "follow synchronization activity")
+	flags.DurationVar(&opts.interval, "interval", time.Second, "polling interval used when following")
+	flags.BoolVar(&opts.stats, "stats", false, "show statistics instead of records")
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(1),   // One argument is required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func logCommand(c *cli.CLI, opts *logOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		switch status := history.Status(opts.status); status {
+		case "", history.StatusReceived, history.StatusStarted, history.StatusSucceeded, history.StatusFailed:
+		default:
+			return fmt.Errorf("invalid status %q", opts.status)
+		}
+
+		logOpts := &machine.MountLogOptions{
+			Identifier: args[0],
+			Filter: history.Filter{
+				Path:   opts.path,
+				Status: history.Status(opts.status),
+				Limit:  opts.limit,
+			},
+		}
+
+		if opts.since > 0 {
+			logOpts.Filter.Since = time.Now().Add(-opts.since).UTC()
+		}
+
+		res, err := machine.MountLog(logOpts)
+		if err != nil {
+			return err
+		}
+
+		if opts.stats {
+			if opts.jsonOutput {
+				cli.PrintJSON(c.Out(), res.Stats)
+			} else {
+				tabStatsFormatter(c.Out(), res.Stats)
+			}
+			return nil
+		}
+
+		printRecords(c.Out(), res.Records, opts.jsonOutput)
+		if !opts.follow {
+			return nil
+		}
+
+		// Follow new records. Limit is applied only to initial output.
+		logOpts.Filter.Limit = 0
+		for {
+			if n := len(res.Records); n != 0 {
+				logOpts.Filter.After = res.Records[n-1].ID
+			}
+
+			time.Sleep(opts.interval)
+
+			if res, err = machine.MountLog(logOpts); err != nil {
+				return err
+			}
+
+			printRecords(c.Out(), res.Records, opts.jsonOutput)
+		}
+	}
+}
+
+func printRecords(w io.Writer, recs []*history.Record, jsonOutput bool) {
+	// Print one record per line, so the output can be easily streamed.
+	if jsonOutput {
+		enc := json.NewEncoder(w)
+		for _, rec := range recs {
+			enc.Encode(rec)
+		}
+		return
+	}
+
+	for _, rec := range recs {
+		fmt.Fprintf(w, "%s %-9s %s", rec.CreatedAt.Local().Format("2006-01-02 15:04:05"), rec.Status, rec.Path)
+
+		switch rec.Status {
+		case history.StatusSucceeded:
+			fmt.Fprintf(w, " (%s in %s)", humanize.IBytes(uint64(rec.Size)), rec.Duration)
+		case history.StatusFailed:
+			fmt.Fprintf(w, " (after %s): %s", rec.Duration, rec.Error)
+		case "":
+			// Records created by older klient versions.
+			fmt.Fprint(w, rec.Message)
+		}
+
+		fmt.Fprintln(w)
+	}
+}
+
+func tabStatsFormatter(w io.Writer, stats *history.Stats) {
+	tw := tabwriter.NewWriter(w, 2, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "SUCCEEDED\tFAILED\tTRANSFERRED\tAVG LATENCY\n")
+	fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n",
+		stats.Succeeded,
+		stats.Failed,
+		humanize.IBytes(uint64(stats.Bytes)),
+		stats.AvgLatency,
+	)
+}
@@ -0,0 +1,51 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates a command that manages remote machine snapshots.
+func NewCommand(c *cli.CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage remote machine snapshots",
+		RunE:  cli.PrintHelp(c.Err()),
+	}
+
+	// Subcommands.
+	cmd.AddCommand(
+		NewCreateCommand(c),
+		NewDeleteCommand(c),
+		NewListCommand(c),
+		NewRestoreCommand(c),
+	)
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.NoArgs, // No custom arguments are accepted.
+	)(c, cmd)
+
+	return cmd
+}
+
+// wait prints progress of the snapshot operation given by the event.
+func wait(c *cli.CLI, event string, jsonOutput bool) (err error) {
+	for e := range machine.Wait(event) {
+		if e.Error != nil {
+			err = e.Error
+		}
+
+		if jsonOutput {
+			cli.PrintJSON(c.Out(), e)
+		} else {
+			fmt.Fprintf(c.Out(), "[%d%%] %s\n", e.Event.Percentage, e.Event.Message)
+		}
+	}
+
+	return err
+}
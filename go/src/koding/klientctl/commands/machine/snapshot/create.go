@@ -0,0 +1,52 @@
+package snapshot
+
+import (
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+type createOptions struct {
+	label      string
+	jsonOutput bool
+}
+
+// NewCreateCommand creates a command that creates a remote machine snapshot.
+func NewCreateCommand(c *cli.CLI) *cobra.Command {
+	opts := &createOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create <machine-identifier>",
+		Short: "Create machine snapshot",
+		RunE:  createCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.StringVar(&opts.label, "label", "", "snapshot label")
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(1),   // One argument is required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func createCommand(c *cli.CLI, opts *createOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		event, err := machine.CreateSnapshot(&machine.SnapshotOptions{
+			Identifier: args[0],
+			Label:      opts.label,
+			AskList:    cli.AskList(c, cmd),
+		})
+		if err != nil {
+			return err
+		}
+
+		return wait(c, event, opts.jsonOutput)
+	}
+}
@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+type deleteOptions struct {
+	jsonOutput bool
+}
+
+// NewDeleteCommand creates a command that deletes a remote machine snapshot.
+func NewDeleteCommand(c *cli.CLI) *cobra.Command {
+	opts := &deleteOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete <machine-identifier> <snapshot-id>",
+		Short: "Delete machine snapshot",
+		RunE:  deleteCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(2),   // Two arguments are required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func deleteCommand(c *cli.CLI, opts *deleteOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		event, err := machine.DeleteSnapshot(&machine.SnapshotOptions{
+			Identifier: args[0],
+			SnapshotID: args[1],
+			AskList:    cli.AskList(c, cmd),
+		})
+		if err != nil {
+			return err
+		}
+
+		return wait(c, event, opts.jsonOutput)
+	}
+}
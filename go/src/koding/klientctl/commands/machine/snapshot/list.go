@@ -0,0 +1,83 @@
+package snapshot
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+type listOptions struct {
+	jsonOutput bool
+}
+
+// NewListCommand creates a command that lists remote machine snapshots.
+func NewListCommand(c *cli.CLI) *cobra.Command {
+	opts := &listOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "list <machine-identifier>",
+		Aliases: []string{"ls"},
+		Short:   "List machine snapshots",
+		RunE:    listCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(1),   // One argument is required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func listCommand(c *cli.CLI, opts *listOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		snapshots, err := machine.ListSnapshots(&machine.SnapshotOptions{
+			Identifier: args[0],
+			AskList:    cli.AskList(c, cmd),
+		})
+		if err != nil {
+			return err
+		}
+
+		if opts.jsonOutput {
+			cli.PrintJSON(c.Out(), snapshots)
+			return nil
+		}
+
+		now := time.Now()
+		tw := tabwriter.NewWriter(c.Out(), 2, 0, 2, ' ', 0)
+		defer tw.Flush()
+
+		fmt.Fprintf(tw, "ID\tLABEL\tPROVIDER\tREGION\tSIZE\tAGE\n")
+		for _, s := range snapshots {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.ID,
+				s.Label,
+				s.Provider,
+				dash(s.Region),
+				dash(s.StorageSize),
+				machine.ShortDuration(s.CreatedAt, now),
+			)
+		}
+
+		return nil
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/machine"
+
+	"github.com/spf13/cobra"
+)
+
+type restoreOptions struct {
+	jsonOutput bool
+}
+
+// NewRestoreCommand creates a command that restores a remote machine from its snapshot.
+func NewRestoreCommand(c *cli.CLI) *cobra.Command {
+	opts := &restoreOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "restore <machine-identifier> <snapshot-id>",
+		Short: "Restore machine from snapshot",
+		RunE:  restoreCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+		cli.ExactArgs(2),   // Two arguments are required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func restoreCommand(c *cli.CLI, opts *restoreOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		event, err := machine.RestoreSnapshot(&machine.SnapshotOptions{
+			Identifier: args[0],
+			SnapshotID: args[1],
+			AskList:    cli.AskList(c, cmd),
+		})
+		if err != nil {
+			return err
+		}
+
+		return wait(c, event, opts.jsonOutput)
+	}
+}
@@ -19,7 +19,14 @@ func NewSSHCommand(c *cli.CLI) *cobra.Command {
 		Use:     "ssh <machine-identifier>",
 		Aliases: []string{"s"},
 		Short:   "SSH to remote machine",
-		RunE:    sshCommand(c, opts),
+		Long: `SSH to remote machine.
+
+A short-lived SSH certificate signed by the team certificate authority is used
+when available, otherwise local public key is added to remote authorized_keys
+file. SSH agent forwarding is enabled per machine with:
+
+  kd machine config set <machine-identifier> forwardAgent true`,
+		RunE: sshCommand(c, opts),
 	}
 
 	// Flags.
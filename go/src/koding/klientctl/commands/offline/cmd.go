@@ -0,0 +1,54 @@
+package offline
+
+import (
+	"fmt"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/offline"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates a command that manages data cached for offline use
+// and operations queued while Koding was unreachable.
+func NewCommand(c *cli.CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline",
+		Short: "Manage offline cache and queued operations",
+		RunE:  cli.PrintHelp(c.Err()),
+	}
+
+	// Subcommands.
+	cmd.AddCommand(
+		NewDropCommand(c),
+		NewReplayCommand(c),
+		NewStatusCommand(c),
+	)
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.NoArgs, // No custom arguments are accepted.
+	)(c, cmd)
+
+	return cmd
+}
+
+// Notify informs user about cached data which was displayed in place
+// of fresh one, and about queued operations which can be replayed
+// now that Koding is reachable again.
+func Notify(c *cli.CLI) {
+	for _, s := range offline.Stale() {
+		fmt.Fprintf(c.Err(), "Koding is unreachable (%s); showing cached %s from %s.\n",
+			s.Err, s.Name, humanize.Time(s.UpdatedAt))
+	}
+
+	if !offline.Online() {
+		return
+	}
+
+	if n := len(offline.Pending()); n != 0 {
+		fmt.Fprintf(c.Err(), "There are %d operations queued while Koding was unreachable. "+
+			"Please run \"kd offline replay\" to review and apply them.\n", n)
+	}
+}
@@ -0,0 +1,50 @@
+package offline
+
+import (
+	"errors"
+	"fmt"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/offline"
+
+	"github.com/spf13/cobra"
+)
+
+type dropOptions struct {
+	all bool
+}
+
+// NewDropCommand creates a command that discards queued operations.
+func NewDropCommand(c *cli.CLI) *cobra.Command {
+	opts := &dropOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "drop [operation-id...]",
+		Short: "Discard queued operations",
+		RunE:  dropCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.all, "all", false, "discard all queued operations")
+
+	return cmd
+}
+
+func dropCommand(c *cli.CLI, opts *dropOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !opts.all {
+			return errors.New("either operation IDs or --all flag is required")
+		}
+
+		for _, op := range selectOps(offline.Pending(), args) {
+			if err := offline.Drop(op.ID); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.Out(), "Discarded #%s: %s.\n", op.ID, op.Summary)
+		}
+
+		return nil
+	}
+}
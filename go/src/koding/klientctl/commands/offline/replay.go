@@ -0,0 +1,107 @@
+package offline
+
+import (
+	"fmt"
+	"strings"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/offline"
+	"koding/klientctl/helper"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+type replayOptions struct {
+	force bool
+}
+
+// NewReplayCommand creates a command that applies operations queued
+// while Koding was unreachable.
+func NewReplayCommand(c *cli.CLI) *cobra.Command {
+	opts := &replayOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "replay [operation-id...]",
+		Short: "Apply queued operations",
+		Long: `Applies operations, which were queued while Koding was unreachable.
+
+Each operation needs to be confirmed before it is applied, unless --force
+flag is used. If no operation IDs are given, all queued operations are
+replayed, oldest first.`,
+		RunE: replayCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.force, "force", false, "confirm all questions")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.DaemonRequired, // Deamon service is required.
+	)(c, cmd)
+
+	return cmd
+}
+
+func replayCommand(c *cli.CLI, opts *replayOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		ops := selectOps(offline.Pending(), args)
+
+		if len(ops) == 0 {
+			fmt.Fprintln(c.Out(), "There are no queued operations.")
+			return nil
+		}
+
+		var failed int
+
+		for _, op := range ops {
+			if !opts.force {
+				s, err := helper.Fask(c.In(), c.Out(), "Apply #%s: %s (queued %s)? [y/N]: ",
+					op.ID, op.Summary, humanize.Time(op.QueuedAt))
+				if err != nil {
+					return err
+				}
+
+				if s = strings.ToLower(strings.TrimSpace(s)); s != "y" && s != "yes" {
+					fmt.Fprintf(c.Out(), "Skipped #%s.\n", op.ID)
+					continue
+				}
+			}
+
+			if err := offline.Replay(op.ID); err != nil {
+				fmt.Fprintf(c.Err(), "Failed to apply #%s: %s\n", op.ID, err)
+				failed++
+				continue
+			}
+
+			fmt.Fprintf(c.Out(), "Applied #%s: %s.\n", op.ID, op.Summary)
+		}
+
+		if failed != 0 {
+			return fmt.Errorf("failed to apply %d operations", failed)
+		}
+
+		return nil
+	}
+}
+
+// selectOps gives operations with the given IDs, or all
+// of them if no IDs were given.
+func selectOps(ops []*offline.Op, ids []string) []*offline.Op {
+	if len(ids) == 0 {
+		return ops
+	}
+
+	var selected []*offline.Op
+
+	for _, id := range ids {
+		for _, op := range ops {
+			if op.ID == strings.TrimPrefix(id, "#") {
+				selected = append(selected, op)
+			}
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,95 @@
+package offline
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"koding/klientctl/commands/cli"
+	"koding/klientctl/endpoint/offline"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+type statusOptions struct {
+	jsonOutput bool
+}
+
+// NewStatusCommand creates a command that displays cached resources
+// and queued operations.
+func NewStatusCommand(c *cli.CLI) *cobra.Command {
+	opts := &statusOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show cached resources and queued operations",
+		RunE:  statusCommand(c, opts),
+	}
+
+	// Flags.
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.jsonOutput, "json", false, "output in JSON format")
+
+	// Middlewares.
+	cli.MultiCobraCmdMiddleware(
+		cli.NoArgs, // No custom arguments are accepted.
+	)(c, cmd)
+
+	return cmd
+}
+
+func statusCommand(c *cli.CLI, opts *statusOptions) cli.CobraFuncE {
+	return func(cmd *cobra.Command, args []string) error {
+		entries, pending := offline.Entries(), offline.Pending()
+
+		if opts.jsonOutput {
+			cli.PrintJSON(c.Out(), map[string]interface{}{
+				"cached":  entries,
+				"pending": pending,
+			})
+			return nil
+		}
+
+		printEntries(c, entries)
+
+		if len(pending) == 0 {
+			fmt.Fprintln(c.Out(), "\nThere are no queued operations.")
+			return nil
+		}
+
+		fmt.Fprintln(c.Out())
+		printOps(c, pending)
+
+		return nil
+	}
+}
+
+func printEntries(c *cli.CLI, entries map[string]time.Time) {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(c.Out(), 2, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "CACHED\tUPDATED")
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, humanize.Time(entries[name]))
+	}
+}
+
+func printOps(c *cli.CLI, ops []*offline.Op) {
+	w := tabwriter.NewWriter(c.Out(), 2, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tOPERATION\tQUEUED")
+
+	for _, op := range ops {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", op.ID, op.Summary, humanize.Time(op.QueuedAt))
+	}
+}
@@ -1,9 +1,12 @@
 package stack
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+
+	"koding/kites/tracing"
 	"koding/klientctl/commands/cli"
 	"koding/klientctl/endpoint/kloud"
 	"koding/klientctl/endpoint/stack"
@@ -47,9 +50,8 @@ func NewCreateCommand(c *cli.CLI) *cobra.Command {
 }
 
 func createCommand(c *cli.CLI, opts *createOptions) cli.CobraFuncE {
-	return func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) (err error) {
 		var p []byte
-		var err error
 
 		switch opts.file {
 		case "":
@@ -66,11 +68,19 @@ func createCommand(c *cli.CLI, opts *createOptions) cli.CobraFuncE {
 
 		fmt.Fprintln(c.Err(), "Creating stack... ")
 
+		// The span covers both creating and building the stack.
+		span, ctx := tracing.StartSpan(context.Background(), "stack.create")
+		defer func() {
+			span.SetError(err)
+			span.Finish()
+		}()
+
 		createOpts := &stack.CreateOptions{
 			Team:        opts.team,
 			Title:       opts.title,
 			Credentials: opts.creds,
 			Template:    p,
+			Trace:       tracing.FromContext(ctx),
 		}
 
 		resp, err := stack.Create(createOpts)
@@ -78,6 +88,8 @@ func createCommand(c *cli.CLI, opts *createOptions) cli.CobraFuncE {
 			return errors.New("error creating stack: " + err.Error())
 		}
 
+		span.SetTag("stackId", resp.StackID)
+
 		if opts.jsonOutput {
 			cli.PrintJSON(c.Out(), resp)
 			return nil
@@ -1,19 +1,25 @@
 package machine
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"strconv"
+	"strings"
 	"sync"
 
 	"koding/kites/config"
+	"koding/kites/config/configstore"
 	"koding/kites/kloud/stack"
 	"koding/klient/machine"
+	mclient "koding/klient/machine/client"
 	"koding/klient/machine/machinegroup"
 	"koding/klient/os"
 	konfig "koding/klientctl/config"
 	"koding/klientctl/ctlcli"
 	"koding/klientctl/endpoint/kloud"
+	"koding/klientctl/endpoint/offline"
 	koding "koding/klientctl/endpoint/remoteapi"
 	"koding/klientctl/stream"
 	"koding/remoteapi/models"
@@ -36,11 +42,13 @@ func init() {
 //   - creating, deleting and listing mounts
 //
 type Client struct {
-	Konfig *config.Konfig
-	Klient kloud.Transport
-	Kloud  *kloud.Client
-	Koding *koding.Client
-	Stream stream.Streamer
+	Konfig  *config.Konfig
+	Konfigs config.Konfigs // if nil, configurations from konfig.bolt are used
+	Klient  kloud.Transport
+	Kloud   *kloud.Client
+	Koding  *koding.Client
+	Offline *offline.Client // if nil, offline.DefaultClient is used
+	Stream  stream.Streamer
 
 	k        kloud.Transport
 	once     sync.Once // for c.init()
@@ -213,8 +221,10 @@ func (c *Client) Set(options *SetOptions) error {
 	switch options.Key {
 	case "alwaysOn":
 		return c.setAlwaysOn(id, options.Value)
+	case stack.ForwardAgentMetaKey:
+		return c.setForwardAgent(id, options.Value)
 	default:
-		return fmt.Errorf(`unsupported %q key; supported ones: "alwaysOn"`, options.Value)
+		return fmt.Errorf(`unsupported %q key; supported ones: "alwaysOn", %q`, options.Key, stack.ForwardAgentMetaKey)
 	}
 }
 
@@ -232,6 +242,25 @@ func (c *Client) setAlwaysOn(id machine.ID, key string) error {
 	return c.koding().UpdateMachineAlwaysOn(m, on)
 }
 
+func (c *Client) setForwardAgent(id machine.ID, key string) error {
+	on, err := strconv.ParseBool(key)
+	if err != nil {
+		return err
+	}
+
+	req := &stack.SSHConfigRequest{
+		MachineID:    string(id),
+		ForwardAgent: on,
+	}
+
+	kc, err := c.machineKloud(id)
+	if err != nil {
+		return err
+	}
+
+	return kc.Call("ssh.config", req, nil)
+}
+
 func (c *Client) machineCall(id machine.ID, method string) (string, error) {
 	m, err := c.machine(id)
 	if err != nil {
@@ -245,12 +274,22 @@ func (c *Client) machineCall(id machine.ID, method string) (string, error) {
 	var resp machineResp
 
 	if err := c.kloud().Call(method, req, &resp); err != nil {
-		return "", err
+		call := &offline.KloudCall{
+			Method: method,
+			Arg:    req,
+		}
+
+		return "", c.offline().QueueIfOffline(err, "kloud", fmt.Sprintf("%s %q machine", actions[method], m.Label), call)
 	}
 
 	return resp.EventId, nil
 }
 
+var actions = map[string]string{
+	"start": "starting",
+	"stop":  "stopping",
+}
+
 type machineReq struct {
 	MachineId string
 	Provider  string
@@ -309,6 +348,13 @@ func (c *Client) konfig() *config.Konfig {
 	return konfig.Konfig
 }
 
+func (c *Client) konfigs() config.Konfigs {
+	if c.Konfigs != nil {
+		return c.Konfigs
+	}
+	return configstore.List()
+}
+
 func (c *Client) klient() kloud.Transport {
 	if c.Klient != nil {
 		return c.Klient
@@ -337,6 +383,84 @@ func (c *Client) kloud() *kloud.Client {
 	return kloud.DefaultClient
 }
 
+// machineKloud gives kloud client of the Koding deployment the machine
+// belongs to.
+func (c *Client) machineKloud(id machine.ID) (*kloud.Client, error) {
+	meta, err := c.metadata(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.Deployment == "" || meta.Deployment == c.konfig().Deployment() {
+		return c.kloud(), nil
+	}
+
+	for _, k := range c.konfigs().Deployments() {
+		if k.Deployment() == meta.Deployment {
+			return deploymentKloud(k), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s deployment of %s machine is not configured", meta.Deployment, id)
+}
+
+// metadata gets machine metadata stored by klient.
+func (c *Client) metadata(id machine.ID) (*machine.Metadata, error) {
+	idReq := &machinegroup.IDRequest{
+		Identifier: string(id),
+	}
+	var idRes machinegroup.IDResponse
+
+	if err := c.klient().Call("machine.id", idReq, &idRes); err != nil {
+		return nil, err
+	}
+
+	meta, ok := idRes.IDs[id]
+	if !ok {
+		return nil, fmt.Errorf("machine %s does not exist", id)
+	}
+
+	return &meta, nil
+}
+
+// deploymentKloud gives kloud client of the given deployment.
+func deploymentKloud(k *config.Konfig) *kloud.Client {
+	return &kloud.Client{
+		Transport: &kloud.KiteTransport{Konfig: k},
+	}
+}
+
+// deploymentKite gives kontrol URL and kite key klient uses to connect to
+// machines of the given deployment.
+func deploymentKite(k *config.Konfig) (*mclient.DeploymentKite, error) {
+	kiteKey := k.KiteKey
+
+	if kiteKey == "" && k.KiteKeyFile != "" {
+		p, err := ioutil.ReadFile(k.KiteKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		kiteKey = string(bytes.TrimSpace(p))
+	}
+
+	if kiteKey == "" {
+		return nil, errors.New("kite key is not set")
+	}
+
+	return &mclient.DeploymentKite{
+		KontrolURL: k.Endpoints.Kontrol().Public.String(),
+		KiteKey:    kiteKey,
+	}, nil
+}
+
+func (c *Client) offline() *offline.Client {
+	if c.Offline != nil {
+		return c.Offline
+	}
+	return offline.DefaultClient
+}
+
 func (c *Client) stream() stream.Streamer {
 	if c.Stream != nil {
 		return c.Stream
@@ -362,6 +486,31 @@ func (c *Client) getMachineID(str string, askList func(is, ds []string) (string,
 	return machine.ID(strID), nil
 }
 
+// SplitRemote splits remote address in form [deployment:]identifier[:path]
+// into machine identifier and remote path. The deployment prefix is kept in
+// returned identifier only when it names one of configured deployments.
+func (c *Client) SplitRemote(addr string) (ident, path string) {
+	toks := strings.SplitN(addr, ":", 3)
+
+	if len(toks) > 1 && c.isDeployment(toks[0]) {
+		ident, toks = toks[0]+":"+toks[1], toks[2:]
+	} else {
+		ident, toks = toks[0], toks[1:]
+	}
+
+	return ident, strings.Join(toks, ":")
+}
+
+func (c *Client) isDeployment(name string) bool {
+	for _, k := range c.konfigs().Deployments() {
+		if k.Deployment() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Exec runs the given command in a remote machine using DefaultClient.
 func Exec(opts *ExecOptions) (int, error) { return DefaultClient.Exec(opts) }
 
@@ -383,6 +532,10 @@ func Wait(event string) <-chan *stack.EventResponse {
 	return DefaultClient.kloud().Wait(event)
 }
 
+// SplitRemote splits remote address into machine identifier and remote path
+// using DefaultClient.
+func SplitRemote(addr string) (ident, path string) { return DefaultClient.SplitRemote(addr) }
+
 // Show gets JMachine.meta value of a vm given by the identifier.
 func Show(opts *ShowOptions) (map[string]interface{}, error) {
 	return DefaultClient.Show(opts)
@@ -0,0 +1,122 @@
+package machine
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"koding/kites/config"
+	"koding/klient/machine"
+	"koding/klient/machine/machinegroup"
+	"koding/klientctl/endpoint/kloud"
+)
+
+// fakeKlient serves machine.id requests with stored machine metadata.
+type fakeKlient map[machine.ID]machine.Metadata
+
+func (fk fakeKlient) Connect(string) (kloud.Transport, error) { return fk, nil }
+
+func (fk fakeKlient) Call(method string, arg, reply interface{}) error {
+	if method != "machine.id" {
+		return errors.New("unsupported method: " + method)
+	}
+
+	id := machine.ID(arg.(*machinegroup.IDRequest).Identifier)
+
+	meta, ok := fk[id]
+	if !ok {
+		return errors.New("machine not found")
+	}
+
+	reply.(*machinegroup.IDResponse).IDs = map[machine.ID]machine.Metadata{id: meta}
+
+	return nil
+}
+
+func TestMachineKloud(t *testing.T) {
+	current := &config.Konfig{
+		Endpoints: &config.Endpoints{Koding: config.NewEndpoint("https://koding.com")},
+	}
+	other := &config.Konfig{
+		Endpoints: &config.Endpoints{Koding: config.NewEndpoint("https://team.example.com")},
+		KiteKey:   "kite.key",
+	}
+
+	c := &Client{
+		Konfig:  current,
+		Konfigs: config.Konfigs{current.ID(): current, other.ID(): other},
+		Klient: fakeKlient{
+			"current":      {Label: "a", Deployment: "koding.com"},
+			"legacy":       {Label: "b"},
+			"other":        {Label: "c", Deployment: "team.example.com"},
+			"unconfigured": {Label: "d", Deployment: "unknown.example.com"},
+		},
+		Kloud: &kloud.Client{},
+	}
+
+	for _, id := range []machine.ID{"current", "legacy"} {
+		kc, err := c.machineKloud(id)
+		if err != nil {
+			t.Fatalf("%s: want err = nil; got %v", id, err)
+		}
+		if kc != c.Kloud {
+			t.Fatalf("%s: want kloud of used deployment", id)
+		}
+	}
+
+	kc, err := c.machineKloud("other")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	kt, ok := kc.Transport.(*kloud.KiteTransport)
+	if !ok {
+		t.Fatalf("want *kloud.KiteTransport; got %T", kc.Transport)
+	}
+	if d := kt.Konfig.Deployment(); d != "team.example.com" {
+		t.Fatalf("want deployment = %q; got %q", "team.example.com", d)
+	}
+
+	if _, err := c.machineKloud("unconfigured"); err == nil {
+		t.Fatal("want err != nil; got nil")
+	}
+}
+
+func TestDeploymentKite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deploymentkite")
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "kite.key")
+
+	if err := ioutil.WriteFile(file, []byte("kite.key\n"), 0600); err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	k := &config.Konfig{
+		Endpoints:   &config.Endpoints{Koding: config.NewEndpoint("https://team.example.com")},
+		KiteKeyFile: file,
+	}
+
+	dk, err := deploymentKite(k)
+	if err != nil {
+		t.Fatalf("want err = nil; got %v", err)
+	}
+
+	if dk.KiteKey != "kite.key" {
+		t.Fatalf("want kite key = %q; got %q", "kite.key", dk.KiteKey)
+	}
+	if want := "https://team.example.com/kontrol/kite"; dk.KontrolURL != want {
+		t.Fatalf("want kontrol URL = %q; got %q", want, dk.KontrolURL)
+	}
+
+	k.KiteKeyFile = ""
+
+	if _, err := deploymentKite(k); err == nil {
+		t.Fatal("want err != nil; got nil")
+	}
+}
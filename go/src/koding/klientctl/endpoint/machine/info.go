@@ -49,6 +49,9 @@ type Info struct {
 
 	// Owner describes who shared the machine if it's shared.
 	Owner string `json:"owner"`
+
+	// Deployment is a name of Koding deployment the machine belongs to.
+	Deployment string `json:"deployment,omitempty"`
 }
 
 // InfoSlice attaches the methods of Interface to []Info, they provide priority
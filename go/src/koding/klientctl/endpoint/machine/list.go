@@ -3,12 +3,15 @@ package machine
 import (
 	"errors"
 	"sort"
+	"sync"
 	"time"
 
+	"koding/kites/config"
 	"koding/kites/kloud/machine"
 	"koding/kites/kloud/machinestate"
 	"koding/kites/kloud/stack"
 	kmachine "koding/klient/machine"
+	mclient "koding/klient/machine/client"
 	"koding/klient/machine/machinegroup"
 )
 
@@ -40,7 +43,9 @@ type ListOptions struct {
 	MachineID string
 }
 
-// List retrieves user's machines from kloud.
+// List retrieves user's machines from kloud. Machines are listed from
+// every configured Koding deployment, errors of deployments other than
+// the used one are only logged.
 func (c *Client) List(options *ListOptions) ([]*Info, error) {
 	if options == nil {
 		return nil, errors.New("invalid nil options")
@@ -51,19 +56,97 @@ func (c *Client) List(options *ListOptions) ([]*Info, error) {
 	}
 	var listRes stack.MachineListResponse
 
-	// Get info from kloud.
-	if err := c.kloud().Call("machine.list", listReq, &listRes); err != nil {
+	// Get info from kloud, or from the cache if Koding is unreachable.
+	err := c.offline().Fetch("machines", listReq, &listRes, func() error {
+		return c.kloud().Call("machine.list", listReq, &listRes)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Register machines to klient and get aliases.
+	infos, err := c.create(c.konfig().Deployment(), nil, listRes.Machines)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range c.listDeployments(listReq) {
+		if res.err == nil {
+			var depInfos []*Info
+			if depInfos, res.err = c.create(res.deployment, res.kite, res.machines); res.err == nil {
+				infos = append(infos, depInfos...)
+				continue
+			}
+		}
+
+		c.stream().Log().Warning("Cannot list machines of %s deployment: %s", res.deployment, res.err)
+	}
+
+	// Sort items before we return.
+	sort.Sort(InfoSlice(infos))
+
+	return infos, nil
+}
+
+type deploymentList struct {
+	deployment string
+	kite       *mclient.DeploymentKite
+	machines   []*machine.Machine
+	err        error
+}
+
+// listDeployments lists machines of configured Koding deployments other than
+// the used one. Deployments without kite key are skipped, since their kloud
+// would not authenticate us.
+func (c *Client) listDeployments(req *stack.MachineListRequest) []*deploymentList {
+	var (
+		used = c.konfig().ID()
+		res  []*deploymentList
+		wg   sync.WaitGroup
+	)
+
+	for _, k := range c.konfigs().Deployments() {
+		if k.ID() == used || (k.KiteKey == "" && k.KiteKeyFile == "") {
+			continue
+		}
+
+		dl := &deploymentList{
+			deployment: k.Deployment(),
+		}
+		res = append(res, dl)
+
+		if dl.kite, dl.err = deploymentKite(k); dl.err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(k *config.Konfig) {
+			defer wg.Done()
+
+			var listRes stack.MachineListResponse
+			if dl.err = deploymentKloud(k).Call("machine.list", req, &listRes); dl.err == nil {
+				dl.machines = listRes.Machines
+			}
+		}(k)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// create registers machines of a given deployment to klient and converts them
+// to Info values. The kite is required for deployments other than the used
+// one, so klient can authenticate to their machines.
+func (c *Client) create(deployment string, kite *mclient.DeploymentKite, machines []*machine.Machine) ([]*Info, error) {
 	createReq := &machinegroup.CreateRequest{
-		Addresses: make(map[kmachine.ID][]kmachine.Addr),
-		Metadata:  make(map[kmachine.ID]*kmachine.Metadata),
+		Addresses:      make(map[kmachine.ID][]kmachine.Addr),
+		Metadata:       make(map[kmachine.ID]*kmachine.Metadata),
+		Deployment:     deployment,
+		DeploymentKite: kite,
 	}
 	var createRes machinegroup.CreateResponse
 
-	for _, m := range listRes.Machines {
+	for _, m := range machines {
 		createReq.Addresses[kmachine.ID(m.ID)] = []kmachine.Addr{
 			{
 				Network:   "ip",
@@ -82,10 +165,11 @@ func (c *Client) List(options *ListOptions) ([]*Info, error) {
 			},
 		}
 		createReq.Metadata[kmachine.ID(m.ID)] = &kmachine.Metadata{
-			Owner: ownerFromUsers(m.Users),
-			Label: m.Label,
-			Stack: m.Stack,
-			Team:  m.Team,
+			Owner:      ownerFromUsers(m.Users),
+			Label:      m.Label,
+			Stack:      m.Stack,
+			Team:       m.Team,
+			Deployment: deployment,
 		}
 	}
 
@@ -93,8 +177,8 @@ func (c *Client) List(options *ListOptions) ([]*Info, error) {
 		return nil, err
 	}
 
-	infos := make([]*Info, len(listRes.Machines))
-	for i, m := range listRes.Machines {
+	infos := make([]*Info, len(machines))
+	for i, m := range machines {
 		infos[i] = &Info{
 			ID:          m.ID,
 			Alias:       createRes.Aliases[kmachine.ID(m.ID)],
@@ -111,14 +195,12 @@ func (c *Client) List(options *ListOptions) ([]*Info, error) {
 				Reason: m.Status.Reason,
 				Since:  m.Status.ModifiedAt,
 			}, createRes.Statuses[kmachine.ID(m.ID)]),
-			Username: machineUserFromUsers(m.Users),
-			Owner:    ownerFromUsers(m.Users),
+			Username:   machineUserFromUsers(m.Users),
+			Owner:      ownerFromUsers(m.Users),
+			Deployment: deployment,
 		}
 	}
 
-	// Sort items before we return.
-	sort.Sort(InfoSlice(infos))
-
 	return infos, nil
 }
 
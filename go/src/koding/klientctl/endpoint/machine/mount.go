@@ -15,6 +15,7 @@ import (
 	"koding/klient/machine/machinegroup"
 	"koding/klient/machine/mount"
 	"koding/klient/machine/mount/prefetch"
+	"koding/klient/machine/mount/sync/history"
 	"koding/klientctl/helper"
 
 	humanize "github.com/dustin/go-humanize"
@@ -27,16 +28,34 @@ type MountOptions struct {
 	Path       string // Machine local path - absolute and cleaned.
 	RemotePath string // Remote machine path - raw format.
 
+	// PrefetchBudget limits the size of prefetched files; if zero,
+	// the configured budget is used.
+	PrefetchBudget int64
+
+	// Reverse exposes local Path directory on remote machine instead of
+	// mounting remote directory locally.
+	Reverse bool
+
 	AskList func(is, ds []string) (string, error) // Ask for multiple choices.
 }
 
 func (c *Client) mountPoint(id machine.ID) (string, error) {
-	m, err := c.machine(id)
+	if m, err := c.machine(id); err == nil {
+		return filepath.Join(c.konfig().Mount.Home, m.Label), nil
+	}
+
+	// Machines of other deployments are not known to used Koding, so
+	// use machine label stored by klient.
+	meta, err := c.metadata(id)
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(c.konfig().Mount.Home, m.Label), nil
+	if meta.Label == "" {
+		return "", fmt.Errorf("unable to find label of %s machine", id)
+	}
+
+	return filepath.Join(c.konfig().Mount.Home, meta.Deployment, meta.Label), nil
 }
 
 // Mount synchronizes directories between remote and local machines.
@@ -53,6 +72,10 @@ func (c *Client) Mount(options *MountOptions) (err error) {
 		return err
 	}
 
+	if options.Reverse {
+		return c.mountReverse(id, options)
+	}
+
 	if options.Path == "" {
 		if options.Path, err = c.mountPoint(id); err != nil {
 			return err
@@ -116,6 +139,7 @@ func (c *Client) Mount(options *MountOptions) (err error) {
 			Mount: m,
 		},
 		Strategies: prefetch.DefaultStrategy.Available(),
+		Budget:     options.PrefetchBudget,
 	}
 	var addMountRes machinegroup.AddMountResponse
 	if err = c.klient().Call("machine.mount.add", addMountReq, &addMountRes); err != nil {
@@ -164,6 +188,53 @@ func (c *Client) Mount(options *MountOptions) (err error) {
 	return nil
 }
 
+// mountReverse exposes local directory on remote machine. Local directory is
+// the source of synchronization, files are sent to remote machine as they
+// change.
+func (c *Client) mountReverse(id machine.ID, options *MountOptions) error {
+	if options.Path == "" {
+		return errors.New("local directory path is required for reverse mounts")
+	}
+	if options.RemotePath == "" {
+		return errors.New("remote directory path is required for reverse mounts")
+	}
+
+	info, err := os.Stat(options.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", options.Path)
+	}
+
+	m := mount.Mount{
+		Path:       options.Path,
+		RemotePath: options.RemotePath,
+		Reverse:    true,
+	}
+
+	fmt.Fprintf(c.stream().Out(), "Initializing reverse mount %s...\n", m)
+
+	addMountReq := &machinegroup.AddMountRequest{
+		MountRequest: machinegroup.MountRequest{
+			ID:    id,
+			Mount: m,
+		},
+	}
+	var addMountRes machinegroup.AddMountResponse
+	if err := c.klient().Call("machine.mount.add", addMountReq, &addMountRes); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stream().Out(), "Created reverse mount with ID: %s\n", addMountRes.MountID)
+
+	// Best-effort attempt of making the remote vm do not
+	// turn off after 1h.
+	_ = c.setAlwaysOn(id, "true")
+
+	return nil
+}
+
 // ListMountOptions stores options for `machine mount list` call.
 type ListMountOptions struct {
 	ID      string // Machine ID - optional.
@@ -217,6 +288,7 @@ type InspectMountOptions struct {
 	Sync       bool   // Get syncing history.
 	Tree       bool   // Show index tree.
 	Filesystem bool   // Check and report filesystem consistency.
+	Prefetch   bool   // Show prefetched files.
 }
 
 // InspectMount inspects provided mount.
@@ -232,12 +304,38 @@ func (c *Client) InspectMount(options *InspectMountOptions) (machinegroup.Inspec
 		Sync:       options.Sync,
 		Tree:       options.Tree,
 		Filesystem: options.Filesystem,
+		Prefetch:   options.Prefetch,
 	}
 
 	err := c.klient().Call("machine.mount.inspect", inspectMountReq, &inspectMountRes)
 	return inspectMountRes, err
 }
 
+// MountLogOptions stores options for `machine mount log` call.
+type MountLogOptions struct {
+	Identifier string         // Mount identifier.
+	Filter     history.Filter // Synchronization records filter.
+}
+
+// MountLog gets synchronization activity of provided mount.
+func (c *Client) MountLog(options *MountLogOptions) (*machinegroup.MountLogResponse, error) {
+	if options == nil {
+		return nil, errors.New("invalid nil options")
+	}
+
+	mountLogReq := &machinegroup.MountLogRequest{
+		Identifier: options.Identifier,
+		Filter:     options.Filter,
+	}
+	var mountLogRes machinegroup.MountLogResponse
+
+	if err := c.klient().Call("machine.mount.log", mountLogReq, &mountLogRes); err != nil {
+		return nil, err
+	}
+
+	return &mountLogRes, nil
+}
+
 // UmountOptions stores options for `machine umount` call.
 type UmountOptions struct {
 	Identifiers []string // Mount identifiers.
@@ -474,5 +572,10 @@ func InspectMount(opts *InspectMountOptions) (machinegroup.InspectMountResponse,
 	return DefaultClient.InspectMount(opts)
 }
 
+// MountLog gets mount synchronization activity using DefaultClient.
+func MountLog(opts *MountLogOptions) (*machinegroup.MountLogResponse, error) {
+	return DefaultClient.MountLog(opts)
+}
+
 // Umount removes existing mount using DefaultClient.
 func Umount(opts *UmountOptions) error { return DefaultClient.Umount(opts) }
@@ -0,0 +1,99 @@
+package machine
+
+import (
+	"errors"
+
+	"koding/klient/fs"
+	"koding/klient/machine/machinegroup"
+
+	"github.com/koding/kite/dnode"
+)
+
+// SearchOptions represents available parameters for the Search method.
+type SearchOptions struct {
+	Identifier string   // machine identifier; if empty, Path must reside inside existing mount
+	Path       string   // remote path if Identifier is set, local mount path otherwise
+	Pattern    string   // regular expression to look for
+	Literal    bool     // treat Pattern as a plain string
+	IgnoreCase bool     // case insensitive search
+	Include    []string // glob patterns of files to search in
+	Exclude    []string // glob patterns of files and directories to skip
+	SkipBinary bool     // do not report matching binary files
+	MaxResults int      // maximum number of matches, remote default if zero
+
+	Match func(*fs.SearchMatch)  // called in-order on each match; required
+	Done  func(*fs.SearchResult) // called last, when the search is finished
+
+	AskList func(is, ds []string) (string, error) // Ask for multiple choices.
+}
+
+// Search starts looking for file content that matches the given pattern on
+// a remote machine. Matches are streamed to the opts.Match callback.
+//
+// The returned function cancels the search.
+func (c *Client) Search(opts *SearchOptions) (cancel func() error, err error) {
+	if opts == nil || opts.Match == nil {
+		return nil, errors.New("invalid nil match callback")
+	}
+
+	req := &machinegroup.SearchRequest{
+		SearchRequest: fs.SearchRequest{
+			Path:       opts.Path,
+			Pattern:    opts.Pattern,
+			Literal:    opts.Literal,
+			IgnoreCase: opts.IgnoreCase,
+			Include:    opts.Include,
+			Exclude:    opts.Exclude,
+			SkipBinary: opts.SkipBinary,
+			MaxResults: opts.MaxResults,
+			OnMatch: dnode.Callback(func(r *dnode.Partial) {
+				var m fs.SearchMatch
+				r.One().MustUnmarshal(&m)
+				opts.Match(&m)
+			}),
+		},
+	}
+
+	if opts.Done != nil {
+		req.OnDone = dnode.Callback(func(r *dnode.Partial) {
+			var res fs.SearchResult
+			r.One().MustUnmarshal(&res)
+			opts.Done(&res)
+		})
+	}
+
+	if opts.Identifier != "" {
+		if req.MachineID, err = c.getMachineID(opts.Identifier, opts.AskList); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp machinegroup.SearchResponse
+
+	if err := c.klient().Call("machine.search", req, &resp); err != nil {
+		return nil, err
+	}
+
+	cancel = func() error {
+		cancelReq := &machinegroup.CancelSearchRequest{
+			CancelSearchRequest: fs.CancelSearchRequest{
+				ID: resp.ID,
+			},
+			MachineRequest: machinegroup.MachineRequest{
+				MachineID: req.MachineID,
+			},
+		}
+
+		if req.MachineID == "" {
+			cancelReq.Path = opts.Path
+		}
+
+		return c.klient().Call("machine.cancelSearch", cancelReq, nil)
+	}
+
+	return cancel, nil
+}
+
+// Search looks for file content that matches the given pattern on a remote
+// machine using DefaultClient.
+func Search(opts *SearchOptions) (cancel func() error, err error) { return DefaultClient.Search(opts) }
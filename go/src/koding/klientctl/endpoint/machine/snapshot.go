@@ -0,0 +1,102 @@
+package machine
+
+import (
+	"koding/kites/kloud/stack"
+)
+
+// SnapshotOptions represents available parameters for the snapshot methods.
+type SnapshotOptions struct {
+	Identifier string // Machine identifier.
+	SnapshotID string // Snapshot identifier, used by delete and restore.
+	Label      string // Snapshot label, used by create.
+
+	AskList func(is, ds []string) (string, error) // Ask for multiple choices.
+}
+
+// CreateSnapshot creates a snapshot of a vm given by the identifier.
+func (c *Client) CreateSnapshot(opts *SnapshotOptions) (string, error) {
+	return c.snapshotCall("machine.snapshot.create", opts)
+}
+
+// DeleteSnapshot deletes a snapshot of a vm given by the identifier.
+func (c *Client) DeleteSnapshot(opts *SnapshotOptions) (string, error) {
+	return c.snapshotCall("machine.snapshot.delete", opts)
+}
+
+// RestoreSnapshot restores a vm given by the identifier from the snapshot.
+func (c *Client) RestoreSnapshot(opts *SnapshotOptions) (string, error) {
+	return c.snapshotCall("machine.snapshot.restore", opts)
+}
+
+// ListSnapshots lists snapshots of a vm given by the identifier.
+func (c *Client) ListSnapshots(opts *SnapshotOptions) ([]*stack.Snapshot, error) {
+	req, err := c.snapshotRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp stack.SnapshotListResponse
+
+	if err := c.kloud().Call("machine.snapshot.list", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Snapshots, nil
+}
+
+func (c *Client) snapshotCall(method string, opts *SnapshotOptions) (string, error) {
+	req, err := c.snapshotRequest(opts)
+	if err != nil {
+		return "", err
+	}
+
+	var resp machineResp
+
+	if err := c.kloud().Call(method, req, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.EventId, nil
+}
+
+func (c *Client) snapshotRequest(opts *SnapshotOptions) (*stack.SnapshotRequest, error) {
+	c.init()
+
+	// Translate identifier to machine ID.
+	id, err := c.getMachineID(opts.Identifier, opts.AskList)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := c.machine(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stack.SnapshotRequest{
+		MachineID:  m.ID,
+		Provider:   *m.Provider,
+		SnapshotID: opts.SnapshotID,
+		Label:      opts.Label,
+	}, nil
+}
+
+// CreateSnapshot creates a snapshot of a vm using DefaultClient.
+func CreateSnapshot(opts *SnapshotOptions) (string, error) {
+	return DefaultClient.CreateSnapshot(opts)
+}
+
+// DeleteSnapshot deletes a snapshot of a vm using DefaultClient.
+func DeleteSnapshot(opts *SnapshotOptions) (string, error) {
+	return DefaultClient.DeleteSnapshot(opts)
+}
+
+// RestoreSnapshot restores a vm from the snapshot using DefaultClient.
+func RestoreSnapshot(opts *SnapshotOptions) (string, error) {
+	return DefaultClient.RestoreSnapshot(opts)
+}
+
+// ListSnapshots lists snapshots of a vm using DefaultClient.
+func ListSnapshots(opts *SnapshotOptions) ([]*stack.Snapshot, error) {
+	return DefaultClient.ListSnapshots(opts)
+}
@@ -2,11 +2,14 @@ package machine
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 
+	"koding/kites/kloud/stack"
+	"koding/klient/machine"
 	"koding/klient/machine/machinegroup"
 	"koding/klientctl/ssh"
 )
@@ -44,6 +47,17 @@ func (c *Client) SSH(options *SSHOptions) error {
 	}
 	var sshRes machinegroup.SSHResponse
 
+	// Prefer short-lived certificate signed by team authority. Public key is
+	// still sent, so it can be used when remote machine does not support
+	// certificates.
+	cert, certPath, err := c.sshCert(id, pubKey, privPath)
+	if err != nil {
+		c.stream().Log().Warning("Unable to get SSH certificate: %s", err)
+	} else {
+		sshReq.CAPublicKey = cert.CAPublicKey
+		sshReq.Principal = cert.Principal
+	}
+
 	if err := c.klient().Call("machine.ssh", sshReq, &sshRes); err != nil {
 		return err
 	}
@@ -57,9 +71,17 @@ func (c *Client) SSH(options *SSHOptions) error {
 		"-o", "ServerAliveCountMax=3",
 		"-o", "ConnectTimeout=7",
 		"-o", "ConnectionAttempts=1",
-		sshRes.Username + "@" + sshRes.Host,
 	}
 
+	if cert != nil {
+		args = append(args,
+			"-o", "CertificateFile="+certPath,
+			"-o", "ForwardAgent="+yesNo(cert.ForwardAgent),
+		)
+	}
+
+	args = append(args, sshRes.Username+"@"+sshRes.Host)
+
 	if sshRes.Port > 0 {
 		args = append(args, "-p", strconv.Itoa(sshRes.Port))
 	}
@@ -72,6 +94,42 @@ func (c *Client) SSH(options *SSHOptions) error {
 	return cmd.Run()
 }
 
+// sshCert requests a certificate for the given public key and saves it next
+// to the private key, so ssh can use it.
+func (c *Client) sshCert(id machine.ID, pubKey, privPath string) (*stack.SSHCertResponse, string, error) {
+	req := &stack.SSHCertRequest{
+		MachineID: string(id),
+		PublicKey: pubKey,
+	}
+	var resp stack.SSHCertResponse
+
+	// Certificates are signed by authority of the team the machine belongs
+	// to, which is stored by kloud of the machine's deployment.
+	kc, err := c.machineKloud(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := kc.Call("ssh.cert", req, &resp); err != nil {
+		return nil, "", err
+	}
+
+	certPath := privPath + "-cert.pub"
+	if err := ioutil.WriteFile(certPath, []byte(resp.Certificate+"\n"), 0600); err != nil {
+		return nil, "", err
+	}
+
+	return &resp, certPath, nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
 // sshGetKeyPath gets local public key in case we need to copy it to remote
 // machine. It also returns paths to public and private keys.
 func sshGetKeyPath() (pubKey, pubPath, privPath string, err error) {
@@ -0,0 +1,585 @@
+// Package offline provides a local cache of Koding resources, which
+// lets read-only kd commands work while Koding is unreachable.
+//
+// Values fetched from Koding are stored in kd.bolt together with
+// the time of the last update. When a request fails due to
+// connectivity problems, the cached value is used instead
+// and it is reported as stale.
+//
+// Write operations which fail due to connectivity problems are
+// queued and replayed only after user confirms them, once
+// Koding is reachable again.
+package offline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"koding/kites/config"
+	"koding/klient/storage"
+	"koding/klientctl/ctlcli"
+	"koding/klientctl/endpoint/kloud"
+
+	"github.com/koding/kite"
+)
+
+// DefaultClient is a default client used by package-level functions.
+var DefaultClient = &Client{}
+
+func init() {
+	ctlcli.CloseOnExit(DefaultClient)
+
+	Register("kloud", ReplayFunc(replayKloud))
+}
+
+// Entry represents a single cached value.
+type Entry struct {
+	Value     json.RawMessage `json:"value"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// StaleValue describes a cached value, which was used in place
+// of a fresh one as Koding was unreachable.
+type StaleValue struct {
+	Name      string    // name of the resource, e.g. "machines"
+	UpdatedAt time.Time // time of the last successful fetch
+	Err       error     // the connectivity error
+}
+
+// Op represents a write operation, which is queued until
+// Koding is reachable again.
+type Op struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`    // replayer name, e.g. "kloud"
+	Summary  string          `json:"summary"` // human-readable description
+	Args     json.RawMessage `json:"args"`
+	QueuedAt time.Time       `json:"queuedAt"`
+}
+
+// QueuedError is returned by endpoints, when a write operation
+// was queued instead of being executed.
+type QueuedError struct {
+	Op  *Op
+	Err error // the connectivity error
+}
+
+// Error implements the built-in error interface.
+func (e *QueuedError) Error() string {
+	return fmt.Sprintf("Koding is unreachable (%s); %s was queued as operation #%s, "+
+		`run "kd offline replay" once Koding is reachable to apply it`, e.Err, e.Op.Summary, e.Op.ID)
+}
+
+// Replayer executes queued operations.
+type Replayer interface {
+	Replay(op *Op) error
+}
+
+// ReplayFunc is an adapter, which allows ordinary functions
+// to be used as Replayers.
+type ReplayFunc func(*Op) error
+
+// Replay implements the Replayer interface.
+func (fn ReplayFunc) Replay(op *Op) error {
+	return fn(op)
+}
+
+var (
+	replayersMu sync.RWMutex
+	replayers   = make(map[string]Replayer)
+)
+
+// Register registers a replayer for operations of the given kind.
+func Register(kind string, r Replayer) {
+	replayersMu.Lock()
+	defer replayersMu.Unlock()
+
+	if _, dup := replayers[kind]; dup {
+		panic("offline: Register called twice for " + kind)
+	}
+
+	replayers[kind] = r
+}
+
+func replayer(kind string) (Replayer, error) {
+	replayersMu.RLock()
+	defer replayersMu.RUnlock()
+
+	r, ok := replayers[kind]
+	if !ok {
+		return nil, fmt.Errorf("no replayer registered for %q operations", kind)
+	}
+
+	return r, nil
+}
+
+// KloudCall represents arguments of a queued kloud method call.
+type KloudCall struct {
+	Method string      `json:"method"`
+	Arg    interface{} `json:"arg"`
+}
+
+func replayKloud(op *Op) error {
+	var call KloudCall
+
+	if err := json.Unmarshal(op.Args, &call); err != nil {
+		return err
+	}
+
+	return kloud.Call(call.Method, call.Arg, nil)
+}
+
+// Client is responsible for caching Koding resources and
+// queueing write operations.
+type Client struct {
+	Kloud *kloud.Client // if nil, kloud.DefaultClient is used
+	Cache *config.Cache // if nil, kd.bolt cache of the Kloud client is used
+
+	once    sync.Once // for c.init()
+	mu      sync.Mutex
+	entries map[string]*Entry
+	updated map[string]*Entry // entries fetched by this client
+	queue   []*Op
+	stale   []*StaleValue
+	online  bool
+}
+
+// IsOffline tells whether the given error was caused by
+// Koding being unreachable.
+func IsOffline(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case net.Error:
+		return true
+	case *kite.Error:
+		switch e.Type {
+		case "timeout", "sendError", "disconnect":
+			return true
+		}
+	}
+
+	s := err.Error()
+
+	for _, msg := range offlineErrors {
+		if strings.Contains(s, msg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var offlineErrors = []string{
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"network is unreachable",
+	"i/o timeout",
+	"no route to host",
+}
+
+// Fetch calls fn in order to read a fresh value of the named
+// resource into v.
+//
+// If fn succeeds, v is cached. If fn fails because Koding
+// is unreachable, a cached value is read into v instead and
+// the resource is reported by Stale method.
+//
+// The arg is used to distinguish values of the same resource
+// fetched with different filters.
+func (c *Client) Fetch(name string, arg, v interface{}, fn func() error) error {
+	c.init()
+
+	key, err := entryKey(name, arg)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.online = true
+
+		p, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		c.entries[key] = &Entry{
+			Value:     p,
+			UpdatedAt: time.Now(),
+		}
+		c.updated[key] = c.entries[key]
+
+		return nil
+	}
+
+	if !IsOffline(err) {
+		return err
+	}
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return err
+	}
+
+	if e := json.Unmarshal(entry.Value, v); e != nil {
+		return err
+	}
+
+	c.stale = append(c.stale, &StaleValue{
+		Name:      name,
+		UpdatedAt: entry.UpdatedAt,
+		Err:       err,
+	})
+
+	return nil
+}
+
+// Queue queues a write operation of the given kind.
+//
+// The summary is displayed to the user, when asking
+// for confirmation to replay the operation.
+//
+// The operation is stored in kd.bolt right away, so it
+// gets an ID unique among operations queued by
+// concurrently running kd processes.
+func (c *Client) Queue(kind, summary string, args interface{}) (*Op, error) {
+	c.init()
+
+	if _, err := replayer(kind); err != nil {
+		return nil, err
+	}
+
+	p, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var op *Op
+
+	err = c.updateQueue(func() error {
+		op = &Op{
+			ID:       c.nextID(),
+			Kind:     kind,
+			Summary:  summary,
+			Args:     p,
+			QueuedAt: time.Now(),
+		}
+
+		c.queue = append(c.queue, op)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// QueueIfOffline queues a write operation of the given kind,
+// if err was caused by Koding being unreachable.
+//
+// It returns *QueuedError if the operation was queued,
+// otherwise it returns the err unchanged.
+func (c *Client) QueueIfOffline(err error, kind, summary string, args interface{}) error {
+	if !IsOffline(err) {
+		return err
+	}
+
+	op, e := c.Queue(kind, summary, args)
+	if e != nil {
+		return err
+	}
+
+	return &QueuedError{
+		Op:  op,
+		Err: err,
+	}
+}
+
+// Pending gives queued operations, oldest first.
+func (c *Client) Pending() []*Op {
+	c.init()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ops := make([]*Op, len(c.queue))
+	copy(ops, c.queue)
+
+	return ops
+}
+
+// Replay executes the queued operation given by the id.
+//
+// The operation is removed from the queue after
+// it was successfully executed.
+func (c *Client) Replay(id string) error {
+	op, err := c.op(id)
+	if err != nil {
+		return err
+	}
+
+	r, err := replayer(op.Kind)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Replay(op); err != nil {
+		return err
+	}
+
+	return c.Drop(id)
+}
+
+// Drop removes the queued operation given by the id
+// without executing it.
+func (c *Client) Drop(id string) error {
+	c.init()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.updateQueue(func() error {
+		for i, op := range c.queue {
+			if op.ID == id {
+				c.queue = append(c.queue[:i], c.queue[i+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("operation #%s not found", id)
+	})
+}
+
+// Entries gives last update times of all cached values,
+// keyed by the resource name.
+func (c *Client) Entries() map[string]time.Time {
+	c.init()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make(map[string]time.Time)
+
+	for key, e := range c.entries {
+		name := entryName(key)
+
+		if t, ok := entries[name]; !ok || e.UpdatedAt.After(t) {
+			entries[name] = e.UpdatedAt
+		}
+	}
+
+	return entries
+}
+
+// Stale gives resources, which cached values were used
+// in place of fresh ones.
+func (c *Client) Stale() []*StaleValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale := make([]*StaleValue, len(c.stale))
+	copy(stale, c.stale)
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+
+	return stale
+}
+
+// Online tells whether any of the resources was
+// successfully fetched from Koding.
+func (c *Client) Online() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.online
+}
+
+// Close implements the io.Closer interface.
+//
+// It flushes values fetched by the client to kd.bolt, merging
+// them with values stored there by other kd processes
+// in the meantime - the most recent value is kept.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.updated) == 0 {
+		return nil
+	}
+
+	// Write access to kd.bolt is exclusive, no other kd
+	// process can modify the entries until we're done.
+	cache := c.writeCache()
+	entries := make(map[string]*Entry)
+
+	if err := cache.GetValue("offline.entries", &entries); err != nil && err != storage.ErrKeyNotFound {
+		return err
+	}
+
+	for key, e := range c.updated {
+		if cur, ok := entries[key]; !ok || e.UpdatedAt.After(cur.UpdatedAt) {
+			entries[key] = e
+		}
+	}
+
+	if err := cache.SetValue("offline.entries", entries); err != nil {
+		return err
+	}
+
+	c.updated = make(map[string]*Entry)
+
+	return nil
+}
+
+// updateQueue reads the queue stored in kd.bolt, applies fn to it
+// and stores it back, so changes made by concurrent kd processes
+// are not lost. Write access to kd.bolt is exclusive, thus no
+// other process can modify the queue in the meantime.
+func (c *Client) updateQueue(fn func() error) error {
+	cache := c.writeCache()
+
+	var queue []*Op
+
+	if err := cache.GetValue("offline.queue", &queue); err != nil && err != storage.ErrKeyNotFound {
+		return err
+	}
+
+	c.queue = queue
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return cache.SetValue("offline.queue", c.queue)
+}
+
+func (c *Client) op(id string) (*Op, error) {
+	c.init()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, op := range c.queue {
+		if op.ID == id {
+			return op, nil
+		}
+	}
+
+	return nil, fmt.Errorf("operation #%s not found", id)
+}
+
+func (c *Client) nextID() string {
+	var max uint64
+
+	for _, op := range c.queue {
+		if n, err := strconv.ParseUint(op.ID, 10, 64); err == nil && n > max {
+			max = n
+		}
+	}
+
+	return strconv.FormatUint(max+1, 10)
+}
+
+func (c *Client) init() {
+	c.once.Do(c.initClient)
+}
+
+func (c *Client) initClient() {
+	c.entries = make(map[string]*Entry)
+	c.updated = make(map[string]*Entry)
+
+	// Ignoring read error, if it's non-nil then empty cache is going to
+	// be used instead.
+	_ = c.readCache().GetValue("offline.entries", &c.entries)
+	_ = c.readCache().GetValue("offline.queue", &c.queue)
+}
+
+func (c *Client) readCache() *config.Cache {
+	if c.Cache != nil {
+		return c.Cache
+	}
+	return c.kloud().Cache().ReadOnly()
+}
+
+func (c *Client) writeCache() *config.Cache {
+	if c.Cache != nil {
+		return c.Cache
+	}
+	return c.kloud().Cache().ReadWrite()
+}
+
+func (c *Client) kloud() *kloud.Client {
+	if c.Kloud != nil {
+		return c.Kloud
+	}
+	return kloud.DefaultClient
+}
+
+func entryKey(name string, arg interface{}) (string, error) {
+	if arg == nil {
+		return name, nil
+	}
+
+	p, err := json.Marshal(arg)
+	if err != nil {
+		return "", errors.New("unable to build cache key: " + err.Error())
+	}
+
+	return name + ":" + string(p), nil
+}
+
+func entryName(key string) string {
+	if i := strings.IndexRune(key, ':'); i != -1 {
+		return key[:i]
+	}
+	return key
+}
+
+// Fetch calls fn in order to read a fresh value of the named
+// resource into v, falling back to a cached value if Koding
+// is unreachable.
+//
+// The function forwards the call to the DefaultClient.
+func Fetch(name string, arg, v interface{}, fn func() error) error {
+	return DefaultClient.Fetch(name, arg, v, fn)
+}
+
+// Queue queues a write operation of the given kind.
+//
+// The function forwards the call to the DefaultClient.
+func Queue(kind, summary string, args interface{}) (*Op, error) {
+	return DefaultClient.Queue(kind, summary, args)
+}
+
+// QueueIfOffline queues a write operation of the given kind,
+// if err was caused by Koding being unreachable.
+//
+// The function forwards the call to the DefaultClient.
+func QueueIfOffline(err error, kind, summary string, args interface{}) error {
+	return DefaultClient.QueueIfOffline(err, kind, summary, args)
+}
+
+func Pending() []*Op                { return DefaultClient.Pending() }
+func Replay(id string) error        { return DefaultClient.Replay(id) }
+func Drop(id string) error          { return DefaultClient.Drop(id) }
+func Entries() map[string]time.Time { return DefaultClient.Entries() }
+func Stale() []*StaleValue          { return DefaultClient.Stale() }
+func Online() bool                  { return DefaultClient.Online() }
@@ -0,0 +1,194 @@
+package offline_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"koding/kites/config"
+	"koding/klient/storage"
+	"koding/klientctl/endpoint/offline"
+)
+
+func newCache() *config.Cache {
+	return &config.Cache{
+		EncodingStorage: &storage.EncodingStorage{
+			Interface: storage.NewMemoryStorage(),
+		},
+	}
+}
+
+var errOffline = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func TestFetch(t *testing.T) {
+	cache := newCache()
+	c := &offline.Client{Cache: cache}
+
+	var teams []string
+
+	err := c.Fetch("teams", "koding", &teams, func() error {
+		teams = []string{"koding", "hackathon"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Fetch()=%s", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close()=%s", err)
+	}
+
+	// New client reads the values cached by the previous one.
+	c = &offline.Client{Cache: cache}
+
+	var cached []string
+
+	err = c.Fetch("teams", "koding", &cached, func() error { return errOffline })
+	if err != nil {
+		t.Fatalf("Fetch()=%s", err)
+	}
+
+	if len(cached) != 2 || cached[0] != "koding" {
+		t.Fatalf("got %v, want %v", cached, teams)
+	}
+
+	stale := c.Stale()
+
+	if len(stale) != 1 || stale[0].Name != "teams" || stale[0].Err != errOffline {
+		t.Fatalf("got %+v, want stale teams", stale)
+	}
+
+	if c.Online() {
+		t.Fatal("expected client to be offline")
+	}
+
+	// Values fetched with different arguments are not shared.
+	if err := c.Fetch("teams", "other", &cached, func() error { return errOffline }); err != errOffline {
+		t.Fatalf("got %v, want %v", err, errOffline)
+	}
+
+	// Errors not caused by connectivity problems are not masked.
+	errAuth := errors.New("authentication failed")
+
+	if err := c.Fetch("teams", "koding", &cached, func() error { return errAuth }); err != errAuth {
+		t.Fatalf("got %v, want %v", err, errAuth)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var replayed []string
+
+	offline.Register("test", offline.ReplayFunc(func(op *offline.Op) error {
+		replayed = append(replayed, op.ID)
+		return nil
+	}))
+
+	c := &offline.Client{Cache: newCache()}
+
+	if err := c.QueueIfOffline(errors.New("not found"), "test", "noop", nil); err == nil || err.Error() != "not found" {
+		t.Fatalf("got %v, want the original error", err)
+	}
+
+	err := c.QueueIfOffline(errOffline, "test", "deleting \"foo\" template", "foo")
+
+	qe, ok := err.(*offline.QueuedError)
+	if !ok {
+		t.Fatalf("got %T, want *offline.QueuedError", err)
+	}
+
+	if _, err := c.Queue("test", "deleting \"bar\" template", "bar"); err != nil {
+		t.Fatalf("Queue()=%s", err)
+	}
+
+	if _, err := c.Queue("unknown", "noop", nil); err == nil {
+		t.Fatal("expected Queue to fail for unknown operation kind")
+	}
+
+	pending := c.Pending()
+
+	if len(pending) != 2 || pending[0].ID != qe.Op.ID || pending[0].ID == pending[1].ID {
+		t.Fatalf("got %+v, want 2 operations", pending)
+	}
+
+	if err := c.Replay(pending[0].ID); err != nil {
+		t.Fatalf("Replay()=%s", err)
+	}
+
+	if err := c.Drop(pending[1].ID); err != nil {
+		t.Fatalf("Drop()=%s", err)
+	}
+
+	if len(replayed) != 1 || replayed[0] != pending[0].ID {
+		t.Fatalf("got %v, want only %q replayed", replayed, pending[0].ID)
+	}
+
+	if n := len(c.Pending()); n != 0 {
+		t.Fatalf("got %d pending operations, want 0", n)
+	}
+}
+
+func TestConcurrentClients(t *testing.T) {
+	offline.Register("concurrent", offline.ReplayFunc(func(*offline.Op) error { return nil }))
+
+	cache := newCache()
+
+	// Both clients read kd.bolt before any of them wrote to it,
+	// like kd processes run simultaneously.
+	c1 := &offline.Client{Cache: cache}
+	c2 := &offline.Client{Cache: cache}
+
+	c1.Pending()
+	c2.Pending()
+
+	op1, err := c1.Queue("concurrent", "deleting \"foo\" template", "foo")
+	if err != nil {
+		t.Fatalf("Queue()=%s", err)
+	}
+
+	op2, err := c2.Queue("concurrent", "deleting \"bar\" template", "bar")
+	if err != nil {
+		t.Fatalf("Queue()=%s", err)
+	}
+
+	if op1.ID == op2.ID {
+		t.Fatalf("got duplicated operation ID: %q", op1.ID)
+	}
+
+	if err := c1.Drop(op1.ID); err != nil {
+		t.Fatalf("Drop()=%s", err)
+	}
+
+	var teams []string
+
+	fetch := func(c *offline.Client, team string) {
+		err := c.Fetch("teams", team, &teams, func() error {
+			teams = []string{team}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Fetch()=%s", err)
+		}
+	}
+
+	fetch(c1, "koding")
+	fetch(c2, "hackathon")
+
+	for _, c := range []*offline.Client{c2, c1} {
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close()=%s", err)
+		}
+	}
+
+	c := &offline.Client{Cache: cache}
+
+	if pending := c.Pending(); len(pending) != 1 || pending[0].ID != op2.ID {
+		t.Fatalf("got %+v, want only %q operation", pending, op2.ID)
+	}
+
+	for _, team := range []string{"koding", "hackathon"} {
+		err := c.Fetch("teams", team, &teams, func() error { return errOffline })
+		if err != nil {
+			t.Fatalf("%s: Fetch()=%s", team, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package remoteapi
 
 import (
+	"fmt"
+
 	"koding/remoteapi"
 	"koding/remoteapi/models"
 
@@ -12,26 +14,29 @@ import (
 func (c *Client) ListMachines(f *Filter) ([]*models.JMachine, error) {
 	c.init()
 
-	params := &machine.JMachineSomeParams{}
+	var machines []*models.JMachine
 
-	if f != nil {
-		if err := c.buildFilter(f); err != nil {
-			return nil, err
-		}
+	err := c.offline().Fetch("machines", f, &machines, func() error {
+		params := &machine.JMachineSomeParams{}
 
-		params.Body = f
-	}
+		if f != nil {
+			if err := c.buildFilter(f); err != nil {
+				return err
+			}
 
-	params.SetTimeout(c.timeout())
+			params.Body = f
+		}
 
-	resp, err := c.client().JMachine.JMachineSome(params, nil)
-	if err != nil {
-		return nil, err
-	}
+		params.SetTimeout(c.timeout())
 
-	var machines []*models.JMachine
+		resp, err := c.client().JMachine.JMachineSome(params, nil)
+		if err != nil {
+			return err
+		}
 
-	if err := remoteapi.Unmarshal(resp.Payload, &machines); err != nil {
+		return remoteapi.Unmarshal(resp.Payload, &machines)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -43,9 +48,26 @@ func (c *Client) ListMachines(f *Filter) ([]*models.JMachine, error) {
 }
 
 // UpdateMachineAlwaysOn updates JMachine.meta.alwaysOn using ComputeProvider.
+//
+// If Koding is unreachable, the operation is queued
+// and *offline.QueuedError is returned.
 func (c *Client) UpdateMachineAlwaysOn(m *models.JMachine, on bool) error {
 	c.init()
 
+	err := c.updateMachineAlwaysOn(m, on)
+
+	return c.offline().QueueIfOffline(err, "machine.alwaysOn", fmt.Sprintf("setting alwaysOn=%t for %q machine", on, m.Label), &alwaysOn{
+		Machine: m,
+		On:      on,
+	})
+}
+
+type alwaysOn struct {
+	Machine *models.JMachine `json:"machine"`
+	On      bool             `json:"on"`
+}
+
+func (c *Client) updateMachineAlwaysOn(m *models.JMachine, on bool) error {
 	params := &computeprovider.ComputeProviderUpdateParams{
 		Body: map[string]interface{}{
 			"machineId":  m.ID,
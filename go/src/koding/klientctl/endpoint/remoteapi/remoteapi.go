@@ -1,6 +1,7 @@
 package remoteapi
 
 import (
+	"encoding/json"
 	"errors"
 	"sync"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"koding/klientctl/endpoint"
 	"koding/klientctl/endpoint/auth"
 	"koding/klientctl/endpoint/kloud"
+	"koding/klientctl/endpoint/offline"
 	"koding/klientctl/endpoint/team"
 	"koding/remoteapi"
 	"koding/remoteapi/client"
@@ -31,6 +33,34 @@ var DefaultClient = &Client{}
 func init() {
 	// Ensure DefaultClient is closed on exit.
 	ctlcli.CloseOnExit(DefaultClient)
+
+	// Replay operations queued while Koding was unreachable.
+	offline.Register("template.delete", offline.ReplayFunc(replayDeleteTemplate))
+	offline.Register("machine.alwaysOn", offline.ReplayFunc(replayMachineAlwaysOn))
+}
+
+func replayDeleteTemplate(op *offline.Op) error {
+	var id string
+
+	if err := json.Unmarshal(op.Args, &id); err != nil {
+		return err
+	}
+
+	DefaultClient.init()
+
+	return DefaultClient.deleteTemplate(id)
+}
+
+func replayMachineAlwaysOn(op *offline.Op) error {
+	var arg alwaysOn
+
+	if err := json.Unmarshal(op.Args, &arg); err != nil {
+		return err
+	}
+
+	DefaultClient.init()
+
+	return DefaultClient.updateMachineAlwaysOn(arg.Machine, arg.On)
 }
 
 // DefaultTimeout defines max remote.api request time,
@@ -40,10 +70,11 @@ var DefaultTimeout = 30 * time.Second
 // Client is a wrapper for remote.api client that takes
 // care of authorization and caching.
 type Client struct {
-	Kloud  *kloud.Client  // if nil, kloud.DefaultClient is used
-	Auth   *auth.Client   // if nil, auth.DefaultClient is used
-	Team   *team.Client   // if nil, team.DefaultClient is used
-	Client *client.Koding // if nil, new client is created (with kloud as auth provider)
+	Kloud   *kloud.Client   // if nil, kloud.DefaultClient is used
+	Auth    *auth.Client    // if nil, auth.DefaultClient is used
+	Team    *team.Client    // if nil, team.DefaultClient is used
+	Client  *client.Koding  // if nil, new client is created (with kloud as auth provider)
+	Offline *offline.Client // if nil, offline.DefaultClient is used
 
 	once     sync.Once // for c.init()
 	api      *remoteapi.Client
@@ -162,6 +193,13 @@ func (c *Client) kloud() *kloud.Client {
 	return kloud.DefaultClient
 }
 
+func (c *Client) offline() *offline.Client {
+	if c.Offline != nil {
+		return c.Offline
+	}
+	return offline.DefaultClient
+}
+
 func (c *Client) team() *team.Client {
 	if c.Team != nil {
 		return c.Team
@@ -11,26 +11,29 @@ import (
 func (c *Client) ListStacks(f *Filter) ([]*models.JComputeStack, error) {
 	c.init()
 
-	params := &computestack.JComputeStackSomeParams{}
+	var stacks []*models.JComputeStack
 
-	if f != nil {
-		if err := c.buildFilter(f); err != nil {
-			return nil, err
-		}
+	err := c.offline().Fetch("stacks", f, &stacks, func() error {
+		params := &computestack.JComputeStackSomeParams{}
 
-		params.Body = f
-	}
+		if f != nil {
+			if err := c.buildFilter(f); err != nil {
+				return err
+			}
 
-	params.SetTimeout(c.timeout())
+			params.Body = f
+		}
 
-	resp, err := c.client().JComputeStack.JComputeStackSome(params, nil)
-	if err != nil {
-		return nil, err
-	}
+		params.SetTimeout(c.timeout())
 
-	var stacks []*models.JComputeStack
+		resp, err := c.client().JComputeStack.JComputeStackSome(params, nil)
+		if err != nil {
+			return err
+		}
 
-	if err := remoteapi.Unmarshal(resp.Payload, &stacks); err != nil {
+		return remoteapi.Unmarshal(resp.Payload, &stacks)
+	})
+	if err != nil {
 		return nil, err
 	}
 
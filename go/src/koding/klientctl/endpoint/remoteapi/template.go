@@ -34,26 +34,29 @@ type Filter struct {
 func (c *Client) ListTemplates(f *Filter) ([]*models.JStackTemplate, error) {
 	c.init()
 
-	params := &stacktemplate.JStackTemplateSomeParams{}
+	var templates []*models.JStackTemplate
 
-	if f != nil {
-		if err := c.buildFilter(f); err != nil {
-			return nil, err
-		}
+	err := c.offline().Fetch("templates", f, &templates, func() error {
+		params := &stacktemplate.JStackTemplateSomeParams{}
 
-		params.Body = f
-	}
+		if f != nil {
+			if err := c.buildFilter(f); err != nil {
+				return err
+			}
 
-	params.SetTimeout(c.timeout())
+			params.Body = f
+		}
 
-	resp, err := c.client().JStackTemplate.JStackTemplateSome(params, nil)
-	if err != nil {
-		return nil, err
-	}
+		params.SetTimeout(c.timeout())
 
-	var templates []*models.JStackTemplate
+		resp, err := c.client().JStackTemplate.JStackTemplateSome(params, nil)
+		if err != nil {
+			return err
+		}
 
-	if err := remoteapi.Unmarshal(resp.Payload, &templates); err != nil {
+		return remoteapi.Unmarshal(resp.Payload, &templates)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -65,9 +68,18 @@ func (c *Client) ListTemplates(f *Filter) ([]*models.JStackTemplate, error) {
 }
 
 // DeleteTemplate deletes a template given by the id.
+//
+// If Koding is unreachable, the operation is queued
+// and *offline.QueuedError is returned.
 func (c *Client) DeleteTemplate(id string) error {
 	c.init()
 
+	err := c.deleteTemplate(id)
+
+	return c.offline().QueueIfOffline(err, "template.delete", fmt.Sprintf("deleting %q template", id), id)
+}
+
+func (c *Client) deleteTemplate(id string) error {
 	params := &stacktemplate.JStackTemplateDeleteParams{
 		ID: id,
 	}
@@ -9,6 +9,7 @@ import (
 	"koding/kites/kloud/stack"
 	kloudstack "koding/kites/kloud/stack"
 	"koding/kites/kloud/utils/object"
+	"koding/kites/tracing"
 	"koding/klientctl/endpoint/credential"
 	"koding/klientctl/endpoint/kloud"
 	"koding/klientctl/endpoint/team"
@@ -22,6 +23,10 @@ type CreateOptions struct {
 	Title       string
 	Credentials []string
 	Template    []byte
+
+	// Trace, when non-nil, is continued by Kloud while
+	// building the stack.
+	Trace *tracing.SpanContext
 }
 
 func (opts *CreateOptions) Valid() error {
@@ -67,6 +72,7 @@ func (c *Client) Create(opts *CreateOptions) (*stack.ImportResponse, error) {
 		Team:        opts.Team,
 		Title:       opts.Title,
 		Credentials: make(map[string][]string),
+		Trace:       opts.Trace,
 	}
 
 	if req.Team == "" {
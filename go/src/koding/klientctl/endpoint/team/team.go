@@ -8,6 +8,7 @@ import (
 	"koding/kites/kloud/team"
 	"koding/klientctl/ctlcli"
 	"koding/klientctl/endpoint/kloud"
+	"koding/klientctl/endpoint/offline"
 )
 
 var DefaultClient = &Client{}
@@ -33,7 +34,8 @@ func (t *Team) Valid() error {
 }
 
 type Client struct {
-	Kloud *kloud.Client
+	Kloud   *kloud.Client
+	Offline *offline.Client // if nil, offline.DefaultClient is used
 
 	once sync.Once // for c.init()
 	used Team
@@ -61,7 +63,10 @@ func (c *Client) List(opts *ListOptions) ([]*team.Team, error) {
 	}
 
 	resp := stack.TeamListResponse{}
-	if err := c.kloud().Call("team.list", req, &resp); err != nil {
+	err := c.offline().Fetch("teams", req, &resp, func() error {
+		return c.kloud().Call("team.list", req, &resp)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -105,6 +110,13 @@ func (c *Client) kloud() *kloud.Client {
 	return kloud.DefaultClient
 }
 
+func (c *Client) offline() *offline.Client {
+	if c.Offline != nil {
+		return c.Offline
+	}
+	return offline.DefaultClient
+}
+
 func Use(team *Team)                               { DefaultClient.Use(team) }
 func Used() *Team                                  { return DefaultClient.Used() }
 func List(opts *ListOptions) ([]*team.Team, error) { return DefaultClient.List(opts) }
@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/signal"
 
+	"koding/kites/config/configstore"
+	"koding/kites/tracing"
 	"koding/klientctl/commands"
 	"koding/klientctl/commands/cli"
+	"koding/klientctl/commands/offline"
 	"koding/klientctl/config"
 	"koding/klientctl/ctlcli"
 	"koding/klientctl/endpoint/kloud"
@@ -41,14 +44,62 @@ func main() {
 
 	kloud.DefaultLog = c.Log()
 
-	if err := commands.NewKdCommand(c).Execute(); err != nil {
+	setupProfile(c)
+	setupTracing(c)
+
+	err := commands.NewKdCommand(c).Execute()
+
+	offline.Notify(c)
+
+	if err != nil {
+		tracing.DefaultTracer.Close()
 		c.Close()
 		os.Exit(cli.ExitCodeFromError(err))
 	}
 
+	tracing.DefaultTracer.Close()
 	c.Close()
 }
 
+// setupProfile switches to a configuration profile, when a .kd.yml
+// file is found in the working directory or any of its parents.
+func setupProfile(c *cli.CLI) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+
+	configstore.DefaultClient.Dir = wd
+
+	switch file, p, err := configstore.DefaultClient.Profile(); {
+	case err == nil:
+		c.Log().Debug("using %q profile from %s", p.Profile, file)
+
+		config.Konfig = configstore.Read(config.Environments)
+	case !os.IsNotExist(err):
+		c.Log().Warning("unable to read profile: %s", err)
+	}
+}
+
+// setupTracing enables exporting spans of kd requests when KD_TRACE
+// is set to either a file path or a collector URL.
+func setupTracing(c *cli.CLI) {
+	target := os.Getenv("KD_TRACE")
+	if target == "" {
+		return
+	}
+
+	e, err := tracing.NewExporter(target)
+	if err != nil {
+		c.Log().Warning("unable to export traces to %q: %s", target, err)
+		return
+	}
+
+	tracing.DefaultTracer.Service = config.Name
+	tracing.DefaultTracer.Exporter = e
+	tracing.DefaultTracer.Log = c.Log().Warning
+}
+
 var signals = []os.Signal{
 	os.Interrupt,
 	os.Kill,
@@ -231,6 +231,15 @@ func (l *Uploader) log() logging.Logger {
 	return defaultLog
 }
 
+// Rotate creates a metadata part that describes content that was not yet
+// streamed according to the given meta, and seeks content to the part's
+// offset. The returned part is not appended to meta.
+//
+// If there's nothing new to stream, Rotate returns *NopError.
+func Rotate(content io.ReadSeeker, meta *Metadata) (*MetadataPart, error) {
+	return rotate(content, meta)
+}
+
 func rotate(content io.ReadSeeker, meta *Metadata) (part *MetadataPart, err error) {
 	part = &MetadataPart{
 		CreatedAt: time.Now(),
@@ -68,6 +68,21 @@ type (
 		ForcedRecipientUsername string `env:"key=KONFIG_SOCIALAPI_EMAIL_FORCEDRECIPIENTUSERNAME"`
 		Username                string `env:"key=KONFIG_SOCIALAPI_EMAIL_USERNAME                 required"`
 		Password                string `env:"key=KONFIG_SOCIALAPI_EMAIL_PASSWORD                 required"`
+
+		// Sender selects the outbound mail sender, one of "mailgun"
+		// (default), "smtp" or "maildir".
+		Sender string `env:"key=KONFIG_SOCIALAPI_EMAIL_SENDER"`
+
+		// SMTPAddr is the host:port of the SMTP server used by "smtp" sender.
+		SMTPAddr string `env:"key=KONFIG_SOCIALAPI_EMAIL_SMTPADDR"`
+
+		// MaildirPath is the directory "maildir" sender writes mails to.
+		MaildirPath string `env:"key=KONFIG_SOCIALAPI_EMAIL_MAILDIRPATH"`
+
+		// AuthServID is the authserv-id of the inbound mail server, only
+		// Authentication-Results and Received-SPF headers added by it are
+		// trusted. Inbound mail handlers refuse to start without it.
+		AuthServID string `env:"key=KONFIG_SOCIALAPI_EMAIL_AUTHSERVID"`
 	}
 
 	// Mixpanel holds mixpanel credentials
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "presence"."presence_session_group_name_started_at_idx";
+DROP INDEX IF EXISTS "presence"."presence_session_group_name_acc_id_source_ended_at_idx";
+DROP TABLE IF EXISTS "presence"."session";
+
+DROP SEQUENCE "presence"."session_id_seq";
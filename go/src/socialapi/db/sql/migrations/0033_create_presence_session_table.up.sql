@@ -0,0 +1,48 @@
+--
+-- create the sequence
+--
+
+DO $$
+  BEGIN
+    BEGIN
+      CREATE SEQUENCE "presence"."session_id_seq" INCREMENT 1 START 1 MAXVALUE 9223372036854775807 MINVALUE 1 CACHE 1;
+    EXCEPTION WHEN duplicate_table THEN
+    END;
+  END;
+$$;
+
+GRANT USAGE ON SEQUENCE "presence"."session_id_seq" TO "social";
+
+
+--
+-- create session table for storing activity periods of accounts
+--
+CREATE TABLE IF NOT EXISTS "presence"."session" (
+    "id" BIGINT NOT NULL DEFAULT nextval('presence.session_id_seq'::regclass),
+    "account_id" BIGINT NOT NULL,
+    "group_name" VARCHAR (200) NOT NULL CHECK ("group_name" <> ''),
+    "source" VARCHAR (20) NOT NULL DEFAULT 'web',
+    "started_at" timestamp(6) WITH TIME ZONE NOT NULL DEFAULT now(),
+    "ended_at" timestamp(6) WITH TIME ZONE NOT NULL DEFAULT now(),
+    "ping_count" INTEGER NOT NULL DEFAULT 1,
+
+    -- create constraints along with table creation
+    PRIMARY KEY ("id") NOT DEFERRABLE INITIALLY IMMEDIATE
+) WITH (OIDS = FALSE);
+GRANT SELECT, INSERT, UPDATE, DELETE ON "presence"."session" TO "social";
+
+DO $$
+  BEGIN
+    CREATE INDEX  "presence_session_group_name_acc_id_source_ended_at_idx" ON presence.session USING btree(group_name DESC, account_id DESC, source DESC, ended_at DESC);
+  EXCEPTION WHEN duplicate_table THEN
+    RAISE NOTICE 'presence_session_group_name_acc_id_source_ended_at_idx already exists';
+  END;
+$$;
+
+DO $$
+  BEGIN
+    CREATE INDEX  "presence_session_group_name_started_at_idx" ON presence.session USING btree(group_name DESC, started_at DESC);
+  EXCEPTION WHEN duplicate_table THEN
+    RAISE NOTICE 'presence_session_group_name_started_at_idx already exists';
+  END;
+$$;
@@ -16,8 +16,12 @@ const (
 	SlackTeamInfo      = "slack-team-information"
 	SlackPostMessage   = "slack-post-message"
 	SlackSlashCommand  = "slack-slash-command"
+	SlackEvents        = "slack-events"
+	SlackGetBridge     = "slack-get-bridge"
+	SlackUpdateBridge  = "slack-update-bridge"
 	SlackOauthCallback = "slack-oauth-callback"
 	SlackOauthSuccess  = "slack-oauth-succeess"
 	SlackOauthSend     = "slack-oauth-send"
 	MailPublishEvent   = "mail-publish-event"
+	MailMailgunWebhook = "mail-mailgun-webhook"
 )
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/koding/bongo"
+)
+
+// Sources of the presence pings.
+const (
+	// PresenceSourceWeb is used for pings sent by the browser
+	PresenceSourceWeb = "web"
+
+	// PresenceSourceMachine is used for pings sent on behalf of the user's
+	// machines, eg: by kloud while klient is being used
+	PresenceSourceMachine = "machine"
+)
+
+// Intervals that active accounts can be counted in.
+const (
+	PresenceIntervalDay  = "day"
+	PresenceIntervalWeek = "week"
+)
+
+// ErrInvalidPresenceInterval is returned when active accounts are counted in
+// an unknown interval
+var ErrInvalidPresenceInterval = errors.New("interval should be either day or week")
+
+// PresenceSession holds a continuous activity period of an account
+type PresenceSession struct {
+	// Id unique identifier of the session
+	Id int64 `json:"id,string"`
+
+	// AccountId holds the active users info
+	AccountId int64 `json:"accountId,string"          sql:"NOT NULL"`
+
+	// Name of the group
+	GroupName string `json:"groupName"                sql:"NOT NULL;TYPE:VARCHAR(200);"`
+
+	// Source of the pings of the session, web or machine
+	Source string `json:"source"                      sql:"NOT NULL;TYPE:VARCHAR(20);"`
+
+	// StartedAt holds the time of the first ping
+	StartedAt time.Time `json:"startedAt"             sql:"NOT NULL"`
+
+	// EndedAt holds the time of the last ping
+	EndedAt time.Time `json:"endedAt"                 sql:"NOT NULL"`
+
+	// PingCount holds the number of pings received during the session
+	PingCount int `json:"pingCount"`
+}
+
+// ActiveCount holds the number of distinct active accounts in an interval
+type ActiveCount struct {
+	Date  time.Time `json:"date"`
+	Count int       `json:"count"`
+}
+
+// Duration returns the length of the session
+func (a *PresenceSession) Duration() time.Duration {
+	return a.EndedAt.Sub(a.StartedAt)
+}
+
+// FetchLast fetches the latest session of the account for the given source
+func (a *PresenceSession) FetchLast(groupName string, accountId int64, source string) error {
+	q := &bongo.Query{
+		Selector: map[string]interface{}{
+			"group_name": groupName,
+			"account_id": accountId,
+			"source":     source,
+		},
+		Sort: map[string]string{
+			"ended_at": "DESC",
+		},
+	}
+
+	return a.One(q)
+}
+
+// Track extends the latest session of the account for the given source with
+// a ping at time t if continues reports that the ping belongs to it, or starts
+// a new session otherwise. Pings of the same account are tracked under an
+// advisory lock, so concurrent pings can not start overlapping sessions.
+func (a *PresenceSession) Track(groupName string, accountId int64, source string, t time.Time, continues func(*PresenceSession) bool) (err error) {
+	tx := bongo.B.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	key := fmt.Sprintf("%s:%s:%d:%s", a.BongoName(), groupName, accountId, source)
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+		return err
+	}
+
+	err = tx.Table(a.BongoName()).
+		Where("group_name = ? AND account_id = ? AND source = ?", groupName, accountId, source).
+		Order("ended_at DESC").
+		First(a).Error
+	if err != nil && err != bongo.RecordNotFound {
+		return err
+	}
+
+	if err == nil && continues(a) {
+		if t.After(a.EndedAt) {
+			a.EndedAt = t
+		}
+		a.PingCount++
+	} else {
+		*a = *NewPresenceSession()
+		a.GroupName = groupName
+		a.AccountId = accountId
+		a.Source = source
+		a.StartedAt = t
+		a.EndedAt = t
+	}
+
+	if err := tx.Table(a.BongoName()).Save(a).Error; err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// FetchInRange fetches the sessions of the group that overlap with the given
+// time range, if accountId is not zero only the sessions of that account are
+// fetched
+func (a *PresenceSession) FetchInRange(groupName string, accountId int64, from, to time.Time) ([]PresenceSession, error) {
+	query := bongo.B.DB.
+		Table(a.BongoName()).
+		Model(&PresenceSession{}).
+		Where("group_name = ? and started_at < ? and ended_at >= ?", groupName, to, from)
+
+	if accountId != 0 {
+		query = query.Where("account_id = ?", accountId)
+	}
+
+	sessions := make([]PresenceSession, 0)
+	if err := query.Order("started_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// CountActive counts distinct active accounts of the group for each day or
+// week in the given time range. An account is active in an interval if any
+// of its sessions overlaps with it.
+func (a *PresenceSession) CountActive(groupName, interval string, from, to time.Time) ([]ActiveCount, error) {
+	if interval != PresenceIntervalDay && interval != PresenceIntervalWeek {
+		return nil, ErrInvalidPresenceInterval
+	}
+
+	sql := `SELECT w.start AS date, count(distinct s.account_id) AS count
+		FROM generate_series(date_trunc('` + interval + `', ?::timestamptz), ?::timestamptz, '1 ` + interval + `') AS w(start)
+		JOIN ` + a.BongoName() + ` s ON s.group_name = ?
+			AND s.started_at < LEAST(w.start + interval '1 ` + interval + `', ?::timestamptz)
+			AND (s.ended_at IS NULL OR s.ended_at > GREATEST(w.start, ?::timestamptz))
+		WHERE w.start < ?::timestamptz
+		GROUP BY w.start ORDER BY w.start`
+
+	res := make([]ActiveCount, 0)
+	err := bongo.B.DB.
+		Raw(sql, from, to, groupName, to, from, to).
+		Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteByGroupName deletes items by their group's name
+func (a *PresenceSession) DeleteByGroupName(groupName string) error {
+	sql := "DELETE FROM " + a.BongoName() + " WHERE group_name = ?"
+	return bongo.B.DB.Exec(sql, groupName).Error
+}
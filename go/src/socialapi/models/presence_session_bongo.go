@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/koding/bongo"
+)
+
+// NewPresenceSession create new PresenceSession item
+func NewPresenceSession() *PresenceSession {
+	now := time.Now().UTC()
+
+	return &PresenceSession{
+		Source:    PresenceSourceWeb,
+		StartedAt: now,
+		EndedAt:   now,
+		PingCount: 1,
+	}
+}
+
+// GetId returns the id
+func (a PresenceSession) GetId() int64 {
+	return a.Id
+}
+
+// BongoName returns the unique name for the bongo operations
+func (a PresenceSession) BongoName() string {
+	return "presence.session"
+}
+
+// One fetches the item from db
+func (a *PresenceSession) One(q *bongo.Query) error {
+	return bongo.B.One(a, a, q)
+}
+
+// Delete deletes the item from db
+func (a *PresenceSession) Delete() error {
+	return bongo.B.Delete(a)
+}
+
+// Create inserts into db
+func (a *PresenceSession) Create() error {
+	return bongo.B.Create(a)
+}
+
+// Update updates the item in db
+func (a *PresenceSession) Update() error {
+	return bongo.B.Update(a)
+}
+
+// Some fetches items from db
+func (a *PresenceSession) Some(data interface{}, q *bongo.Query) error {
+	return bongo.B.Some(a, data, q)
+}
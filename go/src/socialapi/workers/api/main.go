@@ -61,7 +61,9 @@ func main() {
 	handlers.AddHandlers(m)
 	collaboration.AddHandlers(m, mgoCache)
 	paymentapi.AddHandlers(m)
-	mailapi.AddHandlers(m)
+	if err := mailapi.AddHandlers(m, c); err != nil {
+		log.Fatal(err)
+	}
 	account.AddHandlers(m)
 	channel.AddHandlers(m)
 	client.AddHandlers(m)
@@ -74,7 +76,7 @@ func main() {
 	presenceapi.AddHandlers(m)
 	slackapi.AddHandlers(m, c)
 	credential.AddHandlers(m, r.Log, c)
-	emailapi.AddHandlers(m)
+	emailapi.AddHandlers(m, c)
 	countlyapi.AddHandlers(m, c)
 
 	mmdb, err := helper.ReadGeoIPDB(c)
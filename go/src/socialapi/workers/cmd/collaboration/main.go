@@ -6,6 +6,7 @@ import (
 	"socialapi/config"
 	"socialapi/workers/collaboration"
 	"socialapi/workers/collaboration/models"
+	"time"
 
 	"github.com/koding/broker"
 	"github.com/koding/cache"
@@ -38,12 +39,22 @@ func main() {
 	modelhelper.Initialize(appConfig.Mongo)
 	defer modelhelper.Close()
 
+	if err := modelhelper.EnsureCollaborationIndexes(); err != nil {
+		r.Log.Fatal("couldnt ensure collaboration indexes %# v", err)
+	}
+
 	// init with defaults & ensure expireAt index
 	mongoCache := cache.NewMongoCacheWithTTL(modelhelper.Mongo.Session, cache.StartGC(), cache.MustEnsureIndexExpireAt())
 	defer mongoCache.StopGC()
 
 	handler := collaboration.New(r.Log, mongoCache, appConfig, r.Kite)
 	r.SetContext(handler)
+
+	// end suspended sessions that are not resumed by any participant
+	done := make(chan struct{})
+	defer close(done)
+	go handler.SweepSuspended(time.Minute, done)
+
 	// only listen and operate on collaboration ping messages that are fired by the handler
 	r.Register(models.Ping{}).On(collaboration.FireEventName).Handle((*collaboration.Controller).Ping)
 	r.Listen()
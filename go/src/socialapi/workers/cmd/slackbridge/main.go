@@ -0,0 +1,39 @@
+// Package main runs the worker that mirrors bridged channels to Slack
+package main
+
+import (
+	"koding/db/mongodb/modelhelper"
+	"log"
+	"socialapi/config"
+	"socialapi/models"
+	"socialapi/workers/slack/bridge"
+
+	"github.com/koding/runner"
+)
+
+var (
+	// Name holds the worker name
+	Name = "SlackBridge"
+)
+
+func main() {
+	r := runner.New(Name)
+	if err := r.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// init mongo connection
+	appConfig := config.MustRead(r.Conf.Path)
+	modelhelper.Initialize(appConfig.Mongo)
+	defer modelhelper.Close()
+
+	if err := modelhelper.EnsureSlackMessageLinkIndexes(); err != nil {
+		r.Log.Fatal("couldnt ensure slack message link indexes %# v", err)
+	}
+
+	r.SetContext(bridge.New(r.Log, appConfig))
+	r.Register(models.ChannelMessage{}).OnCreate().Handle((*bridge.Controller).MessageCreated)
+	r.Register(models.MessageReply{}).OnCreate().Handle((*bridge.Controller).ReplyCreated)
+	r.Listen()
+	r.Wait()
+}
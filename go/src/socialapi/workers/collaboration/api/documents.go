@@ -0,0 +1,235 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	mongomodels "koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"net/http"
+	"net/url"
+	apimodels "socialapi/models"
+	"socialapi/workers/api/realtimehelper"
+	"socialapi/workers/collaboration"
+	"socialapi/workers/collaboration/models"
+	"socialapi/workers/collaboration/ot"
+	"socialapi/workers/common/response"
+	"strconv"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// OperationEventName is the realtime event that operations are broadcasted
+// with to the collaboration channel
+const OperationEventName = "CollaborationOperation"
+
+// DocRequest holds the collaboration document creation request
+type DocRequest struct {
+	FileId    string `json:"fileId"`
+	ChannelId int64  `json:"channelId,string"`
+	Content   string `json:"content"`
+}
+
+// OperationRequest holds an operation that a client created at Revision
+type OperationRequest struct {
+	Revision  int          `json:"revision"`
+	Operation ot.Operation `json:"operation"`
+}
+
+// OperationResponse holds an operation applied to the document, Revision is
+// the revision of the document after the operation
+type OperationResponse struct {
+	FileId    string       `json:"fileId"`
+	Revision  int          `json:"revision"`
+	AccountId int64        `json:"accountId,string"`
+	Operation ot.Operation `json:"operation"`
+}
+
+// DocResponse holds the document snapshot, and the operations applied since
+// the requested revision
+type DocResponse struct {
+	*mongomodels.CollaborationDoc
+
+	Operations []*OperationResponse `json:"operations,omitempty"`
+}
+
+// CreateDoc creates the document of a collaboration session, the requester
+// becomes the host of the session. If the document already exists it is
+// returned as it is
+func CreateDoc(u *url.URL, h http.Header, req *DocRequest, context *apimodels.Context) (int, http.Header, interface{}, error) {
+	if req.ChannelId == 0 {
+		return response.NewBadRequest(errors.New("channelId not set"))
+	}
+
+	ping := &models.Ping{FileId: req.FileId, ChannelId: req.ChannelId}
+	if err := validateOperation(ping, context); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	doc := &mongomodels.CollaborationDoc{
+		FileId:    req.FileId,
+		ChannelId: req.ChannelId,
+		HostId:    ping.AccountId,
+		Content:   req.Content,
+	}
+
+	err := modelhelper.CreateCollaborationDoc(doc)
+	if mgo.IsDup(err) {
+		doc, err = modelhelper.GetCollaborationDoc(req.FileId)
+		if err != nil {
+			return response.NewBadRequest(err)
+		}
+
+		// file ids are generated by clients, do not leak documents of
+		// other sessions
+		if doc.ChannelId != req.ChannelId {
+			return response.NewAccessDenied(apimodels.ErrCannotOpenChannel)
+		}
+
+		return response.NewOK(&DocResponse{CollaborationDoc: doc})
+	}
+
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	return response.NewOK(&DocResponse{CollaborationDoc: doc})
+}
+
+// GetDoc returns the document snapshot. When revision query parameter is
+// set, the operations applied since that revision are returned as well, so
+// reconnecting clients can catch up
+func GetDoc(u *url.URL, h http.Header, _ interface{}, context *apimodels.Context) (int, http.Header, interface{}, error) {
+	doc, err := fetchDoc(u, context)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	res := &DocResponse{CollaborationDoc: doc}
+
+	rev := u.Query().Get("revision")
+	if rev == "" {
+		return response.NewOK(res)
+	}
+
+	since, err := strconv.Atoi(rev)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	ops, err := modelhelper.GetCollaborationOps(doc.FileId, since)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	if since < doc.Revision && (len(ops) == 0 || ops[0].Revision != since) {
+		return response.NewBadRequest(collaboration.ErrRevisionTooOld)
+	}
+
+	for _, op := range ops {
+		r := &OperationResponse{
+			FileId:    op.FileId,
+			Revision:  op.Revision + 1,
+			AccountId: op.AccountId,
+		}
+
+		if err := json.Unmarshal([]byte(op.Operation), &r.Operation); err != nil {
+			return response.NewBadRequest(err)
+		}
+
+		res.Operations = append(res.Operations, r)
+	}
+
+	return response.NewOK(res)
+}
+
+// SubmitOperation applies the operation to the document and broadcasts the
+// transformed operation to the participants of the session
+func SubmitOperation(u *url.URL, h http.Header, req *OperationRequest, context *apimodels.Context) (int, http.Header, interface{}, error) {
+	doc, err := fetchDoc(u, context)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	accountId := context.Client.Account.Id
+
+	op, revision, err := collaboration.SubmitOperation(collaboration.MongoDocStore{}, doc.FileId, accountId, req.Revision, req.Operation)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	res := &OperationResponse{
+		FileId:    doc.FileId,
+		Revision:  revision,
+		AccountId: accountId,
+		Operation: op,
+	}
+
+	channel, err := apimodels.Cache.Channel.ById(doc.ChannelId)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	// operation is persisted, clients that miss the event can catch up
+	// with GetDoc, so do not fail the request
+	_ = realtimehelper.PushMessage(channel, OperationEventName, res)
+
+	return response.NewOK(res)
+}
+
+// Resume activates a suspended session, the requester becomes the new host
+// and gets the latest snapshot of the document
+func (mgoCache *CacheStore) Resume(u *url.URL, h http.Header, req *models.Ping, context *apimodels.Context) (int, http.Header, interface{}, error) {
+	if err := validateOperation(req, context); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	doc, err := modelhelper.GetCollaborationDoc(req.FileId)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	if doc.ChannelId != req.ChannelId {
+		return response.NewAccessDenied(apimodels.ErrCannotOpenChannel)
+	}
+
+	if doc.State == mongomodels.CollaborationDocEnded {
+		return response.NewBadRequest(collaboration.ErrDocumentEnded)
+	}
+
+	if err := modelhelper.ResumeCollaborationDoc(req.FileId, req.AccountId); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	key := collaboration.PrepareFileKey(req.FileId)
+	if err := mgoCache.SetEx(key, collaboration.ExpireSessionKeyDuration, req.CreatedAt.Unix()); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	doc.HostId = req.AccountId
+	doc.State = mongomodels.CollaborationDocActive
+	doc.SuspendedAt = time.Time{}
+
+	return response.NewOK(&DocResponse{CollaborationDoc: doc})
+}
+
+// fetchDoc fetches the document given in the url and checks whether the
+// requester is a participant of its session
+func fetchDoc(u *url.URL, context *apimodels.Context) (*mongomodels.CollaborationDoc, error) {
+	fileId := u.Query().Get("fileId")
+	if fileId == "" {
+		return nil, errors.New("fileId not set")
+	}
+
+	doc, err := modelhelper.GetCollaborationDoc(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	ping := &models.Ping{FileId: doc.FileId, ChannelId: doc.ChannelId}
+	if err := validateOperation(ping, context); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"koding/db/mongodb/modelhelper"
 	"net/http"
 	"net/url"
 	apimodels "socialapi/models"
@@ -13,6 +14,7 @@ import (
 	"github.com/koding/cache"
 
 	"github.com/koding/bongo"
+	mgo "gopkg.in/mgo.v2"
 )
 
 // CacheStore holds the mongo cache struct as embedded for Ping & End functions
@@ -50,6 +52,12 @@ func (mgoCache *CacheStore) End(u *url.URL, h http.Header, req *models.Ping, con
 		return response.NewBadRequest(err)
 	}
 
+	// mark the document as ended, so the session is terminated instead of
+	// being suspended
+	if err := modelhelper.EndCollaborationDoc(req.FileId); err != nil && err != mgo.ErrNotFound {
+		return response.NewBadRequest(err)
+	}
+
 	key := collaboration.PrepareFileKey(req.FileId)
 
 	// when key is deleted, with the first ping received, collab will be ended
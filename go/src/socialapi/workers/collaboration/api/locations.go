@@ -34,4 +34,41 @@ func AddHandlers(m *mux.Mux, mgoCache *cache.MongoCache) {
 			Endpoint: "/collaboration/end",
 		},
 	)
+
+	m.AddHandler(
+		handler.Request{
+			Handler:  cs.Resume,
+			Name:     "collaboration-resume",
+			Type:     handler.PostRequest,
+			Endpoint: "/collaboration/resume",
+		},
+	)
+
+	m.AddHandler(
+		handler.Request{
+			Handler:  CreateDoc,
+			Name:     "collaboration-doc-create",
+			Type:     handler.PostRequest,
+			Endpoint: "/collaboration/doc",
+		},
+	)
+
+	m.AddHandler(
+		handler.Request{
+			Handler:  GetDoc,
+			Name:     "collaboration-doc-get",
+			Type:     handler.GetRequest,
+			Endpoint: "/collaboration/doc/{fileId}",
+		},
+	)
+
+	m.AddHandler(
+		handler.Request{
+			Handler:   SubmitOperation,
+			Name:      "collaboration-doc-operation",
+			Type:      handler.PostRequest,
+			Endpoint:  "/collaboration/doc/{fileId}/operation",
+			Ratelimit: httpRateLimiter,
+		},
+	)
 }
@@ -127,7 +127,7 @@ func (c *Controller) Ping(ping *models.Ping) error {
 
 	if err == errSessionInvalid {
 		c.log.Info("session is not valid anymore, collab should be terminated %+v", ping)
-		return c.EndSession(ping)
+		return c.suspendOrEnd(ping)
 	}
 
 	err = c.wait(ping) // wait synchronously
@@ -138,7 +138,7 @@ func (c *Controller) Ping(ping *models.Ping) error {
 
 	if err == errSessionInvalid {
 		c.log.Info("session is not valid anymore, collab should be terminated %+v", ping)
-		return c.EndSession(ping)
+		return c.suspendOrEnd(ping)
 	}
 
 	c.log.Debug("session is valid %+v", ping)
@@ -230,7 +230,7 @@ func (c *Controller) EndSession(ping *models.Ping) error {
 	}, errChan, &wg)
 
 	c.goWithRetry(func() error {
-		return c.DeleteDriveDoc(ping)
+		return c.DeleteDoc(ping)
 	}, errChan, &wg)
 
 	go func() {
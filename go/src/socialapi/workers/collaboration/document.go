@@ -0,0 +1,157 @@
+package collaboration
+
+import (
+	"encoding/json"
+	"errors"
+	mongomodels "koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"socialapi/workers/collaboration/ot"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// maxSubmitRetries is the number of times an operation is transformed and
+// applied again when the document is changed concurrently.
+const maxSubmitRetries = 10
+
+var (
+	// ErrDocumentEnded is returned for operations on ended sessions
+	ErrDocumentEnded = errors.New("collaboration session is ended")
+
+	// ErrRevisionTooOld is returned when the operations that are required to
+	// transform a client operation are not stored anymore, clients should
+	// reload the document in that case
+	ErrRevisionTooOld = errors.New("revision is too old, document should be reloaded")
+
+	// ErrInvalidRevision is returned when the revision of an operation is
+	// newer than the document
+	ErrInvalidRevision = errors.New("revision is newer than the document")
+
+	// ErrConflict is returned by DocStore.Apply when the document was
+	// changed concurrently
+	ErrConflict = errors.New("document was changed concurrently")
+
+	errTooManyConflicts = errors.New("could not apply operation due to concurrent changes")
+)
+
+// DocStore persists collaboration documents and their operations.
+type DocStore interface {
+	Get(fileId string) (*mongomodels.CollaborationDoc, error)
+
+	// Ops returns the operations applied at or after the given revision.
+	Ops(fileId string, since int) ([]*mongomodels.CollaborationOp, error)
+
+	// Apply updates the content of the document and stores the operation
+	// at once if the document is still at the given revision, otherwise
+	// returns ErrConflict.
+	Apply(revision int, content string, op *mongomodels.CollaborationOp) error
+}
+
+// MongoDocStore is a DocStore backed by mongodb.
+type MongoDocStore struct{}
+
+var _ DocStore = MongoDocStore{}
+
+// Get implements the DocStore interface.
+func (MongoDocStore) Get(fileId string) (*mongomodels.CollaborationDoc, error) {
+	return modelhelper.GetCollaborationDoc(fileId)
+}
+
+// Ops implements the DocStore interface.
+func (MongoDocStore) Ops(fileId string, since int) ([]*mongomodels.CollaborationOp, error) {
+	return modelhelper.GetCollaborationOps(fileId, since)
+}
+
+// Apply implements the DocStore interface.
+func (MongoDocStore) Apply(revision int, content string, op *mongomodels.CollaborationOp) error {
+	err := modelhelper.ApplyCollaborationOp(revision, content, op)
+	if err == mgo.ErrNotFound {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// SubmitOperation applies the operation that a client created at the given
+// revision to the document. The operation is transformed against the ones
+// that were applied since, the transformed operation and the new revision of
+// the document are returned to be broadcasted to the other participants.
+func SubmitOperation(store DocStore, fileId string, accountId int64, revision int, op ot.Operation) (ot.Operation, int, error) {
+	for i := 0; i < maxSubmitRetries; i++ {
+		doc, err := store.Get(fileId)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if doc.State == mongomodels.CollaborationDocEnded {
+			return nil, 0, ErrDocumentEnded
+		}
+
+		transformed, err := transformSince(store, doc, revision, op)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		content, err := transformed.Apply(doc.Content)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		p, err := json.Marshal(transformed)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		err = store.Apply(doc.Revision, content, &mongomodels.CollaborationOp{
+			FileId:    fileId,
+			Revision:  doc.Revision,
+			AccountId: accountId,
+			Operation: string(p),
+		})
+		if err == ErrConflict {
+			continue
+		}
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return transformed, doc.Revision + 1, nil
+	}
+
+	return nil, 0, errTooManyConflicts
+}
+
+// transformSince transforms op, which was created at the given revision,
+// against the operations that were applied to doc since then.
+func transformSince(store DocStore, doc *mongomodels.CollaborationDoc, revision int, op ot.Operation) (ot.Operation, error) {
+	if revision > doc.Revision || revision < 0 {
+		return nil, ErrInvalidRevision
+	}
+
+	if revision == doc.Revision {
+		return op, nil
+	}
+
+	ops, err := store.Ops(doc.FileId, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := revision; i < doc.Revision; i++ {
+		if i-revision >= len(ops) || ops[i-revision].Revision != i {
+			return nil, ErrRevisionTooOld
+		}
+
+		var concurrent ot.Operation
+		if err := json.Unmarshal([]byte(ops[i-revision].Operation), &concurrent); err != nil {
+			return nil, err
+		}
+
+		if op, _, err = ot.Transform(op, concurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
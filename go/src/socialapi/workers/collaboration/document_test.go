@@ -0,0 +1,105 @@
+package collaboration
+
+import (
+	mongomodels "koding/db/models"
+	"socialapi/workers/collaboration/ot"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// memDocStore is an in-memory DocStore, conflicts are injected for the
+// first Apply calls.
+type memDocStore struct {
+	doc       *mongomodels.CollaborationDoc
+	ops       []*mongomodels.CollaborationOp
+	conflicts int
+}
+
+func (m *memDocStore) Get(fileId string) (*mongomodels.CollaborationDoc, error) {
+	doc := *m.doc
+	return &doc, nil
+}
+
+func (m *memDocStore) Ops(fileId string, since int) ([]*mongomodels.CollaborationOp, error) {
+	var ops []*mongomodels.CollaborationOp
+	for _, op := range m.ops {
+		if op.Revision >= since {
+			ops = append(ops, op)
+		}
+	}
+
+	return ops, nil
+}
+
+func (m *memDocStore) Apply(revision int, content string, op *mongomodels.CollaborationOp) error {
+	if m.conflicts > 0 || m.doc.Revision != revision {
+		m.conflicts--
+		return ErrConflict
+	}
+
+	m.doc.Content = content
+	m.doc.Revision++
+	m.ops = append(m.ops, op)
+	return nil
+}
+
+func TestSubmitOperation(t *testing.T) {
+	Convey("Given a collaboration document", t, func() {
+		store := &memDocStore{
+			doc: &mongomodels.CollaborationDoc{
+				FileId:  "file",
+				Content: "hello world",
+				State:   mongomodels.CollaborationDocActive,
+			},
+		}
+
+		Convey("operations on the latest revision should be applied", func() {
+			op, rev, err := SubmitOperation(store, "file", 1, 0, ot.Operation{}.Retain(5).Insert(",").Retain(6))
+			So(err, ShouldBeNil)
+			So(rev, ShouldEqual, 1)
+			So(op, ShouldResemble, ot.Operation{}.Retain(5).Insert(",").Retain(6))
+			So(store.doc.Content, ShouldEqual, "hello, world")
+			So(len(store.ops), ShouldEqual, 1)
+		})
+
+		Convey("concurrent operations should be transformed", func() {
+			_, _, err := SubmitOperation(store, "file", 1, 0, ot.Operation{}.Insert("oh, ").Retain(11))
+			So(err, ShouldBeNil)
+
+			op, rev, err := SubmitOperation(store, "file", 2, 0, ot.Operation{}.Retain(6).Delete(5).Insert("koding"))
+			So(err, ShouldBeNil)
+			So(rev, ShouldEqual, 2)
+			So(op, ShouldResemble, ot.Operation{}.Retain(10).Insert("koding").Delete(5))
+			So(store.doc.Content, ShouldEqual, "oh, hello koding")
+		})
+
+		Convey("conflicting updates should be retried", func() {
+			store.conflicts = 2
+
+			_, rev, err := SubmitOperation(store, "file", 1, 0, ot.Operation{}.Retain(11).Insert("!"))
+			So(err, ShouldBeNil)
+			So(rev, ShouldEqual, 1)
+			So(store.doc.Content, ShouldEqual, "hello world!")
+		})
+
+		Convey("operations should fail when history is not stored anymore", func() {
+			store.doc.Revision = 3
+
+			_, _, err := SubmitOperation(store, "file", 1, 1, ot.Operation{}.Retain(11))
+			So(err, ShouldEqual, ErrRevisionTooOld)
+		})
+
+		Convey("operations with future revisions should fail", func() {
+			_, _, err := SubmitOperation(store, "file", 1, 1, ot.Operation{}.Retain(11))
+			So(err, ShouldEqual, ErrInvalidRevision)
+		})
+
+		Convey("operations on ended sessions should fail", func() {
+			store.doc.State = mongomodels.CollaborationDocEnded
+
+			_, _, err := SubmitOperation(store, "file", 1, 0, ot.Operation{}.Retain(11))
+			So(err, ShouldEqual, ErrDocumentEnded)
+		})
+	})
+}
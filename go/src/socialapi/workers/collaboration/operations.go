@@ -20,6 +20,31 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+// DeleteDoc deletes the document of the session. Sessions without a stored
+// document are using google drive, their file is deleted from there when
+// the google api is configured
+func (c *Controller) DeleteDoc(ping *models.Ping) error {
+	// if file id is nil, there is nothing to do
+	if ping.FileId == "" {
+		return nil
+	}
+
+	_, err := modelhelper.GetCollaborationDoc(ping.FileId)
+	if err == nil {
+		return modelhelper.DeleteCollaborationDoc(ping.FileId)
+	}
+
+	if err != mgo.ErrNotFound {
+		return err
+	}
+
+	if c.conf.GoogleapiServiceAccount.ClientId == "" {
+		return nil
+	}
+
+	return c.DeleteDriveDoc(ping)
+}
+
 // DeleteDriveDoc deletes the file from google drive
 func (c *Controller) DeleteDriveDoc(ping *models.Ping) error {
 	// if file id is nil, there is nothing to do
@@ -0,0 +1,362 @@
+// Package ot implements operational transformation for plain text
+// documents.
+//
+// Operations are compatible with the ot.js wire format: an operation is a
+// JSON array where positive integers retain characters, negative integers
+// delete characters and strings insert text. Lengths are counted in unicode
+// code points.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var (
+	// ErrBaseLength is returned when an operation is applied to a document or
+	// combined with an operation that it was not created for.
+	ErrBaseLength = errors.New("ot: operation base length does not match")
+
+	// ErrInvalidComponent is returned when an operation can not be decoded.
+	ErrInvalidComponent = errors.New("ot: invalid operation component")
+)
+
+// Component is a single step of an operation, only one of its fields is set.
+type Component struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+// Operation is a sequence of components that transforms a document.
+type Operation []Component
+
+// Retain appends a retain component, merging it with the last one.
+func (o Operation) Retain(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+
+	if l := len(o); l > 0 && o[l-1].Retain > 0 {
+		o[l-1].Retain += n
+		return o
+	}
+
+	return append(o, Component{Retain: n})
+}
+
+// Insert appends an insert component. Inserts are kept before deletes so
+// equivalent operations have the same representation.
+func (o Operation) Insert(s string) Operation {
+	if s == "" {
+		return o
+	}
+
+	l := len(o)
+
+	if l > 0 && o[l-1].Insert != "" {
+		o[l-1].Insert += s
+		return o
+	}
+
+	if l > 0 && o[l-1].Delete > 0 {
+		if l > 1 && o[l-2].Insert != "" {
+			o[l-2].Insert += s
+			return o
+		}
+
+		o = append(o, o[l-1])
+		o[l-1] = Component{Insert: s}
+		return o
+	}
+
+	return append(o, Component{Insert: s})
+}
+
+// Delete appends a delete component, merging it with the last one.
+func (o Operation) Delete(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+
+	if l := len(o); l > 0 && o[l-1].Delete > 0 {
+		o[l-1].Delete += n
+		return o
+	}
+
+	return append(o, Component{Delete: n})
+}
+
+// BaseLen returns the length of the documents the operation can be applied
+// to.
+func (o Operation) BaseLen() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + c.Delete
+	}
+
+	return n
+}
+
+// TargetLen returns the length of the document after the operation is
+// applied.
+func (o Operation) TargetLen() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + utf8.RuneCountInString(c.Insert)
+	}
+
+	return n
+}
+
+// IsNoop returns true if the operation does not change the document.
+func (o Operation) IsNoop() bool {
+	return len(o) == 0 || (len(o) == 1 && o[0].Retain > 0)
+}
+
+// Apply applies the operation to doc.
+func (o Operation) Apply(doc string) (string, error) {
+	runes := []rune(doc)
+	if len(runes) != o.BaseLen() {
+		return "", ErrBaseLength
+	}
+
+	res := make([]rune, 0, o.TargetLen())
+	pos := 0
+
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			res = append(res, runes[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Insert != "":
+			res = append(res, []rune(c.Insert)...)
+		case c.Delete > 0:
+			pos += c.Delete
+		}
+	}
+
+	return string(res), nil
+}
+
+// Compose merges a and b into a single operation that has the same effect
+// as applying a and b consecutively.
+func Compose(a, b Operation) (Operation, error) {
+	if a.TargetLen() != b.BaseLen() {
+		return nil, ErrBaseLength
+	}
+
+	var (
+		res    Operation
+		ia, ib = newIter(a), newIter(b)
+	)
+
+	for ia.more() || ib.more() {
+		ca, cb := ia.peek(), ib.peek()
+
+		switch {
+		case ca.Delete > 0:
+			res = res.Delete(ia.take(ca.Delete).Delete)
+		case cb.Insert != "":
+			res = res.Insert(ib.take(-1).Insert)
+		case !ia.more() || !ib.more():
+			return nil, ErrBaseLength
+		default:
+			n := min(ca.len(), cb.len())
+			xa, xb := ia.take(n), ib.take(n)
+
+			switch {
+			case xb.Delete > 0:
+				// text inserted by a is deleted by b
+				if xa.Retain > 0 {
+					res = res.Delete(n)
+				}
+			case xa.Insert != "":
+				res = res.Insert(xa.Insert)
+			default:
+				res = res.Retain(n)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Transform transforms concurrent operations a and b, which were created for
+// the same document, into a' and b' so that applying a then b' results in
+// the same document as applying b then a'. Inserts of a are placed before
+// the inserts of b at the same position.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLen() != b.BaseLen() {
+		return nil, nil, ErrBaseLength
+	}
+
+	var (
+		a1, b1 Operation
+		ia, ib = newIter(a), newIter(b)
+	)
+
+	for ia.more() || ib.more() {
+		ca, cb := ia.peek(), ib.peek()
+
+		switch {
+		case ca.Insert != "":
+			s := ia.take(-1).Insert
+			a1 = a1.Insert(s)
+			b1 = b1.Retain(utf8.RuneCountInString(s))
+		case cb.Insert != "":
+			s := ib.take(-1).Insert
+			a1 = a1.Retain(utf8.RuneCountInString(s))
+			b1 = b1.Insert(s)
+		case !ia.more() || !ib.more():
+			return nil, nil, ErrBaseLength
+		default:
+			n := min(ca.len(), cb.len())
+			xa, xb := ia.take(n), ib.take(n)
+
+			switch {
+			case xa.Retain > 0 && xb.Retain > 0:
+				a1 = a1.Retain(n)
+				b1 = b1.Retain(n)
+			case xa.Delete > 0 && xb.Retain > 0:
+				a1 = a1.Delete(n)
+			case xa.Retain > 0 && xb.Delete > 0:
+				b1 = b1.Delete(n)
+			}
+			// both deleted the same text, nothing to do
+		}
+	}
+
+	return a1, b1, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	v := make([]interface{}, len(o))
+
+	for i, c := range o {
+		switch {
+		case c.Retain > 0:
+			v[i] = c.Retain
+		case c.Insert != "":
+			v[i] = c.Insert
+		case c.Delete > 0:
+			v[i] = -c.Delete
+		default:
+			return nil, ErrInvalidComponent
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (o *Operation) UnmarshalJSON(p []byte) error {
+	var v []interface{}
+	if err := json.Unmarshal(p, &v); err != nil {
+		return err
+	}
+
+	var op Operation
+	for _, c := range v {
+		switch c := c.(type) {
+		case float64:
+			n := int(c)
+			if float64(n) != c || n == 0 {
+				return fmt.Errorf("%s: %v", ErrInvalidComponent, c)
+			}
+
+			if n > 0 {
+				op = op.Retain(n)
+			} else {
+				op = op.Delete(-n)
+			}
+		case string:
+			if c == "" {
+				return ErrInvalidComponent
+			}
+
+			op = op.Insert(c)
+		default:
+			return fmt.Errorf("%s: %v", ErrInvalidComponent, c)
+		}
+	}
+
+	*o = op
+	return nil
+}
+
+// len returns the length of the component in the base document, or in the
+// target document for inserts.
+func (c Component) len() int {
+	if c.Insert != "" {
+		return utf8.RuneCountInString(c.Insert)
+	}
+
+	return c.Retain + c.Delete
+}
+
+// iter iterates over the components of an operation, splitting them when
+// only a part of a component is consumed.
+type iter struct {
+	op   Operation
+	i    int
+	head Component
+}
+
+func newIter(op Operation) *iter {
+	it := &iter{op: op}
+	it.load()
+	return it
+}
+
+func (it *iter) load() {
+	if it.i < len(it.op) {
+		it.head = it.op[it.i]
+	} else {
+		it.head = Component{}
+	}
+}
+
+func (it *iter) more() bool {
+	return it.i < len(it.op)
+}
+
+func (it *iter) peek() Component {
+	return it.head
+}
+
+// take consumes n characters of the current component, or all of it when n
+// is negative.
+func (it *iter) take(n int) Component {
+	c := it.head
+
+	if n < 0 || n >= c.len() {
+		it.i++
+		it.load()
+		return c
+	}
+
+	switch {
+	case c.Retain > 0:
+		it.head.Retain -= n
+		return Component{Retain: n}
+	case c.Delete > 0:
+		it.head.Delete -= n
+		return Component{Delete: n}
+	default:
+		r := []rune(c.Insert)
+		it.head.Insert = string(r[n:])
+		return Component{Insert: string(r[:n])}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,158 @@
+package ot
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func randomString(r *rand.Rand, n int) string {
+	const letters = "abcçdeğ\n "
+
+	runes := []rune(letters)
+	s := make([]rune, n)
+	for i := range s {
+		s[i] = runes[r.Intn(len(runes))]
+	}
+
+	return string(s)
+}
+
+// randomOperation creates a random operation for doc.
+func randomOperation(r *rand.Rand, doc string) Operation {
+	var op Operation
+
+	left := len([]rune(doc))
+	for left > 0 {
+		n := 1 + r.Intn(min(left, 5))
+
+		switch r.Intn(3) {
+		case 0:
+			op = op.Retain(n)
+			left -= n
+		case 1:
+			op = op.Delete(n)
+			left -= n
+		default:
+			op = op.Insert(randomString(r, n))
+		}
+	}
+
+	if r.Intn(2) == 0 {
+		op = op.Insert(randomString(r, 1+r.Intn(5)))
+	}
+
+	return op
+}
+
+func TestOperation(t *testing.T) {
+	Convey("Given an operation", t, func() {
+		op := Operation{}.Retain(6).Delete(5).Insert("koding").Retain(1)
+
+		Convey("it should be applied to the document", func() {
+			doc, err := op.Apply("hello world!")
+			So(err, ShouldBeNil)
+			So(doc, ShouldEqual, "hello koding!")
+		})
+
+		Convey("it should not be applied to a document with different length", func() {
+			_, err := op.Apply("hello")
+			So(err, ShouldEqual, ErrBaseLength)
+		})
+
+		Convey("inserts should be kept before deletes", func() {
+			So(op, ShouldResemble, Operation{{Retain: 6}, {Insert: "koding"}, {Delete: 5}, {Retain: 1}})
+		})
+
+		Convey("it should be encoded in ot.js format", func() {
+			p, err := json.Marshal(op)
+			So(err, ShouldBeNil)
+			So(string(p), ShouldEqual, `[6,"koding",-5,1]`)
+
+			var decoded Operation
+			So(json.Unmarshal(p, &decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, op)
+		})
+
+		Convey("invalid components should not be decoded", func() {
+			var decoded Operation
+			So(json.Unmarshal([]byte(`[1.5]`), &decoded), ShouldNotBeNil)
+			So(json.Unmarshal([]byte(`[0]`), &decoded), ShouldNotBeNil)
+			So(json.Unmarshal([]byte(`[{}]`), &decoded), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestTransform(t *testing.T) {
+	Convey("Given concurrent operations", t, func() {
+		Convey("inserts at the same position should be ordered", func() {
+			a := Operation{}.Retain(2).Insert("a")
+			b := Operation{}.Retain(2).Insert("b")
+
+			a1, b1, err := Transform(a, b)
+			So(err, ShouldBeNil)
+
+			docA, _ := a.Apply("xx")
+			docA, _ = b1.Apply(docA)
+
+			docB, _ := b.Apply("xx")
+			docB, _ = a1.Apply(docB)
+
+			So(docA, ShouldEqual, "xxab")
+			So(docB, ShouldEqual, "xxab")
+		})
+
+		Convey("random operations should converge", func() {
+			r := rand.New(rand.NewSource(42))
+
+			for i := 0; i < 500; i++ {
+				doc := randomString(r, r.Intn(20))
+				a, b := randomOperation(r, doc), randomOperation(r, doc)
+
+				a1, b1, err := Transform(a, b)
+				So(err, ShouldBeNil)
+
+				docA, err := a.Apply(doc)
+				So(err, ShouldBeNil)
+				docA, err = b1.Apply(docA)
+				So(err, ShouldBeNil)
+
+				docB, err := b.Apply(doc)
+				So(err, ShouldBeNil)
+				docB, err = a1.Apply(docB)
+				So(err, ShouldBeNil)
+
+				So(docA, ShouldEqual, docB)
+			}
+		})
+	})
+}
+
+func TestCompose(t *testing.T) {
+	Convey("Given consecutive operations", t, func() {
+		r := rand.New(rand.NewSource(7))
+
+		Convey("composition should have the same effect", func() {
+			for i := 0; i < 500; i++ {
+				doc := randomString(r, r.Intn(20))
+
+				a := randomOperation(r, doc)
+				afterA, err := a.Apply(doc)
+				So(err, ShouldBeNil)
+
+				b := randomOperation(r, afterA)
+				afterB, err := b.Apply(afterA)
+				So(err, ShouldBeNil)
+
+				ab, err := Compose(a, b)
+				So(err, ShouldBeNil)
+
+				res, err := ab.Apply(doc)
+				So(err, ShouldBeNil)
+				So(res, ShouldEqual, afterB)
+			}
+		})
+	})
+}
@@ -0,0 +1,99 @@
+package collaboration
+
+import (
+	mongomodels "koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"socialapi/workers/collaboration/models"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// SuspendedSessionTimeout is the duration that a session with a stored
+// document can be resumed by any participant after its pings stopped.
+var SuspendedSessionTimeout = 10 * time.Minute
+
+// suspendOrEnd is called when the pings of a session stop. Sessions with a
+// stored document are suspended, so they survive disconnects of the host;
+// the other ones and the ones ended by the host are terminated.
+func (c *Controller) suspendOrEnd(ping *models.Ping) error {
+	doc, err := modelhelper.GetCollaborationDoc(ping.FileId)
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	if err == mgo.ErrNotFound || doc.State == mongomodels.CollaborationDocEnded {
+		return c.EndSession(ping)
+	}
+
+	if doc.State == mongomodels.CollaborationDocSuspended {
+		return nil // already suspended, sweeper will handle it
+	}
+
+	c.log.Info("suspending collaboration session %+v", ping)
+
+	err = modelhelper.SuspendCollaborationDoc(ping.FileId)
+	if err == mgo.ErrNotFound {
+		return nil // state is changed concurrently
+	}
+
+	return err
+}
+
+// SweepSuspended ends the sessions that were not resumed in
+// SuspendedSessionTimeout, it runs every interval until done is closed.
+func (c *Controller) SweepSuspended(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.EndSuspendedSessions(); err != nil {
+				c.log.Error("couldnt end suspended sessions: %s", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// EndSuspendedSessions ends the sessions that were suspended before
+// SuspendedSessionTimeout. Sessions that got pings again in the meantime are
+// activated instead.
+func (c *Controller) EndSuspendedSessions() error {
+	docs, err := modelhelper.GetSuspendedCollaborationDocs(time.Now().UTC().Add(-SuspendedSessionTimeout))
+	if err != nil {
+		return err
+	}
+
+	var multiErr Error
+
+	for _, doc := range docs {
+		ping := &models.Ping{
+			FileId:    doc.FileId,
+			ChannelId: doc.ChannelId,
+			AccountId: doc.HostId,
+			CreatedAt: time.Now().UTC(),
+		}
+
+		err := c.checkIfKeyIsValid(ping)
+		if err == nil {
+			// a participant is pinging the session
+			err = modelhelper.ResumeCollaborationDoc(doc.FileId, doc.HostId)
+		} else if err == errSessionInvalid {
+			c.log.Info("suspended collaboration session is not resumed, terminating %+v", ping)
+			err = c.EndSession(ping)
+		}
+
+		if err != nil && err != mgo.ErrNotFound {
+			multiErr = append(multiErr, err)
+		}
+	}
+
+	if len(multiErr) == 0 {
+		return nil
+	}
+
+	return multiErr
+}
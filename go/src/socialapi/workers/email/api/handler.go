@@ -4,15 +4,18 @@ package api
 import (
 	"net/http"
 	"net/url"
+	"socialapi/config"
 	"socialapi/models"
 	"socialapi/workers/common/handler"
 	"socialapi/workers/common/mux"
 	"socialapi/workers/common/response"
 	"socialapi/workers/email/emailsender"
+
+	"github.com/koding/runner"
 )
 
 // AddHandlers added the internal handlers to the given Muxer
-func AddHandlers(m *mux.Mux) {
+func AddHandlers(m *mux.Mux, c *config.Config) {
 	m.AddHandler(
 		handler.Request{
 			Handler:  PublishEvent,
@@ -21,6 +24,17 @@ func AddHandlers(m *mux.Mux) {
 			Endpoint: "/private/mail/publish",
 		},
 	)
+
+	w := &Webhook{APIKey: c.Mailgun.PrivateKey}
+
+	m.AddUnscopedHandler(
+		handler.Request{
+			Handler:  w.Mailgun,
+			Name:     models.MailMailgunWebhook,
+			Type:     handler.PostRequest,
+			Endpoint: "/mail/webhook/mailgun",
+		},
+	)
 }
 
 func PublishEvent(u *url.URL, h http.Header, req *emailsender.Mail) (int, http.Header, interface{}, error) {
@@ -30,3 +44,29 @@ func PublishEvent(u *url.URL, h http.Header, req *emailsender.Mail) (int, http.H
 
 	return response.NewDefaultOK()
 }
+
+// Webhook handles delivery events of email providers.
+type Webhook struct {
+	APIKey string
+}
+
+// Mailgun suppresses addresses reported by Mailgun as bounced or
+// complained about.
+func (wh *Webhook) Mailgun(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := emailsender.HandleMailgunEvent(wh.APIKey, req.PostForm, emailsender.MongoStore{})
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+	case emailsender.ErrInvalidSignature, emailsender.ErrStaleWebhook:
+		// Mailgun stops retrying the webhook on 406.
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+	default:
+		runner.MustGetLogger().Error("mailgun webhook err: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,9 +2,12 @@
 package emailsender
 
 import (
+	"fmt"
+	"koding/db/models"
 	"koding/db/mongodb/modelhelper"
 	"socialapi/config"
 	"text/template"
+	"time"
 
 	"github.com/koding/bongo"
 	"github.com/koding/eventexporter"
@@ -17,9 +20,22 @@ var SendEmailEventName = "send"
 const (
 	keyInvitedCreateTeam     = "was invited to create a team"
 	subjectInvitedCreateTeam = "You're invited to try Koding for Teams!"
-	emailFrom                = "Devrim <dy@koding.com>"
 )
 
+// MaxAttempts is the number of times delivery of a single mail is
+// attempted, after that the mail is moved to dead letters.
+var MaxAttempts = 10
+
+// RetryDelay returns how long to wait after the given number of failed
+// delivery attempts before the mail is published again.
+var RetryDelay = func(attempts int) time.Duration {
+	if attempts > 6 {
+		return time.Minute
+	}
+
+	return time.Second << uint(attempts-1)
+}
+
 // Controller holds required instances for processing events
 type Controller struct {
 	log                     logging.Logger
@@ -28,22 +44,49 @@ type Controller struct {
 	forcedRecipientEmail    string
 	env                     string
 	host                    string
-	mailgun                 *MailgunSender
+	vmHostname              string
+	sender                  Sender
+	store                   Store
+	templates               *Templates
+
+	// retry publishes the mail again to be delivered after the given
+	// delay, so pending retries survive restarts of the worker.
+	retry func(m *Mail, after time.Duration)
 }
 
 // New Creates a new controller for mail worker
 // func New(exporter eventexporter.Exporter, log logging.Logger, conf runner.Config) *Controller {
 func New(exporter eventexporter.Exporter, log logging.Logger, conf *config.Config) *Controller {
 	vmHostname := conf.Protocol + "//" + conf.Hostname
-	return &Controller{
+
+	sender, err := NewSender(vmHostname, log, conf)
+	if err != nil {
+		log.Error("Could not create email sender, falling back to mailgun: %s", err)
+		sender = NewMailgunSender(vmHostname, log, conf)
+	}
+
+	return NewWithSender(exporter, log, conf, sender, MongoStore{})
+}
+
+// NewWithSender creates a new controller that delivers templated mails
+// with the given sender.
+func NewWithSender(exporter eventexporter.Exporter, log logging.Logger, conf *config.Config, sender Sender, store Store) *Controller {
+	c := &Controller{
 		emailer:                 exporter,
 		log:                     log,
 		env:                     conf.Environment,
 		host:                    conf.Hostname,
 		forcedRecipientEmail:    conf.Email.ForcedRecipientEmail,
 		forcedRecipientUsername: conf.Email.ForcedRecipientUsername,
-		mailgun:                 NewMailgunSender(vmHostname, log, conf),
+		vmHostname:              conf.Protocol + "//" + conf.Hostname,
+		sender:                  sender,
+		store:                   store,
+		templates:               NewTemplates(domainOf(conf.Email.DefaultFromMail)),
 	}
+
+	c.retry = c.republish
+
+	return c
 }
 
 // Send gets the mail struct that includes the message
@@ -57,6 +100,13 @@ func Send(m *Mail) error {
 // and sends the message according to the mail address
 // its a helper method to send message
 func (c *Controller) Process(m *Mail) error {
+	// Every delivery is processed in its own goroutine and the message is
+	// acknowledged only after Process returns, so waiting here keeps the
+	// mail in the queue until it is due.
+	if wait := m.NotBefore.Sub(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+
 	if m.Properties == nil {
 		m.Properties = NewProperties()
 	}
@@ -70,7 +120,15 @@ func (c *Controller) Process(m *Mail) error {
 	m.SetOption("host", c.host)
 
 	if m.Properties.Options["subject"] == keyInvitedCreateTeam {
-		return c.mailgun.SendMailgunEmail(m)
+		return c.sendTeamInvite(m)
+	}
+
+	if m.Template != "" {
+		data := &TemplateData{}
+		data.UserID = m.To
+		data.Link, _ = m.Properties.Options["link"].(string)
+
+		return c.sendTemplate(m.Template, m.To, m, data)
 	}
 
 	event := &eventexporter.Event{
@@ -106,7 +164,119 @@ func (c *Controller) getUserInfo(m *Mail) *eventexporter.User {
 	return user
 }
 
-// Close closes the emailer
+func (c *Controller) sendTeamInvite(m *Mail) error {
+	email, ok := m.Properties.Options["invitee"].(string)
+	if !ok {
+		return fmt.Errorf("invalid invitee: %v", m.Properties.Options["invitee"])
+	}
+
+	link, _ := m.Properties.Options["link"].(string)
+
+	userID := "0"
+
+	if user, err := modelhelper.FetchUserByEmail(email); err == nil {
+		if user.EmailFrequency != nil && !user.EmailFrequency.Global {
+			c.log.Info("User %s is unsubscribed from all emails", email)
+			return nil
+		}
+
+		userID = user.ObjectId.Hex()
+	}
+
+	data := &TemplateData{
+		EmailInvitationUser: EmailInvitationUser{
+			UserID:          email,
+			Link:            link,
+			LinkUnsubscribe: fmt.Sprintf("%s/Unsubscribe/%s/%s", c.vmHostname, userID, email),
+		},
+	}
+
+	return c.sendTemplate(TemplateTeamInviteName, email, m, data)
+}
+
+// sendTemplate renders the named template with the branding of the mail's
+// group and delivers it to the given address.
+func (c *Controller) sendTemplate(name, to string, m *Mail, data *TemplateData) error {
+	if c.forcedRecipientEmail != "" {
+		to = c.forcedRecipientEmail
+	}
+
+	suppressed, err := c.store.IsSuppressed(to)
+	if err != nil {
+		return err
+	}
+
+	if suppressed {
+		c.log.Info("Not sending %q to suppressed address %s", name, to)
+		return nil
+	}
+
+	if data.Branding, err = c.store.Branding(m.GroupName); err != nil {
+		c.log.Error("Could not fetch email branding of %q, using default: %s", m.GroupName, err)
+	}
+
+	data.Options = m.Properties.Options
+
+	msg, err := c.templates.Render(name, to, data)
+	if err != nil {
+		c.log.Error("Sending email template execute err: %s", err)
+		return err
+	}
+
+	return c.deliver(m, msg)
+}
+
+// deliver sends the message once. Temporary failures publish the mail
+// again to be delivered after RetryDelay, permanent ones suppress the
+// recipient address. Rejected requests and mails that still fail after
+// MaxAttempts are moved to dead letters.
+func (c *Controller) deliver(m *Mail, msg *Message) error {
+	err := c.sender.Send(msg)
+	if err == nil {
+		return nil
+	}
+
+	if IsPermanent(err) {
+		c.log.Error("Suppressing %s after delivery failure: %s", msg.To, err)
+		return c.store.Suppress(msg.To, models.EmailSuppressionBounce, err.Error())
+	}
+
+	m.Attempts++
+
+	if IsRequestError(err) {
+		c.log.Error("Not retrying rejected mail %q to %s: %s", msg.Subject, msg.To, err)
+		return c.store.DeadLetter(m, m.Attempts, err)
+	}
+
+	if m.Attempts >= MaxAttempts {
+		c.log.Error("Giving up sending %q to %s after %d attempts: %s", msg.Subject, msg.To, m.Attempts, err)
+		return c.store.DeadLetter(m, m.Attempts, err)
+	}
+
+	next := RetryDelay(m.Attempts)
+
+	c.log.Warning("Sending %q to %s failed, retrying in %s: %s", msg.Subject, msg.To, next, err)
+
+	c.retry(m, next)
+
+	return nil
+}
+
+// republish publishes the mail again to the send queue, to be delivered
+// after the given delay.
+func (c *Controller) republish(m *Mail, after time.Duration) {
+	m.NotBefore = time.Now().Add(after)
+
+	if err := Send(m); err != nil {
+		c.log.Error("Could not republish mail to %s: %s", m.To, err)
+
+		if err := c.store.DeadLetter(m, m.Attempts, err); err != nil {
+			c.log.Error("Could not dead letter mail to %s: %s", m.To, err)
+		}
+	}
+}
+
+// Close closes the emailer.
 func (c *Controller) Close() {
 	if err := c.emailer.Close(); err != nil {
 		c.log.Error("Could not close emailer successfully: %s", err)
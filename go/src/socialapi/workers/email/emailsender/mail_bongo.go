@@ -1,6 +1,8 @@
 // Package sender provides an API for mail sending operations
 package emailsender
 
+import "time"
+
 // Mail struct hold the required parameters for sending an email
 type Mail struct {
 	To      string
@@ -14,6 +16,21 @@ type Mail struct {
 	FromName   string
 	ReplyTo    string
 	Properties *Properties
+
+	// Template is the name of the template the mail is rendered with,
+	// mails without a template are sent through the event exporter.
+	Template string `json:",omitempty"`
+
+	// GroupName is the group the mail is sent on behalf of, it is used
+	// for selecting the email branding.
+	GroupName string `json:",omitempty"`
+
+	// Attempts is the number of failed delivery attempts of the mail.
+	Attempts int `json:",omitempty"`
+
+	// NotBefore is the earliest time the mail is delivered at, it is set
+	// when the mail is published again after a failed attempt.
+	NotBefore time.Time
 }
 
 type Properties struct {
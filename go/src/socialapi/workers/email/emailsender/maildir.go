@@ -0,0 +1,70 @@
+package emailsender
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaildirSender writes messages to a maildir instead of delivering them,
+// which is useful for local development.
+type MaildirSender struct {
+	Dir string
+}
+
+// NewMaildirSender creates a MaildirSender, ensuring the tmp, new and cur
+// subdirectories of dir exist.
+func NewMaildirSender(dir string) (*MaildirSender, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "koding-maildir")
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MaildirSender{Dir: dir}, nil
+}
+
+// Send implements the Sender interface. Messages are first written to tmp
+// and then moved to new, as the maildir format requires.
+func (s *MaildirSender) Send(m *Message) error {
+	p, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name, err := maildirName()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.Dir, "tmp", name)
+
+	if err := ioutil.WriteFile(tmp, p, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(s.Dir, "new", name))
+}
+
+func maildirName() (string, error) {
+	p := make([]byte, 8)
+
+	if _, err := rand.Read(p); err != nil {
+		return "", err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	return fmt.Sprintf("%d.%s.%s", time.Now().UnixNano(), hex.EncodeToString(p), host), nil
+}
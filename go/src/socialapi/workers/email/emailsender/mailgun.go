@@ -2,13 +2,7 @@
 package emailsender
 
 import (
-	"bytes"
-	"errors"
-	"fmt"
-	"html/template"
-	"koding/db/mongodb/modelhelper"
 	"socialapi/config"
-	texttemplate "text/template"
 
 	"github.com/koding/logging"
 	"github.com/mailgun/mailgun-go"
@@ -21,15 +15,16 @@ type EmailInvitationUser struct {
 	Pin             string
 }
 
+// MailgunSender delivers messages with Mailgun API.
 type MailgunSender struct {
-	Conf                   *config.Config
-	Mailgun                mailgun.Mailgun
-	TemplateTeamInvite     *template.Template
-	TemplateTeamInviteText *texttemplate.Template
-	VmHostname             string
-	Log                    logging.Logger
+	Conf       *config.Config
+	Mailgun    mailgun.Mailgun
+	VmHostname string
+	Log        logging.Logger
 }
 
+var _ Sender = (*MailgunSender)(nil)
+
 func NewMailgunSender(hostname string, log logging.Logger, conf *config.Config) *MailgunSender {
 	ms := &MailgunSender{}
 
@@ -38,72 +33,33 @@ func NewMailgunSender(hostname string, log logging.Logger, conf *config.Config)
 	ms.Conf = conf
 	ms.Mailgun = mailgun.NewMailgun(ms.Conf.Mailgun.Domain, ms.Conf.Mailgun.PrivateKey, ms.Conf.Mailgun.PublicKey)
 
-	ms.TemplateTeamInvite = template.Must(template.New("email").Parse(TemplateTeamInvite))
-	ms.TemplateTeamInviteText = texttemplate.Must(texttemplate.New("email").Parse(TemplateTeamInviteText))
-
 	return ms
 }
 
-func (m *MailgunSender) SendMailgunEmail(mail *Mail) error {
-	var subject string
-	var email string
-	var err error
-	var userId string
-	var tpl *template.Template
-	var tplText *texttemplate.Template
-	userObj := EmailInvitationUser{}
-
-	if mail.Properties.Options["subject"] == keyInvitedCreateTeam {
-		email = mail.Properties.Options["invitee"].(string)
-		userObj.UserID = email
-		userObj.Link = mail.Properties.Options["link"].(string)
-		subject = subjectInvitedCreateTeam
-		tpl = m.TemplateTeamInvite
-		tplText = m.TemplateTeamInviteText
-	} else {
-		return errors.New("Wrong subject in mailgun.go")
-	}
-
-	user, err := modelhelper.FetchUserByEmail(email)
-	if err == nil {
-		userId = user.ObjectId.Hex()
-	} else {
-		userId = "0"
-	}
+// Send implements the Sender interface.
+func (m *MailgunSender) Send(msg *Message) error {
+	message := mailgun.NewMessage(
+		msg.From,
+		msg.Subject,
+		msg.Text,
+		msg.To)
 
-	if user.EmailFrequency != nil && !user.EmailFrequency.Global {
-		return errors.New("User is unsubscribed from all emails")
+	if msg.HTML != "" {
+		message.SetHtml(msg.HTML)
 	}
 
-	userObj.LinkUnsubscribe = fmt.Sprintf("%s/Unsubscribe/%s/%s", m.VmHostname, userId, email)
-
-	buf := new(bytes.Buffer)
-	err = tpl.Execute(buf, userObj)
+	_, _, err := m.Mailgun.Send(message)
 	if err != nil {
-		m.Log.Error("Sending email template execute err: %s", err)
-		return err
-	}
-
-	bufText := new(bytes.Buffer)
-	err = tplText.Execute(bufText, userObj)
-
-	if err != nil {
-		m.Log.Error("Sending email template execute err: %s", err)
-		return err
-	}
+		m.Log.Error("Sending email err: %s", err)
 
-	message := mailgun.NewMessage(
-		emailFrom,
-		subject,
-		bufText.String(),
-		email)
+		// Mailgun responds with 400 to requests it can not parse, sending
+		// the same request again is not going to help.
+		if e, ok := err.(*mailgun.UnexpectedResponseError); ok && e.Actual == 400 {
+			return &RequestError{Err: err}
+		}
 
-	message.SetHtml(buf.String())
-	_, _, err = m.Mailgun.Send(message)
-	if err != nil {
-		m.Log.Error("Sending email err: %s", err)
 		return err
 	}
 
-	return err
+	return nil
 }
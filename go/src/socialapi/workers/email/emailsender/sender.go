@@ -0,0 +1,196 @@
+package emailsender
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"socialapi/config"
+	"strings"
+	"time"
+
+	"github.com/koding/logging"
+)
+
+// Message is a rendered email that is ready to be delivered.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers rendered messages.
+//
+// Senders should return a *PermanentError when the message can not be
+// delivered to the recipient at all, e.g. because the mailbox does not
+// exist; such messages are not retried and the address is suppressed.
+// A *RequestError is returned when the provider rejects the message
+// itself; such messages are not retried either, but the recipient is
+// left alone.
+type Sender interface {
+	Send(*Message) error
+}
+
+// PermanentError is returned by senders when retrying the delivery is not
+// going to succeed.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return "permanent delivery failure: " + e.Err.Error()
+}
+
+// IsPermanent returns true if err is a permanent delivery failure.
+func IsPermanent(err error) bool {
+	_, ok := err.(*PermanentError)
+	return ok
+}
+
+// RequestError is returned by senders when the provider rejects the
+// message as malformed, which says nothing about the recipient address.
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return "invalid delivery request: " + e.Err.Error()
+}
+
+// IsRequestError returns true if err is a rejected delivery request.
+func IsRequestError(err error) bool {
+	_, ok := err.(*RequestError)
+	return ok
+}
+
+// NewSender creates the sender selected by the Email.Sender configuration.
+func NewSender(hostname string, log logging.Logger, conf *config.Config) (Sender, error) {
+	switch conf.Email.Sender {
+	case "", "mailgun":
+		return NewMailgunSender(hostname, log, conf), nil
+	case "smtp":
+		return NewSMTPSender(conf), nil
+	case "maildir":
+		return NewMaildirSender(conf.Email.MaildirPath)
+	default:
+		return nil, fmt.Errorf("unknown email sender: %q", conf.Email.Sender)
+	}
+}
+
+// Bytes encodes the message in RFC 5322 format. Messages with both text and
+// HTML bodies are encoded as multipart/alternative.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	h := make(textproto.MIMEHeader)
+	h.Set("From", m.From)
+	h.Set("To", m.To)
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		if err := writeHeader(&buf, h); err != nil {
+			return nil, err
+		}
+
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+
+	h.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+
+	if err := writeHeader(&buf, h); err != nil {
+		return nil, err
+	}
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Recipient returns the bare address of the message recipient.
+func (m *Message) Recipient() (string, error) {
+	return addressOf(m.To)
+}
+
+// addressOf returns the bare address from the "Name <address>" form.
+func addressOf(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.Address, nil
+}
+
+// writeHeader writes the header fields, values with line breaks are
+// rejected as they would inject additional fields.
+func writeHeader(buf *bytes.Buffer, h textproto.MIMEHeader) error {
+	for _, key := range []string{"From", "To", "Subject", "Date", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		v := h.Get(key)
+		if v == "" {
+			continue
+		}
+
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("invalid %s header: contains line break", key)
+		}
+
+		fmt.Fprintf(buf, "%s: %s\r\n", key, v)
+	}
+
+	buf.WriteString("\r\n")
+
+	return nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qw := quotedprintable.NewWriter(w)
+
+	if _, err := qw.Write([]byte(s)); err != nil {
+		return err
+	}
+
+	return qw.Close()
+}
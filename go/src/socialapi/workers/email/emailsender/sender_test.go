@@ -0,0 +1,230 @@
+package emailsender
+
+import (
+	"errors"
+	"io/ioutil"
+	"koding/db/models"
+	"os"
+	"path/filepath"
+	"socialapi/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koding/eventexporter"
+	"github.com/koding/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeSender struct {
+	errs []error
+	sent []*Message
+}
+
+func (s *fakeSender) Send(m *Message) error {
+	s.sent = append(s.sent, m)
+
+	if len(s.errs) == 0 {
+		return nil
+	}
+
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+type fakeStore struct {
+	branding    *models.EmailBranding
+	suppressed  map[string]string
+	deadLetters []*Mail
+}
+
+func (s *fakeStore) Branding(string) (*models.EmailBranding, error) { return s.branding, nil }
+
+func (s *fakeStore) IsSuppressed(email string) (bool, error) {
+	_, ok := s.suppressed[email]
+	return ok, nil
+}
+
+func (s *fakeStore) Suppress(email, reason, _ string) error {
+	s.suppressed[email] = reason
+	return nil
+}
+
+func (s *fakeStore) DeadLetter(m *Mail, _ int, _ error) error {
+	s.deadLetters = append(s.deadLetters, m)
+	return nil
+}
+
+func newTemplateMail() *Mail {
+	m := NewMail("rodin@koding.com", "", "", "rodin")
+	m.Template = TemplateTeamInviteName
+	m.GroupName = "kodingteam"
+	m.Properties.Options["link"] = "https://koding.com/Teams"
+	return m
+}
+
+func TestDeliver(t *testing.T) {
+	defer func(n int) { MaxAttempts = n }(MaxAttempts)
+
+	conf := &config.Config{}
+	conf.Email.DefaultFromMail = "hello@acme.com"
+	log := logging.NewLogger("emailsender_test")
+
+	Convey("Given a controller with a fake sender", t, func() {
+		MaxAttempts = 3
+
+		sender := &fakeSender{}
+		store := &fakeStore{suppressed: make(map[string]string)}
+		c := NewWithSender(eventexporter.NewFakeExporter(), log, conf, sender, store)
+
+		var delays []time.Duration
+		c.retry = func(m *Mail, after time.Duration) {
+			delays = append(delays, after)
+			c.Process(m)
+		}
+
+		Convey("Templated mail should be rendered with group branding", func() {
+			store.branding = &models.EmailBranding{Name: "Acme", Color: "#ff0000"}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent, ShouldHaveLength, 1)
+
+			msg := sender.sent[0]
+			So(msg.To, ShouldEqual, "rodin@koding.com")
+			So(msg.From, ShouldEqual, `"Devrim" <dy@koding.com>`)
+			So(msg.HTML, ShouldContainSubstring, "background:#ff0000")
+			So(msg.HTML, ShouldContainSubstring, "Acme For Teams!")
+			So(msg.Text, ShouldContainSubstring, "https://koding.com/Teams")
+			So(msg.Text, ShouldEndWith, "Team Acme")
+		})
+
+		Convey("Sender of the branding should be encoded", func() {
+			store.branding = &models.EmailBranding{FromName: "Acme, Inc.", FromEmail: "hello@acme.com"}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent[0].From, ShouldEqual, `"Acme, Inc." <hello@acme.com>`)
+		})
+
+		Convey("Sender outside of verified domains should not be used", func() {
+			store.branding = &models.EmailBranding{FromName: "Acme", FromEmail: "ceo@example.com"}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent[0].From, ShouldEqual, `"Acme" <dy@koding.com>`)
+		})
+
+		Convey("Sender with line breaks should be rejected", func() {
+			store.branding = &models.EmailBranding{FromName: "Acme\r\nBcc: victim@example.com"}
+
+			So(c.Process(newTemplateMail()), ShouldEqual, ErrInvalidFrom)
+			So(sender.sent, ShouldBeEmpty)
+		})
+
+		Convey("Group template should override the built-in one", func() {
+			store.branding = &models.EmailBranding{
+				Templates: map[string]*models.EmailTemplate{
+					TemplateTeamInviteName: {Subject: "Join {{ .Branding.Name }}"},
+				},
+			}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent[0].Subject, ShouldEqual, "Join Koding")
+			So(sender.sent[0].HTML, ShouldContainSubstring, "Koding For Teams!")
+		})
+
+		Convey("Temporary failures should be retried", func() {
+			sender.errs = []error{errors.New("timeout"), errors.New("timeout")}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent, ShouldHaveLength, 3)
+			So(delays, ShouldResemble, []time.Duration{time.Second, 2 * time.Second})
+			So(store.deadLetters, ShouldBeEmpty)
+		})
+
+		Convey("Permanent failure should suppress the address", func() {
+			sender.errs = []error{&PermanentError{Err: errors.New("no such user")}}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(store.suppressed["rodin@koding.com"], ShouldEqual, models.EmailSuppressionBounce)
+
+			Convey("Further mails should not be sent", func() {
+				So(c.Process(newTemplateMail()), ShouldBeNil)
+				So(sender.sent, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("Rejected request should be dead lettered without suppressing the address", func() {
+			sender.errs = []error{&RequestError{Err: errors.New("bad request")}}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent, ShouldHaveLength, 1)
+			So(store.deadLetters, ShouldHaveLength, 1)
+			So(store.suppressed, ShouldBeEmpty)
+		})
+
+		Convey("Mail should be dead lettered after retries are exhausted", func() {
+			sender.errs = []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")}
+
+			So(c.Process(newTemplateMail()), ShouldBeNil)
+			So(sender.sent, ShouldHaveLength, 3)
+			So(store.deadLetters, ShouldHaveLength, 1)
+			So(store.deadLetters[0].Attempts, ShouldEqual, 3)
+		})
+	})
+}
+
+func TestMaildirSender(t *testing.T) {
+	Convey("Given a maildir sender", t, func() {
+		dir, err := ioutil.TempDir("", "emailsender")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		s, err := NewMaildirSender(dir)
+		So(err, ShouldBeNil)
+
+		Convey("Message should be written to new directory", func() {
+			err := s.Send(&Message{
+				From:    "Koding <hello@koding.com>",
+				To:      "rodin@koding.com",
+				Subject: "Hello",
+				Text:    "text body",
+				HTML:    "<p>html body</p>",
+			})
+			So(err, ShouldBeNil)
+
+			files, err := filepath.Glob(filepath.Join(dir, "new", "*"))
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+
+			p, err := ioutil.ReadFile(files[0])
+			So(err, ShouldBeNil)
+			So(string(p), ShouldContainSubstring, "Subject: Hello")
+			So(strings.Count(string(p), "Content-Type: text/"), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestVerifyMailgunSignature(t *testing.T) {
+	Convey("Given a Mailgun webhook signature", t, func() {
+		const sig = "68c6279fa433d1441b5eb165ed46a4e4a4d5f08315faa0d29dc4fa8634dc7788"
+
+		Convey("Valid signature should be accepted", func() {
+			So(VerifyMailgunSignature("key", "1", "token", sig), ShouldBeTrue)
+		})
+
+		Convey("Invalid signature should be rejected", func() {
+			So(VerifyMailgunSignature("other", "1", "token", sig), ShouldBeFalse)
+			So(VerifyMailgunSignature("key", "2", "token", sig), ShouldBeFalse)
+			So(VerifyMailgunSignature("key", "1", "token", "not hex"), ShouldBeFalse)
+		})
+
+		Convey("Stale timestamp should be rejected", func() {
+			now := time.Unix(1500000000, 0)
+
+			So(VerifyMailgunTimestamp("1500000000", now), ShouldBeTrue)
+			So(VerifyMailgunTimestamp("1499999500", now), ShouldBeTrue)
+			So(VerifyMailgunTimestamp("1", now), ShouldBeFalse)
+			So(VerifyMailgunTimestamp("not a number", now), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package emailsender
+
+import (
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"socialapi/config"
+)
+
+// SMTPSender delivers messages through an SMTP relay.
+type SMTPSender struct {
+	Addr string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender creates a new SMTPSender. When Email.Username is set, the
+// sender authenticates with PLAIN auth.
+func NewSMTPSender(conf *config.Config) *SMTPSender {
+	s := &SMTPSender{
+		Addr: conf.Email.SMTPAddr,
+	}
+
+	if conf.Email.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			host = s.Addr
+		}
+
+		s.Auth = smtp.PlainAuth("", conf.Email.Username, conf.Email.Password, host)
+	}
+
+	return s
+}
+
+// Send implements the Sender interface.
+func (s *SMTPSender) Send(m *Message) error {
+	from, err := addressOf(m.From)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	to, err := m.Recipient()
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	p, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	err = smtp.SendMail(s.Addr, s.Auth, from, []string{to}, p)
+
+	// 5xx replies are permanent failures, e.g. unknown mailbox.
+	if e, ok := err.(*textproto.Error); ok && e.Code >= 500 {
+		return &PermanentError{Err: err}
+	}
+
+	return err
+}
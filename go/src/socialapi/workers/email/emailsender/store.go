@@ -0,0 +1,81 @@
+package emailsender
+
+import (
+	"encoding/json"
+	"koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Store persists the state the controller needs for sending emails.
+type Store interface {
+	// Branding returns email branding of the given group, nil when group
+	// did not customize its emails.
+	Branding(group string) (*models.EmailBranding, error)
+
+	// IsSuppressed returns true if no emails should be sent to the
+	// given address.
+	IsSuppressed(email string) (bool, error)
+
+	// Suppress marks the address as undeliverable.
+	Suppress(email, reason, details string) error
+
+	// DeadLetter stores the mail that could not be delivered.
+	DeadLetter(m *Mail, attempts int, err error) error
+}
+
+// MongoStore is a Store backed by MongoDB.
+type MongoStore struct{}
+
+var _ Store = MongoStore{}
+
+// Branding implements the Store interface.
+func (MongoStore) Branding(group string) (*models.EmailBranding, error) {
+	if group == "" {
+		return nil, nil
+	}
+
+	b, err := modelhelper.FetchEmailBranding(group)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+
+	return b, err
+}
+
+// IsSuppressed implements the Store interface.
+func (MongoStore) IsSuppressed(email string) (bool, error) {
+	_, err := modelhelper.GetEmailSuppression(email)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Suppress implements the Store interface.
+func (MongoStore) Suppress(email, reason, details string) error {
+	return modelhelper.SuppressEmail(email, reason, details)
+}
+
+// DeadLetter implements the Store interface.
+func (MongoStore) DeadLetter(m *Mail, attempts int, err error) error {
+	p, e := json.Marshal(m)
+	if e != nil {
+		return e
+	}
+
+	return modelhelper.CreateEmailDeadLetter(&models.EmailDeadLetter{
+		To:        m.To,
+		Subject:   m.Subject,
+		Mail:      p,
+		Error:     err.Error(),
+		Attempts:  attempts,
+		CreatedAt: time.Now().UTC(),
+	})
+}
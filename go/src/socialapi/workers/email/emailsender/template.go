@@ -0,0 +1,237 @@
+package emailsender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"koding/db/models"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+)
+
+// ErrInvalidFrom is returned when the sender of the branding contains
+// characters that are not allowed in mail headers.
+var ErrInvalidFrom = errors.New("invalid sender name or address")
+
+// TemplateTeamInviteName is the name of the team invitation template.
+const TemplateTeamInviteName = "teamInvite"
+
+// DefaultBranding is used for emails which are not sent on behalf of a
+// group, and to fill the fields a group did not customize.
+var DefaultBranding = &models.EmailBranding{
+	Name:      "Koding",
+	LogoURL:   "http://cdn2.hubspot.net/hubfs/1593820/logo_emailHeader.png",
+	Color:     "#1aaf5b",
+	FromName:  "Devrim",
+	FromEmail: "dy@koding.com",
+}
+
+// builtinTemplates are used unless a group overrides them.
+var builtinTemplates = map[string]*models.EmailTemplate{
+	TemplateTeamInviteName: {
+		Subject: subjectInvitedCreateTeam,
+		HTML:    TemplateTeamInvite,
+		Text:    TemplateTeamInviteText,
+	},
+}
+
+// TemplateData is the data email templates are executed with.
+type TemplateData struct {
+	EmailInvitationUser
+
+	Branding *models.EmailBranding
+	Options  map[string]interface{}
+}
+
+// compiledTemplate is a parsed EmailTemplate.
+type compiledTemplate struct {
+	subject *texttemplate.Template
+	html    *template.Template
+	text    *texttemplate.Template
+}
+
+// Templates renders messages out of built-in or group specific templates.
+type Templates struct {
+	builtin map[string]*compiledTemplate
+	domains map[string]struct{}
+}
+
+// NewTemplates parses all built-in templates. Sender addresses of
+// brandings are allowed only on the given verified domains, in addition
+// to the domain of the DefaultBranding.
+func NewTemplates(verifiedDomains ...string) *Templates {
+	t := &Templates{
+		builtin: make(map[string]*compiledTemplate, len(builtinTemplates)),
+		domains: map[string]struct{}{
+			domainOf(DefaultBranding.FromEmail): {},
+		},
+	}
+
+	for _, domain := range verifiedDomains {
+		if domain != "" {
+			t.domains[strings.ToLower(domain)] = struct{}{}
+		}
+	}
+
+	for name, tmpl := range builtinTemplates {
+		ct, err := compile(name, tmpl)
+		if err != nil {
+			panic(err)
+		}
+
+		t.builtin[name] = ct
+	}
+
+	return t
+}
+
+// Render executes the named template. When the branding provides
+// a template with the same name it is used instead of the built-in one.
+func (t *Templates) Render(name string, to string, data *TemplateData) (*Message, error) {
+	data.Branding = Brand(data.Branding)
+
+	from, err := t.from(data.Branding)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := t.lookup(name, data.Branding)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, html, text bytes.Buffer
+
+	if err := ct.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+
+	if ct.html != nil {
+		if err := ct.html.Execute(&html, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if ct.text != nil {
+		if err := ct.text.Execute(&text, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Message{
+		From:    from,
+		To:      to,
+		Subject: subject.String(),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// from formats the sender of the branding. Addresses outside of the
+// verified domains are replaced with the default one, as mails sent
+// from them would be spoofed.
+func (t *Templates) from(b *models.EmailBranding) (string, error) {
+	if strings.ContainsAny(b.FromName+b.FromEmail, "\r\n") {
+		return "", ErrInvalidFrom
+	}
+
+	addr := &mail.Address{
+		Name:    b.FromName,
+		Address: b.FromEmail,
+	}
+
+	if _, ok := t.domains[domainOf(addr.Address)]; !ok {
+		addr.Address = DefaultBranding.FromEmail
+	}
+
+	if _, err := mail.ParseAddress(addr.Address); err != nil {
+		return "", ErrInvalidFrom
+	}
+
+	return addr.String(), nil
+}
+
+func (t *Templates) lookup(name string, b *models.EmailBranding) (*compiledTemplate, error) {
+	if tmpl, ok := b.Templates[name]; ok && tmpl != nil {
+		// Fill missing parts of the custom template with built-in ones.
+		if builtin, ok := builtinTemplates[name]; ok {
+			tmpl = &models.EmailTemplate{
+				Subject: nonempty(tmpl.Subject, builtin.Subject),
+				HTML:    nonempty(tmpl.HTML, builtin.HTML),
+				Text:    nonempty(tmpl.Text, builtin.Text),
+			}
+		}
+
+		return compile(name, tmpl)
+	}
+
+	ct, ok := t.builtin[name]
+	if !ok {
+		return nil, fmt.Errorf("email template %q not found", name)
+	}
+
+	return ct, nil
+}
+
+func compile(name string, tmpl *models.EmailTemplate) (*compiledTemplate, error) {
+	var (
+		ct  = &compiledTemplate{}
+		err error
+	)
+
+	if ct.subject, err = texttemplate.New(name + ".subject").Parse(tmpl.Subject); err != nil {
+		return nil, err
+	}
+
+	if tmpl.HTML != "" {
+		if ct.html, err = template.New(name + ".html").Parse(tmpl.HTML); err != nil {
+			return nil, err
+		}
+	}
+
+	if tmpl.Text != "" {
+		if ct.text, err = texttemplate.New(name + ".text").Parse(tmpl.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	return ct, nil
+}
+
+// Brand returns a copy of b with empty fields set to the DefaultBranding
+// ones.
+func Brand(b *models.EmailBranding) *models.EmailBranding {
+	if b == nil {
+		b = &models.EmailBranding{}
+	}
+
+	return &models.EmailBranding{
+		Name:      nonempty(b.Name, DefaultBranding.Name),
+		LogoURL:   nonempty(b.LogoURL, DefaultBranding.LogoURL),
+		Color:     nonempty(b.Color, DefaultBranding.Color),
+		FromName:  nonempty(b.FromName, DefaultBranding.FromName),
+		FromEmail: nonempty(b.FromEmail, DefaultBranding.FromEmail),
+		Footer:    nonempty(b.Footer, DefaultBranding.Footer),
+		Templates: b.Templates,
+	}
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		return strings.ToLower(address[i+1:])
+	}
+
+	return ""
+}
+
+func nonempty(s ...string) string {
+	for _, s := range s {
+		if s != "" {
+			return s
+		}
+	}
+
+	return ""
+}
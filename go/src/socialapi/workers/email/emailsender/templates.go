@@ -39,7 +39,7 @@ var TemplateTeamInvite = `<html>
     <div style="background: #fafafa; color: #3c3c3c; font-family: 'HelveticaNeue', 'Helvetica Neue', Helvetica, Arial, 'Lucida Grande', sans-serif; font-size: 16px; padding: 40px 0;">
       <div style="margin: 0 auto; max-width: 700px;">
         <div style="margin: 0 0 31px; text-align: center;">
-          <img alt="Koding logo" src="http://cdn2.hubspot.net/hubfs/1593820/logo_emailHeader.png" height="36" />
+          <img alt="{{ .Branding.Name }} logo" src="{{ .Branding.LogoURL }}" height="36" />
         </div>
         <div>
           <div style="color: #565656; background: #fff; border: 1px solid #e0e0e0; border-radius: 3px; padding: 35px 45px 41px; max-width: 575px; margin-right: auto; margin-left: auto;">
@@ -81,7 +81,7 @@ body .wysiwyg-font-size-smaller {
         <tbody>
         <tr>
             <td>
-                <span style="color:#565656;font-size:28px;display:block;margin-top:10px;font-weight:600;">Koding For Teams!</span>
+                <span style="color:#565656;font-size:28px;display:block;margin-top:10px;font-weight:600;">{{ .Branding.Name }} For Teams!</span>
             </td>
         </tr>
         </tbody>
@@ -93,7 +93,7 @@ body .wysiwyg-font-size-smaller {
         Your team will have access to new features that help you collaborate and get set up faster. Pair program in the cloud or setup a new hire's dev-environment automatically; then use all that timed saved however you want.
     </p>
     <div style="width:100%;text-align:center;">
-        <a href="{{ .Link }}" style="width:auto;padding:10px 45px;background:#1aaf5b;font-size:14px;text-decoration:none;color:white;border-radius:3px;line-height:25px;display:inline-block;letter-spacing:1px;" target="_blank">
+        <a href="{{ .Link }}" style="width:auto;padding:10px 45px;background:{{ .Branding.Color }};font-size:14px;text-decoration:none;color:white;border-radius:3px;line-height:25px;display:inline-block;letter-spacing:1px;" target="_blank">
         GET STARTED
         </a>
     </div>
@@ -127,7 +127,7 @@ body .wysiwyg-font-size-smaller {
 </div>
 
             <p style="line-height: 23px; margin: 0; margin-top: 30px; padding: 0;">
-                <span style="color:#565656;font-size:14px;font-family:'HelveticaNeue-Light', 'Helvetica Neue Light', 'Helvetica Neue', Helvetica, Arial, 'Lucida Grande', sans-serif;font-weight:300;">&#58; &#62;<br />Team Koding</span>
+                <span style="color:#565656;font-size:14px;font-family:'HelveticaNeue-Light', 'Helvetica Neue Light', 'Helvetica Neue', Helvetica, Arial, 'Lucida Grande', sans-serif;font-weight:300;">&#58; &#62;<br />Team {{ .Branding.Name }}</span>
             </p>
           </div>
         </div>
@@ -172,4 +172,4 @@ Features you'll only find on Koding For Teams:
 * Pair Program with chat & video
 
 : >
-Team Koding`
+Team {{ .Branding.Name }}`
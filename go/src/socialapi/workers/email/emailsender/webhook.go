@@ -0,0 +1,82 @@
+package emailsender
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"koding/db/models"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook     = errors.New("webhook timestamp is too old")
+)
+
+// MaxWebhookAge is how far the timestamp of a signed webhook request may
+// be from the current time, older requests are considered replayed.
+var MaxWebhookAge = 15 * time.Minute
+
+// mailgunEvents maps Mailgun webhook events to suppression reasons.
+var mailgunEvents = map[string]string{
+	"bounced":    models.EmailSuppressionBounce,
+	"dropped":    models.EmailSuppressionBounce,
+	"complained": models.EmailSuppressionComplaint,
+}
+
+// VerifyMailgunSignature checks whether the webhook request was signed
+// by Mailgun with the given API key.
+func VerifyMailgunSignature(apiKey, timestamp, token, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte(timestamp + token))
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// VerifyMailgunTimestamp checks whether the Unix timestamp of the webhook
+// request is within MaxWebhookAge from now.
+func VerifyMailgunTimestamp(timestamp string, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	d := now.Sub(time.Unix(sec, 0))
+	if d < 0 {
+		d = -d
+	}
+
+	return d <= MaxWebhookAge
+}
+
+// HandleMailgunEvent suppresses the recipient of the bounce or complaint
+// event sent by Mailgun webhook. Other events are ignored.
+func HandleMailgunEvent(apiKey string, form url.Values, store Store) error {
+	if !VerifyMailgunSignature(apiKey, form.Get("timestamp"), form.Get("token"), form.Get("signature")) {
+		return ErrInvalidSignature
+	}
+
+	if !VerifyMailgunTimestamp(form.Get("timestamp"), time.Now()) {
+		return ErrStaleWebhook
+	}
+
+	reason, ok := mailgunEvents[form.Get("event")]
+	if !ok {
+		return nil
+	}
+
+	details := form.Get("error")
+	if details == "" {
+		details = form.Get("description")
+	}
+
+	return store.Suppress(form.Get("recipient"), reason, details)
+}
@@ -9,7 +9,16 @@ import (
 	"github.com/koding/runner"
 )
 
-func Parse(u *url.URL, h http.Header, req *models.Mail) (int, http.Header, interface{}, error) {
+// Parser handles inbound mails forwarded by the mail server, which checks
+// the authenticity of their senders.
+type Parser struct {
+	// AuthServID is the authserv-id of the inbound mail server, see
+	// (*models.Mail).Authentication for details.
+	AuthServID string
+}
+
+// Parse handles mails sent as JSON by inbound providers.
+func (p *Parser) Parse(u *url.URL, h http.Header, req *models.Mail) (int, http.Header, interface{}, error) {
 	if err := req.Validate(); err != nil {
 		runner.MustGetLogger().Error("mail parse validate err : %S", err.Error())
 		// faily silently, we dont want mail parser service to retry on
@@ -17,9 +26,51 @@ func Parse(u *url.URL, h http.Header, req *models.Mail) (int, http.Header, inter
 		return response.NewDefaultOK()
 	}
 
+	// older inbound providers do not forward headers, verify sender only
+	// when we have them
+	if len(req.Headers) != 0 {
+		if err := req.Verify(p.AuthServID); err != nil {
+			runner.MustGetLogger().Error("mail parse verify err for %s: %s", req.From, err)
+			return response.NewDefaultOK()
+		}
+	}
+
 	if err := req.Persist(); err != nil {
 		return response.NewBadRequest(err)
 	}
 
 	return response.NewDefaultOK()
 }
+
+// ParseMIME handles raw RFC 5322 messages, e.g. piped from an SMTP relay
+// or read from a maildir.
+func (p *Parser) ParseMIME(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	m, err := models.ParseMIME(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := m.Validate(); err != nil {
+		runner.MustGetLogger().Error("mail parse validate err : %s", err.Error())
+		// faily silently, we dont want relay to retry on the failed
+		// validation
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := m.Verify(p.AuthServID); err != nil {
+		runner.MustGetLogger().Error("mail parse verify err for %s: %s", m.From, err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := m.Persist(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,17 +1,39 @@
 package api
 
 import (
+	"errors"
+	"socialapi/config"
 	"socialapi/workers/common/handler"
 	"socialapi/workers/common/mux"
 )
 
-func AddHandlers(m *mux.Mux) {
+// AddHandlers adds the inbound mail handlers to the given Muxer. It fails
+// when the authserv-id of the inbound mail server is not configured, as
+// senders of the mails could not be verified.
+func AddHandlers(m *mux.Mux, c *config.Config) error {
+	if c.Email.AuthServID == "" {
+		return errors.New("mail parser: email authServID is not configured")
+	}
+
+	p := &Parser{AuthServID: c.Email.AuthServID}
+
 	m.AddHandler(
 		handler.Request{
-			Handler:  Parse,
+			Handler:  p.Parse,
 			Name:     "mail-parse",
 			Type:     handler.PostRequest,
 			Endpoint: "/mail/parse",
 		},
 	)
+
+	m.AddUnscopedHandler(
+		handler.Request{
+			Handler:  p.ParseMIME,
+			Name:     "mail-parse-mime",
+			Type:     handler.PostRequest,
+			Endpoint: "/mail/parse/mime",
+		},
+	)
+
+	return nil
 }
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Results of the sender authentication checks as reported by the receiving
+// mail server in Authentication-Results (RFC 7601) and Received-SPF
+// (RFC 7208) headers.
+const (
+	AuthPass      = "pass"
+	AuthFail      = "fail"
+	AuthSoftFail  = "softfail"
+	AuthNeutral   = "neutral"
+	AuthNone      = "none"
+	AuthTempError = "temperror"
+	AuthPermError = "permerror"
+)
+
+var (
+	ErrDKIMFailed       = errors.New("DKIM verification failed")
+	ErrSPFFailed        = errors.New("SPF verification failed")
+	ErrNotAuthenticated = errors.New("sender is not authenticated")
+)
+
+// AuthResults holds the outcome of the DKIM and SPF checks for a mail.
+type AuthResults struct {
+	// DKIM is the result of the DKIM signature verification.
+	DKIM string
+
+	// DKIMDomain is the signing domain (the "d=" tag) of the DKIM
+	// signature that was verified.
+	DKIMDomain string
+
+	// SPF is the result of the SPF check.
+	SPF string
+
+	// SPFDomain is the domain of the envelope sender (smtp.mailfrom)
+	// the SPF check was performed for.
+	SPFDomain string
+}
+
+// Authentication reads the DKIM and SPF results from the mail headers.
+//
+// Mail servers prepend their headers, thus only the topmost ones can be
+// attributed to the server in front of the parser - the rest may have been
+// added by the sender. The results are read from:
+//
+//   - the topmost Authentication-Results header with the given authserv-id,
+//     the server is required to remove the ones with its own id that were
+//     not added by itself (RFC 7601, section 5)
+//   - the topmost Received-SPF header, when its receiver is the given
+//     authserv-id
+//
+// SPF result of Authentication-Results takes precedence over Received-SPF
+// one. When authservID is empty, no header is trusted.
+func (m *Mail) Authentication(authservID string) *AuthResults {
+	res := &AuthResults{
+		DKIM: AuthNone,
+		SPF:  AuthNone,
+	}
+
+	if authservID == "" {
+		return res
+	}
+
+	authservID = strings.ToLower(authservID)
+
+	var authResults, receivedSPF *Header
+
+	for i, h := range m.Headers {
+		switch strings.ToLower(h.Name) {
+		case "authentication-results":
+			if authResults == nil && authservIDOf(h.Value) == authservID {
+				authResults = &m.Headers[i]
+			}
+		case "received-spf":
+			if receivedSPF == nil {
+				receivedSPF = &m.Headers[i]
+			}
+		}
+	}
+
+	if authResults != nil {
+		parseAuthenticationResults(authResults.Value, domainOf(m.From), res)
+	}
+
+	if receivedSPF != nil && res.SPF == AuthNone {
+		if strings.ToLower(receivedSPFKey(receivedSPF.Value, "receiver")) == authservID {
+			res.SPF = firstWord(receivedSPF.Value)
+			res.SPFDomain = strings.Trim(receivedSPFKey(receivedSPF.Value, "envelope-from"), "<>\"")
+		}
+	}
+
+	return res
+}
+
+// Verify checks whether the sender of the mail was authenticated by either
+// DKIM or SPF. Mails without any authentication headers are considered
+// unauthenticated.
+//
+// Both DKIM and SPF results authenticate only the domain they were
+// performed for, thus they are accepted only when the domain matches
+// the one of the From address. As with DMARC, a single aligned pass is
+// enough, e.g. forwarded mails fail SPF but keep a valid DKIM signature.
+func (m *Mail) Verify(authservID string) error {
+	res := m.Authentication(authservID)
+	from := domainOf(m.From)
+
+	if res.DKIM == AuthPass && domainOf(res.DKIMDomain) == from {
+		return nil
+	}
+
+	if res.SPF == AuthPass && domainOf(res.SPFDomain) == from {
+		return nil
+	}
+
+	switch res.DKIM {
+	case AuthFail, AuthPermError:
+		return ErrDKIMFailed
+	}
+
+	switch res.SPF {
+	case AuthFail, AuthSoftFail, AuthPermError:
+		return ErrSPFFailed
+	}
+
+	return ErrNotAuthenticated
+}
+
+// parseAuthenticationResults parses header in the following format:
+//
+//	mx.koding.com; dkim=pass header.d=koding.com; spf=pass smtp.mailfrom=...
+//
+// When header contains multiple results for the same method, e.g. a mail
+// signed more than once, a passing one for the given domain is preferred,
+// followed by any passing one and the first one.
+func parseAuthenticationResults(header, domain string, res *AuthResults) {
+	fields := strings.Split(header, ";")
+
+	dkimRank, spfRank := 0, 0
+
+	for _, field := range fields[1:] {
+		words := strings.Fields(field)
+		if len(words) == 0 {
+			continue
+		}
+
+		method, result := keyValue(words[0])
+		result = strings.ToLower(result)
+
+		switch strings.ToLower(method) {
+		case "dkim":
+			d := propertyOf(words[1:], "header.d")
+
+			if r := rank(result, d, domain); r > dkimRank {
+				dkimRank = r
+				res.DKIM, res.DKIMDomain = result, d
+			}
+		case "spf":
+			d := propertyOf(words[1:], "smtp.mailfrom")
+
+			if r := rank(result, d, domain); r > spfRank {
+				spfRank = r
+				res.SPF, res.SPFDomain = result, d
+			}
+		}
+	}
+}
+
+// rank orders results of the same method by preference.
+func rank(result, resultDomain, domain string) int {
+	switch {
+	case result == AuthPass && domainOf(resultDomain) == domain:
+		return 3
+	case result == AuthPass:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// authservIDOf reads the authserv-id of Authentication-Results header,
+// which may be followed by an optional version.
+func authservIDOf(header string) string {
+	fields := strings.Split(header, ";")
+	if len(fields) < 2 {
+		return ""
+	}
+
+	return firstWord(fields[0])
+}
+
+func propertyOf(words []string, key string) string {
+	for _, word := range words {
+		if k, v := keyValue(word); strings.ToLower(k) == key {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// receivedSPFKey reads the value of the given key from header in the
+// following format:
+//
+//	Pass (comment) client-ip=10.0.0.1; envelope-from=<rodin@koding.com>; receiver=mx.koding.com
+func receivedSPFKey(header, key string) string {
+	for _, field := range strings.FieldsFunc(header, func(r rune) bool { return r == ';' || r == ' ' }) {
+		if k, v := keyValue(field); strings.ToLower(k) == key {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func keyValue(s string) (key, value string) {
+	i := strings.IndexRune(s, '=')
+	if i == -1 {
+		return s, ""
+	}
+
+	return s[:i], s[i+1:]
+}
+
+func firstWord(s string) string {
+	if f := strings.Fields(s); len(f) != 0 {
+		return strings.ToLower(f[0])
+	}
+
+	return ""
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		address = address[i+1:]
+	}
+
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	mongomodels "koding/db/models"
 	"koding/db/mongodb/modelhelper"
@@ -33,8 +34,19 @@ type Mail struct {
 
 	// StrippedTextReply is message body if the message is reply (not post)
 	StrippedTextReply string
+
+	// Headers holds the headers of the message
+	Headers []Header
+
+	// Attachments holds the files attached to the message
+	Attachments []Attachment
 }
 
+// MaxAttachmentsSize is the maximum total size of attachments that are
+// stored within the message payload, attachments above the limit are
+// dropped.
+const MaxAttachmentsSize = 5 << 20
+
 // errors
 var (
 	ErrNotValid          = errors.New("no valid content")
@@ -130,6 +142,9 @@ func (m *Mail) persistPost(accountId int64) error {
 
 	cm := socialapimodels.NewChannelMessage()
 	cm.Body = m.TextBody // set the body
+	if err := m.setAttachments(cm); err != nil {
+		return err
+	}
 
 	cm.TypeConstant = socialapimodels.ChannelMessage_TYPE_POST
 	if c.TypeConstant == socialapimodels.Channel_TYPE_PRIVATE_MESSAGE {
@@ -174,6 +189,13 @@ func (m *Mail) persistReply(accountId int64) error {
 	// create reply
 	reply := socialapimodels.NewChannelMessage()
 	reply.Body = m.StrippedTextReply // set the body
+	if reply.Body == "" {
+		reply.Body = StripReply(m.TextBody)
+	}
+
+	if err := m.setAttachments(reply); err != nil {
+		return err
+	}
 
 	reply.TypeConstant = socialapimodels.ChannelMessage_TYPE_REPLY
 	if cm.TypeConstant == socialapimodels.ChannelMessage_TYPE_PRIVATE_MESSAGE {
@@ -196,6 +218,42 @@ func (m *Mail) persistReply(accountId int64) error {
 	return nil
 }
 
+// setAttachments stores attachments of the mail as JSON encoded list under
+// the "attachments" key of the message payload.
+func (m *Mail) setAttachments(cm *socialapimodels.ChannelMessage) error {
+	if len(m.Attachments) == 0 {
+		return nil
+	}
+
+	var (
+		attachments []Attachment
+		size        int
+	)
+
+	for _, a := range m.Attachments {
+		if size+len(a.Content) > MaxAttachmentsSize {
+			runner.MustGetLogger().Warning("dropping attachment %q of %s: size limit exceeded", a.Name, m.From)
+			continue
+		}
+
+		size += len(a.Content)
+		attachments = append(attachments, a)
+	}
+
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	p, err := json.Marshal(attachments)
+	if err != nil {
+		return err
+	}
+
+	cm.SetPayload("attachments", string(p))
+
+	return nil
+}
+
 // getSocialIdFromEmail fetchs the SocialApiId in mongodb At this time, we got
 // the account id while getting SocialApiId
 func (m *Mail) getSocialIdFromEmail() (int64, error) {
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// MaxMIMESize is the maximum size of a raw MIME message that ParseMIME
+// is going to read.
+const MaxMIMESize = 25 << 20
+
+// maxMultipartDepth limits nesting of multipart entities, so a crafted
+// message can not make the parser recurse forever.
+const maxMultipartDepth = 8
+
+// inboundHeaders lists the headers, in order of preference, that are
+// looked up for the inbound address the message was delivered to.
+var inboundHeaders = []string{
+	"X-Original-To",
+	"Delivered-To",
+	"To",
+	"Cc",
+}
+
+var errMultipartTooDeep = errors.New("multipart nesting is too deep")
+
+// Attachment represents a single file attached to the mail. The fields
+// follow the format Postmark uses for inbound attachments, so the same
+// struct works for both JSON and MIME inputs.
+type Attachment struct {
+	// Name is the file name of the attachment.
+	Name string
+
+	// ContentType is the media type of the attachment.
+	ContentType string
+
+	// Content holds the base64 encoded attachment data.
+	Content string
+
+	// ContentLength is the size of the decoded attachment data.
+	ContentLength int
+}
+
+// Header represents a single mail header.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// ParseMIME reads a raw RFC 5322 message from r and converts it to a Mail.
+//
+// Plain text bodies are preferred over HTML ones, which are converted to
+// text when they are the only body available. Non-inline parts
+// are stored as attachments. StrippedTextReply is populated by removing
+// quoted text and signatures from the text body.
+func ParseMIME(r io.Reader) (*Mail, error) {
+	msg, err := mail.ReadMessage(io.LimitReader(r, MaxMIMESize))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mail{}
+
+	dec := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+	if from, err := parseAddress(dec, msg.Header.Get("From")); err == nil {
+		m.FromName = from.Name
+		m.From = from.Address
+	}
+
+	// Values of each header keep their order, names are sorted so headers
+	// are stored in a deterministic way.
+	names := make([]string, 0, len(msg.Header))
+	for name := range msg.Header {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range msg.Header[name] {
+			if s, err := dec.DecodeHeader(value); err == nil {
+				value = s
+			}
+
+			m.Headers = append(m.Headers, Header{Name: name, Value: value})
+		}
+	}
+
+	m.OriginalRecipient, m.MailboxHash = inboundRecipient(dec, msg.Header)
+
+	var text, html string
+
+	err = walkPart(mimeHeader(msg.Header), msg.Body, 0, func(p *part) error {
+		switch {
+		case p.isAttachment():
+			m.Attachments = append(m.Attachments, Attachment{
+				Name:          p.filename,
+				ContentType:   p.mediaType,
+				Content:       base64.StdEncoding.EncodeToString(p.body),
+				ContentLength: len(p.body),
+			})
+		case p.mediaType == "text/plain" && text == "":
+			text = string(p.body)
+		case p.mediaType == "text/html" && html == "":
+			html = string(p.body)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if text == "" && html != "" {
+		text = HTMLToText(html)
+	}
+
+	m.TextBody = normalizeNewlines(text)
+	m.StrippedTextReply = StripReply(m.TextBody)
+
+	return m, nil
+}
+
+// part is a single decoded leaf of a MIME message.
+type part struct {
+	mediaType   string
+	disposition string
+	filename    string
+	body        []byte
+}
+
+func (p *part) isAttachment() bool {
+	if p.disposition == "attachment" {
+		return true
+	}
+
+	if p.mediaType == "text/plain" || p.mediaType == "text/html" {
+		return false
+	}
+
+	return p.filename != ""
+}
+
+// mimeHeader converts mail.Header to the type multipart parts are using,
+// so both can be handled by walkPart.
+func mimeHeader(h mail.Header) map[string][]string {
+	return map[string][]string(h)
+}
+
+func headerGet(h map[string][]string, key string) string {
+	if v := h[key]; len(v) != 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// walkPart decodes the entity with the given header and body, descending
+// into multipart entities. The fn is called for every leaf part.
+func walkPart(h map[string][]string, body io.Reader, depth int, fn func(*part) error) error {
+	if depth > maxMultipartDepth {
+		return errMultipartTooDeep
+	}
+
+	contentType := headerGet(h, "Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Follow RFC 2045 and treat entities with broken
+		// content type as plain text.
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := walkPart(p.Header, p, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	p := &part{
+		mediaType: mediaType,
+	}
+
+	if cd := headerGet(h, "Content-Disposition"); cd != "" {
+		if disp, dparams, err := mime.ParseMediaType(cd); err == nil {
+			p.disposition = disp
+			p.filename = dparams["filename"]
+		}
+	}
+
+	if p.filename == "" {
+		p.filename = params["name"]
+	}
+
+	if p.filename != "" {
+		dec := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+		if s, err := dec.DecodeHeader(p.filename); err == nil {
+			p.filename = s
+		}
+	}
+
+	r := decodeTransfer(headerGet(h, "Content-Transfer-Encoding"), body)
+
+	if strings.HasPrefix(mediaType, "text/") && !p.isAttachment() {
+		if cs := params["charset"]; cs != "" {
+			if cr, err := charset.NewReaderLabel(cs, r); err == nil {
+				r = cr
+			}
+		}
+	}
+
+	if p.body, err = ioutil.ReadAll(r); err != nil {
+		return fmt.Errorf("reading %s part: %s", mediaType, err)
+	}
+
+	return fn(p)
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineSkipper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// newlineSkipper drops CR and LF characters, which base64 encoded parts
+// use to wrap lines.
+type newlineSkipper struct {
+	r io.Reader
+}
+
+func (n *newlineSkipper) Read(p []byte) (int, error) {
+	for {
+		k, err := n.r.Read(p)
+
+		j := 0
+		for _, c := range p[:k] {
+			if c != '\r' && c != '\n' {
+				p[j] = c
+				j++
+			}
+		}
+
+		if j != 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+func parseAddress(dec *mime.WordDecoder, s string) (*mail.Address, error) {
+	p := &mail.AddressParser{WordDecoder: dec}
+	return p.Parse(s)
+}
+
+// inboundRecipient looks for the first address which has a mailbox hash,
+// for example post+channelid.5678@inbound.koding.com, and returns it
+// together with the hash.
+func inboundRecipient(dec *mime.WordDecoder, h mail.Header) (recipient, hash string) {
+	p := &mail.AddressParser{WordDecoder: dec}
+
+	for _, key := range inboundHeaders {
+		for _, value := range h[key] {
+			addrs, err := p.ParseList(value)
+			if err != nil {
+				continue
+			}
+
+			for _, addr := range addrs {
+				if hash := mailboxHash(addr.Address); hash != "" {
+					return addr.Address, hash
+				}
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// mailboxHash returns the sub-address part of the given email address,
+// for example "channelid.5678" for post+channelid.5678@inbound.koding.com.
+func mailboxHash(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at == -1 {
+		return ""
+	}
+
+	local := address[:at]
+
+	plus := strings.Index(local, "+")
+	if plus == -1 {
+		return ""
+	}
+
+	return local[plus+1:]
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	s = strings.Replace(s, "\r", "\n", -1)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const multipartMail = "From: Cihangir Savas <cihangir@koding.com>\r\n" +
+	"To: \"Koding\" <reply+messageid.1234@inbound.koding.com>\r\n" +
+	"Subject: =?UTF-8?Q?Re:_test_=C3=A7?=\r\n" +
+	"Authentication-Results: mx.koding.com; dkim=pass header.d=koding.com; spf=neutral smtp.mailfrom=koding.com\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=ISO-8859-1\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Ol=E1 from mail\r\n" +
+	"\r\n" +
+	"On Sun, Sep 7, 2014 at 1:42 AM, Koding <reply+messageid.1234@inbound.koding.com> wrote:\r\n" +
+	"> previous message\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=UTF-8\r\n" +
+	"\r\n" +
+	"<div>Ol&aacute; from mail</div>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream; name=\"data.bin\"\r\n" +
+	"Content-Disposition: attachment; filename=\"data.bin\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"AAEC\r\n" +
+	"Aw==\r\n" +
+	"--outer--\r\n"
+
+const htmlMail = "From: rodin@koding.com\r\n" +
+	"Delivered-To: post+channelid.5678@inbound.koding.com\r\n" +
+	"To: team@koding.com\r\n" +
+	"Received-SPF: Pass (sender SPF authorized) envelope-from=<rodin@koding.com>; identity=mailfrom; receiver=mx.koding.com\r\n" +
+	"Content-Type: text/html; charset=UTF-8\r\n" +
+	"\r\n" +
+	"<html><head><style>p {}</style></head><body><p>first</p><p>second <b>line</b></p>" +
+	"<blockquote><p>quoted</p></blockquote></body></html>\r\n"
+
+func TestParseMIME(t *testing.T) {
+	Convey("while parsing MIME messages", t, func() {
+		Convey("multipart message should be parsed", func() {
+			m, err := ParseMIME(strings.NewReader(multipartMail))
+			So(err, ShouldBeNil)
+
+			So(m.From, ShouldEqual, "cihangir@koding.com")
+			So(m.FromName, ShouldEqual, "Cihangir Savas")
+			So(m.OriginalRecipient, ShouldEqual, "reply+messageid.1234@inbound.koding.com")
+			So(m.MailboxHash, ShouldEqual, "messageid.1234")
+			So(m.TextBody, ShouldStartWith, "Olá from mail")
+			So(m.StrippedTextReply, ShouldEqual, "Olá from mail")
+
+			So(m.Attachments, ShouldHaveLength, 1)
+			So(m.Attachments[0].Name, ShouldEqual, "data.bin")
+			So(m.Attachments[0].ContentType, ShouldEqual, "application/octet-stream")
+			So(m.Attachments[0].ContentLength, ShouldEqual, 4)
+			So(m.Attachments[0].Content, ShouldEqual, base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 3}))
+
+			So(m.Validate(), ShouldBeNil)
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+
+		Convey("html only message should be converted to text", func() {
+			m, err := ParseMIME(strings.NewReader(htmlMail))
+			So(err, ShouldBeNil)
+
+			So(m.OriginalRecipient, ShouldEqual, "post+channelid.5678@inbound.koding.com")
+			So(m.MailboxHash, ShouldEqual, "channelid.5678")
+			So(m.TextBody, ShouldEqual, "first\nsecond line\n> quoted")
+			So(m.StrippedTextReply, ShouldEqual, "first\nsecond line")
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+
+		Convey("malformed message should return error", func() {
+			_, err := ParseMIME(strings.NewReader("not a mail"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestStripReply(t *testing.T) {
+	Convey("while stripping replies", t, func() {
+		tests := map[string]string{
+			"reply\n\n> quoted": "reply",
+			"reply\n\nOn Mon, Jan 1, 2016 at 10:00 AM, Koding\n<a@b.com> wrote:\nquoted": "reply",
+			"reply\n-----Original Message-----\nquoted":                                  "reply",
+			"reply\n\n-- \nsignature":                                                    "reply",
+			"reply\n\nSent from my iPhone":                                               "reply",
+			"multi\nline\nreply":                                                         "multi\nline\nreply",
+		}
+
+		for body, want := range tests {
+			So(StripReply(body), ShouldEqual, want)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	Convey("while verifying senders", t, func() {
+		newMail := func(headers ...string) *Mail {
+			m := &Mail{From: "rodin@koding.com"}
+			for i := 0; i < len(headers); i += 2 {
+				m.Headers = append(m.Headers, Header{Name: headers[i], Value: headers[i+1]})
+			}
+			return m
+		}
+
+		Convey("mails without results should not be authenticated", func() {
+			So(newMail().Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("failed DKIM should be rejected", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; dkim=fail header.d=koding.com; spf=pass")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrDKIMFailed)
+		})
+
+		Convey("failed SPF should be rejected", func() {
+			m := newMail("Received-SPF", "SoftFail (domain does not designate) receiver=mx.koding.com;")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrSPFFailed)
+		})
+
+		Convey("passing DKIM of the sender domain should authenticate despite failed SPF", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; dkim=pass header.d=koding.com; spf=fail smtp.mailfrom=koding.com")
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+
+		Convey("passing DKIM signature of the sender domain should be preferred", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; dkim=pass header.d=example.com; dkim=fail header.d=koding.com; dkim=pass header.d=koding.com")
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+
+		Convey("Received-SPF of other receivers should be ignored", func() {
+			m := newMail("Received-SPF", "Pass (sender SPF authorized) envelope-from=<rodin@koding.com>;")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+
+			m = newMail("Received-SPF", "Pass (sender SPF authorized) envelope-from=<rodin@koding.com>; receiver=mx.example.com")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("headers below the topmost one may be forged and should be ignored", func() {
+			m := newMail(
+				"Received-SPF", "None (no SPF record) envelope-from=<rodin@koding.com>; receiver=mx.koding.com",
+				"Received-SPF", "Pass (sender SPF authorized) envelope-from=<rodin@koding.com>; receiver=mx.koding.com",
+			)
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+
+			m = newMail(
+				"Authentication-Results", "mx.koding.com; dkim=none",
+				"Authentication-Results", "mx.koding.com; dkim=pass header.d=koding.com",
+			)
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("SPF result of Authentication-Results should take precedence over Received-SPF", func() {
+			ar := "mx.koding.com; spf=fail smtp.mailfrom=koding.com"
+			spf := "Pass (sender SPF authorized) envelope-from=<rodin@koding.com>; receiver=mx.koding.com"
+
+			m := newMail("Authentication-Results", ar, "Received-SPF", spf)
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrSPFFailed)
+
+			m = newMail("Received-SPF", spf, "Authentication-Results", ar)
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrSPFFailed)
+		})
+
+		Convey("DKIM signature of a foreign domain should not authenticate", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; dkim=pass header.d=example.com")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("passing SPF of a foreign envelope sender should not authenticate", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; spf=pass smtp.mailfrom=attacker@example.com")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+
+			m = newMail("Received-SPF", "Pass (sender SPF authorized) envelope-from=<attacker@example.com>;")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("passing SPF of the sender domain should authenticate", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; spf=pass smtp.mailfrom=koding.com")
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+
+		Convey("results of a foreign authserv-id should be ignored", func() {
+			m := newMail("Authentication-Results", "mx.example.com; dkim=pass header.d=koding.com")
+			So(m.Verify("mx.koding.com"), ShouldEqual, ErrNotAuthenticated)
+			So(m.Verify(""), ShouldEqual, ErrNotAuthenticated)
+		})
+
+		Convey("passing DKIM of the sender domain should authenticate", func() {
+			m := newMail("Authentication-Results", "mx.koding.com; dkim=pass header.d=koding.com")
+			So(m.Verify("mx.koding.com"), ShouldBeNil)
+		})
+	})
+}
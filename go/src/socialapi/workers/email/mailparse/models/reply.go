@@ -0,0 +1,156 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// quoteHeaders matches lines mail clients put above the quoted
+	// message, e.g. "On Sun, Sep 7, 2014 at 1:42 AM, Cihangir <...> wrote:".
+	quoteHeaders = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^on\b.*\bwrote:$`),
+		regexp.MustCompile(`(?i)^-+\s*original message\s*-+$`),
+		regexp.MustCompile(`(?i)^-+\s*forwarded message\s*-+$`),
+		regexp.MustCompile(`(?i)^from:\s.*$`),
+		regexp.MustCompile(`^_{20,}$`),
+	}
+
+	// signatures matches lines that start a signature block.
+	signatures = []*regexp.Regexp{
+		regexp.MustCompile(`^--\s?$`),
+		regexp.MustCompile(`(?i)^sent from my\b.*$`),
+		regexp.MustCompile(`(?i)^get outlook for\b.*$`),
+	}
+
+	// multilineQuoteHeader matches "On ... wrote:" headers that were
+	// wrapped by the mail client.
+	multilineQuoteHeader = regexp.MustCompile(`(?is)^on\b[^\n]*\n[^\n]*\bwrote:$`)
+)
+
+// StripReply returns only the newly written part of the given text body,
+// dropping quoted text, quote headers and signatures.
+func StripReply(body string) string {
+	lines := strings.Split(normalizeNewlines(body), "\n")
+
+	end := len(lines)
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, ">") || matchAny(quoteHeaders, line) || matchAny(signatures, line) {
+			end = i
+			break
+		}
+
+		if i+1 < len(lines) {
+			joined := line + "\n" + strings.TrimSpace(lines[i+1])
+			if multilineQuoteHeader.MatchString(joined) {
+				end = i
+				break
+			}
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines[:end], "\n"))
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blockElements are HTML elements which are rendered on their own lines.
+var blockElements = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"blockquote": true,
+	"br":         true,
+	"div":        true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"hr":         true,
+	"li":         true,
+	"ol":         true,
+	"p":          true,
+	"pre":        true,
+	"section":    true,
+	"table":      true,
+	"tr":         true,
+	"ul":         true,
+}
+
+// HTMLToText converts the given HTML body to plain text. Quoted content
+// that is enclosed in blockquote elements is prefixed with "> ", so it
+// can be removed later on with StripReply.
+func HTMLToText(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	var (
+		buf   []string
+		line  string
+		quote int
+		skip  int
+	)
+
+	flush := func() {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			buf = append(buf, strings.Repeat("> ", quote)+line)
+		}
+		line = ""
+	}
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			flush()
+			return strings.Join(buf, "\n")
+		case html.TextToken:
+			if skip == 0 {
+				line += strings.Join(strings.Fields(string(z.Text())), " ") + " "
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+
+			switch {
+			case tag == "script" || tag == "style" || tag == "head":
+				skip++
+			case tag == "blockquote":
+				flush()
+				quote++
+			case blockElements[tag]:
+				flush()
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+
+			switch {
+			case tag == "script" || tag == "style" || tag == "head":
+				if skip > 0 {
+					skip--
+				}
+			case tag == "blockquote":
+				flush()
+				if quote > 0 {
+					quote--
+				}
+			case blockElements[tag]:
+				flush()
+			}
+		}
+	}
+}
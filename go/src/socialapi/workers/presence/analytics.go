@@ -0,0 +1,278 @@
+package presence
+
+import (
+	"math"
+	"socialapi/models"
+	"sort"
+	"time"
+)
+
+// minSessionLength is the activity length of a session with a single ping.
+const minSessionLength = time.Minute
+
+// Heatmap holds the active minutes of a member for each hour of the week,
+// the first index is the weekday starting with Sunday.
+type Heatmap [7][24]int
+
+// MemberUsage holds the activity and machine usage of a member.
+type MemberUsage struct {
+	AccountId int64 `json:"accountId,string"`
+
+	// ActiveMinutes is the time spent on Koding
+	ActiveMinutes int `json:"activeMinutes"`
+
+	// MachineMinutes is the time the member's machines were being used
+	MachineMinutes int `json:"machineMinutes"`
+
+	// OverlapMinutes is the time the member was active on Koding while
+	// using the machines
+	OverlapMinutes int `json:"overlapMinutes"`
+}
+
+// MachineUsageReport correlates the activity of the members with their
+// machine usage.
+type MachineUsageReport struct {
+	Members []*MemberUsage `json:"members"`
+
+	// Correlation is the Pearson correlation coefficient of the daily active
+	// and machine minutes of the members. It is zero when there is not
+	// enough data.
+	Correlation float64 `json:"correlation"`
+}
+
+type timeRange struct {
+	start, end time.Time
+}
+
+// NewHeatmap calculates the heatmap of the sessions in the given time
+// range. Hours are calculated in loc. Overlapping sessions, like web and
+// machine ones, are counted once.
+func NewHeatmap(sessions []models.PresenceSession, loc *time.Location, from, to time.Time) *Heatmap {
+	var durations [7][24]time.Duration
+
+	for _, r := range mergeRanges(clipSessions(sessions, from, to)) {
+		for t := r.start; t.Before(r.end); {
+			lt := t.In(loc)
+			next := time.Date(lt.Year(), lt.Month(), lt.Day(), lt.Hour()+1, 0, 0, 0, loc)
+			if next.After(r.end) {
+				next = r.end
+			}
+
+			durations[lt.Weekday()][lt.Hour()] += next.Sub(t)
+			t = next
+		}
+	}
+
+	h := &Heatmap{}
+	for day := range durations {
+		for hour, d := range durations[day] {
+			h[day][hour] = int(d / time.Minute)
+		}
+	}
+
+	return h
+}
+
+// NewMachineUsageReport calculates the machine usage of the members from
+// their web and machine sessions in the given time range.
+func NewMachineUsageReport(sessions []models.PresenceSession, from, to time.Time) *MachineUsageReport {
+	web := make(map[int64][]models.PresenceSession)
+	machine := make(map[int64][]models.PresenceSession)
+
+	for _, s := range sessions {
+		if s.Source == models.PresenceSourceMachine {
+			machine[s.AccountId] = append(machine[s.AccountId], s)
+		} else {
+			web[s.AccountId] = append(web[s.AccountId], s)
+		}
+	}
+
+	ids := make(map[int64]struct{})
+	for id := range web {
+		ids[id] = struct{}{}
+	}
+	for id := range machine {
+		ids[id] = struct{}{}
+	}
+
+	report := &MachineUsageReport{
+		Members: make([]*MemberUsage, 0, len(ids)),
+	}
+
+	var xs, ys []float64
+
+	for id := range ids {
+		w := mergeRanges(clipSessions(web[id], from, to))
+		m := mergeRanges(clipSessions(machine[id], from, to))
+
+		report.Members = append(report.Members, &MemberUsage{
+			AccountId:      id,
+			ActiveMinutes:  minutes(total(w)),
+			MachineMinutes: minutes(total(m)),
+			OverlapMinutes: minutes(total(intersect(w, m))),
+		})
+
+		wd, md := perDay(w), perDay(m)
+		for day := range union(wd, md) {
+			xs = append(xs, wd[day].Minutes())
+			ys = append(ys, md[day].Minutes())
+		}
+	}
+
+	sort.Slice(report.Members, func(i, j int) bool {
+		return report.Members[i].AccountId < report.Members[j].AccountId
+	})
+
+	report.Correlation = pearson(xs, ys)
+
+	return report
+}
+
+// clipSessions converts sessions to time ranges within [from, to).
+func clipSessions(sessions []models.PresenceSession, from, to time.Time) []timeRange {
+	ranges := make([]timeRange, 0, len(sessions))
+
+	for _, s := range sessions {
+		start, end := s.StartedAt, s.EndedAt
+		if end.Sub(start) < minSessionLength {
+			end = start.Add(minSessionLength)
+		}
+
+		if start.Before(from) {
+			start = from
+		}
+
+		if end.After(to) {
+			end = to
+		}
+
+		if start.Before(end) {
+			ranges = append(ranges, timeRange{start, end})
+		}
+	}
+
+	return ranges
+}
+
+// mergeRanges sorts ranges and merges the overlapping ones.
+func mergeRanges(ranges []timeRange) []timeRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Before(ranges[j].start)
+	})
+
+	merged := []timeRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start.After(last.end) {
+			merged = append(merged, r)
+			continue
+		}
+
+		if r.end.After(last.end) {
+			last.end = r.end
+		}
+	}
+
+	return merged
+}
+
+// intersect returns the overlapping parts of two merged range lists.
+func intersect(a, b []timeRange) []timeRange {
+	var res []timeRange
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].start, a[i].end
+		if b[j].start.After(start) {
+			start = b[j].start
+		}
+
+		if b[j].end.Before(end) {
+			end = b[j].end
+		}
+
+		if start.Before(end) {
+			res = append(res, timeRange{start, end})
+		}
+
+		if a[i].end.Before(b[j].end) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return res
+}
+
+// perDay splits ranges by UTC days.
+func perDay(ranges []timeRange) map[time.Time]time.Duration {
+	days := make(map[time.Time]time.Duration)
+
+	for _, r := range ranges {
+		for t := r.start; t.Before(r.end); {
+			day := t.UTC().Truncate(24 * time.Hour)
+			next := day.Add(24 * time.Hour)
+			if next.After(r.end) {
+				next = r.end
+			}
+
+			days[day] += next.Sub(t)
+			t = next
+		}
+	}
+
+	return days
+}
+
+func union(a, b map[time.Time]time.Duration) map[time.Time]struct{} {
+	keys := make(map[time.Time]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	return keys
+}
+
+func total(ranges []timeRange) time.Duration {
+	var d time.Duration
+	for _, r := range ranges {
+		d += r.end.Sub(r.start)
+	}
+
+	return d
+}
+
+func minutes(d time.Duration) int {
+	return int(d / time.Minute)
+}
+
+// pearson calculates the Pearson correlation coefficient of xs and ys.
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+
+	var sx, sy, sxx, syy, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		sxy += xs[i] * ys[i]
+	}
+
+	d := math.Sqrt(n*sxx-sx*sx) * math.Sqrt(n*syy-sy*sy)
+	if d == 0 {
+		return 0
+	}
+
+	return (n*sxy - sx*sy) / d
+}
@@ -0,0 +1,121 @@
+package presence
+
+import (
+	"socialapi/models"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func session(accountId int64, source string, start time.Time, d time.Duration) models.PresenceSession {
+	return models.PresenceSession{
+		AccountId: accountId,
+		Source:    source,
+		StartedAt: start,
+		EndedAt:   start.Add(d),
+	}
+}
+
+func TestContinues(t *testing.T) {
+	Convey("Given a session", t, func() {
+		now := time.Date(2016, 9, 21, 13, 0, 0, 0, time.UTC)
+		s := session(1, models.PresenceSourceWeb, now, 10*time.Minute)
+
+		Convey("pings within the idle timeout should extend it", func() {
+			So(continues(&s, now.Add(12*time.Minute), 5*time.Minute), ShouldBeTrue)
+			So(continues(&s, now.Add(5*time.Minute), 5*time.Minute), ShouldBeTrue)
+		})
+
+		Convey("pings after the idle timeout should start a new one", func() {
+			So(continues(&s, now.Add(16*time.Minute), 5*time.Minute), ShouldBeFalse)
+		})
+
+		Convey("pings before the session should start a new one", func() {
+			So(continues(&s, now.Add(-time.Minute), 5*time.Minute), ShouldBeFalse)
+		})
+	})
+}
+
+func TestHeatmap(t *testing.T) {
+	Convey("Given sessions of a member", t, func() {
+		// 2016-09-18 is a Sunday
+		start := time.Date(2016, 9, 18, 9, 30, 0, 0, time.UTC)
+		from, to := start.Add(-24*time.Hour), start.Add(7*24*time.Hour)
+
+		sessions := []models.PresenceSession{
+			session(1, models.PresenceSourceWeb, start, time.Hour),
+			// overlapping machine session should not be counted twice
+			session(1, models.PresenceSourceMachine, start.Add(45*time.Minute), 30*time.Minute),
+			// single ping session
+			session(1, models.PresenceSourceWeb, start.Add(24*time.Hour), 0),
+		}
+
+		Convey("active minutes should be split into hours", func() {
+			h := NewHeatmap(sessions, time.UTC, from, to)
+			So(h[time.Sunday][9], ShouldEqual, 30)
+			So(h[time.Sunday][10], ShouldEqual, 45)
+			So(h[time.Monday][9], ShouldEqual, 1)
+		})
+
+		Convey("hours should be calculated in the given location", func() {
+			loc := time.FixedZone("UTC+3", 3*60*60)
+			h := NewHeatmap(sessions, loc, from, to)
+			So(h[time.Sunday][12], ShouldEqual, 30)
+			So(h[time.Sunday][13], ShouldEqual, 45)
+		})
+
+		Convey("sessions should be clipped to the time range", func() {
+			h := NewHeatmap(sessions, time.UTC, from, start.Add(10*time.Minute))
+			So(h[time.Sunday][9], ShouldEqual, 10)
+			So(h[time.Sunday][10], ShouldEqual, 0)
+		})
+	})
+}
+
+func TestMachineUsageReport(t *testing.T) {
+	Convey("Given sessions of members", t, func() {
+		day := time.Date(2016, 9, 18, 0, 0, 0, 0, time.UTC)
+		from, to := day, day.Add(3*24*time.Hour)
+
+		var sessions []models.PresenceSession
+		for i := 0; i < 3; i++ {
+			d := day.Add(time.Duration(i) * 24 * time.Hour)
+			active := time.Duration(i+1) * time.Hour
+
+			// member 1 uses machines while being active
+			sessions = append(sessions,
+				session(1, models.PresenceSourceWeb, d, active),
+				session(1, models.PresenceSourceMachine, d.Add(30*time.Minute), active),
+			)
+		}
+
+		// member 2 is never using machines
+		sessions = append(sessions, session(2, models.PresenceSourceWeb, day, 2*time.Hour))
+
+		r := NewMachineUsageReport(sessions, from, to)
+
+		Convey("usage of every member should be calculated", func() {
+			So(r.Members, ShouldHaveLength, 2)
+
+			So(r.Members[0].AccountId, ShouldEqual, 1)
+			So(r.Members[0].ActiveMinutes, ShouldEqual, 360)
+			So(r.Members[0].MachineMinutes, ShouldEqual, 360)
+			So(r.Members[0].OverlapMinutes, ShouldEqual, 270)
+
+			So(r.Members[1].AccountId, ShouldEqual, 2)
+			So(r.Members[1].ActiveMinutes, ShouldEqual, 120)
+			So(r.Members[1].MachineMinutes, ShouldEqual, 0)
+			So(r.Members[1].OverlapMinutes, ShouldEqual, 0)
+		})
+
+		Convey("daily activity should correlate with machine usage", func() {
+			So(r.Correlation, ShouldBeGreaterThan, 0.5)
+			So(r.Correlation, ShouldBeLessThanOrEqualTo, 1)
+		})
+
+		Convey("empty data should not correlate", func() {
+			So(NewMachineUsageReport(nil, from, to).Correlation, ShouldEqual, 0)
+		})
+	})
+}
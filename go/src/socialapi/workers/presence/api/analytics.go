@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"socialapi/models"
+	"socialapi/workers/common/response"
+	"socialapi/workers/presence"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRange is used when the time range is not given
+	defaultRange = 30 * 24 * time.Hour
+
+	// maxRange limits the time range of the analytics queries
+	maxRange = 366 * 24 * time.Hour
+)
+
+var (
+	errInvalidRange    = errors.New("from should be before to")
+	errRangeTooLong    = errors.New("time range can not be longer than a year")
+	errInvalidTimeZone = errors.New("tz should be a valid time zone name")
+)
+
+// CountActive counts the daily or weekly active members of the group
+func CountActive(u *url.URL, h http.Header, _ interface{}, context *models.Context) (int, http.Header, interface{}, error) {
+	if err := context.CanManage(); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	from, to, err := getTimeRange(u)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	interval := u.Query().Get("interval")
+	if interval == "" {
+		interval = models.PresenceIntervalDay
+	}
+
+	s := &models.PresenceSession{}
+	return response.HandleResultAndError(s.CountActive(context.GroupName, interval, from, to))
+}
+
+// Heatmap returns the activity heatmap of a member. Members can see their own
+// heatmap, admins can see every member's.
+func Heatmap(u *url.URL, h http.Header, _ interface{}, context *models.Context) (int, http.Header, interface{}, error) {
+	if !context.IsLoggedIn() {
+		return response.NewBadRequest(models.ErrNotLoggedIn)
+	}
+
+	accountId := context.Client.Account.Id
+	if id := u.Query().Get("accountId"); id != "" {
+		var err error
+		if accountId, err = strconv.ParseInt(id, 10, 64); err != nil {
+			return response.NewBadRequest(err)
+		}
+	}
+
+	if accountId != context.Client.Account.Id {
+		if err := context.CanManage(); err != nil {
+			return response.NewAccessDenied(err)
+		}
+	}
+
+	from, to, err := getTimeRange(u)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	loc := time.UTC
+	if tz := u.Query().Get("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return response.NewBadRequest(errInvalidTimeZone)
+		}
+	}
+
+	sessions, err := (&models.PresenceSession{}).FetchInRange(context.GroupName, accountId, from, to)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	return response.NewOK(presence.NewHeatmap(sessions, loc, from, to))
+}
+
+// MachineUsage correlates the activity of the members with their machine
+// usage
+func MachineUsage(u *url.URL, h http.Header, _ interface{}, context *models.Context) (int, http.Header, interface{}, error) {
+	if err := context.CanManage(); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	from, to, err := getTimeRange(u)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	sessions, err := (&models.PresenceSession{}).FetchInRange(context.GroupName, 0, from, to)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	return response.NewOK(presence.NewMachineUsageReport(sessions, from, to))
+}
+
+// getTimeRange parses from and to query parameters, both of them are
+// optional and can be given either as a date or in RFC 3339 format. The
+// last 30 days are used by default.
+func getTimeRange(u *url.URL) (from, to time.Time, err error) {
+	to = time.Now().UTC()
+	if s := u.Query().Get("to"); s != "" {
+		if to, err = parseTime(s); err != nil {
+			return
+		}
+	}
+
+	from = to.Add(-defaultRange)
+	if s := u.Query().Get("from"); s != "" {
+		if from, err = parseTime(s); err != nil {
+			return
+		}
+	}
+
+	switch {
+	case !from.Before(to):
+		err = errInvalidRange
+	case to.Sub(from) > maxRange:
+		err = errRangeTooLong
+	}
+
+	return
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
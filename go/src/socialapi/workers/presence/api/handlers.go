@@ -47,6 +47,30 @@ func AddHandlers(m *mux.Mux) {
 			Ratelimit: httpRateLimiter,
 		},
 	)
+	m.AddHandler(
+		handler.Request{
+			Handler:  CountActive,
+			Name:     "presence-analytics-active",
+			Type:     handler.GetRequest,
+			Endpoint: presence.EndpointPresenceActive,
+		},
+	)
+	m.AddHandler(
+		handler.Request{
+			Handler:  Heatmap,
+			Name:     "presence-analytics-heatmap",
+			Type:     handler.GetRequest,
+			Endpoint: presence.EndpointPresenceHeatmap,
+		},
+	)
+	m.AddHandler(
+		handler.Request{
+			Handler:  MachineUsage,
+			Name:     "presence-analytics-machines",
+			Type:     handler.GetRequest,
+			Endpoint: presence.EndpointPresenceMachineUsage,
+		},
+	)
 }
 
 // ListMembers lists the members of group
@@ -94,6 +118,7 @@ func HandlePrivatePing(u *url.URL, h http.Header, req *presence.PrivatePing) (in
 	ping := &presence.Ping{
 		GroupName: req.GroupName,
 		AccountID: acc.Id, // if client is logged in, those values are all set
+		Source:    models.PresenceSourceMachine,
 	}
 
 	return handlePing(u, h, ping)
@@ -114,6 +139,10 @@ func handlePing(u *url.URL, h http.Header, req *presence.Ping) (int, http.Header
 
 	req.CreatedAt = time.Now().UTC()
 
+	if req.Source == "" {
+		req.Source = models.PresenceSourceWeb
+	}
+
 	// send the ping request to the related worker
 	if err := bongo.B.PublishEvent(presence.EventName, req); err != nil {
 		return response.NewBadRequest(err)
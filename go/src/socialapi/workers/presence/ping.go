@@ -22,6 +22,10 @@ type Ping struct {
 	// CreatedAt holds the ping time
 	CreatedAt time.Time `json:"createdAt"`
 
+	// Source holds where the ping is coming from, web or machine. Empty
+	// source is treated as web
+	Source string `json:"source,omitempty"`
+
 	// paymentStatus is populated on handler
 	paymentStatus string
 }
@@ -26,6 +26,16 @@ const (
 
 	// EndpointPresencePingPrivate provides private ping endpoint
 	EndpointPresencePingPrivate = "/private/presence/ping"
+
+	// EndpointPresenceActive counts daily or weekly active members
+	EndpointPresenceActive = "/presence/analytics/active"
+
+	// EndpointPresenceHeatmap provides activity heatmap of a member
+	EndpointPresenceHeatmap = "/presence/analytics/heatmap"
+
+	// EndpointPresenceMachineUsage correlates members' activity with their
+	// machine usage
+	EndpointPresenceMachineUsage = "/presence/analytics/machines"
 )
 
 const (
@@ -85,7 +95,11 @@ func (c *Controller) Ping(ping *Ping) error {
 	ping.paymentStatus = status
 
 	today := getTodayBeginningDate()
-	return verifyRecord(ping, today)
+	if err := verifyRecord(ping, today); err != nil {
+		return err
+	}
+
+	return trackSession(ping)
 }
 
 // verifyRecord checks if the daily occurrence is in the db, if not found creates
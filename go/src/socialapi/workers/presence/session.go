@@ -0,0 +1,42 @@
+package presence
+
+import (
+	"socialapi/models"
+	"time"
+)
+
+// IdleTimeouts holds the durations without any ping after which a session
+// of the given source is considered as ended. Browsers ping every 20 seconds
+// while machine pings are cached for a minute by kloud.
+var IdleTimeouts = map[string]time.Duration{
+	models.PresenceSourceWeb:     5 * time.Minute,
+	models.PresenceSourceMachine: 15 * time.Minute,
+}
+
+func sourceOf(ping *Ping) string {
+	if ping.Source == "" {
+		return models.PresenceSourceWeb
+	}
+
+	return ping.Source
+}
+
+// trackSession extends the last session of the account with the ping, or
+// starts a new one if the account was idle
+func trackSession(ping *Ping) error {
+	source := sourceOf(ping)
+
+	s := &models.PresenceSession{}
+	return s.Track(ping.GroupName, ping.AccountID, source, ping.CreatedAt, func(last *models.PresenceSession) bool {
+		return continues(last, ping.CreatedAt, IdleTimeouts[source])
+	})
+}
+
+// continues checks if a ping at time t belongs to session s
+func continues(s *models.PresenceSession, t time.Time, idleTimeout time.Duration) bool {
+	if t.Before(s.StartedAt) {
+		return false
+	}
+
+	return t.Sub(s.EndedAt) <= idleTimeout
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	kodingmodels "koding/db/models"
 	"koding/db/mongodb/modelhelper"
 	"net/http"
 	"net/url"
@@ -32,6 +33,12 @@ type SlackMessageRequest struct {
 	Params  slack.PostMessageParameters
 }
 
+// SlackBridgeRequest carries Slack bridge configuration from client side
+type SlackBridgeRequest struct {
+	Enabled  bool                               `json:"enabled"`
+	Channels []*kodingmodels.SlackBridgeChannel `json:"channels"`
+}
+
 // Send initiates Slack OAuth
 func (s *Slack) Send(u *url.URL, h http.Header, _ interface{}, context *models.Context) (int, http.Header, interface{}, error) {
 	if !context.IsLoggedIn() {
@@ -207,6 +214,66 @@ func (s *Slack) PostMessage(u *url.URL, h http.Header, req *SlackMessageRequest,
 	return response.HandleResultAndError(postMessage(token, req))
 }
 
+// GetBridge returns the Slack bridge configuration of the group
+func (s *Slack) GetBridge(u *url.URL, h http.Header, _ interface{}, context *models.Context) (int, http.Header, interface{}, error) {
+	if err := context.CanManage(); err != nil {
+		return response.NewAccessDenied(err)
+	}
+
+	sb, err := modelhelper.FetchSlackBridge(context.GroupName)
+	if err == mgo.ErrNotFound {
+		return response.NewNotFound()
+	}
+
+	return response.HandleResultAndError(sb, err)
+}
+
+// UpdateBridge configures the Slack bridge of the group, the bridge posts
+// to Slack with the token of the admin that configured it
+func (s *Slack) UpdateBridge(u *url.URL, h http.Header, req *SlackBridgeRequest, context *models.Context) (int, http.Header, interface{}, error) {
+	if err := context.CanManage(); err != nil {
+		return response.NewAccessDenied(err)
+	}
+
+	token, err := getSlackToken(context)
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	for _, pair := range req.Channels {
+		ch, err := models.Cache.Channel.ById(pair.ChannelID)
+		if err != nil {
+			return response.NewBadRequest(err)
+		}
+
+		if ch.GroupName != context.GroupName {
+			return response.NewBadRequest(models.ErrCannotOpenChannel)
+		}
+	}
+
+	auth, err := slack.New(token).AuthTest()
+	if err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	sb := &kodingmodels.SlackBridge{
+		Enabled:  req.Enabled,
+		TeamID:   auth.TeamID,
+		Token:    token,
+		Channels: req.Channels,
+	}
+
+	if old, err := modelhelper.FetchSlackBridge(context.GroupName); err == nil && old.TeamID == sb.TeamID {
+		sb.BotUserID = old.BotUserID
+	}
+
+	if err := modelhelper.UpsertSlackBridge(context.GroupName, sb); err != nil {
+		return response.NewBadRequest(err)
+	}
+
+	return response.NewOK(sb)
+}
+
 // SlashCommand handles slash commands coming from slack
 //
 // Note: this is experimental, on prod instances this will just say hi,
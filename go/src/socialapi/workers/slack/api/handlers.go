@@ -5,7 +5,9 @@ import (
 	"socialapi/models"
 	"socialapi/workers/common/handler"
 	"socialapi/workers/common/mux"
+	"socialapi/workers/slack/bridge"
 
+	"github.com/koding/runner"
 	"golang.org/x/oauth2"
 )
 
@@ -138,6 +140,24 @@ func AddHandlers(m *mux.Mux, config *config.Config) {
 		},
 	)
 
+	m.AddHandler(
+		handler.Request{
+			Handler:  s.GetBridge,
+			Name:     models.SlackGetBridge,
+			Type:     handler.GetRequest,
+			Endpoint: "/slack/bridge",
+		},
+	)
+
+	m.AddHandler(
+		handler.Request{
+			Handler:  s.UpdateBridge,
+			Name:     models.SlackUpdateBridge,
+			Type:     handler.PostRequest,
+			Endpoint: "/slack/bridge",
+		},
+	)
+
 	m.AddUnscopedHandler(
 		handler.Request{
 			Handler:  s.SlashCommand,
@@ -146,4 +166,15 @@ func AddHandlers(m *mux.Mux, config *config.Config) {
 			Endpoint: "/slack/slash",
 		},
 	)
+
+	b := bridge.New(runner.MustGetLogger(), config)
+
+	m.AddUnscopedHandler(
+		handler.Request{
+			Handler:  b.Events,
+			Name:     models.SlackEvents,
+			Type:     handler.PostRequest,
+			Endpoint: "/slack/events",
+		},
+	)
 }
@@ -0,0 +1,170 @@
+// Package bridge mirrors socialapi channels of a group to Slack channels in
+// both directions.
+//
+// Messages created in a bridged channel are posted to its Slack pair by the
+// bridge worker, which listens on ChannelMessage and MessageReply events.
+// Messages written on Slack are received through the Slack Events API and
+// created on behalf of the Koding account with the same email address.
+//
+// Every mirrored message is recorded as a SlackMessageLink, links are used
+// for threading replies on both sides. Messages that originate from Slack
+// carry the "origin=slack" payload, thus they are never posted back; posts
+// of the bridge itself are sent as bot messages and ignored when they come
+// back through the Events API.
+package bridge
+
+import (
+	kodingmodels "koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"socialapi/config"
+	"socialapi/models"
+
+	"github.com/koding/logging"
+	"github.com/streadway/amqp"
+	mgo "gopkg.in/mgo.v2"
+)
+
+const (
+	// PayloadKeyOrigin is the message payload key that holds where the
+	// message was created.
+	PayloadKeyOrigin = "origin"
+
+	// OriginSlack marks messages created from Slack events.
+	OriginSlack = "slack"
+)
+
+// Controller mirrors messages between socialapi and Slack.
+type Controller struct {
+	log               logging.Logger
+	client            Client
+	verificationToken string
+}
+
+// New creates a controller that talks to the Slack Web API.
+func New(log logging.Logger, conf *config.Config) *Controller {
+	return NewWithClient(log, &APIClient{}, conf.Slack.VerificationToken)
+}
+
+// NewWithClient creates a controller with the given Slack client.
+func NewWithClient(log logging.Logger, client Client, verificationToken string) *Controller {
+	return &Controller{
+		log:               log,
+		client:            client,
+		verificationToken: verificationToken,
+	}
+}
+
+// DefaultErrHandler handles the errors, we dont need to ack a message,
+// continue to the success
+func (c *Controller) DefaultErrHandler(delivery amqp.Delivery, err error) bool {
+	c.log.Error("an error occurred putting message back to queue", err)
+	delivery.Nack(false, true)
+	return false
+}
+
+// MessageCreated posts new messages of bridged channels to Slack.
+func (c *Controller) MessageCreated(cm *models.ChannelMessage) error {
+	if cm.TypeConstant != models.ChannelMessage_TYPE_POST || IsFromSlack(cm) {
+		return nil
+	}
+
+	ch, err := models.Cache.Channel.ById(cm.InitialChannelId)
+	if err != nil {
+		return err
+	}
+
+	sb, err := fetchBridge(ch.GroupName)
+	if err != nil || sb == nil {
+		return err
+	}
+
+	pair := sb.ByChannelID(ch.Id)
+	if pair == nil {
+		return nil
+	}
+
+	return c.post(ch.GroupName, sb, pair.SlackChannelID, cm, "")
+}
+
+// ReplyCreated posts replies to the Slack thread of the parent message.
+// Replies of messages that were not mirrored are ignored.
+func (c *Controller) ReplyCreated(mr *models.MessageReply) error {
+	reply, err := models.Cache.Message.ById(mr.ReplyId)
+	if err != nil {
+		return err
+	}
+
+	if IsFromSlack(reply) {
+		return nil
+	}
+
+	parent, err := modelhelper.GetSlackMessageLinkByMessageID(mr.MessageId)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sb, err := fetchBridge(parent.GroupName)
+	if err != nil || sb == nil {
+		return err
+	}
+
+	// channel may be unbridged since the parent was posted
+	if sb.BySlackChannelID(parent.SlackChannelID) == nil {
+		return nil
+	}
+
+	return c.post(parent.GroupName, sb, parent.SlackChannelID, reply, parent.SlackTs)
+}
+
+func (c *Controller) post(groupName string, sb *kodingmodels.SlackBridge, slackChannelID string, cm *models.ChannelMessage, threadTs string) error {
+	acc, err := models.Cache.Account.ById(cm.AccountId)
+	if err != nil {
+		return err
+	}
+
+	ts, err := c.client.PostMessage(sb.Token, &OutgoingMessage{
+		Channel:  slackChannelID,
+		Text:     ToSlack(cm.Body),
+		Username: acc.Nick,
+		ThreadTs: threadTs,
+	})
+	if err != nil {
+		return err
+	}
+
+	return modelhelper.CreateSlackMessageLink(&kodingmodels.SlackMessageLink{
+		GroupName:      groupName,
+		MessageID:      cm.Id,
+		SlackChannelID: slackChannelID,
+		SlackTs:        ts,
+	})
+}
+
+// IsFromSlack returns true if the message was created from a Slack event.
+func IsFromSlack(cm *models.ChannelMessage) bool {
+	origin := cm.GetPayload(PayloadKeyOrigin)
+	return origin != nil && *origin == OriginSlack
+}
+
+// fetchBridge returns the enabled bridge configuration of the group, or nil
+// when the group is not bridged.
+func fetchBridge(groupName string) (*kodingmodels.SlackBridge, error) {
+	sb, err := modelhelper.FetchSlackBridge(groupName)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !sb.Enabled || sb.Token == "" {
+		return nil, nil
+	}
+
+	return sb, nil
+}
@@ -0,0 +1,169 @@
+package bridge
+
+import (
+	"encoding/json"
+	"koding/db/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/koding/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFormat(t *testing.T) {
+	Convey("while converting message bodies", t, func() {
+		Convey("Koding messages should be escaped for Slack", func() {
+			So(ToSlack("a < b && c > d"), ShouldEqual, "a &lt; b &amp;&amp; c &gt; d")
+		})
+
+		Convey("Slack references should be resolved", func() {
+			tests := map[string]string{
+				"see <https://koding.com|koding>":  "see https://koding.com",
+				"see <https://koding.com>":         "see https://koding.com",
+				"hi <@U024BE7LH|bob>":              "hi @bob",
+				"hi <@U024BE7LH>":                  "hi @U024BE7LH",
+				"in <#C024BE7LH|general>":          "in #general",
+				"<!channel> deploy":                "@channel deploy",
+				"mail <mailto:a@b.com|a@b.com>":    "mail a@b.com",
+				"a &lt; b &amp;&amp; c &gt; d":     "a < b && c > d",
+				"plain text without any reference": "plain text without any reference",
+			}
+
+			for text, want := range tests {
+				So(FromSlack(text), ShouldEqual, want)
+			}
+		})
+	})
+}
+
+func TestShouldMirror(t *testing.T) {
+	Convey("Given a bridge with a bot user", t, func() {
+		sb := &models.SlackBridge{BotUserID: "UBOT"}
+		ev := func() *MessageEvent {
+			return &MessageEvent{Type: "message", User: "U1", Text: "hi", Ts: "1.1", Channel: "C1"}
+		}
+
+		Convey("user messages should be mirrored", func() {
+			So(ShouldMirror(sb, ev()), ShouldBeTrue)
+		})
+
+		Convey("bot messages should not be mirrored", func() {
+			e := ev()
+			e.BotID = "B1"
+			So(ShouldMirror(sb, e), ShouldBeFalse)
+
+			e = ev()
+			e.Subtype = "bot_message"
+			So(ShouldMirror(sb, e), ShouldBeFalse)
+
+			e = ev()
+			e.User = "UBOT"
+			So(ShouldMirror(sb, e), ShouldBeFalse)
+		})
+
+		Convey("edits and empty messages should not be mirrored", func() {
+			e := ev()
+			e.Subtype = "message_changed"
+			So(ShouldMirror(sb, e), ShouldBeFalse)
+
+			e = ev()
+			e.Text = ""
+			So(ShouldMirror(sb, e), ShouldBeFalse)
+		})
+
+		Convey("replies should be detected by thread timestamp", func() {
+			e := ev()
+			So(e.IsReply(), ShouldBeFalse)
+
+			e.ThreadTs = e.Ts
+			So(e.IsReply(), ShouldBeFalse)
+
+			e.ThreadTs = "1.0"
+			So(e.IsReply(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestAPIClient(t *testing.T) {
+	Convey("Given a Slack API server", t, func() {
+		var form map[string]string
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+
+			form = make(map[string]string)
+			for k := range r.PostForm {
+				form[k] = r.PostForm.Get(k)
+			}
+
+			switch r.URL.Path {
+			case "/chat.postMessage":
+				w.Write([]byte(`{"ok":true,"ts":"1503435956.000247"}`))
+			case "/users.info":
+				w.Write([]byte(`{"ok":true,"user":{"profile":{"email":"rodin@koding.com"}}}`))
+			default:
+				w.Write([]byte(`{"ok":false,"error":"unknown_method"}`))
+			}
+		}))
+		defer ts.Close()
+
+		c := &APIClient{BaseURL: ts.URL}
+
+		Convey("replies should be posted to the parent thread", func() {
+			id, err := c.PostMessage("xoxb", &OutgoingMessage{
+				Channel:  "C1",
+				Text:     "hi",
+				Username: "rodin",
+				ThreadTs: "1.0",
+			})
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, "1503435956.000247")
+			So(form["token"], ShouldEqual, "xoxb")
+			So(form["thread_ts"], ShouldEqual, "1.0")
+			So(form["username"], ShouldEqual, "rodin")
+			So(form["as_user"], ShouldEqual, "false")
+		})
+
+		Convey("user email should be fetched", func() {
+			email, err := c.UserEmail("xoxb", "U1")
+			So(err, ShouldBeNil)
+			So(email, ShouldEqual, "rodin@koding.com")
+			So(form["user"], ShouldEqual, "U1")
+		})
+
+		Convey("api errors should be returned", func() {
+			c.BaseURL = ts.URL + "/unknown"
+			_, err := c.UserEmail("xoxb", "U1")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unknown_method")
+		})
+	})
+}
+
+func TestEvents(t *testing.T) {
+	Convey("Given an events handler", t, func() {
+		c := NewWithClient(logging.NewLogger("bridge_test"), &APIClient{}, "secret")
+
+		do := func(env *EventEnvelope) *httptest.ResponseRecorder {
+			p, err := json.Marshal(env)
+			So(err, ShouldBeNil)
+
+			rec := httptest.NewRecorder()
+			c.Events(rec, httptest.NewRequest("POST", "/slack/events", strings.NewReader(string(p))))
+			return rec
+		}
+
+		Convey("url verification should be answered with the challenge", func() {
+			rec := do(&EventEnvelope{Token: "secret", Type: EventTypeURLVerification, Challenge: "abc"})
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(rec.Body.String(), ShouldContainSubstring, `"challenge":"abc"`)
+		})
+
+		Convey("requests with invalid token should be rejected", func() {
+			rec := do(&EventEnvelope{Token: "other", Type: EventTypeURLVerification, Challenge: "abc"})
+			So(rec.Code, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the base URL of the Slack Web API.
+const DefaultAPIURL = "https://slack.com/api"
+
+// OutgoingMessage is a message posted to a Slack channel.
+type OutgoingMessage struct {
+	Channel string
+
+	// Text is the already escaped message body.
+	Text string
+
+	// Username and IconURL override the bot identity, so the message shows
+	// up as it was sent by the Koding user.
+	Username string
+	IconURL  string
+
+	// ThreadTs is the timestamp of the parent message when posting a reply.
+	ThreadTs string
+}
+
+// Client is the subset of the Slack Web API used by the bridge.
+type Client interface {
+	// PostMessage posts the message and returns its Slack timestamp.
+	PostMessage(token string, msg *OutgoingMessage) (string, error)
+
+	// UserEmail returns the email address of the given Slack user.
+	UserEmail(token, userID string) (string, error)
+}
+
+// APIClient is a Client that talks to the Slack Web API over HTTP.
+//
+// The vendored slack package does not support threads, thus the required
+// endpoints are called directly.
+type APIClient struct {
+	// BaseURL of the Web API, DefaultAPIURL is used when empty.
+	BaseURL string
+
+	// HTTPClient is used for requests, a client with a 30s timeout is used
+	// when nil.
+	HTTPClient *http.Client
+}
+
+var _ Client = (*APIClient)(nil)
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// apiResponse is the envelope of every Web API response.
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// apiResult is implemented by responses embedding apiResponse.
+type apiResult interface {
+	result() *apiResponse
+}
+
+// PostMessage implements the Client interface.
+func (c *APIClient) PostMessage(token string, msg *OutgoingMessage) (string, error) {
+	v := url.Values{
+		"channel":      {msg.Channel},
+		"text":         {msg.Text},
+		"as_user":      {"false"},
+		"unfurl_links": {"true"},
+	}
+
+	if msg.Username != "" {
+		v.Set("username", msg.Username)
+	}
+
+	if msg.IconURL != "" {
+		v.Set("icon_url", msg.IconURL)
+	}
+
+	if msg.ThreadTs != "" {
+		v.Set("thread_ts", msg.ThreadTs)
+	}
+
+	var resp struct {
+		apiResponse
+		Ts string `json:"ts"`
+	}
+
+	if err := c.call("chat.postMessage", token, v, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Ts, nil
+}
+
+// UserEmail implements the Client interface.
+func (c *APIClient) UserEmail(token, userID string) (string, error) {
+	var resp struct {
+		apiResponse
+		User struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+
+	if err := c.call("users.info", token, url.Values{"user": {userID}}, &resp); err != nil {
+		return "", err
+	}
+
+	if resp.User.Profile.Email == "" {
+		return "", errors.New("slack user has no email")
+	}
+
+	return resp.User.Profile.Email, nil
+}
+
+// call invokes the Web API method and decodes the response into v, which
+// must embed apiResponse.
+func (c *APIClient) call(method, token string, params url.Values, v apiResult) error {
+	params.Set("token", token)
+
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultAPIURL
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	resp, err := client.PostForm(strings.TrimRight(base, "/")+"/"+method, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s returned %s", method, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return err
+	}
+
+	if r := v.result(); !r.OK {
+		return fmt.Errorf("slack: %s failed: %s", method, r.Error)
+	}
+
+	return nil
+}
+
+func (r *apiResponse) result() *apiResponse { return r }
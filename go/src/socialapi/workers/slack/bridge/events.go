@@ -0,0 +1,264 @@
+package bridge
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	kodingmodels "koding/db/models"
+	"koding/db/mongodb/modelhelper"
+	"net/http"
+	"socialapi/models"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Slack Events API request types.
+const (
+	EventTypeURLVerification = "url_verification"
+	EventTypeCallback        = "event_callback"
+)
+
+// maxEventSize limits the size of the Events API request bodies.
+const maxEventSize = 1 << 20
+
+// ErrAccountNotFound is returned when a Slack user can not be mapped to a
+// Koding account.
+var ErrAccountNotFound = errors.New("no koding account for slack user")
+
+// EventEnvelope is the body of the Slack Events API requests.
+type EventEnvelope struct {
+	Token     string        `json:"token"`
+	Type      string        `json:"type"`
+	Challenge string        `json:"challenge,omitempty"`
+	TeamID    string        `json:"team_id"`
+	Event     *MessageEvent `json:"event,omitempty"`
+}
+
+// MessageEvent is a message event sent by the Slack Events API.
+type MessageEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype,omitempty"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id,omitempty"`
+	Text     string `json:"text"`
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts,omitempty"`
+}
+
+// IsReply returns true if the event is a reply in a thread.
+func (ev *MessageEvent) IsReply() bool {
+	return ev.ThreadTs != "" && ev.ThreadTs != ev.Ts
+}
+
+// ShouldMirror decides whether the event should be mirrored to the bridged
+// channel. Only plain user messages are mirrored; edits, joins and
+// everything sent by bots, including the bridge itself, are ignored.
+func ShouldMirror(sb *kodingmodels.SlackBridge, ev *MessageEvent) bool {
+	if ev == nil || ev.Type != "message" || ev.Subtype != "" {
+		return false
+	}
+
+	if ev.BotID != "" || ev.User == "" || ev.User == sb.BotUserID {
+		return false
+	}
+
+	return ev.Text != ""
+}
+
+// Events handles the Slack Events API requests.
+//
+// Slack retries requests which are not responded within 3 seconds, thus
+// events are acknowledged before they are mirrored in the background.
+// Failures of mirroring are only logged, retries of events that were
+// acknowledged already are deduplicated by the message links.
+func (c *Controller) Events(w http.ResponseWriter, req *http.Request) {
+	var env EventEnvelope
+
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxEventSize)).Decode(&env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if c.verificationToken == "" || subtle.ConstantTimeCompare([]byte(env.Token), []byte(c.verificationToken)) != 1 {
+		http.Error(w, "request from unidentified source", http.StatusForbidden)
+		return
+	}
+
+	switch env.Type {
+	case EventTypeURLVerification:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"challenge": env.Challenge})
+	case EventTypeCallback:
+		w.WriteHeader(http.StatusOK)
+
+		if n := req.Header.Get("X-Slack-Retry-Num"); n != "" {
+			c.log.Debug("slack event of %s retried %s times: %s", env.TeamID, n, req.Header.Get("X-Slack-Retry-Reason"))
+		}
+
+		go func() {
+			if err := c.HandleEvent(env.TeamID, env.Event); err != nil {
+				c.log.Error("could not mirror slack event of %s: %s", env.TeamID, err)
+			}
+		}()
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleEvent creates the message of the Slack event in the bridged channel.
+func (c *Controller) HandleEvent(teamID string, ev *MessageEvent) error {
+	groupName, sb, err := modelhelper.FetchSlackBridgeByTeamID(teamID)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !sb.Enabled || !ShouldMirror(sb, ev) {
+		return nil
+	}
+
+	pair := sb.BySlackChannelID(ev.Channel)
+	if pair == nil {
+		return nil
+	}
+
+	// Slack may deliver events more than once, the link is claimed before
+	// the message is created so only one of the deliveries mirrors it
+	link := &kodingmodels.SlackMessageLink{
+		GroupName:      groupName,
+		SlackChannelID: ev.Channel,
+		SlackTs:        ev.Ts,
+	}
+
+	err = modelhelper.CreateSlackMessageLink(link)
+	if mgo.IsDup(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	messageID, err := c.mirror(groupName, sb, pair, ev)
+	if err != nil || messageID == 0 {
+		if e := modelhelper.DeleteSlackMessageLink(link.ObjectId); e != nil {
+			c.log.Error("could not release slack message link %s: %s", link.ObjectId.Hex(), e)
+		}
+
+		return err
+	}
+
+	return modelhelper.UpdateSlackMessageLinkMessageID(link.ObjectId, messageID)
+}
+
+// mirror creates the message of the Slack event and returns its id, or
+// zero when the event should not be mirrored.
+func (c *Controller) mirror(groupName string, sb *kodingmodels.SlackBridge, pair *kodingmodels.SlackBridgeChannel, ev *MessageEvent) (int64, error) {
+	accountID, err := c.accountOf(sb, ev.User)
+	if err == ErrAccountNotFound {
+		c.log.Debug("skipping message of unknown slack user %s of %s", ev.User, groupName)
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	ch, err := models.Cache.Channel.ById(pair.ChannelID)
+	if err != nil {
+		return 0, err
+	}
+
+	canOpen, err := ch.CanOpen(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	if !canOpen {
+		c.log.Debug("account %d can not open channel %d", accountID, ch.Id)
+		return 0, nil
+	}
+
+	cm := models.NewChannelMessage()
+	cm.Body = FromSlack(ev.Text)
+	cm.AccountId = accountID
+	cm.InitialChannelId = ch.Id
+	cm.SetPayload(PayloadKeyOrigin, OriginSlack)
+
+	if err := c.persist(ch, cm, ev); err != nil {
+		return 0, err
+	}
+
+	return cm.Id, nil
+}
+
+// persist creates cm as a reply when the event is a reply to a mirrored
+// message, otherwise as a post of the channel.
+func (c *Controller) persist(ch *models.Channel, cm *models.ChannelMessage, ev *MessageEvent) error {
+	if ev.IsReply() {
+		link, err := modelhelper.GetSlackMessageLinkBySlackTs(ev.Channel, ev.ThreadTs)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+
+		// link without a message is a parent that is still being mirrored
+		if err == nil && link.MessageID != 0 {
+			parent, err := models.Cache.Message.ById(link.MessageID)
+			if err != nil {
+				return err
+			}
+
+			cm.TypeConstant = models.ChannelMessage_TYPE_REPLY
+			if err := cm.Create(); err != nil {
+				return err
+			}
+
+			_, err = parent.AddReply(cm)
+			return err
+		}
+
+		// parent was written before the channel got bridged, post the
+		// reply to the channel instead of losing it
+	}
+
+	cm.TypeConstant = models.ChannelMessage_TYPE_POST
+	if err := cm.Create(); err != nil {
+		return err
+	}
+
+	_, err := ch.EnsureMessage(cm, true)
+	return err
+}
+
+// accountOf maps the Slack user to a socialapi account by email.
+func (c *Controller) accountOf(sb *kodingmodels.SlackBridge, slackUserID string) (int64, error) {
+	email, err := c.client.UserEmail(sb.Token, slackUserID)
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := modelhelper.FetchUserByEmail(email)
+	if err == mgo.ErrNotFound {
+		return 0, ErrAccountNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	acc, err := modelhelper.GetAccount(user.Name)
+	if err == mgo.ErrNotFound {
+		return 0, ErrAccountNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return acc.GetSocialApiId()
+}
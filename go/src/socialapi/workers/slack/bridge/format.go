@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	slackEscaper   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	slackUnescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+	// slackRef matches <...> references in Slack messages, eg:
+	//
+	//   <https://koding.com|koding>, <@U024BE7LH|bob>, <#C024BE7LH>
+	//
+	slackRef = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+)
+
+// ToSlack converts a socialapi message body to Slack message text.
+func ToSlack(body string) string {
+	return slackEscaper.Replace(body)
+}
+
+// FromSlack converts Slack message text to a socialapi message body. Links
+// are replaced with their URLs, user and channel references with their
+// labels when available.
+func FromSlack(text string) string {
+	text = slackRef.ReplaceAllStringFunc(text, func(ref string) string {
+		m := slackRef.FindStringSubmatch(ref)
+		target, label := m[1], m[2]
+
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if label != "" {
+				return "@" + label
+			}
+			return target
+		case strings.HasPrefix(target, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			// special commands, like <!channel> or <!here>
+			return "@" + strings.TrimPrefix(target, "!")
+		default:
+			return strings.TrimPrefix(target, "mailto:")
+		}
+	})
+
+	return slackUnescaper.Replace(text)
+}